@@ -64,14 +64,7 @@ func (m *PostgresAssetManager) GetPaginatedChartList(whereQuery string, whereQue
 		pageNumber = 1
 	}
 
-	paginationClause := ""
-	if pageSize > 0 {
-		offset := (pageNumber - 1) * pageSize
-		paginationClause = fmt.Sprintf("LIMIT %d OFFSET %d", pageSize, offset)
-	}
-
-	dbQuery := fmt.Sprintf("SELECT info FROM %s %s ORDER BY (info->>'name') ASC %s", dbutils.ChartTable, whereQuery, paginationClause)
-	charts, err := m.QueryAllCharts(dbQuery, whereQueryParams...)
+	charts, err := m.getChartList(whereQuery, whereQueryParams, "(info->>'name') ASC", (pageNumber-1)*pageSize, pageSize)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -88,6 +81,82 @@ func (m *PostgresAssetManager) GetPaginatedChartList(whereQuery string, whereQue
 	return charts, numPages, nil
 }
 
+// getChartList returns up to limit charts matching the where query in the
+// given order, starting at the given row offset. A limit of zero returns all
+// the remaining charts.
+func (m *PostgresAssetManager) getChartList(whereQuery string, whereQueryParams []interface{}, orderBy string, offset, limit int) ([]*models.Chart, error) {
+	paginationClause := ""
+	if limit > 0 {
+		paginationClause = fmt.Sprintf("LIMIT %d", limit)
+	}
+	if offset > 0 {
+		paginationClause = strings.TrimSpace(fmt.Sprintf("%s OFFSET %d", paginationClause, offset))
+	}
+
+	dbQuery := fmt.Sprintf("SELECT info FROM %s %s ORDER BY %s %s", dbutils.ChartTable, whereQuery, orderBy, paginationClause)
+	return m.QueryAllCharts(dbQuery, whereQueryParams...)
+}
+
+const (
+	// latestVersion is the latest version of a chart.
+	latestVersion = "(info->'chartVersions'->0->>'version')"
+	// latestPreRelease is the pre-release of the latest version of a chart.
+	latestPreRelease = `substring(` + latestVersion + ` from '^v?[0-9]+(?:\.[0-9]+){0,2}-([0-9A-Za-z.-]+)')`
+	// latestVersionIsSemantic is whether the latest version of a chart is a
+	// semantic version, as parsed by the kubeapps-apis core server: with an
+	// optional "v" prefix, minor and patch, and no numeric pre-release
+	// identifier with a leading zero.
+	latestVersionIsSemantic = `coalesce(` + latestVersion + ` ~ '^v?[0-9]+(\.[0-9]+)?(\.[0-9]+)?(-[0-9A-Za-z-]+(\.[0-9A-Za-z-]+)*)?(\+[0-9A-Za-z-]+(\.[0-9A-Za-z-]+)*)?$'` +
+		` AND coalesce(` + latestPreRelease + `, '') !~ '(^|\.)0[0-9]+(\.|$)', false)`
+)
+
+// latestVersionRankKey sorts the charts whose latest version is not a semantic
+// version after the others, whatever the direction of the order.
+var latestVersionRankKey = fmt.Sprintf("(NOT %s) ASC", latestVersionIsSemantic)
+
+// latestVersionOrderKeys are the expressions ordering charts by their latest
+// version, matching the order in which the kubeapps-apis core server merges
+// the results of the plugins. The versions which are not semantic versions
+// are compared lexically, after the semantic ones as ranked by
+// latestVersionRankKey. The semantic versions are compared by the numeric
+// major, minor and patch components, then with pre-releases before the
+// release, which are compared by their dot-separated identifiers (numeric
+// identifiers compare numerically and before alphanumeric ones). The build
+// metadata is ignored.
+var latestVersionOrderKeys = []string{
+	fmt.Sprintf(`(CASE WHEN NOT %s THEN coalesce(%s, '') END) COLLATE "C"`, latestVersionIsSemantic, latestVersion),
+	fmt.Sprintf(`substring(%s from '^v?([0-9]+)')::numeric`, latestVersion),
+	fmt.Sprintf(`coalesce(substring(%s from '^v?[0-9]+\.([0-9]+)'), '0')::numeric`, latestVersion),
+	fmt.Sprintf(`coalesce(substring(%s from '^v?[0-9]+\.[0-9]+\.([0-9]+)'), '0')::numeric`, latestVersion),
+	fmt.Sprintf(`(%s IS NULL)`, latestPreRelease),
+	// Numeric identifiers are prefixed by their length so that they compare
+	// numerically as text.
+	fmt.Sprintf(`ARRAY(SELECT CASE WHEN id ~ '^[0-9]+$' THEN '0' || lpad(length(id)::text, 4, '0') || id ELSE '1' || id END FROM unnest(string_to_array(%s, '.')) WITH ORDINALITY AS p(id, n) ORDER BY n) COLLATE "C"`, latestPreRelease),
+}
+
+// orderByClause returns the expressions of the ORDER BY clause for the chart
+// order. Text is compared byte-wise, as in the kubeapps-apis core server.
+func orderByClause(order ChartOrder) string {
+	direction := "ASC"
+	if order.Descending {
+		direction = "DESC"
+	}
+	clause := []string{}
+	keys := []string{}
+	switch order.Field {
+	case ChartOrderByLatestVersion:
+		clause = append(clause, latestVersionRankKey)
+		keys = append(keys, latestVersionOrderKeys...)
+	case ChartOrderByRepository:
+		keys = append(keys, `(info->'repo'->>'name') COLLATE "C"`)
+	}
+	keys = append(keys, `(info->>'name') COLLATE "C"`, `chart_id COLLATE "C"`)
+	for _, key := range keys {
+		clause = append(clause, fmt.Sprintf("%s %s", key, direction))
+	}
+	return strings.Join(clause, ", ")
+}
+
 func (m *PostgresAssetManager) GetChart(namespace, chartID string) (models.Chart, error) {
 	return m.GetChartWithFallback(namespace, chartID, enableFallbackQueryMode)
 }
@@ -208,6 +277,21 @@ func (m *PostgresAssetManager) GetPaginatedChartListWithFilters(cq ChartQuery, p
 	return charts, numPages, nil
 }
 
+// GetChartListWithFiltersFromOffset returns up to limit charts matching the
+// query in the given order, starting at the given row offset rather than at a
+// page boundary. This allows callers to resume a listing part-way through a page.
+func (m *PostgresAssetManager) GetChartListWithFiltersFromOffset(cq ChartQuery, order ChartOrder, offset, limit int) ([]*models.Chart, error) {
+	if offset < 0 {
+		offset = 0
+	}
+	whereQuery, whereQueryParams := m.GenerateWhereClause(cq)
+	charts, err := m.getChartList(whereQuery, whereQueryParams, orderByClause(order), offset, limit)
+	if err != nil {
+		return []*models.Chart{}, err
+	}
+	return charts, nil
+}
+
 func (m *PostgresAssetManager) GenerateWhereClause(cq ChartQuery) (string, []interface{}) {
 	whereClauses := []string{}
 	whereQueryParams := []interface{}{}
@@ -443,6 +443,84 @@ func Test_GetPaginatedChartList(t *testing.T) {
 	}
 }
 
+func Test_GetChartListWithFiltersFromOffset(t *testing.T) {
+	tests := []struct {
+		name          string
+		order         ChartOrder
+		offset        int
+		limit         int
+		expectedQuery string
+	}{
+		{
+			name:          "it uses both the offset and the limit",
+			offset:        3,
+			limit:         2,
+			expectedQuery: "ORDER BY \\(info->>'name'\\) COLLATE \"C\" ASC, chart_id COLLATE \"C\" ASC LIMIT 2 OFFSET 3$",
+		},
+		{
+			name:          "it does not limit the results without a limit",
+			offset:        3,
+			expectedQuery: "ORDER BY \\(info->>'name'\\) COLLATE \"C\" ASC, chart_id COLLATE \"C\" ASC OFFSET 3$",
+		},
+		{
+			name:          "it does not include an offset for the first row",
+			limit:         2,
+			expectedQuery: "ORDER BY \\(info->>'name'\\) COLLATE \"C\" ASC, chart_id COLLATE \"C\" ASC LIMIT 2$",
+		},
+		{
+			name:          "it ignores a negative offset",
+			offset:        -1,
+			expectedQuery: "ORDER BY \\(info->>'name'\\) COLLATE \"C\" ASC, chart_id COLLATE \"C\" ASC$",
+		},
+		{
+			name:          "it orders by name in descending order",
+			order:         ChartOrder{Field: ChartOrderByName, Descending: true},
+			expectedQuery: "ORDER BY \\(info->>'name'\\) COLLATE \"C\" DESC, chart_id COLLATE \"C\" DESC$",
+		},
+		{
+			name:          "it orders by the semantic latest version",
+			order:         ChartOrder{Field: ChartOrderByLatestVersion},
+			expectedQuery: "ORDER BY \\(NOT coalesce\\(.*\\)\\) ASC, \\(CASE WHEN NOT .* END\\) COLLATE \"C\" ASC, substring\\(\\(info->'chartVersions'->0->>'version'\\) from '\\^v\\?\\(\\[0-9\\]\\+\\)'\\)::numeric ASC, .*::numeric ASC, .*::numeric ASC, \\(substring\\(.*\\) IS NULL\\) ASC, ARRAY\\(.*\\) COLLATE \"C\" ASC, \\(info->>'name'\\) COLLATE \"C\" ASC, chart_id COLLATE \"C\" ASC$",
+		},
+		{
+			name:          "it orders the versions which are not semantic versions last in descending order",
+			order:         ChartOrder{Field: ChartOrderByLatestVersion, Descending: true},
+			expectedQuery: "ORDER BY \\(NOT coalesce\\(.*\\)\\) ASC, \\(CASE WHEN NOT .* END\\) COLLATE \"C\" DESC, substring\\(.*\\)::numeric DESC, .*, \\(info->>'name'\\) COLLATE \"C\" DESC, chart_id COLLATE \"C\" DESC$",
+		},
+		{
+			name:          "it orders by repository",
+			order:         ChartOrder{Field: ChartOrderByRepository, Descending: true},
+			expectedQuery: "ORDER BY \\(info->'repo'->>'name'\\) COLLATE \"C\" DESC, \\(info->>'name'\\) COLLATE \"C\" DESC, chart_id COLLATE \"C\" DESC$",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pgManager, mock, cleanup := getMockManager(t)
+			defer cleanup()
+
+			chart := &models.Chart{ID: "foo", ChartVersions: []models.ChartVersion{{Digest: "123"}}}
+			chartJSON, err := json.Marshal(chart)
+			if err != nil {
+				t.Fatalf("%+v", err)
+			}
+			mock.ExpectQuery(tt.expectedQuery).
+				WithArgs("namespace", "kubeapps").
+				WillReturnRows(sqlmock.NewRows([]string{"info"}).AddRow(string(chartJSON)))
+
+			charts, err := pgManager.GetChartListWithFiltersFromOffset(ChartQuery{Namespace: "namespace"}, tt.order, tt.offset, tt.limit)
+			if err != nil {
+				t.Fatalf("Found error %v", err)
+			}
+			if got, want := charts, []*models.Chart{chart}; !cmp.Equal(got, want) {
+				t.Errorf("mismatch (-want +got):\n%s", cmp.Diff(want, got))
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("there were unfulfilled expectations: %s", err)
+			}
+		})
+	}
+}
+
 func Test_GenerateWhereClause(t *testing.T) {
 	tests := []struct {
 		name           string
@@ -28,6 +28,7 @@ type AssetManager interface {
 	GetChartVersion(namespace, chartID, version string) (models.Chart, error)
 	GetChartFiles(namespace, filesID string) (models.ChartFiles, error)
 	GetPaginatedChartListWithFilters(cq ChartQuery, pageNumber, pageSize int) ([]*models.Chart, int, error)
+	GetChartListWithFiltersFromOffset(cq ChartQuery, order ChartOrder, offset, limit int) ([]*models.Chart, error)
 	GetAllChartCategories(cq ChartQuery) ([]*models.ChartCategory, error)
 }
 
@@ -42,6 +43,22 @@ type ChartQuery struct {
 	Categories  []string
 }
 
+// ChartOrderField is a field by which charts can be ordered
+type ChartOrderField int
+
+const (
+	ChartOrderByName ChartOrderField = iota
+	ChartOrderByLatestVersion
+	ChartOrderByRepository
+)
+
+// ChartOrder is a container for passing the order in which charts are listed.
+// Charts with equal values for the field are further ordered by name and ID.
+type ChartOrder struct {
+	Field      ChartOrderField
+	Descending bool
+}
+
 func NewManager(databaseType string, config datastore.Config, kubeappsNamespace string) (AssetManager, error) {
 	return NewPGManager(config, kubeappsNamespace)
 }
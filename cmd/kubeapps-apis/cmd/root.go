@@ -19,6 +19,7 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"time"
 
 	homedir "github.com/mitchellh/go-homedir"
 	"github.com/spf13/cobra"
@@ -41,6 +42,10 @@ var rootCmd = &cobra.Command{
 The api service serves both gRPC and HTTP requests for the configured APIs.`,
 
 	Run: func(cmd *cobra.Command, args []string) {
+		// Remote plugins are a list of structs which can only be configured in the config file.
+		cobra.CheckErr(viper.UnmarshalKey("remotePlugins", &serveOpts.RemotePlugins))
+		// Each plugin decodes and validates its own section of the plugins configuration.
+		cobra.CheckErr(viper.UnmarshalKey("plugins", &serveOpts.PluginConfigs))
 		server.Serve(serveOpts)
 	},
 }
@@ -61,6 +66,15 @@ func init() {
 
 	rootCmd.Flags().StringVar(&serveOpts.ClustersConfigPath, "clusters-config-path", "", "Configuration for clusters")
 	rootCmd.Flags().StringVar(&serveOpts.PinnipedProxyURL, "pinniped-proxy-url", "http://kubeapps-internal-pinniped-proxy.kubeapps:3333", "internal url to be used for requests to clusters configured for credential proxying via pinniped")
+	rootCmd.Flags().DurationVar(&serveOpts.PluginTimeout, "plugin-timeout", 30*time.Second, "The deadline for each plugin when a request is sent to all plugins. Results from plugins which do not respond in time are omitted.")
+	rootCmd.Flags().BoolVar(&serveOpts.TokenReview, "token-review", false, "if true, the bearer token of each request is validated with a TokenReview on the cluster on which Kubeapps is installed to resolve the identity of the user for the audit records. Requires the service account to be allowed to create tokenreviews.")
+	rootCmd.Flags().StringVar(&serveOpts.OTLPEndpoint, "otlp-endpoint", "", "the address of the OpenTelemetry collector, such as otel-collector:4317, to which the traces are exported using OTLP over gRPC. No traces are exported if empty.")
+	rootCmd.Flags().StringVar(&serveOpts.TLSCertFile, "tls-cert-file", "", "the TLS certificate file for both the gRPC and HTTP requests. TLS is enabled when set together with --tls-key-file.")
+	rootCmd.Flags().StringVar(&serveOpts.TLSKeyFile, "tls-key-file", "", "the TLS private key file matching --tls-cert-file.")
+	rootCmd.Flags().StringVar(&serveOpts.TLSClientCAFile, "tls-client-ca-file", "", "if set with TLS enabled, the gRPC requests are only served on --mtls-port, where the clients must present a certificate signed by a CA in this file (mTLS), which is enforced for every gRPC client, including the gateway. The gateway presents the certificate of the server, which must then allow client authentication. The HTTP and gRPC-Web requests of the browsers are still served on --port without client certificate.")
+	rootCmd.Flags().IntVar(&serveOpts.MTLSPort, "mtls-port", 50052, "the port on which the gRPC requests are served with mTLS when --tls-client-ca-file is set.")
+	rootCmd.Flags().StringSliceVar(&serveOpts.GRPCWebAllowedOrigins, "grpc-web-allowed-origins", []string{}, "the origins allowed to send cross-origin gRPC-Web requests, or \"*\" for any origin. Same-origin requests are always allowed.")
+	rootCmd.Flags().DurationVar(&serveOpts.ShutdownTimeout, "shutdown-timeout", 25*time.Second, "the time to wait for the in-flight requests to finish when the server receives SIGTERM. It should be lower than the terminationGracePeriodSeconds of the pod.")
 	rootCmd.Flags().BoolVar(&serveOpts.UnsafeUseDemoSA, "unsafe-use-demo-sa", false, "if true, it will create and use a privileged Service Account for interacting with the resources instead of acting on a user's behalf.")
 	rootCmd.Flags().BoolVar(&serveOpts.UnsafeLocalDevKubeconfig, "unsafe-local-dev-kubeconfig", false, "if true, it will use the local kubeconfig at the KUBECONFIG env var instead of using the inCluster configuration.")
 }
@@ -0,0 +1,176 @@
+/*
+Copyright © 2021 VMware
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/kubeapps/kubeapps/cmd/kubeapps-apis/server"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+	log "k8s.io/klog/v2"
+)
+
+const (
+	// previewChartLabel marks the HelmCharts created by the plugin to get the details of the
+	// packages, which are garbage collected once idle, unlike the HelmCharts of the releases
+	previewChartLabel = "fluxv2.packages.kubeapps.com/preview-chart"
+	// previewChartLastUsedAnnotation is the time at which a preview HelmChart was last used
+	previewChartLastUsedAnnotation = "fluxv2.packages.kubeapps.com/last-used"
+)
+
+// chartsGvr is the resource of the flux HelmCharts, from which the chart tarballs are pulled
+var chartsGvr = schema.GroupVersionResource{
+	Group:    fluxGroup,
+	Version:  fluxVersion,
+	Resource: fluxHelmCharts,
+}
+
+// newPreviewChart returns a HelmChart pulling the given version of the chart, or the latest
+// one if empty, labelled as a preview chart last used at the given time
+func newPreviewChart(repoName, chartName, version string, now time.Time) (*unstructured.Unstructured, error) {
+	unstructuredChart := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": fmt.Sprintf("%s/%s", fluxGroup, fluxVersion),
+			"kind":       fluxHelmChart,
+			"metadata": map[string]interface{}{
+				"generateName": fmt.Sprintf("%s-", chartName),
+				"labels": map[string]interface{}{
+					previewChartLabel: "true",
+				},
+				"annotations": map[string]interface{}{
+					previewChartLastUsedAnnotation: now.UTC().Format(time.RFC3339),
+				},
+			},
+			"spec": map[string]interface{}{
+				"chart": chartName,
+				"sourceRef": map[string]interface{}{
+					"name": repoName,
+					"kind": fluxHelmRepository,
+				},
+				"interval": "10m",
+			},
+		},
+	}
+	if version != "" {
+		if err := unstructured.SetNestedField(unstructuredChart.Object, version, "spec", "version"); err != nil {
+			return nil, status.Errorf(codes.Internal, "unable to set the version of the HelmChart: %v", err)
+		}
+	}
+	return unstructuredChart, nil
+}
+
+// isPreviewChart returns whether the HelmChart was created by the plugin for the details of a package
+func isPreviewChart(unstructuredChart *unstructured.Unstructured) bool {
+	return unstructuredChart.GetLabels()[previewChartLabel] == "true"
+}
+
+// touchPreviewChart records that a preview HelmChart is being used at the given time, so that
+// it is not garbage collected. The HelmCharts not created by the plugin are left untouched
+func touchPreviewChart(ctx context.Context, resourceIfc dynamic.ResourceInterface, unstructuredChart *unstructured.Unstructured, now time.Time) error {
+	if !isPreviewChart(unstructuredChart) {
+		return nil
+	}
+	patch := fmt.Sprintf(`{"metadata":{"annotations":{%q:%q}}}`, previewChartLastUsedAnnotation, now.UTC().Format(time.RFC3339))
+	_, err := resourceIfc.Patch(ctx, unstructuredChart.GetName(), types.MergePatchType, []byte(patch), metav1.PatchOptions{})
+	return err
+}
+
+// previewChartLastUsed returns the time at which the preview HelmChart was last used, which
+// is its creation time if it has not been annotated
+func previewChartLastUsed(unstructuredChart *unstructured.Unstructured) time.Time {
+	if lastUsed, ok := unstructuredChart.GetAnnotations()[previewChartLastUsedAnnotation]; ok {
+		if t, err := time.Parse(time.RFC3339, lastUsed); err == nil {
+			return t
+		}
+		log.Warningf("Invalid annotation [%s] on HelmChart [%s/%s]: [%s]", previewChartLastUsedAnnotation, unstructuredChart.GetNamespace(), unstructuredChart.GetName(), lastUsed)
+	}
+	return unstructuredChart.GetCreationTimestamp().Time
+}
+
+// collectPreviewCharts deletes the preview HelmCharts, in all namespaces, which have not
+// been used for longer than the idle TTL at the given time
+func collectPreviewCharts(ctx context.Context, client dynamic.Interface, idleTTL time.Duration, now time.Time) error {
+	chartList, err := client.Resource(chartsGvr).Namespace("").List(ctx, metav1.ListOptions{
+		LabelSelector: previewChartLabel + "=true",
+	})
+	if err != nil {
+		return statusErrorForK8sError(err, "Unable to list fluxv2 helmcharts")
+	}
+	for i := range chartList.Items {
+		unstructuredChart := &chartList.Items[i]
+		if !isPreviewChart(unstructuredChart) || now.Sub(previewChartLastUsed(unstructuredChart)) <= idleTTL {
+			continue
+		}
+		log.Infof("Deleting HelmChart [%s/%s] idle for more than [%s]", unstructuredChart.GetNamespace(), unstructuredChart.GetName(), idleTTL)
+		err := client.Resource(chartsGvr).Namespace(unstructuredChart.GetNamespace()).Delete(ctx, unstructuredChart.GetName(), metav1.DeleteOptions{})
+		if err != nil && !k8serrors.IsNotFound(err) {
+			return statusErrorForK8sError(err, fmt.Sprintf("Unable to delete fluxv2 helmchart [%s/%s]", unstructuredChart.GetNamespace(), unstructuredChart.GetName()))
+		}
+	}
+	return nil
+}
+
+// previewChartsCollectionInterval returns how often the idle preview HelmCharts are collected:
+// every half of the idle TTL, so that a HelmChart is deleted at most one and a half TTL after it
+// was last used, but not more often than every half of the minimum idle TTL.
+func previewChartsCollectionInterval(idleTTL time.Duration) time.Duration {
+	if idleTTL < minPreviewChartsIdleTTL {
+		idleTTL = minPreviewChartsIdleTTL
+	}
+	return idleTTL / 2
+}
+
+// serviceAccountDynamicClient returns a function creating a dynamic client authenticated with
+// the service account of kubeapps-apis, through the config getter of the server, which is used
+// to garbage collect the preview HelmCharts outside of any request. The service account must be
+// allowed to list, delete and patch the helmcharts of the source.toolkit.fluxcd.io group in all
+// namespaces.
+func serviceAccountDynamicClient(clientGetter server.KubernetesClientGetter) func(ctx context.Context) (dynamic.Interface, error) {
+	return func(ctx context.Context) (dynamic.Interface, error) {
+		_, dynamicClient, err := clientGetter(server.ContextWithServiceAccount(ctx), "")
+		return dynamicClient, err
+	}
+}
+
+// collectPreviewChartsPeriodically garbage collects the idle preview HelmCharts on the cluster
+// on which Kubeapps is installed, where they are created, at the given interval. It returns once
+// the context is cancelled.
+func collectPreviewChartsPeriodically(ctx context.Context, newClient func(ctx context.Context) (dynamic.Interface, error), idleTTL, interval time.Duration) {
+	log.Infof("+fluxv2 collecting the preview HelmCharts idle for more than [%s] every [%s]", idleTTL, interval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			log.Infof("+fluxv2 stopped collecting the preview HelmCharts")
+			return
+		case <-ticker.C:
+			client, err := newClient(ctx)
+			if err == nil {
+				err = collectPreviewCharts(ctx, client, idleTTL, time.Now())
+			}
+			if err != nil {
+				log.Errorf("Failed to collect the preview HelmCharts due to: %v", err)
+			}
+		}
+	}
+}
@@ -15,6 +15,7 @@ package main
 import (
 	"fmt"
 
+	corev1 "github.com/kubeapps/kubeapps/cmd/kubeapps-apis/gen/core/packages/v1alpha1"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
@@ -130,6 +131,116 @@ func isChartPullComplete(unstructuredChart *unstructured.Unstructured) (bool, er
 	return false, nil
 }
 
+// installedPackageStatusFromRelease maps the Ready condition of a HelmRelease
+// to the status of the installed package. Until flux has observed the current
+// generation of the release, or while it is still reconciling, the package is
+// reported as pending.
+func installedPackageStatusFromRelease(unstructuredRel map[string]interface{}) *corev1.InstalledPackageStatus {
+	// see docs at https://fluxcd.io/docs/components/helm/helmreleases/
+	pending := &corev1.InstalledPackageStatus{
+		Ready:  false,
+		Reason: corev1.InstalledPackageStatus_STATUS_REASON_PENDING,
+	}
+
+	// Confirm the state we are observing is for the current generation
+	observedGeneration, found, err := unstructured.NestedInt64(unstructuredRel, "status", "observedGeneration")
+	if err != nil || !found {
+		return pending
+	}
+	generation, found, err := unstructured.NestedInt64(unstructuredRel, "metadata", "generation")
+	if err != nil || !found || generation != observedGeneration {
+		return pending
+	}
+
+	conditions, found, err := unstructured.NestedSlice(unstructuredRel, "status", "conditions")
+	if err != nil || !found {
+		return pending
+	}
+
+	for _, conditionUnstructured := range conditions {
+		if conditionAsMap, ok := conditionUnstructured.(map[string]interface{}); ok {
+			if typeString, ok := conditionAsMap["type"]; ok && typeString == "Ready" {
+				reason, _ := conditionAsMap["reason"].(string)
+				if msg, ok := conditionAsMap["message"].(string); ok && msg != "" {
+					reason = fmt.Sprintf("%s: %s", reason, msg)
+				}
+				switch conditionAsMap["status"] {
+				case "True":
+					return &corev1.InstalledPackageStatus{
+						Ready:      true,
+						Reason:     corev1.InstalledPackageStatus_STATUS_REASON_INSTALLED,
+						UserReason: reason,
+					}
+				case "False":
+					return &corev1.InstalledPackageStatus{
+						Ready:      false,
+						Reason:     corev1.InstalledPackageStatus_STATUS_REASON_FAILED,
+						UserReason: reason,
+					}
+				default:
+					pending.UserReason = reason
+					return pending
+				}
+			}
+		}
+	}
+	return pending
+}
+
+// packageRepositoryStatusFromRepo maps the Ready condition of a HelmRepository
+// to the status of the package repository, in the same way as for releases.
+func packageRepositoryStatusFromRepo(unstructuredRepo map[string]interface{}) *corev1.PackageRepositoryStatus {
+	// see docs at https://fluxcd.io/docs/components/source/helmrepositories/
+	pending := &corev1.PackageRepositoryStatus{
+		Ready:  false,
+		Reason: corev1.PackageRepositoryStatus_STATUS_REASON_PENDING,
+	}
+
+	// Confirm the state we are observing is for the current generation
+	observedGeneration, found, err := unstructured.NestedInt64(unstructuredRepo, "status", "observedGeneration")
+	if err != nil || !found {
+		return pending
+	}
+	generation, found, err := unstructured.NestedInt64(unstructuredRepo, "metadata", "generation")
+	if err != nil || !found || generation != observedGeneration {
+		return pending
+	}
+
+	conditions, found, err := unstructured.NestedSlice(unstructuredRepo, "status", "conditions")
+	if err != nil || !found {
+		return pending
+	}
+
+	for _, conditionUnstructured := range conditions {
+		if conditionAsMap, ok := conditionUnstructured.(map[string]interface{}); ok {
+			if typeString, ok := conditionAsMap["type"]; ok && typeString == "Ready" {
+				reason, _ := conditionAsMap["reason"].(string)
+				if msg, ok := conditionAsMap["message"].(string); ok && msg != "" {
+					reason = fmt.Sprintf("%s: %s", reason, msg)
+				}
+				switch conditionAsMap["status"] {
+				case "True":
+					return &corev1.PackageRepositoryStatus{
+						Ready:      true,
+						Reason:     corev1.PackageRepositoryStatus_STATUS_REASON_SUCCESS,
+						UserReason: reason,
+					}
+				case "False":
+					return &corev1.PackageRepositoryStatus{
+						Ready:      false,
+						Reason:     corev1.PackageRepositoryStatus_STATUS_REASON_FAILED,
+						UserReason: reason,
+					}
+				default:
+					pending.UserReason = reason
+					return pending
+				}
+			}
+		}
+	}
+	return pending
+}
+
 // TODO (gfichtenholt):
 // see https://github.com/kubeapps/kubeapps/pull/2915 for context
 // In the future you might instead want to consider something like
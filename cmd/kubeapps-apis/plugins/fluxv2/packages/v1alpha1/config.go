@@ -0,0 +1,124 @@
+/*
+Copyright © 2021 VMware
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/kubeapps/kubeapps/cmd/kubeapps-apis/server"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// pluginConfig is the configuration of the plugin, read from the
+// "fluxv2.packages" section of the kubeapps-apis config file.
+type pluginConfig struct {
+	Cache         cacheConfig         `mapstructure:"cache"`
+	Redis         redisConfig         `mapstructure:"redis"`
+	PreviewCharts previewChartsConfig `mapstructure:"previewCharts"`
+}
+
+// cacheConfig configures the storage of the cache of charts.
+type cacheConfig struct {
+	// Storage is either "redis" or "memory". It defaults to redis when a redis
+	// address is configured and to memory otherwise.
+	Storage string `mapstructure:"storage"`
+	// MaxEntries is the maximum number of repositories kept in the memory storage.
+	MaxEntries int `mapstructure:"maxEntries"`
+}
+
+const (
+	cacheStorageRedis  = "redis"
+	cacheStorageMemory = "memory"
+
+	defaultCacheMaxEntries = 1000
+)
+
+// redisConfig configures the redis instance used for the cache.
+type redisConfig struct {
+	Addr     string `mapstructure:"addr"`
+	Password string `mapstructure:"password"`
+	DB       int    `mapstructure:"db"`
+}
+
+// previewChartsConfig configures the HelmCharts created to get the details of the packages.
+type previewChartsConfig struct {
+	// IdleTTL is how long a HelmChart is kept once it is no longer used, such as "1h".
+	IdleTTL time.Duration `mapstructure:"idleTTL"`
+}
+
+const (
+	defaultPreviewChartsIdleTTL = time.Hour
+	// minPreviewChartsIdleTTL keeps the HelmCharts long enough to be reused by the
+	// following requests, rather than being pulled again by source-controller.
+	minPreviewChartsIdleTTL = time.Minute
+)
+
+// newPluginConfig decodes and validates the plugin configuration. Values which
+// are not set in the config file default to the REDIS_ADDR, REDIS_PASSWORD and
+// REDIS_DB environment variables, so that the password can still be read from
+// a secret.
+func newPluginConfig(config server.PluginConfig) (*pluginConfig, error) {
+	pluginConfig := &pluginConfig{
+		Cache: cacheConfig{
+			MaxEntries: defaultCacheMaxEntries,
+		},
+		PreviewCharts: previewChartsConfig{
+			IdleTTL: defaultPreviewChartsIdleTTL,
+		},
+		Redis: redisConfig{
+			Addr:     os.Getenv("REDIS_ADDR"),
+			Password: os.Getenv("REDIS_PASSWORD"),
+		},
+	}
+	if redisDB, ok := os.LookupEnv("REDIS_DB"); ok {
+		redisDBNum, err := strconv.Atoi(redisDB)
+		if err != nil {
+			return nil, status.Errorf(codes.FailedPrecondition, "invalid environment variable REDIS_DB: %v", err)
+		}
+		pluginConfig.Redis.DB = redisDBNum
+	}
+
+	if err := config.Decode(pluginConfig); err != nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "invalid plugin configuration: %v", err)
+	}
+
+	if pluginConfig.Cache.Storage == "" {
+		pluginConfig.Cache.Storage = cacheStorageMemory
+		if pluginConfig.Redis.Addr != "" {
+			pluginConfig.Cache.Storage = cacheStorageRedis
+		}
+	}
+	if pluginConfig.PreviewCharts.IdleTTL < minPreviewChartsIdleTTL {
+		return nil, status.Errorf(codes.FailedPrecondition, "invalid previewCharts.idleTTL %s, expected a duration of at least %s", pluginConfig.PreviewCharts.IdleTTL, minPreviewChartsIdleTTL)
+	}
+
+	switch pluginConfig.Cache.Storage {
+	case cacheStorageRedis:
+		if pluginConfig.Redis.Addr == "" {
+			return nil, status.Errorf(codes.FailedPrecondition, "missing redis address, set redis.addr in the plugin configuration or the environment variable REDIS_ADDR")
+		}
+		if pluginConfig.Redis.DB < 0 {
+			return nil, status.Errorf(codes.FailedPrecondition, "invalid redis DB %d, expected a non-negative number", pluginConfig.Redis.DB)
+		}
+	case cacheStorageMemory:
+		if pluginConfig.Cache.MaxEntries <= 0 {
+			return nil, status.Errorf(codes.FailedPrecondition, "invalid cache.maxEntries %d, expected a positive number", pluginConfig.Cache.MaxEntries)
+		}
+	default:
+		return nil, status.Errorf(codes.FailedPrecondition, "invalid cache.storage %q, expected %q or %q", pluginConfig.Cache.Storage, cacheStorageRedis, cacheStorageMemory)
+	}
+	return pluginConfig, nil
+}
@@ -25,14 +25,17 @@ import (
 )
 
 // RegisterWithGRPCServer enables a plugin to register with a gRPC server
-// returning the server implementation.
-func RegisterWithGRPCServer(s grpc.ServiceRegistrar, clientGetter server.KubernetesClientGetter) (interface{}, error) {
+// returning the server implementation. The background work of the plugin,
+// such as the garbage collection of the preview HelmCharts, stops once the
+// context is cancelled.
+func RegisterWithGRPCServer(ctx context.Context, s grpc.ServiceRegistrar, configGetter server.KubernetesConfigGetter, kubeappsCluster string, config server.PluginConfig) (interface{}, error) {
 	log.Infof("+fluxv2 RegisterWithGRPCServer")
-	svr, err := NewServer(clientGetter)
+	svr, err := NewServer(ctx, server.NewClientGetter(configGetter), kubeappsCluster, config)
 	if err != nil {
 		return nil, err
 	}
 	v1alpha1.RegisterFluxV2PackagesServiceServer(s, svr)
+	v1alpha1.RegisterFluxV2RepositoriesServiceServer(s, svr)
 	return svr, nil
 }
 
@@ -40,7 +43,10 @@ func RegisterWithGRPCServer(s grpc.ServiceRegistrar, clientGetter server.Kuberne
 // handler to translate to the gRPC request.
 func RegisterHTTPHandlerFromEndpoint(ctx context.Context, mux *runtime.ServeMux, endpoint string, opts []grpc.DialOption) error {
 	log.Infof("+fluxv2 RegisterHTTPHandlerFromEndpoint")
-	return v1alpha1.RegisterFluxV2PackagesServiceHandlerFromEndpoint(ctx, mux, endpoint, opts)
+	if err := v1alpha1.RegisterFluxV2PackagesServiceHandlerFromEndpoint(ctx, mux, endpoint, opts); err != nil {
+		return err
+	}
+	return v1alpha1.RegisterFluxV2RepositoriesServiceHandlerFromEndpoint(ctx, mux, endpoint, opts)
 }
 
 // GetPluginDetail returns a core.plugins.Plugin describing itself.
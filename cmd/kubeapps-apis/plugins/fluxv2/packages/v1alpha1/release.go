@@ -0,0 +1,285 @@
+/*
+Copyright © 2021 VMware
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "github.com/kubeapps/kubeapps/cmd/kubeapps-apis/gen/core/packages/v1alpha1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// see docs at https://fluxcd.io/docs/components/helm/helmreleases/
+	// default interval used when the request does not specify reconciliation options
+	defaultReleaseInterval = "1m"
+)
+
+// newFluxHelmRelease returns a HelmRelease object for the chart referenced by
+// the available package ref, to be created in the target namespace
+func newFluxHelmRelease(availablePackageRef *corev1.AvailablePackageReference, targetNamespace string, name string, versionRef *corev1.VersionReference, values string, reconcile *corev1.ReconciliationOptions) (*unstructured.Unstructured, error) {
+	repoName, chartName, err := splitPackageIdentifier(availablePackageRef.GetIdentifier())
+	if err != nil {
+		return nil, err
+	}
+
+	chartSpec := map[string]interface{}{
+		"chart": chartName,
+		"sourceRef": map[string]interface{}{
+			"kind":      fluxHelmRepository,
+			"name":      repoName,
+			"namespace": availablePackageRef.GetContext().GetNamespace(),
+		},
+	}
+	if versionRef.GetVersion() != "" {
+		chartSpec["version"] = versionRef.GetVersion()
+	}
+
+	unstructuredRel := unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": fmt.Sprintf("%s/%s", fluxHelmReleaseGroup, fluxHelmReleaseVersion),
+			"kind":       fluxHelmRelease,
+			"metadata": map[string]interface{}{
+				"name":      name,
+				"namespace": targetNamespace,
+			},
+			"spec": map[string]interface{}{
+				"chart": map[string]interface{}{
+					"spec": chartSpec,
+				},
+				"interval": defaultReleaseInterval,
+			},
+		},
+	}
+
+	if err = setReleaseValues(&unstructuredRel, values); err != nil {
+		return nil, err
+	}
+	if err = setReleaseReconciliationOptions(&unstructuredRel, reconcile); err != nil {
+		return nil, err
+	}
+	return &unstructuredRel, nil
+}
+
+// setReleaseValues replaces the values of the release with those given as a
+// YAML string. An empty string removes any values previously set.
+func setReleaseValues(unstructuredRel *unstructured.Unstructured, values string) error {
+	if strings.TrimSpace(values) == "" {
+		unstructured.RemoveNestedField(unstructuredRel.Object, "spec", "values")
+		return nil
+	}
+	valuesMap := map[string]interface{}{}
+	if err := yaml.Unmarshal([]byte(values), &valuesMap); err != nil {
+		return status.Errorf(codes.InvalidArgument, "Unable to parse values: %v", err)
+	}
+	return unstructured.SetNestedMap(unstructuredRel.Object, valuesMap, "spec", "values")
+}
+
+// setReleaseReconciliationOptions sets the interval, suspend flag and service account
+// name of the release. A nil value leaves the current options untouched.
+func setReleaseReconciliationOptions(unstructuredRel *unstructured.Unstructured, reconcile *corev1.ReconciliationOptions) error {
+	if reconcile == nil {
+		return nil
+	}
+	if reconcile.Interval < 0 {
+		return status.Errorf(codes.InvalidArgument, "Invalid reconciliation interval: [%d]", reconcile.Interval)
+	} else if reconcile.Interval > 0 {
+		interval := (time.Duration(reconcile.Interval) * time.Second).String()
+		if err := unstructured.SetNestedField(unstructuredRel.Object, interval, "spec", "interval"); err != nil {
+			return err
+		}
+	}
+	if err := unstructured.SetNestedField(unstructuredRel.Object, reconcile.Suspend, "spec", "suspend"); err != nil {
+		return err
+	}
+	if reconcile.ServiceAccountName != "" {
+		return unstructured.SetNestedField(unstructuredRel.Object, reconcile.ServiceAccountName, "spec", "serviceAccountName")
+	}
+	unstructured.RemoveNestedField(unstructuredRel.Object, "spec", "serviceAccountName")
+	return nil
+}
+
+func installedPackageSummaryFromRelease(cluster string, unstructuredRel map[string]interface{}) (*corev1.InstalledPackageSummary, error) {
+	ref, err := installedPackageRefFromRelease(cluster, unstructuredRel)
+	if err != nil {
+		return nil, err
+	}
+	chartName, _, _ := unstructured.NestedString(unstructuredRel, "spec", "chart", "spec", "chart")
+	version, _, _ := unstructured.NestedString(unstructuredRel, "spec", "chart", "spec", "version")
+
+	return &corev1.InstalledPackageSummary{
+		InstalledPackageRef: ref,
+		Name:                ref.Identifier,
+		PkgVersionReference: &corev1.VersionReference{
+			Version: version,
+		},
+		CurrentVersion: currentVersionFromRelease(unstructuredRel),
+		PkgDisplayName: chartName,
+		Status:         installedPackageStatusFromRelease(unstructuredRel),
+	}, nil
+}
+
+func installedPackageDetailFromRelease(cluster string, unstructuredRel map[string]interface{}) (*corev1.InstalledPackageDetail, error) {
+	ref, err := installedPackageRefFromRelease(cluster, unstructuredRel)
+	if err != nil {
+		return nil, err
+	}
+	version, _, _ := unstructured.NestedString(unstructuredRel, "spec", "chart", "spec", "version")
+
+	valuesApplied := ""
+	values, found, err := unstructured.NestedMap(unstructuredRel, "spec", "values")
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Unable to read values of HelmRelease: %v", err)
+	} else if found && len(values) > 0 {
+		valuesBytes, err := yaml.Marshal(values)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "Unable to marshal values of HelmRelease: %v", err)
+		}
+		valuesApplied = string(valuesBytes)
+	}
+
+	reconcile, err := reconciliationOptionsFromRelease(unstructuredRel)
+	if err != nil {
+		return nil, err
+	}
+
+	return &corev1.InstalledPackageDetail{
+		InstalledPackageRef: ref,
+		PkgVersionReference: &corev1.VersionReference{
+			Version: version,
+		},
+		Name:                  ref.Identifier,
+		CurrentVersion:        currentVersionFromRelease(unstructuredRel),
+		ValuesApplied:         valuesApplied,
+		ReconciliationOptions: reconcile,
+		Status:                installedPackageStatusFromRelease(unstructuredRel),
+		AvailablePackageRef:   availablePackageRefFromRelease(unstructuredRel),
+	}, nil
+}
+
+// installedPackageRefFromRelease returns the reference to the HelmRelease on the given cluster.
+func installedPackageRefFromRelease(cluster string, unstructuredRel map[string]interface{}) (*corev1.InstalledPackageReference, error) {
+	name, found, err := unstructured.NestedString(unstructuredRel, "metadata", "name")
+	if err != nil || !found || name == "" {
+		return nil, status.Errorf(codes.Internal, "required field metadata.name not found on HelmRelease: %v:\n%v", err, unstructuredRel)
+	}
+	namespace, found, err := unstructured.NestedString(unstructuredRel, "metadata", "namespace")
+	if err != nil || !found || namespace == "" {
+		return nil, status.Errorf(codes.Internal, "required field metadata.namespace not found on HelmRelease: %v:\n%v", err, unstructuredRel)
+	}
+	return &corev1.InstalledPackageReference{
+		Context: &corev1.Context{
+			Cluster:   cluster,
+			Namespace: namespace,
+		},
+		Identifier: name,
+		Plugin:     GetPluginDetail(),
+	}, nil
+}
+
+// availablePackageRefFromRelease returns the reference to the chart the release
+// was installed from. The HelmRepository is assumed to be in the release
+// namespace unless the sourceRef says otherwise, as per flux semantics.
+func availablePackageRefFromRelease(unstructuredRel map[string]interface{}) *corev1.AvailablePackageReference {
+	chartName, _, _ := unstructured.NestedString(unstructuredRel, "spec", "chart", "spec", "chart")
+	repoName, _, _ := unstructured.NestedString(unstructuredRel, "spec", "chart", "spec", "sourceRef", "name")
+	if chartName == "" || repoName == "" {
+		return nil
+	}
+	repoNamespace, _, _ := unstructured.NestedString(unstructuredRel, "spec", "chart", "spec", "sourceRef", "namespace")
+	if repoNamespace == "" {
+		repoNamespace, _, _ = unstructured.NestedString(unstructuredRel, "metadata", "namespace")
+	}
+	return &corev1.AvailablePackageReference{
+		Context: &corev1.Context{
+			Namespace: repoNamespace,
+		},
+		Identifier: fmt.Sprintf("%s/%s", repoName, chartName),
+		Plugin:     GetPluginDetail(),
+	}
+}
+
+func currentVersionFromRelease(unstructuredRel map[string]interface{}) *corev1.PackageAppVersion {
+	// see https://fluxcd.io/docs/components/helm/helmreleases/#status
+	// lastAppliedRevision is the chart version of the last successful release
+	revision, found, err := unstructured.NestedString(unstructuredRel, "status", "lastAppliedRevision")
+	if err != nil || !found || revision == "" {
+		return nil
+	}
+	return &corev1.PackageAppVersion{
+		PkgVersion: revision,
+	}
+}
+
+func reconciliationOptionsFromRelease(unstructuredRel map[string]interface{}) (*corev1.ReconciliationOptions, error) {
+	reconcile := &corev1.ReconciliationOptions{}
+	if intervalString, found, err := unstructured.NestedString(unstructuredRel, "spec", "interval"); err != nil {
+		return nil, status.Errorf(codes.Internal, "Unable to read interval of HelmRelease: %v", err)
+	} else if found && intervalString != "" {
+		interval, err := time.ParseDuration(intervalString)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "Unable to parse interval of HelmRelease: %v", err)
+		}
+		reconcile.Interval = int32(interval.Seconds())
+	}
+	if suspend, found, err := unstructured.NestedBool(unstructuredRel, "spec", "suspend"); err != nil {
+		return nil, status.Errorf(codes.Internal, "Unable to read suspend flag of HelmRelease: %v", err)
+	} else if found {
+		reconcile.Suspend = suspend
+	}
+	if serviceAccountName, found, err := unstructured.NestedString(unstructuredRel, "spec", "serviceAccountName"); err != nil {
+		return nil, status.Errorf(codes.Internal, "Unable to read service account of HelmRelease: %v", err)
+	} else if found {
+		reconcile.ServiceAccountName = serviceAccountName
+	}
+	return reconcile, nil
+}
+
+func splitPackageIdentifier(identifier string) (repoName string, chartName string, err error) {
+	packageIdParts := strings.Split(identifier, "/")
+	if len(packageIdParts) != 2 || packageIdParts[0] == "" || packageIdParts[1] == "" {
+		return "", "", status.Errorf(codes.InvalidArgument, "Invalid package ref identifier: [%s]", identifier)
+	}
+	return packageIdParts[0], packageIdParts[1], nil
+}
+
+func validateInstalledPackageRef(installedRef *corev1.InstalledPackageReference) error {
+	if installedRef.GetContext().GetNamespace() == "" || installedRef.GetIdentifier() == "" {
+		return status.Errorf(codes.InvalidArgument, "Required context or identifier not provided for the installed package")
+	}
+	return nil
+}
+
+// statusErrorForK8sError returns a grpc status error for the k8s API error, with
+// the most common failure reasons mapped to the corresponding code.
+func statusErrorForK8sError(err error, msg string) error {
+	switch {
+	case k8serrors.IsNotFound(err):
+		return status.Errorf(codes.NotFound, "%s: %v", msg, err)
+	case k8serrors.IsAlreadyExists(err):
+		return status.Errorf(codes.AlreadyExists, "%s: %v", msg, err)
+	case k8serrors.IsForbidden(err):
+		return status.Errorf(codes.PermissionDenied, "%s: %v", msg, err)
+	case k8serrors.IsUnauthorized(err):
+		return status.Errorf(codes.Unauthenticated, "%s: %v", msg, err)
+	case k8serrors.IsInvalid(err), k8serrors.IsBadRequest(err):
+		return status.Errorf(codes.InvalidArgument, "%s: %v", msg, err)
+	}
+	return status.Errorf(codes.Internal, "%s: %v", msg, err)
+}
@@ -0,0 +1,212 @@
+/*
+Copyright © 2021 VMware
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"fmt"
+	"time"
+
+	corev1 "github.com/kubeapps/kubeapps/cmd/kubeapps-apis/gen/core/packages/v1alpha1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const (
+	// see docs at https://fluxcd.io/docs/components/source/helmrepositories/
+	// default interval used when the request does not specify one
+	defaultRepoInterval = "10m"
+
+	// the only type of repository supported by flux HelmRepositories
+	fluxRepoType = "helm"
+
+	// annotation requesting flux to reconcile a resource outside of its interval,
+	// see https://fluxcd.io/docs/components/source/helmrepositories/
+	fluxReconcileRequestAnnotation = "reconcile.fluxcd.io/requestedAt"
+)
+
+// newFluxHelmRepository returns a HelmRepository object for the repository
+// requested to be added
+func newFluxHelmRepository(request *corev1.AddPackageRepositoryRequest) (*unstructured.Unstructured, error) {
+	if request.GetType() != "" && request.GetType() != fluxRepoType {
+		return nil, status.Errorf(codes.InvalidArgument, "Repository type [%s] not supported by the fluxv2 plugin", request.GetType())
+	}
+
+	unstructuredRepo := unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": fmt.Sprintf("%s/%s", fluxGroup, fluxVersion),
+			"kind":       fluxHelmRepository,
+			"metadata": map[string]interface{}{
+				"name":      request.GetName(),
+				"namespace": request.GetContext().GetNamespace(),
+			},
+			"spec": map[string]interface{}{
+				"interval": defaultRepoInterval,
+			},
+		},
+	}
+
+	if err := setRepoSpec(&unstructuredRepo, request.GetUrl(), request.GetInterval(), request.GetAuth(), request.GetTlsConfig()); err != nil {
+		return nil, err
+	}
+	return &unstructuredRepo, nil
+}
+
+// setRepoSpec sets the url, interval and secret of the HelmRepository. A zero
+// interval leaves the current interval untouched.
+// HelmRepositories reference a single secret holding both the credentials and the
+// certificate authority, so only references to existing secrets are supported.
+func setRepoSpec(unstructuredRepo *unstructured.Unstructured, url string, interval uint32, auth *corev1.PackageRepositoryAuth, tlsConfig *corev1.PackageRepositoryTlsConfig) error {
+	if url == "" {
+		return status.Errorf(codes.InvalidArgument, "Required repository url not provided")
+	}
+	if err := unstructured.SetNestedField(unstructuredRepo.Object, url, "spec", "url"); err != nil {
+		return err
+	}
+
+	if interval > 0 {
+		intervalString := (time.Duration(interval) * time.Second).String()
+		if err := unstructured.SetNestedField(unstructuredRepo.Object, intervalString, "spec", "interval"); err != nil {
+			return err
+		}
+	}
+
+	secretName, err := repoSecretNameFromRequest(auth, tlsConfig)
+	if err != nil {
+		return err
+	}
+	if secretName != "" {
+		if err := unstructured.SetNestedField(unstructuredRepo.Object, secretName, "spec", "secretRef", "name"); err != nil {
+			return err
+		}
+	} else {
+		unstructured.RemoveNestedField(unstructuredRepo.Object, "spec", "secretRef")
+	}
+
+	if auth.GetPassCredentials() {
+		return unstructured.SetNestedField(unstructuredRepo.Object, true, "spec", "passCredentials")
+	}
+	unstructured.RemoveNestedField(unstructuredRepo.Object, "spec", "passCredentials")
+	return nil
+}
+
+// repoSecretNameFromRequest returns the name of the secret referenced by the
+// auth and TLS config of the request, which must be the same if both are set.
+func repoSecretNameFromRequest(auth *corev1.PackageRepositoryAuth, tlsConfig *corev1.PackageRepositoryTlsConfig) (string, error) {
+	if auth.GetUsernamePassword() != nil || auth.GetHeader() != "" {
+		return "", status.Errorf(codes.InvalidArgument, "Inline credentials are not supported by the fluxv2 plugin, reference an existing secret instead")
+	}
+	if tlsConfig.GetInsecureSkipVerify() {
+		return "", status.Errorf(codes.InvalidArgument, "Skipping the TLS verification is not supported by the fluxv2 plugin")
+	}
+	if tlsConfig.GetCertAuthority() != "" {
+		return "", status.Errorf(codes.InvalidArgument, "Inline certificate authorities are not supported by the fluxv2 plugin, reference an existing secret instead")
+	}
+
+	authSecretName := auth.GetSecretRef().GetName()
+	tlsSecretName := tlsConfig.GetSecretRef().GetName()
+	if authSecretName != "" && tlsSecretName != "" && authSecretName != tlsSecretName {
+		return "", status.Errorf(codes.InvalidArgument, "The fluxv2 plugin requires the credentials and certificate authority in the same secret")
+	}
+	if authSecretName != "" {
+		return authSecretName, nil
+	}
+	return tlsSecretName, nil
+}
+
+func packageRepositoryRefFromRepo(cluster string, unstructuredRepo map[string]interface{}) (*corev1.PackageRepositoryReference, error) {
+	name, found, err := unstructured.NestedString(unstructuredRepo, "metadata", "name")
+	if err != nil || !found {
+		return nil, status.Errorf(
+			codes.Internal,
+			"required field metadata.name not found on HelmRepository: %v:\n%s", err, prettyPrintMap(unstructuredRepo))
+	}
+	namespace, found, err := unstructured.NestedString(unstructuredRepo, "metadata", "namespace")
+	if err != nil || !found {
+		return nil, status.Errorf(
+			codes.Internal,
+			"field metadata.namespace not found on HelmRepository: %v:\n%s", err, prettyPrintMap(unstructuredRepo))
+	}
+	return &corev1.PackageRepositoryReference{
+		Context: &corev1.Context{
+			Cluster:   cluster,
+			Namespace: namespace,
+		},
+		Identifier: name,
+		Plugin:     GetPluginDetail(),
+	}, nil
+}
+
+func packageRepositorySummaryFromRepo(cluster string, unstructuredRepo map[string]interface{}) (*corev1.PackageRepositorySummary, error) {
+	ref, err := packageRepositoryRefFromRepo(cluster, unstructuredRepo)
+	if err != nil {
+		return nil, err
+	}
+	url, found, err := unstructured.NestedString(unstructuredRepo, "spec", "url")
+	if err != nil || !found {
+		return nil, status.Errorf(
+			codes.Internal,
+			"required field spec.url not found on HelmRepository: %v:\n%s", err, prettyPrintMap(unstructuredRepo))
+	}
+	return &corev1.PackageRepositorySummary{
+		PackageRepoRef: ref,
+		Name:           ref.Identifier,
+		Type:           fluxRepoType,
+		Url:            url,
+		Status:         packageRepositoryStatusFromRepo(unstructuredRepo),
+	}, nil
+}
+
+func packageRepositoryDetailFromRepo(cluster string, unstructuredRepo map[string]interface{}) (*corev1.PackageRepositoryDetail, error) {
+	summary, err := packageRepositorySummaryFromRepo(cluster, unstructuredRepo)
+	if err != nil {
+		return nil, err
+	}
+
+	detail := &corev1.PackageRepositoryDetail{
+		PackageRepoRef: summary.PackageRepoRef,
+		Name:           summary.Name,
+		Type:           summary.Type,
+		Url:            summary.Url,
+		Status:         summary.Status,
+	}
+
+	if intervalString, found, err := unstructured.NestedString(unstructuredRepo, "spec", "interval"); err == nil && found {
+		interval, err := time.ParseDuration(intervalString)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "Unable to parse interval [%s] of HelmRepository: %v", intervalString, err)
+		}
+		detail.Interval = uint32(interval.Seconds())
+	}
+
+	secretName, _, _ := unstructured.NestedString(unstructuredRepo, "spec", "secretRef", "name")
+	passCredentials, _, _ := unstructured.NestedBool(unstructuredRepo, "spec", "passCredentials")
+	if secretName != "" || passCredentials {
+		detail.Auth = &corev1.PackageRepositoryAuth{
+			PassCredentials: passCredentials,
+		}
+		if secretName != "" {
+			detail.Auth.PackageRepoAuthOneOf = &corev1.PackageRepositoryAuth_SecretRef{
+				SecretRef: &corev1.SecretKeyReference{Name: secretName},
+			}
+		}
+	}
+	return detail, nil
+}
+
+func validatePackageRepositoryRef(repoRef *corev1.PackageRepositoryReference) error {
+	if repoRef.GetContext().GetNamespace() == "" || repoRef.GetIdentifier() == "" {
+		return status.Errorf(codes.InvalidArgument, "Required context or identifier not provided for the package repository")
+	}
+	return nil
+}
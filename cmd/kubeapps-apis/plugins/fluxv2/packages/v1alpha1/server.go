@@ -16,6 +16,7 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"time"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
@@ -24,12 +25,16 @@ import (
 	"k8s.io/client-go/kubernetes"
 
 	corev1 "github.com/kubeapps/kubeapps/cmd/kubeapps-apis/gen/core/packages/v1alpha1"
+	plugins "github.com/kubeapps/kubeapps/cmd/kubeapps-apis/gen/core/plugins/v1alpha1"
 	"github.com/kubeapps/kubeapps/cmd/kubeapps-apis/gen/plugins/fluxv2/packages/v1alpha1"
 	"github.com/kubeapps/kubeapps/cmd/kubeapps-apis/server"
-	chart "github.com/kubeapps/kubeapps/pkg/chart/models"
+	"github.com/kubeapps/kubeapps/cmd/kubeapps-apis/server/cache"
 	httpclient "github.com/kubeapps/kubeapps/pkg/http-client"
 
 	tar "github.com/kubeapps/kubeapps/pkg/tarutil"
+
+	"github.com/go-redis/redis/v8"
+	"go.opentelemetry.io/otel/attribute"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 	log "k8s.io/klog/v2"
@@ -45,61 +50,147 @@ const (
 	fluxHelmChart          = "HelmChart"
 	fluxHelmCharts         = "helmcharts"
 	fluxHelmChartList      = "HelmChartList"
+
+	// see docs at https://fluxcd.io/docs/components/helm/
+	fluxHelmReleaseGroup   = "helm.toolkit.fluxcd.io"
+	fluxHelmReleaseVersion = "v2beta1"
+	fluxHelmRelease        = "HelmRelease"
+	fluxHelmReleases       = "helmreleases"
+	fluxHelmReleaseList    = "HelmReleaseList"
 )
 
+// repositoriesGvr is the resource of the flux HelmRepositories, whose charts are cached
+var repositoriesGvr = schema.GroupVersionResource{
+	Group:    fluxGroup,
+	Version:  fluxVersion,
+	Resource: fluxHelmRepositories,
+}
+
 // Compile-time statement to ensure this service implementation satisfies the core packaging API
 var _ corev1.PackagesServiceServer = (*Server)(nil)
+var _ corev1.RepositoriesServiceServer = (*Server)(nil)
+var _ server.PluginDependenciesChecker = (*Server)(nil)
 
 // Server implements the fluxv2 packages v1alpha1 interface.
 type Server struct {
 	v1alpha1.UnimplementedFluxV2PackagesServiceServer
+	v1alpha1.UnimplementedFluxV2RepositoriesServiceServer
 
 	// clientGetter is a field so that it can be switched in tests for
 	// a fake client. NewServer() below sets this automatically with the
 	// non-test implementation.
 	clientGetter server.KubernetesClientGetter
 
-	cache *ResourceWatcherCache
+	// kubeappsCluster is the name of the cluster on which Kubeapps is installed, the
+	// only one on which the HelmRepositories are read and the releases can be managed.
+	kubeappsCluster string
+
+	cache *cache.ResourceWatcherCache
 }
 
 // NewServer returns a Server automatically configured with a function to obtain
-// the k8s client config.
-func NewServer(clientGetter server.KubernetesClientGetter) (*Server, error) {
-	repositoriesGvr := schema.GroupVersionResource{
-		Group:    fluxGroup,
-		Version:  fluxVersion,
-		Resource: fluxHelmRepositories,
+// the k8s client config, after validating the plugin configuration. The preview
+// HelmCharts are garbage collected until the context is cancelled.
+func NewServer(ctx context.Context, clientGetter server.KubernetesClientGetter, kubeappsCluster string, config server.PluginConfig) (*Server, error) {
+	pluginConfig, err := newPluginConfig(config)
+	if err != nil {
+		return nil, err
 	}
-	config := cacheConfig{
-		gvr:          repositoriesGvr,
-		clientGetter: clientGetter,
-		onAdd:        onAddOrModifyRepo,
-		onModify:     onAddOrModifyRepo,
-		onGet:        onGetRepo,
-		onDelete:     onDeleteRepo,
+
+	store, err := newCacheStore(pluginConfig)
+	if err != nil {
+		return nil, err
 	}
-	cache, err := newCache(config)
+	repositoriesCache, err := cache.NewCache(repositoriesCacheConfig(clientGetter), store)
 	if err != nil {
 		return nil, err
 	}
-	return &Server{
-		clientGetter: clientGetter,
-		cache:        cache,
-	}, nil
+	s := &Server{
+		clientGetter:    clientGetter,
+		kubeappsCluster: kubeappsCluster,
+		cache:           repositoriesCache,
+	}
+	idleTTL := pluginConfig.PreviewCharts.IdleTTL
+	go collectPreviewChartsPeriodically(ctx, serviceAccountDynamicClient(clientGetter), idleTTL, previewChartsCollectionInterval(idleTTL))
+	return s, nil
 }
 
-// getClients ensures a client getter is available and uses it to return both a typed and dynamic k8s client.
-func (s *Server) GetClients(ctx context.Context) (kubernetes.Interface, dynamic.Interface, error) {
+// newCacheStore returns the storage of the cache of charts configured for the plugin.
+func newCacheStore(pluginConfig *pluginConfig) (cache.Store, error) {
+	if pluginConfig.Cache.Storage == cacheStorageMemory {
+		log.Infof("+fluxv2 caching charts in memory: maxEntries: [%d]", pluginConfig.Cache.MaxEntries)
+		return cache.NewMemoryStore(pluginConfig.Cache.MaxEntries)
+	}
+	// the password is a secret, which is never logged
+	log.Infof("+fluxv2 caching charts in redis: addr: [%s], DB: [%d]", pluginConfig.Redis.Addr, pluginConfig.Redis.DB)
+	return cache.NewRedisStore(cache.NewRedisClient(&redis.Options{
+		Addr:     pluginConfig.Redis.Addr,
+		Password: pluginConfig.Redis.Password,
+		DB:       pluginConfig.Redis.DB,
+	})), nil
+}
+
+// GetClients ensures a client getter is available and uses it to return both a typed and dynamic k8s client
+// for the given cluster.
+func (s *Server) GetClients(ctx context.Context, cluster string) (kubernetes.Interface, dynamic.Interface, error) {
 	if s.clientGetter == nil {
 		return nil, nil, status.Errorf(codes.Internal, "server not configured with configGetter")
 	}
-	typedClient, dynamicClient, err := s.clientGetter(ctx)
+	typedClient, dynamicClient, err := s.clientGetter(ctx, cluster)
 	if err != nil {
 		return nil, nil, status.Errorf(codes.FailedPrecondition, "unable to get client due to: %v", err)
 	}
 	return typedClient, dynamicClient, nil
 }
 
+// checkKubeappsCluster returns an Unimplemented error for any cluster other than the one on
+// which Kubeapps is installed, for which an empty cluster stands, as the charts are only read
+// from the HelmRepositories there, which a HelmRelease on another cluster could not reference.
+func (s *Server) checkKubeappsCluster(cluster string) error {
+	if cluster != "" && cluster != s.kubeappsCluster {
+		return status.Errorf(codes.Unimplemented, "The fluxv2 plugin only supports the cluster [%s] on which Kubeapps is installed, not [%s]", s.kubeappsCluster, cluster)
+	}
+	return nil
+}
+
+// CheckDependencies checks that redis, when used for the cache of charts, is
+// reachable and that the flux CRDs are installed on the cluster on which
+// Kubeapps is installed.
+func (s *Server) CheckDependencies(ctx context.Context) []*plugins.PluginDependencyStatus {
+	dependencies := []*plugins.PluginDependencyStatus{}
+	if s.cache == nil {
+		dependencies = append(dependencies, &plugins.PluginDependencyStatus{
+			Name:    "cache",
+			Message: "server not configured with cache",
+		})
+	} else if _, ok := s.cache.Store().(*cache.RedisStore); ok {
+		redisDependency := &plugins.PluginDependencyStatus{Name: "redis"}
+		if err := s.cache.Ping(ctx); err != nil {
+			redisDependency.Message = err.Error()
+		} else {
+			redisDependency.Reachable = true
+		}
+		dependencies = append(dependencies, redisDependency)
+	}
+
+	fluxGvrs := []schema.GroupVersionResource{
+		{Group: fluxGroup, Version: fluxVersion, Resource: fluxHelmRepositories},
+		{Group: fluxGroup, Version: fluxVersion, Resource: fluxHelmCharts},
+		{Group: fluxHelmReleaseGroup, Version: fluxHelmReleaseVersion, Resource: fluxHelmReleases},
+	}
+	typedClient, _, err := s.GetClients(ctx, "")
+	if err != nil {
+		for _, gvr := range fluxGvrs {
+			dependencies = append(dependencies, &plugins.PluginDependencyStatus{
+				Name:    gvr.GroupResource().String(),
+				Message: err.Error(),
+			})
+		}
+		return dependencies
+	}
+	return append(dependencies, server.CheckGroupVersionResources(typedClient.Discovery(), fluxGvrs)...)
+}
+
 // ===== general note on error handling ========
 // using fmt.Errorf vs status.Errorf in functions exposed as grpc:
 //
@@ -112,37 +203,192 @@ func (s *Server) GetClients(ctx context.Context) (kubernetes.Interface, dynamic.
 // function that returns an error, then your actual handler function handles
 // that error by returning a status.Errorf with the appropriate code
 
-// GetPackageRepositories returns the package repositories based on the request.
+// GetPackageRepositorySummaries returns the flux HelmRepositories in the request context.
 // note that this func currently returns ALL repositories, not just those in 'ready' (reconciled) state
-func (s *Server) GetPackageRepositories(ctx context.Context, request *v1alpha1.GetPackageRepositoriesRequest) (*v1alpha1.GetPackageRepositoriesResponse, error) {
-	log.Infof("+fluxv2 GetPackageRepositories(request: [%v])", request)
+func (s *Server) GetPackageRepositorySummaries(ctx context.Context, request *corev1.GetPackageRepositorySummariesRequest) (*corev1.GetPackageRepositorySummariesResponse, error) {
+	log.Infof("+fluxv2 GetPackageRepositorySummaries(request: [%v])", request)
 
 	if request == nil || request.Context == nil {
 		return nil, status.Errorf(codes.InvalidArgument, "No context provided")
 	}
 
-	if request.Context.Cluster != "" {
-		return nil, status.Errorf(
-			codes.Unimplemented,
-			"Not supported yet: request.Context.Cluster: [%v]",
-			request.Context.Cluster)
-	}
-
-	repos, err := s.getHelmRepos(ctx, request.Context.Namespace)
+	repos, err := s.getHelmRepos(ctx, request.Context.Cluster, request.Context.Namespace)
 	if err != nil {
 		return nil, err
 	}
 
-	responseRepos := []*v1alpha1.PackageRepository{}
+	summaries := []*corev1.PackageRepositorySummary{}
 	for _, repoUnstructured := range repos.Items {
-		repo, err := newPackageRepository(repoUnstructured.Object)
+		summary, err := packageRepositorySummaryFromRepo(request.Context.Cluster, repoUnstructured.Object)
 		if err != nil {
 			return nil, err
 		}
-		responseRepos = append(responseRepos, repo)
+		summaries = append(summaries, summary)
+	}
+	return &corev1.GetPackageRepositorySummariesResponse{
+		PackageRepositorySummaries: summaries,
+	}, nil
+}
+
+// GetPackageRepositoryDetail returns the details of the flux HelmRepository referenced in the request
+func (s *Server) GetPackageRepositoryDetail(ctx context.Context, request *corev1.GetPackageRepositoryDetailRequest) (*corev1.GetPackageRepositoryDetailResponse, error) {
+	log.Infof("+fluxv2 GetPackageRepositoryDetail(request: [%v])", request)
+
+	repoRef := request.GetPackageRepoRef()
+	if err := validatePackageRepositoryRef(repoRef); err != nil {
+		return nil, err
+	}
+
+	resourceIfc, err := s.getRepositoriesResourceInterface(ctx, repoRef.Context.Cluster, repoRef.Context.Namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	unstructuredRepo, err := resourceIfc.Get(ctx, repoRef.Identifier, metav1.GetOptions{})
+	if err != nil {
+		return nil, statusErrorForK8sError(err, fmt.Sprintf("Unable to get fluxv2 helmrepository [%s]", repoRef.Identifier))
+	}
+
+	detail, err := packageRepositoryDetailFromRepo(repoRef.Context.Cluster, unstructuredRepo.Object)
+	if err != nil {
+		return nil, err
+	}
+	return &corev1.GetPackageRepositoryDetailResponse{
+		Detail: detail,
+	}, nil
+}
+
+// AddPackageRepository creates a flux HelmRepository for the repository in the request.
+func (s *Server) AddPackageRepository(ctx context.Context, request *corev1.AddPackageRepositoryRequest) (*corev1.AddPackageRepositoryResponse, error) {
+	log.Infof("+fluxv2 AddPackageRepository(request: [%v])", request)
+
+	if request == nil || request.Context == nil {
+		return nil, status.Errorf(codes.InvalidArgument, "No context provided")
+	}
+	if request.Context.Namespace == "" || request.Name == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "Required namespace or name not provided")
+	}
+
+	unstructuredRepo, err := newFluxHelmRepository(request)
+	if err != nil {
+		return nil, err
+	}
+
+	resourceIfc, err := s.getRepositoriesResourceInterface(ctx, request.Context.Cluster, request.Context.Namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	newRepo, err := resourceIfc.Create(ctx, unstructuredRepo, metav1.CreateOptions{})
+	if err != nil {
+		return nil, statusErrorForK8sError(err, fmt.Sprintf("Unable to create fluxv2 helmrepository [%s]", request.Name))
+	}
+	log.Infof("created repository: [%s/%s]", newRepo.GetNamespace(), newRepo.GetName())
+
+	repoRef, err := packageRepositoryRefFromRepo(request.Context.Cluster, newRepo.Object)
+	if err != nil {
+		return nil, err
+	}
+	return &corev1.AddPackageRepositoryResponse{
+		PackageRepoRef: repoRef,
+	}, nil
+}
+
+// UpdatePackageRepository updates the url, interval and secret of the flux
+// HelmRepository referenced in the request.
+func (s *Server) UpdatePackageRepository(ctx context.Context, request *corev1.UpdatePackageRepositoryRequest) (*corev1.UpdatePackageRepositoryResponse, error) {
+	log.Infof("+fluxv2 UpdatePackageRepository(request: [%v])", request)
+
+	repoRef := request.GetPackageRepoRef()
+	if err := validatePackageRepositoryRef(repoRef); err != nil {
+		return nil, err
+	}
+
+	resourceIfc, err := s.getRepositoriesResourceInterface(ctx, repoRef.Context.Cluster, repoRef.Context.Namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	unstructuredRepo, err := resourceIfc.Get(ctx, repoRef.Identifier, metav1.GetOptions{})
+	if err != nil {
+		return nil, statusErrorForK8sError(err, fmt.Sprintf("Unable to get fluxv2 helmrepository [%s]", repoRef.Identifier))
+	}
+
+	if err = setRepoSpec(unstructuredRepo, request.Url, request.Interval, request.Auth, request.TlsConfig); err != nil {
+		return nil, err
 	}
-	return &v1alpha1.GetPackageRepositoriesResponse{
-		Repositories: responseRepos,
+
+	updatedRepo, err := resourceIfc.Update(ctx, unstructuredRepo, metav1.UpdateOptions{})
+	if err != nil {
+		return nil, statusErrorForK8sError(err, fmt.Sprintf("Unable to update fluxv2 helmrepository [%s]", repoRef.Identifier))
+	}
+	log.Infof("updated repository: [%s/%s]", updatedRepo.GetNamespace(), updatedRepo.GetName())
+
+	updatedRef, err := packageRepositoryRefFromRepo(repoRef.Context.Cluster, updatedRepo.Object)
+	if err != nil {
+		return nil, err
+	}
+	return &corev1.UpdatePackageRepositoryResponse{
+		PackageRepoRef: updatedRef,
+	}, nil
+}
+
+// DeletePackageRepository deletes the flux HelmRepository referenced in the request.
+func (s *Server) DeletePackageRepository(ctx context.Context, request *corev1.DeletePackageRepositoryRequest) (*corev1.DeletePackageRepositoryResponse, error) {
+	log.Infof("+fluxv2 DeletePackageRepository(request: [%v])", request)
+
+	repoRef := request.GetPackageRepoRef()
+	if err := validatePackageRepositoryRef(repoRef); err != nil {
+		return nil, err
+	}
+
+	resourceIfc, err := s.getRepositoriesResourceInterface(ctx, repoRef.Context.Cluster, repoRef.Context.Namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = resourceIfc.Delete(ctx, repoRef.Identifier, metav1.DeleteOptions{}); err != nil {
+		return nil, statusErrorForK8sError(err, fmt.Sprintf("Unable to delete fluxv2 helmrepository [%s]", repoRef.Identifier))
+	}
+	return &corev1.DeletePackageRepositoryResponse{}, nil
+}
+
+// RefreshPackageRepository requests flux to reconcile the HelmRepository referenced
+// in the request outside of its interval, so that its index is fetched again.
+func (s *Server) RefreshPackageRepository(ctx context.Context, request *corev1.RefreshPackageRepositoryRequest) (*corev1.RefreshPackageRepositoryResponse, error) {
+	log.Infof("+fluxv2 RefreshPackageRepository(request: [%v])", request)
+
+	repoRef := request.GetPackageRepoRef()
+	if err := validatePackageRepositoryRef(repoRef); err != nil {
+		return nil, err
+	}
+
+	resourceIfc, err := s.getRepositoriesResourceInterface(ctx, repoRef.Context.Cluster, repoRef.Context.Namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	unstructuredRepo, err := resourceIfc.Get(ctx, repoRef.Identifier, metav1.GetOptions{})
+	if err != nil {
+		return nil, statusErrorForK8sError(err, fmt.Sprintf("Unable to get fluxv2 helmrepository [%s]", repoRef.Identifier))
+	}
+
+	annotations := unstructuredRepo.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[fluxReconcileRequestAnnotation] = time.Now().Format(time.RFC3339Nano)
+	unstructuredRepo.SetAnnotations(annotations)
+
+	if _, err = resourceIfc.Update(ctx, unstructuredRepo, metav1.UpdateOptions{}); err != nil {
+		return nil, statusErrorForK8sError(err, fmt.Sprintf("Unable to refresh fluxv2 helmrepository [%s]", repoRef.Identifier))
+	}
+	return &corev1.RefreshPackageRepositoryResponse{
+		PackageRepoRef: &corev1.PackageRepositoryReference{
+			Context:    repoRef.Context,
+			Identifier: repoRef.Identifier,
+			Plugin:     GetPluginDetail(),
+		},
 	}, nil
 }
 
@@ -151,15 +397,13 @@ func (s *Server) GetPackageRepositories(ctx context.Context, request *v1alpha1.G
 // state. For the fluxv2 plugin, the request context namespace (the target
 // namespace) is not relevant since charts from a repository in any namespace
 //  accessible to the user are available to be installed in the target namespace.
+// The packages are those of the repositories on the cluster on which Kubeapps is
+// installed, so any other request context cluster is rejected.
 func (s *Server) GetAvailablePackageSummaries(ctx context.Context, request *corev1.GetAvailablePackageSummariesRequest) (*corev1.GetAvailablePackageSummariesResponse, error) {
 	log.Infof("+fluxv2 GetAvailablePackageSummaries(request: [%v])", request)
 
-	// grpc compiles in getters for you which automatically return a default (empty) struct if the pointer was nil
-	if request != nil && request.GetContext().GetCluster() != "" {
-		return nil, status.Errorf(
-			codes.Unimplemented,
-			"Not supported yet: request.Context.Cluster: [%v]",
-			request.Context.Cluster)
+	if err := s.checkKubeappsCluster(request.GetContext().GetCluster()); err != nil {
+		return nil, err
 	}
 
 	if s.cache == nil {
@@ -168,39 +412,111 @@ func (s *Server) GetAvailablePackageSummaries(ctx context.Context, request *core
 			"Server cache has not been properly initialized")
 	}
 
-	// TODO (gfichtenholt) use request.FilterOptions
-	repos, err := s.getHelmRepos(ctx, "")
+	pageOffset, err := pageOffsetFromPageToken(request.GetPaginationOptions().GetPageToken())
+	if err != nil {
+		return nil, err
+	}
+
+	// the cache only indexes the repositories on the cluster on which Kubeapps is installed
+	repos, err := s.getHelmRepos(ctx, "", "")
 	if err != nil {
 		return nil, err
 	}
 
-	responsePackagesFromCache, err := s.cache.fetchCachedObjects(repos.Items)
+	chartsFromCache, err := s.cache.FetchForObjects(ctx, repositoriesGvr, repos.Items)
 	if err != nil {
 		return nil, err
 	}
 
-	// this non-sense below is only here to convert from []interface{} which is
-	// what the generic cache implementation returns for cache hits to
-	// a typed array object.
 	responsePackages := make([]*corev1.AvailablePackageSummary, 0)
-	for _, packages := range responsePackagesFromCache {
-		if packages != nil {
-			typedPackages, ok := packages.([]*corev1.AvailablePackageSummary)
-			if !ok {
-				return nil, status.Errorf(
-					codes.Internal,
-					"Unexpected value fetched from cache: %v", packages)
-			} else {
-				responsePackages = append(responsePackages, typedPackages...)
+	for _, value := range chartsFromCache {
+		charts, err := repoChartsFromCacheValue(value)
+		if err != nil {
+			return nil, err
+		}
+		for i := range charts {
+			if chartMatchesFilterOptions(&charts[i], request.GetFilterOptions()) {
+				responsePackages = append(responsePackages, availablePackageSummaryFromChart(&charts[i]))
 			}
 		}
 	}
 
+	// Sort before paginating so that each page continues where the previous
+	// one left off, and so that the core server can merge the results.
+	server.SortAvailablePackageSummaries(responsePackages, request.GetSortOptions())
+	start, end, nextPageToken := pageBounds(len(responsePackages), pageOffset, request.GetPaginationOptions().GetPageSize())
 	return &corev1.GetAvailablePackageSummariesResponse{
-		AvailablePackagesSummaries: responsePackages,
+		AvailablePackagesSummaries: responsePackages[start:end],
+		NextPageToken:              nextPageToken,
 	}, nil
 }
 
+// WatchAvailablePackages streams the changes of the available packages, first sending
+// the packages currently available as added events. As for GetAvailablePackageSummaries,
+// the packages are those of the repositories on the cluster on which Kubeapps is installed,
+// which the user is required to be able to list when the watch starts.
+func (s *Server) WatchAvailablePackages(request *corev1.WatchAvailablePackagesRequest, stream corev1.PackagesService_WatchAvailablePackagesServer) error {
+	log.Infof("+fluxv2 WatchAvailablePackages(request: [%v])", request)
+
+	if s.cache == nil {
+		return status.Errorf(
+			codes.FailedPrecondition,
+			"Server cache has not been properly initialized")
+	}
+
+	ctx := stream.Context()
+	// subscribe before listing the repositories so that no change is missed in between
+	events, unsubscribe := s.cache.Subscribe()
+	defer unsubscribe()
+
+	repos, err := s.getHelmRepos(ctx, "", "")
+	if err != nil {
+		return err
+	}
+
+	view := newAvailablePackagesView(request.GetFilterOptions())
+	send := func(key string, cachedCharts cache.Value) error {
+		responses, err := view.update(key, cachedCharts)
+		if err != nil {
+			return err
+		}
+		for _, response := range responses {
+			if err := stream.Send(response); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for _, repo := range repos.Items {
+		key, err := s.cache.KeyFor(repositoriesGvr, repo.Object)
+		if err != nil {
+			return err
+		}
+		cachedCharts, err := s.cache.Fetch(ctx, key)
+		if err != nil {
+			return err
+		}
+		if err := send(key, cachedCharts); err != nil {
+			return err
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-events:
+			if !ok {
+				return status.Errorf(codes.Aborted, "The watch fell behind the changes to the available packages")
+			}
+			if err := send(event.Key, event.Value); err != nil {
+				return err
+			}
+		}
+	}
+}
+
 // GetAvailablePackageDetail returns the package metadata managed by the 'fluxv2' plugin
 func (s *Server) GetAvailablePackageDetail(ctx context.Context, request *corev1.GetAvailablePackageDetailRequest) (*corev1.GetAvailablePackageDetailResponse, error) {
 	log.Infof("+fluxv2 GetAvailablePackageDetail(request: [%v])", request)
@@ -219,18 +535,15 @@ func (s *Server) GetAvailablePackageDetail(ctx context.Context, request *corev1.
 		return nil, status.Errorf(codes.InvalidArgument, "Invalid package ref identifier: [%s]", packageRef.Identifier)
 	}
 
-	if request.PkgVersion != "" {
-		return nil, status.Errorf(
-			codes.Unimplemented,
-			"Not supported yet: version: [%v]",
-			request.PkgVersion)
-	}
-
 	// TODO (gfichtenholt) check if the repo has been indexed, stored in the cache and requested
 	// package is part of it. Otherwise, there is a time window when this scenario can happen:
 	// - GetAvailablePackageSummaries may return {} while a ready repo is being indexed BUT
 	// - GetAvailablePackageDetail may return package detail
-	url, err := s.pullChartTarball(ctx, packageIdParts[0], packageIdParts[1], packageRef.Context.Namespace)
+	pullCtx, span := startSpan(ctx, "pullChartTarball",
+		attribute.String("kubeapps.package.identifier", packageRef.Identifier),
+		attribute.String("kubeapps.package.version", request.PkgVersion))
+	url, err := s.pullChartTarball(pullCtx, packageIdParts[0], packageIdParts[1], request.PkgVersion, packageRef.Context.Namespace)
+	endSpan(span, err)
 	if err != nil {
 		return nil, err
 	}
@@ -242,34 +555,307 @@ func (s *Server) GetAvailablePackageDetail(ctx context.Context, request *corev1.
 	// E.g. http://source-controller.flux-system.svc.cluster.local./helmchart/default/redis-j6wtx/redis-latest.tgz
 	// Flux does the hard work of pulling the bits from remote repo
 	// based on secretRef associated with HelmRepository, if applicable
+	_, span = startSpan(ctx, "fetchChartDetailFromTarball", attribute.String("http.url", *url))
 	detail, err := tar.FetchChartDetailFromTarball(packageRef.Identifier, *url, "", "", httpclient.New())
+	endSpan(span, err)
+	if err != nil {
+		return nil, err
+	}
+
+	pkgDetail, err := availablePackageDetailFromChartDetail(packageRef, detail)
 	if err != nil {
 		return nil, err
 	}
 
 	return &corev1.GetAvailablePackageDetailResponse{
-		AvailablePackageDetail: &corev1.AvailablePackageDetail{
-			AvailablePackageRef: packageRef, // copy just for now
-			Name:                packageIdParts[1],
-			LongDescription:     detail[chart.ReadmeKey],
-		},
+		AvailablePackageDetail: pkgDetail,
+	}, nil
+}
+
+// GetAvailablePackageVersions returns the versions of the available package, summarised as
+// with the helm plugin. The versions are read from the cached index of the repository of the
+// package, which the user is required to be able to get.
+func (s *Server) GetAvailablePackageVersions(ctx context.Context, request *corev1.GetAvailablePackageVersionsRequest) (*corev1.GetAvailablePackageVersionsResponse, error) {
+	log.Infof("+fluxv2 GetAvailablePackageVersions(request: [%v])", request)
+
+	if s.cache == nil {
+		return nil, status.Errorf(
+			codes.FailedPrecondition,
+			"Server cache has not been properly initialized")
+	}
+
+	packageRef := request.GetAvailablePackageRef()
+	namespace := packageRef.GetContext().GetNamespace()
+	if namespace == "" || packageRef.GetIdentifier() == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "Required context or identifier not provided")
+	}
+	repoName, chartName, err := splitPackageIdentifier(packageRef.Identifier)
+	if err != nil {
+		return nil, err
+	}
+
+	// the cache only indexes the repositories on the cluster on which Kubeapps is installed
+	resourceIfc, err := s.getRepositoriesResourceInterface(ctx, "", namespace)
+	if err != nil {
+		return nil, err
+	}
+	unstructuredRepo, err := resourceIfc.Get(ctx, repoName, metav1.GetOptions{})
+	if err != nil {
+		return nil, statusErrorForK8sError(err, fmt.Sprintf("Unable to get fluxv2 helmrepository [%s]", repoName))
+	}
+	key, err := s.cache.KeyFor(repositoriesGvr, unstructuredRepo.Object)
+	if err != nil {
+		return nil, err
+	}
+	value, err := s.cache.Fetch(ctx, key)
+	if err != nil {
+		return nil, err
+	} else if value == nil {
+		return nil, status.Errorf(codes.NotFound, "Repository [%s] has not been indexed yet", repoName)
+	}
+	charts, err := repoChartsFromCacheValue(value)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range charts {
+		if charts[i].Name == chartName {
+			return &corev1.GetAvailablePackageVersionsResponse{
+				PackageAppVersions: packageAppVersionsSummary(charts[i].ChartVersions),
+			}, nil
+		}
+	}
+	return nil, status.Errorf(codes.NotFound, "Package [%s] not found in repository [%s]", chartName, repoName)
+}
+
+// GetInstalledPackageSummaries returns the installed packages managed by the 'fluxv2' plugin,
+// i.e. the flux HelmReleases in the request context namespace (or all namespaces, if empty)
+func (s *Server) GetInstalledPackageSummaries(ctx context.Context, request *corev1.GetInstalledPackageSummariesRequest) (*corev1.GetInstalledPackageSummariesResponse, error) {
+	log.Infof("+fluxv2 GetInstalledPackageSummaries(request: [%v])", request)
+
+	pageOffset, err := pageOffsetFromPageToken(request.GetPaginationOptions().GetPageToken())
+	if err != nil {
+		return nil, err
+	}
+
+	resourceIfc, err := s.getReleasesResourceInterface(ctx, request.GetContext().GetCluster(), request.GetContext().GetNamespace())
+	if err != nil {
+		return nil, err
+	}
+
+	releaseList, err := resourceIfc.List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, statusErrorForK8sError(err, "Unable to list fluxv2 helmreleases")
+	}
+
+	start, end, nextPageToken := pageBounds(len(releaseList.Items), pageOffset, request.GetPaginationOptions().GetPageSize())
+	installedPkgSummaries := []*corev1.InstalledPackageSummary{}
+	for _, unstructuredRel := range releaseList.Items[start:end] {
+		summary, err := installedPackageSummaryFromRelease(request.GetContext().GetCluster(), unstructuredRel.Object)
+		if err != nil {
+			return nil, err
+		}
+		installedPkgSummaries = append(installedPkgSummaries, summary)
+	}
+
+	return &corev1.GetInstalledPackageSummariesResponse{
+		InstalledPackagesSummaries: installedPkgSummaries,
+		NextPageToken:              nextPageToken,
 	}, nil
 }
 
-// returns the url from which chart .tgz can be downloaded
-func (s *Server) pullChartTarball(ctx context.Context, repoName string, chartName string, namespace string) (*string, error) {
-	_, client, err := s.GetClients(ctx)
+// GetInstalledPackageDetail returns the details of the flux HelmRelease referenced in the request
+func (s *Server) GetInstalledPackageDetail(ctx context.Context, request *corev1.GetInstalledPackageDetailRequest) (*corev1.GetInstalledPackageDetailResponse, error) {
+	log.Infof("+fluxv2 GetInstalledPackageDetail(request: [%v])", request)
+
+	installedRef := request.GetInstalledPackageRef()
+	if err := validateInstalledPackageRef(installedRef); err != nil {
+		return nil, err
+	}
+
+	resourceIfc, err := s.getReleasesResourceInterface(ctx, installedRef.Context.Cluster, installedRef.Context.Namespace)
 	if err != nil {
 		return nil, err
 	}
 
-	chartsResource := schema.GroupVersionResource{
-		Group:    fluxGroup,
-		Version:  fluxVersion,
-		Resource: fluxHelmCharts,
+	unstructuredRel, err := resourceIfc.Get(ctx, installedRef.Identifier, metav1.GetOptions{})
+	if err != nil {
+		return nil, statusErrorForK8sError(err, fmt.Sprintf("Unable to get fluxv2 helmrelease [%s]", installedRef.Identifier))
+	}
+
+	detail, err := installedPackageDetailFromRelease(installedRef.Context.Cluster, unstructuredRel.Object)
+	if err != nil {
+		return nil, err
 	}
 
-	resourceIfc := client.Resource(chartsResource).Namespace(namespace)
+	return &corev1.GetInstalledPackageDetailResponse{
+		InstalledPackageDetail: detail,
+	}, nil
+}
+
+// CreateInstalledPackage creates a flux HelmRelease for the chart referenced in the request.
+// The HelmRepository referenced by the available package ref is used as the chart source, so
+// both the repository and the release must be on the cluster on which Kubeapps is installed.
+func (s *Server) CreateInstalledPackage(ctx context.Context, request *corev1.CreateInstalledPackageRequest) (*corev1.CreateInstalledPackageResponse, error) {
+	log.Infof("+fluxv2 CreateInstalledPackage(request: [%v])", request)
+
+	if request == nil || request.AvailablePackageRef == nil || request.TargetContext == nil {
+		return nil, status.Errorf(codes.InvalidArgument, "Required AvailablePackageRef or TargetContext not provided")
+	}
+	if request.AvailablePackageRef.GetContext().GetNamespace() == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "AvailablePackageReference is missing required 'namespace' field")
+	}
+	if request.TargetContext.Namespace == "" || request.Name == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "Required target namespace or name not provided")
+	}
+	if err := s.checkKubeappsCluster(request.AvailablePackageRef.GetContext().GetCluster()); err != nil {
+		return nil, err
+	}
+	if err := s.checkKubeappsCluster(request.TargetContext.Cluster); err != nil {
+		return nil, err
+	}
+	unstructuredRel, err := newFluxHelmRelease(
+		request.AvailablePackageRef,
+		request.TargetContext.Namespace,
+		request.Name,
+		request.PkgVersionReference,
+		request.Values,
+		request.ReconciliationOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	resourceIfc, err := s.getReleasesResourceInterface(ctx, request.TargetContext.Cluster, request.TargetContext.Namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	newRel, err := resourceIfc.Create(ctx, unstructuredRel, metav1.CreateOptions{})
+	if err != nil {
+		return nil, statusErrorForK8sError(err, fmt.Sprintf("Unable to create fluxv2 helmrelease [%s]", request.Name))
+	}
+	log.Infof("created release: [%s/%s]", newRel.GetNamespace(), newRel.GetName())
+
+	installedRef, err := installedPackageRefFromRelease(request.TargetContext.Cluster, newRel.Object)
+	if err != nil {
+		return nil, err
+	}
+	return &corev1.CreateInstalledPackageResponse{
+		InstalledPackageRef: installedRef,
+	}, nil
+}
+
+// UpdateInstalledPackage updates the chart version, values and reconciliation options of
+// the flux HelmRelease referenced in the request. An empty version removes the version
+// constraint, so that flux upgrades to the latest chart version available.
+func (s *Server) UpdateInstalledPackage(ctx context.Context, request *corev1.UpdateInstalledPackageRequest) (*corev1.UpdateInstalledPackageResponse, error) {
+	log.Infof("+fluxv2 UpdateInstalledPackage(request: [%v])", request)
+
+	installedRef := request.GetInstalledPackageRef()
+	if err := validateInstalledPackageRef(installedRef); err != nil {
+		return nil, err
+	}
+	if err := s.checkKubeappsCluster(installedRef.Context.Cluster); err != nil {
+		return nil, err
+	}
+
+	resourceIfc, err := s.getReleasesResourceInterface(ctx, installedRef.Context.Cluster, installedRef.Context.Namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	unstructuredRel, err := resourceIfc.Get(ctx, installedRef.Identifier, metav1.GetOptions{})
+	if err != nil {
+		return nil, statusErrorForK8sError(err, fmt.Sprintf("Unable to get fluxv2 helmrelease [%s]", installedRef.Identifier))
+	}
+
+	if version := request.GetPkgVersionReference().GetVersion(); version != "" {
+		err = unstructured.SetNestedField(unstructuredRel.Object, version, "spec", "chart", "spec", "version")
+	} else {
+		unstructured.RemoveNestedField(unstructuredRel.Object, "spec", "chart", "spec", "version")
+	}
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Unable to set version of fluxv2 helmrelease: %v", err)
+	}
+	if err = setReleaseValues(unstructuredRel, request.Values); err != nil {
+		return nil, err
+	}
+	if err = setReleaseReconciliationOptions(unstructuredRel, request.ReconciliationOptions); err != nil {
+		return nil, err
+	}
+
+	updatedRel, err := resourceIfc.Update(ctx, unstructuredRel, metav1.UpdateOptions{})
+	if err != nil {
+		return nil, statusErrorForK8sError(err, fmt.Sprintf("Unable to update fluxv2 helmrelease [%s]", installedRef.Identifier))
+	}
+	log.Infof("updated release: [%s/%s]", updatedRel.GetNamespace(), updatedRel.GetName())
+
+	updatedRef, err := installedPackageRefFromRelease(installedRef.Context.Cluster, updatedRel.Object)
+	if err != nil {
+		return nil, err
+	}
+	return &corev1.UpdateInstalledPackageResponse{
+		InstalledPackageRef: updatedRef,
+	}, nil
+}
+
+// DeleteInstalledPackage deletes the flux HelmRelease referenced in the request.
+// flux takes care of uninstalling the helm release itself.
+func (s *Server) DeleteInstalledPackage(ctx context.Context, request *corev1.DeleteInstalledPackageRequest) (*corev1.DeleteInstalledPackageResponse, error) {
+	log.Infof("+fluxv2 DeleteInstalledPackage(request: [%v])", request)
+
+	installedRef := request.GetInstalledPackageRef()
+	if err := validateInstalledPackageRef(installedRef); err != nil {
+		return nil, err
+	}
+
+	resourceIfc, err := s.getReleasesResourceInterface(ctx, installedRef.Context.Cluster, installedRef.Context.Namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = resourceIfc.Delete(ctx, installedRef.Identifier, metav1.DeleteOptions{}); err != nil {
+		return nil, statusErrorForK8sError(err, fmt.Sprintf("Unable to delete fluxv2 helmrelease [%s]", installedRef.Identifier))
+	}
+	return &corev1.DeleteInstalledPackageResponse{}, nil
+}
+
+// namespace maybe "", in which case the interface spans releases in all namespaces of the cluster
+func (s *Server) getReleasesResourceInterface(ctx context.Context, cluster, namespace string) (dynamic.ResourceInterface, error) {
+	_, client, err := s.GetClients(ctx, cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	releasesResource := schema.GroupVersionResource{
+		Group:    fluxHelmReleaseGroup,
+		Version:  fluxHelmReleaseVersion,
+		Resource: fluxHelmReleases,
+	}
+
+	return client.Resource(releasesResource).Namespace(namespace), nil
+}
+
+// namespace maybe "", in which case the interface spans repositories in all namespaces of the cluster
+func (s *Server) getRepositoriesResourceInterface(ctx context.Context, cluster, namespace string) (dynamic.ResourceInterface, error) {
+	_, client, err := s.GetClients(ctx, cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	return client.Resource(repositoriesGvr).Namespace(namespace), nil
+}
+
+// returns the url from which chart .tgz can be downloaded. The HelmChart is created on the
+// cluster on which Kubeapps is installed, where the repositories in the cache are. When a
+// version is given, the HelmChart pulls that version rather than the latest one
+func (s *Server) pullChartTarball(ctx context.Context, repoName string, chartName string, version string, namespace string) (*string, error) {
+	_, client, err := s.GetClients(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+
+	resourceIfc := client.Resource(chartsGvr).Namespace(namespace)
 
 	// see if we the chart already exists
 	// TODO (gfichtenholt):
@@ -289,8 +875,7 @@ func (s *Server) pullChartTarball(ctx context.Context, repoName string, chartNam
 		thisChartName, found, err := unstructured.NestedString(unstructuredChart.Object, "spec", "chart")
 		thisRepoName, found2, err2 := unstructured.NestedString(unstructuredChart.Object, "spec", "sourceRef", "name")
 
-		// TODO (gfichtenholt) compare chart versions too
-		if err == nil && err2 == nil && found && found2 && repoName == thisRepoName && chartName == thisChartName {
+		if err == nil && err2 == nil && found && found2 && repoName == thisRepoName && chartName == thisChartName && chartVersionMatches(unstructuredChart.Object, version) {
 			done, err := isChartPullComplete(&unstructuredChart)
 			if err != nil {
 				return nil, err
@@ -299,7 +884,11 @@ func (s *Server) pullChartTarball(ctx context.Context, repoName string, chartNam
 				if err != nil || !found {
 					return nil, status.Errorf(codes.Internal, "expected field status.url not found on HelmChart: %v:\n%v", err, unstructuredChart)
 				}
-				log.Infof("Found existing HelmChart for: [%s/%s]", repoName, chartName)
+				log.Infof("Found existing HelmChart for: [%s/%s] version: [%s]", repoName, chartName, version)
+				if err := touchPreviewChart(ctx, resourceIfc, &unstructuredChart, time.Now()); err != nil {
+					// the chart may only be garbage collected a bit early
+					log.Warningf("Failed to record the use of HelmChart [%s/%s] due to: %v", namespace, unstructuredChart.GetName(), err)
+				}
 				return &url, nil
 			}
 			// TODO (gfichtenholt) waitUntilChartPullComplete?
@@ -312,25 +901,14 @@ func (s *Server) pullChartTarball(ctx context.Context, repoName string, chartNam
 	// 1. HelmChart object needs to be co-located in the same namespace as the HelmRepository it is referencing.
 	// 2. flux impersonates a "super" user when doing this (see fluxv2 plug-in specific notes at the end of
 	//	design doc). We should probably be doing simething similar to avoid RBAC-related problems
-	unstructuredChart := unstructured.Unstructured{
-		Object: map[string]interface{}{
-			"apiVersion": fmt.Sprintf("%s/%s", fluxGroup, fluxVersion),
-			"kind":       fluxHelmChart,
-			"metadata": map[string]interface{}{
-				"generateName": fmt.Sprintf("%s-", chartName),
-			},
-			"spec": map[string]interface{}{
-				"chart": chartName,
-				"sourceRef": map[string]interface{}{
-					"name": repoName,
-					"kind": fluxHelmRepository,
-				},
-				"interval": "10m",
-			},
-		},
+
+	// the HelmChart is garbage collected once it has not been used for a while
+	unstructuredChart, err := newPreviewChart(repoName, chartName, version, time.Now())
+	if err != nil {
+		return nil, err
 	}
 
-	newChart, err := resourceIfc.Create(ctx, &unstructuredChart, metav1.CreateOptions{})
+	newChart, err := resourceIfc.Create(ctx, unstructuredChart, metav1.CreateOptions{})
 	if err != nil {
 		log.Errorf("error creating chart: %v\n%v", err, unstructuredChart)
 		return nil, err
@@ -351,20 +929,14 @@ func (s *Server) pullChartTarball(ctx context.Context, repoName string, chartNam
 	return waitUntilChartPullComplete(watcher)
 }
 
-// namespace maybe "", in which case repositories from all namespaces are returned
-func (s *Server) getHelmRepos(ctx context.Context, namespace string) (*unstructured.UnstructuredList, error) {
-	_, client, err := s.GetClients(ctx)
+// namespace maybe "", in which case repositories from all namespaces of the cluster are returned
+func (s *Server) getHelmRepos(ctx context.Context, cluster, namespace string) (*unstructured.UnstructuredList, error) {
+	resourceIfc, err := s.getRepositoriesResourceInterface(ctx, cluster, namespace)
 	if err != nil {
 		return nil, err
 	}
 
-	repositoriesResource := schema.GroupVersionResource{
-		Group:    fluxGroup,
-		Version:  fluxVersion,
-		Resource: fluxHelmRepositories,
-	}
-
-	repos, err := client.Resource(repositoriesResource).Namespace(namespace).List(ctx, metav1.ListOptions{})
+	repos, err := resourceIfc.List(ctx, metav1.ListOptions{})
 	if err != nil {
 		return nil, status.Errorf(codes.Internal, "unable to list fluxv2 helmrepositories: %v", err)
 	} else {
@@ -15,30 +15,38 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"strings"
-	"sync"
 	"testing"
+	"time"
 
 	redismock "github.com/go-redis/redismock/v8"
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
 	corev1 "github.com/kubeapps/kubeapps/cmd/kubeapps-apis/gen/core/packages/v1alpha1"
-	"github.com/kubeapps/kubeapps/cmd/kubeapps-apis/gen/plugins/fluxv2/packages/v1alpha1"
+	plugins "github.com/kubeapps/kubeapps/cmd/kubeapps-apis/gen/core/plugins/v1alpha1"
 	"github.com/kubeapps/kubeapps/cmd/kubeapps-apis/server"
+	"github.com/kubeapps/kubeapps/cmd/kubeapps-apis/server/cache"
+	chart "github.com/kubeapps/kubeapps/pkg/chart/models"
+	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
-	"google.golang.org/protobuf/proto"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/watch"
+	fakediscovery "k8s.io/client-go/discovery/fake"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/dynamic/fake"
 	"k8s.io/client-go/kubernetes"
+	typfake "k8s.io/client-go/kubernetes/fake"
 	k8stesting "k8s.io/client-go/testing"
 )
 
@@ -82,7 +90,7 @@ func TestBadClientGetter(t *testing.T) {
 	}{
 		{
 			name: "returns failed-precondition when configGetter itself errors",
-			clientGetter: func(context.Context) (kubernetes.Interface, dynamic.Interface, error) {
+			clientGetter: func(context.Context, string) (kubernetes.Interface, dynamic.Interface, error) {
 				return nil, nil, fmt.Errorf("Bang!")
 			},
 			statusCode: codes.FailedPrecondition,
@@ -116,6 +124,335 @@ func TestBadClientGetter(t *testing.T) {
 	}
 }
 
+func TestNewPluginConfig(t *testing.T) {
+	testCases := []struct {
+		name           string
+		env            map[string]string
+		config         server.PluginConfig
+		expectedConfig *pluginConfig
+		expectedErr    bool
+	}{
+		{
+			name: "it reads the redis configuration from the config file",
+			config: server.PluginConfig{
+				"redis": map[string]interface{}{
+					"addr":     "kubeapps-redis-master:6379",
+					"password": "redis-password",
+					"db":       1,
+				},
+			},
+			expectedConfig: &pluginConfig{
+				Cache:         cacheConfig{Storage: "redis", MaxEntries: defaultCacheMaxEntries},
+				PreviewCharts: previewChartsConfig{IdleTTL: defaultPreviewChartsIdleTTL},
+				Redis: redisConfig{
+					Addr:     "kubeapps-redis-master:6379",
+					Password: "redis-password",
+					DB:       1,
+				},
+			},
+		},
+		{
+			name: "it defaults to the environment for values not in the config file",
+			env: map[string]string{
+				"REDIS_ADDR":     "localhost:6379",
+				"REDIS_PASSWORD": "password-from-secret",
+				"REDIS_DB":       "2",
+			},
+			config: server.PluginConfig{
+				"redis": map[string]interface{}{
+					"addr": "kubeapps-redis-master:6379",
+				},
+			},
+			expectedConfig: &pluginConfig{
+				Cache:         cacheConfig{Storage: "redis", MaxEntries: defaultCacheMaxEntries},
+				PreviewCharts: previewChartsConfig{IdleTTL: defaultPreviewChartsIdleTTL},
+				Redis: redisConfig{
+					Addr:     "kubeapps-redis-master:6379",
+					Password: "password-from-secret",
+					DB:       2,
+				},
+			},
+		},
+		{
+			name:   "it caches in memory without a redis address",
+			config: server.PluginConfig{},
+			expectedConfig: &pluginConfig{
+				Cache:         cacheConfig{Storage: "memory", MaxEntries: defaultCacheMaxEntries},
+				PreviewCharts: previewChartsConfig{IdleTTL: defaultPreviewChartsIdleTTL},
+			},
+		},
+		{
+			name: "it caches in memory when configured, even with a redis address",
+			env: map[string]string{
+				"REDIS_ADDR": "localhost:6379",
+			},
+			config: server.PluginConfig{
+				"cache": map[string]interface{}{
+					"storage":    "memory",
+					"maxEntries": 10,
+				},
+			},
+			expectedConfig: &pluginConfig{
+				Cache:         cacheConfig{Storage: "memory", MaxEntries: 10},
+				PreviewCharts: previewChartsConfig{IdleTTL: defaultPreviewChartsIdleTTL},
+				Redis:         redisConfig{Addr: "localhost:6379"},
+			},
+		},
+		{
+			name: "it reads the idle TTL of the preview charts from the config file",
+			config: server.PluginConfig{
+				"previewCharts": map[string]interface{}{
+					"idleTTL": "30m",
+				},
+			},
+			expectedConfig: &pluginConfig{
+				Cache:         cacheConfig{Storage: "memory", MaxEntries: defaultCacheMaxEntries},
+				PreviewCharts: previewChartsConfig{IdleTTL: 30 * time.Minute},
+			},
+		},
+		{
+			name: "it returns an error for redis storage without a redis address",
+			config: server.PluginConfig{
+				"cache": map[string]interface{}{
+					"storage": "redis",
+				},
+			},
+			expectedErr: true,
+		},
+		{
+			name: "it returns an error for an unknown storage",
+			config: server.PluginConfig{
+				"cache": map[string]interface{}{
+					"storage": "disk",
+				},
+			},
+			expectedErr: true,
+		},
+		{
+			name: "it returns an error for a non-positive maxEntries",
+			config: server.PluginConfig{
+				"cache": map[string]interface{}{
+					"maxEntries": 0,
+				},
+			},
+			expectedErr: true,
+		},
+		{
+			name: "it returns an error for a non-positive idle TTL of the preview charts",
+			config: server.PluginConfig{
+				"previewCharts": map[string]interface{}{
+					"idleTTL": "0s",
+				},
+			},
+			expectedErr: true,
+		},
+		{
+			name: "it returns an error for an idle TTL of the preview charts under a minute",
+			config: server.PluginConfig{
+				"previewCharts": map[string]interface{}{
+					"idleTTL": "59s",
+				},
+			},
+			expectedErr: true,
+		},
+		{
+			name: "it returns an error for an idle TTL of the preview charts which is a bare number",
+			config: server.PluginConfig{
+				"previewCharts": map[string]interface{}{
+					"idleTTL": 3600,
+				},
+			},
+			expectedErr: true,
+		},
+		{
+			name: "it returns an error for an idle TTL of the preview charts of one nanosecond",
+			config: server.PluginConfig{
+				"previewCharts": map[string]interface{}{
+					"idleTTL": 1,
+				},
+			},
+			expectedErr: true,
+		},
+		{
+			name: "it accepts an idle TTL of the preview charts of a minute",
+			config: server.PluginConfig{
+				"previewCharts": map[string]interface{}{
+					"idleTTL": "1m",
+				},
+			},
+			expectedConfig: &pluginConfig{
+				Cache:         cacheConfig{Storage: "memory", MaxEntries: defaultCacheMaxEntries},
+				PreviewCharts: previewChartsConfig{IdleTTL: time.Minute},
+			},
+		},
+		{
+			name: "it returns an error for an invalid REDIS_DB",
+			env: map[string]string{
+				"REDIS_ADDR": "localhost:6379",
+				"REDIS_DB":   "zero",
+			},
+			expectedErr: true,
+		},
+		{
+			name: "it returns an error for a negative redis DB",
+			config: server.PluginConfig{
+				"redis": map[string]interface{}{
+					"addr": "kubeapps-redis-master:6379",
+					"db":   -1,
+				},
+			},
+			expectedErr: true,
+		},
+		{
+			name: "it returns an error for unknown keys",
+			config: server.PluginConfig{
+				"redis": map[string]interface{}{
+					"address": "kubeapps-redis-master:6379",
+				},
+			},
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, name := range []string{"REDIS_ADDR", "REDIS_PASSWORD", "REDIS_DB"} {
+				value, ok := tc.env[name]
+				if ok {
+					os.Setenv(name, value)
+				} else {
+					os.Unsetenv(name)
+				}
+				defer os.Unsetenv(name)
+			}
+
+			config, err := newPluginConfig(tc.config)
+			if got, want := err != nil, tc.expectedErr; got != want {
+				t.Fatalf("got error: %v, want error: %t", err, want)
+			}
+			if tc.expectedErr {
+				if got, want := status.Code(err), codes.FailedPrecondition; got != want {
+					t.Errorf("got: %+v, want: %+v", got, want)
+				}
+				return
+			}
+
+			if got, want := config, tc.expectedConfig; !cmp.Equal(want, got) {
+				t.Errorf("mismatch (-want +got):\n%s", cmp.Diff(want, got))
+			}
+		})
+	}
+}
+
+func TestCheckDependencies(t *testing.T) {
+	testCases := []struct {
+		name                 string
+		inMemory             bool
+		redisErr             error
+		apiResources         []*metav1.APIResourceList
+		expectedDependencies []*plugins.PluginDependencyStatus
+	}{
+		{
+			name: "reports all dependencies reachable",
+			apiResources: []*metav1.APIResourceList{
+				{
+					GroupVersion: "source.toolkit.fluxcd.io/v1beta1",
+					APIResources: []metav1.APIResource{{Name: "helmrepositories"}, {Name: "helmcharts"}},
+				},
+				{
+					GroupVersion: "helm.toolkit.fluxcd.io/v2beta1",
+					APIResources: []metav1.APIResource{{Name: "helmreleases"}},
+				},
+			},
+			expectedDependencies: []*plugins.PluginDependencyStatus{
+				{Name: "redis", Reachable: true},
+				{Name: "helmrepositories.source.toolkit.fluxcd.io", Reachable: true},
+				{Name: "helmcharts.source.toolkit.fluxcd.io", Reachable: true},
+				{Name: "helmreleases.helm.toolkit.fluxcd.io", Reachable: true},
+			},
+		},
+		{
+			name:     "reports redis and the missing CRDs unreachable",
+			redisErr: fmt.Errorf("connection refused"),
+			apiResources: []*metav1.APIResourceList{
+				{
+					GroupVersion: "source.toolkit.fluxcd.io/v1beta1",
+					APIResources: []metav1.APIResource{{Name: "helmrepositories"}, {Name: "helmcharts"}},
+				},
+			},
+			expectedDependencies: []*plugins.PluginDependencyStatus{
+				{Name: "redis", Message: "connection refused"},
+				{Name: "helmrepositories.source.toolkit.fluxcd.io", Reachable: true},
+				{Name: "helmcharts.source.toolkit.fluxcd.io", Reachable: true},
+				{Name: "helmreleases.helm.toolkit.fluxcd.io", Message: "GroupVersion \"helm.toolkit.fluxcd.io/v2beta1\" not found"},
+			},
+		},
+		{
+			name:     "does not report redis when caching in memory",
+			inMemory: true,
+			apiResources: []*metav1.APIResourceList{
+				{
+					GroupVersion: "source.toolkit.fluxcd.io/v1beta1",
+					APIResources: []metav1.APIResource{{Name: "helmrepositories"}, {Name: "helmcharts"}},
+				},
+				{
+					GroupVersion: "helm.toolkit.fluxcd.io/v2beta1",
+					APIResources: []metav1.APIResource{{Name: "helmreleases"}},
+				},
+			},
+			expectedDependencies: []*plugins.PluginDependencyStatus{
+				{Name: "helmrepositories.source.toolkit.fluxcd.io", Reachable: true},
+				{Name: "helmcharts.source.toolkit.fluxcd.io", Reachable: true},
+				{Name: "helmreleases.helm.toolkit.fluxcd.io", Reachable: true},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			typedClient := typfake.NewSimpleClientset()
+			typedClient.Discovery().(*fakediscovery.FakeDiscovery).Resources = tc.apiResources
+			clientGetter := func(context.Context, string) (kubernetes.Interface, dynamic.Interface, error) {
+				return typedClient, nil, nil
+			}
+			redisCli, mock := redismock.NewClientMock()
+			var store cache.Store = cache.NewRedisStore(redisCli)
+			if tc.inMemory {
+				memoryStore, err := cache.NewMemoryStore(defaultCacheMaxEntries)
+				if err != nil {
+					t.Fatalf("%+v", err)
+				}
+				store = memoryStore
+			} else {
+				mock.ExpectPing().SetVal("PONG")
+			}
+			c, err := cache.NewCache(cache.Config{ClientGetter: clientGetter}, store)
+			if err != nil {
+				t.Fatalf("%+v", err)
+			}
+			if tc.redisErr != nil {
+				mock.ExpectPing().SetErr(tc.redisErr)
+			} else if !tc.inMemory {
+				mock.ExpectPing().SetVal("PONG")
+			}
+			s := &Server{
+				clientGetter: clientGetter,
+				cache:        c,
+			}
+
+			dependencies := s.CheckDependencies(context.Background())
+
+			opts := cmpopts.IgnoreUnexported(plugins.PluginDependencyStatus{})
+			if got, want := dependencies, tc.expectedDependencies; !cmp.Equal(want, got, opts) {
+				t.Errorf("mismatch (-want +got):\n%s", cmp.Diff(want, got, opts))
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatalf("%v", err)
+			}
+		})
+	}
+}
+
 func TestGetAvailablePackagesStatus(t *testing.T) {
 	testCases := []struct {
 		name       string
@@ -259,18 +596,22 @@ func TestGetAvailablePackageSummaries(t *testing.T) {
 			request: &corev1.GetAvailablePackageSummariesRequest{Context: &corev1.Context{}},
 			expectedPackages: []*corev1.AvailablePackageSummary{
 				{
+					Name:             "acs-engine-autoscaler",
 					DisplayName:      "acs-engine-autoscaler",
 					LatestPkgVersion: "2.1.1",
 					IconUrl:          "https://github.com/kubernetes/kubernetes/blob/master/logo/logo.png",
+					Repository:       "bitnami-1",
 					AvailablePackageRef: &corev1.AvailablePackageReference{
 						Identifier: "bitnami-1/acs-engine-autoscaler",
 						Context:    &corev1.Context{Namespace: "default"},
 					},
 				},
 				{
+					Name:             "wordpress",
 					DisplayName:      "wordpress",
 					LatestPkgVersion: "0.7.5",
 					IconUrl:          "https://bitnami.com/assets/stacks/wordpress/img/wordpress-stack-220x234.png",
+					Repository:       "bitnami-1",
 					AvailablePackageRef: &corev1.AvailablePackageReference{
 						Identifier: "bitnami-1/wordpress",
 						Context:    &corev1.Context{Namespace: "default"},
@@ -291,18 +632,22 @@ func TestGetAvailablePackageSummaries(t *testing.T) {
 			request: &corev1.GetAvailablePackageSummariesRequest{Context: &corev1.Context{Namespace: "default"}},
 			expectedPackages: []*corev1.AvailablePackageSummary{
 				{
+					Name:             "acs-engine-autoscaler",
 					DisplayName:      "acs-engine-autoscaler",
 					LatestPkgVersion: "2.1.1",
 					IconUrl:          "https://github.com/kubernetes/kubernetes/blob/master/logo/logo.png",
+					Repository:       "bitnami-1",
 					AvailablePackageRef: &corev1.AvailablePackageReference{
 						Identifier: "bitnami-1/acs-engine-autoscaler",
 						Context:    &corev1.Context{Namespace: "default"},
 					},
 				},
 				{
+					Name:             "wordpress",
 					DisplayName:      "wordpress",
 					LatestPkgVersion: "0.7.5",
 					IconUrl:          "https://bitnami.com/assets/stacks/wordpress/img/wordpress-stack-220x234.png",
+					Repository:       "bitnami-1",
 					AvailablePackageRef: &corev1.AvailablePackageReference{
 						Identifier: "bitnami-1/wordpress",
 						Context:    &corev1.Context{Namespace: "default"},
@@ -329,27 +674,33 @@ func TestGetAvailablePackageSummaries(t *testing.T) {
 			request: &corev1.GetAvailablePackageSummariesRequest{Context: &corev1.Context{Namespace: "non-default"}},
 			expectedPackages: []*corev1.AvailablePackageSummary{
 				{
+					Name:             "acs-engine-autoscaler",
 					DisplayName:      "acs-engine-autoscaler",
 					LatestPkgVersion: "2.1.1",
 					IconUrl:          "https://github.com/kubernetes/kubernetes/blob/master/logo/logo.png",
+					Repository:       "bitnami-1",
 					AvailablePackageRef: &corev1.AvailablePackageReference{
 						Identifier: "bitnami-1/acs-engine-autoscaler",
 						Context:    &corev1.Context{Namespace: "default"},
 					},
 				},
 				{
+					Name:             "cert-manager",
 					DisplayName:      "cert-manager",
 					LatestPkgVersion: "v1.4.0",
 					IconUrl:          "https://raw.githubusercontent.com/jetstack/cert-manager/master/logo/logo.png",
+					Repository:       "jetstack-1",
 					AvailablePackageRef: &corev1.AvailablePackageReference{
 						Identifier: "jetstack-1/cert-manager",
 						Context:    &corev1.Context{Namespace: "ns1"},
 					},
 				},
 				{
+					Name:             "wordpress",
 					DisplayName:      "wordpress",
 					LatestPkgVersion: "0.7.5",
 					IconUrl:          "https://bitnami.com/assets/stacks/wordpress/img/wordpress-stack-220x234.png",
+					Repository:       "bitnami-1",
 					AvailablePackageRef: &corev1.AvailablePackageReference{
 						Identifier: "bitnami-1/wordpress",
 						Context:    &corev1.Context{Namespace: "default"},
@@ -357,109 +708,300 @@ func TestGetAvailablePackageSummaries(t *testing.T) {
 				},
 			},
 		},
-	}
-	for _, tc := range testCases {
-		t.Run(tc.testName, func(t *testing.T) {
-			repos := []runtime.Object{}
-
-			for _, rs := range tc.testRepos {
-				indexYAMLBytes, err := ioutil.ReadFile(rs.index)
-				if err != nil {
-					t.Fatalf("%+v", err)
-				}
-
-				// stand up an http server just for the duration of this test
-				ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-					fmt.Fprintln(w, string(indexYAMLBytes))
-				}))
-				defer ts.Close()
-
-				repoSpec := map[string]interface{}{
-					"url":      rs.url,
-					"interval": "1m0s",
-				}
-				repoStatus := map[string]interface{}{
-					"conditions": []interface{}{
-						map[string]interface{}{
-							"type":   "Ready",
-							"status": "True",
-							"reason": "IndexationSucceed",
-						},
+		{
+			testName: "it filters fluxv2 packages by a text query on the keywords",
+			testRepos: []testRepoStruct{
+				{
+					name:      "bitnami-1",
+					namespace: "default",
+					url:       "https://example.repo.com/charts",
+					index:     "testdata/valid-index.yaml",
+				},
+			},
+			request: &corev1.GetAvailablePackageSummariesRequest{
+				Context:       &corev1.Context{Namespace: "default"},
+				FilterOptions: &corev1.FilterOptions{Query: "BLOG"},
+			},
+			expectedPackages: []*corev1.AvailablePackageSummary{
+				{
+					Name:             "wordpress",
+					DisplayName:      "wordpress",
+					LatestPkgVersion: "0.7.5",
+					IconUrl:          "https://bitnami.com/assets/stacks/wordpress/img/wordpress-stack-220x234.png",
+					Repository:       "bitnami-1",
+					AvailablePackageRef: &corev1.AvailablePackageReference{
+						Identifier: "bitnami-1/wordpress",
+						Context:    &corev1.Context{Namespace: "default"},
 					},
-					"url": ts.URL,
-				}
-				repos = append(repos, newRepo(rs.name, rs.namespace, repoSpec, repoStatus))
-			}
-
-			s, mock, _, err := newServerWithWatcher(false, repos...)
-			if err != nil {
-				t.Fatalf("error instantiating the server: %v", err)
-			}
-
-			response, err := s.GetAvailablePackageSummaries(context.Background(), tc.request)
-			if err != nil {
-				t.Fatalf("%v", err)
-			}
-
-			err = mock.ExpectationsWereMet()
-			if err != nil {
-				t.Fatalf("%v", err)
-			}
-
-			opt1 := cmpopts.IgnoreUnexported(corev1.AvailablePackageSummary{}, corev1.AvailablePackageReference{}, corev1.Context{})
-			opt2 := cmpopts.SortSlices(lessAvailablePackageFunc)
-			if got, want := response.AvailablePackagesSummaries, tc.expectedPackages; !cmp.Equal(got, want, opt1, opt2) {
-				t.Errorf("mismatch (-want +got):\n%s", cmp.Diff(want, got, opt1, opt2))
-			}
-		})
-	}
-}
-
-func TestGetAvailablePackageSummariesAfterRepoIndexUpdate(t *testing.T) {
-	t.Run("test get available package summaries after repo index is updated", func(t *testing.T) {
-		indexYamlBeforeUpdateBytes, err := ioutil.ReadFile("testdata/index-before-update.yaml")
-		if err != nil {
-			t.Fatalf("%+v", err)
-		}
-
-		indexYamlAfterUpdateBytes, err := ioutil.ReadFile("testdata/index-after-update.yaml")
-		if err != nil {
-			t.Fatalf("%+v", err)
-		}
-
-		updateHappened := false
-		// stand up an http server just for the duration of this test
-		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			if updateHappened {
-				fmt.Fprintln(w, string(indexYamlAfterUpdateBytes))
-			} else {
-				fmt.Fprintln(w, string(indexYamlBeforeUpdateBytes))
-			}
-		}))
-		defer ts.Close()
-
-		repoSpec := map[string]interface{}{
-			"url":      "https://example.repo.com/charts",
-			"interval": "1m0s",
-		}
-
-		repoStatus := map[string]interface{}{
-			"conditions": []interface{}{
-				map[string]interface{}{
-					"type":   "Ready",
-					"status": "True",
-					"reason": "IndexationSucceed",
 				},
 			},
-			"url": ts.URL,
-		}
-		repo := newRepo("testrepo", "ns2", repoSpec, repoStatus)
-
-		s, mock, watcher, err := newServerWithWatcher(false, repo)
-		if err != nil {
-			t.Fatalf("error instantiating the server: %v", err)
-		}
-
+		},
+		{
+			testName: "it filters fluxv2 packages by a text query on the maintainers",
+			testRepos: []testRepoStruct{
+				{
+					name:      "bitnami-1",
+					namespace: "default",
+					url:       "https://example.repo.com/charts",
+					index:     "testdata/valid-index.yaml",
+				},
+			},
+			request: &corev1.GetAvailablePackageSummariesRequest{
+				Context:       &corev1.Context{Namespace: "default"},
+				FilterOptions: &corev1.FilterOptions{Query: "ritazh"},
+			},
+			expectedPackages: []*corev1.AvailablePackageSummary{
+				{
+					Name:             "acs-engine-autoscaler",
+					DisplayName:      "acs-engine-autoscaler",
+					LatestPkgVersion: "2.1.1",
+					IconUrl:          "https://github.com/kubernetes/kubernetes/blob/master/logo/logo.png",
+					Repository:       "bitnami-1",
+					AvailablePackageRef: &corev1.AvailablePackageReference{
+						Identifier: "bitnami-1/acs-engine-autoscaler",
+						Context:    &corev1.Context{Namespace: "default"},
+					},
+				},
+			},
+		},
+		{
+			testName: "it filters fluxv2 packages by repository",
+			testRepos: []testRepoStruct{
+				{
+					name:      "bitnami-1",
+					namespace: "default",
+					url:       "https://example.repo.com/charts",
+					index:     "testdata/valid-index.yaml",
+				},
+				{
+					name:      "jetstack-1",
+					namespace: "ns1",
+					url:       "https://charts.jetstack.io",
+					index:     "testdata/jetstack-index.yaml",
+				},
+			},
+			request: &corev1.GetAvailablePackageSummariesRequest{
+				Context:       &corev1.Context{Namespace: "default"},
+				FilterOptions: &corev1.FilterOptions{Repositories: []string{"jetstack-1"}},
+			},
+			expectedPackages: []*corev1.AvailablePackageSummary{
+				{
+					Name:             "cert-manager",
+					DisplayName:      "cert-manager",
+					LatestPkgVersion: "v1.4.0",
+					IconUrl:          "https://raw.githubusercontent.com/jetstack/cert-manager/master/logo/logo.png",
+					Repository:       "jetstack-1",
+					AvailablePackageRef: &corev1.AvailablePackageReference{
+						Identifier: "jetstack-1/cert-manager",
+						Context:    &corev1.Context{Namespace: "ns1"},
+					},
+				},
+			},
+		},
+		{
+			testName: "it filters fluxv2 packages by category",
+			testRepos: []testRepoStruct{
+				{
+					name:      "bitnami-1",
+					namespace: "default",
+					url:       "https://example.repo.com/charts",
+					index:     "testdata/valid-index.yaml",
+				},
+			},
+			request: &corev1.GetAvailablePackageSummariesRequest{
+				Context:       &corev1.Context{Namespace: "default"},
+				FilterOptions: &corev1.FilterOptions{Categories: []string{"Database"}},
+			},
+			expectedPackages: []*corev1.AvailablePackageSummary{},
+		},
+		{
+			testName: "it filters fluxv2 packages by package and app version",
+			testRepos: []testRepoStruct{
+				{
+					name:      "bitnami-1",
+					namespace: "default",
+					url:       "https://example.repo.com/charts",
+					index:     "testdata/valid-index.yaml",
+				},
+			},
+			request: &corev1.GetAvailablePackageSummariesRequest{
+				Context:       &corev1.Context{Namespace: "default"},
+				FilterOptions: &corev1.FilterOptions{PkgVersion: "0.7.4", AppVersion: "4.9.0"},
+			},
+			expectedPackages: []*corev1.AvailablePackageSummary{
+				{
+					Name:             "wordpress",
+					DisplayName:      "wordpress",
+					LatestPkgVersion: "0.7.5",
+					IconUrl:          "https://bitnami.com/assets/stacks/wordpress/img/wordpress-stack-220x234.png",
+					Repository:       "bitnami-1",
+					AvailablePackageRef: &corev1.AvailablePackageReference{
+						Identifier: "bitnami-1/wordpress",
+						Context:    &corev1.Context{Namespace: "default"},
+					},
+				},
+			},
+		},
+		{
+			testName: "it ignores the package version filter without an app version",
+			testRepos: []testRepoStruct{
+				{
+					name:      "bitnami-1",
+					namespace: "default",
+					url:       "https://example.repo.com/charts",
+					index:     "testdata/valid-index.yaml",
+				},
+			},
+			request: &corev1.GetAvailablePackageSummariesRequest{
+				Context:       &corev1.Context{Namespace: "default"},
+				FilterOptions: &corev1.FilterOptions{PkgVersion: "0.7.4"},
+			},
+			expectedPackages: []*corev1.AvailablePackageSummary{
+				{
+					Name:             "acs-engine-autoscaler",
+					DisplayName:      "acs-engine-autoscaler",
+					LatestPkgVersion: "2.1.1",
+					IconUrl:          "https://github.com/kubernetes/kubernetes/blob/master/logo/logo.png",
+					Repository:       "bitnami-1",
+					AvailablePackageRef: &corev1.AvailablePackageReference{
+						Identifier: "bitnami-1/acs-engine-autoscaler",
+						Context:    &corev1.Context{Namespace: "default"},
+					},
+				},
+				{
+					Name:             "wordpress",
+					DisplayName:      "wordpress",
+					LatestPkgVersion: "0.7.5",
+					IconUrl:          "https://bitnami.com/assets/stacks/wordpress/img/wordpress-stack-220x234.png",
+					Repository:       "bitnami-1",
+					AvailablePackageRef: &corev1.AvailablePackageReference{
+						Identifier: "bitnami-1/wordpress",
+						Context:    &corev1.Context{Namespace: "default"},
+					},
+				},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			repos := []runtime.Object{}
+
+			for _, rs := range tc.testRepos {
+				indexYAMLBytes, err := ioutil.ReadFile(rs.index)
+				if err != nil {
+					t.Fatalf("%+v", err)
+				}
+
+				// stand up an http server just for the duration of this test
+				ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					fmt.Fprintln(w, string(indexYAMLBytes))
+				}))
+				defer ts.Close()
+
+				repoSpec := map[string]interface{}{
+					"url":      rs.url,
+					"interval": "1m0s",
+				}
+				repoStatus := map[string]interface{}{
+					"conditions": []interface{}{
+						map[string]interface{}{
+							"type":   "Ready",
+							"status": "True",
+							"reason": "IndexationSucceed",
+						},
+					},
+					"url": ts.URL,
+				}
+				repos = append(repos, newRepo(rs.name, rs.namespace, repoSpec, repoStatus))
+			}
+
+			s, mock, _, err := newServerWithWatcher(false, repos...)
+			if err != nil {
+				t.Fatalf("error instantiating the server: %v", err)
+			}
+
+			response, err := s.GetAvailablePackageSummaries(context.Background(), tc.request)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+
+			err = mock.ExpectationsWereMet()
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+
+			opt1 := cmpopts.IgnoreUnexported(corev1.AvailablePackageSummary{}, corev1.AvailablePackageReference{}, corev1.Context{})
+			opt2 := cmpopts.SortSlices(lessAvailablePackageFunc)
+			if got, want := response.AvailablePackagesSummaries, tc.expectedPackages; !cmp.Equal(got, want, opt1, opt2) {
+				t.Errorf("mismatch (-want +got):\n%s", cmp.Diff(want, got, opt1, opt2))
+			}
+		})
+	}
+}
+
+func TestGetAvailablePackageSummariesForOtherCluster(t *testing.T) {
+	s, mock, _, err := newServerWithWatcher(false)
+	if err != nil {
+		t.Fatalf("error instantiating the server: %v", err)
+	}
+
+	_, err = s.GetAvailablePackageSummaries(context.Background(), &corev1.GetAvailablePackageSummariesRequest{
+		Context: &corev1.Context{Cluster: "other", Namespace: "default"},
+	})
+	if got, want := status.Code(err), codes.Unimplemented; got != want {
+		t.Fatalf("got: %+v, want: %+v, err: %+v", got, want, err)
+	}
+
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("%v", err)
+	}
+}
+
+func TestGetAvailablePackageSummariesAfterRepoIndexUpdate(t *testing.T) {
+	t.Run("test get available package summaries after repo index is updated", func(t *testing.T) {
+		indexYamlBeforeUpdateBytes, err := ioutil.ReadFile("testdata/index-before-update.yaml")
+		if err != nil {
+			t.Fatalf("%+v", err)
+		}
+
+		indexYamlAfterUpdateBytes, err := ioutil.ReadFile("testdata/index-after-update.yaml")
+		if err != nil {
+			t.Fatalf("%+v", err)
+		}
+
+		updateHappened := false
+		// stand up an http server just for the duration of this test
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if updateHappened {
+				fmt.Fprintln(w, string(indexYamlAfterUpdateBytes))
+			} else {
+				fmt.Fprintln(w, string(indexYamlBeforeUpdateBytes))
+			}
+		}))
+		defer ts.Close()
+
+		repoSpec := map[string]interface{}{
+			"url":      "https://example.repo.com/charts",
+			"interval": "1m0s",
+		}
+
+		repoStatus := map[string]interface{}{
+			"conditions": []interface{}{
+				map[string]interface{}{
+					"type":   "Ready",
+					"status": "True",
+					"reason": "IndexationSucceed",
+				},
+			},
+			"url": ts.URL,
+		}
+		repo := newRepo("testrepo", "ns2", repoSpec, repoStatus)
+
+		s, mock, watcher, err := newServerWithWatcher(false, repo)
+		if err != nil {
+			t.Fatalf("error instantiating the server: %v", err)
+		}
+
 		responseBeforeUpdate, err := s.GetAvailablePackageSummaries(
 			context.Background(),
 			&corev1.GetAvailablePackageSummariesRequest{Context: &corev1.Context{}})
@@ -474,18 +1016,22 @@ func TestGetAvailablePackageSummariesAfterRepoIndexUpdate(t *testing.T) {
 
 		expectedPackagesBeforeUpdate := []*corev1.AvailablePackageSummary{
 			{
+				Name:             "alpine",
 				DisplayName:      "alpine",
 				LatestPkgVersion: "0.2.0",
 				IconUrl:          "",
+				Repository:       "testrepo",
 				AvailablePackageRef: &corev1.AvailablePackageReference{
 					Identifier: "testrepo/alpine",
 					Context:    &corev1.Context{Namespace: "ns2"},
 				},
 			},
 			{
+				Name:             "nginx",
 				DisplayName:      "nginx",
 				LatestPkgVersion: "1.1.0",
 				IconUrl:          "",
+				Repository:       "testrepo",
 				AvailablePackageRef: &corev1.AvailablePackageReference{
 					Identifier: "testrepo/nginx",
 					Context:    &corev1.Context{Namespace: "ns2"},
@@ -501,23 +1047,23 @@ func TestGetAvailablePackageSummariesAfterRepoIndexUpdate(t *testing.T) {
 		updateHappened = true
 		// now we are going to simulate flux seeing an update of the index.yaml and modifying the
 		// HelmRepository CRD which, in turn, causes k8s server to fire a MODIFY event
-		s.cache.eventProcessingWaitGroup.Add(1)
+		events, unsubscribe := s.cache.Subscribe()
+		defer unsubscribe()
 
 		key := redisKeyForRuntimeObject(repo)
-		packageSummariesAfterUpdate, err := indexOneRepo(repo.Object)
+		chartsAfterUpdate, err := indexOneRepo(repo.Object)
 		if err != nil {
 			t.Fatalf("%v", err)
 		}
-		protoMsg := corev1.GetAvailablePackageSummariesResponse{
-			AvailablePackagesSummaries: packageSummariesAfterUpdate,
-		}
-		bytes, err := proto.Marshal(&protoMsg)
+		bytes, err := json.Marshal(chartsAfterUpdate)
 		if err != nil {
 			t.Fatalf("%v", err)
 		}
 		mock.ExpectSet(key, bytes, 0).SetVal("")
 		watcher.Modify(repo)
-		s.cache.eventProcessingWaitGroup.Wait()
+		if err = waitForCacheEvents(events, 1); err != nil {
+			t.Fatalf("%v", err)
+		}
 
 		err = mock.ExpectationsWereMet()
 		if err != nil {
@@ -535,18 +1081,22 @@ func TestGetAvailablePackageSummariesAfterRepoIndexUpdate(t *testing.T) {
 
 		expectedPackagesAfterUpdate := []*corev1.AvailablePackageSummary{
 			{
+				Name:             "alpine",
 				DisplayName:      "alpine",
 				LatestPkgVersion: "0.3.0",
 				IconUrl:          "",
+				Repository:       "testrepo",
 				AvailablePackageRef: &corev1.AvailablePackageReference{
 					Identifier: "testrepo/alpine",
 					Context:    &corev1.Context{Namespace: "ns2"},
 				},
 			},
 			{
+				Name:             "nginx",
 				DisplayName:      "nginx",
 				LatestPkgVersion: "1.1.0",
 				IconUrl:          "",
+				Repository:       "testrepo",
 				AvailablePackageRef: &corev1.AvailablePackageReference{
 					Identifier: "testrepo/nginx",
 					Context:    &corev1.Context{Namespace: "ns2"},
@@ -613,18 +1163,22 @@ func TestGetAvailablePackageSummariesAfterFluxHelmRepoDelete(t *testing.T) {
 
 		expectedPackagesBeforeDelete := []*corev1.AvailablePackageSummary{
 			{
+				Name:             "acs-engine-autoscaler",
 				DisplayName:      "acs-engine-autoscaler",
 				LatestPkgVersion: "2.1.1",
 				IconUrl:          "https://github.com/kubernetes/kubernetes/blob/master/logo/logo.png",
+				Repository:       "bitnami-1",
 				AvailablePackageRef: &corev1.AvailablePackageReference{
 					Identifier: "bitnami-1/acs-engine-autoscaler",
 					Context:    &corev1.Context{Namespace: "default"},
 				},
 			},
 			{
+				Name:             "wordpress",
 				DisplayName:      "wordpress",
 				LatestPkgVersion: "0.7.5",
 				IconUrl:          "https://bitnami.com/assets/stacks/wordpress/img/wordpress-stack-220x234.png",
+				Repository:       "bitnami-1",
 				AvailablePackageRef: &corev1.AvailablePackageReference{
 					Identifier: "bitnami-1/wordpress",
 					Context:    &corev1.Context{Namespace: "default"},
@@ -640,11 +1194,14 @@ func TestGetAvailablePackageSummariesAfterFluxHelmRepoDelete(t *testing.T) {
 
 		// now we are going to simulate flux seeing an update of the index.yaml and modifying the
 		// HelmRepository CRD which, in turn, causes k8s server to fire a MODIFY event
-		s.cache.eventProcessingWaitGroup.Add(1)
+		events, unsubscribe := s.cache.Subscribe()
+		defer unsubscribe()
 		key := redisKeyForRuntimeObject(repo)
 		mock.ExpectDel(key).SetVal(0)
 		watcher.Delete(repo)
-		s.cache.eventProcessingWaitGroup.Wait()
+		if err = waitForCacheEvents(events, 1); err != nil {
+			t.Fatalf("%v", err)
+		}
 
 		err = mock.ExpectationsWereMet()
 		if err != nil {
@@ -671,54 +1228,294 @@ func TestGetAvailablePackageSummariesAfterFluxHelmRepoDelete(t *testing.T) {
 	})
 }
 
-func TestGetPackageRepositories(t *testing.T) {
-	testCases := []struct {
-		name                        string
-		request                     *v1alpha1.GetPackageRepositoriesRequest
-		repoNamespace               string
-		repoSpecs                   map[string]map[string]interface{}
-		expectedPackageRepositories []*v1alpha1.PackageRepository
-		statusCode                  codes.Code
-	}{
-		{
-			name:          "returns an internal error status if item in response cannot be converted to v1alpha1.PackageRepository",
-			request:       &v1alpha1.GetPackageRepositoriesRequest{Context: &corev1.Context{}},
-			repoNamespace: "default",
-			repoSpecs: map[string]map[string]interface{}{
-				"repo-1": {
-					"foo": "bar",
-				},
-			},
-			statusCode: codes.Internal,
-		},
-		{
-			name:          "returns expected repositories",
-			request:       &v1alpha1.GetPackageRepositoriesRequest{Context: &corev1.Context{}},
-			repoNamespace: "default",
-			repoSpecs: map[string]map[string]interface{}{
-				"repo-1": {
-					"url": "https://charts.bitnami.com/bitnami",
-				},
-				"repo-2": {
-					"url": "https://charts.helm.sh/stable",
+// watchStream is a stream for WatchAvailablePackages forwarding the events sent to a channel
+type watchStream struct {
+	grpc.ServerStream
+	ctx       context.Context
+	responses chan *corev1.WatchAvailablePackagesResponse
+}
+
+func (s *watchStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *watchStream) Send(response *corev1.WatchAvailablePackagesResponse) error {
+	s.responses <- response
+	return nil
+}
+
+// receive returns the next n events sent on the stream
+func (s *watchStream) receive(t *testing.T, n int) []*corev1.WatchAvailablePackagesResponse {
+	responses := []*corev1.WatchAvailablePackagesResponse{}
+	for i := 0; i < n; i++ {
+		select {
+		case response := <-s.responses:
+			responses = append(responses, response)
+		case <-time.After(10 * time.Second):
+			t.Fatalf("timed out waiting for event %d of %d", i+1, n)
+		}
+	}
+	return responses
+}
+
+func TestWatchAvailablePackages(t *testing.T) {
+	t.Run("test watch available packages as the flux helm repository gets updated and deleted", func(t *testing.T) {
+		indexYamlBeforeUpdateBytes, err := ioutil.ReadFile("testdata/index-before-update.yaml")
+		if err != nil {
+			t.Fatalf("%+v", err)
+		}
+
+		indexYamlAfterUpdateBytes, err := ioutil.ReadFile("testdata/index-after-update.yaml")
+		if err != nil {
+			t.Fatalf("%+v", err)
+		}
+
+		updateHappened := false
+		// stand up an http server just for the duration of this test
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if updateHappened {
+				fmt.Fprintln(w, string(indexYamlAfterUpdateBytes))
+			} else {
+				fmt.Fprintln(w, string(indexYamlBeforeUpdateBytes))
+			}
+		}))
+		defer ts.Close()
+
+		repoSpec := map[string]interface{}{
+			"url":      "https://example.repo.com/charts",
+			"interval": "1m0s",
+		}
+
+		repoStatus := map[string]interface{}{
+			"conditions": []interface{}{
+				map[string]interface{}{
+					"type":   "Ready",
+					"status": "True",
+					"reason": "IndexationSucceed",
+				},
+			},
+			"url": ts.URL,
+		}
+		repo := newRepo("testrepo", "ns2", repoSpec, repoStatus)
+
+		s, mock, watcher, err := newServerWithWatcher(false, repo)
+		if err != nil {
+			t.Fatalf("error instantiating the server: %v", err)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		stream := &watchStream{ctx: ctx, responses: make(chan *corev1.WatchAvailablePackagesResponse, 10)}
+		watchErr := make(chan error, 1)
+		go func() {
+			watchErr <- s.WatchAvailablePackages(&corev1.WatchAvailablePackagesRequest{}, stream)
+		}()
+
+		packageSummary := func(name, version string) *corev1.AvailablePackageSummary {
+			return &corev1.AvailablePackageSummary{
+				Name:             name,
+				DisplayName:      name,
+				LatestPkgVersion: version,
+				Repository:       "testrepo",
+				AvailablePackageRef: &corev1.AvailablePackageReference{
+					Identifier: "testrepo/" + name,
+					Context:    &corev1.Context{Namespace: "ns2"},
+				},
+			}
+		}
+		opts := cmpopts.IgnoreUnexported(corev1.WatchAvailablePackagesResponse{}, corev1.AvailablePackageSummary{}, corev1.AvailablePackageReference{}, corev1.Context{})
+
+		expectedInitialEvents := []*corev1.WatchAvailablePackagesResponse{
+			{
+				Type:                    corev1.WatchAvailablePackagesResponse_EVENT_TYPE_ADDED,
+				AvailablePackageSummary: packageSummary("alpine", "0.2.0"),
+			},
+			{
+				Type:                    corev1.WatchAvailablePackagesResponse_EVENT_TYPE_ADDED,
+				AvailablePackageSummary: packageSummary("nginx", "1.1.0"),
+			},
+		}
+		if got, want := stream.receive(t, 2), expectedInitialEvents; !cmp.Equal(got, want, opts) {
+			t.Errorf("mismatch (-want +got):\n%s", cmp.Diff(want, got, opts))
+		}
+
+		// now we are going to simulate flux seeing an update of the index.yaml and modifying the
+		// HelmRepository CRD which, in turn, causes k8s server to fire a MODIFY event
+		updateHappened = true
+		key := redisKeyForRuntimeObject(repo)
+		chartsAfterUpdate, err := indexOneRepo(repo.Object)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		bytes, err := json.Marshal(chartsAfterUpdate)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		mock.ExpectSet(key, bytes, 0).SetVal("")
+		events, unsubscribe := s.cache.Subscribe()
+		defer unsubscribe()
+		watcher.Modify(repo)
+		if err = waitForCacheEvents(events, 1); err != nil {
+			t.Fatalf("%v", err)
+		}
+
+		expectedUpdateEvents := []*corev1.WatchAvailablePackagesResponse{
+			{
+				Type:                    corev1.WatchAvailablePackagesResponse_EVENT_TYPE_MODIFIED,
+				AvailablePackageSummary: packageSummary("alpine", "0.3.0"),
+			},
+		}
+		if got, want := stream.receive(t, 1), expectedUpdateEvents; !cmp.Equal(got, want, opts) {
+			t.Errorf("mismatch (-want +got):\n%s", cmp.Diff(want, got, opts))
+		}
+
+		mock.ExpectDel(key).SetVal(0)
+		watcher.Delete(repo)
+		if err = waitForCacheEvents(events, 1); err != nil {
+			t.Fatalf("%v", err)
+		}
+
+		expectedDeleteEvents := []*corev1.WatchAvailablePackagesResponse{
+			{
+				Type:                    corev1.WatchAvailablePackagesResponse_EVENT_TYPE_DELETED,
+				AvailablePackageSummary: packageSummary("alpine", "0.3.0"),
+			},
+			{
+				Type:                    corev1.WatchAvailablePackagesResponse_EVENT_TYPE_DELETED,
+				AvailablePackageSummary: packageSummary("nginx", "1.1.0"),
+			},
+		}
+		if got, want := stream.receive(t, 2), expectedDeleteEvents; !cmp.Equal(got, want, opts) {
+			t.Errorf("mismatch (-want +got):\n%s", cmp.Diff(want, got, opts))
+		}
+
+		cancel()
+		if err := <-watchErr; err != nil {
+			t.Fatalf("%+v", err)
+		}
+		if err = mock.ExpectationsWereMet(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	})
+}
+
+func TestGetAvailablePackageSummariesWithMemoryStore(t *testing.T) {
+	indexYAMLBytes, err := ioutil.ReadFile("testdata/index-before-update.yaml")
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, string(indexYAMLBytes))
+	}))
+	defer ts.Close()
+
+	repo := newRepo("testrepo", "ns2",
+		map[string]interface{}{
+			"url":      "https://example.repo.com/charts",
+			"interval": "1m0s",
+		},
+		map[string]interface{}{
+			"conditions": []interface{}{
+				map[string]interface{}{
+					"type":   "Ready",
+					"status": "True",
+					"reason": "IndexationSucceed",
+				},
+			},
+			"url": ts.URL,
+		})
+	dynamicClient := newFakeRepositoriesClient(repo)
+	clientGetter := func(context.Context, string) (kubernetes.Interface, dynamic.Interface, error) {
+		return nil, dynamicClient, nil
+	}
+
+	// no redis is needed when caching in memory
+	store, err := newCacheStore(&pluginConfig{Cache: cacheConfig{Storage: cacheStorageMemory, MaxEntries: 10}})
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	c, err := cache.NewCache(repositoriesCacheConfig(clientGetter), store)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	s := &Server{clientGetter: clientGetter, kubeappsCluster: "default", cache: c}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := s.cache.WaitForCacheSync(ctx); err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	response, err := s.GetAvailablePackageSummaries(
+		context.Background(),
+		&corev1.GetAvailablePackageSummariesRequest{Context: &corev1.Context{}})
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	names := []string{}
+	for _, summary := range response.AvailablePackagesSummaries {
+		names = append(names, summary.AvailablePackageRef.Identifier)
+	}
+	opts := cmpopts.SortSlices(func(a, b string) bool { return a < b })
+	if got, want := names, []string{"testrepo/alpine", "testrepo/nginx"}; !cmp.Equal(want, got, opts) {
+		t.Errorf("mismatch (-want +got):\n%s", cmp.Diff(want, got, opts))
+	}
+}
+
+func TestGetPackageRepositorySummaries(t *testing.T) {
+	pending := &corev1.PackageRepositoryStatus{
+		Reason: corev1.PackageRepositoryStatus_STATUS_REASON_PENDING,
+	}
+	testCases := []struct {
+		name                        string
+		request                     *corev1.GetPackageRepositorySummariesRequest
+		repoNamespace               string
+		repoSpecs                   map[string]map[string]interface{}
+		expectedRepositorySummaries []*corev1.PackageRepositorySummary
+		statusCode                  codes.Code
+	}{
+		{
+			name:          "returns an internal error status if item in response cannot be converted to a package repository summary",
+			request:       &corev1.GetPackageRepositorySummariesRequest{Context: &corev1.Context{}},
+			repoNamespace: "default",
+			repoSpecs: map[string]map[string]interface{}{
+				"repo-1": {
+					"foo": "bar",
+				},
+			},
+			statusCode: codes.Internal,
+		},
+		{
+			name:          "returns expected repositories",
+			request:       &corev1.GetPackageRepositorySummariesRequest{Context: &corev1.Context{}},
+			repoNamespace: "default",
+			repoSpecs: map[string]map[string]interface{}{
+				"repo-1": {
+					"url": "https://charts.bitnami.com/bitnami",
+				},
+				"repo-2": {
+					"url": "https://charts.helm.sh/stable",
 				},
 			},
-			expectedPackageRepositories: []*v1alpha1.PackageRepository{
+			expectedRepositorySummaries: []*corev1.PackageRepositorySummary{
 				{
-					Name:      "repo-1",
-					Namespace: "default",
-					Url:       "https://charts.bitnami.com/bitnami",
+					PackageRepoRef: repoRef("repo-1", "default"),
+					Name:           "repo-1",
+					Type:           "helm",
+					Url:            "https://charts.bitnami.com/bitnami",
+					Status:         pending,
 				},
 				{
-					Name:      "repo-2",
-					Namespace: "default",
-					Url:       "https://charts.helm.sh/stable",
+					PackageRepoRef: repoRef("repo-2", "default"),
+					Name:           "repo-2",
+					Type:           "helm",
+					Url:            "https://charts.helm.sh/stable",
+					Status:         pending,
 				},
 			},
 		},
 		{
-			name: "returns expected repositories in specific namespace",
-			request: &v1alpha1.GetPackageRepositoriesRequest{
+			name: "returns no repositories from another namespace",
+			request: &corev1.GetPackageRepositorySummariesRequest{
 				Context: &corev1.Context{
 					Namespace: "default",
 				},
@@ -728,16 +1525,14 @@ func TestGetPackageRepositories(t *testing.T) {
 				"repo-1": {
 					"url": "https://charts.bitnami.com/bitnami",
 				},
-				"repo-2": {
-					"url": "https://charts.helm.sh/stable",
-				},
 			},
-			expectedPackageRepositories: []*v1alpha1.PackageRepository{},
+			expectedRepositorySummaries: []*corev1.PackageRepositorySummary{},
 		},
 		{
-			name: "returns expected repositories in specific namespace",
-			request: &v1alpha1.GetPackageRepositoriesRequest{
+			name: "returns the repositories of the requested cluster",
+			request: &corev1.GetPackageRepositorySummariesRequest{
 				Context: &corev1.Context{
+					Cluster:   "other",
 					Namespace: "default",
 				},
 			},
@@ -746,20 +1541,18 @@ func TestGetPackageRepositories(t *testing.T) {
 				"repo-1": {
 					"url": "https://charts.bitnami.com/bitnami",
 				},
-				"repo-2": {
-					"url": "https://charts.helm.sh/stable",
-				},
 			},
-			expectedPackageRepositories: []*v1alpha1.PackageRepository{
-				{
-					Name:      "repo-1",
-					Namespace: "default",
-					Url:       "https://charts.bitnami.com/bitnami",
-				},
+			expectedRepositorySummaries: []*corev1.PackageRepositorySummary{
 				{
-					Name:      "repo-2",
-					Namespace: "default",
-					Url:       "https://charts.helm.sh/stable",
+					PackageRepoRef: &corev1.PackageRepositoryReference{
+						Context:    &corev1.Context{Cluster: "other", Namespace: "default"},
+						Identifier: "repo-1",
+						Plugin:     GetPluginDetail(),
+					},
+					Name:   "repo-1",
+					Type:   "helm",
+					Url:    "https://charts.bitnami.com/bitnami",
+					Status: pending,
 				},
 			},
 		},
@@ -772,7 +1565,7 @@ func TestGetPackageRepositories(t *testing.T) {
 				t.Fatalf("error instantiating the server: %v", err)
 			}
 
-			response, err := s.GetPackageRepositories(context.Background(), tc.request)
+			response, err := s.GetPackageRepositorySummaries(context.Background(), tc.request)
 
 			if got, want := status.Code(err), tc.statusCode; got != want {
 				t.Fatalf("got: %+v, want: %+v, err: %+v", got, want, err)
@@ -780,14 +1573,10 @@ func TestGetPackageRepositories(t *testing.T) {
 
 			// Only check the response for OK status.
 			if tc.statusCode == codes.OK {
-				if response == nil {
-					t.Fatalf("got: nil, want: response")
-				} else {
-					opt1 := cmpopts.IgnoreUnexported(v1alpha1.PackageRepository{}, corev1.Context{})
-					opt2 := cmpopts.SortSlices(lessPackageRepositoryFunc)
-					if got, want := response.Repositories, tc.expectedPackageRepositories; !cmp.Equal(got, want, opt1, opt2) {
-						t.Errorf("mismatch (-want +got):\n%s", cmp.Diff(want, got, opt1, opt2))
-					}
+				opt1 := cmpopts.IgnoreUnexported(corev1.PackageRepositorySummary{}, corev1.PackageRepositoryReference{}, corev1.PackageRepositoryStatus{}, corev1.Context{}, plugins.Plugin{})
+				opt2 := cmpopts.SortSlices(lessPackageRepositorySummaryFunc)
+				if got, want := response.PackageRepositorySummaries, tc.expectedRepositorySummaries; !cmp.Equal(got, want, opt1, opt2) {
+					t.Errorf("mismatch (-want +got):\n%s", cmp.Diff(want, got, opt1, opt2))
 				}
 			}
 
@@ -799,185 +1588,1854 @@ func TestGetPackageRepositories(t *testing.T) {
 	}
 }
 
-func TestGetAvailablePackageDetail(t *testing.T) {
+func TestGetPackageRepositoryDetail(t *testing.T) {
 	testCases := []struct {
-		testName              string
-		request               *corev1.GetAvailablePackageDetailRequest
-		repoName              string
-		repoNamespace         string
-		chartName             string
-		chartTarGz            string
-		expectedPackageDetail *corev1.AvailablePackageDetail
+		name               string
+		request            *corev1.GetPackageRepositoryDetailRequest
+		existingRepos      []runtime.Object
+		expectedDetail     *corev1.PackageRepositoryDetail
+		expectedStatusCode codes.Code
 	}{
 		{
-			testName:      "it returns details about the redis package in bitnami repo",
-			repoName:      "bitnami-1",
-			repoNamespace: "default",
-			request: &corev1.GetAvailablePackageDetailRequest{
-				AvailablePackageRef: &corev1.AvailablePackageReference{
-					Identifier: "bitnami-1/redis",
-					Context: &corev1.Context{
-						Namespace: "default",
+			name: "returns the detail of a ready repository",
+			request: &corev1.GetPackageRepositoryDetailRequest{
+				PackageRepoRef: repoRef("bitnami", "default"),
+			},
+			existingRepos: []runtime.Object{
+				newRepo("bitnami", "default", map[string]interface{}{
+					"url":             "https://charts.bitnami.com/bitnami",
+					"interval":        "1m0s",
+					"secretRef":       map[string]interface{}{"name": "bitnami-creds"},
+					"passCredentials": true,
+				}, map[string]interface{}{
+					"conditions": []interface{}{
+						map[string]interface{}{
+							"type":    "Ready",
+							"status":  "True",
+							"reason":  "IndexationSucceed",
+							"message": "Fetched revision: abc",
+						},
 					},
-				}},
-			chartName:  "redis",
-			chartTarGz: "testdata/redis-14.4.0.tgz",
-			expectedPackageDetail: &corev1.AvailablePackageDetail{
-				// TODO (gfichtenholt) other fields
-				AvailablePackageRef: &corev1.AvailablePackageReference{
-					Identifier: "bitnami-1/redis",
-					Context: &corev1.Context{
-						Namespace: "default",
+				}),
+			},
+			expectedDetail: &corev1.PackageRepositoryDetail{
+				PackageRepoRef: repoRef("bitnami", "default"),
+				Name:           "bitnami",
+				Type:           "helm",
+				Url:            "https://charts.bitnami.com/bitnami",
+				Interval:       60,
+				Auth: &corev1.PackageRepositoryAuth{
+					PackageRepoAuthOneOf: &corev1.PackageRepositoryAuth_SecretRef{
+						SecretRef: &corev1.SecretKeyReference{Name: "bitnami-creds"},
+					},
+					PassCredentials: true,
+				},
+				Status: &corev1.PackageRepositoryStatus{
+					Ready:      true,
+					Reason:     corev1.PackageRepositoryStatus_STATUS_REASON_SUCCESS,
+					UserReason: "IndexationSucceed: Fetched revision: abc",
+				},
+			},
+		},
+		{
+			name: "returns not found for a missing repository",
+			request: &corev1.GetPackageRepositoryDetailRequest{
+				PackageRepoRef: repoRef("bitnami", "default"),
+			},
+			expectedStatusCode: codes.NotFound,
+		},
+		{
+			name:               "returns invalid argument without a repository reference",
+			request:            &corev1.GetPackageRepositoryDetailRequest{},
+			expectedStatusCode: codes.InvalidArgument,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s, _, mock, err := newServerWithRepos(tc.existingRepos...)
+			if err != nil {
+				t.Fatalf("error instantiating the server: %v", err)
+			}
+
+			response, err := s.GetPackageRepositoryDetail(context.Background(), tc.request)
+
+			if got, want := status.Code(err), tc.expectedStatusCode; got != want {
+				t.Fatalf("got: %+v, want: %+v, err: %+v", got, want, err)
+			}
+
+			if tc.expectedStatusCode == codes.OK {
+				opt := cmpopts.IgnoreUnexported(corev1.PackageRepositoryDetail{}, corev1.PackageRepositoryReference{}, corev1.PackageRepositoryStatus{}, corev1.PackageRepositoryAuth{}, corev1.SecretKeyReference{}, corev1.Context{}, plugins.Plugin{})
+				if got, want := response.Detail, tc.expectedDetail; !cmp.Equal(got, want, opt) {
+					t.Errorf("mismatch (-want +got):\n%s", cmp.Diff(want, got, opt))
+				}
+			}
+
+			if err = mock.ExpectationsWereMet(); err != nil {
+				t.Fatalf("%v", err)
+			}
+		})
+	}
+}
+
+func TestAddPackageRepository(t *testing.T) {
+	testCases := []struct {
+		name               string
+		request            *corev1.AddPackageRepositoryRequest
+		existingRepos      []runtime.Object
+		expectedRepo       *unstructured.Unstructured
+		expectedStatusCode codes.Code
+	}{
+		{
+			name: "creates a repository with interval and secret",
+			request: &corev1.AddPackageRepositoryRequest{
+				Context:  &corev1.Context{Namespace: "default"},
+				Name:     "bitnami",
+				Type:     "helm",
+				Url:      "https://charts.bitnami.com/bitnami",
+				Interval: 300,
+				Auth: &corev1.PackageRepositoryAuth{
+					PackageRepoAuthOneOf: &corev1.PackageRepositoryAuth_SecretRef{
+						SecretRef: &corev1.SecretKeyReference{Name: "bitnami-creds"},
+					},
+				},
+				TlsConfig: &corev1.PackageRepositoryTlsConfig{
+					PackageRepoTlsConfigOneOf: &corev1.PackageRepositoryTlsConfig_SecretRef{
+						SecretRef: &corev1.SecretKeyReference{Name: "bitnami-creds"},
 					},
 				},
-				Name:            "redis",
-				LongDescription: "Redis<sup>TM</sup> Chart packaged by Bitnami\n\n[Redis<sup>TM</sup>](http://redis.io/) is an advanced key-value cache",
 			},
+			expectedRepo: &unstructured.Unstructured{
+				Object: map[string]interface{}{
+					"apiVersion": "source.toolkit.fluxcd.io/v1beta1",
+					"kind":       "HelmRepository",
+					"metadata": map[string]interface{}{
+						"name":      "bitnami",
+						"namespace": "default",
+					},
+					"spec": map[string]interface{}{
+						"url":       "https://charts.bitnami.com/bitnami",
+						"interval":  "5m0s",
+						"secretRef": map[string]interface{}{"name": "bitnami-creds"},
+					},
+				},
+			},
+		},
+		{
+			name: "creates a repository with the default interval",
+			request: &corev1.AddPackageRepositoryRequest{
+				Context: &corev1.Context{Namespace: "default"},
+				Name:    "bitnami",
+				Url:     "https://charts.bitnami.com/bitnami",
+			},
+			expectedRepo: &unstructured.Unstructured{
+				Object: map[string]interface{}{
+					"apiVersion": "source.toolkit.fluxcd.io/v1beta1",
+					"kind":       "HelmRepository",
+					"metadata": map[string]interface{}{
+						"name":      "bitnami",
+						"namespace": "default",
+					},
+					"spec": map[string]interface{}{
+						"url":      "https://charts.bitnami.com/bitnami",
+						"interval": "10m",
+					},
+				},
+			},
+		},
+		{
+			name: "returns invalid argument for an unsupported repository type",
+			request: &corev1.AddPackageRepositoryRequest{
+				Context: &corev1.Context{Namespace: "default"},
+				Name:    "bitnami",
+				Type:    "oci",
+				Url:     "https://charts.bitnami.com/bitnami",
+			},
+			expectedStatusCode: codes.InvalidArgument,
+		},
+		{
+			name: "returns invalid argument for inline credentials",
+			request: &corev1.AddPackageRepositoryRequest{
+				Context: &corev1.Context{Namespace: "default"},
+				Name:    "bitnami",
+				Url:     "https://charts.bitnami.com/bitnami",
+				Auth: &corev1.PackageRepositoryAuth{
+					PackageRepoAuthOneOf: &corev1.PackageRepositoryAuth_UsernamePassword{
+						UsernamePassword: &corev1.UsernamePassword{Username: "foo", Password: "bar"},
+					},
+				},
+			},
+			expectedStatusCode: codes.InvalidArgument,
+		},
+		{
+			name: "returns invalid argument for different auth and TLS secrets",
+			request: &corev1.AddPackageRepositoryRequest{
+				Context: &corev1.Context{Namespace: "default"},
+				Name:    "bitnami",
+				Url:     "https://charts.bitnami.com/bitnami",
+				Auth: &corev1.PackageRepositoryAuth{
+					PackageRepoAuthOneOf: &corev1.PackageRepositoryAuth_SecretRef{
+						SecretRef: &corev1.SecretKeyReference{Name: "bitnami-creds"},
+					},
+				},
+				TlsConfig: &corev1.PackageRepositoryTlsConfig{
+					PackageRepoTlsConfigOneOf: &corev1.PackageRepositoryTlsConfig_SecretRef{
+						SecretRef: &corev1.SecretKeyReference{Name: "bitnami-ca"},
+					},
+				},
+			},
+			expectedStatusCode: codes.InvalidArgument,
+		},
+		{
+			name: "returns invalid argument without a url",
+			request: &corev1.AddPackageRepositoryRequest{
+				Context: &corev1.Context{Namespace: "default"},
+				Name:    "bitnami",
+			},
+			expectedStatusCode: codes.InvalidArgument,
+		},
+		{
+			name: "returns already exists for an existing repository",
+			request: &corev1.AddPackageRepositoryRequest{
+				Context: &corev1.Context{Namespace: "default"},
+				Name:    "bitnami",
+				Url:     "https://charts.bitnami.com/bitnami",
+			},
+			existingRepos: []runtime.Object{
+				newRepo("bitnami", "default", map[string]interface{}{"url": "https://charts.bitnami.com/bitnami"}, nil),
+			},
+			expectedStatusCode: codes.AlreadyExists,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s, dynamicClient, mock, err := newServerWithRepos(tc.existingRepos...)
+			if err != nil {
+				t.Fatalf("error instantiating the server: %v", err)
+			}
+
+			response, err := s.AddPackageRepository(context.Background(), tc.request)
+
+			if got, want := status.Code(err), tc.expectedStatusCode; got != want {
+				t.Fatalf("got: %+v, want: %+v, err: %+v", got, want, err)
+			}
+
+			if tc.expectedStatusCode == codes.OK {
+				opt := cmpopts.IgnoreUnexported(corev1.PackageRepositoryReference{}, corev1.Context{}, plugins.Plugin{})
+				if got, want := response.PackageRepoRef, repoRef(tc.request.Name, tc.request.Context.Namespace); !cmp.Equal(got, want, opt) {
+					t.Errorf("mismatch (-want +got):\n%s", cmp.Diff(want, got, opt))
+				}
+
+				repo, err := dynamicClient.Resource(repositoriesGvr).Namespace(tc.request.Context.Namespace).Get(context.Background(), tc.request.Name, metav1.GetOptions{})
+				if err != nil {
+					t.Fatalf("%+v", err)
+				}
+				if got, want := repo, tc.expectedRepo; !cmp.Equal(got, want) {
+					t.Errorf("mismatch (-want +got):\n%s", cmp.Diff(want, got))
+				}
+			}
+
+			if err = mock.ExpectationsWereMet(); err != nil {
+				t.Fatalf("%v", err)
+			}
+		})
+	}
+}
+
+func TestUpdatePackageRepository(t *testing.T) {
+	existingRepo := func() *unstructured.Unstructured {
+		return newRepo("bitnami", "default", map[string]interface{}{
+			"url":       "https://charts.bitnami.com/bitnami",
+			"interval":  "10m",
+			"secretRef": map[string]interface{}{"name": "bitnami-creds"},
+		}, nil)
+	}
+	testCases := []struct {
+		name               string
+		request            *corev1.UpdatePackageRepositoryRequest
+		existingRepos      []runtime.Object
+		expectedSpec       map[string]interface{}
+		expectedStatusCode codes.Code
+	}{
+		{
+			name: "updates the url and interval and removes the secret",
+			request: &corev1.UpdatePackageRepositoryRequest{
+				PackageRepoRef: repoRef("bitnami", "default"),
+				Url:            "https://charts.example.com/bitnami",
+				Interval:       60,
+			},
+			existingRepos: []runtime.Object{existingRepo()},
+			expectedSpec: map[string]interface{}{
+				"url":      "https://charts.example.com/bitnami",
+				"interval": "1m0s",
+			},
+		},
+		{
+			name: "keeps the interval when none is requested",
+			request: &corev1.UpdatePackageRepositoryRequest{
+				PackageRepoRef: repoRef("bitnami", "default"),
+				Url:            "https://charts.bitnami.com/bitnami",
+				Auth: &corev1.PackageRepositoryAuth{
+					PackageRepoAuthOneOf: &corev1.PackageRepositoryAuth_SecretRef{
+						SecretRef: &corev1.SecretKeyReference{Name: "other-creds"},
+					},
+					PassCredentials: true,
+				},
+			},
+			existingRepos: []runtime.Object{existingRepo()},
+			expectedSpec: map[string]interface{}{
+				"url":             "https://charts.bitnami.com/bitnami",
+				"interval":        "10m",
+				"secretRef":       map[string]interface{}{"name": "other-creds"},
+				"passCredentials": true,
+			},
+		},
+		{
+			name: "returns not found for a missing repository",
+			request: &corev1.UpdatePackageRepositoryRequest{
+				PackageRepoRef: repoRef("bitnami", "default"),
+				Url:            "https://charts.bitnami.com/bitnami",
+			},
+			expectedStatusCode: codes.NotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s, dynamicClient, mock, err := newServerWithRepos(tc.existingRepos...)
+			if err != nil {
+				t.Fatalf("error instantiating the server: %v", err)
+			}
+
+			response, err := s.UpdatePackageRepository(context.Background(), tc.request)
+
+			if got, want := status.Code(err), tc.expectedStatusCode; got != want {
+				t.Fatalf("got: %+v, want: %+v, err: %+v", got, want, err)
+			}
+
+			if tc.expectedStatusCode == codes.OK {
+				opt := cmpopts.IgnoreUnexported(corev1.PackageRepositoryReference{}, corev1.Context{}, plugins.Plugin{})
+				if got, want := response.PackageRepoRef, tc.request.PackageRepoRef; !cmp.Equal(got, want, opt) {
+					t.Errorf("mismatch (-want +got):\n%s", cmp.Diff(want, got, opt))
+				}
+
+				repo, err := dynamicClient.Resource(repositoriesGvr).Namespace("default").Get(context.Background(), "bitnami", metav1.GetOptions{})
+				if err != nil {
+					t.Fatalf("%+v", err)
+				}
+				if got, want := repo.Object["spec"], tc.expectedSpec; !cmp.Equal(got, want) {
+					t.Errorf("mismatch (-want +got):\n%s", cmp.Diff(want, got))
+				}
+			}
+
+			if err = mock.ExpectationsWereMet(); err != nil {
+				t.Fatalf("%v", err)
+			}
+		})
+	}
+}
+
+func TestDeletePackageRepository(t *testing.T) {
+	testCases := []struct {
+		name               string
+		request            *corev1.DeletePackageRepositoryRequest
+		existingRepos      []runtime.Object
+		expectedStatusCode codes.Code
+	}{
+		{
+			name: "deletes the repository",
+			request: &corev1.DeletePackageRepositoryRequest{
+				PackageRepoRef: repoRef("bitnami", "default"),
+			},
+			existingRepos: []runtime.Object{
+				newRepo("bitnami", "default", map[string]interface{}{"url": "https://charts.bitnami.com/bitnami"}, nil),
+			},
+		},
+		{
+			name: "returns not found for a missing repository",
+			request: &corev1.DeletePackageRepositoryRequest{
+				PackageRepoRef: repoRef("bitnami", "default"),
+			},
+			expectedStatusCode: codes.NotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s, dynamicClient, mock, err := newServerWithRepos(tc.existingRepos...)
+			if err != nil {
+				t.Fatalf("error instantiating the server: %v", err)
+			}
+
+			_, err = s.DeletePackageRepository(context.Background(), tc.request)
+
+			if got, want := status.Code(err), tc.expectedStatusCode; got != want {
+				t.Fatalf("got: %+v, want: %+v, err: %+v", got, want, err)
+			}
+
+			if tc.expectedStatusCode == codes.OK {
+				_, err := dynamicClient.Resource(repositoriesGvr).Namespace("default").Get(context.Background(), "bitnami", metav1.GetOptions{})
+				if !k8serrors.IsNotFound(err) {
+					t.Errorf("got: %+v, want: not found error", err)
+				}
+			}
+
+			if err = mock.ExpectationsWereMet(); err != nil {
+				t.Fatalf("%v", err)
+			}
+		})
+	}
+}
+
+func TestRefreshPackageRepository(t *testing.T) {
+	testCases := []struct {
+		name               string
+		request            *corev1.RefreshPackageRepositoryRequest
+		existingRepos      []runtime.Object
+		expectedStatusCode codes.Code
+	}{
+		{
+			name: "requests the reconciliation of the repository",
+			request: &corev1.RefreshPackageRepositoryRequest{
+				PackageRepoRef: repoRef("bitnami", "default"),
+			},
+			existingRepos: []runtime.Object{
+				newRepo("bitnami", "default", map[string]interface{}{"url": "https://charts.bitnami.com/bitnami"}, nil),
+			},
+		},
+		{
+			name: "returns not found for a missing repository",
+			request: &corev1.RefreshPackageRepositoryRequest{
+				PackageRepoRef: repoRef("bitnami", "default"),
+			},
+			expectedStatusCode: codes.NotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s, dynamicClient, mock, err := newServerWithRepos(tc.existingRepos...)
+			if err != nil {
+				t.Fatalf("error instantiating the server: %v", err)
+			}
+
+			response, err := s.RefreshPackageRepository(context.Background(), tc.request)
+
+			if got, want := status.Code(err), tc.expectedStatusCode; got != want {
+				t.Fatalf("got: %+v, want: %+v, err: %+v", got, want, err)
+			}
+
+			if tc.expectedStatusCode == codes.OK {
+				opt := cmpopts.IgnoreUnexported(corev1.PackageRepositoryReference{}, corev1.Context{}, plugins.Plugin{})
+				if got, want := response.PackageRepoRef, tc.request.PackageRepoRef; !cmp.Equal(got, want, opt) {
+					t.Errorf("mismatch (-want +got):\n%s", cmp.Diff(want, got, opt))
+				}
+
+				repo, err := dynamicClient.Resource(repositoriesGvr).Namespace("default").Get(context.Background(), "bitnami", metav1.GetOptions{})
+				if err != nil {
+					t.Fatalf("%+v", err)
+				}
+				if _, ok := repo.GetAnnotations()[fluxReconcileRequestAnnotation]; !ok {
+					t.Errorf("expected annotation %s on repository, got: %v", fluxReconcileRequestAnnotation, repo.GetAnnotations())
+				}
+			}
+
+			if err = mock.ExpectationsWereMet(); err != nil {
+				t.Fatalf("%v", err)
+			}
+		})
+	}
+}
+
+func TestGetAvailablePackageDetail(t *testing.T) {
+	redisPackageDetail := &corev1.AvailablePackageDetail{
+		AvailablePackageRef: &corev1.AvailablePackageReference{
+			Identifier: "bitnami-1/redis",
+			Context: &corev1.Context{
+				Namespace: "default",
+			},
+			Plugin: GetPluginDetail(),
+		},
+		Name:             "redis",
+		DisplayName:      "redis",
+		PkgVersion:       "14.4.0",
+		AppVersion:       "6.2.4",
+		IconUrl:          "https://bitnami.com/assets/stacks/redis/img/redis-stack-220x234.png",
+		ShortDescription: "Open source, advanced key-value store. It is often referred to as a data structure server since keys can contain strings, hashes, lists, sets and sorted sets.",
+		Readme:           "Redis<sup>TM</sup> Chart packaged by Bitnami\n\n[Redis<sup>TM</sup>](http://redis.io/) is an advanced key-value cache",
+		DefaultValues:    "## @section Global parameters\n",
+		ValuesSchema:     "\"$schema\": \"http://json-schema.org/schema#\"",
+		Maintainers: []*corev1.Maintainer{
+			{Name: "Bitnami", Email: "containers@bitnami.com"},
+			{Name: "desaintmartin", Email: "cedric@desaintmartin.fr"},
+		},
+	}
+
+	testCases := []struct {
+		testName              string
+		request               *corev1.GetAvailablePackageDetailRequest
+		repoName              string
+		repoNamespace         string
+		chartName             string
+		chartVersion          string
+		chartTarGz            string
+		expectedPackageDetail *corev1.AvailablePackageDetail
+	}{
+		{
+			testName:      "it returns details about the redis package in bitnami repo",
+			repoName:      "bitnami-1",
+			repoNamespace: "default",
+			request: &corev1.GetAvailablePackageDetailRequest{
+				AvailablePackageRef: &corev1.AvailablePackageReference{
+					Identifier: "bitnami-1/redis",
+					Context: &corev1.Context{
+						Namespace: "default",
+					},
+				}},
+			chartName:             "redis",
+			chartTarGz:            "testdata/redis-14.4.0.tgz",
+			expectedPackageDetail: redisPackageDetail,
+		},
+		{
+			testName:      "it returns details about a specific version of the redis package",
+			repoName:      "bitnami-1",
+			repoNamespace: "default",
+			request: &corev1.GetAvailablePackageDetailRequest{
+				AvailablePackageRef: &corev1.AvailablePackageReference{
+					Identifier: "bitnami-1/redis",
+					Context: &corev1.Context{
+						Namespace: "default",
+					},
+				},
+				PkgVersion: "14.4.0",
+			},
+			chartName:             "redis",
+			chartVersion:          "14.4.0",
+			chartTarGz:            "testdata/redis-14.4.0.tgz",
+			expectedPackageDetail: redisPackageDetail,
+		},
+		// TODO (gfichtenholt) negative test
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			tarGzBytes, err := ioutil.ReadFile(tc.chartTarGz)
+			if err != nil {
+				t.Fatalf("%+v", err)
+			}
+
+			// stand up an http server just for the duration of this test
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(200)
+				w.Write(tarGzBytes)
+			}))
+			defer ts.Close()
+
+			chartSpec := map[string]interface{}{
+				"chart": tc.chartName,
+				"sourceRef": map[string]interface{}{
+					"name": tc.repoName,
+					"kind": fluxHelmRepository,
+				},
+				"interval": "10m",
+			}
+			if tc.chartVersion != "" {
+				chartSpec["version"] = tc.chartVersion
+			}
+			chartStatus := map[string]interface{}{
+				"conditions": []interface{}{
+					map[string]interface{}{
+						"type":   "Ready",
+						"status": "True",
+						"reason": "ChartPullSucceeded",
+					},
+				},
+				"url": ts.URL,
+			}
+			chart := newChart(tc.chartName, tc.repoNamespace, chartSpec, chartStatus)
+
+			s, _, mock, err := newServerWithCharts(chart)
+			if err != nil {
+				t.Fatalf("%+v", err)
+			}
+
+			response, err := s.GetAvailablePackageDetail(context.Background(), tc.request)
+			if err != nil {
+				t.Fatalf("%+v", err)
+			}
+
+			opt1 := cmpopts.IgnoreUnexported(corev1.AvailablePackageDetail{}, corev1.AvailablePackageReference{}, corev1.Context{}, plugins.Plugin{}, corev1.Maintainer{})
+			// the files of the chart are too long to be compared in full
+			opt2 := cmpopts.IgnoreFields(corev1.AvailablePackageDetail{}, "Readme", "DefaultValues", "ValuesSchema")
+			got, want := response.AvailablePackageDetail, tc.expectedPackageDetail
+			if !cmp.Equal(got, want, opt1, opt2) {
+				t.Errorf("mismatch (-want +got):\n%s", cmp.Diff(want, got, opt1, opt2))
+			}
+			for _, file := range []struct{ got, want string }{
+				{got.Readme, want.Readme},
+				{got.DefaultValues, want.DefaultValues},
+				{got.ValuesSchema, want.ValuesSchema},
+			} {
+				if !strings.Contains(file.got, file.want) {
+					t.Errorf("substring mismatch (-want: %s\n+got: %s):\n", file.want, file.got)
+				}
+			}
+			if tc.request.AvailablePackageRef.Plugin != nil {
+				t.Errorf("got: %v, want: the reference of the request left untouched", tc.request.AvailablePackageRef)
+			}
+
+			err = mock.ExpectationsWereMet()
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+		})
+	}
+
+	negativeTestCases := []struct {
+		testName      string
+		request       *corev1.GetAvailablePackageDetailRequest
+		repoName      string
+		repoNamespace string
+		chartName     string
+		statusCode    codes.Code
+	}{
+		{
+			testName:      "it fails if request is missing namespace",
+			repoName:      "bitnami-1",
+			repoNamespace: "default",
+			request: &corev1.GetAvailablePackageDetailRequest{
+				AvailablePackageRef: &corev1.AvailablePackageReference{
+					Identifier: "redis",
+				}},
+			chartName:  "redis",
+			statusCode: codes.InvalidArgument,
+		},
+		{
+			testName:      "it fails if request has invalid identifier",
+			repoName:      "bitnami-1",
+			repoNamespace: "default",
+			request: &corev1.GetAvailablePackageDetailRequest{
+				AvailablePackageRef: &corev1.AvailablePackageReference{
+					Identifier: "redis",
+					Context: &corev1.Context{
+						Namespace: "default",
+					},
+				}},
+			chartName:  "redis",
+			statusCode: codes.InvalidArgument,
+		},
+	}
+
+	for _, tc := range negativeTestCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			chartSpec := map[string]interface{}{
+				"chart": tc.chartName,
+				"sourceRef": map[string]interface{}{
+					"name": "does-not-matter-for-now",
+					"kind": "HelmRepository",
+				},
+				"interval": "10m",
+			}
+			chartStatus := map[string]interface{}{
+				"conditions": []interface{}{
+					map[string]interface{}{
+						"type":   "Ready",
+						"status": "True",
+						"reason": "ChartPullSucceeded",
+					},
+				},
+				"url": "does-not-matter",
+			}
+			chart := newChart(tc.chartName, tc.repoNamespace, chartSpec, chartStatus)
+			s, _, mock, err := newServerWithCharts(chart)
+			if err != nil {
+				t.Fatalf("%+v", err)
+			}
+
+			_, err = s.GetAvailablePackageDetail(context.Background(), tc.request)
+			if err == nil {
+				t.Fatalf("got nil, want error")
+			}
+			if got, want := status.Code(err), tc.statusCode; got != want {
+				t.Fatalf("got: %+v, want: %+v, err: %+v", got, want, err)
+			}
+
+			err = mock.ExpectationsWereMet()
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+		})
+	}
+}
+
+func TestAvailablePackageDetailFromChartDetailErrors(t *testing.T) {
+	testCases := []struct {
+		name        string
+		chartDetail map[string]string
+	}{
+		{
+			name:        "it fails if the tarball has no Chart.yaml",
+			chartDetail: map[string]string{chart.ReadmeKey: "# redis"},
+		},
+		{
+			name:        "it fails if the Chart.yaml is invalid",
+			chartDetail: map[string]string{chart.ChartYamlKey: "maintainers: not-a-list"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			packageRef := &corev1.AvailablePackageReference{Identifier: "bitnami-1/redis"}
+			_, err := availablePackageDetailFromChartDetail(packageRef, tc.chartDetail)
+			if got, want := status.Code(err), codes.Internal; got != want {
+				t.Errorf("got: %v, want: %v", got, want)
+			}
+		})
+	}
+}
+
+func TestGetAvailablePackageDetailCreatesChartForVersion(t *testing.T) {
+	tarGzBytes, err := ioutil.ReadFile("testdata/redis-14.4.0.tgz")
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(200)
+		w.Write(tarGzBytes)
+	}))
+	defer ts.Close()
+
+	readyStatus := func() map[string]interface{} {
+		return map[string]interface{}{
+			"conditions": []interface{}{
+				map[string]interface{}{
+					"type":   "Ready",
+					"status": "True",
+					"reason": "ChartPullSucceeded",
+				},
+			},
+			"url": ts.URL,
+		}
+	}
+	// a HelmChart of the latest version of the package, which is not used for a specific version
+	latestChart := newChart("redis-latest", "default", map[string]interface{}{
+		"chart": "redis",
+		"sourceRef": map[string]interface{}{
+			"name": "bitnami-1",
+			"kind": fluxHelmRepository,
+		},
+		"interval": "10m",
+	}, readyStatus())
+
+	dynamicClient := newFakeDynamicClient(map[schema.GroupVersionResource]string{chartsGvr: fluxHelmChartList}, latestChart)
+	// the reactor is added before the cache starts watching with the client
+	watcher := watch.NewFake()
+	dynamicClient.PrependWatchReactor(fluxHelmCharts, k8stesting.DefaultWatchReactor(watcher, nil))
+	clientGetter := func(context.Context, string) (kubernetes.Interface, dynamic.Interface, error) {
+		return nil, dynamicClient, nil
+	}
+	s, mock, _, err := newSyncedServer(clientGetter)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	// flux pulls the chart of the new HelmChart
+	go watcher.Modify(newChart("redis-pinned", "default", nil, readyStatus()))
+
+	response, err := s.GetAvailablePackageDetail(context.Background(), &corev1.GetAvailablePackageDetailRequest{
+		AvailablePackageRef: &corev1.AvailablePackageReference{
+			Identifier: "bitnami-1/redis",
+			Context:    &corev1.Context{Namespace: "default"},
+		},
+		PkgVersion: "14.4.0",
+	})
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if got, want := response.AvailablePackageDetail.PkgVersion, "14.4.0"; got != want {
+		t.Errorf("got: %q, want: %q", got, want)
+	}
+
+	charts, err := dynamicClient.Resource(chartsGvr).Namespace("default").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	// whether the HelmChart of each version is a preview chart, to be garbage collected
+	previewByVersion := map[string]bool{}
+	for i := range charts.Items {
+		version, _, _ := unstructured.NestedString(charts.Items[i].Object, "spec", "version")
+		previewByVersion[version] = isPreviewChart(&charts.Items[i])
+	}
+	if got, want := previewByVersion, map[string]bool{"": false, "14.4.0": true}; !cmp.Equal(want, got) {
+		t.Errorf("mismatch (-want +got):\n%s", cmp.Diff(want, got))
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("%v", err)
+	}
+}
+
+func TestGetAvailablePackageDetailReusesPreviewChart(t *testing.T) {
+	tarGzBytes, err := ioutil.ReadFile("testdata/redis-14.4.0.tgz")
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(200)
+		w.Write(tarGzBytes)
+	}))
+	defer ts.Close()
+
+	lastUsed := time.Now().Add(-time.Hour)
+	previewChart, err := newPreviewChart("bitnami-1", "redis", "14.4.0", lastUsed)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	previewChart.SetName("redis-preview")
+	previewChart.SetNamespace("default")
+	previewChart.SetGeneration(1)
+	previewChart.Object["status"] = map[string]interface{}{
+		"conditions": []interface{}{
+			map[string]interface{}{
+				"type":   "Ready",
+				"status": "True",
+				"reason": "ChartPullSucceeded",
+			},
+		},
+		"url":                ts.URL,
+		"observedGeneration": int64(1),
+	}
+
+	s, dynamicClient, mock, err := newServerWithCharts(previewChart)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	_, err = s.GetAvailablePackageDetail(context.Background(), &corev1.GetAvailablePackageDetailRequest{
+		AvailablePackageRef: &corev1.AvailablePackageReference{
+			Identifier: "bitnami-1/redis",
+			Context:    &corev1.Context{Namespace: "default"},
+		},
+		PkgVersion: "14.4.0",
+	})
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	charts, err := dynamicClient.Resource(chartsGvr).Namespace("default").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if got, want := len(charts.Items), 1; got != want {
+		t.Fatalf("got: %d HelmCharts, want: %d", got, want)
+	}
+	if got := previewChartLastUsed(&charts.Items[0]); !got.After(lastUsed) {
+		t.Errorf("got: last used at %v, want: after %v", got, lastUsed)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("%v", err)
+	}
+}
+
+func TestCollectPreviewCharts(t *testing.T) {
+	now := time.Now()
+	idleTTL := time.Hour
+	previewChart := func(name string, lastUsed time.Time) *unstructured.Unstructured {
+		unstructuredChart, err := newPreviewChart("bitnami-1", "redis", "", lastUsed)
+		if err != nil {
+			t.Fatalf("%+v", err)
+		}
+		unstructuredChart.SetName(name)
+		unstructuredChart.SetNamespace("default")
+		return unstructuredChart
+	}
+	withoutAnnotation := previewChart("created-long-ago", now)
+	withoutAnnotation.SetAnnotations(nil)
+	withoutAnnotation.SetCreationTimestamp(metav1.NewTime(now.Add(-2 * idleTTL)))
+	releaseChart := newChart("default-my-redis", "default", map[string]interface{}{
+		"chart": "redis",
+		"sourceRef": map[string]interface{}{
+			"name": "bitnami-1",
+			"kind": fluxHelmRepository,
+		},
+	}, nil)
+	releaseChart.SetCreationTimestamp(metav1.NewTime(now.Add(-2 * idleTTL)))
+
+	dynamicClient := newFakeDynamicClient(
+		map[schema.GroupVersionResource]string{chartsGvr: fluxHelmChartList},
+		previewChart("idle", now.Add(-2*idleTTL)),
+		previewChart("recently-used", now.Add(-idleTTL/2)),
+		withoutAnnotation,
+		releaseChart,
+	)
+
+	if err := collectPreviewCharts(context.Background(), dynamicClient, idleTTL, now); err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	charts, err := dynamicClient.Resource(chartsGvr).Namespace("").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	names := []string{}
+	for _, unstructuredChart := range charts.Items {
+		names = append(names, unstructuredChart.GetName())
+	}
+	opt := cmpopts.SortSlices(func(a, b string) bool { return a < b })
+	if got, want := names, []string{"recently-used", "default-my-redis"}; !cmp.Equal(want, got, opt) {
+		t.Errorf("mismatch (-want +got):\n%s", cmp.Diff(want, got, opt))
+	}
+}
+
+func TestPreviewChartsCollectionInterval(t *testing.T) {
+	testCases := []struct {
+		name             string
+		idleTTL          time.Duration
+		expectedInterval time.Duration
+	}{
+		{
+			name:             "it collects every half of the idle TTL",
+			idleTTL:          time.Hour,
+			expectedInterval: 30 * time.Minute,
+		},
+		{
+			name:             "it collects every half of the minimum idle TTL at most",
+			idleTTL:          time.Nanosecond,
+			expectedInterval: minPreviewChartsIdleTTL / 2,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got, want := previewChartsCollectionInterval(tc.idleTTL), tc.expectedInterval; got != want {
+				t.Errorf("got: %s, want: %s", got, want)
+			}
+		})
+	}
+}
+
+func TestCollectPreviewChartsPeriodically(t *testing.T) {
+	idleTTL := 20 * time.Millisecond
+	idleChart, err := newPreviewChart("bitnami-1", "redis", "", time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	idleChart.SetName("idle")
+	idleChart.SetNamespace("default")
+	dynamicClient := newFakeDynamicClient(
+		map[schema.GroupVersionResource]string{chartsGvr: fluxHelmChartList},
+		idleChart,
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		collectPreviewChartsPeriodically(ctx, func(context.Context) (dynamic.Interface, error) { return dynamicClient, nil }, idleTTL, idleTTL/2)
+		close(stopped)
+	}()
+
+	// the idle HelmChart is deleted by one of the first collections
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		charts, err := dynamicClient.Resource(chartsGvr).Namespace("").List(context.Background(), metav1.ListOptions{})
+		if err != nil {
+			t.Fatalf("%+v", err)
+		}
+		if len(charts.Items) == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("the idle HelmChart was not collected")
+		}
+		time.Sleep(idleTTL / 4)
+	}
+
+	cancel()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("the collection did not stop once the context was cancelled")
+	}
+}
+
+func TestGetAvailablePackageVersions(t *testing.T) {
+	indexYAMLBytes, err := ioutil.ReadFile("testdata/valid-index.yaml")
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, string(indexYAMLBytes))
+	}))
+	defer ts.Close()
+
+	testCases := []struct {
+		name             string
+		request          *corev1.GetAvailablePackageVersionsRequest
+		expectedStatus   codes.Code
+		expectedVersions []*corev1.GetAvailablePackageVersionsResponse_PackageAppVersion
+	}{
+		{
+			name: "it returns the versions of the package from the index of the repository",
+			request: &corev1.GetAvailablePackageVersionsRequest{
+				AvailablePackageRef: &corev1.AvailablePackageReference{
+					Identifier: "bitnami-1/wordpress",
+					Context:    &corev1.Context{Namespace: "default"},
+				},
+			},
+			expectedStatus: codes.OK,
+			expectedVersions: []*corev1.GetAvailablePackageVersionsResponse_PackageAppVersion{
+				{PkgVersion: "0.7.5", AppVersion: "4.9.1"},
+				{PkgVersion: "0.7.4", AppVersion: "4.9.0"},
+			},
+		},
+		{
+			name: "it returns not found for a package not in the repository",
+			request: &corev1.GetAvailablePackageVersionsRequest{
+				AvailablePackageRef: &corev1.AvailablePackageReference{
+					Identifier: "bitnami-1/redis",
+					Context:    &corev1.Context{Namespace: "default"},
+				},
+			},
+			expectedStatus: codes.NotFound,
+		},
+		{
+			name: "it returns not found for a repository not in the namespace",
+			request: &corev1.GetAvailablePackageVersionsRequest{
+				AvailablePackageRef: &corev1.AvailablePackageReference{
+					Identifier: "bitnami-1/wordpress",
+					Context:    &corev1.Context{Namespace: "other"},
+				},
+			},
+			expectedStatus: codes.NotFound,
+		},
+		{
+			name: "it returns invalid argument for an invalid identifier",
+			request: &corev1.GetAvailablePackageVersionsRequest{
+				AvailablePackageRef: &corev1.AvailablePackageReference{
+					Identifier: "wordpress",
+					Context:    &corev1.Context{Namespace: "default"},
+				},
+			},
+			expectedStatus: codes.InvalidArgument,
+		},
+		{
+			name: "it returns invalid argument without a namespace",
+			request: &corev1.GetAvailablePackageVersionsRequest{
+				AvailablePackageRef: &corev1.AvailablePackageReference{
+					Identifier: "bitnami-1/wordpress",
+				},
+			},
+			expectedStatus: codes.InvalidArgument,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := newRepo("bitnami-1", "default",
+				map[string]interface{}{
+					"url":      "https://example.repo.com/charts",
+					"interval": "1m0s",
+				},
+				map[string]interface{}{
+					"conditions": []interface{}{
+						map[string]interface{}{
+							"type":   "Ready",
+							"status": "True",
+							"reason": "IndexationSucceed",
+						},
+					},
+					"url": ts.URL,
+				})
+			s, mock, _, err := newServerWithWatcher(false, repo)
+			if err != nil {
+				t.Fatalf("error instantiating the server: %v", err)
+			}
+
+			response, err := s.GetAvailablePackageVersions(context.Background(), tc.request)
+			if got, want := status.Code(err), tc.expectedStatus; got != want {
+				t.Fatalf("got: %v, want: %v", got, want)
+			}
+			if tc.expectedStatus != codes.OK {
+				return
+			}
+
+			opt := cmpopts.IgnoreUnexported(corev1.GetAvailablePackageVersionsResponse_PackageAppVersion{})
+			if got, want := response.PackageAppVersions, tc.expectedVersions; !cmp.Equal(want, got, opt) {
+				t.Errorf("mismatch (-want +got):\n%s", cmp.Diff(want, got, opt))
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatalf("%v", err)
+			}
+		})
+	}
+}
+
+//
+// utilities
+//
+func TestGetInstalledPackageSummaries(t *testing.T) {
+	testCases := []struct {
+		name               string
+		request            *corev1.GetInstalledPackageSummariesRequest
+		existingReleases   []runtime.Object
+		expectedSummaries  []*corev1.InstalledPackageSummary
+		expectedNextToken  string
+		expectedStatusCode codes.Code
+	}{
+		{
+			name:    "returns the releases in all namespaces",
+			request: &corev1.GetInstalledPackageSummariesRequest{Context: &corev1.Context{}},
+			existingReleases: []runtime.Object{
+				newRelease("my-redis", "default", "bitnami/redis", "14.4.0", readyReleaseStatus("14.4.0")),
+				newRelease("my-apache", "other-ns", "bitnami/apache", "", nil),
+			},
+			expectedSummaries: []*corev1.InstalledPackageSummary{
+				{
+					InstalledPackageRef: &corev1.InstalledPackageReference{
+						Context:    &corev1.Context{Namespace: "default"},
+						Identifier: "my-redis",
+						Plugin:     GetPluginDetail(),
+					},
+					Name:                "my-redis",
+					PkgVersionReference: &corev1.VersionReference{Version: "14.4.0"},
+					CurrentVersion:      &corev1.PackageAppVersion{PkgVersion: "14.4.0"},
+					PkgDisplayName:      "redis",
+					Status: &corev1.InstalledPackageStatus{
+						Ready:      true,
+						Reason:     corev1.InstalledPackageStatus_STATUS_REASON_INSTALLED,
+						UserReason: "ReconciliationSucceeded: Release reconciliation succeeded",
+					},
+				},
+				{
+					InstalledPackageRef: &corev1.InstalledPackageReference{
+						Context:    &corev1.Context{Namespace: "other-ns"},
+						Identifier: "my-apache",
+						Plugin:     GetPluginDetail(),
+					},
+					Name:                "my-apache",
+					PkgVersionReference: &corev1.VersionReference{},
+					PkgDisplayName:      "apache",
+					Status: &corev1.InstalledPackageStatus{
+						Reason: corev1.InstalledPackageStatus_STATUS_REASON_PENDING,
+					},
+				},
+			},
+		},
+		{
+			name:    "returns the releases in the requested namespace only",
+			request: &corev1.GetInstalledPackageSummariesRequest{Context: &corev1.Context{Namespace: "other-ns"}},
+			existingReleases: []runtime.Object{
+				newRelease("my-redis", "default", "bitnami/redis", "14.4.0", readyReleaseStatus("14.4.0")),
+				newRelease("my-apache", "other-ns", "bitnami/apache", "", nil),
+			},
+			expectedSummaries: []*corev1.InstalledPackageSummary{
+				{
+					InstalledPackageRef: &corev1.InstalledPackageReference{
+						Context:    &corev1.Context{Namespace: "other-ns"},
+						Identifier: "my-apache",
+						Plugin:     GetPluginDetail(),
+					},
+					Name:                "my-apache",
+					PkgVersionReference: &corev1.VersionReference{},
+					PkgDisplayName:      "apache",
+					Status: &corev1.InstalledPackageStatus{
+						Reason: corev1.InstalledPackageStatus_STATUS_REASON_PENDING,
+					},
+				},
+			},
+		},
+		{
+			name: "returns the first page of releases with a next page token",
+			request: &corev1.GetInstalledPackageSummariesRequest{
+				Context:           &corev1.Context{},
+				PaginationOptions: &corev1.PaginationOptions{PageSize: 1},
+			},
+			existingReleases: []runtime.Object{
+				newRelease("my-redis", "default", "bitnami/redis", "14.4.0", readyReleaseStatus("14.4.0")),
+				newRelease("my-apache", "other-ns", "bitnami/apache", "", nil),
+			},
+			expectedSummaries: []*corev1.InstalledPackageSummary{
+				{
+					InstalledPackageRef: &corev1.InstalledPackageReference{
+						Context:    &corev1.Context{Namespace: "default"},
+						Identifier: "my-redis",
+						Plugin:     GetPluginDetail(),
+					},
+					Name:                "my-redis",
+					PkgVersionReference: &corev1.VersionReference{Version: "14.4.0"},
+					CurrentVersion:      &corev1.PackageAppVersion{PkgVersion: "14.4.0"},
+					PkgDisplayName:      "redis",
+					Status: &corev1.InstalledPackageStatus{
+						Ready:      true,
+						Reason:     corev1.InstalledPackageStatus_STATUS_REASON_INSTALLED,
+						UserReason: "ReconciliationSucceeded: Release reconciliation succeeded",
+					},
+				},
+			},
+			expectedNextToken: "1",
+		},
+		{
+			name: "returns the page of releases at the offset in the page token",
+			request: &corev1.GetInstalledPackageSummariesRequest{
+				Context:           &corev1.Context{},
+				PaginationOptions: &corev1.PaginationOptions{PageToken: "1", PageSize: 1},
+			},
+			existingReleases: []runtime.Object{
+				newRelease("my-redis", "default", "bitnami/redis", "14.4.0", readyReleaseStatus("14.4.0")),
+				newRelease("my-apache", "other-ns", "bitnami/apache", "", nil),
+			},
+			expectedSummaries: []*corev1.InstalledPackageSummary{
+				{
+					InstalledPackageRef: &corev1.InstalledPackageReference{
+						Context:    &corev1.Context{Namespace: "other-ns"},
+						Identifier: "my-apache",
+						Plugin:     GetPluginDetail(),
+					},
+					Name:                "my-apache",
+					PkgVersionReference: &corev1.VersionReference{},
+					PkgDisplayName:      "apache",
+					Status: &corev1.InstalledPackageStatus{
+						Reason: corev1.InstalledPackageStatus_STATUS_REASON_PENDING,
+					},
+				},
+			},
+		},
+		{
+			name: "returns invalid argument for an invalid page token",
+			request: &corev1.GetInstalledPackageSummariesRequest{
+				Context:           &corev1.Context{},
+				PaginationOptions: &corev1.PaginationOptions{PageToken: "not-a-number", PageSize: 1},
+			},
+			expectedStatusCode: codes.InvalidArgument,
+		},
+		{
+			name:    "returns the releases of the requested cluster",
+			request: &corev1.GetInstalledPackageSummariesRequest{Context: &corev1.Context{Cluster: "other", Namespace: "other-ns"}},
+			existingReleases: []runtime.Object{
+				newRelease("my-apache", "other-ns", "bitnami/apache", "", nil),
+			},
+			expectedSummaries: []*corev1.InstalledPackageSummary{
+				{
+					InstalledPackageRef: &corev1.InstalledPackageReference{
+						Context:    &corev1.Context{Cluster: "other", Namespace: "other-ns"},
+						Identifier: "my-apache",
+						Plugin:     GetPluginDetail(),
+					},
+					Name:                "my-apache",
+					PkgVersionReference: &corev1.VersionReference{},
+					PkgDisplayName:      "apache",
+					Status: &corev1.InstalledPackageStatus{
+						Reason: corev1.InstalledPackageStatus_STATUS_REASON_PENDING,
+					},
+				},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s, _, mock, err := newServerWithReleases(tc.existingReleases...)
+			if err != nil {
+				t.Fatalf("error instantiating the server: %v", err)
+			}
+
+			response, err := s.GetInstalledPackageSummaries(context.Background(), tc.request)
+
+			if got, want := status.Code(err), tc.expectedStatusCode; got != want {
+				t.Fatalf("got: %+v, want: %+v, err: %+v", got, want, err)
+			}
+
+			if tc.expectedStatusCode == codes.OK {
+				opts := []cmp.Option{
+					cmpopts.IgnoreUnexported(corev1.InstalledPackageSummary{}, corev1.InstalledPackageReference{}, corev1.Context{}, corev1.VersionReference{}, corev1.PackageAppVersion{}, corev1.InstalledPackageStatus{}, plugins.Plugin{}),
+					cmpopts.SortSlices(lessInstalledPackageSummaryFunc),
+				}
+				if got, want := response.InstalledPackagesSummaries, tc.expectedSummaries; !cmp.Equal(got, want, opts...) {
+					t.Errorf("mismatch (-want +got):\n%s", cmp.Diff(want, got, opts...))
+				}
+				if got, want := response.NextPageToken, tc.expectedNextToken; got != want {
+					t.Errorf("got: %q, want: %q", got, want)
+				}
+			}
+
+			if err = mock.ExpectationsWereMet(); err != nil {
+				t.Fatalf("%v", err)
+			}
+		})
+	}
+}
+
+func TestGetInstalledPackageDetail(t *testing.T) {
+	releaseWithOptions := newRelease("my-redis", "default", "bitnami/redis", "14.4.0", map[string]interface{}{
+		"lastAppliedRevision": "14.4.0",
+		"conditions": []interface{}{
+			map[string]interface{}{
+				"type":    "Ready",
+				"status":  "False",
+				"reason":  "InstallFailed",
+				"message": "Helm install failed: timed out waiting for the condition",
+			},
+		},
+	})
+	unstructured.SetNestedMap(releaseWithOptions.Object, map[string]interface{}{"replicaCount": int64(2)}, "spec", "values")
+	unstructured.SetNestedField(releaseWithOptions.Object, "10m", "spec", "interval")
+	unstructured.SetNestedField(releaseWithOptions.Object, true, "spec", "suspend")
+	unstructured.SetNestedField(releaseWithOptions.Object, "my-sa", "spec", "serviceAccountName")
+
+	testCases := []struct {
+		name               string
+		request            *corev1.GetInstalledPackageDetailRequest
+		existingReleases   []runtime.Object
+		expectedDetail     *corev1.InstalledPackageDetail
+		expectedStatusCode codes.Code
+	}{
+		{
+			name: "returns the installed package detail",
+			request: &corev1.GetInstalledPackageDetailRequest{
+				InstalledPackageRef: installedRef("my-redis", "default"),
+			},
+			existingReleases: []runtime.Object{releaseWithOptions},
+			expectedDetail: &corev1.InstalledPackageDetail{
+				InstalledPackageRef: installedRef("my-redis", "default"),
+				PkgVersionReference: &corev1.VersionReference{Version: "14.4.0"},
+				Name:                "my-redis",
+				CurrentVersion:      &corev1.PackageAppVersion{PkgVersion: "14.4.0"},
+				ValuesApplied:       "replicaCount: 2\n",
+				ReconciliationOptions: &corev1.ReconciliationOptions{
+					Interval:           600,
+					Suspend:            true,
+					ServiceAccountName: "my-sa",
+				},
+				Status: &corev1.InstalledPackageStatus{
+					Reason:     corev1.InstalledPackageStatus_STATUS_REASON_FAILED,
+					UserReason: "InstallFailed: Helm install failed: timed out waiting for the condition",
+				},
+				AvailablePackageRef: &corev1.AvailablePackageReference{
+					Context:    &corev1.Context{Namespace: "default"},
+					Identifier: "bitnami/redis",
+					Plugin:     GetPluginDetail(),
+				},
+			},
+		},
+		{
+			name: "returns not found for a missing release",
+			request: &corev1.GetInstalledPackageDetailRequest{
+				InstalledPackageRef: installedRef("my-redis", "default"),
+			},
+			expectedStatusCode: codes.NotFound,
+		},
+		{
+			name: "returns invalid argument when the ref has no namespace",
+			request: &corev1.GetInstalledPackageDetailRequest{
+				InstalledPackageRef: installedRef("my-redis", ""),
+			},
+			expectedStatusCode: codes.InvalidArgument,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s, _, mock, err := newServerWithReleases(tc.existingReleases...)
+			if err != nil {
+				t.Fatalf("error instantiating the server: %v", err)
+			}
+
+			response, err := s.GetInstalledPackageDetail(context.Background(), tc.request)
+
+			if got, want := status.Code(err), tc.expectedStatusCode; got != want {
+				t.Fatalf("got: %+v, want: %+v, err: %+v", got, want, err)
+			}
+
+			if tc.expectedStatusCode == codes.OK {
+				opt := cmpopts.IgnoreUnexported(corev1.InstalledPackageDetail{}, corev1.InstalledPackageReference{}, corev1.AvailablePackageReference{}, corev1.Context{}, corev1.VersionReference{}, corev1.PackageAppVersion{}, corev1.InstalledPackageStatus{}, corev1.ReconciliationOptions{}, plugins.Plugin{})
+				if got, want := response.InstalledPackageDetail, tc.expectedDetail; !cmp.Equal(got, want, opt) {
+					t.Errorf("mismatch (-want +got):\n%s", cmp.Diff(want, got, opt))
+				}
+			}
+
+			if err = mock.ExpectationsWereMet(); err != nil {
+				t.Fatalf("%v", err)
+			}
+		})
+	}
+}
+
+func TestCreateInstalledPackage(t *testing.T) {
+	testCases := []struct {
+		name               string
+		request            *corev1.CreateInstalledPackageRequest
+		existingReleases   []runtime.Object
+		expectedRelease    *unstructured.Unstructured
+		expectedStatusCode codes.Code
+	}{
+		{
+			name: "creates a release with version, values and reconciliation options",
+			request: &corev1.CreateInstalledPackageRequest{
+				AvailablePackageRef: &corev1.AvailablePackageReference{
+					Context:    &corev1.Context{Namespace: "flux-system"},
+					Identifier: "bitnami/redis",
+				},
+				TargetContext:       &corev1.Context{Namespace: "default"},
+				Name:                "my-redis",
+				PkgVersionReference: &corev1.VersionReference{Version: "14.4.0"},
+				Values:              "replicaCount: 2\n",
+				ReconciliationOptions: &corev1.ReconciliationOptions{
+					Interval:           300,
+					ServiceAccountName: "my-sa",
+				},
+			},
+			expectedRelease: &unstructured.Unstructured{
+				Object: map[string]interface{}{
+					"apiVersion": "helm.toolkit.fluxcd.io/v2beta1",
+					"kind":       "HelmRelease",
+					"metadata": map[string]interface{}{
+						"name":      "my-redis",
+						"namespace": "default",
+					},
+					"spec": map[string]interface{}{
+						"chart": map[string]interface{}{
+							"spec": map[string]interface{}{
+								"chart":   "redis",
+								"version": "14.4.0",
+								"sourceRef": map[string]interface{}{
+									"kind":      "HelmRepository",
+									"name":      "bitnami",
+									"namespace": "flux-system",
+								},
+							},
+						},
+						"interval":           "5m0s",
+						"suspend":            false,
+						"serviceAccountName": "my-sa",
+						"values": map[string]interface{}{
+							"replicaCount": float64(2),
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "creates a release with the default interval",
+			request: &corev1.CreateInstalledPackageRequest{
+				AvailablePackageRef: &corev1.AvailablePackageReference{
+					Context:    &corev1.Context{Namespace: "default"},
+					Identifier: "bitnami/redis",
+				},
+				TargetContext: &corev1.Context{Namespace: "default"},
+				Name:          "my-redis",
+			},
+			expectedRelease: &unstructured.Unstructured{
+				Object: map[string]interface{}{
+					"apiVersion": "helm.toolkit.fluxcd.io/v2beta1",
+					"kind":       "HelmRelease",
+					"metadata": map[string]interface{}{
+						"name":      "my-redis",
+						"namespace": "default",
+					},
+					"spec": map[string]interface{}{
+						"chart": map[string]interface{}{
+							"spec": map[string]interface{}{
+								"chart": "redis",
+								"sourceRef": map[string]interface{}{
+									"kind":      "HelmRepository",
+									"name":      "bitnami",
+									"namespace": "default",
+								},
+							},
+						},
+						"interval": "1m",
+					},
+				},
+			},
+		},
+		{
+			name: "returns already exists if the release exists",
+			request: &corev1.CreateInstalledPackageRequest{
+				AvailablePackageRef: &corev1.AvailablePackageReference{
+					Context:    &corev1.Context{Namespace: "default"},
+					Identifier: "bitnami/redis",
+				},
+				TargetContext: &corev1.Context{Namespace: "default"},
+				Name:          "my-redis",
+			},
+			existingReleases:   []runtime.Object{newRelease("my-redis", "default", "bitnami/redis", "", nil)},
+			expectedStatusCode: codes.AlreadyExists,
+		},
+		{
+			name: "returns invalid argument for an invalid package identifier",
+			request: &corev1.CreateInstalledPackageRequest{
+				AvailablePackageRef: &corev1.AvailablePackageReference{
+					Context:    &corev1.Context{Namespace: "default"},
+					Identifier: "redis",
+				},
+				TargetContext: &corev1.Context{Namespace: "default"},
+				Name:          "my-redis",
+			},
+			expectedStatusCode: codes.InvalidArgument,
+		},
+		{
+			name: "returns invalid argument for invalid values",
+			request: &corev1.CreateInstalledPackageRequest{
+				AvailablePackageRef: &corev1.AvailablePackageReference{
+					Context:    &corev1.Context{Namespace: "default"},
+					Identifier: "bitnami/redis",
+				},
+				TargetContext: &corev1.Context{Namespace: "default"},
+				Name:          "my-redis",
+				Values:        "not: valid: yaml",
+			},
+			expectedStatusCode: codes.InvalidArgument,
+		},
+		{
+			name: "returns unimplemented for a target cluster other than the kubeapps one",
+			request: &corev1.CreateInstalledPackageRequest{
+				AvailablePackageRef: &corev1.AvailablePackageReference{
+					Context:    &corev1.Context{Namespace: "default"},
+					Identifier: "bitnami/redis",
+				},
+				TargetContext: &corev1.Context{Namespace: "default", Cluster: "other"},
+				Name:          "my-redis",
+			},
+			expectedStatusCode: codes.Unimplemented,
+		},
+		{
+			name: "returns unimplemented for a package from a cluster other than the kubeapps one",
+			request: &corev1.CreateInstalledPackageRequest{
+				AvailablePackageRef: &corev1.AvailablePackageReference{
+					Context:    &corev1.Context{Namespace: "default", Cluster: "other"},
+					Identifier: "bitnami/redis",
+				},
+				TargetContext: &corev1.Context{Namespace: "default"},
+				Name:          "my-redis",
+			},
+			expectedStatusCode: codes.Unimplemented,
+		},
+		{
+			name: "creates a release in the kubeapps cluster when requested explicitly",
+			request: &corev1.CreateInstalledPackageRequest{
+				AvailablePackageRef: &corev1.AvailablePackageReference{
+					Context:    &corev1.Context{Namespace: "default", Cluster: "default"},
+					Identifier: "bitnami/redis",
+				},
+				TargetContext: &corev1.Context{Namespace: "default", Cluster: "default"},
+				Name:          "my-redis",
+			},
+			expectedRelease: &unstructured.Unstructured{
+				Object: map[string]interface{}{
+					"apiVersion": "helm.toolkit.fluxcd.io/v2beta1",
+					"kind":       "HelmRelease",
+					"metadata": map[string]interface{}{
+						"name":      "my-redis",
+						"namespace": "default",
+					},
+					"spec": map[string]interface{}{
+						"chart": map[string]interface{}{
+							"spec": map[string]interface{}{
+								"chart": "redis",
+								"sourceRef": map[string]interface{}{
+									"kind":      "HelmRepository",
+									"name":      "bitnami",
+									"namespace": "default",
+								},
+							},
+						},
+						"interval": "1m",
+					},
+				},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s, dynamicClient, mock, err := newServerWithReleases(tc.existingReleases...)
+			if err != nil {
+				t.Fatalf("error instantiating the server: %v", err)
+			}
+
+			response, err := s.CreateInstalledPackage(context.Background(), tc.request)
+
+			if got, want := status.Code(err), tc.expectedStatusCode; got != want {
+				t.Fatalf("got: %+v, want: %+v, err: %+v", got, want, err)
+			}
+
+			if tc.expectedStatusCode == codes.OK {
+				opt := cmpopts.IgnoreUnexported(corev1.InstalledPackageReference{}, corev1.Context{}, plugins.Plugin{})
+				want := installedRef(tc.request.Name, tc.request.TargetContext.Namespace)
+				want.Context.Cluster = tc.request.TargetContext.Cluster
+				if got := response.InstalledPackageRef; !cmp.Equal(got, want, opt) {
+					t.Errorf("mismatch (-want +got):\n%s", cmp.Diff(want, got, opt))
+				}
+
+				rel, err := dynamicClient.Resource(releasesGvr).Namespace(tc.request.TargetContext.Namespace).Get(context.Background(), tc.request.Name, metav1.GetOptions{})
+				if err != nil {
+					t.Fatalf("%+v", err)
+				}
+				if got, want := rel, tc.expectedRelease; !cmp.Equal(got, want) {
+					t.Errorf("mismatch (-want +got):\n%s", cmp.Diff(want, got))
+				}
+			}
+
+			if err = mock.ExpectationsWereMet(); err != nil {
+				t.Fatalf("%v", err)
+			}
+		})
+	}
+}
+
+func TestUpdateInstalledPackage(t *testing.T) {
+	testCases := []struct {
+		name               string
+		request            *corev1.UpdateInstalledPackageRequest
+		existingReleases   []runtime.Object
+		expectedSpec       map[string]interface{}
+		expectedStatusCode codes.Code
+	}{
+		{
+			name: "updates the version, values and reconciliation options",
+			request: &corev1.UpdateInstalledPackageRequest{
+				InstalledPackageRef: installedRef("my-redis", "default"),
+				PkgVersionReference: &corev1.VersionReference{Version: "14.5.0"},
+				Values:              "replicaCount: 3\n",
+				ReconciliationOptions: &corev1.ReconciliationOptions{
+					Interval: 60,
+					Suspend:  true,
+				},
+			},
+			existingReleases: []runtime.Object{newRelease("my-redis", "default", "bitnami/redis", "14.4.0", nil)},
+			expectedSpec: map[string]interface{}{
+				"chart": map[string]interface{}{
+					"spec": map[string]interface{}{
+						"chart":   "redis",
+						"version": "14.5.0",
+						"sourceRef": map[string]interface{}{
+							"kind": "HelmRepository",
+							"name": "bitnami",
+						},
+					},
+				},
+				"interval": "1m0s",
+				"suspend":  true,
+				"values": map[string]interface{}{
+					"replicaCount": float64(3),
+				},
+			},
+		},
+		{
+			name: "removes the version constraint and values when not provided",
+			request: &corev1.UpdateInstalledPackageRequest{
+				InstalledPackageRef: installedRef("my-redis", "default"),
+			},
+			existingReleases: []runtime.Object{newRelease("my-redis", "default", "bitnami/redis", "14.4.0", nil)},
+			expectedSpec: map[string]interface{}{
+				"chart": map[string]interface{}{
+					"spec": map[string]interface{}{
+						"chart": "redis",
+						"sourceRef": map[string]interface{}{
+							"kind": "HelmRepository",
+							"name": "bitnami",
+						},
+					},
+				},
+				"interval": "1m",
+			},
+		},
+		{
+			name: "returns not found for a missing release",
+			request: &corev1.UpdateInstalledPackageRequest{
+				InstalledPackageRef: installedRef("my-redis", "default"),
+			},
+			expectedStatusCode: codes.NotFound,
+		},
+		{
+			name: "returns unimplemented for a cluster other than the kubeapps one",
+			request: &corev1.UpdateInstalledPackageRequest{
+				InstalledPackageRef: &corev1.InstalledPackageReference{
+					Context:    &corev1.Context{Namespace: "default", Cluster: "other"},
+					Identifier: "my-redis",
+				},
+			},
+			existingReleases:   []runtime.Object{newRelease("my-redis", "default", "bitnami/redis", "14.4.0", nil)},
+			expectedStatusCode: codes.Unimplemented,
 		},
-		// TODO (gfichtenholt) specific version
-		// TODO (gfichtenholt) negative test
 	}
 
 	for _, tc := range testCases {
-		t.Run(tc.testName, func(t *testing.T) {
-			tarGzBytes, err := ioutil.ReadFile(tc.chartTarGz)
+		t.Run(tc.name, func(t *testing.T) {
+			s, dynamicClient, mock, err := newServerWithReleases(tc.existingReleases...)
 			if err != nil {
-				t.Fatalf("%+v", err)
+				t.Fatalf("error instantiating the server: %v", err)
 			}
 
-			// stand up an http server just for the duration of this test
-			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-				w.WriteHeader(200)
-				w.Write(tarGzBytes)
-			}))
-			defer ts.Close()
+			response, err := s.UpdateInstalledPackage(context.Background(), tc.request)
 
-			chartSpec := map[string]interface{}{
-				"chart": tc.chartName,
-				"sourceRef": map[string]interface{}{
-					"name": tc.repoName,
-					"kind": fluxHelmRepository,
-				},
-				"interval": "10m",
+			if got, want := status.Code(err), tc.expectedStatusCode; got != want {
+				t.Fatalf("got: %+v, want: %+v, err: %+v", got, want, err)
 			}
-			chartStatus := map[string]interface{}{
-				"conditions": []interface{}{
-					map[string]interface{}{
-						"type":   "Ready",
-						"status": "True",
-						"reason": "ChartPullSucceeded",
-					},
-				},
-				"url": ts.URL,
+
+			if tc.expectedStatusCode == codes.OK {
+				opt := cmpopts.IgnoreUnexported(corev1.InstalledPackageReference{}, corev1.Context{}, plugins.Plugin{})
+				if got, want := response.InstalledPackageRef, tc.request.InstalledPackageRef; !cmp.Equal(got, want, opt) {
+					t.Errorf("mismatch (-want +got):\n%s", cmp.Diff(want, got, opt))
+				}
+
+				rel, err := dynamicClient.Resource(releasesGvr).Namespace("default").Get(context.Background(), "my-redis", metav1.GetOptions{})
+				if err != nil {
+					t.Fatalf("%+v", err)
+				}
+				if got, want := rel.Object["spec"], tc.expectedSpec; !cmp.Equal(got, want) {
+					t.Errorf("mismatch (-want +got):\n%s", cmp.Diff(want, got))
+				}
 			}
-			chart := newChart(tc.chartName, tc.repoNamespace, chartSpec, chartStatus)
 
-			s, _, mock, err := newServerWithCharts(chart)
-			if err != nil {
-				t.Fatalf("%+v", err)
+			if err = mock.ExpectationsWereMet(); err != nil {
+				t.Fatalf("%v", err)
 			}
+		})
+	}
+}
 
-			response, err := s.GetAvailablePackageDetail(context.Background(), tc.request)
+func TestDeleteInstalledPackage(t *testing.T) {
+	testCases := []struct {
+		name               string
+		request            *corev1.DeleteInstalledPackageRequest
+		existingReleases   []runtime.Object
+		expectedStatusCode codes.Code
+	}{
+		{
+			name: "deletes the release",
+			request: &corev1.DeleteInstalledPackageRequest{
+				InstalledPackageRef: installedRef("my-redis", "default"),
+			},
+			existingReleases: []runtime.Object{newRelease("my-redis", "default", "bitnami/redis", "", nil)},
+		},
+		{
+			name: "returns not found for a missing release",
+			request: &corev1.DeleteInstalledPackageRequest{
+				InstalledPackageRef: installedRef("my-redis", "default"),
+			},
+			expectedStatusCode: codes.NotFound,
+		},
+		{
+			name: "deletes the release in the requested cluster",
+			request: &corev1.DeleteInstalledPackageRequest{
+				InstalledPackageRef: &corev1.InstalledPackageReference{
+					Context:    &corev1.Context{Namespace: "default", Cluster: "other"},
+					Identifier: "my-redis",
+				},
+			},
+			existingReleases: []runtime.Object{newRelease("my-redis", "default", "bitnami/redis", "", nil)},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s, dynamicClient, mock, err := newServerWithReleases(tc.existingReleases...)
 			if err != nil {
-				t.Fatalf("%+v", err)
+				t.Fatalf("error instantiating the server: %v", err)
 			}
 
-			opt1 := cmpopts.IgnoreUnexported(corev1.AvailablePackageDetail{}, corev1.AvailablePackageReference{}, corev1.Context{})
-			opt2 := cmpopts.IgnoreFields(corev1.AvailablePackageDetail{}, "LongDescription")
-			if got, want := response.AvailablePackageDetail, tc.expectedPackageDetail; !cmp.Equal(got, want, opt1, opt2) {
-				t.Errorf("mismatch (-want +got):\n%s", cmp.Diff(want, got, opt1, opt2))
+			_, err = s.DeleteInstalledPackage(context.Background(), tc.request)
+
+			if got, want := status.Code(err), tc.expectedStatusCode; got != want {
+				t.Fatalf("got: %+v, want: %+v, err: %+v", got, want, err)
 			}
-			if !strings.Contains(response.AvailablePackageDetail.LongDescription, tc.expectedPackageDetail.LongDescription) {
-				t.Errorf("substring mismatch (-want: %s\n+got: %s):\n", tc.expectedPackageDetail.LongDescription, response.AvailablePackageDetail.LongDescription)
+
+			if tc.expectedStatusCode == codes.OK {
+				_, err := dynamicClient.Resource(releasesGvr).Namespace("default").Get(context.Background(), "my-redis", metav1.GetOptions{})
+				if !k8serrors.IsNotFound(err) {
+					t.Errorf("got: %+v, want: not found error", err)
+				}
 			}
 
-			err = mock.ExpectationsWereMet()
-			if err != nil {
+			if err = mock.ExpectationsWereMet(); err != nil {
 				t.Fatalf("%v", err)
 			}
 		})
 	}
+}
 
-	negativeTestCases := []struct {
-		testName      string
-		request       *corev1.GetAvailablePackageDetailRequest
-		repoName      string
-		repoNamespace string
-		chartName     string
-		statusCode    codes.Code
+func TestInstalledPackageStatusFromRelease(t *testing.T) {
+	testCases := []struct {
+		name           string
+		generation     int64
+		status         map[string]interface{}
+		expectedStatus *corev1.InstalledPackageStatus
 	}{
 		{
-			testName:      "it fails if request is missing namespace",
-			repoName:      "bitnami-1",
-			repoNamespace: "default",
-			request: &corev1.GetAvailablePackageDetailRequest{
-				AvailablePackageRef: &corev1.AvailablePackageReference{
-					Identifier: "redis",
-				}},
-			chartName:  "redis",
-			statusCode: codes.InvalidArgument,
+			name:       "returns pending when the release has not been reconciled",
+			generation: 1,
+			expectedStatus: &corev1.InstalledPackageStatus{
+				Reason: corev1.InstalledPackageStatus_STATUS_REASON_PENDING,
+			},
 		},
 		{
-			testName:      "it fails if request has invalid identifier",
-			repoName:      "bitnami-1",
-			repoNamespace: "default",
-			request: &corev1.GetAvailablePackageDetailRequest{
-				AvailablePackageRef: &corev1.AvailablePackageReference{
-					Identifier: "redis",
-					Context: &corev1.Context{
-						Namespace: "default",
-					},
-				}},
-			chartName:  "redis",
-			statusCode: codes.InvalidArgument,
+			name:       "returns pending when the observed generation is outdated",
+			generation: 2,
+			status:     readyReleaseStatus("14.4.0"),
+			expectedStatus: &corev1.InstalledPackageStatus{
+				Reason: corev1.InstalledPackageStatus_STATUS_REASON_PENDING,
+			},
 		},
-	}
-
-	for _, tc := range negativeTestCases {
-		t.Run(tc.testName, func(t *testing.T) {
-			chartSpec := map[string]interface{}{
-				"chart": tc.chartName,
-				"sourceRef": map[string]interface{}{
-					"name": "does-not-matter-for-now",
-					"kind": "HelmRepository",
-				},
-				"interval": "10m",
-			}
-			chartStatus := map[string]interface{}{
+		{
+			name:       "returns installed when ready",
+			generation: 1,
+			status:     readyReleaseStatus("14.4.0"),
+			expectedStatus: &corev1.InstalledPackageStatus{
+				Ready:      true,
+				Reason:     corev1.InstalledPackageStatus_STATUS_REASON_INSTALLED,
+				UserReason: "ReconciliationSucceeded: Release reconciliation succeeded",
+			},
+		},
+		{
+			name:       "returns failed when not ready",
+			generation: 1,
+			status: map[string]interface{}{
 				"conditions": []interface{}{
 					map[string]interface{}{
 						"type":   "Ready",
-						"status": "True",
-						"reason": "ChartPullSucceeded",
+						"status": "False",
+						"reason": "ArtifactFailed",
 					},
 				},
-				"url": "does-not-matter",
-			}
-			chart := newChart(tc.chartName, tc.repoNamespace, chartSpec, chartStatus)
-			s, _, mock, err := newServerWithCharts(chart)
-			if err != nil {
-				t.Fatalf("%+v", err)
-			}
+			},
+			expectedStatus: &corev1.InstalledPackageStatus{
+				Reason:     corev1.InstalledPackageStatus_STATUS_REASON_FAILED,
+				UserReason: "ArtifactFailed",
+			},
+		},
+		{
+			name:       "returns pending while progressing",
+			generation: 1,
+			status: map[string]interface{}{
+				"conditions": []interface{}{
+					map[string]interface{}{
+						"type":    "Ready",
+						"status":  "Unknown",
+						"reason":  "Progressing",
+						"message": "Reconciliation in progress",
+					},
+				},
+			},
+			expectedStatus: &corev1.InstalledPackageStatus{
+				Reason:     corev1.InstalledPackageStatus_STATUS_REASON_PENDING,
+				UserReason: "Progressing: Reconciliation in progress",
+			},
+		},
+	}
 
-			_, err = s.GetAvailablePackageDetail(context.Background(), tc.request)
-			if err == nil {
-				t.Fatalf("got nil, want error")
-			}
-			if got, want := status.Code(err), tc.statusCode; got != want {
-				t.Fatalf("got: %+v, want: %+v, err: %+v", got, want, err)
-			}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rel := newRelease("my-redis", "default", "bitnami/redis", "", tc.status)
+			rel.SetGeneration(tc.generation)
 
-			err = mock.ExpectationsWereMet()
-			if err != nil {
-				t.Fatalf("%v", err)
+			opt := cmpopts.IgnoreUnexported(corev1.InstalledPackageStatus{})
+			if got, want := installedPackageStatusFromRelease(rel.Object), tc.expectedStatus; !cmp.Equal(got, want, opt) {
+				t.Errorf("mismatch (-want +got):\n%s", cmp.Diff(want, got, opt))
 			}
 		})
 	}
 }
 
-//
-// utilities
-//
 func newRepo(name string, namespace string, spec map[string]interface{}, status map[string]interface{}) *unstructured.Unstructured {
 	metadata := map[string]interface{}{
 		"name":       name,
@@ -1050,43 +3508,103 @@ func newServer(clientGetter server.KubernetesClientGetter) (*Server, redismock.C
 	if clientGetter != nil {
 		mock.ExpectPing().SetVal("PONG")
 	}
-	repositoriesGvr := schema.GroupVersionResource{
-		Group:    fluxGroup,
-		Version:  fluxVersion,
-		Resource: fluxHelmRepositories,
-	}
-	config := cacheConfig{
-		gvr:          repositoriesGvr,
-		clientGetter: clientGetter,
-		onAdd:        onAddOrModifyRepo,
-		onModify:     onAddOrModifyRepo,
-		onGet:        onGetRepo,
-		onDelete:     onDeleteRepo,
-	}
-	cache, err := newCacheWithRedisClient(config, redisCli)
+	c, err := cache.NewCache(repositoriesCacheConfig(clientGetter), cache.NewRedisStore(redisCli))
 	if err != nil {
 		return nil, mock, err
 	}
 	s := &Server{
-		clientGetter: clientGetter,
-		cache:        cache,
+		clientGetter:    clientGetter,
+		kubeappsCluster: "default",
+		cache:           c,
 	}
 	return s, mock, nil
 }
 
+// waitForCacheEvents waits until the cache has processed the given number of
+// events, as seen by a subscriber.
+func waitForCacheEvents(events <-chan cache.Event, count int) error {
+	for i := 0; i < count; i++ {
+		select {
+		case <-events:
+		case <-time.After(10 * time.Second):
+			return fmt.Errorf("timed out waiting for cache event [%d] of [%d]", i+1, count)
+		}
+	}
+	return nil
+}
+
+// newSyncedServer returns a server with a cache backed by a redis mock, once the cache
+// has listed the repos. The charts of the given indexed repos are expected to be stored
+// in redis by this initial sync, and are returned by key.
+func newSyncedServer(clientGetter server.KubernetesClientGetter, indexedRepos ...runtime.Object) (*Server, redismock.ClientMock, map[string][]byte, error) {
+	redisCli, mock := redismock.NewClientMock()
+	mock.ExpectPing().SetVal("PONG")
+	// the charts of the repos are indexed concurrently
+	mock.MatchExpectationsInOrder(false)
+	mock.ExpectScan(0, fluxHelmRepositories+":*", 100).SetVal([]string{}, 0)
+	mapVals := make(map[string][]byte)
+	for _, r := range indexedRepos {
+		key := redisKeyForRuntimeObject(r)
+		charts, err := indexOneRepo(r.(*unstructured.Unstructured).Object)
+		if err != nil {
+			return nil, mock, nil, err
+		}
+		bytes, err := json.Marshal(charts)
+		if err != nil {
+			return nil, mock, nil, err
+		}
+		mapVals[key] = bytes
+		mock.ExpectSet(key, bytes, 0).SetVal("")
+	}
+
+	c, err := cache.NewCache(repositoriesCacheConfig(clientGetter), cache.NewRedisStore(redisCli))
+	if err != nil {
+		return nil, mock, nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := c.WaitForCacheSync(ctx); err != nil {
+		return nil, mock, nil, err
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		return nil, mock, nil, err
+	}
+	mock.MatchExpectationsInOrder(true)
+
+	s := &Server{
+		clientGetter:    clientGetter,
+		kubeappsCluster: "default",
+		cache:           c,
+	}
+	return s, mock, mapVals, nil
+}
+
+// newFakeRepositoriesClient returns a fake dynamic client with the given objects, which
+// can list the repositories watched by the cache.
+func newFakeRepositoriesClient(objects ...runtime.Object) *fake.FakeDynamicClient {
+	return newFakeDynamicClient(nil, objects...)
+}
+
+// newFakeDynamicClient returns a fake dynamic client with the given objects, which can
+// list the repositories watched by the cache as well as the given resources.
+func newFakeDynamicClient(listKinds map[schema.GroupVersionResource]string, objects ...runtime.Object) *fake.FakeDynamicClient {
+	allListKinds := map[schema.GroupVersionResource]string{
+		repositoriesGvr: fluxHelmRepositoryList,
+	}
+	for gvr, listKind := range listKinds {
+		allListKinds[gvr] = listKind
+	}
+	return fake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), allListKinds, objects...)
+}
+
 func newServerWithRepos(repos ...runtime.Object) (*Server, *fake.FakeDynamicClient, redismock.ClientMock, error) {
-	dynamicClient := fake.NewSimpleDynamicClientWithCustomListKinds(
-		runtime.NewScheme(),
-		map[schema.GroupVersionResource]string{
-			{Group: fluxGroup, Version: fluxVersion, Resource: fluxHelmRepositories}: fluxHelmRepositoryList,
-		},
-		repos...)
+	dynamicClient := newFakeRepositoriesClient(repos...)
 
-	clientGetter := func(context.Context) (kubernetes.Interface, dynamic.Interface, error) {
+	clientGetter := func(context.Context, string) (kubernetes.Interface, dynamic.Interface, error) {
 		return nil, dynamicClient, nil
 	}
 
-	s, mock, err := newServer(clientGetter)
+	s, mock, _, err := newSyncedServer(clientGetter)
 	if err != nil {
 		return nil, nil, nil, err
 	}
@@ -1094,10 +3612,7 @@ func newServerWithRepos(repos ...runtime.Object) (*Server, *fake.FakeDynamicClie
 }
 
 func newServerWithWatcher(expectNil bool, repos ...runtime.Object) (*Server, redismock.ClientMock, *watch.FakeWatcher, error) {
-	s, dynamicClient, mock, err := newServerWithRepos(repos...)
-	if err != nil {
-		return s, mock, nil, err
-	}
+	dynamicClient := newFakeRepositoriesClient(repos...)
 
 	// this is so we can emulate actual k8s server firing events
 	// see https://github.com/kubernetes/kubernetes/issues/54075 for explanation
@@ -1107,50 +3622,22 @@ func newServerWithWatcher(expectNil bool, repos ...runtime.Object) (*Server, red
 		"*",
 		k8stesting.DefaultWatchReactor(watcher, nil))
 
-	mock.MatchExpectationsInOrder(false)
-	// first we need to mock all the SETs and only then all the GETs, otherwise
-	// redismock throws a fit
-	mapVals := make(map[string][]byte)
-	if !expectNil {
-		s.cache.eventProcessingWaitGroup = &sync.WaitGroup{}
-
-		for _, r := range repos {
-			s.cache.eventProcessingWaitGroup.Add(1)
-			key := redisKeyForRuntimeObject(r)
-			packageSummaries, err := indexOneRepo(r.(*unstructured.Unstructured).Object)
-			if err != nil {
-				return s, mock, watcher, err
-			}
-			protoMsg := corev1.GetAvailablePackageSummariesResponse{
-				AvailablePackagesSummaries: packageSummaries,
-			}
-			bytes, err := proto.Marshal(&protoMsg)
-			if err != nil {
-				return s, mock, watcher, err
-			}
-			mapVals[key] = bytes
-			mock.ExpectSet(key, bytes, 0).SetVal("")
-
-			// fire an ADD event for this repo as k8s server would do
-			watcher.Add(r)
-		}
-
-		// sanity check
-		s.cache.watcherMutex.Lock()
-		defer s.cache.watcherMutex.Unlock()
-		if !s.cache.watcherStarted {
-			return s, mock, watcher, fmt.Errorf("unexpected condition: watcher not started")
-		}
-
-		// here we wait until all repos have been indexed on the server-side
-		s.cache.eventProcessingWaitGroup.Wait()
+	clientGetter := func(context.Context, string) (kubernetes.Interface, dynamic.Interface, error) {
+		return nil, dynamicClient, nil
 	}
 
-	err = mock.ExpectationsWereMet()
+	// the repos are indexed by the initial sync of the cache
+	indexedRepos := repos
+	if expectNil {
+		indexedRepos = nil
+	}
+	s, mock, mapVals, err := newSyncedServer(clientGetter, indexedRepos...)
 	if err != nil {
 		return s, mock, watcher, err
 	}
 
+	// the charts of the repos are read concurrently
+	mock.MatchExpectationsInOrder(false)
 	// TODO (gfichtenholt) move this out of this func - strictly speaking,
 	// GET only expected when the caller immediately calls GetAvailablePackageSummaries()
 	// which at the moment, they all do, but may not necessarily so
@@ -1166,18 +3653,17 @@ func newServerWithWatcher(expectNil bool, repos ...runtime.Object) (*Server, red
 }
 
 func newServerWithCharts(charts ...runtime.Object) (*Server, *fake.FakeDynamicClient, redismock.ClientMock, error) {
-	dynamicClient := fake.NewSimpleDynamicClientWithCustomListKinds(
-		runtime.NewScheme(),
+	dynamicClient := newFakeDynamicClient(
 		map[schema.GroupVersionResource]string{
 			{Group: fluxGroup, Version: fluxVersion, Resource: fluxHelmCharts}: fluxHelmChartList,
 		},
 		charts...)
 
-	clientGetter := func(context.Context) (kubernetes.Interface, dynamic.Interface, error) {
+	clientGetter := func(context.Context, string) (kubernetes.Interface, dynamic.Interface, error) {
 		return nil, dynamicClient, nil
 	}
 
-	s, mock, err := newServer(clientGetter)
+	s, mock, _, err := newSyncedServer(clientGetter)
 	if err != nil {
 		return nil, nil, nil, err
 	}
@@ -1201,6 +3687,106 @@ func lessAvailablePackageFunc(p1, p2 *corev1.AvailablePackageSummary) bool {
 	return p1.DisplayName < p2.DisplayName
 }
 
-func lessPackageRepositoryFunc(p1, p2 *v1alpha1.PackageRepository) bool {
-	return p1.Name < p2.Name && p1.Namespace < p2.Namespace
+func lessPackageRepositorySummaryFunc(p1, p2 *corev1.PackageRepositorySummary) bool {
+	return p1.Name < p2.Name
+}
+
+var releasesGvr = schema.GroupVersionResource{
+	Group:    fluxHelmReleaseGroup,
+	Version:  fluxHelmReleaseVersion,
+	Resource: fluxHelmReleases,
+}
+
+// newRelease returns a HelmRelease for the "repo/chart" identifier, observed by flux
+// at the current generation when a status is given.
+func newRelease(name string, namespace string, identifier string, version string, status map[string]interface{}) *unstructured.Unstructured {
+	idParts := strings.Split(identifier, "/")
+	chartSpec := map[string]interface{}{
+		"chart": idParts[1],
+		"sourceRef": map[string]interface{}{
+			"kind": fluxHelmRepository,
+			"name": idParts[0],
+		},
+	}
+	if version != "" {
+		chartSpec["version"] = version
+	}
+	obj := map[string]interface{}{
+		"apiVersion": fmt.Sprintf("%s/%s", fluxHelmReleaseGroup, fluxHelmReleaseVersion),
+		"kind":       fluxHelmRelease,
+		"metadata": map[string]interface{}{
+			"name":       name,
+			"namespace":  namespace,
+			"generation": int64(1),
+		},
+		"spec": map[string]interface{}{
+			"chart": map[string]interface{}{
+				"spec": chartSpec,
+			},
+			"interval": "1m",
+		},
+	}
+	if status != nil {
+		status["observedGeneration"] = int64(1)
+		obj["status"] = status
+	}
+	return &unstructured.Unstructured{
+		Object: obj,
+	}
+}
+
+func readyReleaseStatus(revision string) map[string]interface{} {
+	return map[string]interface{}{
+		"lastAppliedRevision": revision,
+		"conditions": []interface{}{
+			map[string]interface{}{
+				"type":    "Ready",
+				"status":  "True",
+				"reason":  "ReconciliationSucceeded",
+				"message": "Release reconciliation succeeded",
+			},
+		},
+	}
+}
+
+func installedRef(name, namespace string) *corev1.InstalledPackageReference {
+	return &corev1.InstalledPackageReference{
+		Context: &corev1.Context{
+			Namespace: namespace,
+		},
+		Identifier: name,
+		Plugin:     GetPluginDetail(),
+	}
+}
+
+func repoRef(name, namespace string) *corev1.PackageRepositoryReference {
+	return &corev1.PackageRepositoryReference{
+		Context: &corev1.Context{
+			Namespace: namespace,
+		},
+		Identifier: name,
+		Plugin:     GetPluginDetail(),
+	}
+}
+
+func newServerWithReleases(releases ...runtime.Object) (*Server, *fake.FakeDynamicClient, redismock.ClientMock, error) {
+	dynamicClient := newFakeDynamicClient(
+		map[schema.GroupVersionResource]string{
+			releasesGvr: fluxHelmReleaseList,
+		},
+		releases...)
+
+	clientGetter := func(context.Context, string) (kubernetes.Interface, dynamic.Interface, error) {
+		return nil, dynamicClient, nil
+	}
+
+	s, mock, _, err := newSyncedServer(clientGetter)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return s, dynamicClient, mock, nil
+}
+
+func lessInstalledPackageSummaryFunc(p1, p2 *corev1.InstalledPackageSummary) bool {
+	return p1.InstalledPackageRef.Identifier < p2.InstalledPackageRef.Identifier
 }
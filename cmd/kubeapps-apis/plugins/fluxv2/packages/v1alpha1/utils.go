@@ -13,21 +13,28 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"sort"
+	"strconv"
 	"time"
 
 	corev1 "github.com/kubeapps/kubeapps/cmd/kubeapps-apis/gen/core/packages/v1alpha1"
-	"github.com/kubeapps/kubeapps/cmd/kubeapps-apis/gen/plugins/fluxv2/packages/v1alpha1"
+	"github.com/kubeapps/kubeapps/cmd/kubeapps-apis/server"
+	"github.com/kubeapps/kubeapps/cmd/kubeapps-apis/server/cache"
 	chart "github.com/kubeapps/kubeapps/pkg/chart/models"
 	"github.com/kubeapps/kubeapps/pkg/helm"
 	httpclient "github.com/kubeapps/kubeapps/pkg/http-client"
+	"go.opentelemetry.io/otel/attribute"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/proto"
+	helmchart "helm.sh/helm/v3/pkg/chart"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	log "k8s.io/klog/v2"
+	"sigs.k8s.io/yaml"
 )
 
 // calling this file utils.go until I can come up with better name or organize code differently
@@ -47,10 +54,41 @@ func prettyPrintMap(m map[string]interface{}) string {
 	return string(prettyBytes)
 }
 
-func indexOneRepo(unstructuredRepo map[string]interface{}) ([]*corev1.AvailablePackageSummary, error) {
+// pageOffsetFromPageToken converts a page token to an integer offset
+// representing the number of items already returned.
+func pageOffsetFromPageToken(pageToken string) (int, error) {
+	if pageToken == "" {
+		return 0, nil
+	}
+	offset, err := strconv.ParseUint(pageToken, 10, 0)
+	if err != nil {
+		return 0, status.Errorf(codes.InvalidArgument, "Unable to interpret page token %q: %v", pageToken, err)
+	}
+	return int(offset), nil
+}
+
+// pageBounds returns the indexes delimiting the page of the given size which
+// starts at offset in a result set of total items, together with the token for
+// the next page, which is empty if there are no more results. A page size of
+// zero returns all the remaining items.
+func pageBounds(total, offset int, pageSize int32) (start, end int, nextPageToken string) {
+	if offset > total {
+		offset = total
+	}
+	end = total
+	if pageSize > 0 && offset+int(pageSize) < total {
+		end = offset + int(pageSize)
+		nextPageToken = fmt.Sprintf("%d", end)
+	}
+	return offset, end, nextPageToken
+}
+
+// indexOneRepo returns the charts in the index of a ready HelmRepository. The chart models,
+// rather than the package summaries, are cached so that the summaries can be filtered.
+func indexOneRepo(unstructuredRepo map[string]interface{}) ([]chart.Chart, error) {
 	startTime := time.Now()
 
-	repo, err := newPackageRepository(unstructuredRepo)
+	repo, err := packageRepositorySummaryFromRepo("", unstructuredRepo)
 	if err != nil {
 		return nil, err
 	}
@@ -80,90 +118,276 @@ func indexOneRepo(unstructuredRepo map[string]interface{}) ([]*corev1.AvailableP
 	// e.g. http://source-controller.flux-system.svc.cluster.local./helmrepository/default/bitnami/index.yaml
 	// Flux does the hard work of pulling the index file from remote repo
 	// into local cluster based on secretRef associated with HelmRepository, if applicable
+	// the repository is indexed in the background, so this is the root span of its own trace
+	_, span := startSpan(context.Background(), "fetchRepoIndex", attribute.String("http.url", indexUrl))
 	bytes, err := httpclient.Get(indexUrl, httpclient.New(), map[string]string{})
+	endSpan(span, err)
 	if err != nil {
 		return nil, err
 	}
 
 	modelRepo := &chart.Repo{
-		Namespace: repo.Namespace,
+		Namespace: repo.PackageRepoRef.Context.Namespace,
 		Name:      repo.Name,
 		URL:       repo.Url,
 		Type:      "helm",
 	}
 
 	// this is potentially a very expensive operation for large repos like 'bitnami'
-	charts, err := helm.ChartsFromIndex(bytes, modelRepo, true)
+	// all chart versions are kept, rather than just the latest, so that the packages
+	// can be filtered by version
+	charts, err := helm.ChartsFromIndex(bytes, modelRepo, false)
 	if err != nil {
 		return nil, err
 	}
 
-	responsePackages := []*corev1.AvailablePackageSummary{}
-	for _, chart := range charts {
-		pkg := &corev1.AvailablePackageSummary{
-			DisplayName:      chart.Name,
-			LatestPkgVersion: chart.ChartVersions[0].Version,
-			IconUrl:          chart.Icon,
-			AvailablePackageRef: &corev1.AvailablePackageReference{
-				Context:    &corev1.Context{Namespace: repo.Namespace},
-				Identifier: chart.ID,
-			},
-		}
-		responsePackages = append(responsePackages, pkg)
-	}
 	duration := time.Since(startTime)
-	log.Infof("Indexed [%d] packages in repository [%s] in [%d] ms", len(responsePackages), repo.Name, duration.Milliseconds())
+	log.Infof("Indexed [%d] packages in repository [%s] in [%d] ms", len(charts), repo.Name, duration.Milliseconds())
 
-	return responsePackages, nil
+	return charts, nil
 }
 
-func newPackageRepository(unstructuredRepo map[string]interface{}) (*v1alpha1.PackageRepository, error) {
-	name, found, err := unstructured.NestedString(unstructuredRepo, "metadata", "name")
-	if err != nil || !found {
-		return nil, status.Errorf(
-			codes.Internal,
-			"required field metadata.name not found on HelmRepository: %v:\n%s", err, prettyPrintMap(unstructuredRepo))
+func availablePackageSummaryFromChart(chart *chart.Chart) *corev1.AvailablePackageSummary {
+	return &corev1.AvailablePackageSummary{
+		Name:             chart.Name,
+		DisplayName:      chart.Name,
+		LatestPkgVersion: chart.ChartVersions[0].Version,
+		IconUrl:          chart.Icon,
+		Repository:       chart.Repo.Name,
+		AvailablePackageRef: &corev1.AvailablePackageReference{
+			Context:    &corev1.Context{Namespace: chart.Repo.Namespace},
+			Identifier: chart.ID,
+		},
 	}
-	namespace, found, err := unstructured.NestedString(unstructuredRepo, "metadata", "namespace")
-	if err != nil || !found {
-		return nil, status.Errorf(
-			codes.Internal,
-			"field metadata.namespace not found on HelmRepository: %v:\n%s", err, prettyPrintMap(unstructuredRepo))
+}
+
+// availablePackageDetailFromChartDetail builds an AvailablePackageDetail from the files of
+// a chart tarball, as returned by tarutil.FetchChartDetailFromTarball, with the same fields
+// as the helm plugin's AvailablePackageDetailFromChart.
+func availablePackageDetailFromChartDetail(packageRef *corev1.AvailablePackageReference, chartDetail map[string]string) (*corev1.AvailablePackageDetail, error) {
+	chartYaml, ok := chartDetail[chart.ChartYamlKey]
+	if !ok || chartYaml == "" {
+		return nil, status.Errorf(codes.Internal, "Chart.yaml not found in the tarball of package [%s]", packageRef.Identifier)
 	}
-	url, found, err := unstructured.NestedString(unstructuredRepo, "spec", "url")
-	if err != nil || !found {
-		return nil, status.Errorf(
-			codes.Internal,
-			"required field spec.url not found on HelmRepository: %v:\n%s", err, prettyPrintMap(unstructuredRepo))
-	}
-	return &v1alpha1.PackageRepository{
-		Name:      name,
-		Namespace: namespace,
-		Url:       url,
+	var metadata helmchart.Metadata
+	if err := yaml.Unmarshal([]byte(chartYaml), &metadata); err != nil {
+		return nil, status.Errorf(codes.Internal, "unable to parse Chart.yaml of package [%s]: %v", packageRef.Identifier, err)
+	}
+
+	maintainers := []*corev1.Maintainer{}
+	for _, maintainer := range metadata.Maintainers {
+		maintainers = append(maintainers, &corev1.Maintainer{Name: maintainer.Name, Email: maintainer.Email})
+	}
+
+	return &corev1.AvailablePackageDetail{
+		// a copy of the reference of the request, which is left untouched
+		AvailablePackageRef: &corev1.AvailablePackageReference{
+			Context:    packageRef.Context,
+			Identifier: packageRef.Identifier,
+			Plugin:     GetPluginDetail(),
+		},
+		Name:             metadata.Name,
+		DisplayName:      metadata.Name,
+		PkgVersion:       metadata.Version,
+		AppVersion:       metadata.AppVersion,
+		IconUrl:          metadata.Icon,
+		ShortDescription: metadata.Description,
+		Readme:           chartDetail[chart.ReadmeKey],
+		DefaultValues:    chartDetail[chart.ValuesKey],
+		ValuesSchema:     chartDetail[chart.SchemaKey],
+		Maintainers:      maintainers,
 	}, nil
 }
 
+// chartVersionMatches returns whether a HelmChart pulls the given version of its chart, the
+// latest version being pulled when the version of the HelmChart is empty or "*", as with flux.
+func chartVersionMatches(unstructuredChart map[string]interface{}, version string) bool {
+	chartVersion, _, err := unstructured.NestedString(unstructuredChart, "spec", "version")
+	if err != nil {
+		return false
+	}
+	if chartVersion == "*" {
+		chartVersion = ""
+	}
+	return chartVersion == version
+}
+
+// packageAppVersionsSummary converts the chart versions, sorted from the most recent as in the
+// repository index, into the version summary also returned by the helm plugin.
+func packageAppVersionsSummary(versions []chart.ChartVersion) []*corev1.GetAvailablePackageVersionsResponse_PackageAppVersion {
+	pav := []*corev1.GetAvailablePackageVersionsResponse_PackageAppVersion{}
+	for _, v := range versions {
+		pav = append(pav, &corev1.GetAvailablePackageVersionsResponse_PackageAppVersion{
+			PkgVersion: v.Version,
+			AppVersion: v.AppVersion,
+		})
+	}
+	return server.PackageAppVersionsSummary(pav)
+}
+
+// chartMatchesFilterOptions returns whether the chart matches the filter options,
+// with the same semantics as the helm plugin's query of the assets database.
+func chartMatchesFilterOptions(chart *chart.Chart, filterOptions *corev1.FilterOptions) bool {
+	if filterOptions == nil {
+		return true
+	}
+
+	if !server.MatchesFilterValues(filterOptions.GetRepositories(), chart.Repo.Name) {
+		return false
+	}
+
+	if !server.MatchesFilterValues(filterOptions.GetCategories(), chart.Category) {
+		return false
+	}
+
+	if filterOptions.GetPkgVersion() != "" && filterOptions.GetAppVersion() != "" {
+		found := false
+		for _, version := range chart.ChartVersions {
+			if version.Version == filterOptions.GetPkgVersion() && version.AppVersion == filterOptions.GetAppVersion() {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if filterOptions.GetQuery() != "" {
+		fields := []string{chart.Name, chart.Description, chart.Repo.Name}
+		fields = append(fields, chart.Keywords...)
+		fields = append(fields, chart.Sources...)
+		for _, maintainer := range chart.Maintainers {
+			fields = append(fields, maintainer.Name)
+		}
+		if !server.MatchesFilterQuery(filterOptions.GetQuery(), fields...) {
+			return false
+		}
+	}
+	return true
+}
+
+// availablePackagesView is the view of the available packages of each repository
+// which a client watching the available packages was sent, so that the changes to
+// the cached charts of a repository are streamed as package events.
+type availablePackagesView struct {
+	filterOptions *corev1.FilterOptions
+	// the summaries sent for each repository, by cache key then package identifier
+	summaries map[string]map[string]*corev1.AvailablePackageSummary
+}
+
+func newAvailablePackagesView(filterOptions *corev1.FilterOptions) *availablePackagesView {
+	return &availablePackagesView{
+		filterOptions: filterOptions,
+		summaries:     make(map[string]map[string]*corev1.AvailablePackageSummary),
+	}
+}
+
+// update sets the charts cached for the repository with the given key, which may be nil
+// when the repository has no cached charts, and returns the events for the packages which
+// were added, modified or deleted since the last update, ordered by package identifier.
+func (v *availablePackagesView) update(key string, cachedCharts cache.Value) ([]*corev1.WatchAvailablePackagesResponse, error) {
+	summaries := make(map[string]*corev1.AvailablePackageSummary)
+	if cachedCharts != nil {
+		charts, err := repoChartsFromCacheValue(cachedCharts)
+		if err != nil {
+			return nil, err
+		}
+		for i := range charts {
+			if chartMatchesFilterOptions(&charts[i], v.filterOptions) {
+				summary := availablePackageSummaryFromChart(&charts[i])
+				summaries[summary.AvailablePackageRef.Identifier] = summary
+			}
+		}
+	}
+
+	events := []*corev1.WatchAvailablePackagesResponse{}
+	previous := v.summaries[key]
+	for id, summary := range summaries {
+		if previousSummary, ok := previous[id]; !ok {
+			events = append(events, &corev1.WatchAvailablePackagesResponse{
+				Type:                    corev1.WatchAvailablePackagesResponse_EVENT_TYPE_ADDED,
+				AvailablePackageSummary: summary,
+			})
+		} else if !proto.Equal(previousSummary, summary) {
+			events = append(events, &corev1.WatchAvailablePackagesResponse{
+				Type:                    corev1.WatchAvailablePackagesResponse_EVENT_TYPE_MODIFIED,
+				AvailablePackageSummary: summary,
+			})
+		}
+	}
+	for id, previousSummary := range previous {
+		if _, ok := summaries[id]; !ok {
+			events = append(events, &corev1.WatchAvailablePackagesResponse{
+				Type:                    corev1.WatchAvailablePackagesResponse_EVENT_TYPE_DELETED,
+				AvailablePackageSummary: previousSummary,
+			})
+		}
+	}
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].AvailablePackageSummary.AvailablePackageRef.Identifier < events[j].AvailablePackageSummary.AvailablePackageRef.Identifier
+	})
+
+	if len(summaries) == 0 {
+		delete(v.summaries, key)
+	} else {
+		v.summaries[key] = summaries
+	}
+	return events, nil
+}
+
+// repoCharts is the value cached for a flux HelmRepository: the charts of its index
+type repoCharts []chart.Chart
+
+func (c repoCharts) MarshalBinary() ([]byte, error) {
+	return json.Marshal([]chart.Chart(c))
+}
+
+func (c *repoCharts) UnmarshalBinary(data []byte) error {
+	return json.Unmarshal(data, (*[]chart.Chart)(c))
+}
+
+// repoChartsFromCacheValue returns the charts of a value fetched from the cache
+func repoChartsFromCacheValue(value cache.Value) (repoCharts, error) {
+	charts, ok := value.(*repoCharts)
+	if !ok {
+		return nil, status.Errorf(codes.Internal, "Unexpected value fetched from cache: %v", value)
+	}
+	return *charts, nil
+}
+
+// repositoriesCacheConfig returns the config of the cache of the charts of the flux
+// HelmRepositories on the cluster on which Kubeapps is installed
+func repositoriesCacheConfig(clientGetter server.KubernetesClientGetter) cache.Config {
+	return cache.Config{
+		ClientGetter: clientGetter,
+		Resources: []cache.ResourceConfig{
+			{
+				Gvr:      repositoriesGvr,
+				OnAdd:    onAddOrModifyRepo,
+				OnModify: onAddOrModifyRepo,
+				OnDelete: onDeleteRepo,
+				NewValue: func() cache.Value { return &repoCharts{} },
+			},
+		},
+	}
+}
+
 // implements plug-in specific cache-related functionality
 // onAddOrModifyRepo essentially tells the cache what to store for a given key
-func onAddOrModifyRepo(key string, unstructuredRepo map[string]interface{}) (interface{}, bool, error) {
+func onAddOrModifyRepo(key string, unstructuredRepo map[string]interface{}) (cache.Value, bool, error) {
 	ready, err := isRepoReady(unstructuredRepo)
 	if err != nil {
 		return nil, false, err
 	}
 
 	if ready {
-		packages, err := indexOneRepo(unstructuredRepo)
-		if err != nil {
-			return nil, false, err
-		}
-		protoMsg := corev1.GetAvailablePackageSummariesResponse{
-			AvailablePackagesSummaries: packages,
-		}
-		bytes, err := proto.Marshal(&protoMsg)
+		charts, err := indexOneRepo(unstructuredRepo)
 		if err != nil {
 			return nil, false, err
 		}
-		return bytes, true, nil
+		value := repoCharts(charts)
+		return &value, true, nil
 	} else {
 		// repo is not quite ready to be indexed - not really an error condition,
 		// just skip it eventually there will be another event when it is in ready state
@@ -172,20 +396,6 @@ func onAddOrModifyRepo(key string, unstructuredRepo map[string]interface{}) (int
 	}
 }
 
-func onGetRepo(key string, value interface{}) (interface{}, error) {
-	bytes, ok := value.([]byte)
-	if !ok {
-		return nil, status.Errorf(codes.Internal, "unexpected value found in cache for key [%s]: %v", key, value)
-	}
-
-	var protoMsg corev1.GetAvailablePackageSummariesResponse
-	err := proto.Unmarshal(bytes, &protoMsg)
-	if err != nil {
-		return nil, err
-	}
-	return protoMsg.AvailablePackagesSummaries, nil
-}
-
 func onDeleteRepo(key string, unstructuredRepo map[string]interface{}) (bool, error) {
 	return true, nil
 }
@@ -0,0 +1,85 @@
+/*
+Copyright © 2021 VMware
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"os"
+
+	"github.com/kubeapps/common/datastore"
+	"github.com/kubeapps/kubeapps/cmd/kubeapps-apis/server"
+	"github.com/kubeapps/kubeapps/pkg/agent"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// pluginConfig is the configuration of the plugin, read from the
+// "helm.packages" section of the kubeapps-apis config file.
+type pluginConfig struct {
+	// Database is the assetsvc database from which the catalog is read.
+	Database databaseConfig `mapstructure:"database"`
+	// HelmDriver is the storage driver for helm releases, which defaults to
+	// secrets as in Helm 3.
+	HelmDriver string `mapstructure:"helmDriver"`
+}
+
+type databaseConfig struct {
+	URL      string `mapstructure:"url"`
+	Name     string `mapstructure:"name"`
+	Username string `mapstructure:"username"`
+	Password string `mapstructure:"password"`
+}
+
+// newPluginConfig decodes and validates the plugin configuration. Values which
+// are not set in the config file default to the ASSET_SYNCER_DB_* and
+// HELM_DRIVER environment variables, so that the password can still be read
+// from a secret.
+func newPluginConfig(config server.PluginConfig) (*pluginConfig, error) {
+	pluginConfig := &pluginConfig{
+		Database: databaseConfig{
+			URL:      os.Getenv("ASSET_SYNCER_DB_URL"),
+			Name:     os.Getenv("ASSET_SYNCER_DB_NAME"),
+			Username: os.Getenv("ASSET_SYNCER_DB_USERNAME"),
+			Password: os.Getenv("ASSET_SYNCER_DB_USERPASSWORD"),
+		},
+		HelmDriver: os.Getenv("HELM_DRIVER"),
+	}
+
+	if err := config.Decode(pluginConfig); err != nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "invalid plugin configuration: %v", err)
+	}
+
+	if pluginConfig.Database.URL == "" || pluginConfig.Database.Name == "" {
+		return nil, status.Errorf(codes.FailedPrecondition, "missing database url or name, set database.url and database.name in the plugin configuration or the environment variables ASSET_SYNCER_DB_URL and ASSET_SYNCER_DB_NAME")
+	}
+	if _, err := pluginConfig.storageForDriver(); err != nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "invalid helm driver: %v", err)
+	}
+	return pluginConfig, nil
+}
+
+func (c *pluginConfig) datastoreConfig() datastore.Config {
+	return datastore.Config{
+		URL:      c.Database.URL,
+		Database: c.Database.Name,
+		Username: c.Database.Username,
+		Password: c.Database.Password,
+	}
+}
+
+// storageForDriver returns the storage for helm releases of the configured driver.
+func (c *pluginConfig) storageForDriver() (agent.StorageForDriver, error) {
+	if c.HelmDriver == "" {
+		return agent.StorageForSecrets, nil
+	}
+	return agent.ParseDriverType(c.HelmDriver)
+}
@@ -0,0 +1,88 @@
+/*
+Copyright © 2021 VMware
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"time"
+
+	"github.com/kubeapps/kubeapps/cmd/assetsvc/pkg/utils"
+	"github.com/kubeapps/kubeapps/cmd/kubeapps-apis/server"
+	"github.com/kubeapps/kubeapps/pkg/chart/models"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var dbQueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: server.MetricsNamespace,
+	Subsystem: "helm",
+	Name:      "db_query_duration_seconds",
+	Help:      "Duration of the queries to the assets database, by query and result.",
+	Buckets:   prometheus.DefBuckets,
+}, []string{"query", "result"})
+
+// observeQuery records the duration of a query to the assets database.
+func observeQuery(query string, start time.Time, err error) {
+	result := "success"
+	if err != nil {
+		result = "error"
+	}
+	dbQueryDuration.WithLabelValues(query, result).Observe(time.Since(start).Seconds())
+}
+
+// instrumentedAssetManager records the duration of each query of the asset
+// manager.
+type instrumentedAssetManager struct {
+	utils.AssetManager
+}
+
+func (m *instrumentedAssetManager) GetChart(namespace, chartID string) (models.Chart, error) {
+	start := time.Now()
+	chart, err := m.AssetManager.GetChart(namespace, chartID)
+	observeQuery("GetChart", start, err)
+	return chart, err
+}
+
+func (m *instrumentedAssetManager) GetChartVersion(namespace, chartID, version string) (models.Chart, error) {
+	start := time.Now()
+	chart, err := m.AssetManager.GetChartVersion(namespace, chartID, version)
+	observeQuery("GetChartVersion", start, err)
+	return chart, err
+}
+
+func (m *instrumentedAssetManager) GetChartFiles(namespace, filesID string) (models.ChartFiles, error) {
+	start := time.Now()
+	files, err := m.AssetManager.GetChartFiles(namespace, filesID)
+	observeQuery("GetChartFiles", start, err)
+	return files, err
+}
+
+func (m *instrumentedAssetManager) GetPaginatedChartListWithFilters(cq utils.ChartQuery, pageNumber, pageSize int) ([]*models.Chart, int, error) {
+	start := time.Now()
+	charts, numPages, err := m.AssetManager.GetPaginatedChartListWithFilters(cq, pageNumber, pageSize)
+	observeQuery("GetPaginatedChartListWithFilters", start, err)
+	return charts, numPages, err
+}
+
+func (m *instrumentedAssetManager) GetChartListWithFiltersFromOffset(cq utils.ChartQuery, order utils.ChartOrder, offset, limit int) ([]*models.Chart, error) {
+	start := time.Now()
+	charts, err := m.AssetManager.GetChartListWithFiltersFromOffset(cq, order, offset, limit)
+	observeQuery("GetChartListWithFiltersFromOffset", start, err)
+	return charts, err
+}
+
+func (m *instrumentedAssetManager) GetAllChartCategories(cq utils.ChartQuery) ([]*models.ChartCategory, error) {
+	start := time.Now()
+	categories, err := m.AssetManager.GetAllChartCategories(cq)
+	observeQuery("GetAllChartCategories", start, err)
+	return categories, err
+}
@@ -36,16 +36,23 @@ func init() {
 
 // RegisterWithGRPCServer enables a plugin to register with a gRPC server
 // returning the server implementation.
-func RegisterWithGRPCServer(s grpc.ServiceRegistrar, clientGetter server.KubernetesClientGetter) (interface{}, error) {
-	svr := NewServer(clientGetter)
+func RegisterWithGRPCServer(ctx context.Context, s grpc.ServiceRegistrar, configGetter server.KubernetesConfigGetter, kubeappsCluster string, config server.PluginConfig) (interface{}, error) {
+	svr, err := NewServer(configGetter, config)
+	if err != nil {
+		return nil, err
+	}
 	v1alpha1.RegisterHelmPackagesServiceServer(s, svr)
+	v1alpha1.RegisterHelmRepositoriesServiceServer(s, svr)
 	return svr, nil
 }
 
 // RegisterHTTPHandlerFromEndpoint enables a plugin to register an http
 // handler to translate to the gRPC request.
 func RegisterHTTPHandlerFromEndpoint(ctx context.Context, mux *runtime.ServeMux, endpoint string, opts []grpc.DialOption) error {
-	return v1alpha1.RegisterHelmPackagesServiceHandlerFromEndpoint(ctx, mux, endpoint, opts)
+	if err := v1alpha1.RegisterHelmPackagesServiceHandlerFromEndpoint(ctx, mux, endpoint, opts); err != nil {
+		return err
+	}
+	return v1alpha1.RegisterHelmRepositoriesServiceHandlerFromEndpoint(ctx, mux, endpoint, opts)
 }
 
 // GetPluginDetail returns a core.plugins.Plugin describing itself.
@@ -0,0 +1,410 @@
+/*
+Copyright © 2021 VMware
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	appRepov1 "github.com/kubeapps/kubeapps/cmd/apprepository-controller/pkg/apis/apprepository/v1alpha1"
+	corev1 "github.com/kubeapps/kubeapps/cmd/kubeapps-apis/gen/core/packages/v1alpha1"
+	"github.com/kubeapps/kubeapps/pkg/kube"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	log "k8s.io/klog/v2"
+)
+
+// Compile-time statement to ensure this service implementation satisfies the core repositories API
+var _ corev1.RepositoriesServiceServer = (*Server)(nil)
+
+const (
+	// the only type of AppRepository which can be fully described with the core API,
+	// since OCI AppRepositories also require the list of repositories in the registry
+	helmRepoType = "helm"
+
+	// the secret key used by the kube handler for docker config credentials
+	dockerConfigSecretKey = ".dockerconfigjson"
+)
+
+// appRepoHandlerGetter is a function type used to obtain the handler for the
+// AppRepositories, using the credentials from the request context.
+type appRepoHandlerGetter func(ctx context.Context) (kube.AppRepositoryHandler, error)
+
+// GetPackageRepositorySummaries returns the AppRepositories in the request context namespace,
+// or in the global packaging namespace if none is specified. AppRepositories are always on the
+// cluster on which Kubeapps is installed.
+func (s *Server) GetPackageRepositorySummaries(ctx context.Context, request *corev1.GetPackageRepositorySummariesRequest) (*corev1.GetPackageRepositorySummariesResponse, error) {
+	contextMsg := ""
+	if request.Context != nil {
+		contextMsg = fmt.Sprintf("(cluster=[%s], namespace=[%s])", request.Context.Cluster, request.Context.Namespace)
+	}
+
+	log.Infof("+helm GetPackageRepositorySummaries %s", contextMsg)
+
+	namespace := request.GetContext().GetNamespace()
+	if namespace == "" {
+		namespace = s.globalPackagingNamespace
+	}
+
+	handler, err := s.getAppRepoHandler(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	appRepos, err := handler.ListAppRepositories(namespace)
+	if err != nil {
+		return nil, statusErrorForAppRepoError(err, "Unable to list app repositories")
+	}
+
+	summaries := []*corev1.PackageRepositorySummary{}
+	for i := range appRepos.Items {
+		summaries = append(summaries, packageRepositorySummaryFromAppRepo(request.GetContext().GetCluster(), &appRepos.Items[i]))
+	}
+	return &corev1.GetPackageRepositorySummariesResponse{
+		PackageRepositorySummaries: summaries,
+	}, nil
+}
+
+// GetPackageRepositoryDetail returns the AppRepository referenced in the request.
+func (s *Server) GetPackageRepositoryDetail(ctx context.Context, request *corev1.GetPackageRepositoryDetailRequest) (*corev1.GetPackageRepositoryDetailResponse, error) {
+	repoRef := request.GetPackageRepoRef()
+	log.Infof("+helm GetPackageRepositoryDetail (namespace=[%s], identifier=[%s])", repoRef.GetContext().GetNamespace(), repoRef.GetIdentifier())
+
+	if err := validatePackageRepositoryRef(repoRef); err != nil {
+		return nil, err
+	}
+
+	handler, err := s.getAppRepoHandler(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	appRepo, err := handler.GetAppRepository(repoRef.Identifier, repoRef.Context.Namespace)
+	if err != nil {
+		return nil, statusErrorForAppRepoError(err, fmt.Sprintf("Unable to get app repository [%s]", repoRef.Identifier))
+	}
+
+	return &corev1.GetPackageRepositoryDetailResponse{
+		Detail: packageRepositoryDetailFromAppRepo(repoRef.Context.Cluster, appRepo),
+	}, nil
+}
+
+// AddPackageRepository creates an AppRepository, together with the secret for its
+// credentials and certificate authority if any, for the repository in the request.
+func (s *Server) AddPackageRepository(ctx context.Context, request *corev1.AddPackageRepositoryRequest) (*corev1.AddPackageRepositoryResponse, error) {
+	log.Infof("+helm AddPackageRepository (namespace=[%s], name=[%s])", request.GetContext().GetNamespace(), request.GetName())
+
+	if request.GetContext().GetNamespace() == "" || request.Name == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "Required namespace or name not provided")
+	}
+	if request.Type != "" && request.Type != helmRepoType {
+		return nil, status.Errorf(codes.InvalidArgument, "Repository type [%s] not supported by the helm plugin", request.Type)
+	}
+
+	details := kube.AppRepositoryRequestDetails{
+		Name:        request.Name,
+		Type:        helmRepoType,
+		Description: request.Description,
+	}
+	if err := setAppRepoRequestDetails(&details, request.Url, request.Interval, request.Auth, request.TlsConfig); err != nil {
+		return nil, err
+	}
+
+	handler, err := s.getAppRepoHandler(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := appRepoRequestBody(details)
+	if err != nil {
+		return nil, err
+	}
+	appRepo, err := handler.CreateAppRepository(body, request.Context.Namespace)
+	if err != nil {
+		return nil, statusErrorForAppRepoError(err, fmt.Sprintf("Unable to create app repository [%s]", request.Name))
+	}
+
+	return &corev1.AddPackageRepositoryResponse{
+		PackageRepoRef: packageRepositoryRefFromAppRepo(request.Context.Cluster, appRepo),
+	}, nil
+}
+
+// UpdatePackageRepository updates the url, description, credentials and TLS configuration
+// of the AppRepository referenced in the request. The credentials and TLS configuration
+// are replaced by those in the request, while the rest of the spec is kept.
+func (s *Server) UpdatePackageRepository(ctx context.Context, request *corev1.UpdatePackageRepositoryRequest) (*corev1.UpdatePackageRepositoryResponse, error) {
+	repoRef := request.GetPackageRepoRef()
+	log.Infof("+helm UpdatePackageRepository (namespace=[%s], identifier=[%s])", repoRef.GetContext().GetNamespace(), repoRef.GetIdentifier())
+
+	if err := validatePackageRepositoryRef(repoRef); err != nil {
+		return nil, err
+	}
+
+	handler, err := s.getAppRepoHandler(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	existingAppRepo, err := handler.GetAppRepository(repoRef.Identifier, repoRef.Context.Namespace)
+	if err != nil {
+		return nil, statusErrorForAppRepoError(err, fmt.Sprintf("Unable to get app repository [%s]", repoRef.Identifier))
+	}
+
+	// The kube handler replaces the whole spec, so the fields which cannot be set
+	// with the core API are copied from the existing AppRepository.
+	details := kube.AppRepositoryRequestDetails{
+		Name:               existingAppRepo.Name,
+		Type:               existingAppRepo.Spec.Type,
+		Description:        request.Description,
+		RegistrySecrets:    existingAppRepo.Spec.DockerRegistrySecrets,
+		SyncJobPodTemplate: existingAppRepo.Spec.SyncJobPodTemplate,
+		ResyncRequests:     existingAppRepo.Spec.ResyncRequests,
+		OCIRepositories:    existingAppRepo.Spec.OCIRepositories,
+		FilterRule:         existingAppRepo.Spec.FilterRule,
+	}
+	if err = setAppRepoRequestDetails(&details, request.Url, request.Interval, request.Auth, request.TlsConfig); err != nil {
+		return nil, err
+	}
+
+	body, err := appRepoRequestBody(details)
+	if err != nil {
+		return nil, err
+	}
+	appRepo, err := handler.UpdateAppRepository(body, repoRef.Context.Namespace)
+	if err != nil {
+		return nil, statusErrorForAppRepoError(err, fmt.Sprintf("Unable to update app repository [%s]", repoRef.Identifier))
+	}
+
+	return &corev1.UpdatePackageRepositoryResponse{
+		PackageRepoRef: packageRepositoryRefFromAppRepo(repoRef.Context.Cluster, appRepo),
+	}, nil
+}
+
+// DeletePackageRepository deletes the AppRepository referenced in the request, together
+// with the copy of its credentials in the Kubeapps namespace.
+func (s *Server) DeletePackageRepository(ctx context.Context, request *corev1.DeletePackageRepositoryRequest) (*corev1.DeletePackageRepositoryResponse, error) {
+	repoRef := request.GetPackageRepoRef()
+	log.Infof("+helm DeletePackageRepository (namespace=[%s], identifier=[%s])", repoRef.GetContext().GetNamespace(), repoRef.GetIdentifier())
+
+	if err := validatePackageRepositoryRef(repoRef); err != nil {
+		return nil, err
+	}
+
+	handler, err := s.getAppRepoHandler(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = handler.DeleteAppRepository(repoRef.Identifier, repoRef.Context.Namespace); err != nil {
+		return nil, statusErrorForAppRepoError(err, fmt.Sprintf("Unable to delete app repository [%s]", repoRef.Identifier))
+	}
+	return &corev1.DeletePackageRepositoryResponse{}, nil
+}
+
+// RefreshPackageRepository requests the AppRepository referenced in the request to be
+// synced again by the apprepository-controller.
+func (s *Server) RefreshPackageRepository(ctx context.Context, request *corev1.RefreshPackageRepositoryRequest) (*corev1.RefreshPackageRepositoryResponse, error) {
+	repoRef := request.GetPackageRepoRef()
+	log.Infof("+helm RefreshPackageRepository (namespace=[%s], identifier=[%s])", repoRef.GetContext().GetNamespace(), repoRef.GetIdentifier())
+
+	if err := validatePackageRepositoryRef(repoRef); err != nil {
+		return nil, err
+	}
+
+	handler, err := s.getAppRepoHandler(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	appRepo, err := handler.RefreshAppRepository(repoRef.Identifier, repoRef.Context.Namespace)
+	if err != nil {
+		return nil, statusErrorForAppRepoError(err, fmt.Sprintf("Unable to refresh app repository [%s]", repoRef.Identifier))
+	}
+
+	return &corev1.RefreshPackageRepositoryResponse{
+		PackageRepoRef: packageRepositoryRefFromAppRepo(repoRef.Context.Cluster, appRepo),
+	}, nil
+}
+
+// getAppRepoHandler ensures a handler getter is available and uses it to return
+// the handler for the AppRepositories.
+func (s *Server) getAppRepoHandler(ctx context.Context) (kube.AppRepositoryHandler, error) {
+	if s.appRepoHandlerGetter == nil {
+		return nil, status.Errorf(codes.Internal, "server not configured with appRepoHandlerGetter")
+	}
+	handler, err := s.appRepoHandlerGetter(ctx)
+	if err != nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "unable to get app repository handler: %v", err)
+	}
+	return handler, nil
+}
+
+// setAppRepoRequestDetails sets the url, credentials and TLS configuration of the
+// AppRepository request. Credentials are stored by the kube handler in a secret
+// for the AppRepository, except for docker config secrets, which are referenced.
+func setAppRepoRequestDetails(details *kube.AppRepositoryRequestDetails, url string, interval uint32, auth *corev1.PackageRepositoryAuth, tlsConfig *corev1.PackageRepositoryTlsConfig) error {
+	if url == "" {
+		return status.Errorf(codes.InvalidArgument, "Required repository url not provided")
+	}
+	if interval > 0 {
+		return status.Errorf(codes.InvalidArgument, "A custom interval is not supported by the helm plugin, AppRepositories are synced on the global schedule")
+	}
+	details.RepoURL = url
+
+	switch auth.GetType() {
+	case corev1.PackageRepositoryAuth_PACKAGE_REPOSITORY_AUTH_TYPE_UNSPECIFIED:
+		if auth.GetPackageRepoAuthOneOf() != nil {
+			return status.Errorf(codes.InvalidArgument, "Required auth type not provided")
+		}
+	case corev1.PackageRepositoryAuth_PACKAGE_REPOSITORY_AUTH_TYPE_BASIC_AUTH:
+		usernamePassword := auth.GetUsernamePassword()
+		if usernamePassword == nil {
+			return status.Errorf(codes.InvalidArgument, "Basic auth requires the username and password")
+		}
+		credentials := fmt.Sprintf("%s:%s", usernamePassword.Username, usernamePassword.Password)
+		details.AuthHeader = fmt.Sprintf("Basic %s", base64.StdEncoding.EncodeToString([]byte(credentials)))
+	case corev1.PackageRepositoryAuth_PACKAGE_REPOSITORY_AUTH_TYPE_AUTHORIZATION_HEADER:
+		if auth.GetHeader() == "" {
+			return status.Errorf(codes.InvalidArgument, "Authorization header auth requires the header")
+		}
+		details.AuthHeader = auth.GetHeader()
+	case corev1.PackageRepositoryAuth_PACKAGE_REPOSITORY_AUTH_TYPE_DOCKER_CONFIG_JSON:
+		if auth.GetSecretRef().GetName() == "" {
+			return status.Errorf(codes.InvalidArgument, "Docker config auth requires a reference to an existing secret")
+		}
+		details.AuthRegCreds = auth.GetSecretRef().GetName()
+	default:
+		return status.Errorf(codes.InvalidArgument, "Auth type [%v] not supported by the helm plugin", auth.GetType())
+	}
+	details.PassCredentials = auth.GetPassCredentials()
+
+	if tlsConfig.GetSecretRef() != nil {
+		return status.Errorf(codes.InvalidArgument, "A certificate authority secret is not supported by the helm plugin, provide the certificate authority instead")
+	}
+	details.CustomCA = tlsConfig.GetCertAuthority()
+	details.TLSInsecureSkipVerify = tlsConfig.GetInsecureSkipVerify()
+	return nil
+}
+
+// appRepoRequestBody returns the JSON body expected by the kube handler for the AppRepository details.
+func appRepoRequestBody(details kube.AppRepositoryRequestDetails) (io.ReadCloser, error) {
+	body, err := json.Marshal(kube.AppRepositoryRequest{AppRepository: details})
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Unable to encode app repository request: %v", err)
+	}
+	return ioutil.NopCloser(bytes.NewReader(body)), nil
+}
+
+func packageRepositoryRefFromAppRepo(cluster string, appRepo *appRepov1.AppRepository) *corev1.PackageRepositoryReference {
+	return &corev1.PackageRepositoryReference{
+		Context: &corev1.Context{
+			Cluster:   cluster,
+			Namespace: appRepo.Namespace,
+		},
+		Identifier: appRepo.Name,
+		Plugin:     GetPluginDetail(),
+	}
+}
+
+func packageRepositorySummaryFromAppRepo(cluster string, appRepo *appRepov1.AppRepository) *corev1.PackageRepositorySummary {
+	return &corev1.PackageRepositorySummary{
+		PackageRepoRef: packageRepositoryRefFromAppRepo(cluster, appRepo),
+		Name:           appRepo.Name,
+		Description:    appRepo.Spec.Description,
+		Type:           appRepo.Spec.Type,
+		Url:            appRepo.Spec.URL,
+	}
+}
+
+// packageRepositoryDetailFromAppRepo returns the detail of the AppRepository, with
+// references to the secrets holding its credentials and certificate authority.
+func packageRepositoryDetailFromAppRepo(cluster string, appRepo *appRepov1.AppRepository) *corev1.PackageRepositoryDetail {
+	detail := &corev1.PackageRepositoryDetail{
+		PackageRepoRef: packageRepositoryRefFromAppRepo(cluster, appRepo),
+		Name:           appRepo.Name,
+		Description:    appRepo.Spec.Description,
+		Type:           appRepo.Spec.Type,
+		Url:            appRepo.Spec.URL,
+	}
+
+	if header := appRepo.Spec.Auth.Header; header != nil {
+		authType := corev1.PackageRepositoryAuth_PACKAGE_REPOSITORY_AUTH_TYPE_AUTHORIZATION_HEADER
+		if header.SecretKeyRef.Key == dockerConfigSecretKey {
+			authType = corev1.PackageRepositoryAuth_PACKAGE_REPOSITORY_AUTH_TYPE_DOCKER_CONFIG_JSON
+		}
+		detail.Auth = &corev1.PackageRepositoryAuth{
+			Type: authType,
+			PackageRepoAuthOneOf: &corev1.PackageRepositoryAuth_SecretRef{
+				SecretRef: &corev1.SecretKeyReference{
+					Name: header.SecretKeyRef.Name,
+					Key:  header.SecretKeyRef.Key,
+				},
+			},
+			PassCredentials: appRepo.Spec.PassCredentials,
+		}
+	} else if appRepo.Spec.PassCredentials {
+		detail.Auth = &corev1.PackageRepositoryAuth{
+			PassCredentials: true,
+		}
+	}
+
+	if customCA := appRepo.Spec.Auth.CustomCA; customCA != nil || appRepo.Spec.TLSInsecureSkipVerify {
+		detail.TlsConfig = &corev1.PackageRepositoryTlsConfig{
+			InsecureSkipVerify: appRepo.Spec.TLSInsecureSkipVerify,
+		}
+		if customCA != nil {
+			detail.TlsConfig.PackageRepoTlsConfigOneOf = &corev1.PackageRepositoryTlsConfig_SecretRef{
+				SecretRef: &corev1.SecretKeyReference{
+					Name: customCA.SecretKeyRef.Name,
+					Key:  customCA.SecretKeyRef.Key,
+				},
+			}
+		}
+	}
+	return detail
+}
+
+func validatePackageRepositoryRef(repoRef *corev1.PackageRepositoryReference) error {
+	if repoRef.GetContext().GetNamespace() == "" || repoRef.GetIdentifier() == "" {
+		return status.Errorf(codes.InvalidArgument, "Required context or identifier not provided for the package repository")
+	}
+	return nil
+}
+
+// statusErrorForAppRepoError returns a grpc status error for the error returned
+// by the kube handler, with the most common failure reasons mapped to the
+// corresponding code.
+func statusErrorForAppRepoError(err error, msg string) error {
+	switch {
+	case errors.Is(err, kube.ErrGlobalRepositoryWithSecrets), errors.Is(err, kube.ErrEmptyOCIRegistry):
+		return status.Errorf(codes.InvalidArgument, "%s: %v", msg, err)
+	case k8serrors.IsNotFound(err):
+		return status.Errorf(codes.NotFound, "%s: %v", msg, err)
+	case k8serrors.IsAlreadyExists(err):
+		return status.Errorf(codes.AlreadyExists, "%s: %v", msg, err)
+	case k8serrors.IsForbidden(err):
+		return status.Errorf(codes.PermissionDenied, "%s: %v", msg, err)
+	case k8serrors.IsUnauthorized(err):
+		return status.Errorf(codes.Unauthenticated, "%s: %v", msg, err)
+	case k8serrors.IsInvalid(err), k8serrors.IsBadRequest(err):
+		return status.Errorf(codes.InvalidArgument, "%s: %v", msg, err)
+	}
+	return status.Errorf(codes.Internal, "%s: %v", msg, err)
+}
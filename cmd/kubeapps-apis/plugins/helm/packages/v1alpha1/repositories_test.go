@@ -0,0 +1,452 @@
+/*
+Copyright © 2021 VMware
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	appRepov1 "github.com/kubeapps/kubeapps/cmd/apprepository-controller/pkg/apis/apprepository/v1alpha1"
+	corev1 "github.com/kubeapps/kubeapps/cmd/kubeapps-apis/gen/core/packages/v1alpha1"
+	plugins "github.com/kubeapps/kubeapps/cmd/kubeapps-apis/gen/core/plugins/v1alpha1"
+	"github.com/kubeapps/kubeapps/pkg/kube"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	k8scorev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// fakeAppRepoHandler records the AppRepository requests and returns the
+// AppRepositories built from them, as the kube handler does.
+type fakeAppRepoHandler struct {
+	appRepos       []*appRepov1.AppRepository
+	requests       []kube.AppRepositoryRequestDetails
+	requestedNames []string
+	err            error
+}
+
+func (h *fakeAppRepoHandler) ListAppRepositories(requestNamespace string) (*appRepov1.AppRepositoryList, error) {
+	list := &appRepov1.AppRepositoryList{}
+	for _, r := range h.appRepos {
+		if r.Namespace == requestNamespace {
+			list.Items = append(list.Items, *r)
+		}
+	}
+	return list, h.err
+}
+
+func (h *fakeAppRepoHandler) appRepoFromBody(appRepoBody io.ReadCloser, requestNamespace string) (*appRepov1.AppRepository, error) {
+	var request kube.AppRepositoryRequest
+	if err := json.NewDecoder(appRepoBody).Decode(&request); err != nil {
+		return nil, err
+	}
+	h.requests = append(h.requests, request.AppRepository)
+	return newAppRepoObject(request.AppRepository.Name, requestNamespace, request.AppRepository.RepoURL), h.err
+}
+
+func (h *fakeAppRepoHandler) CreateAppRepository(appRepoBody io.ReadCloser, requestNamespace string) (*appRepov1.AppRepository, error) {
+	return h.appRepoFromBody(appRepoBody, requestNamespace)
+}
+
+func (h *fakeAppRepoHandler) UpdateAppRepository(appRepoBody io.ReadCloser, requestNamespace string) (*appRepov1.AppRepository, error) {
+	return h.appRepoFromBody(appRepoBody, requestNamespace)
+}
+
+func (h *fakeAppRepoHandler) RefreshAppRepository(repoName string, requestNamespace string) (*appRepov1.AppRepository, error) {
+	h.requestedNames = append(h.requestedNames, repoName)
+	return h.GetAppRepository(repoName, requestNamespace)
+}
+
+func (h *fakeAppRepoHandler) DeleteAppRepository(repoName, repoNamespace string) error {
+	h.requestedNames = append(h.requestedNames, repoName)
+	_, err := h.GetAppRepository(repoName, repoNamespace)
+	return err
+}
+
+func (h *fakeAppRepoHandler) GetAppRepository(repoName, repoNamespace string) (*appRepov1.AppRepository, error) {
+	for _, r := range h.appRepos {
+		if r.Name == repoName && r.Namespace == repoNamespace {
+			return r, nil
+		}
+	}
+	return nil, k8serrors.NewNotFound(schema.GroupResource{Group: "kubeapps.com", Resource: "apprepositories"}, repoName)
+}
+
+func newAppRepoObject(name, namespace, url string) *appRepov1.AppRepository {
+	return &appRepov1.AppRepository{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		Spec: appRepov1.AppRepositorySpec{
+			Type: "helm",
+			URL:  url,
+		},
+	}
+}
+
+func makeServerWithAppRepoHandler(handler *fakeAppRepoHandler) *Server {
+	return &Server{
+		globalPackagingNamespace: globalPackagingNamespace,
+		appRepoHandlerGetter: func(context.Context) (kube.AppRepositoryHandler, error) {
+			return handler, nil
+		},
+	}
+}
+
+func repoRef(name, namespace string) *corev1.PackageRepositoryReference {
+	return &corev1.PackageRepositoryReference{
+		Context: &corev1.Context{
+			Namespace: namespace,
+		},
+		Identifier: name,
+		Plugin:     GetPluginDetail(),
+	}
+}
+
+var packageRepositoryOpts = cmpopts.IgnoreUnexported(
+	corev1.PackageRepositorySummary{},
+	corev1.PackageRepositoryDetail{},
+	corev1.PackageRepositoryReference{},
+	corev1.PackageRepositoryAuth{},
+	corev1.PackageRepositoryTlsConfig{},
+	corev1.SecretKeyReference{},
+	corev1.Context{},
+	plugins.Plugin{},
+)
+
+func TestGetPackageRepositorySummaries(t *testing.T) {
+	testCases := []struct {
+		name              string
+		request           *corev1.GetPackageRepositorySummariesRequest
+		expectedSummaries []*corev1.PackageRepositorySummary
+	}{
+		{
+			name:    "returns the repositories in the global namespace by default",
+			request: &corev1.GetPackageRepositorySummariesRequest{Context: &corev1.Context{}},
+			expectedSummaries: []*corev1.PackageRepositorySummary{
+				{
+					PackageRepoRef: repoRef("bitnami", globalPackagingNamespace),
+					Name:           "bitnami",
+					Type:           "helm",
+					Url:            "https://charts.bitnami.com/bitnami",
+				},
+			},
+		},
+		{
+			name:    "returns the repositories in the requested namespace",
+			request: &corev1.GetPackageRepositorySummariesRequest{Context: &corev1.Context{Namespace: "my-ns"}},
+			expectedSummaries: []*corev1.PackageRepositorySummary{
+				{
+					PackageRepoRef: repoRef("my-repo", "my-ns"),
+					Name:           "my-repo",
+					Type:           "helm",
+					Url:            "https://example.com/charts",
+				},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := makeServerWithAppRepoHandler(&fakeAppRepoHandler{
+				appRepos: []*appRepov1.AppRepository{
+					newAppRepoObject("bitnami", globalPackagingNamespace, "https://charts.bitnami.com/bitnami"),
+					newAppRepoObject("my-repo", "my-ns", "https://example.com/charts"),
+				},
+			})
+
+			response, err := s.GetPackageRepositorySummaries(context.Background(), tc.request)
+			if err != nil {
+				t.Fatalf("%+v", err)
+			}
+
+			if got, want := response.PackageRepositorySummaries, tc.expectedSummaries; !cmp.Equal(got, want, packageRepositoryOpts) {
+				t.Errorf("mismatch (-want +got):\n%s", cmp.Diff(want, got, packageRepositoryOpts))
+			}
+		})
+	}
+}
+
+func TestPackageRepositoryDetailFromAppRepo(t *testing.T) {
+	appRepo := newAppRepoObject("my-repo", "my-ns", "https://example.com/charts")
+	appRepo.Spec.Description = "my repository"
+	appRepo.Spec.PassCredentials = true
+	appRepo.Spec.Auth = appRepov1.AppRepositoryAuth{
+		Header: &appRepov1.AppRepositoryAuthHeader{
+			SecretKeyRef: k8scorev1.SecretKeySelector{
+				LocalObjectReference: k8scorev1.LocalObjectReference{Name: "apprepo-my-repo"},
+				Key:                  "authorizationHeader",
+			},
+		},
+		CustomCA: &appRepov1.AppRepositoryCustomCA{
+			SecretKeyRef: k8scorev1.SecretKeySelector{
+				LocalObjectReference: k8scorev1.LocalObjectReference{Name: "apprepo-my-repo"},
+				Key:                  "ca.crt",
+			},
+		},
+	}
+
+	expected := &corev1.PackageRepositoryDetail{
+		PackageRepoRef: repoRef("my-repo", "my-ns"),
+		Name:           "my-repo",
+		Description:    "my repository",
+		Type:           "helm",
+		Url:            "https://example.com/charts",
+		Auth: &corev1.PackageRepositoryAuth{
+			Type: corev1.PackageRepositoryAuth_PACKAGE_REPOSITORY_AUTH_TYPE_AUTHORIZATION_HEADER,
+			PackageRepoAuthOneOf: &corev1.PackageRepositoryAuth_SecretRef{
+				SecretRef: &corev1.SecretKeyReference{Name: "apprepo-my-repo", Key: "authorizationHeader"},
+			},
+			PassCredentials: true,
+		},
+		TlsConfig: &corev1.PackageRepositoryTlsConfig{
+			PackageRepoTlsConfigOneOf: &corev1.PackageRepositoryTlsConfig_SecretRef{
+				SecretRef: &corev1.SecretKeyReference{Name: "apprepo-my-repo", Key: "ca.crt"},
+			},
+		},
+	}
+
+	if got, want := packageRepositoryDetailFromAppRepo("", appRepo), expected; !cmp.Equal(got, want, packageRepositoryOpts) {
+		t.Errorf("mismatch (-want +got):\n%s", cmp.Diff(want, got, packageRepositoryOpts))
+	}
+}
+
+func TestAddPackageRepository(t *testing.T) {
+	testCases := []struct {
+		name               string
+		request            *corev1.AddPackageRepositoryRequest
+		expectedRequest    kube.AppRepositoryRequestDetails
+		expectedStatusCode codes.Code
+	}{
+		{
+			name: "it creates a repository with basic auth and a custom CA",
+			request: &corev1.AddPackageRepositoryRequest{
+				Context:     &corev1.Context{Namespace: "my-ns"},
+				Name:        "my-repo",
+				Description: "my repository",
+				Url:         "https://example.com/charts",
+				Auth: &corev1.PackageRepositoryAuth{
+					Type: corev1.PackageRepositoryAuth_PACKAGE_REPOSITORY_AUTH_TYPE_BASIC_AUTH,
+					PackageRepoAuthOneOf: &corev1.PackageRepositoryAuth_UsernamePassword{
+						UsernamePassword: &corev1.UsernamePassword{Username: "foo", Password: "bar"},
+					},
+				},
+				TlsConfig: &corev1.PackageRepositoryTlsConfig{
+					PackageRepoTlsConfigOneOf: &corev1.PackageRepositoryTlsConfig_CertAuthority{
+						CertAuthority: "-----BEGIN CERTIFICATE-----",
+					},
+				},
+			},
+			expectedRequest: kube.AppRepositoryRequestDetails{
+				Name:        "my-repo",
+				Type:        "helm",
+				RepoURL:     "https://example.com/charts",
+				Description: "my repository",
+				AuthHeader:  "Basic Zm9vOmJhcg==",
+				CustomCA:    "-----BEGIN CERTIFICATE-----",
+			},
+		},
+		{
+			name: "it creates a repository referencing a docker config secret",
+			request: &corev1.AddPackageRepositoryRequest{
+				Context: &corev1.Context{Namespace: "my-ns"},
+				Name:    "my-repo",
+				Url:     "https://example.com/charts",
+				Auth: &corev1.PackageRepositoryAuth{
+					Type: corev1.PackageRepositoryAuth_PACKAGE_REPOSITORY_AUTH_TYPE_DOCKER_CONFIG_JSON,
+					PackageRepoAuthOneOf: &corev1.PackageRepositoryAuth_SecretRef{
+						SecretRef: &corev1.SecretKeyReference{Name: "registry-creds"},
+					},
+				},
+			},
+			expectedRequest: kube.AppRepositoryRequestDetails{
+				Name:         "my-repo",
+				Type:         "helm",
+				RepoURL:      "https://example.com/charts",
+				AuthRegCreds: "registry-creds",
+			},
+		},
+		{
+			name: "it returns invalid argument for an unsupported type",
+			request: &corev1.AddPackageRepositoryRequest{
+				Context: &corev1.Context{Namespace: "my-ns"},
+				Name:    "my-repo",
+				Type:    "oci",
+				Url:     "https://example.com/charts",
+			},
+			expectedStatusCode: codes.InvalidArgument,
+		},
+		{
+			name: "it returns invalid argument for a custom interval",
+			request: &corev1.AddPackageRepositoryRequest{
+				Context:  &corev1.Context{Namespace: "my-ns"},
+				Name:     "my-repo",
+				Url:      "https://example.com/charts",
+				Interval: 60,
+			},
+			expectedStatusCode: codes.InvalidArgument,
+		},
+		{
+			name: "it returns invalid argument for an auth without type",
+			request: &corev1.AddPackageRepositoryRequest{
+				Context: &corev1.Context{Namespace: "my-ns"},
+				Name:    "my-repo",
+				Url:     "https://example.com/charts",
+				Auth: &corev1.PackageRepositoryAuth{
+					PackageRepoAuthOneOf: &corev1.PackageRepositoryAuth_Header{Header: "Bearer foo"},
+				},
+			},
+			expectedStatusCode: codes.InvalidArgument,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			handler := &fakeAppRepoHandler{}
+			s := makeServerWithAppRepoHandler(handler)
+
+			response, err := s.AddPackageRepository(context.Background(), tc.request)
+
+			if got, want := status.Code(err), tc.expectedStatusCode; got != want {
+				t.Fatalf("got: %+v, want: %+v, err: %+v", got, want, err)
+			}
+			if tc.expectedStatusCode != codes.OK {
+				return
+			}
+
+			if got, want := response.PackageRepoRef, repoRef(tc.request.Name, tc.request.Context.Namespace); !cmp.Equal(got, want, packageRepositoryOpts) {
+				t.Errorf("mismatch (-want +got):\n%s", cmp.Diff(want, got, packageRepositoryOpts))
+			}
+			if got, want := handler.requests, []kube.AppRepositoryRequestDetails{tc.expectedRequest}; !cmp.Equal(got, want, cmpopts.EquateEmpty()) {
+				t.Errorf("mismatch (-want +got):\n%s", cmp.Diff(want, got, cmpopts.EquateEmpty()))
+			}
+		})
+	}
+}
+
+func TestUpdatePackageRepository(t *testing.T) {
+	existingAppRepo := newAppRepoObject("my-repo", "my-ns", "https://example.com/charts")
+	existingAppRepo.Spec.DockerRegistrySecrets = []string{"registry-creds"}
+	existingAppRepo.Spec.FilterRule = appRepov1.FilterRuleSpec{JQ: ".name == $var0", Variables: map[string]string{"$var0": "nginx"}}
+
+	testCases := []struct {
+		name               string
+		request            *corev1.UpdatePackageRepositoryRequest
+		expectedRequest    kube.AppRepositoryRequestDetails
+		expectedStatusCode codes.Code
+	}{
+		{
+			name: "it updates the repository keeping the fields not in the request",
+			request: &corev1.UpdatePackageRepositoryRequest{
+				PackageRepoRef: repoRef("my-repo", "my-ns"),
+				Url:            "https://example.com/other-charts",
+				TlsConfig: &corev1.PackageRepositoryTlsConfig{
+					InsecureSkipVerify: true,
+				},
+			},
+			expectedRequest: kube.AppRepositoryRequestDetails{
+				Name:                  "my-repo",
+				Type:                  "helm",
+				RepoURL:               "https://example.com/other-charts",
+				RegistrySecrets:       []string{"registry-creds"},
+				FilterRule:            existingAppRepo.Spec.FilterRule,
+				TLSInsecureSkipVerify: true,
+			},
+		},
+		{
+			name: "it returns not found for a missing repository",
+			request: &corev1.UpdatePackageRepositoryRequest{
+				PackageRepoRef: repoRef("other-repo", "my-ns"),
+				Url:            "https://example.com/other-charts",
+			},
+			expectedStatusCode: codes.NotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			handler := &fakeAppRepoHandler{appRepos: []*appRepov1.AppRepository{existingAppRepo}}
+			s := makeServerWithAppRepoHandler(handler)
+
+			response, err := s.UpdatePackageRepository(context.Background(), tc.request)
+
+			if got, want := status.Code(err), tc.expectedStatusCode; got != want {
+				t.Fatalf("got: %+v, want: %+v, err: %+v", got, want, err)
+			}
+			if tc.expectedStatusCode != codes.OK {
+				return
+			}
+
+			if got, want := response.PackageRepoRef, tc.request.PackageRepoRef; !cmp.Equal(got, want, packageRepositoryOpts) {
+				t.Errorf("mismatch (-want +got):\n%s", cmp.Diff(want, got, packageRepositoryOpts))
+			}
+			if got, want := handler.requests, []kube.AppRepositoryRequestDetails{tc.expectedRequest}; !cmp.Equal(got, want, cmpopts.EquateEmpty()) {
+				t.Errorf("mismatch (-want +got):\n%s", cmp.Diff(want, got, cmpopts.EquateEmpty()))
+			}
+		})
+	}
+}
+
+func TestDeleteAndRefreshPackageRepository(t *testing.T) {
+	testCases := []struct {
+		name               string
+		repoRef            *corev1.PackageRepositoryReference
+		expectedStatusCode codes.Code
+	}{
+		{
+			name:    "it deletes and refreshes the repository",
+			repoRef: repoRef("my-repo", "my-ns"),
+		},
+		{
+			name:               "it returns not found for a missing repository",
+			repoRef:            repoRef("other-repo", "my-ns"),
+			expectedStatusCode: codes.NotFound,
+		},
+		{
+			name:               "it returns invalid argument without a repository reference",
+			expectedStatusCode: codes.InvalidArgument,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			handler := &fakeAppRepoHandler{
+				appRepos: []*appRepov1.AppRepository{newAppRepoObject("my-repo", "my-ns", "https://example.com/charts")},
+			}
+			s := makeServerWithAppRepoHandler(handler)
+
+			_, err := s.RefreshPackageRepository(context.Background(), &corev1.RefreshPackageRepositoryRequest{PackageRepoRef: tc.repoRef})
+			if got, want := status.Code(err), tc.expectedStatusCode; got != want {
+				t.Fatalf("got: %+v, want: %+v, err: %+v", got, want, err)
+			}
+
+			_, err = s.DeletePackageRepository(context.Background(), &corev1.DeletePackageRepositoryRequest{PackageRepoRef: tc.repoRef})
+			if got, want := status.Code(err), tc.expectedStatusCode; got != want {
+				t.Fatalf("got: %+v, want: %+v, err: %+v", got, want, err)
+			}
+
+			if tc.expectedStatusCode == codes.OK {
+				if got, want := handler.requestedNames, []string{"my-repo", "my-repo"}; !cmp.Equal(got, want) {
+					t.Errorf("mismatch (-want +got):\n%s", cmp.Diff(want, got))
+				}
+			}
+		})
+	}
+}
@@ -14,81 +14,135 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/url"
 	"os"
 	"strconv"
 	"strings"
 
-	"github.com/Masterminds/semver"
-	"github.com/kubeapps/common/datastore"
+	appRepov1 "github.com/kubeapps/kubeapps/cmd/apprepository-controller/pkg/apis/apprepository/v1alpha1"
 	"github.com/kubeapps/kubeapps/cmd/assetsvc/pkg/utils"
 	corev1 "github.com/kubeapps/kubeapps/cmd/kubeapps-apis/gen/core/packages/v1alpha1"
+	plugins "github.com/kubeapps/kubeapps/cmd/kubeapps-apis/gen/core/plugins/v1alpha1"
 	"github.com/kubeapps/kubeapps/cmd/kubeapps-apis/gen/plugins/helm/packages/v1alpha1"
 	"github.com/kubeapps/kubeapps/cmd/kubeapps-apis/server"
+	"github.com/kubeapps/kubeapps/pkg/agent"
+	chartutils "github.com/kubeapps/kubeapps/pkg/chart"
 	"github.com/kubeapps/kubeapps/pkg/chart/models"
+	"github.com/kubeapps/kubeapps/pkg/handlerutil"
+	"github.com/kubeapps/kubeapps/pkg/kube"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage/driver"
 	authorizationv1 "k8s.io/api/authorization/v1"
+	k8scorev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sruntime "k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
 	log "k8s.io/klog/v2"
+	"sigs.k8s.io/yaml"
 )
 
 // Compile-time statement to ensure this service implementation satisfies the core packaging API
 var _ corev1.PackagesServiceServer = (*Server)(nil)
+var _ server.PluginDependenciesChecker = (*Server)(nil)
 
-const (
-	MajorVersionsInSummary = 3
-	MinorVersionsInSummary = 3
-	PatchVersionsInSummary = 3
-)
+// helmActionConfigGetter is a function type used to obtain the helm action
+// configuration for a namespace of a cluster, using the credentials from the
+// request context.
+type helmActionConfigGetter func(ctx context.Context, cluster, namespace string) (*action.Configuration, error)
 
 // Server implements the helm packages v1alpha1 interface.
 type Server struct {
 	v1alpha1.UnimplementedHelmPackagesServiceServer
+	v1alpha1.UnimplementedHelmRepositoriesServiceServer
 	// clientGetter is a field so that it can be switched in tests for
 	// a fake client. NewServer() below sets this automatically with the
 	// non-test implementation.
 	clientGetter             server.KubernetesClientGetter
 	globalPackagingNamespace string
 	manager                  utils.AssetManager
+	// actionConfigGetter and chartClientFactory are fields for the same
+	// reason, so that tests can use in-memory helm storage and fake charts.
+	actionConfigGetter helmActionConfigGetter
+	chartClientFactory handlerutil.ResolverFactory
+	// appRepoHandlerGetter is a field so that tests can use a fake handler.
+	appRepoHandlerGetter appRepoHandlerGetter
 }
 
 // NewServer returns a Server automatically configured with a function to obtain
-// the k8s client config.
-func NewServer(clientGetter server.KubernetesClientGetter) *Server {
+// the k8s client config, after validating the plugin configuration.
+func NewServer(configGetter server.KubernetesConfigGetter, config server.PluginConfig) (*Server, error) {
+	pluginConfig, err := newPluginConfig(config)
+	if err != nil {
+		return nil, err
+	}
 	var kubeappsNamespace = os.Getenv("POD_NAMESPACE")
-	var ASSET_SYNCER_DB_URL = os.Getenv("ASSET_SYNCER_DB_URL")
-	var ASSET_SYNCER_DB_NAME = os.Getenv("ASSET_SYNCER_DB_NAME")
-	var ASSET_SYNCER_DB_USERNAME = os.Getenv("ASSET_SYNCER_DB_USERNAME")
-	var ASSET_SYNCER_DB_USERPASSWORD = os.Getenv("ASSET_SYNCER_DB_USERPASSWORD")
-
-	var dbConfig = datastore.Config{URL: ASSET_SYNCER_DB_URL, Database: ASSET_SYNCER_DB_NAME, Username: ASSET_SYNCER_DB_USERNAME, Password: ASSET_SYNCER_DB_USERPASSWORD}
 
-	manager, err := utils.NewPGManager(dbConfig, kubeappsNamespace)
+	manager, err := utils.NewPGManager(pluginConfig.datastoreConfig(), kubeappsNamespace)
 	if err != nil {
-		log.Fatalf("%s", err)
+		return nil, err
 	}
 	err = manager.Init()
 	if err != nil {
-		log.Fatalf("%s", err)
+		return nil, err
+	}
+
+	storageForDriver, err := pluginConfig.storageForDriver()
+	if err != nil {
+		return nil, err
 	}
 
 	return &Server{
-		clientGetter:             clientGetter,
-		manager:                  manager,
+		clientGetter:             server.NewClientGetter(configGetter),
+		manager:                  &instrumentedAssetManager{manager},
 		globalPackagingNamespace: kubeappsNamespace,
-	}
+		actionConfigGetter: func(ctx context.Context, cluster, namespace string) (*action.Configuration, error) {
+			if configGetter == nil {
+				return nil, status.Errorf(codes.Internal, "configGetter arg required")
+			}
+			config, err := configGetter(ctx, cluster)
+			if err != nil {
+				return nil, status.Errorf(codes.FailedPrecondition, "unable to get config : %v", err)
+			}
+			restClientSet, err := kubernetes.NewForConfig(config)
+			if err != nil {
+				return nil, status.Errorf(codes.FailedPrecondition, "unable to create kube client : %v", err)
+			}
+			return agent.NewActionConfig(storageForDriver, config, restClientSet, namespace)
+		},
+		chartClientFactory: &handlerutil.ClientResolver{},
+		appRepoHandlerGetter: func(ctx context.Context) (kube.AppRepositoryHandler, error) {
+			if configGetter == nil {
+				return nil, status.Errorf(codes.Internal, "configGetter arg required")
+			}
+			// The AppRepositories are on the cluster on which Kubeapps is installed.
+			config, err := configGetter(ctx, "")
+			if err != nil {
+				return nil, status.Errorf(codes.FailedPrecondition, "unable to get config : %v", err)
+			}
+			svcConfig, err := rest.InClusterConfig()
+			if err != nil {
+				return nil, status.Errorf(codes.FailedPrecondition, "unable to get in-cluster config : %v", err)
+			}
+			return kube.NewAppRepositoryHandler(kubeappsNamespace, config, svcConfig)
+		},
+	}, nil
 }
 
-// GetClients ensures a client getter is available and uses it to return both a typed and dynamic k8s client.
-func (s *Server) GetClients(ctx context.Context) (kubernetes.Interface, dynamic.Interface, error) {
+// GetClients ensures a client getter is available and uses it to return both a typed and dynamic k8s client
+// for the given cluster.
+func (s *Server) GetClients(ctx context.Context, cluster string) (kubernetes.Interface, dynamic.Interface, error) {
 	if s.clientGetter == nil {
 		return nil, nil, status.Errorf(codes.Internal, "server not configured with configGetter")
 	}
-	typedClient, dynamicClient, err := s.clientGetter(ctx)
+	typedClient, dynamicClient, err := s.clientGetter(ctx, cluster)
 	if err != nil {
 		return nil, nil, status.Errorf(codes.FailedPrecondition, fmt.Sprintf("unable to get client : %v", err))
 	}
@@ -104,6 +158,20 @@ func (s *Server) GetManager() (utils.AssetManager, error) {
 	return manager, nil
 }
 
+// CheckDependencies checks that the postgresql database, from which the
+// available packages are read, is reachable.
+func (s *Server) CheckDependencies(ctx context.Context) []*plugins.PluginDependencyStatus {
+	dependency := &plugins.PluginDependencyStatus{Name: "postgresql"}
+	if s.manager == nil {
+		dependency.Message = "server not configured with manager"
+	} else if _, err := s.manager.GetChartListWithFiltersFromOffset(utils.ChartQuery{Namespace: s.globalPackagingNamespace}, utils.ChartOrder{}, 0, 1); err != nil {
+		dependency.Message = err.Error()
+	} else {
+		dependency.Reachable = true
+	}
+	return []*plugins.PluginDependencyStatus{dependency}
+}
+
 // GetAvailablePackageSummaries returns the available packages based on the request.
 func (s *Server) GetAvailablePackageSummaries(ctx context.Context, request *corev1.GetAvailablePackageSummariesRequest) (*corev1.GetAvailablePackageSummariesResponse, error) {
 	contextMsg := ""
@@ -113,12 +181,11 @@ func (s *Server) GetAvailablePackageSummaries(ctx context.Context, request *core
 
 	log.Infof("+helm GetAvailablePackageSummaries %s", contextMsg)
 
-	// Check the request context (namespace and cluster)
+	// Check the request context namespace. The catalog is the same for every cluster,
+	// since it is indexed from the AppRepositories on the cluster on which Kubeapps is
+	// installed, so the cluster is not taken into account.
 	namespace := ""
 	if request.Context != nil {
-		if request.Context.Cluster != "" {
-			return nil, status.Errorf(codes.Unimplemented, "Not supported yet: request.Context.Cluster: [%v]", request.Context.Cluster)
-		}
 		namespace = request.Context.Namespace
 	}
 	// Check the requested namespace: if any, return "everything a user can read";
@@ -151,7 +218,7 @@ func (s *Server) GetAvailablePackageSummaries(ctx context.Context, request *core
 	if err != nil {
 		return nil, status.Errorf(codes.InvalidArgument, "Unable to intepret page token %q: %v", request.GetPaginationOptions().GetPageToken(), err)
 	}
-	charts, _, err := s.manager.GetPaginatedChartListWithFilters(cq, pageOffset, int(pageSize))
+	charts, err := s.manager.GetChartListWithFiltersFromOffset(cq, chartOrderFromSortOptions(request.GetSortOptions()), pageOffset, int(pageSize))
 	if err != nil {
 		return nil, status.Errorf(codes.Internal, "Unable to retrieve charts: %v", err)
 	}
@@ -170,7 +237,7 @@ func (s *Server) GetAvailablePackageSummaries(ctx context.Context, request *core
 	// the results are a full page.
 	nextPageToken := ""
 	if pageSize > 0 && len(responsePackages) == int(pageSize) {
-		nextPageToken = fmt.Sprintf("%d", pageOffset+1)
+		nextPageToken = fmt.Sprintf("%d", pageOffset+len(responsePackages))
 	}
 	return &corev1.GetAvailablePackageSummariesResponse{
 		AvailablePackagesSummaries: responsePackages,
@@ -179,14 +246,12 @@ func (s *Server) GetAvailablePackageSummaries(ctx context.Context, request *core
 }
 
 // pageOffsetFromPageToken converts a page token to an integer offset
-// representing the page of results.
-// TODO(mnelson): When aggregating results from different plugins, we'll
-// need to update the actual query in GetPaginatedChartListWithFilters to
-// use a row offset rather than a page offset (as not all rows may be consumed
-// for a specific plugin when combining).
+// representing the number of rows already returned. Using a row offset rather
+// than a page offset allows the core server to resume a listing part-way through
+// a page when not all rows are consumed while merging the results of plugins.
 func pageOffsetFromPageToken(pageToken string) (int, error) {
 	if pageToken == "" {
-		return 1, nil
+		return 0, nil
 	}
 	offset, err := strconv.ParseUint(pageToken, 10, 0)
 	if err != nil {
@@ -196,6 +261,25 @@ func pageOffsetFromPageToken(pageToken string) (int, error) {
 	return int(offset), nil
 }
 
+// chartOrderFromSortOptions returns the order in which charts are listed for
+// the requested sort options, so that the core server can merge the results.
+// The display name of a chart is its name, and charts are ordered by name
+// when no sort field is requested.
+func chartOrderFromSortOptions(options *corev1.SortOptions) utils.ChartOrder {
+	order := utils.ChartOrder{
+		Descending: options.GetDirection() == corev1.SortOptions_SORT_DIRECTION_DESCENDING,
+	}
+	switch options.GetField() {
+	case corev1.SortOptions_SORT_FIELD_LATEST_VERSION:
+		order.Field = utils.ChartOrderByLatestVersion
+	case corev1.SortOptions_SORT_FIELD_REPOSITORY:
+		order.Field = utils.ChartOrderByRepository
+	default:
+		order.Field = utils.ChartOrderByName
+	}
+	return order
+}
+
 // AvailablePackageSummaryFromChart builds an AvailablePackageSummary from a Chart
 func AvailablePackageSummaryFromChart(chart *models.Chart) (*corev1.AvailablePackageSummary, error) {
 	pkg := &corev1.AvailablePackageSummary{}
@@ -205,9 +289,11 @@ func AvailablePackageSummaryFromChart(chart *models.Chart) (*corev1.AvailablePac
 		return nil, status.Errorf(codes.Internal, "invalid chart: %s", err.Error())
 	}
 
+	pkg.Name = chart.Name
 	pkg.DisplayName = chart.Name
 	pkg.IconUrl = chart.Icon
 	pkg.ShortDescription = chart.Description
+	pkg.Repository = chart.Repo.Name
 
 	pkg.AvailablePackageRef = &corev1.AvailablePackageReference{
 		Identifier: chart.ID,
@@ -316,48 +402,13 @@ func (s *Server) GetAvailablePackageVersions(ctx context.Context, request *corev
 // packageAppVersionsSummary converts the model chart versions into the required version summary.
 func packageAppVersionsSummary(versions []models.ChartVersion) []*corev1.GetAvailablePackageVersionsResponse_PackageAppVersion {
 	pav := []*corev1.GetAvailablePackageVersionsResponse_PackageAppVersion{}
-
-	// Use a version map to be able to count how many major, minor and patch versions
-	// we have included.
-	version_map := map[int64]map[int64][]int64{}
 	for _, v := range versions {
-		version, err := semver.NewVersion(v.Version)
-		if err != nil {
-			continue
-		}
-
-		if _, ok := version_map[version.Major()]; !ok {
-			// Don't add a new major version if we already have enough
-			if len(version_map) >= MajorVersionsInSummary {
-				continue
-			}
-		} else {
-			// If we don't yet have this minor version
-			if _, ok := version_map[version.Major()][version.Minor()]; !ok {
-				// Don't add a new minor version if we already have enough for this major version
-				if len(version_map[version.Major()]) >= MinorVersionsInSummary {
-					continue
-				}
-			} else {
-				if len(version_map[version.Major()][version.Minor()]) >= PatchVersionsInSummary {
-					continue
-				}
-			}
-		}
-
-		// Include the version and update the version map.
 		pav = append(pav, &corev1.GetAvailablePackageVersionsResponse_PackageAppVersion{
 			PkgVersion: v.Version,
 			AppVersion: v.AppVersion,
 		})
-
-		if _, ok := version_map[version.Major()]; !ok {
-			version_map[version.Major()] = map[int64][]int64{}
-		}
-		version_map[version.Major()][version.Minor()] = append(version_map[version.Major()][version.Minor()], version.Patch())
 	}
-
-	return pav
+	return server.PackageAppVersionsSummary(pav)
 }
 
 // AvailablePackageDetailFromChart builds an AvailablePackageDetail from a Chart
@@ -399,13 +450,19 @@ func AvailablePackageDetailFromChart(chart *models.Chart) (*corev1.AvailablePack
 	return pkg, nil
 }
 
+// WatchAvailablePackages streams the changes of the available packages.
+func (s *Server) WatchAvailablePackages(request *corev1.WatchAvailablePackagesRequest, stream corev1.PackagesService_WatchAvailablePackagesServer) error {
+	return status.Errorf(codes.Unimplemented, "Watching the available packages is not supported by the 'helm' plugin")
+}
+
 // hasAccessToNamespace returns an error if the client does not have read access to a given namespace
+// of the cluster on which Kubeapps is installed, where the AppRepositories are.
 func (s *Server) hasAccessToNamespace(ctx context.Context, namespace string) error {
 	// If checking the global namespace, allow access always
 	if namespace == s.globalPackagingNamespace {
 		return nil
 	}
-	client, _, err := s.GetClients(ctx)
+	client, _, err := s.GetClients(ctx, "")
 	if err != nil {
 		return err
 	}
@@ -462,3 +519,507 @@ func isValidChart(chart *models.Chart) (bool, error) {
 	}
 	return true, nil
 }
+
+// GetActionConfig ensures an action config getter is available and uses it to
+// return the helm action configuration for the namespace of the cluster.
+func (s *Server) GetActionConfig(ctx context.Context, cluster, namespace string) (*action.Configuration, error) {
+	if s.actionConfigGetter == nil {
+		return nil, status.Errorf(codes.Internal, "server not configured with actionConfigGetter")
+	}
+	actionConfig, err := s.actionConfigGetter(ctx, cluster, namespace)
+	if err != nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "unable to get action config: %v", err)
+	}
+	return actionConfig, nil
+}
+
+// GetInstalledPackageSummaries returns the installed packages managed by the 'helm' plugin
+func (s *Server) GetInstalledPackageSummaries(ctx context.Context, request *corev1.GetInstalledPackageSummariesRequest) (*corev1.GetInstalledPackageSummariesResponse, error) {
+	contextMsg := ""
+	if request.Context != nil {
+		contextMsg = fmt.Sprintf("(cluster=[%s], namespace=[%s])", request.Context.Cluster, request.Context.Namespace)
+	}
+
+	log.Infof("+helm GetInstalledPackageSummaries %s", contextMsg)
+
+	cluster, namespace := request.GetContext().GetCluster(), request.GetContext().GetNamespace()
+
+	actionConfig, err := s.GetActionConfig(ctx, cluster, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	pageSize := request.GetPaginationOptions().GetPageSize()
+	pageOffset, err := pageOffsetFromPageToken(request.GetPaginationOptions().GetPageToken())
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "Unable to intepret page token %q: %v", request.GetPaginationOptions().GetPageToken(), err)
+	}
+
+	cmd := action.NewList(actionConfig)
+	if namespace == "" {
+		cmd.AllNamespaces = true
+	}
+	cmd.StateMask = action.ListAll
+	if pageSize > 0 {
+		cmd.Limit = int(pageSize)
+	}
+	cmd.Offset = pageOffset
+	releases, err := cmd.Run()
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Unable to list helm releases: %v", err)
+	}
+
+	installedPkgSummaries := []*corev1.InstalledPackageSummary{}
+	for _, r := range releases {
+		// The storage driver may return releases from other namespaces.
+		if namespace != "" && r.Namespace != namespace {
+			continue
+		}
+		installedPkgSummaries = append(installedPkgSummaries, installedPkgSummaryFromRelease(cluster, r))
+	}
+
+	// Only return a next page token if the request was for pagination and
+	// the results are a full page.
+	nextPageToken := ""
+	if pageSize > 0 && len(releases) == int(pageSize) {
+		nextPageToken = fmt.Sprintf("%d", pageOffset+len(releases))
+	}
+	return &corev1.GetInstalledPackageSummariesResponse{
+		InstalledPackagesSummaries: installedPkgSummaries,
+		NextPageToken:              nextPageToken,
+	}, nil
+}
+
+// installedPkgSummaryFromRelease builds an InstalledPackageSummary from a helm release
+// on the given cluster
+func installedPkgSummaryFromRelease(cluster string, r *release.Release) *corev1.InstalledPackageSummary {
+	summary := &corev1.InstalledPackageSummary{
+		InstalledPackageRef: installedPkgRefFromRelease(cluster, r),
+		Name:                r.Name,
+		Status:              installedPkgStatusFromRelease(r),
+	}
+	if r.Chart != nil && r.Chart.Metadata != nil {
+		summary.PkgVersionReference = &corev1.VersionReference{
+			Version: r.Chart.Metadata.Version,
+		}
+		summary.CurrentVersion = &corev1.PackageAppVersion{
+			PkgVersion: r.Chart.Metadata.Version,
+			AppVersion: r.Chart.Metadata.AppVersion,
+		}
+		summary.IconUrl = r.Chart.Metadata.Icon
+		summary.PkgDisplayName = r.Chart.Metadata.Name
+		summary.ShortDescription = r.Chart.Metadata.Description
+	}
+	return summary
+}
+
+// installedPkgRefFromRelease returns the reference for an installed package
+// given the helm release and the cluster on which it is installed.
+func installedPkgRefFromRelease(cluster string, r *release.Release) *corev1.InstalledPackageReference {
+	return &corev1.InstalledPackageReference{
+		Context: &corev1.Context{
+			Cluster:   cluster,
+			Namespace: r.Namespace,
+		},
+		Identifier: r.Name,
+		Plugin:     GetPluginDetail(),
+	}
+}
+
+// installedPkgStatusFromRelease maps the status of a helm release to
+// the generic installed package status.
+func installedPkgStatusFromRelease(r *release.Release) *corev1.InstalledPackageStatus {
+	if r.Info == nil {
+		return &corev1.InstalledPackageStatus{
+			Reason: corev1.InstalledPackageStatus_STATUS_REASON_UNSPECIFIED,
+		}
+	}
+	pkgStatus := &corev1.InstalledPackageStatus{
+		UserReason: r.Info.Status.String(),
+	}
+	switch r.Info.Status {
+	case release.StatusDeployed:
+		pkgStatus.Ready = true
+		pkgStatus.Reason = corev1.InstalledPackageStatus_STATUS_REASON_INSTALLED
+	case release.StatusUninstalled:
+		pkgStatus.Reason = corev1.InstalledPackageStatus_STATUS_REASON_UNINSTALLED
+	case release.StatusFailed:
+		pkgStatus.Reason = corev1.InstalledPackageStatus_STATUS_REASON_FAILED
+	case release.StatusPendingInstall, release.StatusPendingUpgrade, release.StatusPendingRollback, release.StatusUninstalling:
+		pkgStatus.Reason = corev1.InstalledPackageStatus_STATUS_REASON_PENDING
+	default:
+		pkgStatus.Reason = corev1.InstalledPackageStatus_STATUS_REASON_UNSPECIFIED
+	}
+	return pkgStatus
+}
+
+// GetInstalledPackageDetail returns the package detail of an installed package managed by the 'helm' plugin
+func (s *Server) GetInstalledPackageDetail(ctx context.Context, request *corev1.GetInstalledPackageDetailRequest) (*corev1.GetInstalledPackageDetailResponse, error) {
+	installedRef := request.GetInstalledPackageRef()
+	if err := validateInstalledPackageRef(installedRef); err != nil {
+		return nil, err
+	}
+	contextMsg := fmt.Sprintf("(cluster=[%s], namespace=[%s])", installedRef.Context.Cluster, installedRef.Context.Namespace)
+	log.Infof("+helm GetInstalledPackageDetail %s", contextMsg)
+
+	actionConfig, err := s.GetActionConfig(ctx, installedRef.Context.Cluster, installedRef.Context.Namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := agent.GetRelease(actionConfig, installedRef.Identifier)
+	if err != nil {
+		return nil, statusErrorForHelmError(err, fmt.Sprintf("Unable to get release %q", installedRef.Identifier))
+	}
+
+	detail, err := installedPkgDetailFromRelease(installedRef.Context.Cluster, r)
+	if err != nil {
+		return nil, err
+	}
+
+	return &corev1.GetInstalledPackageDetailResponse{
+		InstalledPackageDetail: detail,
+	}, nil
+}
+
+// installedPkgDetailFromRelease builds an InstalledPackageDetail from a helm release
+// on the given cluster
+func installedPkgDetailFromRelease(cluster string, r *release.Release) (*corev1.InstalledPackageDetail, error) {
+	valuesApplied := ""
+	if len(r.Config) > 0 {
+		valuesBytes, err := yaml.Marshal(r.Config)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "Unable to serialize the values of release %q: %v", r.Name, err)
+		}
+		valuesApplied = string(valuesBytes)
+	}
+
+	detail := &corev1.InstalledPackageDetail{
+		InstalledPackageRef: installedPkgRefFromRelease(cluster, r),
+		Name:                r.Name,
+		ValuesApplied:       valuesApplied,
+		Status:              installedPkgStatusFromRelease(r),
+	}
+	if r.Chart != nil && r.Chart.Metadata != nil {
+		detail.PkgVersionReference = &corev1.VersionReference{
+			Version: r.Chart.Metadata.Version,
+		}
+		detail.CurrentVersion = &corev1.PackageAppVersion{
+			PkgVersion: r.Chart.Metadata.Version,
+			AppVersion: r.Chart.Metadata.AppVersion,
+		}
+	}
+	if r.Info != nil {
+		detail.PostInstallationNotes = r.Info.Notes
+	}
+	return detail, nil
+}
+
+// CreateInstalledPackage installs a helm release for the requested available package
+func (s *Server) CreateInstalledPackage(ctx context.Context, request *corev1.CreateInstalledPackageRequest) (*corev1.CreateInstalledPackageResponse, error) {
+	availableRef := request.GetAvailablePackageRef()
+	if availableRef.GetContext().GetNamespace() == "" || availableRef.GetIdentifier() == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "Required context or identifier not provided for the available package")
+	}
+	if request.GetTargetContext().GetNamespace() == "" || request.GetName() == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "Required target context or name not provided for the installed package")
+	}
+	contextMsg := fmt.Sprintf("(cluster=[%s], namespace=[%s])", request.TargetContext.Cluster, request.TargetContext.Namespace)
+	log.Infof("+helm CreateInstalledPackage %s", contextMsg)
+
+	unescapedChartID, err := getUnescapedChartID(availableRef.Identifier)
+	if err != nil {
+		return nil, err
+	}
+	chartIDParts := strings.Split(unescapedChartID, "/")
+
+	chartDetails := &chartutils.Details{
+		AppRepositoryResourceName:      chartIDParts[0],
+		AppRepositoryResourceNamespace: availableRef.Context.Namespace,
+		ChartName:                      chartIDParts[1],
+		ReleaseName:                    request.Name,
+		Version:                        request.GetPkgVersionReference().GetVersion(),
+		Values:                         request.Values,
+	}
+	ch, registrySecrets, err := s.fetchChartWithRegistrySecrets(ctx, chartDetails)
+	if err != nil {
+		return nil, err
+	}
+
+	actionConfig, err := s.GetActionConfig(ctx, request.TargetContext.Cluster, request.TargetContext.Namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := agent.CreateRelease(actionConfig, request.Name, request.TargetContext.Namespace, request.Values, ch, registrySecrets)
+	if err != nil {
+		return nil, statusErrorForHelmError(err, fmt.Sprintf("Unable to create release %q", request.Name))
+	}
+
+	return &corev1.CreateInstalledPackageResponse{
+		InstalledPackageRef: installedPkgRefFromRelease(request.TargetContext.Cluster, r),
+	}, nil
+}
+
+// UpdateInstalledPackage upgrades the helm release of an installed package.
+// If no version reference is included, the currently installed chart version
+// is used, which enables updating only the values of a release. The chart is
+// fetched from the repository of the available package reference if included,
+// otherwise from the only repository providing the chart version.
+func (s *Server) UpdateInstalledPackage(ctx context.Context, request *corev1.UpdateInstalledPackageRequest) (*corev1.UpdateInstalledPackageResponse, error) {
+	installedRef := request.GetInstalledPackageRef()
+	if err := validateInstalledPackageRef(installedRef); err != nil {
+		return nil, err
+	}
+	contextMsg := fmt.Sprintf("(cluster=[%s], namespace=[%s])", installedRef.Context.Cluster, installedRef.Context.Namespace)
+	log.Infof("+helm UpdateInstalledPackage %s", contextMsg)
+
+	actionConfig, err := s.GetActionConfig(ctx, installedRef.Context.Cluster, installedRef.Context.Namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := agent.GetRelease(actionConfig, installedRef.Identifier)
+	if err != nil {
+		return nil, statusErrorForHelmError(err, fmt.Sprintf("Unable to get release %q", installedRef.Identifier))
+	}
+	if existing.Chart == nil || existing.Chart.Metadata == nil {
+		return nil, status.Errorf(codes.Internal, "Unable to determine the chart for release %q", installedRef.Identifier)
+	}
+
+	version := request.GetPkgVersionReference().GetVersion()
+	if version == "" {
+		version = existing.Chart.Metadata.Version
+	}
+
+	var repoName, repoNamespace string
+	if availableRef := request.GetAvailablePackageRef(); availableRef != nil {
+		repoName, repoNamespace, err = chartRepoFromAvailablePackageRef(availableRef, existing)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		repo, err := s.chartRepoForRelease(existing, version)
+		if err != nil {
+			return nil, err
+		}
+		repoName, repoNamespace = repo.Name, repo.Namespace
+	}
+
+	chartDetails := &chartutils.Details{
+		AppRepositoryResourceName:      repoName,
+		AppRepositoryResourceNamespace: repoNamespace,
+		ChartName:                      existing.Chart.Metadata.Name,
+		ReleaseName:                    installedRef.Identifier,
+		Version:                        version,
+		Values:                         request.Values,
+	}
+	ch, registrySecrets, err := s.fetchChartWithRegistrySecrets(ctx, chartDetails)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := agent.UpgradeRelease(actionConfig, installedRef.Identifier, request.Values, ch, registrySecrets)
+	if err != nil {
+		return nil, statusErrorForHelmError(err, fmt.Sprintf("Unable to upgrade release %q", installedRef.Identifier))
+	}
+
+	return &corev1.UpdateInstalledPackageResponse{
+		InstalledPackageRef: installedPkgRefFromRelease(installedRef.Context.Cluster, r),
+	}, nil
+}
+
+// chartRepoFromAvailablePackageRef returns the name and namespace of the
+// repository of the available package reference, which must be for the
+// released chart.
+func chartRepoFromAvailablePackageRef(availableRef *corev1.AvailablePackageReference, r *release.Release) (string, string, error) {
+	if availableRef.GetContext().GetNamespace() == "" || availableRef.GetIdentifier() == "" {
+		return "", "", status.Errorf(codes.InvalidArgument, "Required context or identifier not provided for the available package")
+	}
+	unescapedChartID, err := getUnescapedChartID(availableRef.Identifier)
+	if err != nil {
+		return "", "", err
+	}
+	chartIDParts := strings.Split(unescapedChartID, "/")
+	if chartIDParts[1] != r.Chart.Metadata.Name {
+		return "", "", status.Errorf(codes.InvalidArgument, "The available package %q is not for the chart %q of release %q", availableRef.Identifier, r.Chart.Metadata.Name, r.Name)
+	}
+	return chartIDParts[0], availableRef.Context.Namespace, nil
+}
+
+// chartRepoForRelease finds the repository, available in the namespace of the
+// release, which provides the released chart with the given version. Since
+// the release does not record its repository, the repository is not guessed
+// when several provide the version.
+func (s *Server) chartRepoForRelease(r *release.Release, version string) (*models.Repo, error) {
+	charts, _, err := s.manager.GetPaginatedChartListWithFilters(utils.ChartQuery{
+		Namespace: r.Namespace,
+		ChartName: r.Chart.Metadata.Name,
+	}, 1, 0)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Unable to retrieve charts: %v", err)
+	}
+	repos := []*models.Repo{}
+	for _, ch := range charts {
+		if ch.Repo == nil {
+			continue
+		}
+		for _, cv := range ch.ChartVersions {
+			if cv.Version == version {
+				repos = append(repos, ch.Repo)
+				break
+			}
+		}
+	}
+	switch len(repos) {
+	case 0:
+		return nil, status.Errorf(codes.NotFound, "Unable to find a repository providing chart %q with version %q", r.Chart.Metadata.Name, version)
+	case 1:
+		return repos[0], nil
+	}
+	repoNames := []string{}
+	for _, repo := range repos {
+		repoNames = append(repoNames, fmt.Sprintf("%s/%s", repo.Namespace, repo.Name))
+	}
+	return nil, status.Errorf(codes.FailedPrecondition, "Several repositories (%s) provide chart %q with version %q, the available package reference is required to choose one", strings.Join(repoNames, ", "), r.Chart.Metadata.Name, version)
+}
+
+// DeleteInstalledPackage uninstalls the helm release of an installed package
+func (s *Server) DeleteInstalledPackage(ctx context.Context, request *corev1.DeleteInstalledPackageRequest) (*corev1.DeleteInstalledPackageResponse, error) {
+	installedRef := request.GetInstalledPackageRef()
+	if err := validateInstalledPackageRef(installedRef); err != nil {
+		return nil, err
+	}
+	contextMsg := fmt.Sprintf("(cluster=[%s], namespace=[%s])", installedRef.Context.Cluster, installedRef.Context.Namespace)
+	log.Infof("+helm DeleteInstalledPackage %s", contextMsg)
+
+	actionConfig, err := s.GetActionConfig(ctx, installedRef.Context.Cluster, installedRef.Context.Namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	// As with kubeops, the release history is not kept (ie. purged).
+	if err := agent.DeleteRelease(actionConfig, installedRef.Identifier, false); err != nil {
+		return nil, statusErrorForHelmError(err, fmt.Sprintf("Unable to delete release %q", installedRef.Identifier))
+	}
+
+	return &corev1.DeleteInstalledPackageResponse{}, nil
+}
+
+// RollbackInstalledPackage rolls back the helm release of an installed package to the requested revision
+func (s *Server) RollbackInstalledPackage(ctx context.Context, request *v1alpha1.RollbackInstalledPackageRequest) (*v1alpha1.RollbackInstalledPackageResponse, error) {
+	installedRef := request.GetInstalledPackageRef()
+	if err := validateInstalledPackageRef(installedRef); err != nil {
+		return nil, err
+	}
+	if request.GetReleaseRevision() <= 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "Required release revision not provided")
+	}
+	contextMsg := fmt.Sprintf("(cluster=[%s], namespace=[%s])", installedRef.Context.Cluster, installedRef.Context.Namespace)
+	log.Infof("+helm RollbackInstalledPackage %s", contextMsg)
+
+	actionConfig, err := s.GetActionConfig(ctx, installedRef.Context.Cluster, installedRef.Context.Namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := agent.RollbackRelease(actionConfig, installedRef.Identifier, int(request.ReleaseRevision))
+	if err != nil {
+		return nil, statusErrorForHelmError(err, fmt.Sprintf("Unable to rollback release %q", installedRef.Identifier))
+	}
+
+	return &v1alpha1.RollbackInstalledPackageResponse{
+		InstalledPackageRef: installedPkgRefFromRelease(installedRef.Context.Cluster, r),
+	}, nil
+}
+
+// validateInstalledPackageRef returns an error if the installed package
+// reference is missing the required fields.
+func validateInstalledPackageRef(installedRef *corev1.InstalledPackageReference) error {
+	if installedRef.GetContext().GetNamespace() == "" || installedRef.GetIdentifier() == "" {
+		return status.Errorf(codes.InvalidArgument, "Required context or identifier not provided for the installed package")
+	}
+	return nil
+}
+
+// statusErrorForHelmError returns a grpc status error for the helm error, with
+// the not found case mapped to the corresponding code.
+func statusErrorForHelmError(err error, msg string) error {
+	if errors.Is(err, driver.ErrReleaseNotFound) || strings.Contains(err.Error(), driver.ErrReleaseNotFound.Error()) {
+		return status.Errorf(codes.NotFound, "%s: %v", msg, err)
+	}
+	return status.Errorf(codes.Internal, "%s: %v", msg, err)
+}
+
+// fetchChartWithRegistrySecrets fetches the chart from the app repository
+// referenced in the chart details, together with the registry secrets per
+// domain required by the DockerSecretsPostRenderer.
+func (s *Server) fetchChartWithRegistrySecrets(ctx context.Context, chartDetails *chartutils.Details) (*chart.Chart, map[string]string, error) {
+	// The app repositories are on the cluster on which Kubeapps is installed.
+	typedClient, dynamicClient, err := s.GetClients(ctx, "")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	appRepo, caCertSecret, authSecret, err := getAppRepoAndRelatedSecrets(ctx, typedClient, dynamicClient, chartDetails.AppRepositoryResourceName, chartDetails.AppRepositoryResourceNamespace)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if s.chartClientFactory == nil {
+		return nil, nil, status.Errorf(codes.Internal, "server not configured with chartClientFactory")
+	}
+	userAgent := fmt.Sprintf("kubeapps-apis/plugins/%s/%s", pluginDetail.Name, pluginDetail.Version)
+	ch, err := handlerutil.GetChart(
+		chartDetails,
+		appRepo,
+		caCertSecret, authSecret,
+		s.chartClientFactory.New(appRepo.Spec.Type, userAgent),
+	)
+	if err != nil {
+		return nil, nil, status.Errorf(codes.Internal, "Unable to fetch the chart %q from the repository %q: %v", chartDetails.ChartName, appRepo.Name, err)
+	}
+
+	registrySecrets, err := chartutils.RegistrySecretsPerDomainWithClient(ctx, appRepo.Spec.DockerRegistrySecrets, appRepo.Namespace, typedClient)
+	if err != nil {
+		return nil, nil, status.Errorf(codes.Internal, "Unable to fetch registry secrets from the repository %q: %v", appRepo.Name, err)
+	}
+
+	return ch, registrySecrets, nil
+}
+
+// getAppRepoAndRelatedSecrets returns the AppRepository together with its
+// optional CA certificate and auth header secrets, using the user's clients.
+func getAppRepoAndRelatedSecrets(ctx context.Context, typedClient kubernetes.Interface, dynamicClient dynamic.Interface, appRepoName, appRepoNamespace string) (*appRepov1.AppRepository, *k8scorev1.Secret, *k8scorev1.Secret, error) {
+	appRepoResource := appRepov1.SchemeGroupVersion.WithResource("apprepositories")
+	unstructuredAppRepo, err := dynamicClient.Resource(appRepoResource).Namespace(appRepoNamespace).Get(ctx, appRepoName, metav1.GetOptions{})
+	if err != nil {
+		return nil, nil, nil, status.Errorf(codes.NotFound, "Unable to get app repository %s/%s: %v", appRepoNamespace, appRepoName, err)
+	}
+	appRepo := &appRepov1.AppRepository{}
+	err = k8sruntime.DefaultUnstructuredConverter.FromUnstructured(unstructuredAppRepo.UnstructuredContent(), appRepo)
+	if err != nil {
+		return nil, nil, nil, status.Errorf(codes.Internal, "Unable to convert app repository %s/%s: %v", appRepoNamespace, appRepoName, err)
+	}
+
+	auth := appRepo.Spec.Auth
+	var caCertSecret *k8scorev1.Secret
+	if auth.CustomCA != nil {
+		secretName := auth.CustomCA.SecretKeyRef.Name
+		caCertSecret, err = typedClient.CoreV1().Secrets(appRepoNamespace).Get(ctx, secretName, metav1.GetOptions{})
+		if err != nil {
+			return nil, nil, nil, status.Errorf(codes.Internal, "Unable to read secret %q: %v", secretName, err)
+		}
+	}
+
+	var authSecret *k8scorev1.Secret
+	if auth.Header != nil {
+		secretName := auth.Header.SecretKeyRef.Name
+		authSecret, err = typedClient.CoreV1().Secrets(appRepoNamespace).Get(ctx, secretName, metav1.GetOptions{})
+		if err != nil {
+			return nil, nil, nil, status.Errorf(codes.Internal, "Unable to read secret %q: %v", secretName, err)
+		}
+	}
+
+	return appRepo, caCertSecret, authSecret, nil
+}
@@ -17,6 +17,8 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"os"
 	"testing"
 
 	"github.com/DATA-DOG/go-sqlmock"
@@ -26,12 +28,23 @@ import (
 	"github.com/kubeapps/kubeapps/cmd/assetsvc/pkg/utils"
 	corev1 "github.com/kubeapps/kubeapps/cmd/kubeapps-apis/gen/core/packages/v1alpha1"
 	plugins "github.com/kubeapps/kubeapps/cmd/kubeapps-apis/gen/core/plugins/v1alpha1"
+	"github.com/kubeapps/kubeapps/cmd/kubeapps-apis/gen/plugins/helm/packages/v1alpha1"
 	"github.com/kubeapps/kubeapps/cmd/kubeapps-apis/server"
 	"github.com/kubeapps/kubeapps/pkg/chart/models"
 	"github.com/kubeapps/kubeapps/pkg/dbutils"
+	fakeHandlerUtils "github.com/kubeapps/kubeapps/pkg/handlerutil/fake"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"helm.sh/helm/v3/pkg/action"
+	helmchart "helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+	kubefake "helm.sh/helm/v3/pkg/kube/fake"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage"
+	"helm.sh/helm/v3/pkg/storage/driver"
 	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/dynamic"
@@ -56,7 +69,7 @@ func setMockManager(t *testing.T) (sqlmock.Sqlmock, func(), utils.AssetManager)
 	if err != nil {
 		t.Fatalf("%+v", err)
 	}
-	manager = &utils.PostgresAssetManager{&dbutils.PostgresAssetManager{DB: db, KubeappsNamespace: globalPackagingNamespace}}
+	manager = &utils.PostgresAssetManager{PostgresAssetManagerIface: &dbutils.PostgresAssetManager{DB: db, KubeappsNamespace: globalPackagingNamespace}}
 	return mock, func() { db.Close() }, manager
 }
 
@@ -66,7 +79,7 @@ func TestGetClient(t *testing.T) {
 	if err != nil {
 		log.Fatalf("%s", err)
 	}
-	clientGetter := func(context.Context) (kubernetes.Interface, dynamic.Interface, error) {
+	clientGetter := func(context.Context, string) (kubernetes.Interface, dynamic.Interface, error) {
 		return typfake.NewSimpleClientset(), dynfake.NewSimpleDynamicClientWithCustomListKinds(
 			runtime.NewScheme(),
 			map[schema.GroupVersionResource]string{
@@ -106,7 +119,7 @@ func TestGetClient(t *testing.T) {
 		{
 			name:    "it returns failed-precondition when configGetter itself errors",
 			manager: manager,
-			clientGetter: func(context.Context) (kubernetes.Interface, dynamic.Interface, error) {
+			clientGetter: func(context.Context, string) (kubernetes.Interface, dynamic.Interface, error) {
 				return nil, nil, fmt.Errorf("Bang!")
 			},
 			statusCodeClient:  codes.FailedPrecondition,
@@ -123,7 +136,7 @@ func TestGetClient(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			s := Server{clientGetter: tc.clientGetter, manager: tc.manager}
 
-			typedClient, dynamicClient, errClient := s.GetClients(context.Background())
+			typedClient, dynamicClient, errClient := s.GetClients(context.Background(), "")
 
 			if got, want := status.Code(errClient), tc.statusCodeClient; got != want {
 				t.Errorf("got: %+v, want: %+v", got, want)
@@ -148,6 +161,119 @@ func TestGetClient(t *testing.T) {
 	}
 }
 
+func TestNewPluginConfig(t *testing.T) {
+	testCases := []struct {
+		name           string
+		env            map[string]string
+		config         server.PluginConfig
+		expectedConfig *pluginConfig
+		expectedErr    bool
+	}{
+		{
+			name: "it reads the database configuration from the config file",
+			config: server.PluginConfig{
+				"database": map[string]interface{}{
+					"url":      "kubeapps-postgresql-headless:5432",
+					"name":     "assets",
+					"username": "postgres",
+					"password": "db-password",
+				},
+				"helmdriver": "configmap",
+			},
+			expectedConfig: &pluginConfig{
+				Database: databaseConfig{
+					URL:      "kubeapps-postgresql-headless:5432",
+					Name:     "assets",
+					Username: "postgres",
+					Password: "db-password",
+				},
+				HelmDriver: "configmap",
+			},
+		},
+		{
+			name: "it defaults to the environment for values not in the config file",
+			env: map[string]string{
+				"ASSET_SYNCER_DB_URL":          "localhost:5432",
+				"ASSET_SYNCER_DB_NAME":         "assets",
+				"ASSET_SYNCER_DB_USERPASSWORD": "password-from-secret",
+			},
+			config: server.PluginConfig{
+				"database": map[string]interface{}{
+					"url":      "kubeapps-postgresql-headless:5432",
+					"username": "postgres",
+				},
+			},
+			expectedConfig: &pluginConfig{
+				Database: databaseConfig{
+					URL:      "kubeapps-postgresql-headless:5432",
+					Name:     "assets",
+					Username: "postgres",
+					Password: "password-from-secret",
+				},
+			},
+		},
+		{
+			name: "it returns an error without a database name",
+			config: server.PluginConfig{
+				"database": map[string]interface{}{
+					"url": "kubeapps-postgresql-headless:5432",
+				},
+			},
+			expectedErr: true,
+		},
+		{
+			name: "it returns an error for an invalid helm driver",
+			config: server.PluginConfig{
+				"database": map[string]interface{}{
+					"url":  "kubeapps-postgresql-headless:5432",
+					"name": "assets",
+				},
+				"helmdriver": "sql",
+			},
+			expectedErr: true,
+		},
+		{
+			name: "it returns an error for unknown keys",
+			config: server.PluginConfig{
+				"db": map[string]interface{}{
+					"url": "kubeapps-postgresql-headless:5432",
+				},
+			},
+			expectedErr: true,
+		},
+	}
+
+	envNames := []string{"ASSET_SYNCER_DB_URL", "ASSET_SYNCER_DB_NAME", "ASSET_SYNCER_DB_USERNAME", "ASSET_SYNCER_DB_USERPASSWORD", "HELM_DRIVER"}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, name := range envNames {
+				value, ok := tc.env[name]
+				if ok {
+					os.Setenv(name, value)
+				} else {
+					os.Unsetenv(name)
+				}
+				defer os.Unsetenv(name)
+			}
+
+			config, err := newPluginConfig(tc.config)
+			if got, want := err != nil, tc.expectedErr; got != want {
+				t.Fatalf("got error: %v, want error: %t", err, want)
+			}
+			if tc.expectedErr {
+				if got, want := status.Code(err), codes.FailedPrecondition; got != want {
+					t.Errorf("got: %+v, want: %+v", got, want)
+				}
+				return
+			}
+
+			if got, want := config, tc.expectedConfig; !cmp.Equal(want, got) {
+				t.Errorf("mismatch (-want +got):\n%s", cmp.Diff(want, got))
+			}
+		})
+	}
+}
+
 func TestIsValidChart(t *testing.T) {
 	testCases := []struct {
 		name     string
@@ -309,10 +435,12 @@ func TestAvailablePackageSummaryFromChart(t *testing.T) {
 				},
 			},
 			expected: &corev1.AvailablePackageSummary{
+				Name:             "foo",
 				DisplayName:      "foo",
 				LatestPkgVersion: "3.0.0",
 				IconUrl:          "foo.bar/icon.svg",
 				ShortDescription: "best chart",
+				Repository:       "bar",
 				AvailablePackageRef: &corev1.AvailablePackageReference{
 					Context:    &corev1.Context{Namespace: "my-ns"},
 					Identifier: "foo/bar",
@@ -337,8 +465,10 @@ func TestAvailablePackageSummaryFromChart(t *testing.T) {
 				},
 			},
 			expected: &corev1.AvailablePackageSummary{
+				Name:             "foo",
 				DisplayName:      "foo",
 				LatestPkgVersion: "3.0.0",
+				Repository:       "bar",
 				AvailablePackageRef: &corev1.AvailablePackageReference{
 					Context:    &corev1.Context{Namespace: "my-ns"},
 					Identifier: "foo/bar",
@@ -428,7 +558,7 @@ func makeChartRowsJSON(t *testing.T, charts []*models.Chart, pageToken string, p
 		if pageSize == 0 {
 			t.Fatalf("pagesize must be > 0 when using a page token")
 		}
-		rowsJSON = rowsJSON[((pageOffset - 1) * pageSize):]
+		rowsJSON = rowsJSON[pageOffset:]
 	}
 	if pageSize > 0 && pageSize < len(rowsJSON) {
 		rowsJSON = rowsJSON[0:pageSize]
@@ -453,7 +583,7 @@ func makeServer(t *testing.T, authorized bool) (*Server, sqlmock.Sqlmock, func()
 			Status: authorizationv1.SubjectAccessReviewStatus{Allowed: authorized},
 		}, nil
 	})
-	clientGetter := func(context.Context) (kubernetes.Interface, dynamic.Interface, error) {
+	clientGetter := func(context.Context, string) (kubernetes.Interface, dynamic.Interface, error) {
 		return clientSet, dynamicClient, nil
 	}
 
@@ -467,6 +597,77 @@ func makeServer(t *testing.T, authorized bool) (*Server, sqlmock.Sqlmock, func()
 	}, mock, cleanup
 }
 
+func TestCheckDependencies(t *testing.T) {
+	testCases := []struct {
+		name                 string
+		dbErr                error
+		expectedDependencies []*plugins.PluginDependencyStatus
+	}{
+		{
+			name: "reports postgresql reachable",
+			expectedDependencies: []*plugins.PluginDependencyStatus{
+				{Name: "postgresql", Reachable: true},
+			},
+		},
+		{
+			name:  "reports postgresql unreachable when the query fails",
+			dbErr: fmt.Errorf("connection refused"),
+			expectedDependencies: []*plugins.PluginDependencyStatus{
+				{Name: "postgresql", Message: "connection refused"},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mock, cleanup, manager := setMockManager(t)
+			defer cleanup()
+			query := mock.ExpectQuery("SELECT info FROM")
+			if tc.dbErr != nil {
+				query.WillReturnError(tc.dbErr)
+			} else {
+				query.WillReturnRows(sqlmock.NewRows([]string{"info"}))
+			}
+			s := &Server{
+				manager:                  manager,
+				globalPackagingNamespace: globalPackagingNamespace,
+			}
+
+			dependencies := s.CheckDependencies(context.Background())
+
+			opts := cmpopts.IgnoreUnexported(plugins.PluginDependencyStatus{})
+			if got, want := dependencies, tc.expectedDependencies; !cmp.Equal(want, got, opts) {
+				t.Errorf("mismatch (-want +got):\n%s", cmp.Diff(want, got, opts))
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("%v", err)
+			}
+		})
+	}
+}
+
+func TestInstrumentedAssetManager(t *testing.T) {
+	mock, cleanup, manager := setMockManager(t)
+	defer cleanup()
+	instrumented := &instrumentedAssetManager{manager}
+	dbQueryDuration.Reset()
+
+	mock.ExpectQuery("SELECT info FROM").WillReturnRows(sqlmock.NewRows([]string{"info"}))
+	mock.ExpectQuery("SELECT info FROM").WillReturnError(fmt.Errorf("connection refused"))
+	for i := 0; i < 2; i++ {
+		// the error is only recorded in the metrics
+		_, _ = instrumented.GetChartListWithFiltersFromOffset(utils.ChartQuery{Namespace: globalPackagingNamespace}, utils.ChartOrder{}, 0, 1)
+	}
+
+	// one series for the successful query and one for the failed one
+	if got, want := testutil.CollectAndCount(dbQueryDuration), 2; got != want {
+		t.Errorf("got: %d series, want: %d", got, want)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("%v", err)
+	}
+}
+
 func TestGetAvailablePackageSummaries(t *testing.T) {
 	testCases := []struct {
 		name             string
@@ -494,10 +695,12 @@ func TestGetAvailablePackageSummaries(t *testing.T) {
 			expectedResponse: &corev1.GetAvailablePackageSummariesResponse{
 				AvailablePackagesSummaries: []*corev1.AvailablePackageSummary{
 					{
+						Name:             "chart-1",
 						DisplayName:      "chart-1",
 						LatestPkgVersion: "3.0.0",
 						IconUrl:          DefaultChartIconURL,
 						ShortDescription: DefaultChartDescription,
+						Repository:       "repo-1",
 						AvailablePackageRef: &corev1.AvailablePackageReference{
 							Context:    &corev1.Context{Namespace: "my-ns"},
 							Identifier: "repo-1/chart-1",
@@ -505,10 +708,12 @@ func TestGetAvailablePackageSummaries(t *testing.T) {
 						},
 					},
 					{
+						Name:             "chart-2",
 						DisplayName:      "chart-2",
 						LatestPkgVersion: "2.0.0",
 						IconUrl:          DefaultChartIconURL,
 						ShortDescription: DefaultChartDescription,
+						Repository:       "repo-1",
 						AvailablePackageRef: &corev1.AvailablePackageReference{
 							Context:    &corev1.Context{Namespace: "my-ns"},
 							Identifier: "repo-1/chart-2",
@@ -536,10 +741,12 @@ func TestGetAvailablePackageSummaries(t *testing.T) {
 			expectedResponse: &corev1.GetAvailablePackageSummariesResponse{
 				AvailablePackagesSummaries: []*corev1.AvailablePackageSummary{
 					{
+						Name:             "chart-1",
 						DisplayName:      "chart-1",
 						LatestPkgVersion: "3.0.0",
 						IconUrl:          DefaultChartIconURL,
 						ShortDescription: DefaultChartDescription,
+						Repository:       "repo-1",
 						AvailablePackageRef: &corev1.AvailablePackageReference{
 							Context:    &corev1.Context{Namespace: "my-ns"},
 							Identifier: "repo-1/chart-1",
@@ -547,10 +754,12 @@ func TestGetAvailablePackageSummaries(t *testing.T) {
 						},
 					},
 					{
+						Name:             "chart-2",
 						DisplayName:      "chart-2",
 						LatestPkgVersion: "2.0.0",
 						IconUrl:          DefaultChartIconURL,
 						ShortDescription: DefaultChartDescription,
+						Repository:       "repo-1",
 						AvailablePackageRef: &corev1.AvailablePackageReference{
 							Context:    &corev1.Context{Namespace: "my-ns"},
 							Identifier: "repo-1/chart-2",
@@ -607,7 +816,7 @@ func TestGetAvailablePackageSummaries(t *testing.T) {
 					Namespace: globalPackagingNamespace,
 				},
 				PaginationOptions: &corev1.PaginationOptions{
-					PageToken: "2",
+					PageToken: "1",
 					PageSize:  1,
 				},
 			},
@@ -620,10 +829,12 @@ func TestGetAvailablePackageSummaries(t *testing.T) {
 			expectedResponse: &corev1.GetAvailablePackageSummariesResponse{
 				AvailablePackagesSummaries: []*corev1.AvailablePackageSummary{
 					{
+						Name:             "chart-2",
 						DisplayName:      "chart-2",
 						LatestPkgVersion: "2.0.0",
 						IconUrl:          DefaultChartIconURL,
 						ShortDescription: DefaultChartDescription,
+						Repository:       "repo-1",
 						AvailablePackageRef: &corev1.AvailablePackageReference{
 							Context:    &corev1.Context{Namespace: "my-ns"},
 							Identifier: "repo-1/chart-2",
@@ -631,7 +842,7 @@ func TestGetAvailablePackageSummaries(t *testing.T) {
 						},
 					},
 				},
-				NextPageToken: "3",
+				NextPageToken: "2",
 			},
 		},
 		{
@@ -642,8 +853,8 @@ func TestGetAvailablePackageSummaries(t *testing.T) {
 					Cluster:   "",
 					Namespace: globalPackagingNamespace,
 				},
-				// Start on page two with two results per page, which in this input
-				// corresponds only to the third chart.
+				// Start after the first two results with two results per page, which
+				// in this input corresponds only to the third chart.
 				PaginationOptions: &corev1.PaginationOptions{
 					PageToken: "2",
 					PageSize:  2,
@@ -658,10 +869,12 @@ func TestGetAvailablePackageSummaries(t *testing.T) {
 			expectedResponse: &corev1.GetAvailablePackageSummariesResponse{
 				AvailablePackagesSummaries: []*corev1.AvailablePackageSummary{
 					{
+						Name:             "chart-3",
 						DisplayName:      "chart-3",
 						LatestPkgVersion: "1.0.0",
 						IconUrl:          DefaultChartIconURL,
 						ShortDescription: DefaultChartDescription,
+						Repository:       "repo-1",
 						AvailablePackageRef: &corev1.AvailablePackageReference{
 							Context:    &corev1.Context{Namespace: "my-ns"},
 							Identifier: "repo-1/chart-3",
@@ -709,11 +922,6 @@ func TestGetAvailablePackageSummaries(t *testing.T) {
 				mock.ExpectQuery("SELECT info FROM").
 					WithArgs(tc.request.Context.Namespace, server.globalPackagingNamespace).
 					WillReturnRows(rows)
-				if tc.request.GetPaginationOptions().GetPageSize() > 0 {
-					mock.ExpectQuery("SELECT count").
-						WithArgs(tc.request.Context.Namespace, server.globalPackagingNamespace).
-						WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
-				}
 			}
 			availablePackageSummaries, err := server.GetAvailablePackageSummaries(context.Background(), tc.request)
 
@@ -735,6 +943,51 @@ func TestGetAvailablePackageSummaries(t *testing.T) {
 	}
 }
 
+func TestChartOrderFromSortOptions(t *testing.T) {
+	testCases := []struct {
+		name     string
+		options  *corev1.SortOptions
+		expected utils.ChartOrder
+	}{
+		{
+			name:     "it orders by name without sort options",
+			expected: utils.ChartOrder{Field: utils.ChartOrderByName},
+		},
+		{
+			name: "it orders by name when sorting by display name",
+			options: &corev1.SortOptions{
+				Field:     corev1.SortOptions_SORT_FIELD_DISPLAY_NAME,
+				Direction: corev1.SortOptions_SORT_DIRECTION_DESCENDING,
+			},
+			expected: utils.ChartOrder{Field: utils.ChartOrderByName, Descending: true},
+		},
+		{
+			name: "it orders by latest version",
+			options: &corev1.SortOptions{
+				Field:     corev1.SortOptions_SORT_FIELD_LATEST_VERSION,
+				Direction: corev1.SortOptions_SORT_DIRECTION_ASCENDING,
+			},
+			expected: utils.ChartOrder{Field: utils.ChartOrderByLatestVersion},
+		},
+		{
+			name: "it orders by repository",
+			options: &corev1.SortOptions{
+				Field:     corev1.SortOptions_SORT_FIELD_REPOSITORY,
+				Direction: corev1.SortOptions_SORT_DIRECTION_DESCENDING,
+			},
+			expected: utils.ChartOrder{Field: utils.ChartOrderByRepository, Descending: true},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got, want := chartOrderFromSortOptions(tc.options), tc.expected; !cmp.Equal(got, want) {
+				t.Errorf("mismatch (-want +got):\n%s", cmp.Diff(want, got))
+			}
+		})
+	}
+}
+
 func TestAvailablePackageDetailFromChart(t *testing.T) {
 	testCases := []struct {
 		name       string
@@ -1065,160 +1318,907 @@ func TestGetAvailablePackageVersions(t *testing.T) {
 	}
 }
 
-func TestPackageAppVersionsSummary(t *testing.T) {
+type releaseStub struct {
+	name         string
+	namespace    string
+	chartName    string
+	chartVersion string
+	appVersion   string
+	version      int
+	status       release.Status
+	notes        string
+	values       map[string]interface{}
+}
+
+func releaseForStub(r releaseStub) *release.Release {
+	return &release.Release{
+		Name:      r.name,
+		Namespace: r.namespace,
+		Version:   r.version,
+		Info: &release.Info{
+			Status: r.status,
+			Notes:  r.notes,
+		},
+		Chart: &helmchart.Chart{
+			Metadata: &helmchart.Metadata{
+				Name:        r.chartName,
+				Version:     r.chartVersion,
+				AppVersion:  r.appVersion,
+				Icon:        DefaultChartIconURL,
+				Description: DefaultChartDescription,
+			},
+		},
+		Config: r.values,
+	}
+}
+
+// newActionConfigFixture returns an action.Configuration with fake clients
+// and memory storage containing the given releases.
+func newActionConfigFixture(t *testing.T, namespace string, rels []releaseStub) *action.Configuration {
+	t.Helper()
+
+	memDriver := driver.NewMemory()
+	actionConfig := &action.Configuration{
+		Releases:     storage.Init(memDriver),
+		KubeClient:   &kubefake.FailingKubeClient{PrintingKubeClient: kubefake.PrintingKubeClient{Out: ioutil.Discard}},
+		Capabilities: chartutil.DefaultCapabilities,
+		Log: func(format string, v ...interface{}) {
+			t.Helper()
+			t.Logf(format, v...)
+		},
+	}
+	for _, r := range rels {
+		if err := actionConfig.Releases.Create(releaseForStub(r)); err != nil {
+			t.Fatalf("%+v", err)
+		}
+	}
+	// It is the namespace of the storage driver which determines the
+	// releases returned, as with the secrets driver used in production.
+	memDriver.SetNamespace(namespace)
+	return actionConfig
+}
+
+// makeServerWithActionConfig returns a server which uses the provided action
+// config, a fake chart client and the given app repositories.
+func makeServerWithActionConfig(t *testing.T, actionConfig *action.Configuration, appRepos ...*unstructured.Unstructured) (*Server, sqlmock.Sqlmock, func()) {
+	server, mock, cleanup := makeServer(t, true)
+
+	objects := []runtime.Object{}
+	for _, r := range appRepos {
+		objects = append(objects, r)
+	}
+	dynamicClient := dynfake.NewSimpleDynamicClientWithCustomListKinds(
+		runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{
+			{Group: "kubeapps.com", Version: "v1alpha1", Resource: "apprepositories"}: "AppRepositoryList",
+		},
+		objects...,
+	)
+	server.clientGetter = func(context.Context, string) (kubernetes.Interface, dynamic.Interface, error) {
+		return typfake.NewSimpleClientset(), dynamicClient, nil
+	}
+	server.actionConfigGetter = func(context.Context, string, string) (*action.Configuration, error) {
+		return actionConfig, nil
+	}
+	server.chartClientFactory = &fakeHandlerUtils.ClientResolver{}
+	return server, mock, cleanup
+}
+
+func newAppRepo(name, namespace string) *unstructured.Unstructured {
+	return &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "kubeapps.com/v1alpha1",
+			"kind":       "AppRepository",
+			"metadata": map[string]interface{}{
+				"name":      name,
+				"namespace": namespace,
+			},
+			"spec": map[string]interface{}{
+				"type": "helm",
+				"url":  "https://example.com/charts",
+			},
+		},
+	}
+}
+
+var installedPkgOpts = cmpopts.IgnoreUnexported(
+	corev1.GetInstalledPackageSummariesResponse{},
+	corev1.GetInstalledPackageDetailResponse{},
+	corev1.InstalledPackageSummary{},
+	corev1.InstalledPackageDetail{},
+	corev1.InstalledPackageReference{},
+	corev1.InstalledPackageStatus{},
+	corev1.VersionReference{},
+	corev1.PackageAppVersion{},
+	corev1.Context{},
+	plugins.Plugin{},
+)
+
+func TestGetInstalledPackageSummaries(t *testing.T) {
 	testCases := []struct {
-		name            string
-		chart_versions  []models.ChartVersion
-		version_summary []*corev1.GetAvailablePackageVersionsResponse_PackageAppVersion
+		name             string
+		request          *corev1.GetInstalledPackageSummariesRequest
+		existingReleases []releaseStub
+		expectedStatus   codes.Code
+		expectedResponse *corev1.GetInstalledPackageSummariesResponse
+	}{
+		{
+			name: "returns the installed packages in the namespace",
+			request: &corev1.GetInstalledPackageSummariesRequest{
+				Context: &corev1.Context{Namespace: "default"},
+			},
+			existingReleases: []releaseStub{
+				{
+					name:         "my-apache",
+					namespace:    "default",
+					chartName:    "apache",
+					chartVersion: "1.2.3",
+					appVersion:   DefaultAppVersion,
+					version:      1,
+					status:       release.StatusDeployed,
+				},
+				{
+					name:         "my-failed-apache",
+					namespace:    "default",
+					chartName:    "apache",
+					chartVersion: "1.2.4",
+					appVersion:   DefaultAppVersion,
+					version:      1,
+					status:       release.StatusFailed,
+				},
+				{
+					name:         "other-apache",
+					namespace:    "other-namespace",
+					chartName:    "apache",
+					chartVersion: "1.2.3",
+					appVersion:   DefaultAppVersion,
+					version:      1,
+					status:       release.StatusDeployed,
+				},
+			},
+			expectedResponse: &corev1.GetInstalledPackageSummariesResponse{
+				InstalledPackagesSummaries: []*corev1.InstalledPackageSummary{
+					{
+						InstalledPackageRef: &corev1.InstalledPackageReference{
+							Context:    &corev1.Context{Namespace: "default"},
+							Identifier: "my-apache",
+							Plugin:     GetPluginDetail(),
+						},
+						Name:                "my-apache",
+						PkgVersionReference: &corev1.VersionReference{Version: "1.2.3"},
+						CurrentVersion: &corev1.PackageAppVersion{
+							PkgVersion: "1.2.3",
+							AppVersion: DefaultAppVersion,
+						},
+						IconUrl:          DefaultChartIconURL,
+						PkgDisplayName:   "apache",
+						ShortDescription: DefaultChartDescription,
+						Status: &corev1.InstalledPackageStatus{
+							Ready:      true,
+							Reason:     corev1.InstalledPackageStatus_STATUS_REASON_INSTALLED,
+							UserReason: "deployed",
+						},
+					},
+					{
+						InstalledPackageRef: &corev1.InstalledPackageReference{
+							Context:    &corev1.Context{Namespace: "default"},
+							Identifier: "my-failed-apache",
+							Plugin:     GetPluginDetail(),
+						},
+						Name:                "my-failed-apache",
+						PkgVersionReference: &corev1.VersionReference{Version: "1.2.4"},
+						CurrentVersion: &corev1.PackageAppVersion{
+							PkgVersion: "1.2.4",
+							AppVersion: DefaultAppVersion,
+						},
+						IconUrl:          DefaultChartIconURL,
+						PkgDisplayName:   "apache",
+						ShortDescription: DefaultChartDescription,
+						Status: &corev1.InstalledPackageStatus{
+							Reason:     corev1.InstalledPackageStatus_STATUS_REASON_FAILED,
+							UserReason: "failed",
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "returns a next page token when the results are a full page",
+			request: &corev1.GetInstalledPackageSummariesRequest{
+				Context: &corev1.Context{Namespace: "default"},
+				PaginationOptions: &corev1.PaginationOptions{
+					PageSize: 1,
+				},
+			},
+			existingReleases: []releaseStub{
+				{
+					name:         "my-apache",
+					namespace:    "default",
+					chartName:    "apache",
+					chartVersion: "1.2.3",
+					appVersion:   DefaultAppVersion,
+					version:      1,
+					status:       release.StatusDeployed,
+				},
+				{
+					name:         "my-other-apache",
+					namespace:    "default",
+					chartName:    "apache",
+					chartVersion: "1.2.3",
+					appVersion:   DefaultAppVersion,
+					version:      1,
+					status:       release.StatusDeployed,
+				},
+			},
+			expectedResponse: &corev1.GetInstalledPackageSummariesResponse{
+				InstalledPackagesSummaries: []*corev1.InstalledPackageSummary{
+					{
+						InstalledPackageRef: &corev1.InstalledPackageReference{
+							Context:    &corev1.Context{Namespace: "default"},
+							Identifier: "my-apache",
+							Plugin:     GetPluginDetail(),
+						},
+						Name:                "my-apache",
+						PkgVersionReference: &corev1.VersionReference{Version: "1.2.3"},
+						CurrentVersion: &corev1.PackageAppVersion{
+							PkgVersion: "1.2.3",
+							AppVersion: DefaultAppVersion,
+						},
+						IconUrl:          DefaultChartIconURL,
+						PkgDisplayName:   "apache",
+						ShortDescription: DefaultChartDescription,
+						Status: &corev1.InstalledPackageStatus{
+							Ready:      true,
+							Reason:     corev1.InstalledPackageStatus_STATUS_REASON_INSTALLED,
+							UserReason: "deployed",
+						},
+					},
+				},
+				NextPageToken: "1",
+			},
+		},
+		{
+			name: "returns the results from the offset in the page token",
+			request: &corev1.GetInstalledPackageSummariesRequest{
+				Context: &corev1.Context{Namespace: "default"},
+				PaginationOptions: &corev1.PaginationOptions{
+					PageToken: "1",
+					PageSize:  1,
+				},
+			},
+			existingReleases: []releaseStub{
+				{
+					name:         "my-apache",
+					namespace:    "default",
+					chartName:    "apache",
+					chartVersion: "1.2.3",
+					appVersion:   DefaultAppVersion,
+					version:      1,
+					status:       release.StatusDeployed,
+				},
+				{
+					name:         "my-other-apache",
+					namespace:    "default",
+					chartName:    "apache",
+					chartVersion: "1.2.3",
+					appVersion:   DefaultAppVersion,
+					version:      1,
+					status:       release.StatusDeployed,
+				},
+			},
+			expectedResponse: &corev1.GetInstalledPackageSummariesResponse{
+				InstalledPackagesSummaries: []*corev1.InstalledPackageSummary{
+					{
+						InstalledPackageRef: &corev1.InstalledPackageReference{
+							Context:    &corev1.Context{Namespace: "default"},
+							Identifier: "my-other-apache",
+							Plugin:     GetPluginDetail(),
+						},
+						Name:                "my-other-apache",
+						PkgVersionReference: &corev1.VersionReference{Version: "1.2.3"},
+						CurrentVersion: &corev1.PackageAppVersion{
+							PkgVersion: "1.2.3",
+							AppVersion: DefaultAppVersion,
+						},
+						IconUrl:          DefaultChartIconURL,
+						PkgDisplayName:   "apache",
+						ShortDescription: DefaultChartDescription,
+						Status: &corev1.InstalledPackageStatus{
+							Ready:      true,
+							Reason:     corev1.InstalledPackageStatus_STATUS_REASON_INSTALLED,
+							UserReason: "deployed",
+						},
+					},
+				},
+				NextPageToken: "2",
+			},
+		},
+		{
+			name: "returns installed packages referencing the requested cluster",
+			request: &corev1.GetInstalledPackageSummariesRequest{
+				Context: &corev1.Context{Cluster: "other", Namespace: "default"},
+			},
+			existingReleases: []releaseStub{
+				{
+					name:         "my-apache",
+					namespace:    "default",
+					chartName:    "apache",
+					chartVersion: "1.2.3",
+					appVersion:   DefaultAppVersion,
+					version:      1,
+					status:       release.StatusDeployed,
+				},
+			},
+			expectedResponse: &corev1.GetInstalledPackageSummariesResponse{
+				InstalledPackagesSummaries: []*corev1.InstalledPackageSummary{
+					{
+						InstalledPackageRef: &corev1.InstalledPackageReference{
+							Context:    &corev1.Context{Cluster: "other", Namespace: "default"},
+							Identifier: "my-apache",
+							Plugin:     GetPluginDetail(),
+						},
+						Name:                "my-apache",
+						PkgVersionReference: &corev1.VersionReference{Version: "1.2.3"},
+						CurrentVersion: &corev1.PackageAppVersion{
+							PkgVersion: "1.2.3",
+							AppVersion: DefaultAppVersion,
+						},
+						IconUrl:          DefaultChartIconURL,
+						PkgDisplayName:   "apache",
+						ShortDescription: DefaultChartDescription,
+						Status: &corev1.InstalledPackageStatus{
+							Ready:      true,
+							Reason:     corev1.InstalledPackageStatus_STATUS_REASON_INSTALLED,
+							UserReason: "deployed",
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "returns an invalid argument error for an invalid page token",
+			request: &corev1.GetInstalledPackageSummariesRequest{
+				Context: &corev1.Context{Namespace: "default"},
+				PaginationOptions: &corev1.PaginationOptions{
+					PageToken: "not-a-number",
+					PageSize:  1,
+				},
+			},
+			expectedStatus: codes.InvalidArgument,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			actionConfig := newActionConfigFixture(t, tc.request.GetContext().GetNamespace(), tc.existingReleases)
+			server, _, cleanup := makeServerWithActionConfig(t, actionConfig)
+			defer cleanup()
+
+			response, err := server.GetInstalledPackageSummaries(context.Background(), tc.request)
+
+			if got, want := status.Code(err), tc.expectedStatus; got != want {
+				t.Fatalf("got: %+v, want: %+v, err: %+v", got, want, err)
+			}
+			if tc.expectedStatus != codes.OK {
+				return
+			}
+
+			if got, want := response, tc.expectedResponse; !cmp.Equal(want, got, installedPkgOpts) {
+				t.Errorf("mismatch (-want +got):\n%s", cmp.Diff(want, got, installedPkgOpts))
+			}
+		})
+	}
+}
+
+func TestGetInstalledPackageDetail(t *testing.T) {
+	existingReleases := []releaseStub{
+		{
+			name:         "my-apache",
+			namespace:    "default",
+			chartName:    "apache",
+			chartVersion: "1.2.3",
+			appVersion:   DefaultAppVersion,
+			version:      1,
+			status:       release.StatusDeployed,
+			notes:        "some notes",
+			values:       map[string]interface{}{"replicaCount": 2},
+		},
+	}
+
+	testCases := []struct {
+		name             string
+		request          *corev1.GetInstalledPackageDetailRequest
+		expectedStatus   codes.Code
+		expectedResponse *corev1.GetInstalledPackageDetailResponse
 	}{
 		{
-			name: "it includes the latest three major versions only",
-			chart_versions: []models.ChartVersion{
-				{Version: "8.5.6", AppVersion: DefaultAppVersion},
-				{Version: "7.5.6", AppVersion: DefaultAppVersion},
-				{Version: "6.5.6", AppVersion: DefaultAppVersion},
-				{Version: "5.5.6", AppVersion: DefaultAppVersion},
-			},
-			version_summary: []*corev1.GetAvailablePackageVersionsResponse_PackageAppVersion{
-				{PkgVersion: "8.5.6", AppVersion: DefaultAppVersion},
-				{PkgVersion: "7.5.6", AppVersion: DefaultAppVersion},
-				{PkgVersion: "6.5.6", AppVersion: DefaultAppVersion},
-			},
-		},
-		{
-			name: "it includes the latest three minor versions for each major version only",
-			chart_versions: []models.ChartVersion{
-				{Version: "8.5.6", AppVersion: DefaultAppVersion},
-				{Version: "8.4.6", AppVersion: DefaultAppVersion},
-				{Version: "8.3.6", AppVersion: DefaultAppVersion},
-				{Version: "8.2.6", AppVersion: DefaultAppVersion},
-			},
-			version_summary: []*corev1.GetAvailablePackageVersionsResponse_PackageAppVersion{
-				{PkgVersion: "8.5.6", AppVersion: DefaultAppVersion},
-				{PkgVersion: "8.4.6", AppVersion: DefaultAppVersion},
-				{PkgVersion: "8.3.6", AppVersion: DefaultAppVersion},
-			},
-		},
-		{
-			name: "it includes the latest three patch versions for each minor version only",
-			chart_versions: []models.ChartVersion{
-				{Version: "8.5.6", AppVersion: DefaultAppVersion},
-				{Version: "8.5.5", AppVersion: DefaultAppVersion},
-				{Version: "8.5.4", AppVersion: DefaultAppVersion},
-				{Version: "8.5.3", AppVersion: DefaultAppVersion},
-			},
-			version_summary: []*corev1.GetAvailablePackageVersionsResponse_PackageAppVersion{
-				{PkgVersion: "8.5.6", AppVersion: DefaultAppVersion},
-				{PkgVersion: "8.5.5", AppVersion: DefaultAppVersion},
-				{PkgVersion: "8.5.4", AppVersion: DefaultAppVersion},
-			},
-		},
-		{
-			name: "it includes the latest three patch versions of the latest three minor versions of the latest three major versions only",
-			chart_versions: []models.ChartVersion{
-				{Version: "8.5.6", AppVersion: DefaultAppVersion},
-				{Version: "8.5.5", AppVersion: DefaultAppVersion},
-				{Version: "8.5.4", AppVersion: DefaultAppVersion},
-				{Version: "8.5.3", AppVersion: DefaultAppVersion},
-				{Version: "8.4.6", AppVersion: DefaultAppVersion},
-				{Version: "8.4.5", AppVersion: DefaultAppVersion},
-				{Version: "8.4.4", AppVersion: DefaultAppVersion},
-				{Version: "8.4.3", AppVersion: DefaultAppVersion},
-				{Version: "8.3.6", AppVersion: DefaultAppVersion},
-				{Version: "8.3.5", AppVersion: DefaultAppVersion},
-				{Version: "8.3.4", AppVersion: DefaultAppVersion},
-				{Version: "8.3.3", AppVersion: DefaultAppVersion},
-				{Version: "8.2.6", AppVersion: DefaultAppVersion},
-				{Version: "8.2.5", AppVersion: DefaultAppVersion},
-				{Version: "8.2.4", AppVersion: DefaultAppVersion},
-				{Version: "8.2.3", AppVersion: DefaultAppVersion},
-				{Version: "6.5.6", AppVersion: DefaultAppVersion},
-				{Version: "6.5.5", AppVersion: DefaultAppVersion},
-				{Version: "6.5.4", AppVersion: DefaultAppVersion},
-				{Version: "6.5.3", AppVersion: DefaultAppVersion},
-				{Version: "6.4.6", AppVersion: DefaultAppVersion},
-				{Version: "6.4.5", AppVersion: DefaultAppVersion},
-				{Version: "6.4.4", AppVersion: DefaultAppVersion},
-				{Version: "6.4.3", AppVersion: DefaultAppVersion},
-				{Version: "6.3.6", AppVersion: DefaultAppVersion},
-				{Version: "6.3.5", AppVersion: DefaultAppVersion},
-				{Version: "6.3.4", AppVersion: DefaultAppVersion},
-				{Version: "6.3.3", AppVersion: DefaultAppVersion},
-				{Version: "6.2.6", AppVersion: DefaultAppVersion},
-				{Version: "6.2.5", AppVersion: DefaultAppVersion},
-				{Version: "6.2.4", AppVersion: DefaultAppVersion},
-				{Version: "6.2.3", AppVersion: DefaultAppVersion},
-				{Version: "4.5.6", AppVersion: DefaultAppVersion},
-				{Version: "4.5.5", AppVersion: DefaultAppVersion},
-				{Version: "4.5.4", AppVersion: DefaultAppVersion},
-				{Version: "4.5.3", AppVersion: DefaultAppVersion},
-				{Version: "4.4.6", AppVersion: DefaultAppVersion},
-				{Version: "4.4.5", AppVersion: DefaultAppVersion},
-				{Version: "4.4.4", AppVersion: DefaultAppVersion},
-				{Version: "4.4.3", AppVersion: DefaultAppVersion},
-				{Version: "4.3.6", AppVersion: DefaultAppVersion},
-				{Version: "4.3.5", AppVersion: DefaultAppVersion},
-				{Version: "4.3.4", AppVersion: DefaultAppVersion},
-				{Version: "4.3.3", AppVersion: DefaultAppVersion},
-				{Version: "4.2.6", AppVersion: DefaultAppVersion},
-				{Version: "4.2.5", AppVersion: DefaultAppVersion},
-				{Version: "4.2.4", AppVersion: DefaultAppVersion},
-				{Version: "4.2.3", AppVersion: DefaultAppVersion},
-				{Version: "2.5.6", AppVersion: DefaultAppVersion},
-				{Version: "2.5.5", AppVersion: DefaultAppVersion},
-				{Version: "2.5.4", AppVersion: DefaultAppVersion},
-				{Version: "2.5.3", AppVersion: DefaultAppVersion},
-				{Version: "2.4.6", AppVersion: DefaultAppVersion},
-				{Version: "2.4.5", AppVersion: DefaultAppVersion},
-				{Version: "2.4.4", AppVersion: DefaultAppVersion},
-				{Version: "2.4.3", AppVersion: DefaultAppVersion},
-				{Version: "2.3.6", AppVersion: DefaultAppVersion},
-				{Version: "2.3.5", AppVersion: DefaultAppVersion},
-				{Version: "2.3.4", AppVersion: DefaultAppVersion},
-				{Version: "2.3.3", AppVersion: DefaultAppVersion},
-				{Version: "2.2.6", AppVersion: DefaultAppVersion},
-				{Version: "2.2.5", AppVersion: DefaultAppVersion},
-				{Version: "2.2.4", AppVersion: DefaultAppVersion},
-				{Version: "2.2.3", AppVersion: DefaultAppVersion},
-			},
-			version_summary: []*corev1.GetAvailablePackageVersionsResponse_PackageAppVersion{
-				{PkgVersion: "8.5.6", AppVersion: DefaultAppVersion},
-				{PkgVersion: "8.5.5", AppVersion: DefaultAppVersion},
-				{PkgVersion: "8.5.4", AppVersion: DefaultAppVersion},
-				{PkgVersion: "8.4.6", AppVersion: DefaultAppVersion},
-				{PkgVersion: "8.4.5", AppVersion: DefaultAppVersion},
-				{PkgVersion: "8.4.4", AppVersion: DefaultAppVersion},
-				{PkgVersion: "8.3.6", AppVersion: DefaultAppVersion},
-				{PkgVersion: "8.3.5", AppVersion: DefaultAppVersion},
-				{PkgVersion: "8.3.4", AppVersion: DefaultAppVersion},
-				{PkgVersion: "6.5.6", AppVersion: DefaultAppVersion},
-				{PkgVersion: "6.5.5", AppVersion: DefaultAppVersion},
-				{PkgVersion: "6.5.4", AppVersion: DefaultAppVersion},
-				{PkgVersion: "6.4.6", AppVersion: DefaultAppVersion},
-				{PkgVersion: "6.4.5", AppVersion: DefaultAppVersion},
-				{PkgVersion: "6.4.4", AppVersion: DefaultAppVersion},
-				{PkgVersion: "6.3.6", AppVersion: DefaultAppVersion},
-				{PkgVersion: "6.3.5", AppVersion: DefaultAppVersion},
-				{PkgVersion: "6.3.4", AppVersion: DefaultAppVersion},
-				{PkgVersion: "4.5.6", AppVersion: DefaultAppVersion},
-				{PkgVersion: "4.5.5", AppVersion: DefaultAppVersion},
-				{PkgVersion: "4.5.4", AppVersion: DefaultAppVersion},
-				{PkgVersion: "4.4.6", AppVersion: DefaultAppVersion},
-				{PkgVersion: "4.4.5", AppVersion: DefaultAppVersion},
-				{PkgVersion: "4.4.4", AppVersion: DefaultAppVersion},
-				{PkgVersion: "4.3.6", AppVersion: DefaultAppVersion},
-				{PkgVersion: "4.3.5", AppVersion: DefaultAppVersion},
-				{PkgVersion: "4.3.4", AppVersion: DefaultAppVersion},
+			name: "returns the installed package detail",
+			request: &corev1.GetInstalledPackageDetailRequest{
+				InstalledPackageRef: &corev1.InstalledPackageReference{
+					Context:    &corev1.Context{Namespace: "default"},
+					Identifier: "my-apache",
+				},
 			},
+			expectedResponse: &corev1.GetInstalledPackageDetailResponse{
+				InstalledPackageDetail: &corev1.InstalledPackageDetail{
+					InstalledPackageRef: &corev1.InstalledPackageReference{
+						Context:    &corev1.Context{Namespace: "default"},
+						Identifier: "my-apache",
+						Plugin:     GetPluginDetail(),
+					},
+					Name:                "my-apache",
+					PkgVersionReference: &corev1.VersionReference{Version: "1.2.3"},
+					CurrentVersion: &corev1.PackageAppVersion{
+						PkgVersion: "1.2.3",
+						AppVersion: DefaultAppVersion,
+					},
+					ValuesApplied:         "replicaCount: 2\n",
+					PostInstallationNotes: "some notes",
+					Status: &corev1.InstalledPackageStatus{
+						Ready:      true,
+						Reason:     corev1.InstalledPackageStatus_STATUS_REASON_INSTALLED,
+						UserReason: "deployed",
+					},
+				},
+			},
+		},
+		{
+			name: "returns a not found error if the release does not exist",
+			request: &corev1.GetInstalledPackageDetailRequest{
+				InstalledPackageRef: &corev1.InstalledPackageReference{
+					Context:    &corev1.Context{Namespace: "default"},
+					Identifier: "not-my-apache",
+				},
+			},
+			expectedStatus: codes.NotFound,
 		},
+		{
+			name: "returns an invalid argument error if the identifier is missing",
+			request: &corev1.GetInstalledPackageDetailRequest{
+				InstalledPackageRef: &corev1.InstalledPackageReference{
+					Context: &corev1.Context{Namespace: "default"},
+				},
+			},
+			expectedStatus: codes.InvalidArgument,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			actionConfig := newActionConfigFixture(t, "default", existingReleases)
+			server, _, cleanup := makeServerWithActionConfig(t, actionConfig)
+			defer cleanup()
+
+			response, err := server.GetInstalledPackageDetail(context.Background(), tc.request)
+
+			if got, want := status.Code(err), tc.expectedStatus; got != want {
+				t.Fatalf("got: %+v, want: %+v, err: %+v", got, want, err)
+			}
+			if tc.expectedStatus != codes.OK {
+				return
+			}
+
+			if got, want := response, tc.expectedResponse; !cmp.Equal(want, got, installedPkgOpts) {
+				t.Errorf("mismatch (-want +got):\n%s", cmp.Diff(want, got, installedPkgOpts))
+			}
+		})
 	}
+}
 
-	opts := cmpopts.IgnoreUnexported(corev1.GetAvailablePackageVersionsResponse_PackageAppVersion{})
+func TestCreateInstalledPackage(t *testing.T) {
+	testCases := []struct {
+		name             string
+		request          *corev1.CreateInstalledPackageRequest
+		existingReleases []releaseStub
+		expectedStatus   codes.Code
+		expectedRef      *corev1.InstalledPackageReference
+	}{
+		{
+			name: "creates the release from the chart in the app repository",
+			request: &corev1.CreateInstalledPackageRequest{
+				AvailablePackageRef: &corev1.AvailablePackageReference{
+					Context:    &corev1.Context{Namespace: globalPackagingNamespace},
+					Identifier: "bitnami/apache",
+				},
+				TargetContext: &corev1.Context{Namespace: "default"},
+				Name:          "my-apache",
+				PkgVersionReference: &corev1.VersionReference{
+					Version: "1.2.3",
+				},
+				Values: "replicaCount: 2",
+			},
+			expectedRef: &corev1.InstalledPackageReference{
+				Context:    &corev1.Context{Namespace: "default"},
+				Identifier: "my-apache",
+				Plugin:     GetPluginDetail(),
+			},
+		},
+		{
+			name: "returns an internal error if the release already exists",
+			request: &corev1.CreateInstalledPackageRequest{
+				AvailablePackageRef: &corev1.AvailablePackageReference{
+					Context:    &corev1.Context{Namespace: globalPackagingNamespace},
+					Identifier: "bitnami/apache",
+				},
+				TargetContext: &corev1.Context{Namespace: "default"},
+				Name:          "my-apache",
+			},
+			existingReleases: []releaseStub{
+				{
+					name:         "my-apache",
+					namespace:    "default",
+					chartName:    "apache",
+					chartVersion: "1.2.3",
+					version:      1,
+					status:       release.StatusDeployed,
+				},
+			},
+			expectedStatus: codes.Internal,
+		},
+		{
+			name: "returns a not found error if the app repository does not exist",
+			request: &corev1.CreateInstalledPackageRequest{
+				AvailablePackageRef: &corev1.AvailablePackageReference{
+					Context:    &corev1.Context{Namespace: globalPackagingNamespace},
+					Identifier: "not-bitnami/apache",
+				},
+				TargetContext: &corev1.Context{Namespace: "default"},
+				Name:          "my-apache",
+			},
+			expectedStatus: codes.NotFound,
+		},
+		{
+			name: "returns an invalid argument error if the name is missing",
+			request: &corev1.CreateInstalledPackageRequest{
+				AvailablePackageRef: &corev1.AvailablePackageReference{
+					Context:    &corev1.Context{Namespace: globalPackagingNamespace},
+					Identifier: "bitnami/apache",
+				},
+				TargetContext: &corev1.Context{Namespace: "default"},
+			},
+			expectedStatus: codes.InvalidArgument,
+		},
+	}
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			if got, want := packageAppVersionsSummary(tc.chart_versions), tc.version_summary; !cmp.Equal(want, got, opts) {
-				t.Errorf("mismatch (-want +got):\n%s", cmp.Diff(want, got, opts))
+			actionConfig := newActionConfigFixture(t, "default", tc.existingReleases)
+			server, _, cleanup := makeServerWithActionConfig(t, actionConfig, newAppRepo("bitnami", globalPackagingNamespace))
+			defer cleanup()
+
+			response, err := server.CreateInstalledPackage(context.Background(), tc.request)
+
+			if got, want := status.Code(err), tc.expectedStatus; got != want {
+				t.Fatalf("got: %+v, want: %+v, err: %+v", got, want, err)
+			}
+			if tc.expectedStatus != codes.OK {
+				return
+			}
+
+			if got, want := response.InstalledPackageRef, tc.expectedRef; !cmp.Equal(want, got, installedPkgOpts) {
+				t.Errorf("mismatch (-want +got):\n%s", cmp.Diff(want, got, installedPkgOpts))
+			}
+
+			// Verify the release was stored with the requested values.
+			r, err := actionConfig.Releases.Last(tc.request.Name)
+			if err != nil {
+				t.Fatalf("%+v", err)
+			}
+			if got, want := r.Config, map[string]interface{}{"replicaCount": float64(2)}; !cmp.Equal(want, got) {
+				t.Errorf("mismatch (-want +got):\n%s", cmp.Diff(want, got))
+			}
+		})
+	}
+}
+
+func TestUpdateInstalledPackage(t *testing.T) {
+	existingReleases := []releaseStub{
+		{
+			name:         "my-apache",
+			namespace:    "default",
+			chartName:    "apache",
+			chartVersion: "1.2.3",
+			version:      1,
+			status:       release.StatusDeployed,
+		},
+	}
+
+	testCases := []struct {
+		name           string
+		request        *corev1.UpdateInstalledPackageRequest
+		charts         []*models.Chart
+		expectedStatus codes.Code
+	}{
+		{
+			name: "upgrades the release using the chart from the repository providing the version",
+			request: &corev1.UpdateInstalledPackageRequest{
+				InstalledPackageRef: &corev1.InstalledPackageReference{
+					Context:    &corev1.Context{Namespace: "default"},
+					Identifier: "my-apache",
+				},
+				PkgVersionReference: &corev1.VersionReference{
+					Version: "1.2.4",
+				},
+				Values: "replicaCount: 3",
+			},
+			charts: []*models.Chart{
+				makeChart("apache", "other-repo", globalPackagingNamespace, []string{"2.0.0"}),
+				makeChart("apache", "bitnami", globalPackagingNamespace, []string{"1.2.4", "1.2.3"}),
+			},
+		},
+		{
+			name: "upgrades the release with the current version when none is requested",
+			request: &corev1.UpdateInstalledPackageRequest{
+				InstalledPackageRef: &corev1.InstalledPackageReference{
+					Context:    &corev1.Context{Namespace: "default"},
+					Identifier: "my-apache",
+				},
+				Values: "replicaCount: 3",
+			},
+			charts: []*models.Chart{
+				makeChart("apache", "bitnami", globalPackagingNamespace, []string{"1.2.4", "1.2.3"}),
+			},
+		},
+		{
+			name: "returns a not found error if no repository provides the version",
+			request: &corev1.UpdateInstalledPackageRequest{
+				InstalledPackageRef: &corev1.InstalledPackageReference{
+					Context:    &corev1.Context{Namespace: "default"},
+					Identifier: "my-apache",
+				},
+				PkgVersionReference: &corev1.VersionReference{
+					Version: "3.0.0",
+				},
+			},
+			charts: []*models.Chart{
+				makeChart("apache", "bitnami", globalPackagingNamespace, []string{"1.2.4", "1.2.3"}),
+			},
+			expectedStatus: codes.NotFound,
+		},
+		{
+			name: "returns a failed precondition error if several repositories provide the version",
+			request: &corev1.UpdateInstalledPackageRequest{
+				InstalledPackageRef: &corev1.InstalledPackageReference{
+					Context:    &corev1.Context{Namespace: "default"},
+					Identifier: "my-apache",
+				},
+				PkgVersionReference: &corev1.VersionReference{
+					Version: "1.2.4",
+				},
+			},
+			charts: []*models.Chart{
+				makeChart("apache", "other-repo", globalPackagingNamespace, []string{"1.2.4"}),
+				makeChart("apache", "bitnami", globalPackagingNamespace, []string{"1.2.4", "1.2.3"}),
+			},
+			expectedStatus: codes.FailedPrecondition,
+		},
+		{
+			name: "upgrades the release using the chart from the repository of the available package",
+			request: &corev1.UpdateInstalledPackageRequest{
+				InstalledPackageRef: &corev1.InstalledPackageReference{
+					Context:    &corev1.Context{Namespace: "default"},
+					Identifier: "my-apache",
+				},
+				AvailablePackageRef: &corev1.AvailablePackageReference{
+					Context:    &corev1.Context{Namespace: globalPackagingNamespace},
+					Identifier: "bitnami/apache",
+				},
+				PkgVersionReference: &corev1.VersionReference{
+					Version: "1.2.4",
+				},
+				Values: "replicaCount: 3",
+			},
+		},
+		{
+			name: "returns an invalid argument error if the available package is for another chart",
+			request: &corev1.UpdateInstalledPackageRequest{
+				InstalledPackageRef: &corev1.InstalledPackageReference{
+					Context:    &corev1.Context{Namespace: "default"},
+					Identifier: "my-apache",
+				},
+				AvailablePackageRef: &corev1.AvailablePackageReference{
+					Context:    &corev1.Context{Namespace: globalPackagingNamespace},
+					Identifier: "bitnami/nginx",
+				},
+			},
+			expectedStatus: codes.InvalidArgument,
+		},
+		{
+			name: "returns a not found error if the release does not exist",
+			request: &corev1.UpdateInstalledPackageRequest{
+				InstalledPackageRef: &corev1.InstalledPackageReference{
+					Context:    &corev1.Context{Namespace: "default"},
+					Identifier: "not-my-apache",
+				},
+			},
+			expectedStatus: codes.NotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			actionConfig := newActionConfigFixture(t, "default", existingReleases)
+			server, mock, cleanup := makeServerWithActionConfig(t, actionConfig, newAppRepo("bitnami", globalPackagingNamespace))
+			defer cleanup()
+
+			if tc.charts != nil {
+				rows := sqlmock.NewRows([]string{"info"})
+				for _, chartJSON := range makeChartRowsJSON(t, tc.charts, "", 0) {
+					rows.AddRow(chartJSON)
+				}
+				mock.ExpectQuery("SELECT info FROM").
+					WithArgs("default", globalPackagingNamespace, "apache").
+					WillReturnRows(rows)
+			}
+
+			response, err := server.UpdateInstalledPackage(context.Background(), tc.request)
+
+			if got, want := status.Code(err), tc.expectedStatus; got != want {
+				t.Fatalf("got: %+v, want: %+v, err: %+v", got, want, err)
+			}
+			if err = mock.ExpectationsWereMet(); err != nil {
+				t.Fatalf("%v", err)
+			}
+			if tc.expectedStatus != codes.OK {
+				return
+			}
+
+			expectedRef := &corev1.InstalledPackageReference{
+				Context:    &corev1.Context{Namespace: "default"},
+				Identifier: "my-apache",
+				Plugin:     GetPluginDetail(),
+			}
+			if got, want := response.InstalledPackageRef, expectedRef; !cmp.Equal(want, got, installedPkgOpts) {
+				t.Errorf("mismatch (-want +got):\n%s", cmp.Diff(want, got, installedPkgOpts))
+			}
+
+			r, err := actionConfig.Releases.Last("my-apache")
+			if err != nil {
+				t.Fatalf("%+v", err)
+			}
+			if got, want := r.Version, 2; got != want {
+				t.Errorf("got: %d, want: %d", got, want)
+			}
+			if got, want := r.Config, map[string]interface{}{"replicaCount": float64(3)}; !cmp.Equal(want, got) {
+				t.Errorf("mismatch (-want +got):\n%s", cmp.Diff(want, got))
+			}
+		})
+	}
+}
+
+func TestDeleteInstalledPackage(t *testing.T) {
+	existingReleases := []releaseStub{
+		{
+			name:         "my-apache",
+			namespace:    "default",
+			chartName:    "apache",
+			chartVersion: "1.2.3",
+			version:      1,
+			status:       release.StatusDeployed,
+		},
+	}
+
+	testCases := []struct {
+		name           string
+		request        *corev1.DeleteInstalledPackageRequest
+		expectedStatus codes.Code
+	}{
+		{
+			name: "deletes the release",
+			request: &corev1.DeleteInstalledPackageRequest{
+				InstalledPackageRef: &corev1.InstalledPackageReference{
+					Context:    &corev1.Context{Namespace: "default"},
+					Identifier: "my-apache",
+				},
+			},
+		},
+		{
+			name: "returns a not found error if the release does not exist",
+			request: &corev1.DeleteInstalledPackageRequest{
+				InstalledPackageRef: &corev1.InstalledPackageReference{
+					Context:    &corev1.Context{Namespace: "default"},
+					Identifier: "not-my-apache",
+				},
+			},
+			expectedStatus: codes.NotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			actionConfig := newActionConfigFixture(t, "default", existingReleases)
+			server, _, cleanup := makeServerWithActionConfig(t, actionConfig)
+			defer cleanup()
+
+			_, err := server.DeleteInstalledPackage(context.Background(), tc.request)
+
+			if got, want := status.Code(err), tc.expectedStatus; got != want {
+				t.Fatalf("got: %+v, want: %+v, err: %+v", got, want, err)
+			}
+			if tc.expectedStatus != codes.OK {
+				return
+			}
+
+			if _, err := actionConfig.Releases.Last("my-apache"); err == nil {
+				t.Errorf("got: nil, want: release not found error")
+			}
+		})
+	}
+}
+
+func TestRollbackInstalledPackage(t *testing.T) {
+	existingReleases := []releaseStub{
+		{
+			name:         "my-apache",
+			namespace:    "default",
+			chartName:    "apache",
+			chartVersion: "1.2.3",
+			version:      1,
+			status:       release.StatusSuperseded,
+		},
+		{
+			name:         "my-apache",
+			namespace:    "default",
+			chartName:    "apache",
+			chartVersion: "1.2.4",
+			version:      2,
+			status:       release.StatusDeployed,
+		},
+	}
+
+	testCases := []struct {
+		name            string
+		request         *v1alpha1.RollbackInstalledPackageRequest
+		expectedStatus  codes.Code
+		expectedVersion string
+	}{
+		{
+			name: "rolls back the release to the requested revision",
+			request: &v1alpha1.RollbackInstalledPackageRequest{
+				InstalledPackageRef: &corev1.InstalledPackageReference{
+					Context:    &corev1.Context{Namespace: "default"},
+					Identifier: "my-apache",
+				},
+				ReleaseRevision: 1,
+			},
+			expectedVersion: "1.2.3",
+		},
+		{
+			name: "returns an invalid argument error if the revision is missing",
+			request: &v1alpha1.RollbackInstalledPackageRequest{
+				InstalledPackageRef: &corev1.InstalledPackageReference{
+					Context:    &corev1.Context{Namespace: "default"},
+					Identifier: "my-apache",
+				},
+			},
+			expectedStatus: codes.InvalidArgument,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			actionConfig := newActionConfigFixture(t, "default", existingReleases)
+			server, _, cleanup := makeServerWithActionConfig(t, actionConfig)
+			defer cleanup()
+
+			_, err := server.RollbackInstalledPackage(context.Background(), tc.request)
+
+			if got, want := status.Code(err), tc.expectedStatus; got != want {
+				t.Fatalf("got: %+v, want: %+v, err: %+v", got, want, err)
+			}
+			if tc.expectedStatus != codes.OK {
+				return
+			}
+
+			r, err := actionConfig.Releases.Last("my-apache")
+			if err != nil {
+				t.Fatalf("%+v", err)
+			}
+			if got, want := r.Version, 3; got != want {
+				t.Errorf("got: %d, want: %d", got, want)
+			}
+			if got, want := r.Chart.Metadata.Version, tc.expectedVersion; got != want {
+				t.Errorf("got: %q, want: %q", got, want)
 			}
 		})
 	}
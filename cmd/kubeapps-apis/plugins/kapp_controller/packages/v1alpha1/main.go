@@ -21,6 +21,8 @@ import (
 	plugins "github.com/kubeapps/kubeapps/cmd/kubeapps-apis/gen/core/plugins/v1alpha1"
 	"github.com/kubeapps/kubeapps/cmd/kubeapps-apis/gen/plugins/kapp_controller/packages/v1alpha1"
 	"github.com/kubeapps/kubeapps/cmd/kubeapps-apis/server"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 // Set the pluginDetail once during a module init function so the single struct
@@ -36,16 +38,24 @@ func init() {
 
 // RegisterWithGRPCServer enables a plugin to register with a gRPC server
 // returning the server implementation.
-func RegisterWithGRPCServer(s grpc.ServiceRegistrar, clientGetter server.KubernetesClientGetter) (interface{}, error) {
-	svr := NewServer(clientGetter)
+func RegisterWithGRPCServer(ctx context.Context, s grpc.ServiceRegistrar, configGetter server.KubernetesConfigGetter, kubeappsCluster string, config server.PluginConfig) (interface{}, error) {
+	// The plugin does not have any configuration yet, so any key set for it is a mistake.
+	if err := config.Decode(&struct{}{}); err != nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "invalid plugin configuration: %v", err)
+	}
+	svr := NewServer(server.NewClientGetter(configGetter))
 	v1alpha1.RegisterKappControllerPackagesServiceServer(s, svr)
+	v1alpha1.RegisterKappControllerRepositoriesServiceServer(s, svr)
 	return svr, nil
 }
 
 // RegisterHTTPHandlerFromEndpoint enables a plugin to register an http
 // handler to translate to the gRPC request.
 func RegisterHTTPHandlerFromEndpoint(ctx context.Context, mux *runtime.ServeMux, endpoint string, opts []grpc.DialOption) error {
-	return v1alpha1.RegisterKappControllerPackagesServiceHandlerFromEndpoint(ctx, mux, endpoint, opts)
+	if err := v1alpha1.RegisterKappControllerPackagesServiceHandlerFromEndpoint(ctx, mux, endpoint, opts); err != nil {
+		return err
+	}
+	return v1alpha1.RegisterKappControllerRepositoriesServiceHandlerFromEndpoint(ctx, mux, endpoint, opts)
 }
 
 // GetPluginDetail returns a core.plugins.Plugin describing itself.
@@ -0,0 +1,231 @@
+/*
+Copyright © 2021 VMware
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/Masterminds/semver/v3"
+	corev1 "github.com/kubeapps/kubeapps/cmd/kubeapps-apis/gen/core/packages/v1alpha1"
+	"github.com/kubeapps/kubeapps/cmd/kubeapps-apis/gen/plugins/kapp_controller/packages/v1alpha1"
+	"github.com/kubeapps/kubeapps/cmd/kubeapps-apis/server"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/anypb"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// versionedPackage pairs a Package CR with its parsed version, so that
+// the packages for a refName can be sorted by semver.
+type versionedPackage struct {
+	version *semver.Version
+	pkg     *unstructured.Unstructured
+}
+
+// packageVersionsForRefName returns the Package CRs with the given refName,
+// sorted by version from the latest to the oldest. Packages with an invalid
+// semver version are skipped, as kapp-controller does.
+func packageVersionsForRefName(pkgs []unstructured.Unstructured, refName string) []versionedPackage {
+	versions := []versionedPackage{}
+	for i := range pkgs {
+		pkg := &pkgs[i]
+		// https://carvel.dev/kapp-controller/docs/latest/packaging/#package
+		pkgRefName, found, err := unstructured.NestedString(pkg.Object, "spec", "refName")
+		if err != nil || !found || pkgRefName != refName {
+			continue
+		}
+		versionString, found, err := unstructured.NestedString(pkg.Object, "spec", "version")
+		if err != nil || !found {
+			continue
+		}
+		version, err := semver.NewVersion(versionString)
+		if err != nil {
+			continue
+		}
+		versions = append(versions, versionedPackage{version: version, pkg: pkg})
+	}
+	sort.SliceStable(versions, func(i, j int) bool {
+		return versions[i].version.GreaterThan(versions[j].version)
+	})
+	return versions
+}
+
+// availablePackageSummaryFromUnstructured builds the AvailablePackageSummary from the
+// PackageMetadata and the Package for its latest version on the given cluster.
+func availablePackageSummaryFromUnstructured(cluster string, pkgMetadata *unstructured.Unstructured, latestPkg *unstructured.Unstructured) *corev1.AvailablePackageSummary {
+	// https://carvel.dev/kapp-controller/docs/latest/packaging/#package-metadata
+	refName := pkgMetadata.GetName()
+	displayName, _, _ := unstructured.NestedString(pkgMetadata.Object, "spec", "displayName")
+	if displayName == "" {
+		displayName = refName
+	}
+	shortDescription, _, _ := unstructured.NestedString(pkgMetadata.Object, "spec", "shortDescription")
+	iconSVGBase64, _, _ := unstructured.NestedString(pkgMetadata.Object, "spec", "iconSVGBase64")
+	// https://carvel.dev/kapp-controller/docs/latest/packaging/#package
+	version, _, _ := unstructured.NestedString(latestPkg.Object, "spec", "version")
+
+	return &corev1.AvailablePackageSummary{
+		AvailablePackageRef: &corev1.AvailablePackageReference{
+			Context: &corev1.Context{
+				Cluster:   cluster,
+				Namespace: pkgMetadata.GetNamespace(),
+			},
+			Identifier: refName,
+			Plugin:     GetPluginDetail(),
+		},
+		Name:             refName,
+		LatestPkgVersion: version,
+		IconUrl:          iconUrlFromSVGBase64(iconSVGBase64),
+		DisplayName:      displayName,
+		ShortDescription: shortDescription,
+	}
+}
+
+// packageMatchesFilterOptions returns whether the package, given by its PackageMetadata and
+// its versions, matches the filter options with the same semantics as the helm plugin.
+// Carvel packages do not record the repository they were fetched from, so they never
+// match a filter on repositories, nor do they have an app version distinct from the package
+// version, so only the latter is compared when filtering by versions.
+func packageMatchesFilterOptions(pkgMetadata *unstructured.Unstructured, versions []versionedPackage, filterOptions *corev1.FilterOptions) bool {
+	if filterOptions == nil {
+		return true
+	}
+
+	if !server.MatchesFilterValues(filterOptions.GetRepositories()) {
+		return false
+	}
+
+	categories, _, _ := unstructured.NestedStringSlice(pkgMetadata.Object, "spec", "categories")
+	if !server.MatchesFilterValues(filterOptions.GetCategories(), categories...) {
+		return false
+	}
+
+	if filterOptions.GetPkgVersion() != "" && filterOptions.GetAppVersion() != "" {
+		found := false
+		for _, v := range versions {
+			if v.version.Original() == filterOptions.GetPkgVersion() {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if filterOptions.GetQuery() != "" {
+		fields := []string{pkgMetadata.GetName()}
+		for _, field := range []string{"displayName", "shortDescription", "longDescription", "providerName"} {
+			value, _, _ := unstructured.NestedString(pkgMetadata.Object, "spec", field)
+			fields = append(fields, value)
+		}
+		fields = append(fields, categories...)
+		maintainers, _, _ := unstructured.NestedSlice(pkgMetadata.Object, "spec", "maintainers")
+		for _, maintainer := range maintainers {
+			if maintainerAsMap, ok := maintainer.(map[string]interface{}); ok {
+				name, _ := maintainerAsMap["name"].(string)
+				fields = append(fields, name)
+			}
+		}
+		if !server.MatchesFilterQuery(filterOptions.GetQuery(), fields...) {
+			return false
+		}
+	}
+	return true
+}
+
+// iconUrlFromSVGBase64 returns a data URL for the base64 encoded SVG icon of a
+// PackageMetadata, or an empty string if it has no icon.
+func iconUrlFromSVGBase64(iconSVGBase64 string) string {
+	if iconSVGBase64 == "" {
+		return ""
+	}
+	return fmt.Sprintf("data:image/svg+xml;base64,%s", iconSVGBase64)
+}
+
+// availablePackageDetailFromUnstructured builds the AvailablePackageDetail by joining the
+// PackageMetadata, which holds the data common to all versions, with the Package for
+// the specific version, on the given cluster.
+func availablePackageDetailFromUnstructured(cluster string, pkgMetadata *unstructured.Unstructured, pkg *unstructured.Unstructured) (*corev1.AvailablePackageDetail, error) {
+	// https://carvel.dev/kapp-controller/docs/latest/packaging/#package-metadata
+	refName := pkgMetadata.GetName()
+	displayName, _, _ := unstructured.NestedString(pkgMetadata.Object, "spec", "displayName")
+	if displayName == "" {
+		displayName = refName
+	}
+	shortDescription, _, _ := unstructured.NestedString(pkgMetadata.Object, "spec", "shortDescription")
+	longDescription, _, _ := unstructured.NestedString(pkgMetadata.Object, "spec", "longDescription")
+	iconSVGBase64, _, _ := unstructured.NestedString(pkgMetadata.Object, "spec", "iconSVGBase64")
+	categories, _, _ := unstructured.NestedStringSlice(pkgMetadata.Object, "spec", "categories")
+
+	maintainers := []*corev1.Maintainer{}
+	maintainersUnstructured, _, _ := unstructured.NestedSlice(pkgMetadata.Object, "spec", "maintainers")
+	for _, maintainerUnstructured := range maintainersUnstructured {
+		if maintainerAsMap, ok := maintainerUnstructured.(map[string]interface{}); ok {
+			name, _ := maintainerAsMap["name"].(string)
+			maintainers = append(maintainers, &corev1.Maintainer{Name: name})
+		}
+	}
+
+	// https://carvel.dev/kapp-controller/docs/latest/packaging/#package
+	version, found, err := unstructured.NestedString(pkg.Object, "spec", "version")
+	if err != nil || !found {
+		return nil, status.Errorf(codes.Internal, "required field spec.version not found on kapp-controller package: %v:\n%v", err, pkg.Object)
+	}
+
+	valuesSchema := ""
+	if schema, found, err := unstructured.NestedMap(pkg.Object, "spec", "valuesSchema", "openAPIv3"); err != nil {
+		return nil, status.Errorf(codes.Internal, "unable to read spec.valuesSchema.openAPIv3 of kapp-controller package: %v", err)
+	} else if found {
+		schemaBytes, err := json.Marshal(schema)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "unable to marshal values schema of kapp-controller package: %v", err)
+		}
+		valuesSchema = string(schemaBytes)
+	}
+
+	releaseNotes, _, _ := unstructured.NestedString(pkg.Object, "spec", "releaseNotes")
+	releasedAt, _, _ := unstructured.NestedString(pkg.Object, "spec", "releasedAt")
+	licenses, _, _ := unstructured.NestedStringSlice(pkg.Object, "spec", "licenses")
+	customDetail, err := anypb.New(&v1alpha1.PackageDetailCustomData{
+		ReleaseNotes: releaseNotes,
+		Licenses:     licenses,
+		ReleasedAt:   releasedAt,
+	})
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "unable to marshal custom detail of kapp-controller package: %v", err)
+	}
+
+	return &corev1.AvailablePackageDetail{
+		AvailablePackageRef: &corev1.AvailablePackageReference{
+			Context: &corev1.Context{
+				Cluster:   cluster,
+				Namespace: pkgMetadata.GetNamespace(),
+			},
+			Identifier: refName,
+			Plugin:     GetPluginDetail(),
+		},
+		Name:             refName,
+		PkgVersion:       version,
+		IconUrl:          iconUrlFromSVGBase64(iconSVGBase64),
+		DisplayName:      displayName,
+		ShortDescription: shortDescription,
+		LongDescription:  longDescription,
+		ValuesSchema:     valuesSchema,
+		Maintainers:      maintainers,
+		CustomDetail:     customDetail,
+		Categories:       categories,
+	}, nil
+}
@@ -0,0 +1,380 @@
+/*
+Copyright © 2021 VMware
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "github.com/kubeapps/kubeapps/cmd/kubeapps-apis/gen/core/packages/v1alpha1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	k8scorev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const (
+	// the key of the values secret data used by kapp-controller, see
+	// https://carvel.dev/kapp-controller/docs/latest/package-install-extensions/
+	valuesSecretKey = "values.yaml"
+)
+
+// valuesSecretName returns the name of the secret holding the values of the
+// PackageInstall with the given name.
+func valuesSecretName(installName string) string {
+	return fmt.Sprintf("%s-values", installName)
+}
+
+// newValuesSecret returns the secret holding the values of the PackageInstall.
+func newValuesSecret(installName string, namespace string, values string) *k8scorev1.Secret {
+	return &k8scorev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      valuesSecretName(installName),
+			Namespace: namespace,
+		},
+		Type: k8scorev1.SecretTypeOpaque,
+		StringData: map[string]string{
+			valuesSecretKey: values,
+		},
+	}
+}
+
+// newPackageInstall returns a PackageInstall object for the package referenced
+// by refName, to be created in the target namespace.
+func newPackageInstall(name string, namespace string, refName string, versionRef *corev1.VersionReference, hasValues bool, reconcile *corev1.ReconciliationOptions) (*unstructured.Unstructured, error) {
+	if reconcile.GetServiceAccountName() == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "Required ReconciliationOptions.ServiceAccountName not provided")
+	}
+
+	unstructuredInstall := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": fmt.Sprintf("%s/%s", packagingGroup, packageInstallVersion),
+			"kind":       packageInstallResource,
+			"metadata": map[string]interface{}{
+				"name":      name,
+				"namespace": namespace,
+			},
+			"spec": map[string]interface{}{
+				"packageRef": map[string]interface{}{
+					"refName": refName,
+				},
+			},
+		},
+	}
+
+	if err := setPackageInstallVersion(unstructuredInstall, versionRef.GetVersion()); err != nil {
+		return nil, err
+	}
+	if err := setPackageInstallValuesRef(unstructuredInstall, hasValues); err != nil {
+		return nil, err
+	}
+	if err := setPackageInstallReconciliationOptions(unstructuredInstall, reconcile); err != nil {
+		return nil, err
+	}
+	return unstructuredInstall, nil
+}
+
+// setPackageInstallVersion sets the version constraint of the PackageInstall.
+// An empty version leaves the current constraint untouched.
+func setPackageInstallVersion(unstructuredInstall *unstructured.Unstructured, version string) error {
+	if version == "" {
+		return nil
+	}
+	err := unstructured.SetNestedField(unstructuredInstall.Object, version, "spec", "packageRef", "versionSelection", "constraints")
+	if err != nil {
+		return status.Errorf(codes.Internal, "Unable to set version of PackageInstall: %v", err)
+	}
+	return nil
+}
+
+// setPackageInstallValuesRef references the values secret from the PackageInstall,
+// or removes the reference when there are no values.
+func setPackageInstallValuesRef(unstructuredInstall *unstructured.Unstructured, hasValues bool) error {
+	if !hasValues {
+		unstructured.RemoveNestedField(unstructuredInstall.Object, "spec", "values")
+		return nil
+	}
+	values := []interface{}{
+		map[string]interface{}{
+			"secretRef": map[string]interface{}{
+				"name": valuesSecretName(unstructuredInstall.GetName()),
+			},
+		},
+	}
+	if err := unstructured.SetNestedSlice(unstructuredInstall.Object, values, "spec", "values"); err != nil {
+		return status.Errorf(codes.Internal, "Unable to set values of PackageInstall: %v", err)
+	}
+	return nil
+}
+
+// setPackageInstallReconciliationOptions sets the sync period, paused flag and service
+// account name of the PackageInstall. A nil value leaves the current options untouched.
+func setPackageInstallReconciliationOptions(unstructuredInstall *unstructured.Unstructured, reconcile *corev1.ReconciliationOptions) error {
+	if reconcile == nil {
+		return nil
+	}
+	if reconcile.Interval < 0 {
+		return status.Errorf(codes.InvalidArgument, "Invalid reconciliation interval: [%d]", reconcile.Interval)
+	} else if reconcile.Interval > 0 {
+		syncPeriod := (time.Duration(reconcile.Interval) * time.Second).String()
+		if err := unstructured.SetNestedField(unstructuredInstall.Object, syncPeriod, "spec", "syncPeriod"); err != nil {
+			return status.Errorf(codes.Internal, "Unable to set sync period of PackageInstall: %v", err)
+		}
+	}
+	if err := unstructured.SetNestedField(unstructuredInstall.Object, reconcile.Suspend, "spec", "paused"); err != nil {
+		return status.Errorf(codes.Internal, "Unable to set paused flag of PackageInstall: %v", err)
+	}
+	if reconcile.ServiceAccountName != "" {
+		if err := unstructured.SetNestedField(unstructuredInstall.Object, reconcile.ServiceAccountName, "spec", "serviceAccountName"); err != nil {
+			return status.Errorf(codes.Internal, "Unable to set service account of PackageInstall: %v", err)
+		}
+	}
+	return nil
+}
+
+func installedPackageSummaryFromUnstructured(cluster string, unstructuredInstall *unstructured.Unstructured) (*corev1.InstalledPackageSummary, error) {
+	ref, err := installedPackageRefFromUnstructured(cluster, unstructuredInstall)
+	if err != nil {
+		return nil, err
+	}
+	refName, _, _ := unstructured.NestedString(unstructuredInstall.Object, "spec", "packageRef", "refName")
+	constraints, _, _ := unstructured.NestedString(unstructuredInstall.Object, "spec", "packageRef", "versionSelection", "constraints")
+
+	return &corev1.InstalledPackageSummary{
+		InstalledPackageRef: ref,
+		Name:                ref.Identifier,
+		PkgVersionReference: &corev1.VersionReference{
+			Version: constraints,
+		},
+		CurrentVersion: currentVersionFromUnstructured(unstructuredInstall),
+		PkgDisplayName: refName,
+		Status:         installedPackageStatusFromUnstructured(unstructuredInstall),
+	}, nil
+}
+
+// installedPackageDetailFromUnstructured returns the detail of the PackageInstall,
+// with the values taken from the given values secret, if any.
+func installedPackageDetailFromUnstructured(cluster string, unstructuredInstall *unstructured.Unstructured, valuesSecret *k8scorev1.Secret) (*corev1.InstalledPackageDetail, error) {
+	ref, err := installedPackageRefFromUnstructured(cluster, unstructuredInstall)
+	if err != nil {
+		return nil, err
+	}
+	refName, _, _ := unstructured.NestedString(unstructuredInstall.Object, "spec", "packageRef", "refName")
+	constraints, _, _ := unstructured.NestedString(unstructuredInstall.Object, "spec", "packageRef", "versionSelection", "constraints")
+
+	reconcile, err := reconciliationOptionsFromUnstructured(unstructuredInstall)
+	if err != nil {
+		return nil, err
+	}
+
+	return &corev1.InstalledPackageDetail{
+		InstalledPackageRef: ref,
+		PkgVersionReference: &corev1.VersionReference{
+			Version: constraints,
+		},
+		Name:                  ref.Identifier,
+		CurrentVersion:        currentVersionFromUnstructured(unstructuredInstall),
+		ValuesApplied:         valuesFromSecret(valuesSecret),
+		ReconciliationOptions: reconcile,
+		Status:                installedPackageStatusFromUnstructured(unstructuredInstall),
+		AvailablePackageRef: &corev1.AvailablePackageReference{
+			Context: &corev1.Context{
+				Cluster:   ref.Context.Cluster,
+				Namespace: ref.Context.Namespace,
+			},
+			Identifier: refName,
+			Plugin:     GetPluginDetail(),
+		},
+	}, nil
+}
+
+// installedPackageRefFromUnstructured returns the reference to the PackageInstall on the given cluster.
+func installedPackageRefFromUnstructured(cluster string, unstructuredInstall *unstructured.Unstructured) (*corev1.InstalledPackageReference, error) {
+	// https://carvel.dev/kapp-controller/docs/latest/packaging/#packageinstall-cr
+	name, found, err := unstructured.NestedString(unstructuredInstall.Object, "metadata", "name")
+	if err != nil || !found || name == "" {
+		return nil, status.Errorf(codes.Internal, "required field metadata.name not found on PackageInstall: %v:\n%v", err, unstructuredInstall.Object)
+	}
+	namespace, found, err := unstructured.NestedString(unstructuredInstall.Object, "metadata", "namespace")
+	if err != nil || !found || namespace == "" {
+		return nil, status.Errorf(codes.Internal, "required field metadata.namespace not found on PackageInstall: %v:\n%v", err, unstructuredInstall.Object)
+	}
+	return &corev1.InstalledPackageReference{
+		Context: &corev1.Context{
+			Cluster:   cluster,
+			Namespace: namespace,
+		},
+		Identifier: name,
+		Plugin:     GetPluginDetail(),
+	}, nil
+}
+
+func currentVersionFromUnstructured(unstructuredInstall *unstructured.Unstructured) *corev1.PackageAppVersion {
+	// status.version is the version of the package last deployed by kapp-controller
+	version, found, err := unstructured.NestedString(unstructuredInstall.Object, "status", "version")
+	if err != nil || !found || version == "" {
+		return nil
+	}
+	return &corev1.PackageAppVersion{
+		PkgVersion: version,
+	}
+}
+
+func reconciliationOptionsFromUnstructured(unstructuredInstall *unstructured.Unstructured) (*corev1.ReconciliationOptions, error) {
+	reconcile := &corev1.ReconciliationOptions{}
+	if syncPeriodString, found, err := unstructured.NestedString(unstructuredInstall.Object, "spec", "syncPeriod"); err != nil {
+		return nil, status.Errorf(codes.Internal, "Unable to read sync period of PackageInstall: %v", err)
+	} else if found && syncPeriodString != "" {
+		syncPeriod, err := time.ParseDuration(syncPeriodString)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "Unable to parse sync period of PackageInstall: %v", err)
+		}
+		reconcile.Interval = int32(syncPeriod.Seconds())
+	}
+	if paused, found, err := unstructured.NestedBool(unstructuredInstall.Object, "spec", "paused"); err != nil {
+		return nil, status.Errorf(codes.Internal, "Unable to read paused flag of PackageInstall: %v", err)
+	} else if found {
+		reconcile.Suspend = paused
+	}
+	if serviceAccountName, found, err := unstructured.NestedString(unstructuredInstall.Object, "spec", "serviceAccountName"); err != nil {
+		return nil, status.Errorf(codes.Internal, "Unable to read service account of PackageInstall: %v", err)
+	} else if found {
+		reconcile.ServiceAccountName = serviceAccountName
+	}
+	return reconcile, nil
+}
+
+// installedPackageStatusFromUnstructured maps the conditions of the PackageInstall
+// to the status of the installed package. When the reconciliation failed, the
+// usefulErrorMessage of the status is surfaced to the user as it is far more
+// helpful than the condition message.
+func installedPackageStatusFromUnstructured(unstructuredInstall *unstructured.Unstructured) *corev1.InstalledPackageStatus {
+	// https://carvel.dev/kapp-controller/docs/latest/packaging/#packageinstall-cr
+	pending := &corev1.InstalledPackageStatus{
+		Ready:  false,
+		Reason: corev1.InstalledPackageStatus_STATUS_REASON_PENDING,
+	}
+
+	// Confirm the state we are observing is for the current generation
+	observedGeneration, found, err := unstructured.NestedInt64(unstructuredInstall.Object, "status", "observedGeneration")
+	if err != nil || !found || observedGeneration != unstructuredInstall.GetGeneration() {
+		return pending
+	}
+
+	conditions, found, err := unstructured.NestedSlice(unstructuredInstall.Object, "status", "conditions")
+	if err != nil || !found {
+		return pending
+	}
+	friendlyDescription, _, _ := unstructured.NestedString(unstructuredInstall.Object, "status", "friendlyDescription")
+	usefulErrorMessage, _, _ := unstructured.NestedString(unstructuredInstall.Object, "status", "usefulErrorMessage")
+
+	for _, conditionUnstructured := range conditions {
+		conditionAsMap, ok := conditionUnstructured.(map[string]interface{})
+		if !ok || conditionAsMap["status"] != "True" {
+			continue
+		}
+		message, _ := conditionAsMap["message"].(string)
+		switch conditionAsMap["type"] {
+		case "ReconcileSucceeded":
+			return &corev1.InstalledPackageStatus{
+				Ready:      true,
+				Reason:     corev1.InstalledPackageStatus_STATUS_REASON_INSTALLED,
+				UserReason: friendlyDescription,
+			}
+		case "ReconcileFailed", "DeleteFailed":
+			userReason := usefulErrorMessage
+			if userReason == "" {
+				userReason = message
+			}
+			if userReason == "" {
+				userReason = friendlyDescription
+			}
+			return &corev1.InstalledPackageStatus{
+				Ready:      false,
+				Reason:     corev1.InstalledPackageStatus_STATUS_REASON_FAILED,
+				UserReason: userReason,
+			}
+		case "Reconciling", "Deleting":
+			pending.UserReason = friendlyDescription
+			return pending
+		}
+	}
+	return pending
+}
+
+// valuesFromSecret returns the values stored in the values secret. When the
+// secret has multiple keys, the documents are concatenated in key order.
+func valuesFromSecret(valuesSecret *k8scorev1.Secret) string {
+	if valuesSecret == nil {
+		return ""
+	}
+	if values, ok := valuesSecret.Data[valuesSecretKey]; ok {
+		return string(values)
+	}
+	keys := []string{}
+	for key := range valuesSecret.Data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	documents := []string{}
+	for _, key := range keys {
+		documents = append(documents, string(valuesSecret.Data[key]))
+	}
+	return strings.Join(documents, "\n---\n")
+}
+
+// valuesSecretNameFromUnstructured returns the name of the first secret referenced
+// in the values of the PackageInstall, or an empty string if there is none.
+func valuesSecretNameFromUnstructured(unstructuredInstall *unstructured.Unstructured) string {
+	values, found, err := unstructured.NestedSlice(unstructuredInstall.Object, "spec", "values")
+	if err != nil || !found {
+		return ""
+	}
+	for _, value := range values {
+		if valueAsMap, ok := value.(map[string]interface{}); ok {
+			if name, found, err := unstructured.NestedString(valueAsMap, "secretRef", "name"); err == nil && found && name != "" {
+				return name
+			}
+		}
+	}
+	return ""
+}
+
+func validateInstalledPackageRef(installedRef *corev1.InstalledPackageReference) error {
+	if installedRef.GetContext().GetNamespace() == "" || installedRef.GetIdentifier() == "" {
+		return status.Errorf(codes.InvalidArgument, "Required context or identifier not provided for the installed package")
+	}
+	return nil
+}
+
+// statusErrorForK8sError returns a grpc status error for the k8s API error, with
+// the most common failure reasons mapped to the corresponding code.
+func statusErrorForK8sError(err error, msg string) error {
+	switch {
+	case k8serrors.IsNotFound(err):
+		return status.Errorf(codes.NotFound, "%s: %v", msg, err)
+	case k8serrors.IsAlreadyExists(err):
+		return status.Errorf(codes.AlreadyExists, "%s: %v", msg, err)
+	case k8serrors.IsForbidden(err):
+		return status.Errorf(codes.PermissionDenied, "%s: %v", msg, err)
+	case k8serrors.IsUnauthorized(err):
+		return status.Errorf(codes.Unauthenticated, "%s: %v", msg, err)
+	case k8serrors.IsInvalid(err), k8serrors.IsBadRequest(err):
+		return status.Errorf(codes.InvalidArgument, "%s: %v", msg, err)
+	}
+	return status.Errorf(codes.Internal, "%s: %v", msg, err)
+}
@@ -0,0 +1,266 @@
+/*
+Copyright © 2021 VMware
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"fmt"
+	"time"
+
+	corev1 "github.com/kubeapps/kubeapps/cmd/kubeapps-apis/gen/core/packages/v1alpha1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const (
+	// the fetch type used when the request does not specify one
+	defaultRepositoryType = "imgpkgBundle"
+)
+
+// repositoryUrlPaths maps each fetch type supported by a PackageRepository to the
+// path of its url, see the PackageRepository CR at
+// https://carvel.dev/kapp-controller/docs/latest/packaging/#packagerepository-cr
+var repositoryUrlPaths = map[string][]string{
+	"imgpkgBundle": {"spec", "fetch", "imgpkgBundle", "image"},
+	"image":        {"spec", "fetch", "image", "url"},
+	"http":         {"spec", "fetch", "http", "url"},
+	"git":          {"spec", "fetch", "git", "url"},
+}
+
+// repositoryTypes is the order in which the fetch types are looked up.
+var repositoryTypes = []string{"imgpkgBundle", "image", "http", "git"}
+
+// newPackageRepository returns a PackageRepository object for the repository
+// requested to be added.
+func newPackageRepository(request *corev1.AddPackageRepositoryRequest) (*unstructured.Unstructured, error) {
+	repoType := request.GetType()
+	if repoType == "" {
+		repoType = defaultRepositoryType
+	}
+	if _, ok := repositoryUrlPaths[repoType]; !ok {
+		return nil, status.Errorf(codes.InvalidArgument, "Repository type [%s] not supported by the kapp_controller plugin", repoType)
+	}
+
+	unstructuredRepo := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": fmt.Sprintf("%s/%s", packagingGroup, installPackageVersion),
+			"kind":       repositoryResource,
+			"metadata": map[string]interface{}{
+				"name":      request.GetName(),
+				"namespace": request.GetContext().GetNamespace(),
+			},
+			"spec": map[string]interface{}{
+				"fetch": map[string]interface{}{
+					repoType: map[string]interface{}{},
+				},
+			},
+		},
+	}
+
+	if err := setPackageRepositorySpec(unstructuredRepo, request.GetUrl(), request.GetInterval(), request.GetAuth(), request.GetTlsConfig()); err != nil {
+		return nil, err
+	}
+	return unstructuredRepo, nil
+}
+
+// setPackageRepositorySpec sets the url, sync period and secret of the fetch type
+// of the PackageRepository. A zero interval leaves the current sync period untouched.
+func setPackageRepositorySpec(unstructuredRepo *unstructured.Unstructured, url string, interval uint32, auth *corev1.PackageRepositoryAuth, tlsConfig *corev1.PackageRepositoryTlsConfig) error {
+	if url == "" {
+		return status.Errorf(codes.InvalidArgument, "Required repository url not provided")
+	}
+	if auth.GetUsernamePassword() != nil || auth.GetHeader() != "" {
+		return status.Errorf(codes.InvalidArgument, "Inline credentials are not supported by the kapp_controller plugin, reference an existing secret instead")
+	}
+	if auth.GetPassCredentials() {
+		return status.Errorf(codes.InvalidArgument, "Passing credentials is not supported by the kapp_controller plugin")
+	}
+	if tlsConfig != nil {
+		return status.Errorf(codes.InvalidArgument, "TLS configuration is not supported by the kapp_controller plugin")
+	}
+
+	repoType, err := packageRepositoryType(unstructuredRepo)
+	if err != nil {
+		return err
+	}
+	urlPath := repositoryUrlPaths[repoType]
+	if err := unstructured.SetNestedField(unstructuredRepo.Object, url, urlPath...); err != nil {
+		return status.Errorf(codes.Internal, "Unable to set url of PackageRepository: %v", err)
+	}
+
+	if interval > 0 {
+		syncPeriod := (time.Duration(interval) * time.Second).String()
+		if err := unstructured.SetNestedField(unstructuredRepo.Object, syncPeriod, "spec", "syncPeriod"); err != nil {
+			return status.Errorf(codes.Internal, "Unable to set sync period of PackageRepository: %v", err)
+		}
+	}
+
+	secretRefPath := []string{"spec", "fetch", repoType, "secretRef"}
+	if secretName := auth.GetSecretRef().GetName(); secretName != "" {
+		if err := unstructured.SetNestedField(unstructuredRepo.Object, secretName, append(secretRefPath, "name")...); err != nil {
+			return status.Errorf(codes.Internal, "Unable to set secret of PackageRepository: %v", err)
+		}
+	} else {
+		unstructured.RemoveNestedField(unstructuredRepo.Object, secretRefPath...)
+	}
+	return nil
+}
+
+// packageRepositoryType returns the fetch type of the PackageRepository.
+func packageRepositoryType(unstructuredRepo *unstructured.Unstructured) (string, error) {
+	for _, repoType := range repositoryTypes {
+		if _, found, err := unstructured.NestedMap(unstructuredRepo.Object, "spec", "fetch", repoType); err == nil && found {
+			return repoType, nil
+		}
+	}
+	return "", status.Errorf(codes.Internal, "packagerepository without fetch of one of imgpkgBundle, image, http or git: %v", unstructuredRepo.Object)
+}
+
+// packageRepositoryRefFromUnstructured returns the reference to the PackageRepository on the given cluster.
+func packageRepositoryRefFromUnstructured(cluster string, unstructuredRepo *unstructured.Unstructured) (*corev1.PackageRepositoryReference, error) {
+	// https://carvel.dev/kapp-controller/docs/latest/packaging/#packagerepository-cr
+	name, found, err := unstructured.NestedString(unstructuredRepo.Object, "metadata", "name")
+	if err != nil || !found || name == "" {
+		return nil, status.Errorf(codes.Internal, "required field metadata.name not found on PackageRepository: %v:\n%v", err, unstructuredRepo.Object)
+	}
+	namespace, found, err := unstructured.NestedString(unstructuredRepo.Object, "metadata", "namespace")
+	if err != nil || !found || namespace == "" {
+		return nil, status.Errorf(codes.Internal, "required field metadata.namespace not found on PackageRepository: %v:\n%v", err, unstructuredRepo.Object)
+	}
+	return &corev1.PackageRepositoryReference{
+		Context: &corev1.Context{
+			Cluster:   cluster,
+			Namespace: namespace,
+		},
+		Identifier: name,
+		Plugin:     GetPluginDetail(),
+	}, nil
+}
+
+func packageRepositorySummaryFromUnstructured(cluster string, unstructuredRepo *unstructured.Unstructured) (*corev1.PackageRepositorySummary, error) {
+	ref, err := packageRepositoryRefFromUnstructured(cluster, unstructuredRepo)
+	if err != nil {
+		return nil, err
+	}
+	repoType, err := packageRepositoryType(unstructuredRepo)
+	if err != nil {
+		return nil, err
+	}
+	url, found, err := unstructured.NestedString(unstructuredRepo.Object, repositoryUrlPaths[repoType]...)
+	if err != nil || !found {
+		return nil, status.Errorf(codes.Internal, "required field %v not found on PackageRepository: %v:\n%v", repositoryUrlPaths[repoType], err, unstructuredRepo.Object)
+	}
+	return &corev1.PackageRepositorySummary{
+		PackageRepoRef: ref,
+		Name:           ref.Identifier,
+		Type:           repoType,
+		Url:            url,
+		Status:         packageRepositoryStatusFromUnstructured(unstructuredRepo),
+	}, nil
+}
+
+func packageRepositoryDetailFromUnstructured(cluster string, unstructuredRepo *unstructured.Unstructured) (*corev1.PackageRepositoryDetail, error) {
+	summary, err := packageRepositorySummaryFromUnstructured(cluster, unstructuredRepo)
+	if err != nil {
+		return nil, err
+	}
+	detail := &corev1.PackageRepositoryDetail{
+		PackageRepoRef: summary.PackageRepoRef,
+		Name:           summary.Name,
+		Type:           summary.Type,
+		Url:            summary.Url,
+		Status:         summary.Status,
+	}
+
+	if syncPeriodString, found, err := unstructured.NestedString(unstructuredRepo.Object, "spec", "syncPeriod"); err != nil {
+		return nil, status.Errorf(codes.Internal, "Unable to read sync period of PackageRepository: %v", err)
+	} else if found && syncPeriodString != "" {
+		syncPeriod, err := time.ParseDuration(syncPeriodString)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "Unable to parse sync period of PackageRepository: %v", err)
+		}
+		detail.Interval = uint32(syncPeriod.Seconds())
+	}
+
+	if secretName, found, err := unstructured.NestedString(unstructuredRepo.Object, "spec", "fetch", summary.Type, "secretRef", "name"); err == nil && found && secretName != "" {
+		detail.Auth = &corev1.PackageRepositoryAuth{
+			PackageRepoAuthOneOf: &corev1.PackageRepositoryAuth_SecretRef{
+				SecretRef: &corev1.SecretKeyReference{Name: secretName},
+			},
+		}
+	}
+	return detail, nil
+}
+
+// packageRepositoryStatusFromUnstructured maps the conditions of the PackageRepository
+// to the status of the package repository, in the same way as for PackageInstalls.
+func packageRepositoryStatusFromUnstructured(unstructuredRepo *unstructured.Unstructured) *corev1.PackageRepositoryStatus {
+	// https://carvel.dev/kapp-controller/docs/latest/packaging/#packagerepository-cr
+	pending := &corev1.PackageRepositoryStatus{
+		Ready:  false,
+		Reason: corev1.PackageRepositoryStatus_STATUS_REASON_PENDING,
+	}
+
+	// Confirm the state we are observing is for the current generation
+	observedGeneration, found, err := unstructured.NestedInt64(unstructuredRepo.Object, "status", "observedGeneration")
+	if err != nil || !found || observedGeneration != unstructuredRepo.GetGeneration() {
+		return pending
+	}
+
+	conditions, found, err := unstructured.NestedSlice(unstructuredRepo.Object, "status", "conditions")
+	if err != nil || !found {
+		return pending
+	}
+	friendlyDescription, _, _ := unstructured.NestedString(unstructuredRepo.Object, "status", "friendlyDescription")
+	usefulErrorMessage, _, _ := unstructured.NestedString(unstructuredRepo.Object, "status", "usefulErrorMessage")
+
+	for _, conditionUnstructured := range conditions {
+		conditionAsMap, ok := conditionUnstructured.(map[string]interface{})
+		if !ok || conditionAsMap["status"] != "True" {
+			continue
+		}
+		message, _ := conditionAsMap["message"].(string)
+		switch conditionAsMap["type"] {
+		case "ReconcileSucceeded":
+			return &corev1.PackageRepositoryStatus{
+				Ready:      true,
+				Reason:     corev1.PackageRepositoryStatus_STATUS_REASON_SUCCESS,
+				UserReason: friendlyDescription,
+			}
+		case "ReconcileFailed":
+			userReason := usefulErrorMessage
+			if userReason == "" {
+				userReason = message
+			}
+			if userReason == "" {
+				userReason = friendlyDescription
+			}
+			return &corev1.PackageRepositoryStatus{
+				Ready:      false,
+				Reason:     corev1.PackageRepositoryStatus_STATUS_REASON_FAILED,
+				UserReason: userReason,
+			}
+		case "Reconciling":
+			pending.UserReason = friendlyDescription
+			return pending
+		}
+	}
+	return pending
+}
+
+func validatePackageRepositoryRef(repoRef *corev1.PackageRepositoryReference) error {
+	if repoRef.GetContext().GetNamespace() == "" || repoRef.GetIdentifier() == "" {
+		return status.Errorf(codes.InvalidArgument, "Required context or identifier not provided for the package repository")
+	}
+	return nil
+}
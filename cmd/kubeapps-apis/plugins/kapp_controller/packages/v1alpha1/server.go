@@ -15,6 +15,8 @@ package main
 import (
 	"context"
 	"fmt"
+	"strconv"
+	"strings"
 
 	// v1 "github.com/kubeapps/kubeapps/cmd/kubeapps-api-service/kubeappsapis/core/packagerepositories/v1"
 	// *sigh*, seems different versions of the k8s client.go (at the time of writing, kapp-controller
@@ -25,6 +27,8 @@ import (
 		        want (context.Context)
 	*/
 	// So instead we use the dynamic (untyped) client.
+	k8scorev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
@@ -34,6 +38,7 @@ import (
 	log "k8s.io/klog/v2"
 
 	corev1 "github.com/kubeapps/kubeapps/cmd/kubeapps-apis/gen/core/packages/v1alpha1"
+	plugins "github.com/kubeapps/kubeapps/cmd/kubeapps-apis/gen/core/plugins/v1alpha1"
 	"github.com/kubeapps/kubeapps/cmd/kubeapps-apis/gen/plugins/kapp_controller/packages/v1alpha1"
 	"github.com/kubeapps/kubeapps/cmd/kubeapps-apis/server"
 	"google.golang.org/grpc/codes"
@@ -43,25 +48,36 @@ import (
 const (
 	packagingGroup = "packaging.carvel.dev"
 
-	// See https://carvel.dev/kapp-controller/docs/latest/packaging/#package-cr
-	packageVersion   = "v1alpha1"
-	packageResource  = "PackageInstall"
-	packagesResource = "packageinstalls"
-
 	// See https://carvel.dev/kapp-controller/docs/latest/packaging/#packagerepository-cr
 	installPackageVersion = "v1alpha1"
 	repositoryResource    = "PackageRepository"
 	repositoriesResource  = "packagerepositories"
 
+	// See https://carvel.dev/kapp-controller/docs/latest/packaging/#packageinstall-cr
+	packageInstallVersion   = "v1alpha1"
+	packageInstallResource  = "PackageInstall"
+	packageInstallsResource = "packageinstalls"
+
+	// See https://carvel.dev/kapp-controller/docs/latest/packaging/#package
+	dataPackagingGroup   = "data.packaging.carvel.dev"
+	dataPackagingVersion = "v1alpha1"
+	pkgResource          = "Package"
+	pkgsResource         = "packages"
+	pkgMetadataResource  = "PackageMetadata"
+	pkgMetadatasResource = "packagemetadatas"
+
 	globalPackagingNamespace = "kapp-controller-packaging-global"
 )
 
 // Compile-time statement to ensure this service implementation satisfies the core packaging API
 var _ corev1.PackagesServiceServer = (*Server)(nil)
+var _ corev1.RepositoriesServiceServer = (*Server)(nil)
+var _ server.PluginDependenciesChecker = (*Server)(nil)
 
 // Server implements the kapp-controller packages v1alpha1 interface.
 type Server struct {
 	v1alpha1.UnimplementedKappControllerPackagesServiceServer
+	v1alpha1.UnimplementedKappControllerRepositoriesServiceServer
 	// clientGetter is a field so that it can be switched in tests for
 	// a fake client. NewServer() below sets this automatically with the
 	// non-test implementation.
@@ -76,18 +92,37 @@ func NewServer(clientGetter server.KubernetesClientGetter) *Server {
 	}
 }
 
-// GetClients ensures a client getter is available and uses it to return both a typed and dynamic k8s client.
-func (s *Server) GetClients(ctx context.Context) (kubernetes.Interface, dynamic.Interface, error) {
+// GetClients ensures a client getter is available and uses it to return both a typed and dynamic k8s client
+// for the given cluster.
+func (s *Server) GetClients(ctx context.Context, cluster string) (kubernetes.Interface, dynamic.Interface, error) {
 	if s.clientGetter == nil {
 		return nil, nil, status.Errorf(codes.Internal, "server not configured with configGetter")
 	}
-	typedClient, dynamicClient, err := s.clientGetter(ctx)
+	typedClient, dynamicClient, err := s.clientGetter(ctx, cluster)
 	if err != nil {
 		return nil, nil, status.Errorf(codes.FailedPrecondition, fmt.Sprintf("unable to get client : %v", err))
 	}
 	return typedClient, dynamicClient, nil
 }
 
+// CheckDependencies checks that the kapp-controller CRDs are installed on the
+// cluster on which Kubeapps is installed.
+func (s *Server) CheckDependencies(ctx context.Context) []*plugins.PluginDependencyStatus {
+	gvrs := []schema.GroupVersionResource{pkgGVR(), pkgMetadataGVR(), packageRepositoryGVR(), packageInstallGVR()}
+	typedClient, _, err := s.GetClients(ctx, "")
+	if err != nil {
+		dependencies := []*plugins.PluginDependencyStatus{}
+		for _, gvr := range gvrs {
+			dependencies = append(dependencies, &plugins.PluginDependencyStatus{
+				Name:    gvr.GroupResource().String(),
+				Message: err.Error(),
+			})
+		}
+		return dependencies
+	}
+	return server.CheckGroupVersionResources(typedClient.Discovery(), gvrs)
+}
+
 // GetAvailablePackageSummaries returns the available packages based on the request.
 func (s *Server) GetAvailablePackageSummaries(ctx context.Context, request *corev1.GetAvailablePackageSummariesRequest) (*corev1.GetAvailablePackageSummariesResponse, error) {
 	contextMsg := ""
@@ -99,144 +134,621 @@ func (s *Server) GetAvailablePackageSummaries(ctx context.Context, request *core
 
 	namespace := ""
 	if request.Context != nil {
-		if request.Context.Cluster != "" {
-			return nil, status.Errorf(codes.Unimplemented, "Not supported yet: request.Context.Cluster: [%v]", request.Context.Cluster)
-		}
 		if request.Context.Namespace != "" {
 			namespace = request.Context.Namespace
 		}
 	}
 
-	_, client, err := s.GetClients(ctx)
+	pageOffset, err := pageOffsetFromPageToken(request.GetPaginationOptions().GetPageToken())
 	if err != nil {
 		return nil, err
 	}
 
-	packageResource := schema.GroupVersionResource{Group: packagingGroup, Version: packageVersion, Resource: packagesResource}
+	_, client, err := s.GetClients(ctx, request.GetContext().GetCluster())
+	if err != nil {
+		return nil, err
+	}
 
-	pkgs, err := client.Resource(packageResource).Namespace(namespace).List(ctx, metav1.ListOptions{})
+	pkgMetadatas, err := client.Resource(pkgMetadataGVR()).Namespace(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, statusErrorForK8sError(err, "unable to list kapp-controller package metadatas")
+	}
+	pkgs, err := client.Resource(pkgGVR()).Namespace(namespace).List(ctx, metav1.ListOptions{})
 	if err != nil {
-		return nil, status.Errorf(codes.Internal, fmt.Sprintf("unable to list kapp-controller packages: %v", err))
+		return nil, statusErrorForK8sError(err, "unable to list kapp-controller packages")
 	}
 
 	responsePackages := []*corev1.AvailablePackageSummary{}
-	for _, pkgUnstructured := range pkgs.Items {
-		pkg, err := AvailablePackageSummaryFromUnstructured(&pkgUnstructured)
-		if err != nil {
-			return nil, err
+	for i := range pkgMetadatas.Items {
+		pkgMetadata := &pkgMetadatas.Items[i]
+		// Packages are matched with their metadata within the same namespace only.
+		nsPkgs := []unstructured.Unstructured{}
+		for _, pkg := range pkgs.Items {
+			if pkg.GetNamespace() == pkgMetadata.GetNamespace() {
+				nsPkgs = append(nsPkgs, pkg)
+			}
 		}
-		responsePackages = append(responsePackages, pkg)
+		versions := packageVersionsForRefName(nsPkgs, pkgMetadata.GetName())
+		// A package without any valid version cannot be installed, so it is not available.
+		if len(versions) == 0 {
+			continue
+		}
+		if !packageMatchesFilterOptions(pkgMetadata, versions, request.GetFilterOptions()) {
+			continue
+		}
+		responsePackages = append(responsePackages, availablePackageSummaryFromUnstructured(request.GetContext().GetCluster(), pkgMetadata, versions[0].pkg))
 	}
+
+	// Sort before paginating so that each page continues where the previous
+	// one left off, and so that the core server can merge the results.
+	server.SortAvailablePackageSummaries(responsePackages, request.GetSortOptions())
+	start, end, nextPageToken := pageBounds(len(responsePackages), pageOffset, request.GetPaginationOptions().GetPageSize())
 	return &corev1.GetAvailablePackageSummariesResponse{
-		AvailablePackagesSummaries: responsePackages,
+		AvailablePackagesSummaries: responsePackages[start:end],
+		NextPageToken:              nextPageToken,
+	}, nil
+}
+
+// GetAvailablePackageDetail returns the detail of the package referenced in the request,
+// joining its PackageMetadata with the Package for the requested version, or the latest
+// version if none is requested.
+func (s *Server) GetAvailablePackageDetail(ctx context.Context, request *corev1.GetAvailablePackageDetailRequest) (*corev1.GetAvailablePackageDetailResponse, error) {
+	packageRef := request.GetAvailablePackageRef()
+	if packageRef.GetContext().GetNamespace() == "" || packageRef.GetIdentifier() == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "Required context or identifier not provided")
+	}
+	contextMsg := fmt.Sprintf("(cluster=[%s], namespace=[%s])", packageRef.Context.Cluster, packageRef.Context.Namespace)
+	log.Infof("+kapp_controller GetAvailablePackageDetail %s", contextMsg)
+
+	_, client, err := s.GetClients(ctx, packageRef.Context.Cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	namespace, refName := packageRef.Context.Namespace, packageRef.Identifier
+	pkgMetadata, err := client.Resource(pkgMetadataGVR()).Namespace(namespace).Get(ctx, refName, metav1.GetOptions{})
+	if err != nil {
+		return nil, statusErrorForK8sError(err, fmt.Sprintf("unable to get kapp-controller package metadata [%s]", refName))
+	}
+
+	versions, err := s.getPackageVersions(ctx, client, namespace, refName)
+	if err != nil {
+		return nil, err
+	}
+
+	var pkg *unstructured.Unstructured
+	for _, v := range versions {
+		if request.PkgVersion == "" || request.PkgVersion == v.version.Original() {
+			pkg = v.pkg
+			break
+		}
+	}
+	if pkg == nil {
+		return nil, status.Errorf(codes.NotFound, "unable to find kapp-controller package [%s] with version [%s]", refName, request.PkgVersion)
+	}
+
+	detail, err := availablePackageDetailFromUnstructured(packageRef.Context.Cluster, pkgMetadata, pkg)
+	if err != nil {
+		return nil, err
+	}
+	return &corev1.GetAvailablePackageDetailResponse{
+		AvailablePackageDetail: detail,
 	}, nil
 }
 
-func AvailablePackageSummaryFromUnstructured(ap *unstructured.Unstructured) (*corev1.AvailablePackageSummary, error) {
-	pkg := &corev1.AvailablePackageSummary{}
+// GetAvailablePackageVersions returns the versions of the package referenced in the request,
+// from the latest to the oldest, across all the Package CRs with the same refName.
+func (s *Server) GetAvailablePackageVersions(ctx context.Context, request *corev1.GetAvailablePackageVersionsRequest) (*corev1.GetAvailablePackageVersionsResponse, error) {
+	packageRef := request.GetAvailablePackageRef()
+	if packageRef.GetContext().GetNamespace() == "" || packageRef.GetIdentifier() == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "Required context or identifier not provided")
+	}
+	contextMsg := fmt.Sprintf("(cluster=[%s], namespace=[%s])", packageRef.Context.Cluster, packageRef.Context.Namespace)
+	log.Infof("+kapp_controller GetAvailablePackageVersions %s", contextMsg)
+
+	_, client, err := s.GetClients(ctx, packageRef.Context.Cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	versions, err := s.getPackageVersions(ctx, client, packageRef.Context.Namespace, packageRef.Identifier)
+	if err != nil {
+		return nil, err
+	}
 
-	// https://carvel.dev/kapp-controller/docs/latest/packaging/#package-cr
-	name, found, err := unstructured.NestedString(ap.Object, "spec", "packageRef", "refName")
-	if err != nil || !found {
-		return nil, status.Errorf(codes.Internal, "required field spec.packageRef.refName not found on kapp-controller package: %v:\n%v", err, ap.Object)
+	pkgVersions := []*corev1.GetAvailablePackageVersionsResponse_PackageAppVersion{}
+	for _, v := range versions {
+		pkgVersions = append(pkgVersions, &corev1.GetAvailablePackageVersionsResponse_PackageAppVersion{
+			PkgVersion: v.version.Original(),
+		})
 	}
-	pkg.DisplayName = name
+	return &corev1.GetAvailablePackageVersionsResponse{
+		PackageAppVersions: pkgVersions,
+	}, nil
+}
 
-	// https://carvel.dev/kapp-controller/docs/latest/packaging/#package-cr
-	version, found, err := unstructured.NestedString(ap.Object, "status", "version")
-	if err != nil || !found {
-		return nil, status.Errorf(codes.Internal, "required field status.version not found on kapp-controller package: %v:\n%v", err, ap.Object)
+// WatchAvailablePackages streams the changes of the available packages.
+func (s *Server) WatchAvailablePackages(request *corev1.WatchAvailablePackagesRequest, stream corev1.PackagesService_WatchAvailablePackagesServer) error {
+	return status.Errorf(codes.Unimplemented, "Watching the available packages is not supported by the 'kapp_controller' plugin")
+}
+
+// getPackageVersions returns the Package CRs in the namespace with the given refName, sorted by version.
+func (s *Server) getPackageVersions(ctx context.Context, client dynamic.Interface, namespace string, refName string) ([]versionedPackage, error) {
+	// Field selectors are not supported for custom resources, so the packages are filtered here.
+	pkgs, err := client.Resource(pkgGVR()).Namespace(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, statusErrorForK8sError(err, "unable to list kapp-controller packages")
 	}
-	pkg.LatestPkgVersion = version
-	return pkg, nil
+	return packageVersionsForRefName(pkgs.Items, refName), nil
 }
 
-// GetPackageRepositories returns the package repositories based on the request.
-func (s *Server) GetPackageRepositories(ctx context.Context, request *v1alpha1.GetPackageRepositoriesRequest) (*v1alpha1.GetPackageRepositoriesResponse, error) {
+// GetPackageRepositorySummaries returns the PackageRepositories in the request context namespace,
+// or in the global packaging namespace if none is specified.
+func (s *Server) GetPackageRepositorySummaries(ctx context.Context, request *corev1.GetPackageRepositorySummariesRequest) (*corev1.GetPackageRepositorySummariesResponse, error) {
 	contextMsg := ""
 	if request.Context != nil {
 		contextMsg = fmt.Sprintf("(cluster=[%s], namespace=[%s])", request.Context.Cluster, request.Context.Namespace)
 	}
 
-	log.Infof("+kapp_controller GetPackageRepositories %s", contextMsg)
+	log.Infof("+kapp_controller GetPackageRepositorySummaries %s", contextMsg)
 
 	namespace := globalPackagingNamespace
 	if request.Context != nil {
-		if request.Context.Cluster != "" {
-			return nil, status.Errorf(codes.Unimplemented, "Not supported yet: request.Context.Cluster: [%v]", request.Context.Cluster)
-		}
 		if request.Context.Namespace != "" {
 			namespace = request.Context.Namespace
 		}
 	}
 
-	_, client, err := s.GetClients(ctx)
+	cluster := request.GetContext().GetCluster()
+	_, client, err := s.GetClients(ctx, cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	repos, err := client.Resource(packageRepositoryGVR()).Namespace(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, statusErrorForK8sError(err, "unable to list kapp-controller repositories")
+	}
+
+	summaries := []*corev1.PackageRepositorySummary{}
+	for i := range repos.Items {
+		summary, err := packageRepositorySummaryFromUnstructured(cluster, &repos.Items[i])
+		if err != nil {
+			return nil, err
+		}
+		summaries = append(summaries, summary)
+	}
+	return &corev1.GetPackageRepositorySummariesResponse{
+		PackageRepositorySummaries: summaries,
+	}, nil
+}
+
+// GetPackageRepositoryDetail returns the PackageRepository referenced in the request.
+func (s *Server) GetPackageRepositoryDetail(ctx context.Context, request *corev1.GetPackageRepositoryDetailRequest) (*corev1.GetPackageRepositoryDetailResponse, error) {
+	repoRef := request.GetPackageRepoRef()
+	log.Infof("+kapp_controller GetPackageRepositoryDetail (namespace=[%s], identifier=[%s])", repoRef.GetContext().GetNamespace(), repoRef.GetIdentifier())
+
+	if err := validatePackageRepositoryRef(repoRef); err != nil {
+		return nil, err
+	}
+
+	_, client, err := s.GetClients(ctx, repoRef.Context.Cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	repo, err := client.Resource(packageRepositoryGVR()).Namespace(repoRef.Context.Namespace).Get(ctx, repoRef.Identifier, metav1.GetOptions{})
+	if err != nil {
+		return nil, statusErrorForK8sError(err, fmt.Sprintf("unable to get kapp-controller repository [%s]", repoRef.Identifier))
+	}
+
+	detail, err := packageRepositoryDetailFromUnstructured(repoRef.Context.Cluster, repo)
+	if err != nil {
+		return nil, err
+	}
+	return &corev1.GetPackageRepositoryDetailResponse{
+		Detail: detail,
+	}, nil
+}
+
+// AddPackageRepository creates a PackageRepository fetching the packages from the
+// url in the request, using the fetch type given as the repository type.
+func (s *Server) AddPackageRepository(ctx context.Context, request *corev1.AddPackageRepositoryRequest) (*corev1.AddPackageRepositoryResponse, error) {
+	log.Infof("+kapp_controller AddPackageRepository (namespace=[%s], name=[%s])", request.GetContext().GetNamespace(), request.GetName())
+
+	if request.GetContext().GetNamespace() == "" || request.Name == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "Required namespace or name not provided")
+	}
+	repo, err := newPackageRepository(request)
+	if err != nil {
+		return nil, err
+	}
+
+	_, client, err := s.GetClients(ctx, request.Context.Cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	newRepo, err := client.Resource(packageRepositoryGVR()).Namespace(request.Context.Namespace).Create(ctx, repo, metav1.CreateOptions{})
+	if err != nil {
+		return nil, statusErrorForK8sError(err, fmt.Sprintf("unable to create kapp-controller repository [%s]", request.Name))
+	}
+
+	repoRef, err := packageRepositoryRefFromUnstructured(request.Context.Cluster, newRepo)
+	if err != nil {
+		return nil, err
+	}
+	return &corev1.AddPackageRepositoryResponse{
+		PackageRepoRef: repoRef,
+	}, nil
+}
+
+// UpdatePackageRepository updates the url, sync period and secret of the
+// PackageRepository referenced in the request. The fetch type is not changed.
+func (s *Server) UpdatePackageRepository(ctx context.Context, request *corev1.UpdatePackageRepositoryRequest) (*corev1.UpdatePackageRepositoryResponse, error) {
+	repoRef := request.GetPackageRepoRef()
+	log.Infof("+kapp_controller UpdatePackageRepository (namespace=[%s], identifier=[%s])", repoRef.GetContext().GetNamespace(), repoRef.GetIdentifier())
+
+	if err := validatePackageRepositoryRef(repoRef); err != nil {
+		return nil, err
+	}
+
+	_, client, err := s.GetClients(ctx, repoRef.Context.Cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	resourceIfc := client.Resource(packageRepositoryGVR()).Namespace(repoRef.Context.Namespace)
+	repo, err := resourceIfc.Get(ctx, repoRef.Identifier, metav1.GetOptions{})
+	if err != nil {
+		return nil, statusErrorForK8sError(err, fmt.Sprintf("unable to get kapp-controller repository [%s]", repoRef.Identifier))
+	}
+
+	if err = setPackageRepositorySpec(repo, request.Url, request.Interval, request.Auth, request.TlsConfig); err != nil {
+		return nil, err
+	}
+
+	updatedRepo, err := resourceIfc.Update(ctx, repo, metav1.UpdateOptions{})
+	if err != nil {
+		return nil, statusErrorForK8sError(err, fmt.Sprintf("unable to update kapp-controller repository [%s]", repoRef.Identifier))
+	}
+
+	updatedRef, err := packageRepositoryRefFromUnstructured(repoRef.Context.Cluster, updatedRepo)
+	if err != nil {
+		return nil, err
+	}
+	return &corev1.UpdatePackageRepositoryResponse{
+		PackageRepoRef: updatedRef,
+	}, nil
+}
+
+// DeletePackageRepository deletes the PackageRepository referenced in the request.
+func (s *Server) DeletePackageRepository(ctx context.Context, request *corev1.DeletePackageRepositoryRequest) (*corev1.DeletePackageRepositoryResponse, error) {
+	repoRef := request.GetPackageRepoRef()
+	log.Infof("+kapp_controller DeletePackageRepository (namespace=[%s], identifier=[%s])", repoRef.GetContext().GetNamespace(), repoRef.GetIdentifier())
+
+	if err := validatePackageRepositoryRef(repoRef); err != nil {
+		return nil, err
+	}
+
+	_, client, err := s.GetClients(ctx, repoRef.Context.Cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	err = client.Resource(packageRepositoryGVR()).Namespace(repoRef.Context.Namespace).Delete(ctx, repoRef.Identifier, metav1.DeleteOptions{})
+	if err != nil {
+		return nil, statusErrorForK8sError(err, fmt.Sprintf("unable to delete kapp-controller repository [%s]", repoRef.Identifier))
+	}
+	return &corev1.DeletePackageRepositoryResponse{}, nil
+}
+
+// RefreshPackageRepository triggers the reconciliation of the PackageRepository referenced
+// in the request by pausing and unpausing it, as done by "kctrl package repository kick".
+func (s *Server) RefreshPackageRepository(ctx context.Context, request *corev1.RefreshPackageRepositoryRequest) (*corev1.RefreshPackageRepositoryResponse, error) {
+	repoRef := request.GetPackageRepoRef()
+	log.Infof("+kapp_controller RefreshPackageRepository (namespace=[%s], identifier=[%s])", repoRef.GetContext().GetNamespace(), repoRef.GetIdentifier())
+
+	if err := validatePackageRepositoryRef(repoRef); err != nil {
+		return nil, err
+	}
+
+	_, client, err := s.GetClients(ctx, repoRef.Context.Cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	resourceIfc := client.Resource(packageRepositoryGVR()).Namespace(repoRef.Context.Namespace)
+	repo, err := resourceIfc.Get(ctx, repoRef.Identifier, metav1.GetOptions{})
+	if err != nil {
+		return nil, statusErrorForK8sError(err, fmt.Sprintf("unable to get kapp-controller repository [%s]", repoRef.Identifier))
+	}
+
+	for _, paused := range []bool{true, false} {
+		if err = unstructured.SetNestedField(repo.Object, paused, "spec", "paused"); err != nil {
+			return nil, status.Errorf(codes.Internal, "Unable to set paused flag of PackageRepository: %v", err)
+		}
+		if repo, err = resourceIfc.Update(ctx, repo, metav1.UpdateOptions{}); err != nil {
+			return nil, statusErrorForK8sError(err, fmt.Sprintf("unable to refresh kapp-controller repository [%s]", repoRef.Identifier))
+		}
+	}
+
+	refreshedRef, err := packageRepositoryRefFromUnstructured(repoRef.Context.Cluster, repo)
+	if err != nil {
+		return nil, err
+	}
+	return &corev1.RefreshPackageRepositoryResponse{
+		PackageRepoRef: refreshedRef,
+	}, nil
+}
+
+// GetInstalledPackageSummaries returns the PackageInstalls in the request context namespace
+// (or all namespaces, if empty).
+func (s *Server) GetInstalledPackageSummaries(ctx context.Context, request *corev1.GetInstalledPackageSummariesRequest) (*corev1.GetInstalledPackageSummariesResponse, error) {
+	contextMsg := ""
+	if request.Context != nil {
+		contextMsg = fmt.Sprintf("(cluster=[%s], namespace=[%s])", request.Context.Cluster, request.Context.Namespace)
+	}
+
+	log.Infof("+kapp_controller GetInstalledPackageSummaries %s", contextMsg)
+
+	pageOffset, err := pageOffsetFromPageToken(request.GetPaginationOptions().GetPageToken())
 	if err != nil {
 		return nil, err
 	}
 
-	repositoryResource := schema.GroupVersionResource{Group: packagingGroup, Version: installPackageVersion, Resource: repositoriesResource}
+	_, client, err := s.GetClients(ctx, request.GetContext().GetCluster())
+	if err != nil {
+		return nil, err
+	}
 
-	// Currently checks globally. Update to handle namespaced requests (?)
-	repos, err := client.Resource(repositoryResource).Namespace(namespace).List(ctx, metav1.ListOptions{})
+	installs, err := client.Resource(packageInstallGVR()).Namespace(request.GetContext().GetNamespace()).List(ctx, metav1.ListOptions{})
 	if err != nil {
-		return nil, fmt.Errorf("unable to list kapp-controller repositories: %w", err)
+		return nil, statusErrorForK8sError(err, "unable to list kapp-controller package installs")
 	}
 
-	responseRepos := []*v1alpha1.PackageRepository{}
-	for _, repoUnstructured := range repos.Items {
-		repo, err := packageRepositoryFromUnstructured(&repoUnstructured)
+	start, end, nextPageToken := pageBounds(len(installs.Items), pageOffset, request.GetPaginationOptions().GetPageSize())
+	responsePackages := []*corev1.InstalledPackageSummary{}
+	for _, installUnstructured := range installs.Items[start:end] {
+		pkg, err := installedPackageSummaryFromUnstructured(request.GetContext().GetCluster(), &installUnstructured)
 		if err != nil {
 			return nil, err
 		}
-		responseRepos = append(responseRepos, repo)
+		responsePackages = append(responsePackages, pkg)
 	}
-	return &v1alpha1.GetPackageRepositoriesResponse{
-		Repositories: responseRepos,
+	return &corev1.GetInstalledPackageSummariesResponse{
+		InstalledPackagesSummaries: responsePackages,
+		NextPageToken:              nextPageToken,
 	}, nil
 }
 
-func packageRepositoryFromUnstructured(pr *unstructured.Unstructured) (*v1alpha1.PackageRepository, error) {
-	repo := &v1alpha1.PackageRepository{}
+// GetInstalledPackageDetail returns the PackageInstall referenced in the request, together
+// with the values stored in its values secret.
+func (s *Server) GetInstalledPackageDetail(ctx context.Context, request *corev1.GetInstalledPackageDetailRequest) (*corev1.GetInstalledPackageDetailResponse, error) {
+	installedRef := request.GetInstalledPackageRef()
+	log.Infof("+kapp_controller GetInstalledPackageDetail (namespace=[%s], identifier=[%s])", installedRef.GetContext().GetNamespace(), installedRef.GetIdentifier())
+
+	if err := validateInstalledPackageRef(installedRef); err != nil {
+		return nil, err
+	}
+
+	typedClient, client, err := s.GetClients(ctx, installedRef.Context.Cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	namespace := installedRef.Context.Namespace
+	install, err := client.Resource(packageInstallGVR()).Namespace(namespace).Get(ctx, installedRef.Identifier, metav1.GetOptions{})
+	if err != nil {
+		return nil, statusErrorForK8sError(err, fmt.Sprintf("unable to get kapp-controller package install [%s]", installedRef.Identifier))
+	}
+
+	var valuesSecret *k8scorev1.Secret
+	if secretName := valuesSecretNameFromUnstructured(install); secretName != "" {
+		valuesSecret, err = typedClient.CoreV1().Secrets(namespace).Get(ctx, secretName, metav1.GetOptions{})
+		if err != nil && !k8serrors.IsNotFound(err) {
+			return nil, statusErrorForK8sError(err, fmt.Sprintf("unable to get values secret [%s]", secretName))
+		} else if err != nil {
+			valuesSecret = nil
+		}
+	}
 
-	// https://carvel.dev/kapp-controller/docs/latest/packaging/#packagerepository-cr
-	name, found, err := unstructured.NestedString(pr.Object, "metadata", "name")
-	if err != nil || !found || name == "" {
-		return nil, status.Errorf(codes.Internal, "required field metadata.name not found on PackageRepository: %v:\n%v", err, pr.Object)
+	detail, err := installedPackageDetailFromUnstructured(installedRef.Context.Cluster, install, valuesSecret)
+	if err != nil {
+		return nil, err
 	}
-	repo.Name = name
+	return &corev1.GetInstalledPackageDetailResponse{
+		InstalledPackageDetail: detail,
+	}, nil
+}
 
-	// https://carvel.dev/kapp-controller/docs/latest/packaging/#packagerepository-cr
-	namespace, found, err := unstructured.NestedString(pr.Object, "metadata", "namespace")
-	if err != nil || !found || namespace == "" {
-		return nil, status.Errorf(codes.Internal, "required field metadata.namespace not found on PackageRepository: %v:\n%v", err, pr.Object)
+// CreateInstalledPackage creates a PackageInstall for the package referenced in the request.
+// The values, if any, are stored in a secret referenced by the PackageInstall, and the
+// service account used by kapp-controller is taken from the reconciliation options.
+func (s *Server) CreateInstalledPackage(ctx context.Context, request *corev1.CreateInstalledPackageRequest) (*corev1.CreateInstalledPackageResponse, error) {
+	log.Infof("+kapp_controller CreateInstalledPackage (namespace=[%s], name=[%s])", request.GetTargetContext().GetNamespace(), request.GetName())
+
+	if request.GetAvailablePackageRef().GetIdentifier() == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "Required AvailablePackageRef not provided")
+	}
+	if request.GetTargetContext().GetNamespace() == "" || request.Name == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "Required target namespace or name not provided")
+	}
+	namespace := request.TargetContext.Namespace
+	hasValues := strings.TrimSpace(request.Values) != ""
+	install, err := newPackageInstall(request.Name, namespace, request.AvailablePackageRef.Identifier, request.PkgVersionReference, hasValues, request.ReconciliationOptions)
+	if err != nil {
+		return nil, err
 	}
-	repo.Namespace = namespace
 
-	// See the PackageRepository CR at
-	// https://carvel.dev/kapp-controller/docs/latest/packaging/#packagerepository-cr
-	valid_url_paths := [][]string{
-		{"spec", "fetch", "imgpkgBundle", "image"},
-		{"spec", "fetch", "image", "url"},
-		{"spec", "fetch", "http", "url"},
-		{"spec", "fetch", "git", "url"},
+	typedClient, client, err := s.GetClients(ctx, request.TargetContext.Cluster)
+	if err != nil {
+		return nil, err
 	}
 
-	found = false
-	url := ""
-	for _, path := range valid_url_paths {
-		url, found, err = unstructured.NestedString(pr.Object, path...)
+	if hasValues {
+		_, err = typedClient.CoreV1().Secrets(namespace).Create(ctx, newValuesSecret(request.Name, namespace, request.Values), metav1.CreateOptions{})
 		if err != nil {
-			return nil, status.Errorf(codes.Internal, "error fetching nested string %v from %v:\n%v", path, pr.Object, err)
+			return nil, statusErrorForK8sError(err, fmt.Sprintf("unable to create values secret for [%s]", request.Name))
 		}
-		if found {
-			break
+	}
+
+	newInstall, err := client.Resource(packageInstallGVR()).Namespace(namespace).Create(ctx, install, metav1.CreateOptions{})
+	if err != nil {
+		if hasValues {
+			// don't leave the values secret behind
+			if err := typedClient.CoreV1().Secrets(namespace).Delete(ctx, valuesSecretName(request.Name), metav1.DeleteOptions{}); err != nil {
+				log.Errorf("unable to delete values secret for [%s]: %v", request.Name, err)
+			}
+		}
+		return nil, statusErrorForK8sError(err, fmt.Sprintf("unable to create kapp-controller package install [%s]", request.Name))
+	}
+
+	installedRef, err := installedPackageRefFromUnstructured(request.TargetContext.Cluster, newInstall)
+	if err != nil {
+		return nil, err
+	}
+	return &corev1.CreateInstalledPackageResponse{
+		InstalledPackageRef: installedRef,
+	}, nil
+}
+
+// UpdateInstalledPackage updates the version constraint, values and reconciliation options of
+// the PackageInstall referenced in the request. An empty version keeps the current constraint,
+// while empty values remove the values secret.
+func (s *Server) UpdateInstalledPackage(ctx context.Context, request *corev1.UpdateInstalledPackageRequest) (*corev1.UpdateInstalledPackageResponse, error) {
+	installedRef := request.GetInstalledPackageRef()
+	log.Infof("+kapp_controller UpdateInstalledPackage (namespace=[%s], identifier=[%s])", installedRef.GetContext().GetNamespace(), installedRef.GetIdentifier())
+
+	if err := validateInstalledPackageRef(installedRef); err != nil {
+		return nil, err
+	}
+
+	typedClient, client, err := s.GetClients(ctx, installedRef.Context.Cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	namespace := installedRef.Context.Namespace
+	resourceIfc := client.Resource(packageInstallGVR()).Namespace(namespace)
+	install, err := resourceIfc.Get(ctx, installedRef.Identifier, metav1.GetOptions{})
+	if err != nil {
+		return nil, statusErrorForK8sError(err, fmt.Sprintf("unable to get kapp-controller package install [%s]", installedRef.Identifier))
+	}
+
+	hasValues := strings.TrimSpace(request.Values) != ""
+	if err = setPackageInstallVersion(install, request.GetPkgVersionReference().GetVersion()); err != nil {
+		return nil, err
+	}
+	if err = setPackageInstallValuesRef(install, hasValues); err != nil {
+		return nil, err
+	}
+	if err = setPackageInstallReconciliationOptions(install, request.ReconciliationOptions); err != nil {
+		return nil, err
+	}
+
+	secretsIfc := typedClient.CoreV1().Secrets(namespace)
+	if hasValues {
+		valuesSecret := newValuesSecret(installedRef.Identifier, namespace, request.Values)
+		_, err = secretsIfc.Update(ctx, valuesSecret, metav1.UpdateOptions{})
+		if k8serrors.IsNotFound(err) {
+			_, err = secretsIfc.Create(ctx, valuesSecret, metav1.CreateOptions{})
+		}
+		if err != nil {
+			return nil, statusErrorForK8sError(err, fmt.Sprintf("unable to update values secret for [%s]", installedRef.Identifier))
+		}
+	}
+
+	updatedInstall, err := resourceIfc.Update(ctx, install, metav1.UpdateOptions{})
+	if err != nil {
+		return nil, statusErrorForK8sError(err, fmt.Sprintf("unable to update kapp-controller package install [%s]", installedRef.Identifier))
+	}
+
+	if !hasValues {
+		err = secretsIfc.Delete(ctx, valuesSecretName(installedRef.Identifier), metav1.DeleteOptions{})
+		if err != nil && !k8serrors.IsNotFound(err) {
+			return nil, statusErrorForK8sError(err, fmt.Sprintf("unable to delete values secret for [%s]", installedRef.Identifier))
 		}
 	}
 
-	if !found {
-		return nil, status.Errorf(codes.Internal, "packagerepository without fetch of one of imgpkgBundle, image, http or git: %v", pr.Object)
+	updatedRef, err := installedPackageRefFromUnstructured(installedRef.Context.Cluster, updatedInstall)
+	if err != nil {
+		return nil, err
+	}
+	return &corev1.UpdateInstalledPackageResponse{
+		InstalledPackageRef: updatedRef,
+	}, nil
+}
+
+// DeleteInstalledPackage deletes the PackageInstall referenced in the request, together with
+// its values secret. kapp-controller takes care of deleting the installed resources.
+func (s *Server) DeleteInstalledPackage(ctx context.Context, request *corev1.DeleteInstalledPackageRequest) (*corev1.DeleteInstalledPackageResponse, error) {
+	installedRef := request.GetInstalledPackageRef()
+	log.Infof("+kapp_controller DeleteInstalledPackage (namespace=[%s], identifier=[%s])", installedRef.GetContext().GetNamespace(), installedRef.GetIdentifier())
+
+	if err := validateInstalledPackageRef(installedRef); err != nil {
+		return nil, err
+	}
+
+	typedClient, client, err := s.GetClients(ctx, installedRef.Context.Cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	namespace := installedRef.Context.Namespace
+	err = client.Resource(packageInstallGVR()).Namespace(namespace).Delete(ctx, installedRef.Identifier, metav1.DeleteOptions{})
+	if err != nil {
+		return nil, statusErrorForK8sError(err, fmt.Sprintf("unable to delete kapp-controller package install [%s]", installedRef.Identifier))
+	}
+
+	err = typedClient.CoreV1().Secrets(namespace).Delete(ctx, valuesSecretName(installedRef.Identifier), metav1.DeleteOptions{})
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return nil, statusErrorForK8sError(err, fmt.Sprintf("unable to delete values secret for [%s]", installedRef.Identifier))
+	}
+	return &corev1.DeleteInstalledPackageResponse{}, nil
+}
+
+func pkgGVR() schema.GroupVersionResource {
+	return schema.GroupVersionResource{Group: dataPackagingGroup, Version: dataPackagingVersion, Resource: pkgsResource}
+}
+
+func pkgMetadataGVR() schema.GroupVersionResource {
+	return schema.GroupVersionResource{Group: dataPackagingGroup, Version: dataPackagingVersion, Resource: pkgMetadatasResource}
+}
+
+func packageRepositoryGVR() schema.GroupVersionResource {
+	return schema.GroupVersionResource{Group: packagingGroup, Version: installPackageVersion, Resource: repositoriesResource}
+}
+
+func packageInstallGVR() schema.GroupVersionResource {
+	return schema.GroupVersionResource{Group: packagingGroup, Version: packageInstallVersion, Resource: packageInstallsResource}
+}
+
+// pageOffsetFromPageToken converts a page token to an integer offset
+// representing the number of items already returned.
+func pageOffsetFromPageToken(pageToken string) (int, error) {
+	if pageToken == "" {
+		return 0, nil
+	}
+	offset, err := strconv.ParseUint(pageToken, 10, 0)
+	if err != nil {
+		return 0, status.Errorf(codes.InvalidArgument, "Unable to interpret page token %q: %v", pageToken, err)
+	}
+	return int(offset), nil
+}
+
+// pageBounds returns the indexes delimiting the page of the given size which
+// starts at offset in a result set of total items, together with the token for
+// the next page, which is empty if there are no more results. A page size of
+// zero returns all the remaining items.
+func pageBounds(total, offset int, pageSize int32) (start, end int, nextPageToken string) {
+	if offset > total {
+		offset = total
+	}
+	end = total
+	if pageSize > 0 && offset+int(pageSize) < total {
+		end = offset + int(pageSize)
+		nextPageToken = fmt.Sprintf("%d", end)
 	}
-	repo.Url = url
-	return repo, nil
+	return offset, end, nextPageToken
 }
@@ -21,13 +21,20 @@ import (
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
 	corev1 "github.com/kubeapps/kubeapps/cmd/kubeapps-apis/gen/core/packages/v1alpha1"
+	plugins "github.com/kubeapps/kubeapps/cmd/kubeapps-apis/gen/core/plugins/v1alpha1"
 	"github.com/kubeapps/kubeapps/cmd/kubeapps-apis/gen/plugins/kapp_controller/packages/v1alpha1"
 	"github.com/kubeapps/kubeapps/cmd/kubeapps-apis/server"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/testing/protocmp"
+	"google.golang.org/protobuf/types/known/anypb"
+	k8scorev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	fakediscovery "k8s.io/client-go/discovery/fake"
 	"k8s.io/client-go/dynamic"
 	dynfake "k8s.io/client-go/dynamic/fake"
 	"k8s.io/client-go/kubernetes"
@@ -39,6 +46,7 @@ func TestGetClient(t *testing.T) {
 	testCases := []struct {
 		name         string
 		clientGetter server.KubernetesClientGetter
+		cluster      string
 		statusCode   codes.Code
 	}{
 		{
@@ -48,18 +56,28 @@ func TestGetClient(t *testing.T) {
 		},
 		{
 			name: "returns failed-precondition when configGetter itself errors",
-			clientGetter: func(context.Context) (kubernetes.Interface, dynamic.Interface, error) {
+			clientGetter: func(context.Context, string) (kubernetes.Interface, dynamic.Interface, error) {
 				return nil, nil, fmt.Errorf("Bang!")
 			},
 			statusCode: codes.FailedPrecondition,
 		},
+		{
+			name: "returns client for the requested cluster",
+			clientGetter: func(ctx context.Context, cluster string) (kubernetes.Interface, dynamic.Interface, error) {
+				if cluster != "other" {
+					return nil, nil, fmt.Errorf("unexpected cluster %q", cluster)
+				}
+				return typfake.NewSimpleClientset(), dynfake.NewSimpleDynamicClient(runtime.NewScheme()), nil
+			},
+			cluster: "other",
+		},
 		{
 			name: "returns client without error when configured correctly",
-			clientGetter: func(context.Context) (kubernetes.Interface, dynamic.Interface, error) {
+			clientGetter: func(context.Context, string) (kubernetes.Interface, dynamic.Interface, error) {
 				return typfake.NewSimpleClientset(), dynfake.NewSimpleDynamicClientWithCustomListKinds(
 					runtime.NewScheme(),
 					map[schema.GroupVersionResource]string{
-						{Group: packagingGroup, Version: packageVersion, Resource: packagesResource}: "PackageList",
+						pkgGVR(): "PackageList",
 					},
 				), nil
 			},
@@ -70,7 +88,7 @@ func TestGetClient(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			s := Server{clientGetter: tc.clientGetter}
 
-			typedClient, dynamicClient, err := s.GetClients(context.Background())
+			typedClient, dynamicClient, err := s.GetClients(context.Background(), tc.cluster)
 
 			if got, want := status.Code(err), tc.statusCode; got != want {
 				t.Errorf("got: %+v, want: %+v", got, want)
@@ -90,173 +108,213 @@ func TestGetClient(t *testing.T) {
 
 }
 
-func TestGetAvailablePackagesStatus(t *testing.T) {
+func TestCheckDependencies(t *testing.T) {
 	testCases := []struct {
-		name         string
-		clientGetter server.KubernetesClientGetter
-		statusCode   codes.Code
+		name                 string
+		apiResources         []*metav1.APIResourceList
+		expectedDependencies []*plugins.PluginDependencyStatus
 	}{
 		{
-			name: "returns an internal error status if response does not contain packageRef.refName",
-			clientGetter: func(context.Context) (kubernetes.Interface, dynamic.Interface, error) {
-				return nil, dynfake.NewSimpleDynamicClientWithCustomListKinds(
-					runtime.NewScheme(),
-					map[schema.GroupVersionResource]string{
-						{Group: packagingGroup, Version: packageVersion, Resource: packagesResource}: "PackageList",
-					},
-					packageFromSpec("1.2.3", map[string]interface{}{
-						"packageRef": map[string]interface{}{},
-					}, t),
-				), nil
+			name: "reports the CRDs reachable when installed",
+			apiResources: []*metav1.APIResourceList{
+				{
+					GroupVersion: "data.packaging.carvel.dev/v1alpha1",
+					APIResources: []metav1.APIResource{{Name: "packages"}, {Name: "packagemetadatas"}},
+				},
+				{
+					GroupVersion: "packaging.carvel.dev/v1alpha1",
+					APIResources: []metav1.APIResource{{Name: "packagerepositories"}, {Name: "packageinstalls"}},
+				},
 			},
-			statusCode: codes.Internal,
-		},
-		{
-			name: "returns an internal error status if response does not contain version",
-			clientGetter: func(context.Context) (kubernetes.Interface, dynamic.Interface, error) {
-				return nil, dynfake.NewSimpleDynamicClientWithCustomListKinds(
-					runtime.NewScheme(),
-					map[schema.GroupVersionResource]string{
-						{Group: packagingGroup, Version: packageVersion, Resource: packagesResource}: "PackageList",
-					},
-					packageFromSpec(nil, map[string]interface{}{
-						"packageRef": map[string]interface{}{
-							"refName": "someName",
-						},
-					}, t),
-				), nil
+			expectedDependencies: []*plugins.PluginDependencyStatus{
+				{Name: "packages.data.packaging.carvel.dev", Reachable: true},
+				{Name: "packagemetadatas.data.packaging.carvel.dev", Reachable: true},
+				{Name: "packagerepositories.packaging.carvel.dev", Reachable: true},
+				{Name: "packageinstalls.packaging.carvel.dev", Reachable: true},
 			},
-			statusCode: codes.Internal,
 		},
 		{
-			name: "returns OK status if items contain required fields",
-			clientGetter: func(context.Context) (kubernetes.Interface, dynamic.Interface, error) {
-				return nil, dynfake.NewSimpleDynamicClientWithCustomListKinds(
-					runtime.NewScheme(),
-					map[schema.GroupVersionResource]string{
-						{Group: packagingGroup, Version: packageVersion, Resource: packagesResource}: "PackageList",
-					},
-					packageFromSpec("1.2.3", map[string]interface{}{
-						"packageRef": map[string]interface{}{
-							"refName": "someName",
-						},
-					}, t),
-				), nil
+			name: "reports the CRDs unreachable when not installed",
+			apiResources: []*metav1.APIResourceList{
+				{
+					GroupVersion: "packaging.carvel.dev/v1alpha1",
+					APIResources: []metav1.APIResource{{Name: "packageinstalls"}},
+				},
+			},
+			expectedDependencies: []*plugins.PluginDependencyStatus{
+				{Name: "packages.data.packaging.carvel.dev", Message: "GroupVersion \"data.packaging.carvel.dev/v1alpha1\" not found"},
+				{Name: "packagemetadatas.data.packaging.carvel.dev", Message: "GroupVersion \"data.packaging.carvel.dev/v1alpha1\" not found"},
+				{Name: "packagerepositories.packaging.carvel.dev", Message: "resource packaging.carvel.dev/v1alpha1, Resource=packagerepositories not served by the API server"},
+				{Name: "packageinstalls.packaging.carvel.dev", Reachable: true},
 			},
-			statusCode: codes.OK,
 		},
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			s := Server{clientGetter: tc.clientGetter}
-
-			_, err := s.GetAvailablePackageSummaries(context.Background(), &corev1.GetAvailablePackageSummariesRequest{Context: &corev1.Context{}})
-
-			if err == nil && tc.statusCode != codes.OK {
-				t.Fatalf("got: nil, want: error")
+			typedClient := typfake.NewSimpleClientset()
+			typedClient.Discovery().(*fakediscovery.FakeDiscovery).Resources = tc.apiResources
+			s := Server{
+				clientGetter: func(context.Context, string) (kubernetes.Interface, dynamic.Interface, error) {
+					return typedClient, nil, nil
+				},
 			}
 
-			if got, want := status.Code(err), tc.statusCode; got != want {
-				t.Errorf("got: %+v, want: %+v", got, want)
+			dependencies := s.CheckDependencies(context.Background())
+
+			opts := cmpopts.IgnoreUnexported(plugins.PluginDependencyStatus{})
+			if got, want := dependencies, tc.expectedDependencies; !cmp.Equal(want, got, opts) {
+				t.Errorf("mismatch (-want +got):\n%s", cmp.Diff(want, got, opts))
 			}
 		})
 	}
-
 }
 
-func packageFromSpec(version interface{}, spec map[string]interface{}, t *testing.T) *unstructured.Unstructured {
-	pkgRef, ok := spec["packageRef"].(map[string]interface{})
-	if !ok {
-		t.Fatalf("unable to convert %+v to a map[string]interface{}", spec["packageRef"])
-	}
-	return &unstructured.Unstructured{
-		Object: map[string]interface{}{
-			"apiVersion": fmt.Sprintf("%s/%s", packagingGroup, packageVersion),
-			"kind":       packageResource,
-			"metadata": map[string]interface{}{
-				"name": fmt.Sprintf("%s.%s", pkgRef["refName"], version),
-			},
-			"spec": spec,
-			"status": map[string]interface{}{
-				"version": version,
+func TestGetAvailablePackageSummaries(t *testing.T) {
+	existingObjects := []runtime.Object{
+		pkgMetadataFromSpec("tetris.foo.example.com", "default", map[string]interface{}{
+			"displayName":      "Classic Tetris",
+			"iconSVGBase64":    "Tm90IHJlYWxseSBTVkcK",
+			"shortDescription": "A great game for arcade gamers",
+			"categories":       []interface{}{"logging", "daemon-set"},
+			"maintainers": []interface{}{
+				map[string]interface{}{"name": "person1"},
 			},
-		},
+		}),
+		pkgFromSpec("tetris.foo.example.com", "1.2.3", "default", nil),
+		pkgFromSpec("tetris.foo.example.com", "1.2.10", "default", nil),
+		pkgMetadataFromSpec("another.foo.example.com", "default", map[string]interface{}{
+			"shortDescription": "Another package",
+			"longDescription":  "Not really a game",
+			"categories":       []interface{}{"cms"},
+		}),
+		pkgFromSpec("another.foo.example.com", "1.2.5", "default", nil),
+		pkgMetadataFromSpec("unreleased.foo.example.com", "default", map[string]interface{}{
+			"displayName": "Without packages",
+		}),
+		pkgMetadataFromSpec("tetris.foo.example.com", "other-ns", map[string]interface{}{
+			"displayName": "Tetris in another namespace",
+		}),
+		pkgFromSpec("tetris.foo.example.com", "2.0.0", "other-ns", nil),
 	}
-}
 
-func packagesFromSpecs(specs []map[string]interface{}, t *testing.T) []runtime.Object {
-	pkgs := []runtime.Object{}
-	for _, s := range specs {
-		pkgSpec, ok := s["spec"].(map[string]interface{})
-		if !ok {
-			t.Fatalf("unable to convert %+v to a map[string]interface{}", s["spec"])
-		}
-		pkgs = append(pkgs, packageFromSpec(s["version"], pkgSpec, t))
+	tetrisSummary := &corev1.AvailablePackageSummary{
+		AvailablePackageRef: &corev1.AvailablePackageReference{
+			Context:    &corev1.Context{Namespace: "default"},
+			Identifier: "tetris.foo.example.com",
+			Plugin:     &plugins.Plugin{Name: "kapp_controller.packages", Version: "v1alpha1"},
+		},
+		Name:             "tetris.foo.example.com",
+		DisplayName:      "Classic Tetris",
+		LatestPkgVersion: "1.2.10",
+		IconUrl:          "data:image/svg+xml;base64,Tm90IHJlYWxseSBTVkcK",
+		ShortDescription: "A great game for arcade gamers",
+	}
+	anotherSummary := &corev1.AvailablePackageSummary{
+		AvailablePackageRef: &corev1.AvailablePackageReference{
+			Context:    &corev1.Context{Namespace: "default"},
+			Identifier: "another.foo.example.com",
+			Plugin:     &plugins.Plugin{Name: "kapp_controller.packages", Version: "v1alpha1"},
+		},
+		Name:             "another.foo.example.com",
+		DisplayName:      "another.foo.example.com",
+		LatestPkgVersion: "1.2.5",
+		ShortDescription: "Another package",
+	}
+	otherNamespaceSummary := &corev1.AvailablePackageSummary{
+		AvailablePackageRef: &corev1.AvailablePackageReference{
+			Context:    &corev1.Context{Namespace: "other-ns"},
+			Identifier: "tetris.foo.example.com",
+			Plugin:     &plugins.Plugin{Name: "kapp_controller.packages", Version: "v1alpha1"},
+		},
+		Name:             "tetris.foo.example.com",
+		DisplayName:      "Tetris in another namespace",
+		LatestPkgVersion: "2.0.0",
 	}
-	return pkgs
-}
 
-func TestGetAvailablePackageSummaries(t *testing.T) {
 	testCases := []struct {
 		name             string
-		packageSpecs     []map[string]interface{}
+		namespace        string
+		sortOptions      *corev1.SortOptions
+		filterOptions    *corev1.FilterOptions
 		expectedPackages []*corev1.AvailablePackageSummary
 	}{
 		{
-			name: "it returns carvel packages from the cluster",
-			packageSpecs: []map[string]interface{}{
-				{
-					"spec": map[string]interface{}{
-						"packageRef": map[string]interface{}{
-							"refName": "tetris.foo.example.com",
-						},
-					},
-					"version": "1.2.3",
-				},
-				{
-					"spec": map[string]interface{}{
-						"packageRef": map[string]interface{}{
-							"refName": "another.foo.example.com",
-						},
-					},
-					"version": "1.2.5",
-				},
-			},
-			expectedPackages: []*corev1.AvailablePackageSummary{
-				{
-					DisplayName:      "another.foo.example.com",
-					LatestPkgVersion: "1.2.5",
-				},
-				{
-					DisplayName:      "tetris.foo.example.com",
-					LatestPkgVersion: "1.2.3",
-				},
+			name:             "it returns carvel packages with their latest version from the namespace",
+			namespace:        "default",
+			expectedPackages: []*corev1.AvailablePackageSummary{anotherSummary, tetrisSummary},
+		},
+		{
+			name:             "it returns carvel packages from all namespaces",
+			expectedPackages: []*corev1.AvailablePackageSummary{anotherSummary, tetrisSummary, otherNamespaceSummary},
+		},
+		{
+			name:      "it returns carvel packages sorted as requested",
+			namespace: "default",
+			sortOptions: &corev1.SortOptions{
+				Field:     corev1.SortOptions_SORT_FIELD_LATEST_VERSION,
+				Direction: corev1.SortOptions_SORT_DIRECTION_DESCENDING,
 			},
+			expectedPackages: []*corev1.AvailablePackageSummary{tetrisSummary, anotherSummary},
+		},
+		{
+			name:             "it filters by a text query on the display name ignoring case",
+			namespace:        "default",
+			filterOptions:    &corev1.FilterOptions{Query: "tetris"},
+			expectedPackages: []*corev1.AvailablePackageSummary{tetrisSummary},
+		},
+		{
+			name:             "it filters by a text query on the long description",
+			namespace:        "default",
+			filterOptions:    &corev1.FilterOptions{Query: "not really"},
+			expectedPackages: []*corev1.AvailablePackageSummary{anotherSummary},
+		},
+		{
+			name:             "it filters by a text query on the maintainers",
+			namespace:        "default",
+			filterOptions:    &corev1.FilterOptions{Query: "person1"},
+			expectedPackages: []*corev1.AvailablePackageSummary{tetrisSummary},
+		},
+		{
+			name:             "it filters by any of the categories",
+			namespace:        "default",
+			filterOptions:    &corev1.FilterOptions{Categories: []string{"cms", "database"}},
+			expectedPackages: []*corev1.AvailablePackageSummary{anotherSummary},
+		},
+		{
+			name:             "it filters by package version when an app version is also requested",
+			namespace:        "default",
+			filterOptions:    &corev1.FilterOptions{PkgVersion: "1.2.3", AppVersion: "1.2.3"},
+			expectedPackages: []*corev1.AvailablePackageSummary{tetrisSummary},
+		},
+		{
+			name:             "it ignores the package version when no app version is requested",
+			namespace:        "default",
+			filterOptions:    &corev1.FilterOptions{PkgVersion: "1.2.3"},
+			expectedPackages: []*corev1.AvailablePackageSummary{anotherSummary, tetrisSummary},
+		},
+		{
+			name:             "it returns no packages when filtering by repositories",
+			namespace:        "default",
+			filterOptions:    &corev1.FilterOptions{Repositories: []string{"bitnami"}},
+			expectedPackages: []*corev1.AvailablePackageSummary{},
 		},
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			pkgs := packagesFromSpecs(tc.packageSpecs, t)
-			s := Server{
-				clientGetter: func(context.Context) (kubernetes.Interface, dynamic.Interface, error) {
-					return nil, dynfake.NewSimpleDynamicClientWithCustomListKinds(
-						runtime.NewScheme(),
-						map[schema.GroupVersionResource]string{
-							{Group: packagingGroup, Version: packageVersion, Resource: packagesResource}: "PackageList",
-						},
-						pkgs...,
-					), nil
-				},
-			}
+			s := newServerWithPackages(existingObjects...)
 
-			response, err := s.GetAvailablePackageSummaries(context.Background(), &corev1.GetAvailablePackageSummariesRequest{Context: &corev1.Context{}})
+			response, err := s.GetAvailablePackageSummaries(context.Background(), &corev1.GetAvailablePackageSummariesRequest{
+				Context:       &corev1.Context{Namespace: tc.namespace},
+				SortOptions:   tc.sortOptions,
+				FilterOptions: tc.filterOptions,
+			})
 			if err != nil {
 				t.Fatalf("%+v", err)
 			}
 
-			opt1 := cmpopts.IgnoreUnexported(corev1.AvailablePackageSummary{}, corev1.Context{})
+			opt1 := cmpopts.IgnoreUnexported(corev1.AvailablePackageSummary{}, corev1.AvailablePackageReference{}, corev1.Context{}, plugins.Plugin{})
 			if got, want := response.AvailablePackagesSummaries, tc.expectedPackages; !cmp.Equal(got, want, opt1) {
 				t.Errorf("mismatch (-want +got):\n%s", cmp.Diff(want, got, opt1))
 			}
@@ -292,17 +350,17 @@ func repositoriesFromSpecs(specs map[string]spec) []runtime.Object {
 	return repos
 }
 
-func TestGetPackageRepositories(t *testing.T) {
+func TestGetPackageRepositorySummaries(t *testing.T) {
 	testCases := []struct {
 		name                        string
-		request                     *v1alpha1.GetPackageRepositoriesRequest
+		request                     *corev1.GetPackageRepositorySummariesRequest
 		repoSpecs                   map[string]spec
-		expectedPackageRepositories []*v1alpha1.PackageRepository
+		expectedRepositorySummaries []*corev1.PackageRepositorySummary
 		statusCode                  codes.Code
 	}{
 		{
-			name:    "returns an internal error status if item in response cannot be converted to v1alpha1.PackageRepository",
-			request: &v1alpha1.GetPackageRepositoriesRequest{Context: &corev1.Context{}},
+			name:    "returns an internal error status if item in response cannot be converted to a package repository summary",
+			request: &corev1.GetPackageRepositorySummariesRequest{Context: &corev1.Context{}},
 			repoSpecs: map[string]spec{
 				"repo-1": {
 					"fetch": "unexpected",
@@ -312,7 +370,7 @@ func TestGetPackageRepositories(t *testing.T) {
 		},
 		{
 			name:    "returns expected repositories",
-			request: &v1alpha1.GetPackageRepositoriesRequest{Context: &corev1.Context{}},
+			request: &corev1.GetPackageRepositorySummariesRequest{Context: &corev1.Context{}},
 			repoSpecs: map[string]spec{
 				"repo-1": {
 					"fetch": map[string]interface{}{
@@ -329,16 +387,20 @@ func TestGetPackageRepositories(t *testing.T) {
 					},
 				},
 			},
-			expectedPackageRepositories: []*v1alpha1.PackageRepository{
+			expectedRepositorySummaries: []*corev1.PackageRepositorySummary{
 				{
-					Name:      "repo-1",
-					Url:       "projects.registry.example.com/repo-1/main@sha256:abcd",
-					Namespace: globalPackagingNamespace,
+					PackageRepoRef: repoRef("repo-1", globalPackagingNamespace),
+					Name:           "repo-1",
+					Type:           "imgpkgBundle",
+					Url:            "projects.registry.example.com/repo-1/main@sha256:abcd",
+					Status:         pendingRepoStatus,
 				},
 				{
-					Name:      "repo-2",
-					Url:       "projects.registry.example.com/repo-2/main@sha256:abcd",
-					Namespace: globalPackagingNamespace,
+					PackageRepoRef: repoRef("repo-2", globalPackagingNamespace),
+					Name:           "repo-2",
+					Type:           "imgpkgBundle",
+					Url:            "projects.registry.example.com/repo-2/main@sha256:abcd",
+					Status:         pendingRepoStatus,
 				},
 			},
 		},
@@ -347,7 +409,7 @@ func TestGetPackageRepositories(t *testing.T) {
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			s := Server{
-				clientGetter: func(context.Context) (kubernetes.Interface, dynamic.Interface, error) {
+				clientGetter: func(context.Context, string) (kubernetes.Interface, dynamic.Interface, error) {
 					return nil, dynfake.NewSimpleDynamicClientWithCustomListKinds(
 						runtime.NewScheme(),
 						map[schema.GroupVersionResource]string{
@@ -358,7 +420,7 @@ func TestGetPackageRepositories(t *testing.T) {
 				},
 			}
 
-			response, err := s.GetPackageRepositories(context.Background(), &v1alpha1.GetPackageRepositoriesRequest{Context: &corev1.Context{}})
+			response, err := s.GetPackageRepositorySummaries(context.Background(), tc.request)
 
 			if got, want := status.Code(err), tc.statusCode; got != want {
 				t.Fatalf("got: %+v, want: %+v, err: %+v", got, want, err)
@@ -369,9 +431,8 @@ func TestGetPackageRepositories(t *testing.T) {
 				if response == nil {
 					t.Fatalf("got: nil, want: response")
 				} else {
-					opt1 := cmpopts.IgnoreUnexported(v1alpha1.PackageRepository{}, corev1.Context{})
-					if got, want := response.Repositories, tc.expectedPackageRepositories; !cmp.Equal(got, want, opt1) {
-						t.Errorf("mismatch (-want +got):\n%s", cmp.Diff(want, got, opt1))
+					if got, want := response.PackageRepositorySummaries, tc.expectedRepositorySummaries; !cmp.Equal(got, want, packageRepositoryOpts) {
+						t.Errorf("mismatch (-want +got):\n%s", cmp.Diff(want, got, packageRepositoryOpts))
 					}
 				}
 			}
@@ -379,7 +440,7 @@ func TestGetPackageRepositories(t *testing.T) {
 	}
 }
 
-func TestPackageRepositoryFromUnstructured(t *testing.T) {
+func TestPackageRepositorySummaryFromUnstructured(t *testing.T) {
 	validSpec := map[string]interface{}{
 		"fetch": map[string]interface{}{
 			"imgpkgBundle": map[string]interface{}{
@@ -393,7 +454,7 @@ func TestPackageRepositoryFromUnstructured(t *testing.T) {
 	testCases := []struct {
 		name       string
 		in         *unstructured.Unstructured
-		expected   *v1alpha1.PackageRepository
+		expected   *corev1.PackageRepositorySummary
 		statusCode codes.Code
 	}{
 		{
@@ -409,10 +470,12 @@ func TestPackageRepositoryFromUnstructured(t *testing.T) {
 		{
 			name: "returns a repo for an imgpkgBundle type",
 			in:   repositoryFromSpec("valid-name", validSpec),
-			expected: &v1alpha1.PackageRepository{
-				Name:      "valid-name",
-				Url:       "projects.registry.example.com/repo-1/main@sha256:abcd",
-				Namespace: globalPackagingNamespace,
+			expected: &corev1.PackageRepositorySummary{
+				PackageRepoRef: repoRef("valid-name", globalPackagingNamespace),
+				Name:           "valid-name",
+				Type:           "imgpkgBundle",
+				Url:            "projects.registry.example.com/repo-1/main@sha256:abcd",
+				Status:         pendingRepoStatus,
 			},
 		},
 		{
@@ -424,10 +487,12 @@ func TestPackageRepositoryFromUnstructured(t *testing.T) {
 					},
 				},
 			}),
-			expected: &v1alpha1.PackageRepository{
-				Name:      "valid-name",
-				Url:       "host.com/username/image:v0.1.0",
-				Namespace: globalPackagingNamespace,
+			expected: &corev1.PackageRepositorySummary{
+				PackageRepoRef: repoRef("valid-name", globalPackagingNamespace),
+				Name:           "valid-name",
+				Type:           "image",
+				Url:            "host.com/username/image:v0.1.0",
+				Status:         pendingRepoStatus,
 			},
 		},
 		{
@@ -439,10 +504,12 @@ func TestPackageRepositoryFromUnstructured(t *testing.T) {
 					},
 				},
 			}),
-			expected: &v1alpha1.PackageRepository{
-				Name:      "valid-name",
-				Url:       "https://host.com/archive.tgz",
-				Namespace: globalPackagingNamespace,
+			expected: &corev1.PackageRepositorySummary{
+				PackageRepoRef: repoRef("valid-name", globalPackagingNamespace),
+				Name:           "valid-name",
+				Type:           "http",
+				Url:            "https://host.com/archive.tgz",
+				Status:         pendingRepoStatus,
 			},
 		},
 		{
@@ -454,28 +521,1446 @@ func TestPackageRepositoryFromUnstructured(t *testing.T) {
 					},
 				},
 			}),
-			expected: &v1alpha1.PackageRepository{
-				Name:      "valid-name",
-				Url:       "https://github.com/k14s/k8s-simple-app-example",
-				Namespace: globalPackagingNamespace,
+			expected: &corev1.PackageRepositorySummary{
+				PackageRepoRef: repoRef("valid-name", globalPackagingNamespace),
+				Name:           "valid-name",
+				Type:           "git",
+				Url:            "https://github.com/k14s/k8s-simple-app-example",
+				Status:         pendingRepoStatus,
 			},
 		},
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			repo, err := packageRepositoryFromUnstructured(tc.in)
+			repo, err := packageRepositorySummaryFromUnstructured("", tc.in)
 
 			if got, want := status.Code(err), tc.statusCode; got != want {
 				t.Fatalf("got: %+v, want: %+v, err: %+v", got, want, err)
 			}
 
 			if tc.statusCode == codes.OK {
-				opt1 := cmpopts.IgnoreUnexported(v1alpha1.PackageRepository{}, corev1.Context{})
-				if got, want := repo, tc.expected; !cmp.Equal(got, want, opt1) {
-					t.Errorf("mismatch (-want +got):\n%s", cmp.Diff(want, got, opt1))
+				if got, want := repo, tc.expected; !cmp.Equal(got, want, packageRepositoryOpts) {
+					t.Errorf("mismatch (-want +got):\n%s", cmp.Diff(want, got, packageRepositoryOpts))
 				}
 			}
 		})
 	}
 }
+
+func TestGetPackageRepositoryDetail(t *testing.T) {
+	testCases := []struct {
+		name               string
+		request            *corev1.GetPackageRepositoryDetailRequest
+		existingRepos      []runtime.Object
+		expectedDetail     *corev1.PackageRepositoryDetail
+		expectedStatusCode codes.Code
+	}{
+		{
+			name: "returns the detail of a reconciled repository",
+			request: &corev1.GetPackageRepositoryDetailRequest{
+				PackageRepoRef: repoRef("tce-repo", "default"),
+			},
+			existingRepos: []runtime.Object{
+				packageRepositoryFromSpec("tce-repo", "default", map[string]interface{}{
+					"syncPeriod": "10m0s",
+					"fetch": map[string]interface{}{
+						"imgpkgBundle": map[string]interface{}{
+							"image":     "projects.registry.example.com/tce/main:stable",
+							"secretRef": map[string]interface{}{"name": "registry-creds"},
+						},
+					},
+				}, map[string]interface{}{
+					"friendlyDescription": "Reconcile succeeded",
+					"conditions": []interface{}{
+						map[string]interface{}{
+							"type":   "ReconcileSucceeded",
+							"status": "True",
+						},
+					},
+				}),
+			},
+			expectedDetail: &corev1.PackageRepositoryDetail{
+				PackageRepoRef: repoRef("tce-repo", "default"),
+				Name:           "tce-repo",
+				Type:           "imgpkgBundle",
+				Url:            "projects.registry.example.com/tce/main:stable",
+				Interval:       600,
+				Auth: &corev1.PackageRepositoryAuth{
+					PackageRepoAuthOneOf: &corev1.PackageRepositoryAuth_SecretRef{
+						SecretRef: &corev1.SecretKeyReference{Name: "registry-creds"},
+					},
+				},
+				Status: &corev1.PackageRepositoryStatus{
+					Ready:      true,
+					Reason:     corev1.PackageRepositoryStatus_STATUS_REASON_SUCCESS,
+					UserReason: "Reconcile succeeded",
+				},
+			},
+		},
+		{
+			name: "returns the useful error message of a failed repository",
+			request: &corev1.GetPackageRepositoryDetailRequest{
+				PackageRepoRef: repoRef("tce-repo", "default"),
+			},
+			existingRepos: []runtime.Object{
+				packageRepositoryFromSpec("tce-repo", "default", map[string]interface{}{
+					"fetch": map[string]interface{}{
+						"git": map[string]interface{}{
+							"url": "https://github.com/example/repo",
+						},
+					},
+				}, map[string]interface{}{
+					"usefulErrorMessage": "git: repository not found",
+					"conditions": []interface{}{
+						map[string]interface{}{
+							"type":    "ReconcileFailed",
+							"status":  "True",
+							"message": "Fetching resources: Error",
+						},
+					},
+				}),
+			},
+			expectedDetail: &corev1.PackageRepositoryDetail{
+				PackageRepoRef: repoRef("tce-repo", "default"),
+				Name:           "tce-repo",
+				Type:           "git",
+				Url:            "https://github.com/example/repo",
+				Status: &corev1.PackageRepositoryStatus{
+					Reason:     corev1.PackageRepositoryStatus_STATUS_REASON_FAILED,
+					UserReason: "git: repository not found",
+				},
+			},
+		},
+		{
+			name: "returns not found for a missing repository",
+			request: &corev1.GetPackageRepositoryDetailRequest{
+				PackageRepoRef: repoRef("tce-repo", "default"),
+			},
+			expectedStatusCode: codes.NotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s, _, _ := newServerWithObjects(nil, tc.existingRepos...)
+
+			response, err := s.GetPackageRepositoryDetail(context.Background(), tc.request)
+
+			if got, want := status.Code(err), tc.expectedStatusCode; got != want {
+				t.Fatalf("got: %+v, want: %+v, err: %+v", got, want, err)
+			}
+			if tc.expectedStatusCode != codes.OK {
+				return
+			}
+
+			if got, want := response.Detail, tc.expectedDetail; !cmp.Equal(got, want, packageRepositoryOpts) {
+				t.Errorf("mismatch (-want +got):\n%s", cmp.Diff(want, got, packageRepositoryOpts))
+			}
+		})
+	}
+}
+
+func TestAddPackageRepository(t *testing.T) {
+	testCases := []struct {
+		name               string
+		request            *corev1.AddPackageRepositoryRequest
+		expectedSpec       map[string]interface{}
+		expectedStatusCode codes.Code
+	}{
+		{
+			name: "it creates an imgpkgBundle repository by default",
+			request: &corev1.AddPackageRepositoryRequest{
+				Context:  &corev1.Context{Namespace: "default"},
+				Name:     "tce-repo",
+				Url:      "projects.registry.example.com/tce/main:stable",
+				Interval: 300,
+				Auth: &corev1.PackageRepositoryAuth{
+					PackageRepoAuthOneOf: &corev1.PackageRepositoryAuth_SecretRef{
+						SecretRef: &corev1.SecretKeyReference{Name: "registry-creds"},
+					},
+				},
+			},
+			expectedSpec: map[string]interface{}{
+				"syncPeriod": "5m0s",
+				"fetch": map[string]interface{}{
+					"imgpkgBundle": map[string]interface{}{
+						"image":     "projects.registry.example.com/tce/main:stable",
+						"secretRef": map[string]interface{}{"name": "registry-creds"},
+					},
+				},
+			},
+		},
+		{
+			name: "it creates a git repository",
+			request: &corev1.AddPackageRepositoryRequest{
+				Context: &corev1.Context{Namespace: "default"},
+				Name:    "tce-repo",
+				Type:    "git",
+				Url:     "https://github.com/example/repo",
+			},
+			expectedSpec: map[string]interface{}{
+				"fetch": map[string]interface{}{
+					"git": map[string]interface{}{
+						"url": "https://github.com/example/repo",
+					},
+				},
+			},
+		},
+		{
+			name: "it returns invalid argument for an unsupported type",
+			request: &corev1.AddPackageRepositoryRequest{
+				Context: &corev1.Context{Namespace: "default"},
+				Name:    "tce-repo",
+				Type:    "helm",
+				Url:     "https://charts.bitnami.com/bitnami",
+			},
+			expectedStatusCode: codes.InvalidArgument,
+		},
+		{
+			name: "it returns invalid argument for inline credentials",
+			request: &corev1.AddPackageRepositoryRequest{
+				Context: &corev1.Context{Namespace: "default"},
+				Name:    "tce-repo",
+				Url:     "projects.registry.example.com/tce/main:stable",
+				Auth: &corev1.PackageRepositoryAuth{
+					PackageRepoAuthOneOf: &corev1.PackageRepositoryAuth_UsernamePassword{
+						UsernamePassword: &corev1.UsernamePassword{Username: "foo", Password: "bar"},
+					},
+				},
+			},
+			expectedStatusCode: codes.InvalidArgument,
+		},
+		{
+			name: "it returns invalid argument without a name",
+			request: &corev1.AddPackageRepositoryRequest{
+				Context: &corev1.Context{Namespace: "default"},
+				Url:     "projects.registry.example.com/tce/main:stable",
+			},
+			expectedStatusCode: codes.InvalidArgument,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s, _, dynamicClient := newServerWithObjects(nil)
+
+			response, err := s.AddPackageRepository(context.Background(), tc.request)
+
+			if got, want := status.Code(err), tc.expectedStatusCode; got != want {
+				t.Fatalf("got: %+v, want: %+v, err: %+v", got, want, err)
+			}
+			if tc.expectedStatusCode != codes.OK {
+				return
+			}
+
+			if got, want := response.PackageRepoRef, repoRef(tc.request.Name, "default"); !cmp.Equal(got, want, packageRepositoryOpts) {
+				t.Errorf("mismatch (-want +got):\n%s", cmp.Diff(want, got, packageRepositoryOpts))
+			}
+			repo, err := dynamicClient.Resource(packageRepositoryGVR()).Namespace("default").Get(context.Background(), tc.request.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("%+v", err)
+			}
+			if got, want := repo.Object["spec"], tc.expectedSpec; !cmp.Equal(got, want) {
+				t.Errorf("mismatch (-want +got):\n%s", cmp.Diff(want, got))
+			}
+		})
+	}
+}
+
+func TestUpdatePackageRepository(t *testing.T) {
+	existingSpec := map[string]interface{}{
+		"syncPeriod": "5m0s",
+		"fetch": map[string]interface{}{
+			"image": map[string]interface{}{
+				"url":       "host.com/username/image:v0.1.0",
+				"secretRef": map[string]interface{}{"name": "registry-creds"},
+			},
+		},
+	}
+	testCases := []struct {
+		name               string
+		request            *corev1.UpdatePackageRepositoryRequest
+		existingRepos      []runtime.Object
+		expectedSpec       map[string]interface{}
+		expectedStatusCode codes.Code
+	}{
+		{
+			name: "it updates the url and removes the secret, keeping the fetch type and sync period",
+			request: &corev1.UpdatePackageRepositoryRequest{
+				PackageRepoRef: repoRef("tce-repo", "default"),
+				Url:            "host.com/username/image:v0.2.0",
+			},
+			existingRepos: []runtime.Object{packageRepositoryFromSpec("tce-repo", "default", existingSpec, nil)},
+			expectedSpec: map[string]interface{}{
+				"syncPeriod": "5m0s",
+				"fetch": map[string]interface{}{
+					"image": map[string]interface{}{
+						"url": "host.com/username/image:v0.2.0",
+					},
+				},
+			},
+		},
+		{
+			name: "it returns not found for a missing repository",
+			request: &corev1.UpdatePackageRepositoryRequest{
+				PackageRepoRef: repoRef("tce-repo", "default"),
+				Url:            "host.com/username/image:v0.2.0",
+			},
+			expectedStatusCode: codes.NotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s, _, dynamicClient := newServerWithObjects(nil, tc.existingRepos...)
+
+			response, err := s.UpdatePackageRepository(context.Background(), tc.request)
+
+			if got, want := status.Code(err), tc.expectedStatusCode; got != want {
+				t.Fatalf("got: %+v, want: %+v, err: %+v", got, want, err)
+			}
+			if tc.expectedStatusCode != codes.OK {
+				return
+			}
+
+			if got, want := response.PackageRepoRef, tc.request.PackageRepoRef; !cmp.Equal(got, want, packageRepositoryOpts) {
+				t.Errorf("mismatch (-want +got):\n%s", cmp.Diff(want, got, packageRepositoryOpts))
+			}
+			repo, err := dynamicClient.Resource(packageRepositoryGVR()).Namespace("default").Get(context.Background(), "tce-repo", metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("%+v", err)
+			}
+			if got, want := repo.Object["spec"], tc.expectedSpec; !cmp.Equal(got, want) {
+				t.Errorf("mismatch (-want +got):\n%s", cmp.Diff(want, got))
+			}
+		})
+	}
+}
+
+func TestDeletePackageRepository(t *testing.T) {
+	testCases := []struct {
+		name               string
+		request            *corev1.DeletePackageRepositoryRequest
+		existingRepos      []runtime.Object
+		expectedStatusCode codes.Code
+	}{
+		{
+			name: "it deletes the repository",
+			request: &corev1.DeletePackageRepositoryRequest{
+				PackageRepoRef: repoRef("tce-repo", "default"),
+			},
+			existingRepos: []runtime.Object{packageRepositoryFromSpec("tce-repo", "default", map[string]interface{}{}, nil)},
+		},
+		{
+			name: "it returns not found for a missing repository",
+			request: &corev1.DeletePackageRepositoryRequest{
+				PackageRepoRef: repoRef("tce-repo", "default"),
+			},
+			expectedStatusCode: codes.NotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s, _, dynamicClient := newServerWithObjects(nil, tc.existingRepos...)
+
+			_, err := s.DeletePackageRepository(context.Background(), tc.request)
+
+			if got, want := status.Code(err), tc.expectedStatusCode; got != want {
+				t.Fatalf("got: %+v, want: %+v, err: %+v", got, want, err)
+			}
+			if tc.expectedStatusCode != codes.OK {
+				return
+			}
+
+			_, err = dynamicClient.Resource(packageRepositoryGVR()).Namespace("default").Get(context.Background(), "tce-repo", metav1.GetOptions{})
+			if !k8serrors.IsNotFound(err) {
+				t.Errorf("got: %+v, want: not found error", err)
+			}
+		})
+	}
+}
+
+func TestRefreshPackageRepository(t *testing.T) {
+	repo := packageRepositoryFromSpec("tce-repo", "default", map[string]interface{}{
+		"fetch": map[string]interface{}{
+			"imgpkgBundle": map[string]interface{}{
+				"image": "projects.registry.example.com/tce/main:stable",
+			},
+		},
+	}, nil)
+	s, _, dynamicClient := newServerWithObjects(nil, repo)
+
+	response, err := s.RefreshPackageRepository(context.Background(), &corev1.RefreshPackageRepositoryRequest{
+		PackageRepoRef: repoRef("tce-repo", "default"),
+	})
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if got, want := response.PackageRepoRef, repoRef("tce-repo", "default"); !cmp.Equal(got, want, packageRepositoryOpts) {
+		t.Errorf("mismatch (-want +got):\n%s", cmp.Diff(want, got, packageRepositoryOpts))
+	}
+
+	// the repository is paused and then unpaused
+	updates := 0
+	for _, action := range dynamicClient.Actions() {
+		if action.GetVerb() == "update" {
+			updates++
+		}
+	}
+	if got, want := updates, 2; got != want {
+		t.Errorf("got: %d updates, want: %d", got, want)
+	}
+	refreshed, err := dynamicClient.Resource(packageRepositoryGVR()).Namespace("default").Get(context.Background(), "tce-repo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if paused, _, _ := unstructured.NestedBool(refreshed.Object, "spec", "paused"); paused {
+		t.Errorf("got: paused repository, want: unpaused")
+	}
+}
+
+func packageRepositoryFromSpec(name string, namespace string, spec map[string]interface{}, status map[string]interface{}) *unstructured.Unstructured {
+	obj := map[string]interface{}{
+		"apiVersion": fmt.Sprintf("%s/%s", packagingGroup, installPackageVersion),
+		"kind":       repositoryResource,
+		"metadata": map[string]interface{}{
+			"name":       name,
+			"namespace":  namespace,
+			"generation": int64(1),
+		},
+		"spec": spec,
+	}
+	if status != nil {
+		status["observedGeneration"] = int64(1)
+		obj["status"] = status
+	}
+	return &unstructured.Unstructured{
+		Object: obj,
+	}
+}
+
+func repoRef(name, namespace string) *corev1.PackageRepositoryReference {
+	return &corev1.PackageRepositoryReference{
+		Context: &corev1.Context{
+			Namespace: namespace,
+		},
+		Identifier: name,
+		Plugin:     GetPluginDetail(),
+	}
+}
+
+var pendingRepoStatus = &corev1.PackageRepositoryStatus{
+	Reason: corev1.PackageRepositoryStatus_STATUS_REASON_PENDING,
+}
+
+var packageRepositoryOpts = cmpopts.IgnoreUnexported(
+	corev1.PackageRepositorySummary{},
+	corev1.PackageRepositoryDetail{},
+	corev1.PackageRepositoryReference{},
+	corev1.PackageRepositoryStatus{},
+	corev1.PackageRepositoryAuth{},
+	corev1.SecretKeyReference{},
+	corev1.Context{},
+	plugins.Plugin{},
+)
+
+func packageInstallFromSpec(name string, namespace string, spec map[string]interface{}, status map[string]interface{}) *unstructured.Unstructured {
+	obj := map[string]interface{}{
+		"apiVersion": fmt.Sprintf("%s/%s", packagingGroup, packageInstallVersion),
+		"kind":       packageInstallResource,
+		"metadata": map[string]interface{}{
+			"name":       name,
+			"namespace":  namespace,
+			"generation": int64(1),
+		},
+		"spec": spec,
+	}
+	if status != nil {
+		status["observedGeneration"] = int64(1)
+		obj["status"] = status
+	}
+	return &unstructured.Unstructured{
+		Object: obj,
+	}
+}
+
+func reconcileSucceededStatus(version string) map[string]interface{} {
+	return map[string]interface{}{
+		"version":             version,
+		"friendlyDescription": "Reconcile succeeded",
+		"conditions": []interface{}{
+			map[string]interface{}{
+				"type":   "ReconcileSucceeded",
+				"status": "True",
+			},
+		},
+	}
+}
+
+func newServerWithObjects(typedObjects []runtime.Object, unstructuredObjects ...runtime.Object) (*Server, *typfake.Clientset, *dynfake.FakeDynamicClient) {
+	typedClient := typfake.NewSimpleClientset(typedObjects...)
+	dynamicClient := dynfake.NewSimpleDynamicClientWithCustomListKinds(
+		runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{
+			packageInstallGVR(): "PackageInstallList",
+		},
+		unstructuredObjects...,
+	)
+	s := &Server{
+		clientGetter: func(context.Context, string) (kubernetes.Interface, dynamic.Interface, error) {
+			return typedClient, dynamicClient, nil
+		},
+	}
+	return s, typedClient, dynamicClient
+}
+
+func installedRef(name, namespace string) *corev1.InstalledPackageReference {
+	return &corev1.InstalledPackageReference{
+		Context: &corev1.Context{
+			Namespace: namespace,
+		},
+		Identifier: name,
+		Plugin:     GetPluginDetail(),
+	}
+}
+
+var installedPackageOpts = cmpopts.IgnoreUnexported(
+	corev1.InstalledPackageSummary{},
+	corev1.InstalledPackageDetail{},
+	corev1.InstalledPackageReference{},
+	corev1.AvailablePackageReference{},
+	corev1.Context{},
+	corev1.VersionReference{},
+	corev1.PackageAppVersion{},
+	corev1.InstalledPackageStatus{},
+	corev1.ReconciliationOptions{},
+	plugins.Plugin{},
+)
+
+func TestGetInstalledPackageSummaries(t *testing.T) {
+	testCases := []struct {
+		name               string
+		request            *corev1.GetInstalledPackageSummariesRequest
+		existingInstalls   []runtime.Object
+		expectedPackages   []*corev1.InstalledPackageSummary
+		expectedNextToken  string
+		expectedStatusCode codes.Code
+	}{
+		{
+			name:    "it returns the package installs in the requested namespace",
+			request: &corev1.GetInstalledPackageSummariesRequest{Context: &corev1.Context{Namespace: "default"}},
+			existingInstalls: []runtime.Object{
+				packageInstallFromSpec("my-tetris", "default", map[string]interface{}{
+					"packageRef": map[string]interface{}{
+						"refName": "tetris.foo.example.com",
+						"versionSelection": map[string]interface{}{
+							"constraints": "1.2.3",
+						},
+					},
+				}, reconcileSucceededStatus("1.2.3")),
+				packageInstallFromSpec("other-tetris", "other-ns", map[string]interface{}{
+					"packageRef": map[string]interface{}{
+						"refName": "tetris.foo.example.com",
+					},
+				}, nil),
+			},
+			expectedPackages: []*corev1.InstalledPackageSummary{
+				{
+					InstalledPackageRef: installedRef("my-tetris", "default"),
+					Name:                "my-tetris",
+					PkgVersionReference: &corev1.VersionReference{Version: "1.2.3"},
+					CurrentVersion:      &corev1.PackageAppVersion{PkgVersion: "1.2.3"},
+					PkgDisplayName:      "tetris.foo.example.com",
+					Status: &corev1.InstalledPackageStatus{
+						Ready:      true,
+						Reason:     corev1.InstalledPackageStatus_STATUS_REASON_INSTALLED,
+						UserReason: "Reconcile succeeded",
+					},
+				},
+			},
+		},
+		{
+			name: "it returns the first page of package installs with a next page token",
+			request: &corev1.GetInstalledPackageSummariesRequest{
+				Context:           &corev1.Context{Namespace: "default"},
+				PaginationOptions: &corev1.PaginationOptions{PageSize: 1},
+			},
+			existingInstalls: []runtime.Object{
+				packageInstallFromSpec("my-tetris", "default", map[string]interface{}{
+					"packageRef": map[string]interface{}{
+						"refName": "tetris.foo.example.com",
+					},
+				}, nil),
+				packageInstallFromSpec("other-tetris", "default", map[string]interface{}{
+					"packageRef": map[string]interface{}{
+						"refName": "tetris.foo.example.com",
+					},
+				}, nil),
+			},
+			expectedPackages: []*corev1.InstalledPackageSummary{
+				{
+					InstalledPackageRef: installedRef("my-tetris", "default"),
+					Name:                "my-tetris",
+					PkgVersionReference: &corev1.VersionReference{},
+					PkgDisplayName:      "tetris.foo.example.com",
+					Status: &corev1.InstalledPackageStatus{
+						Reason: corev1.InstalledPackageStatus_STATUS_REASON_PENDING,
+					},
+				},
+			},
+			expectedNextToken: "1",
+		},
+		{
+			name: "it returns the page of package installs at the offset in the page token",
+			request: &corev1.GetInstalledPackageSummariesRequest{
+				Context:           &corev1.Context{Namespace: "default"},
+				PaginationOptions: &corev1.PaginationOptions{PageToken: "1", PageSize: 1},
+			},
+			existingInstalls: []runtime.Object{
+				packageInstallFromSpec("my-tetris", "default", map[string]interface{}{
+					"packageRef": map[string]interface{}{
+						"refName": "tetris.foo.example.com",
+					},
+				}, nil),
+				packageInstallFromSpec("other-tetris", "default", map[string]interface{}{
+					"packageRef": map[string]interface{}{
+						"refName": "tetris.foo.example.com",
+					},
+				}, nil),
+			},
+			expectedPackages: []*corev1.InstalledPackageSummary{
+				{
+					InstalledPackageRef: installedRef("other-tetris", "default"),
+					Name:                "other-tetris",
+					PkgVersionReference: &corev1.VersionReference{},
+					PkgDisplayName:      "tetris.foo.example.com",
+					Status: &corev1.InstalledPackageStatus{
+						Reason: corev1.InstalledPackageStatus_STATUS_REASON_PENDING,
+					},
+				},
+			},
+		},
+		{
+			name: "it returns invalid argument for an invalid page token",
+			request: &corev1.GetInstalledPackageSummariesRequest{
+				Context:           &corev1.Context{Namespace: "default"},
+				PaginationOptions: &corev1.PaginationOptions{PageToken: "not-a-number", PageSize: 1},
+			},
+			expectedStatusCode: codes.InvalidArgument,
+		},
+		{
+			name:    "it returns package installs referencing the requested cluster",
+			request: &corev1.GetInstalledPackageSummariesRequest{Context: &corev1.Context{Cluster: "other", Namespace: "default"}},
+			existingInstalls: []runtime.Object{
+				packageInstallFromSpec("my-tetris", "default", map[string]interface{}{
+					"packageRef": map[string]interface{}{
+						"refName": "tetris.foo.example.com",
+					},
+				}, nil),
+			},
+			expectedPackages: []*corev1.InstalledPackageSummary{
+				{
+					InstalledPackageRef: &corev1.InstalledPackageReference{
+						Context:    &corev1.Context{Cluster: "other", Namespace: "default"},
+						Identifier: "my-tetris",
+						Plugin:     GetPluginDetail(),
+					},
+					Name:                "my-tetris",
+					PkgVersionReference: &corev1.VersionReference{},
+					PkgDisplayName:      "tetris.foo.example.com",
+					Status: &corev1.InstalledPackageStatus{
+						Reason: corev1.InstalledPackageStatus_STATUS_REASON_PENDING,
+					},
+				},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s, _, _ := newServerWithObjects(nil, tc.existingInstalls...)
+
+			response, err := s.GetInstalledPackageSummaries(context.Background(), tc.request)
+
+			if got, want := status.Code(err), tc.expectedStatusCode; got != want {
+				t.Fatalf("got: %+v, want: %+v, err: %+v", got, want, err)
+			}
+			if tc.expectedStatusCode != codes.OK {
+				return
+			}
+
+			if got, want := response.InstalledPackagesSummaries, tc.expectedPackages; !cmp.Equal(got, want, installedPackageOpts) {
+				t.Errorf("mismatch (-want +got):\n%s", cmp.Diff(want, got, installedPackageOpts))
+			}
+			if got, want := response.NextPageToken, tc.expectedNextToken; got != want {
+				t.Errorf("got: %q, want: %q", got, want)
+			}
+		})
+	}
+}
+
+func TestGetInstalledPackageDetail(t *testing.T) {
+	testCases := []struct {
+		name               string
+		request            *corev1.GetInstalledPackageDetailRequest
+		existingSecrets    []runtime.Object
+		existingInstalls   []runtime.Object
+		expectedDetail     *corev1.InstalledPackageDetail
+		expectedStatusCode codes.Code
+	}{
+		{
+			name: "it returns the package install with its values and reconciliation options",
+			request: &corev1.GetInstalledPackageDetailRequest{
+				InstalledPackageRef: installedRef("my-tetris", "default"),
+			},
+			existingSecrets: []runtime.Object{
+				&k8scorev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{Name: "my-tetris-values", Namespace: "default"},
+					Data:       map[string][]byte{"values.yaml": []byte("difficulty: hard\n")},
+				},
+			},
+			existingInstalls: []runtime.Object{
+				packageInstallFromSpec("my-tetris", "default", map[string]interface{}{
+					"serviceAccountName": "default-sa",
+					"syncPeriod":         "10m0s",
+					"paused":             false,
+					"packageRef": map[string]interface{}{
+						"refName": "tetris.foo.example.com",
+						"versionSelection": map[string]interface{}{
+							"constraints": "1.2.3",
+						},
+					},
+					"values": []interface{}{
+						map[string]interface{}{
+							"secretRef": map[string]interface{}{"name": "my-tetris-values"},
+						},
+					},
+				}, map[string]interface{}{
+					"version":             "1.2.3",
+					"friendlyDescription": "Reconcile failed: Error (see .status.usefulErrorMessage for details)",
+					"usefulErrorMessage":  "kapp: Error: Timed out waiting after 15m0s",
+					"conditions": []interface{}{
+						map[string]interface{}{
+							"type":    "ReconcileFailed",
+							"status":  "True",
+							"message": "Error (see .status.usefulErrorMessage for details)",
+						},
+					},
+				}),
+			},
+			expectedDetail: &corev1.InstalledPackageDetail{
+				InstalledPackageRef: installedRef("my-tetris", "default"),
+				PkgVersionReference: &corev1.VersionReference{Version: "1.2.3"},
+				Name:                "my-tetris",
+				CurrentVersion:      &corev1.PackageAppVersion{PkgVersion: "1.2.3"},
+				ValuesApplied:       "difficulty: hard\n",
+				ReconciliationOptions: &corev1.ReconciliationOptions{
+					Interval:           600,
+					ServiceAccountName: "default-sa",
+				},
+				Status: &corev1.InstalledPackageStatus{
+					Reason:     corev1.InstalledPackageStatus_STATUS_REASON_FAILED,
+					UserReason: "kapp: Error: Timed out waiting after 15m0s",
+				},
+				AvailablePackageRef: &corev1.AvailablePackageReference{
+					Context:    &corev1.Context{Namespace: "default"},
+					Identifier: "tetris.foo.example.com",
+					Plugin:     GetPluginDetail(),
+				},
+			},
+		},
+		{
+			name: "it returns not found for a missing package install",
+			request: &corev1.GetInstalledPackageDetailRequest{
+				InstalledPackageRef: installedRef("my-tetris", "default"),
+			},
+			expectedStatusCode: codes.NotFound,
+		},
+		{
+			name: "it returns invalid argument when the ref has no namespace",
+			request: &corev1.GetInstalledPackageDetailRequest{
+				InstalledPackageRef: installedRef("my-tetris", ""),
+			},
+			expectedStatusCode: codes.InvalidArgument,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s, _, _ := newServerWithObjects(tc.existingSecrets, tc.existingInstalls...)
+
+			response, err := s.GetInstalledPackageDetail(context.Background(), tc.request)
+
+			if got, want := status.Code(err), tc.expectedStatusCode; got != want {
+				t.Fatalf("got: %+v, want: %+v, err: %+v", got, want, err)
+			}
+			if tc.expectedStatusCode != codes.OK {
+				return
+			}
+
+			if got, want := response.InstalledPackageDetail, tc.expectedDetail; !cmp.Equal(got, want, installedPackageOpts) {
+				t.Errorf("mismatch (-want +got):\n%s", cmp.Diff(want, got, installedPackageOpts))
+			}
+		})
+	}
+}
+
+func TestCreateInstalledPackage(t *testing.T) {
+	testCases := []struct {
+		name               string
+		request            *corev1.CreateInstalledPackageRequest
+		existingInstalls   []runtime.Object
+		expectedSpec       map[string]interface{}
+		expectedSecretData map[string]string
+		expectedStatusCode codes.Code
+	}{
+		{
+			name: "it creates the package install and its values secret",
+			request: &corev1.CreateInstalledPackageRequest{
+				AvailablePackageRef: &corev1.AvailablePackageReference{
+					Context:    &corev1.Context{Namespace: "default"},
+					Identifier: "tetris.foo.example.com",
+				},
+				TargetContext:       &corev1.Context{Namespace: "default"},
+				Name:                "my-tetris",
+				PkgVersionReference: &corev1.VersionReference{Version: "1.2.3"},
+				Values:              "difficulty: hard\n",
+				ReconciliationOptions: &corev1.ReconciliationOptions{
+					Interval:           300,
+					ServiceAccountName: "default-sa",
+				},
+			},
+			expectedSpec: map[string]interface{}{
+				"serviceAccountName": "default-sa",
+				"syncPeriod":         "5m0s",
+				"paused":             false,
+				"packageRef": map[string]interface{}{
+					"refName": "tetris.foo.example.com",
+					"versionSelection": map[string]interface{}{
+						"constraints": "1.2.3",
+					},
+				},
+				"values": []interface{}{
+					map[string]interface{}{
+						"secretRef": map[string]interface{}{"name": "my-tetris-values"},
+					},
+				},
+			},
+			expectedSecretData: map[string]string{"values.yaml": "difficulty: hard\n"},
+		},
+		{
+			name: "it creates the package install without a values secret when no values are provided",
+			request: &corev1.CreateInstalledPackageRequest{
+				AvailablePackageRef: &corev1.AvailablePackageReference{
+					Identifier: "tetris.foo.example.com",
+				},
+				TargetContext: &corev1.Context{Namespace: "default"},
+				Name:          "my-tetris",
+				ReconciliationOptions: &corev1.ReconciliationOptions{
+					ServiceAccountName: "default-sa",
+				},
+			},
+			expectedSpec: map[string]interface{}{
+				"serviceAccountName": "default-sa",
+				"paused":             false,
+				"packageRef": map[string]interface{}{
+					"refName": "tetris.foo.example.com",
+				},
+			},
+		},
+		{
+			name: "it returns invalid argument when no service account is provided",
+			request: &corev1.CreateInstalledPackageRequest{
+				AvailablePackageRef: &corev1.AvailablePackageReference{
+					Identifier: "tetris.foo.example.com",
+				},
+				TargetContext: &corev1.Context{Namespace: "default"},
+				Name:          "my-tetris",
+			},
+			expectedStatusCode: codes.InvalidArgument,
+		},
+		{
+			name: "it returns already exists and removes the values secret if the package install exists",
+			request: &corev1.CreateInstalledPackageRequest{
+				AvailablePackageRef: &corev1.AvailablePackageReference{
+					Identifier: "tetris.foo.example.com",
+				},
+				TargetContext: &corev1.Context{Namespace: "default"},
+				Name:          "my-tetris",
+				Values:        "difficulty: hard\n",
+				ReconciliationOptions: &corev1.ReconciliationOptions{
+					ServiceAccountName: "default-sa",
+				},
+			},
+			existingInstalls: []runtime.Object{
+				packageInstallFromSpec("my-tetris", "default", map[string]interface{}{}, nil),
+			},
+			expectedStatusCode: codes.AlreadyExists,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s, typedClient, dynamicClient := newServerWithObjects(nil, tc.existingInstalls...)
+
+			response, err := s.CreateInstalledPackage(context.Background(), tc.request)
+
+			if got, want := status.Code(err), tc.expectedStatusCode; got != want {
+				t.Fatalf("got: %+v, want: %+v, err: %+v", got, want, err)
+			}
+
+			secret, secretErr := typedClient.CoreV1().Secrets("default").Get(context.Background(), "my-tetris-values", metav1.GetOptions{})
+			if tc.expectedSecretData == nil {
+				if !k8serrors.IsNotFound(secretErr) {
+					t.Errorf("got: %+v, want: not found error", secretErr)
+				}
+			} else if secretErr != nil {
+				t.Fatalf("%+v", secretErr)
+			} else if got, want := secret.StringData, tc.expectedSecretData; !cmp.Equal(got, want) {
+				t.Errorf("mismatch (-want +got):\n%s", cmp.Diff(want, got))
+			}
+
+			if tc.expectedStatusCode != codes.OK {
+				return
+			}
+
+			if got, want := response.InstalledPackageRef, installedRef("my-tetris", "default"); !cmp.Equal(got, want, installedPackageOpts) {
+				t.Errorf("mismatch (-want +got):\n%s", cmp.Diff(want, got, installedPackageOpts))
+			}
+
+			install, err := dynamicClient.Resource(packageInstallGVR()).Namespace("default").Get(context.Background(), "my-tetris", metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("%+v", err)
+			}
+			if got, want := install.Object["spec"], tc.expectedSpec; !cmp.Equal(got, want) {
+				t.Errorf("mismatch (-want +got):\n%s", cmp.Diff(want, got))
+			}
+		})
+	}
+}
+
+func TestUpdateInstalledPackage(t *testing.T) {
+	existingSpec := func() map[string]interface{} {
+		return map[string]interface{}{
+			"serviceAccountName": "default-sa",
+			"packageRef": map[string]interface{}{
+				"refName": "tetris.foo.example.com",
+				"versionSelection": map[string]interface{}{
+					"constraints": "1.2.3",
+				},
+			},
+			"values": []interface{}{
+				map[string]interface{}{
+					"secretRef": map[string]interface{}{"name": "my-tetris-values"},
+				},
+			},
+		}
+	}
+	existingSecret := func() runtime.Object {
+		return &k8scorev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "my-tetris-values", Namespace: "default"},
+			Data:       map[string][]byte{"values.yaml": []byte("difficulty: hard\n")},
+		}
+	}
+
+	testCases := []struct {
+		name               string
+		request            *corev1.UpdateInstalledPackageRequest
+		existingInstalls   []runtime.Object
+		expectedSpec       map[string]interface{}
+		expectedSecretData map[string]string
+		expectedStatusCode codes.Code
+	}{
+		{
+			name: "it updates the version, values and reconciliation options",
+			request: &corev1.UpdateInstalledPackageRequest{
+				InstalledPackageRef: installedRef("my-tetris", "default"),
+				PkgVersionReference: &corev1.VersionReference{Version: "1.2.4"},
+				Values:              "difficulty: easy\n",
+				ReconciliationOptions: &corev1.ReconciliationOptions{
+					Suspend: true,
+				},
+			},
+			existingInstalls: []runtime.Object{packageInstallFromSpec("my-tetris", "default", existingSpec(), nil)},
+			expectedSpec: map[string]interface{}{
+				"serviceAccountName": "default-sa",
+				"paused":             true,
+				"packageRef": map[string]interface{}{
+					"refName": "tetris.foo.example.com",
+					"versionSelection": map[string]interface{}{
+						"constraints": "1.2.4",
+					},
+				},
+				"values": []interface{}{
+					map[string]interface{}{
+						"secretRef": map[string]interface{}{"name": "my-tetris-values"},
+					},
+				},
+			},
+			expectedSecretData: map[string]string{"values.yaml": "difficulty: easy\n"},
+		},
+		{
+			name: "it keeps the version and removes the values when not provided",
+			request: &corev1.UpdateInstalledPackageRequest{
+				InstalledPackageRef: installedRef("my-tetris", "default"),
+			},
+			existingInstalls: []runtime.Object{packageInstallFromSpec("my-tetris", "default", existingSpec(), nil)},
+			expectedSpec: map[string]interface{}{
+				"serviceAccountName": "default-sa",
+				"packageRef": map[string]interface{}{
+					"refName": "tetris.foo.example.com",
+					"versionSelection": map[string]interface{}{
+						"constraints": "1.2.3",
+					},
+				},
+			},
+		},
+		{
+			name: "it returns not found for a missing package install",
+			request: &corev1.UpdateInstalledPackageRequest{
+				InstalledPackageRef: installedRef("other", "default"),
+			},
+			expectedStatusCode: codes.NotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s, typedClient, dynamicClient := newServerWithObjects([]runtime.Object{existingSecret()}, tc.existingInstalls...)
+
+			response, err := s.UpdateInstalledPackage(context.Background(), tc.request)
+
+			if got, want := status.Code(err), tc.expectedStatusCode; got != want {
+				t.Fatalf("got: %+v, want: %+v, err: %+v", got, want, err)
+			}
+			if tc.expectedStatusCode != codes.OK {
+				return
+			}
+
+			if got, want := response.InstalledPackageRef, tc.request.InstalledPackageRef; !cmp.Equal(got, want, installedPackageOpts) {
+				t.Errorf("mismatch (-want +got):\n%s", cmp.Diff(want, got, installedPackageOpts))
+			}
+
+			install, err := dynamicClient.Resource(packageInstallGVR()).Namespace("default").Get(context.Background(), "my-tetris", metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("%+v", err)
+			}
+			if got, want := install.Object["spec"], tc.expectedSpec; !cmp.Equal(got, want) {
+				t.Errorf("mismatch (-want +got):\n%s", cmp.Diff(want, got))
+			}
+
+			secret, err := typedClient.CoreV1().Secrets("default").Get(context.Background(), "my-tetris-values", metav1.GetOptions{})
+			if tc.expectedSecretData == nil {
+				if !k8serrors.IsNotFound(err) {
+					t.Errorf("got: %+v, want: not found error", err)
+				}
+			} else if err != nil {
+				t.Fatalf("%+v", err)
+			} else if got, want := secret.StringData, tc.expectedSecretData; !cmp.Equal(got, want) {
+				t.Errorf("mismatch (-want +got):\n%s", cmp.Diff(want, got))
+			}
+		})
+	}
+}
+
+func TestDeleteInstalledPackage(t *testing.T) {
+	testCases := []struct {
+		name               string
+		request            *corev1.DeleteInstalledPackageRequest
+		existingInstalls   []runtime.Object
+		expectedStatusCode codes.Code
+	}{
+		{
+			name: "it deletes the package install and its values secret",
+			request: &corev1.DeleteInstalledPackageRequest{
+				InstalledPackageRef: installedRef("my-tetris", "default"),
+			},
+			existingInstalls: []runtime.Object{packageInstallFromSpec("my-tetris", "default", map[string]interface{}{}, nil)},
+		},
+		{
+			name: "it returns not found for a missing package install",
+			request: &corev1.DeleteInstalledPackageRequest{
+				InstalledPackageRef: installedRef("my-tetris", "default"),
+			},
+			expectedStatusCode: codes.NotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			secret := &k8scorev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "my-tetris-values", Namespace: "default"},
+			}
+			s, typedClient, dynamicClient := newServerWithObjects([]runtime.Object{secret}, tc.existingInstalls...)
+
+			_, err := s.DeleteInstalledPackage(context.Background(), tc.request)
+
+			if got, want := status.Code(err), tc.expectedStatusCode; got != want {
+				t.Fatalf("got: %+v, want: %+v, err: %+v", got, want, err)
+			}
+			if tc.expectedStatusCode != codes.OK {
+				return
+			}
+
+			_, err = dynamicClient.Resource(packageInstallGVR()).Namespace("default").Get(context.Background(), "my-tetris", metav1.GetOptions{})
+			if !k8serrors.IsNotFound(err) {
+				t.Errorf("got: %+v, want: not found error", err)
+			}
+			_, err = typedClient.CoreV1().Secrets("default").Get(context.Background(), "my-tetris-values", metav1.GetOptions{})
+			if !k8serrors.IsNotFound(err) {
+				t.Errorf("got: %+v, want: not found error", err)
+			}
+		})
+	}
+}
+
+func TestInstalledPackageStatusFromUnstructured(t *testing.T) {
+	testCases := []struct {
+		name           string
+		generation     int64
+		status         map[string]interface{}
+		expectedStatus *corev1.InstalledPackageStatus
+	}{
+		{
+			name:       "it returns pending when the package install has not been reconciled",
+			generation: 1,
+			expectedStatus: &corev1.InstalledPackageStatus{
+				Reason: corev1.InstalledPackageStatus_STATUS_REASON_PENDING,
+			},
+		},
+		{
+			name:       "it returns pending when the observed generation is outdated",
+			generation: 2,
+			status:     reconcileSucceededStatus("1.2.3"),
+			expectedStatus: &corev1.InstalledPackageStatus{
+				Reason: corev1.InstalledPackageStatus_STATUS_REASON_PENDING,
+			},
+		},
+		{
+			name:       "it returns installed when the reconcile succeeded",
+			generation: 1,
+			status:     reconcileSucceededStatus("1.2.3"),
+			expectedStatus: &corev1.InstalledPackageStatus{
+				Ready:      true,
+				Reason:     corev1.InstalledPackageStatus_STATUS_REASON_INSTALLED,
+				UserReason: "Reconcile succeeded",
+			},
+		},
+		{
+			name:       "it returns failed with the condition message when there is no useful error message",
+			generation: 1,
+			status: map[string]interface{}{
+				"conditions": []interface{}{
+					map[string]interface{}{
+						"type":    "ReconcileFailed",
+						"status":  "True",
+						"message": "Expected to find at least one version",
+					},
+				},
+			},
+			expectedStatus: &corev1.InstalledPackageStatus{
+				Reason:     corev1.InstalledPackageStatus_STATUS_REASON_FAILED,
+				UserReason: "Expected to find at least one version",
+			},
+		},
+		{
+			name:       "it returns pending while reconciling",
+			generation: 1,
+			status: map[string]interface{}{
+				"friendlyDescription": "Reconciling",
+				"conditions": []interface{}{
+					map[string]interface{}{
+						"type":   "Reconciling",
+						"status": "True",
+					},
+				},
+			},
+			expectedStatus: &corev1.InstalledPackageStatus{
+				Reason:     corev1.InstalledPackageStatus_STATUS_REASON_PENDING,
+				UserReason: "Reconciling",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			install := packageInstallFromSpec("my-tetris", "default", map[string]interface{}{}, tc.status)
+			install.SetGeneration(tc.generation)
+
+			if got, want := installedPackageStatusFromUnstructured(install), tc.expectedStatus; !cmp.Equal(got, want, installedPackageOpts) {
+				t.Errorf("mismatch (-want +got):\n%s", cmp.Diff(want, got, installedPackageOpts))
+			}
+		})
+	}
+}
+
+func pkgMetadataFromSpec(refName string, namespace string, spec map[string]interface{}) *unstructured.Unstructured {
+	return &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": fmt.Sprintf("%s/%s", dataPackagingGroup, dataPackagingVersion),
+			"kind":       pkgMetadataResource,
+			"metadata": map[string]interface{}{
+				"name":      refName,
+				"namespace": namespace,
+			},
+			"spec": spec,
+		},
+	}
+}
+
+func pkgFromSpec(refName string, version string, namespace string, spec map[string]interface{}) *unstructured.Unstructured {
+	if spec == nil {
+		spec = map[string]interface{}{}
+	}
+	spec["refName"] = refName
+	spec["version"] = version
+	return &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": fmt.Sprintf("%s/%s", dataPackagingGroup, dataPackagingVersion),
+			"kind":       pkgResource,
+			"metadata": map[string]interface{}{
+				"name":      fmt.Sprintf("%s.%s", refName, version),
+				"namespace": namespace,
+			},
+			"spec": spec,
+		},
+	}
+}
+
+func newServerWithPackages(objects ...runtime.Object) *Server {
+	dynamicClient := dynfake.NewSimpleDynamicClientWithCustomListKinds(
+		runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{
+			pkgGVR():         "PackageList",
+			pkgMetadataGVR(): "PackageMetadataList",
+		},
+		objects...,
+	)
+	return &Server{
+		clientGetter: func(context.Context, string) (kubernetes.Interface, dynamic.Interface, error) {
+			return nil, dynamicClient, nil
+		},
+	}
+}
+
+func TestGetAvailablePackageDetail(t *testing.T) {
+	existingObjects := []runtime.Object{
+		pkgMetadataFromSpec("tetris.foo.example.com", "default", map[string]interface{}{
+			"displayName":      "Classic Tetris",
+			"iconSVGBase64":    "Tm90IHJlYWxseSBTVkcK",
+			"shortDescription": "A great game for arcade gamers",
+			"longDescription":  "A few sentences but not really a readme",
+			"categories":       []interface{}{"logging", "daemon-set"},
+			"maintainers": []interface{}{
+				map[string]interface{}{"name": "person1"},
+				map[string]interface{}{"name": "person2"},
+			},
+		}),
+		pkgFromSpec("tetris.foo.example.com", "1.2.3", "default", map[string]interface{}{
+			"releaseNotes": "Some notes for 1.2.3",
+		}),
+		pkgFromSpec("tetris.foo.example.com", "1.10.0", "default", map[string]interface{}{
+			"releaseNotes": "Some notes for 1.10.0",
+			"releasedAt":   "2021-10-05T00:00:00Z",
+			"licenses":     []interface{}{"Apache 2.0"},
+			"valuesSchema": map[string]interface{}{
+				"openAPIv3": map[string]interface{}{
+					"title": "tetris.foo.example.com values schema",
+				},
+			},
+		}),
+		pkgFromSpec("other.foo.example.com", "2.0.0", "default", nil),
+	}
+
+	customDetail := func(data *v1alpha1.PackageDetailCustomData) *anypb.Any {
+		a, err := anypb.New(data)
+		if err != nil {
+			t.Fatalf("%+v", err)
+		}
+		return a
+	}
+
+	expectedDetail := func(version string, valuesSchema string, data *v1alpha1.PackageDetailCustomData) *corev1.AvailablePackageDetail {
+		return &corev1.AvailablePackageDetail{
+			AvailablePackageRef: &corev1.AvailablePackageReference{
+				Context:    &corev1.Context{Namespace: "default"},
+				Identifier: "tetris.foo.example.com",
+				Plugin:     GetPluginDetail(),
+			},
+			Name:             "tetris.foo.example.com",
+			PkgVersion:       version,
+			IconUrl:          "data:image/svg+xml;base64,Tm90IHJlYWxseSBTVkcK",
+			DisplayName:      "Classic Tetris",
+			ShortDescription: "A great game for arcade gamers",
+			LongDescription:  "A few sentences but not really a readme",
+			ValuesSchema:     valuesSchema,
+			Maintainers: []*corev1.Maintainer{
+				{Name: "person1"},
+				{Name: "person2"},
+			},
+			CustomDetail: customDetail(data),
+			Categories:   []string{"logging", "daemon-set"},
+		}
+	}
+
+	testCases := []struct {
+		name               string
+		request            *corev1.GetAvailablePackageDetailRequest
+		expectedDetail     *corev1.AvailablePackageDetail
+		expectedStatusCode codes.Code
+	}{
+		{
+			name: "it returns the detail of the latest version",
+			request: &corev1.GetAvailablePackageDetailRequest{
+				AvailablePackageRef: &corev1.AvailablePackageReference{
+					Context:    &corev1.Context{Namespace: "default"},
+					Identifier: "tetris.foo.example.com",
+				},
+			},
+			expectedDetail: expectedDetail("1.10.0", `{"title":"tetris.foo.example.com values schema"}`, &v1alpha1.PackageDetailCustomData{
+				ReleaseNotes: "Some notes for 1.10.0",
+				Licenses:     []string{"Apache 2.0"},
+				ReleasedAt:   "2021-10-05T00:00:00Z",
+			}),
+		},
+		{
+			name: "it returns the detail of the requested version",
+			request: &corev1.GetAvailablePackageDetailRequest{
+				AvailablePackageRef: &corev1.AvailablePackageReference{
+					Context:    &corev1.Context{Namespace: "default"},
+					Identifier: "tetris.foo.example.com",
+				},
+				PkgVersion: "1.2.3",
+			},
+			expectedDetail: expectedDetail("1.2.3", "", &v1alpha1.PackageDetailCustomData{
+				ReleaseNotes: "Some notes for 1.2.3",
+			}),
+		},
+		{
+			name: "it returns not found for a missing version",
+			request: &corev1.GetAvailablePackageDetailRequest{
+				AvailablePackageRef: &corev1.AvailablePackageReference{
+					Context:    &corev1.Context{Namespace: "default"},
+					Identifier: "tetris.foo.example.com",
+				},
+				PkgVersion: "9.9.9",
+			},
+			expectedStatusCode: codes.NotFound,
+		},
+		{
+			name: "it returns not found for a missing package metadata",
+			request: &corev1.GetAvailablePackageDetailRequest{
+				AvailablePackageRef: &corev1.AvailablePackageReference{
+					Context:    &corev1.Context{Namespace: "other-ns"},
+					Identifier: "tetris.foo.example.com",
+				},
+			},
+			expectedStatusCode: codes.NotFound,
+		},
+		{
+			name: "it returns invalid argument when no identifier is provided",
+			request: &corev1.GetAvailablePackageDetailRequest{
+				AvailablePackageRef: &corev1.AvailablePackageReference{
+					Context: &corev1.Context{Namespace: "default"},
+				},
+			},
+			expectedStatusCode: codes.InvalidArgument,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := newServerWithPackages(existingObjects...)
+
+			response, err := s.GetAvailablePackageDetail(context.Background(), tc.request)
+
+			if got, want := status.Code(err), tc.expectedStatusCode; got != want {
+				t.Fatalf("got: %+v, want: %+v, err: %+v", got, want, err)
+			}
+			if tc.expectedStatusCode != codes.OK {
+				return
+			}
+
+			// protocmp is required to compare the packed custom detail.
+			opt := protocmp.Transform()
+			if got, want := response.AvailablePackageDetail, tc.expectedDetail; !cmp.Equal(got, want, opt) {
+				t.Errorf("mismatch (-want +got):\n%s", cmp.Diff(want, got, opt))
+			}
+		})
+	}
+}
+
+func TestGetAvailablePackageVersions(t *testing.T) {
+	testCases := []struct {
+		name               string
+		request            *corev1.GetAvailablePackageVersionsRequest
+		existingObjects    []runtime.Object
+		expectedVersions   []*corev1.GetAvailablePackageVersionsResponse_PackageAppVersion
+		expectedStatusCode codes.Code
+	}{
+		{
+			name: "it returns the versions sorted by semver",
+			request: &corev1.GetAvailablePackageVersionsRequest{
+				AvailablePackageRef: &corev1.AvailablePackageReference{
+					Context:    &corev1.Context{Namespace: "default"},
+					Identifier: "tetris.foo.example.com",
+				},
+			},
+			existingObjects: []runtime.Object{
+				pkgFromSpec("tetris.foo.example.com", "1.2.3", "default", nil),
+				pkgFromSpec("tetris.foo.example.com", "1.10.0", "default", nil),
+				pkgFromSpec("tetris.foo.example.com", "1.2.10", "default", nil),
+				pkgFromSpec("tetris.foo.example.com", "1.10.0-rc.1", "default", nil),
+				pkgFromSpec("other.foo.example.com", "2.0.0", "default", nil),
+				pkgFromSpec("tetris.foo.example.com", "3.0.0", "other-ns", nil),
+			},
+			expectedVersions: []*corev1.GetAvailablePackageVersionsResponse_PackageAppVersion{
+				{PkgVersion: "1.10.0"},
+				{PkgVersion: "1.10.0-rc.1"},
+				{PkgVersion: "1.2.10"},
+				{PkgVersion: "1.2.3"},
+			},
+		},
+		{
+			name: "it returns an empty list for an unknown package",
+			request: &corev1.GetAvailablePackageVersionsRequest{
+				AvailablePackageRef: &corev1.AvailablePackageReference{
+					Context:    &corev1.Context{Namespace: "default"},
+					Identifier: "tetris.foo.example.com",
+				},
+			},
+			expectedVersions: []*corev1.GetAvailablePackageVersionsResponse_PackageAppVersion{},
+		},
+		{
+			name: "it returns invalid argument when no namespace is provided",
+			request: &corev1.GetAvailablePackageVersionsRequest{
+				AvailablePackageRef: &corev1.AvailablePackageReference{
+					Identifier: "tetris.foo.example.com",
+				},
+			},
+			expectedStatusCode: codes.InvalidArgument,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := newServerWithPackages(tc.existingObjects...)
+
+			response, err := s.GetAvailablePackageVersions(context.Background(), tc.request)
+
+			if got, want := status.Code(err), tc.expectedStatusCode; got != want {
+				t.Fatalf("got: %+v, want: %+v, err: %+v", got, want, err)
+			}
+			if tc.expectedStatusCode != codes.OK {
+				return
+			}
+
+			opt := cmpopts.IgnoreUnexported(corev1.GetAvailablePackageVersionsResponse_PackageAppVersion{})
+			if got, want := response.PackageAppVersions, tc.expectedVersions; !cmp.Equal(got, want, opt) {
+				t.Errorf("mismatch (-want +got):\n%s", cmp.Diff(want, got, opt))
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+/*
+Copyright © 2021 VMware
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package server
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	packages "github.com/kubeapps/kubeapps/cmd/kubeapps-apis/gen/core/packages/v1alpha1"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	log "k8s.io/klog/v2"
+)
+
+// mutatingMethodPrefixes are the prefixes of the names of the methods which
+// modify resources, for the core APIs as well as the plugin APIs.
+var mutatingMethodPrefixes = []string{"Create", "Update", "Delete", "Add", "Refresh", "Rollback"}
+
+// auditRecord describes a mutating request, who sent it and its outcome.
+type auditRecord struct {
+	Method string
+	User   string
+	Groups []string
+	Peer   string
+	// Cluster, Namespace and Identifier describe the target of the request,
+	// when it can be determined from the request message.
+	Cluster    string
+	Namespace  string
+	Identifier string
+	Code       string
+	Error      string
+	Duration   time.Duration
+}
+
+// isMutatingMethod returns whether the full gRPC method, such as
+// "/kubeappsapis.core.packages.v1alpha1.PackagesService/CreateInstalledPackage",
+// modifies resources.
+func isMutatingMethod(fullMethod string) bool {
+	method := fullMethod[strings.LastIndex(fullMethod, "/")+1:]
+	for _, prefix := range mutatingMethodPrefixes {
+		if strings.HasPrefix(method, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// newAuditRecord returns the audit record of a request. The request message
+// itself is not recorded as it may contain credentials, such as those of a
+// package repository.
+func newAuditRecord(ctx context.Context, fullMethod string, req interface{}, err error, duration time.Duration) auditRecord {
+	record := auditRecord{
+		Method:   fullMethod,
+		Code:     status.Code(err).String(),
+		Duration: duration,
+	}
+	if err != nil {
+		record.Error = status.Convert(err).Message()
+	}
+	if userInfo, ok := UserInfoFromContext(ctx); ok {
+		record.User = userInfo.Username
+		record.Groups = userInfo.Groups
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		record.Peer = p.Addr.String()
+	}
+
+	var targetContext *packages.Context
+	switch r := req.(type) {
+	case interface {
+		GetInstalledPackageRef() *packages.InstalledPackageReference
+	}:
+		targetContext = r.GetInstalledPackageRef().GetContext()
+		record.Identifier = r.GetInstalledPackageRef().GetIdentifier()
+	case interface {
+		GetPackageRepoRef() *packages.PackageRepositoryReference
+	}:
+		targetContext = r.GetPackageRepoRef().GetContext()
+		record.Identifier = r.GetPackageRepoRef().GetIdentifier()
+	case interface {
+		GetTargetContext() *packages.Context
+		GetName() string
+	}:
+		targetContext = r.GetTargetContext()
+		record.Identifier = r.GetName()
+	case interface {
+		GetContext() *packages.Context
+		GetName() string
+	}:
+		targetContext = r.GetContext()
+		record.Identifier = r.GetName()
+	}
+	record.Cluster = targetContext.GetCluster()
+	record.Namespace = targetContext.GetNamespace()
+	return record
+}
+
+// auditRequest emits a structured audit record for a mutating request,
+// including those rejected because of invalid credentials.
+func auditRequest(ctx context.Context, fullMethod string, req interface{}, err error, duration time.Duration) {
+	if !isMutatingMethod(fullMethod) {
+		return
+	}
+	record := newAuditRecord(ctx, fullMethod, req, err, duration)
+	log.InfoS("audit",
+		"method", record.Method,
+		"user", record.User,
+		"groups", record.Groups,
+		"peer", record.Peer,
+		"cluster", record.Cluster,
+		"namespace", record.Namespace,
+		"identifier", record.Identifier,
+		"code", record.Code,
+		"error", record.Error,
+		"duration", record.Duration)
+}
@@ -0,0 +1,147 @@
+/*
+Copyright © 2021 VMware
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package server
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	packages "github.com/kubeapps/kubeapps/cmd/kubeapps-apis/gen/core/packages/v1alpha1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+func TestIsMutatingMethod(t *testing.T) {
+	testCases := []struct {
+		fullMethod string
+		expected   bool
+	}{
+		{"/kubeappsapis.core.packages.v1alpha1.PackagesService/CreateInstalledPackage", true},
+		{"/kubeappsapis.core.packages.v1alpha1.RepositoriesService/AddPackageRepository", true},
+		{"/kubeappsapis.core.packages.v1alpha1.RepositoriesService/RefreshPackageRepository", true},
+		{"/kubeappsapis.plugins.helm.packages.v1alpha1.HelmPackagesService/RollbackInstalledPackage", true},
+		{"/kubeappsapis.core.packages.v1alpha1.PackagesService/GetInstalledPackageDetail", false},
+		{"/kubeappsapis.core.plugins.v1alpha1.PluginsService/GetConfiguredPlugins", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.fullMethod, func(t *testing.T) {
+			if got, want := isMutatingMethod(tc.fullMethod), tc.expected; got != want {
+				t.Errorf("got: %t, want: %t", got, want)
+			}
+		})
+	}
+}
+
+func TestNewAuditRecord(t *testing.T) {
+	userCtx := context.WithValue(context.Background(), userInfoContextKey, &validUser)
+	userCtx = peer.NewContext(userCtx, &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 4242}})
+
+	testCases := []struct {
+		name           string
+		ctx            context.Context
+		fullMethod     string
+		request        interface{}
+		err            error
+		expectedRecord auditRecord
+	}{
+		{
+			name:       "it records the user and target of an installed package request",
+			ctx:        userCtx,
+			fullMethod: "/kubeappsapis.core.packages.v1alpha1.PackagesService/DeleteInstalledPackage",
+			request: &packages.DeleteInstalledPackageRequest{
+				InstalledPackageRef: &packages.InstalledPackageReference{
+					Context:    &packages.Context{Cluster: "default", Namespace: "apps"},
+					Identifier: "my-apache",
+				},
+			},
+			expectedRecord: auditRecord{
+				Method:     "/kubeappsapis.core.packages.v1alpha1.PackagesService/DeleteInstalledPackage",
+				User:       "alice",
+				Groups:     []string{"system:authenticated"},
+				Peer:       "10.0.0.1:4242",
+				Cluster:    "default",
+				Namespace:  "apps",
+				Identifier: "my-apache",
+				Code:       "OK",
+				Duration:   time.Second,
+			},
+		},
+		{
+			name:       "it records the target of a request creating an installed package",
+			ctx:        context.Background(),
+			fullMethod: "/kubeappsapis.core.packages.v1alpha1.PackagesService/CreateInstalledPackage",
+			request: &packages.CreateInstalledPackageRequest{
+				TargetContext: &packages.Context{Cluster: "default", Namespace: "apps"},
+				Name:          "my-apache",
+			},
+			err: status.Errorf(codes.PermissionDenied, "forbidden"),
+			expectedRecord: auditRecord{
+				Method:     "/kubeappsapis.core.packages.v1alpha1.PackagesService/CreateInstalledPackage",
+				Cluster:    "default",
+				Namespace:  "apps",
+				Identifier: "my-apache",
+				Code:       "PermissionDenied",
+				Error:      "forbidden",
+				Duration:   time.Second,
+			},
+		},
+		{
+			name:       "it records the target but not the credentials of a request adding a repository",
+			ctx:        context.Background(),
+			fullMethod: "/kubeappsapis.core.packages.v1alpha1.RepositoriesService/AddPackageRepository",
+			request: &packages.AddPackageRepositoryRequest{
+				Context: &packages.Context{Cluster: "default", Namespace: "kubeapps"},
+				Name:    "bitnami",
+				Auth: &packages.PackageRepositoryAuth{
+					PackageRepoAuthOneOf: &packages.PackageRepositoryAuth_Header{
+						Header: "Bearer secret",
+					},
+				},
+			},
+			expectedRecord: auditRecord{
+				Method:     "/kubeappsapis.core.packages.v1alpha1.RepositoriesService/AddPackageRepository",
+				Cluster:    "default",
+				Namespace:  "kubeapps",
+				Identifier: "bitnami",
+				Code:       "OK",
+				Duration:   time.Second,
+			},
+		},
+		{
+			name:       "it records the method of a streaming request",
+			ctx:        context.Background(),
+			fullMethod: "/test.Service/UpdateStream",
+			err:        status.Errorf(codes.Unauthenticated, "invalid token"),
+			expectedRecord: auditRecord{
+				Method:   "/test.Service/UpdateStream",
+				Code:     "Unauthenticated",
+				Error:    "invalid token",
+				Duration: time.Second,
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			record := newAuditRecord(tc.ctx, tc.fullMethod, tc.request, tc.err, time.Second)
+			if got, want := record, tc.expectedRecord; !cmp.Equal(want, got) {
+				t.Errorf("mismatch (-want +got):\n%s", cmp.Diff(want, got))
+			}
+		})
+	}
+}
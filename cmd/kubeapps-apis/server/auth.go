@@ -0,0 +1,231 @@
+/*
+Copyright © 2021 VMware
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	log "k8s.io/klog/v2"
+)
+
+const (
+	// tokenReviewCacheTTL is how long the identity resolved for a token is
+	// reused before reviewing the token again.
+	tokenReviewCacheTTL = time.Minute
+	// tokenReviewCacheSize bounds the number of identities cached, so that
+	// the cache does not grow with every token seen.
+	tokenReviewCacheSize = 1000
+)
+
+type contextKey int
+
+const (
+	// tokenContextKey is the context key for the bearer token of the request.
+	tokenContextKey contextKey = iota
+	// userInfoContextKey is the context key for the identity of the user.
+	userInfoContextKey
+	// serviceAccountContextKey is the context key marking the background work of
+	// the plugins, which uses the service account of kubeapps-apis.
+	serviceAccountContextKey
+)
+
+// UserInfoFromContext returns the identity of the user sending the request, as
+// resolved by the authentication interceptor. It is not available for requests
+// without a bearer token or when the server is not configured to review tokens.
+func UserInfoFromContext(ctx context.Context) (*authenticationv1.UserInfo, bool) {
+	userInfo, ok := ctx.Value(userInfoContextKey).(*authenticationv1.UserInfo)
+	return userInfo, ok
+}
+
+// tokenAuthenticator resolves the identity of the user of a bearer token.
+type tokenAuthenticator interface {
+	authenticate(ctx context.Context, token string) (*authenticationv1.UserInfo, error)
+}
+
+// cachedUserInfo is an identity resolved for a token, valid until it expires.
+type cachedUserInfo struct {
+	userInfo *authenticationv1.UserInfo
+	expires  time.Time
+}
+
+// tokenReviewAuthenticator resolves the identity of the user with a TokenReview
+// on the cluster on which Kubeapps is installed, caching the result for a short
+// time so that tokens are not reviewed on every request. The SelfSubjectReview
+// API, which would not require the service account to be allowed to create
+// tokenreviews, is not available in the supported versions of Kubernetes.
+type tokenReviewAuthenticator struct {
+	client kubernetes.Interface
+	ttl    time.Duration
+	// now is a field so that tests can control the expiry of the cache.
+	now func() time.Time
+
+	mutex sync.Mutex
+	// cache is keyed by the hash of the token so that tokens are not kept in memory.
+	cache map[string]cachedUserInfo
+}
+
+func newTokenReviewAuthenticator(client kubernetes.Interface) *tokenReviewAuthenticator {
+	return &tokenReviewAuthenticator{
+		client: client,
+		ttl:    tokenReviewCacheTTL,
+		now:    time.Now,
+		cache:  map[string]cachedUserInfo{},
+	}
+}
+
+func (a *tokenReviewAuthenticator) authenticate(ctx context.Context, token string) (*authenticationv1.UserInfo, error) {
+	hash := sha256.Sum256([]byte(token))
+	key := hex.EncodeToString(hash[:])
+
+	a.mutex.Lock()
+	cached, ok := a.cache[key]
+	a.mutex.Unlock()
+	if ok && a.now().Before(cached.expires) {
+		return cached.userInfo, nil
+	}
+
+	review, err := a.client.AuthenticationV1().TokenReviews().Create(ctx, &authenticationv1.TokenReview{
+		Spec: authenticationv1.TokenReviewSpec{
+			Token: token,
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return nil, status.Errorf(codes.Unavailable, "unable to review the token: %v", err)
+	}
+	if !review.Status.Authenticated {
+		return nil, status.Errorf(codes.Unauthenticated, "invalid token: %s", review.Status.Error)
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if len(a.cache) >= tokenReviewCacheSize {
+		a.evictExpired()
+	}
+	if len(a.cache) < tokenReviewCacheSize {
+		a.cache[key] = cachedUserInfo{
+			userInfo: &review.Status.User,
+			expires:  a.now().Add(a.ttl),
+		}
+	}
+	return &review.Status.User, nil
+}
+
+// evictExpired removes the expired identities from the cache. It must be called
+// with the mutex held.
+func (a *tokenReviewAuthenticator) evictExpired() {
+	now := a.now()
+	for key, cached := range a.cache {
+		if !now.Before(cached.expires) {
+			delete(a.cache, key)
+		}
+	}
+}
+
+// authInterceptor extracts and validates the bearer token once for each
+// request, attaching it together with the identity of the user to the context
+// used by the handlers, and emits an audit record for mutating requests.
+type authInterceptor struct {
+	// authenticator is nil when the server is not configured to review tokens,
+	// in which case the token is only checked to be well formed and validated
+	// by the API server when used.
+	authenticator tokenAuthenticator
+}
+
+// authenticate returns the context for the request with the bearer token and
+// identity of the user. Requests without a token are let through as they
+// may be served by plugins not requiring credentials, or rejected by the RBAC
+// of the cluster.
+func (a *authInterceptor) authenticate(ctx context.Context) (context.Context, error) {
+	token, err := extractToken(ctx)
+	if err != nil {
+		return nil, status.Errorf(codes.Unauthenticated, "invalid authorization metadata: %v", err)
+	}
+	ctx = context.WithValue(ctx, tokenContextKey, token)
+	if token == "" || a.authenticator == nil {
+		return ctx, nil
+	}
+	userInfo, err := a.authenticator.authenticate(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	return context.WithValue(ctx, userInfoContextKey, userInfo), nil
+}
+
+// unaryInterceptor is the grpc.UnaryServerInterceptor authenticating each request.
+func (a *authInterceptor) unaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	authCtx, err := a.authenticate(ctx)
+	if err != nil {
+		auditRequest(ctx, info.FullMethod, req, err, time.Since(start))
+		return nil, err
+	}
+	response, err := handler(authCtx, req)
+	auditRequest(authCtx, info.FullMethod, req, err, time.Since(start))
+	return response, err
+}
+
+// streamInterceptor is the grpc.StreamServerInterceptor authenticating each
+// streaming request. The request message is not known when the stream starts,
+// so the audit record, if any, only describes the method.
+func (a *authInterceptor) streamInterceptor(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	start := time.Now()
+	authCtx, err := a.authenticate(stream.Context())
+	if err != nil {
+		auditRequest(stream.Context(), info.FullMethod, nil, err, time.Since(start))
+		return err
+	}
+	err = handler(srv, &authServerStream{ServerStream: stream, ctx: authCtx})
+	auditRequest(authCtx, info.FullMethod, nil, err, time.Since(start))
+	return err
+}
+
+// authServerStream overrides the context of a server stream with the
+// authenticated one.
+type authServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authServerStream) Context() context.Context {
+	return s.ctx
+}
+
+// newAuthInterceptor returns the interceptor for the server, which resolves the
+// identity of users with a TokenReview when enabled.
+func newAuthInterceptor(serveOpts ServeOptions) (*authInterceptor, error) {
+	if !serveOpts.TokenReview {
+		return &authInterceptor{}, nil
+	}
+	restConfig, err := getRestConfigFromServeOpts(serveOpts)
+	if err != nil {
+		return nil, err
+	}
+	client, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return nil, err
+	}
+	log.Infof("Resolving the identity of users with TokenReviews")
+	return &authInterceptor{
+		authenticator: newTokenReviewAuthenticator(client),
+	}, nil
+}
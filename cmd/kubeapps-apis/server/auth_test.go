@@ -0,0 +1,191 @@
+/*
+Copyright © 2021 VMware
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package server
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	typfake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+const validToken = "valid-token"
+
+var validUser = authenticationv1.UserInfo{
+	Username: "alice",
+	Groups:   []string{"system:authenticated"},
+}
+
+// newFakeTokenReviewClient returns a client which authenticates validToken as
+// validUser, counting the TokenReviews created.
+func newFakeTokenReviewClient(reviews *int) *typfake.Clientset {
+	client := typfake.NewSimpleClientset()
+	client.PrependReactor("create", "tokenreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		*reviews++
+		review := action.(k8stesting.CreateAction).GetObject().(*authenticationv1.TokenReview)
+		if review.Spec.Token == validToken {
+			review.Status = authenticationv1.TokenReviewStatus{
+				Authenticated: true,
+				User:          validUser,
+			}
+		} else {
+			review.Status = authenticationv1.TokenReviewStatus{
+				Error: "token expired",
+			}
+		}
+		return true, review, nil
+	})
+	return client
+}
+
+func TestTokenReviewAuthenticator(t *testing.T) {
+	reviews := 0
+	authenticator := newTokenReviewAuthenticator(newFakeTokenReviewClient(&reviews))
+	now := time.Date(2021, 9, 1, 10, 0, 0, 0, time.UTC)
+	authenticator.now = func() time.Time { return now }
+
+	userInfo, err := authenticator.authenticate(context.Background(), validToken)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if got, want := userInfo, &validUser; !cmp.Equal(want, got) {
+		t.Errorf("mismatch (-want +got):\n%s", cmp.Diff(want, got))
+	}
+
+	// The identity is cached until it expires.
+	if _, err = authenticator.authenticate(context.Background(), validToken); err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if got, want := reviews, 1; got != want {
+		t.Errorf("got: %d, want: %d", got, want)
+	}
+	now = now.Add(tokenReviewCacheTTL)
+	if _, err = authenticator.authenticate(context.Background(), validToken); err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if got, want := reviews, 2; got != want {
+		t.Errorf("got: %d, want: %d", got, want)
+	}
+
+	_, err = authenticator.authenticate(context.Background(), "invalid-token")
+	if got, want := status.Code(err), codes.Unauthenticated; got != want {
+		t.Errorf("got: %+v, want: %+v", got, want)
+	}
+	if got, want := len(authenticator.cache), 1; got != want {
+		t.Errorf("got: %d, want: %d", got, want)
+	}
+}
+
+func TestTokenReviewAuthenticatorError(t *testing.T) {
+	client := typfake.NewSimpleClientset()
+	client.PrependReactor("create", "tokenreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New("connection refused")
+	})
+	authenticator := newTokenReviewAuthenticator(client)
+
+	_, err := authenticator.authenticate(context.Background(), validToken)
+	if got, want := status.Code(err), codes.Unavailable; got != want {
+		t.Errorf("got: %+v, want: %+v", got, want)
+	}
+}
+
+func TestAuthInterceptor(t *testing.T) {
+	testCases := []struct {
+		name              string
+		authorization     string
+		tokenReview       bool
+		expectedToken     string
+		expectedUserInfo  *authenticationv1.UserInfo
+		expectedErrorCode codes.Code
+	}{
+		{
+			name:              "it lets through requests without a token",
+			tokenReview:       true,
+			expectedErrorCode: codes.OK,
+		},
+		{
+			name:              "it attaches the token without resolving the identity when token reviews are disabled",
+			authorization:     "Bearer " + validToken,
+			expectedToken:     validToken,
+			expectedErrorCode: codes.OK,
+		},
+		{
+			name:              "it attaches the token and identity of the user",
+			authorization:     "Bearer " + validToken,
+			tokenReview:       true,
+			expectedToken:     validToken,
+			expectedUserInfo:  &validUser,
+			expectedErrorCode: codes.OK,
+		},
+		{
+			name:              "it rejects requests with an invalid token",
+			authorization:     "Bearer invalid-token",
+			tokenReview:       true,
+			expectedErrorCode: codes.Unauthenticated,
+		},
+		{
+			name:              "it rejects requests with malformed authorization metadata",
+			authorization:     "Basic Zm9vOmJhcg==",
+			expectedErrorCode: codes.Unauthenticated,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			interceptor := &authInterceptor{}
+			reviews := 0
+			if tc.tokenReview {
+				interceptor.authenticator = newTokenReviewAuthenticator(newFakeTokenReviewClient(&reviews))
+			}
+			ctx := context.Background()
+			if tc.authorization != "" {
+				ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("authorization", tc.authorization))
+			}
+
+			handlerCalled := false
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+				handlerCalled = true
+				token, err := extractToken(ctx)
+				if err != nil {
+					t.Fatalf("%+v", err)
+				}
+				if got, want := token, tc.expectedToken; got != want {
+					t.Errorf("got: %q, want: %q", got, want)
+				}
+				userInfo, _ := UserInfoFromContext(ctx)
+				if got, want := userInfo, tc.expectedUserInfo; !cmp.Equal(want, got) {
+					t.Errorf("mismatch (-want +got):\n%s", cmp.Diff(want, got))
+				}
+				return "response", nil
+			}
+
+			_, err := interceptor.unaryInterceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/test.Service/GetTest"}, handler)
+			if got, want := status.Code(err), tc.expectedErrorCode; got != want {
+				t.Fatalf("got: %+v, want: %+v", got, want)
+			}
+			if got, want := handlerCalled, tc.expectedErrorCode == codes.OK; got != want {
+				t.Errorf("got: %t, want: %t", got, want)
+			}
+		})
+	}
+}
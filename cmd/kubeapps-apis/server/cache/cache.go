@@ -0,0 +1,569 @@
+/*
+Copyright © 2021 VMware
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cache implements a cache, shared by the plugins, of values computed from
+// kubernetes resources, which are kept up to date by watching these resources.
+package cache
+
+import (
+	"context"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kubeapps/kubeapps/cmd/kubeapps-apis/server"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	log "k8s.io/klog/v2"
+)
+
+// Value is what is stored in the cache for a kubernetes object. Each plug-in defines
+// its own types of values, which are encoded to be stored and decoded into a
+// new value of the same type when read back from the cache
+type Value interface {
+	encoding.BinaryMarshaler
+	encoding.BinaryUnmarshaler
+}
+
+// ResourceConfig configures how the objects of one of the watched resources are cached
+type ResourceConfig struct {
+	Gvr schema.GroupVersionResource
+	// 'OnAdd' and 'OnModify' hooks are called when a new or modified object comes about and
+	// allows the plug-in to return information about WHETHER OR NOT and WHAT is to be stored
+	// in the cache for a given k8s object (passed in as a untyped/unstructured map)
+	OnAdd    func(key string, unstructuredObj map[string]interface{}) (Value, bool, error)
+	OnModify func(key string, unstructuredObj map[string]interface{}) (Value, bool, error)
+	// OnDelete hook is called on the plug-in when the corresponding object is deleted in k8s
+	// cluster and returns whether the value of the object is to be removed from the cache
+	OnDelete func(key string, unstructuredObj map[string]interface{}) (bool, error)
+	// NewValue returns an empty value of the type stored for the resource, into which
+	// the values read back from the cache are decoded
+	NewValue func() Value
+}
+
+// Config is the configuration of a ResourceWatcherCache
+type Config struct {
+	ClientGetter server.KubernetesClientGetter
+	// the resources watched on the cluster on which Kubeapps is installed. The objects of
+	// each resource are cached with keys prefixed by the resource name, which must
+	// therefore be unique
+	Resources []ResourceConfig
+	// ResyncPeriod is how often the objects of each resource are listed again to reconcile
+	// the cache with them, defaulting to defaultResyncPeriod when zero
+	ResyncPeriod time.Duration
+}
+
+// Event notifies the subscribers of the cache of a change to the value cached for an object
+type Event struct {
+	Key string
+	// Value is the value now cached for the object, or nil when no value is cached
+	// for the object any more
+	Value Value
+}
+
+// subscriberBufferSize is the number of events buffered for each subscriber. A subscriber
+// which falls behind by more events is unsubscribed so as not to block the cache
+const subscriberBufferSize = 100
+
+// a type of cache that is based on watching for changes to specified kubernetes resources
+type ResourceWatcherCache struct {
+	// these expected to be provided by the caller when creating new cache
+	config Config
+	// the config of each watched resource, by resource name
+	resources map[string]*ResourceConfig
+	store     Store
+	// the channels of the subscribers notified of each change to the cache,
+	// guarded by subscribersMutex
+	subscribers      map[chan Event]struct{}
+	subscribersMutex sync.Mutex
+	// the objects processed by the hooks, by key, guarded by processedMutex
+	processed      map[string]processedObject
+	processedMutex sync.Mutex
+	// serializes the processing of each object, so that the values computed concurrently
+	// for the same object are not stored out of order
+	objectLocks keyLocks
+	// closed once the objects of every resource have been listed and processed
+	synced         chan struct{}
+	resyncPeriod   time.Duration
+	restartBackoff wait.Backoff
+}
+
+// processedObject records the version of an object last processed by the hooks, so that
+// the objects which did not change are not processed again by each resync
+type processedObject struct {
+	resourceVersion string
+	// whether a value was stored for this version of the object
+	stored bool
+}
+
+// NewCache returns a cache storing its values in the given store, which lists and then
+// watches each of the configured resources in the background
+func NewCache(config Config, store Store) (*ResourceWatcherCache, error) {
+	return newCache(config, store, defaultRestartBackoff)
+}
+
+func newCache(config Config, store Store, restartBackoff wait.Backoff) (*ResourceWatcherCache, error) {
+	log.Infof("+NewCache")
+
+	if store == nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "server not configured with cache store")
+	}
+
+	if config.ClientGetter == nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "server not configured with configGetter")
+	}
+
+	resources := make(map[string]*ResourceConfig, len(config.Resources))
+	for i, r := range config.Resources {
+		if r.OnAdd == nil || r.OnModify == nil || r.OnDelete == nil || r.NewValue == nil {
+			return nil, status.Errorf(codes.FailedPrecondition, "server not configured with expected cache hooks for [%s]", r.Gvr)
+		}
+		if _, ok := resources[r.Gvr.Resource]; ok {
+			return nil, status.Errorf(codes.FailedPrecondition, "server configured to cache more than one resource named [%s]", r.Gvr.Resource)
+		}
+		resources[r.Gvr.Resource] = &config.Resources[i]
+	}
+
+	// sanity check that the store is reachable
+	if err := store.Ping(context.Background()); err != nil {
+		return nil, err
+	}
+
+	resyncPeriod := config.ResyncPeriod
+	if resyncPeriod == 0 {
+		resyncPeriod = defaultResyncPeriod
+	}
+	c := ResourceWatcherCache{
+		config:         config,
+		resources:      resources,
+		store:          store,
+		processed:      make(map[string]processedObject),
+		objectLocks:    keyLocks{locks: make(map[string]*keyLock)},
+		synced:         make(chan struct{}),
+		resyncPeriod:   resyncPeriod,
+		restartBackoff: restartBackoff,
+	}
+	var unsynced sync.WaitGroup
+	unsynced.Add(len(resources))
+	for _, r := range resources {
+		go c.watchResource(r, unsynced.Done)
+	}
+	go func() {
+		unsynced.Wait()
+		close(c.synced)
+	}()
+	return &c, nil
+}
+
+// WaitForCacheSync waits until the objects existing on the cluster have been listed and
+// processed for every resource, which is retried in the background until it succeeds
+func (c *ResourceWatcherCache) WaitForCacheSync(ctx context.Context) error {
+	select {
+	case <-c.synced:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Ping checks that the store of the cache is reachable
+func (c *ResourceWatcherCache) Ping(ctx context.Context) error {
+	return c.store.Ping(ctx)
+}
+
+// Store returns the store of the cache
+func (c *ResourceWatcherCache) Store() Store {
+	return c.store
+}
+
+// this is effectively a cache PUT operation
+func (c *ResourceWatcherCache) onAddOrModify(resource *ResourceConfig, add bool, unstructuredObj map[string]interface{}) {
+	key, err := c.KeyFor(resource.Gvr, unstructuredObj)
+	if err != nil {
+		log.Errorf("Failed to get cache key due to: %v", err)
+		return
+	}
+	unlock := c.objectLocks.lock(key)
+	defer unlock()
+	c.addOrModifyKey(resource, add, key, unstructuredObj)
+}
+
+// addOrModifyKey computes and stores the value of the object with the given key, which
+// must be locked, and returns the value now cached for the object, if any
+func (c *ResourceWatcherCache) addOrModifyKey(resource *ResourceConfig, add bool, key string, unstructuredObj map[string]interface{}) (cachedValue Value) {
+	// clear that key so cache doesn't contain any stale info for this object if not ready or
+	// indexing or marshalling fails for whatever reason
+	ctx := context.Background()
+	if err := c.store.Delete(ctx, key); err != nil {
+		log.Errorf("Failed to delete value for object with key [%s] from cache due to: %v", key, err)
+	}
+	defer func() { c.notify(key, cachedValue) }()
+	// the object is only recorded as processed when successful, so that it is processed
+	// again by the next resync otherwise
+	c.forgetProcessed(key)
+	resourceVersion, _, _ := unstructured.NestedString(unstructuredObj, "metadata", "resourceVersion")
+
+	funcName, addOrModify := "OnModify", resource.OnModify
+	if add {
+		funcName, addOrModify = "OnAdd", resource.OnAdd
+	}
+	value, setVal, err := addOrModify(key, unstructuredObj)
+	if err != nil {
+		log.Errorf("Invokation of [%s] for object %s\nfailed due to: %v", funcName, prettyPrintMap(unstructuredObj), err)
+		return nil
+	}
+
+	if setVal {
+		bytes, err := value.MarshalBinary()
+		if err != nil {
+			log.Errorf("Failed to encode value for object with key [%s] due to: %v", key, err)
+			return nil
+		}
+		err = c.store.Set(ctx, key, bytes)
+		if err != nil {
+			log.Errorf("Failed to set value for object with key [%s] in cache due to: %v", key, err)
+			return nil
+		} else {
+			log.Infof("set value for object with key [%s] in cache", key)
+			cachedValue = value
+		}
+	}
+	c.recordProcessed(key, resourceVersion, setVal)
+	return cachedValue
+}
+
+// this is effectively a cache DEL operation
+func (c *ResourceWatcherCache) onDelete(resource *ResourceConfig, unstructuredObj map[string]interface{}) {
+	key, err := c.KeyFor(resource.Gvr, unstructuredObj)
+	if err != nil {
+		log.Errorf("Failed to get cache key due to: %v", err)
+		return
+	}
+	unlock := c.objectLocks.lock(key)
+	defer unlock()
+
+	delete, err := resource.OnDelete(key, unstructuredObj)
+	if err != nil {
+		log.Errorf("Invokation of 'OnDelete' for object %s\nfailed due to: %v", prettyPrintMap(unstructuredObj), err)
+		return
+	}
+
+	if delete {
+		c.deleteKey(key)
+	}
+}
+
+// deleteKey removes the value cached for the key, which must be locked, notifying the subscribers
+func (c *ResourceWatcherCache) deleteKey(key string) {
+	c.forgetProcessed(key)
+	err := c.store.Delete(context.Background(), key)
+	if err != nil {
+		log.Errorf("Failed to delete value for object [%s] from cache due to: %v", key, err)
+	} else {
+		c.notify(key, nil)
+	}
+}
+
+func (c *ResourceWatcherCache) recordProcessed(key, resourceVersion string, stored bool) {
+	c.processedMutex.Lock()
+	defer c.processedMutex.Unlock()
+	c.processed[key] = processedObject{resourceVersion: resourceVersion, stored: stored}
+}
+
+func (c *ResourceWatcherCache) forgetProcessed(key string) {
+	c.processedMutex.Lock()
+	defer c.processedMutex.Unlock()
+	delete(c.processed, key)
+}
+
+// wasStored returns whether a value was stored for the object with the given key when
+// it was last processed
+func (c *ResourceWatcherCache) wasStored(key string) bool {
+	c.processedMutex.Lock()
+	defer c.processedMutex.Unlock()
+	return c.processed[key].stored
+}
+
+// Subscribe returns a channel on which the changes to the cache are sent, along with the
+// func to unsubscribe. The channel is closed when unsubscribing, or when the subscriber
+// falls behind the changes to the cache
+func (c *ResourceWatcherCache) Subscribe() (<-chan Event, func()) {
+	ch := make(chan Event, subscriberBufferSize)
+	c.subscribersMutex.Lock()
+	defer c.subscribersMutex.Unlock()
+	if c.subscribers == nil {
+		c.subscribers = make(map[chan Event]struct{})
+	}
+	c.subscribers[ch] = struct{}{}
+
+	unsubscribe := func() {
+		c.subscribersMutex.Lock()
+		defer c.subscribersMutex.Unlock()
+		if _, ok := c.subscribers[ch]; ok {
+			delete(c.subscribers, ch)
+			close(ch)
+		}
+	}
+	return ch, unsubscribe
+}
+
+// notify sends the value now cached for the given key to the subscribers of the cache
+func (c *ResourceWatcherCache) notify(key string, value Value) {
+	c.subscribersMutex.Lock()
+	defer c.subscribersMutex.Unlock()
+
+	event := Event{Key: key, Value: value}
+	for ch := range c.subscribers {
+		select {
+		case ch <- event:
+		default:
+			log.Warningf("Unsubscribing from cache after falling behind")
+			delete(c.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// Fetch returns the value cached for the given key, or nil if there is none.
+// This is effectively a cache GET operation
+func (c *ResourceWatcherCache) Fetch(ctx context.Context, key string) (Value, error) {
+	resource, err := c.resourceFor(key)
+	if err != nil {
+		return nil, err
+	}
+
+	// read back from cache: should be what we previously wrote or nothing
+	bytes, found, err := c.store.Get(ctx, key)
+	if err != nil {
+		log.Errorf("Failed to get value for key [%s] from cache due to: %v", key, err)
+		return nil, err
+	} else if !found {
+		// this is normal if the key does not exist
+		cacheMissesTotal.WithLabelValues(resource.Gvr.Resource).Inc()
+		return c.recomputeEvicted(ctx, resource, key)
+	}
+
+	cacheHitsTotal.WithLabelValues(resource.Gvr.Resource).Inc()
+
+	value := resource.NewValue()
+	if err := value.UnmarshalBinary(bytes); err != nil {
+		log.Errorf("Failed to decode value for key [%s] due to: %v", key, err)
+		return nil, err
+	}
+	return value, nil
+}
+
+// recomputeEvicted computes again the value of an object which was stored in the cache
+// but is missing from the store, as a store such as the MemoryStore evicts values when
+// full. It returns nil if no value was stored for the object
+func (c *ResourceWatcherCache) recomputeEvicted(ctx context.Context, resource *ResourceConfig, key string) (Value, error) {
+	if !c.wasStored(key) {
+		return nil, nil
+	}
+	unlock := c.objectLocks.lock(key)
+	defer unlock()
+	// the object may have been processed again or deleted in the meantime
+	if !c.wasStored(key) {
+		return nil, nil
+	}
+	if bytes, found, err := c.store.Get(ctx, key); err != nil {
+		return nil, err
+	} else if found {
+		value := resource.NewValue()
+		if err := value.UnmarshalBinary(bytes); err != nil {
+			return nil, err
+		}
+		return value, nil
+	}
+
+	log.Warningf("Value for object with key [%s] was evicted from the cache, computing it again", key)
+	keyParts := strings.SplitN(key, ":", 3)
+	if len(keyParts) != 3 {
+		return nil, status.Errorf(codes.Internal, "invalid cache key [%s]", key)
+	}
+	// the object is read as when watching it, rather than with the credentials of the request
+	dynamicClient, err := c.dynamicClient(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	unstructuredObj, err := dynamicClient.Resource(resource.Gvr).Namespace(keyParts[1]).Get(ctx, keyParts[2], metav1.GetOptions{})
+	if k8serrors.IsNotFound(err) {
+		// the value is removed when the deletion of the object is watched
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return c.addOrModifyKey(resource, true, key, unstructuredObj.Object), nil
+}
+
+// keyLocks are mutexes by key, which only exist while they are locked or waited for
+type keyLocks struct {
+	locks map[string]*keyLock
+	mutex sync.Mutex
+}
+
+type keyLock struct {
+	sync.Mutex
+	// the number of callers which locked or are waiting for the lock
+	refs int
+}
+
+// lock locks the given key and returns the func to unlock it
+func (l *keyLocks) lock(key string) func() {
+	l.mutex.Lock()
+	lock, ok := l.locks[key]
+	if !ok {
+		lock = &keyLock{}
+		l.locks[key] = lock
+	}
+	lock.refs++
+	l.mutex.Unlock()
+
+	lock.Lock()
+	return func() {
+		lock.Unlock()
+		l.mutex.Lock()
+		defer l.mutex.Unlock()
+		lock.refs--
+		if lock.refs == 0 {
+			delete(l.locks, key)
+		}
+	}
+}
+
+const (
+	// max number of concurrent workers reading results for fetch() at the same time
+	maxWorkers = 10
+)
+
+type fetchValueJobResult struct {
+	key   string
+	value Value
+	err   error
+}
+
+// FetchForObjects returns the values cached for the given objects of the resource, by key.
+// Each object is read from the store in a separate go routine (lightweight thread of execution)
+// and the objects for which no value is cached are omitted
+func (c *ResourceWatcherCache) FetchForObjects(ctx context.Context, gvr schema.GroupVersionResource, items []unstructured.Unstructured) (map[string]Value, error) {
+	values := make(map[string]Value)
+	var wg sync.WaitGroup
+	numWorkers := int(math.Min(float64(len(items)), float64(maxWorkers)))
+	requestChan := make(chan string, numWorkers)
+	responseChan := make(chan fetchValueJobResult, numWorkers)
+
+	// Process only at most maxWorkers at a time
+	for i := 0; i < numWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			for key := range requestChan {
+				// The following loop will only terminate when the request channel is closed (and there are no more items)
+				value, err := c.Fetch(ctx, key)
+				responseChan <- fetchValueJobResult{key, value, err}
+			}
+			wg.Done()
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(responseChan)
+	}()
+
+	go func() {
+		for _, item := range items {
+			key, err := c.KeyFor(gvr, item.Object)
+			if err != nil {
+				log.Errorf("Failed to get cache key due to: %v", err)
+			} else {
+				requestChan <- key
+			}
+		}
+		close(requestChan)
+	}()
+
+	// Start receiving results
+	// The following loop will only terminate when the response channel is closed, i.e.
+	// after the all the requests have been processed
+	for resp := range responseChan {
+		if resp.err == nil {
+			// resp.value may be nil when there is a cache miss
+			if resp.value != nil {
+				values[resp.key] = resp.value
+			}
+		} else {
+			log.Errorf("%v", resp.err)
+		}
+	}
+	return values, nil
+}
+
+// KeyFor returns the key of the value cached for an object of the given resource.
+// TODO (gfichtenholt) give the plug-ins the ability to override this (default) implementation
+// for generating a cache key given an object
+func (c *ResourceWatcherCache) KeyFor(gvr schema.GroupVersionResource, unstructuredObj map[string]interface{}) (string, error) {
+	name, found, err := unstructured.NestedString(unstructuredObj, "metadata", "name")
+	if err != nil || !found {
+		return "", status.Errorf(codes.Internal, "required field metadata.name not found on: %v:\n%s",
+			err,
+			prettyPrintMap(unstructuredObj))
+	}
+
+	namespace, found, err := unstructured.NestedString(unstructuredObj, "metadata", "namespace")
+	if err != nil || !found {
+		return "", status.Errorf(codes.Internal, "required field metadata.namespace not found on: %v:\n%s",
+			err,
+			prettyPrintMap(unstructuredObj))
+	}
+
+	// redis convention on key format
+	// https://redis.io/topics/data-types-intro
+	// Try to stick with a schema. For instance "object-type:id" is a good idea, as in "user:1000".
+	// We will use "helmrepositories:ns:repoName"
+	return fmt.Sprintf("%s:%s:%s", gvr.Resource, namespace, name), nil
+}
+
+// resourceFor returns the config of the resource of the object with the given key
+func (c *ResourceWatcherCache) resourceFor(key string) (*ResourceConfig, error) {
+	resourceName := strings.SplitN(key, ":", 2)[0]
+	resource, ok := c.resources[resourceName]
+	if !ok {
+		return nil, status.Errorf(codes.InvalidArgument, "no resource is cached for key [%s]", key)
+	}
+	return resource, nil
+}
+
+func prettyPrintObject(o runtime.Object) string {
+	prettyBytes, err := json.MarshalIndent(o, "", "  ")
+	if err != nil {
+		return fmt.Sprintf("%v", o)
+	}
+	return string(prettyBytes)
+}
+
+func prettyPrintMap(m map[string]interface{}) string {
+	prettyBytes, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Sprintf("%v", m)
+	}
+	return string(prettyBytes)
+}
@@ -0,0 +1,210 @@
+/*
+Copyright © 2021 VMware
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cache
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	redismock "github.com/go-redis/redismock/v8"
+	"github.com/kubeapps/kubeapps/cmd/kubeapps-apis/server"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+)
+
+var (
+	fooGvr = schema.GroupVersionResource{Group: "test.kubeapps.com", Version: "v1", Resource: "foos"}
+	barGvr = schema.GroupVersionResource{Group: "test.kubeapps.com", Version: "v1", Resource: "bars"}
+)
+
+// testValue is the value cached for the test resources: the spec.value of the object.
+type testValue string
+
+func (v testValue) MarshalBinary() ([]byte, error) {
+	return []byte(v), nil
+}
+
+func (v *testValue) UnmarshalBinary(data []byte) error {
+	*v = testValue(data)
+	return nil
+}
+
+func onAddOrModifyTestObject(key string, obj map[string]interface{}) (Value, bool, error) {
+	value, found, err := unstructured.NestedString(obj, "spec", "value")
+	if err != nil || !found {
+		return nil, false, err
+	}
+	v := testValue(value)
+	return &v, true, nil
+}
+
+func onDeleteTestObject(key string, obj map[string]interface{}) (bool, error) {
+	return true, nil
+}
+
+func testResourceConfig(gvr schema.GroupVersionResource) ResourceConfig {
+	return ResourceConfig{
+		Gvr:      gvr,
+		OnAdd:    onAddOrModifyTestObject,
+		OnModify: onAddOrModifyTestObject,
+		OnDelete: onDeleteTestObject,
+		NewValue: func() Value { return new(testValue) },
+	}
+}
+
+func newTestObject(gvr schema.GroupVersionResource, namespace, name, value string) *unstructured.Unstructured {
+	return &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": gvr.GroupVersion().String(),
+			"kind":       strings.Title(strings.TrimSuffix(gvr.Resource, "s")),
+			"metadata": map[string]interface{}{
+				"name":      name,
+				"namespace": namespace,
+			},
+			"spec": map[string]interface{}{
+				"value": value,
+			},
+		},
+	}
+}
+
+func waitForEvent(t *testing.T, events <-chan Event) Event {
+	select {
+	case event := <-events:
+		return event
+	case <-time.After(10 * time.Second):
+		t.Fatalf("timed out waiting for a cache event")
+	}
+	return Event{}
+}
+
+func TestNewCacheErrors(t *testing.T) {
+	clientGetter := func(context.Context, string) (kubernetes.Interface, dynamic.Interface, error) {
+		return nil, nil, nil
+	}
+	store, err := NewMemoryStore(10)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	withoutHooks := testResourceConfig(fooGvr)
+	withoutHooks.NewValue = nil
+
+	testCases := []struct {
+		name         string
+		clientGetter server.KubernetesClientGetter
+		store        Store
+		resources    []ResourceConfig
+	}{
+		{
+			name:         "it requires a store",
+			clientGetter: clientGetter,
+			resources:    []ResourceConfig{testResourceConfig(fooGvr)},
+		},
+		{
+			name:      "it requires a client getter",
+			store:     store,
+			resources: []ResourceConfig{testResourceConfig(fooGvr)},
+		},
+		{
+			name:         "it requires all the hooks of a resource",
+			clientGetter: clientGetter,
+			store:        store,
+			resources:    []ResourceConfig{withoutHooks},
+		},
+		{
+			name:         "it requires unique resource names",
+			clientGetter: clientGetter,
+			store:        store,
+			resources: []ResourceConfig{
+				testResourceConfig(fooGvr),
+				testResourceConfig(schema.GroupVersionResource{Group: "other.kubeapps.com", Version: "v1", Resource: "foos"}),
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := NewCache(Config{ClientGetter: tc.clientGetter, Resources: tc.resources}, tc.store)
+			if got, want := status.Code(err), codes.FailedPrecondition; got != want {
+				t.Errorf("got: %v, want: %v", got, want)
+			}
+		})
+	}
+}
+
+func TestFetchCountsCacheHitsAndMisses(t *testing.T) {
+	redisCli, mock := redismock.NewClientMock()
+	resource := testResourceConfig(schema.GroupVersionResource{Resource: "testresources"})
+	c := &ResourceWatcherCache{
+		resources: map[string]*ResourceConfig{"testresources": &resource},
+		store:     NewRedisStore(redisCli),
+	}
+	hits := cacheHitsTotal.WithLabelValues("testresources")
+	misses := cacheMissesTotal.WithLabelValues("testresources")
+	hitsBefore, missesBefore := testutil.ToFloat64(hits), testutil.ToFloat64(misses)
+
+	mock.ExpectGet("testresources:ns:found").SetVal("value")
+	mock.ExpectGet("testresources:ns:missing").RedisNil()
+	for _, key := range []string{"testresources:ns:found", "testresources:ns:missing"} {
+		if _, err := c.Fetch(context.Background(), key); err != nil {
+			t.Fatalf("%+v", err)
+		}
+	}
+
+	if got, want := testutil.ToFloat64(hits)-hitsBefore, 1.0; got != want {
+		t.Errorf("got: %v hits, want: %v", got, want)
+	}
+	if got, want := testutil.ToFloat64(misses)-missesBefore, 1.0; got != want {
+		t.Errorf("got: %v misses, want: %v", got, want)
+	}
+	if _, err := c.Fetch(context.Background(), "others:ns:name"); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("got: %v, want: InvalidArgument error for a key of a resource not cached", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("%v", err)
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	c := &ResourceWatcherCache{}
+	events, unsubscribe := c.Subscribe()
+	slow, _ := c.Subscribe()
+
+	// the slow subscriber does not receive any of the events
+	for i := 0; i <= subscriberBufferSize; i++ {
+		c.notify("foos:ns:name", nil)
+		if got, want := (<-events).Key, "foos:ns:name"; got != want {
+			t.Fatalf("got: %q, want: %q", got, want)
+		}
+	}
+
+	unsubscribe()
+	if _, ok := <-events; ok {
+		t.Errorf("got: open channel, want: closed channel after unsubscribing")
+	}
+	for i := 0; i < subscriberBufferSize; i++ {
+		if _, ok := <-slow; !ok {
+			t.Fatalf("got: closed channel after %d events, want: %d buffered events", i, subscriberBufferSize)
+		}
+	}
+	if _, ok := <-slow; ok {
+		t.Errorf("got: open channel, want: closed channel of subscriber falling behind")
+	}
+}
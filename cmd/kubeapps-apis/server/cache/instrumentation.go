@@ -0,0 +1,84 @@
+/*
+Copyright © 2021 VMware
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cache
+
+import (
+	"context"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/kubeapps/kubeapps/cmd/kubeapps-apis/server"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/semconv"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/kubeapps/kubeapps/cmd/kubeapps-apis/server/cache"
+
+var (
+	cacheHitsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: server.MetricsNamespace,
+		Name:      "resource_watcher_cache_hits_total",
+		Help:      "Number of ResourceWatcherCache lookups for which a value was found, by resource.",
+	}, []string{"resource"})
+	cacheMissesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: server.MetricsNamespace,
+		Name:      "resource_watcher_cache_misses_total",
+		Help:      "Number of ResourceWatcherCache lookups for which no value was found, by resource.",
+	}, []string{"resource"})
+	storeEvictionsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: server.MetricsNamespace,
+		Name:      "resource_watcher_cache_evictions_total",
+		Help:      "Number of values evicted from the in-memory storage of the ResourceWatcherCache when full.",
+	})
+)
+
+// redisTracingHook creates a span for each redis command, so that the time
+// spent in redis is visible in the trace of the request.
+type redisTracingHook struct{}
+
+var _ redis.Hook = redisTracingHook{}
+
+func (redisTracingHook) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	ctx, _ = otel.Tracer(tracerName).Start(ctx, "redis "+cmd.Name(),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.DBSystemRedis))
+	return ctx, nil
+}
+
+func (redisTracingHook) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	span := trace.SpanFromContext(ctx)
+	// a missing key is not an error for the cache
+	if err := cmd.Err(); err != nil && err != redis.Nil {
+		span.SetStatus(otelcodes.Error, err.Error())
+	}
+	span.End()
+	return nil
+}
+
+func (redisTracingHook) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	ctx, _ = otel.Tracer(tracerName).Start(ctx, "redis pipeline",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.DBSystemRedis, attribute.Int("db.redis.num_cmd", len(cmds))))
+	return ctx, nil
+}
+
+func (redisTracingHook) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	trace.SpanFromContext(ctx).End()
+	return nil
+}
@@ -0,0 +1,196 @@
+/*
+Copyright © 2021 VMware
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cache
+
+import (
+	"container/list"
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/go-redis/redis/v8"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Store is the storage of the encoded values of a ResourceWatcherCache
+type Store interface {
+	// Get returns the value stored for the key and whether there is one
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	// Set stores the value for the key, without expiration
+	Set(ctx context.Context, key string, value []byte) error
+	// Delete removes the value stored for the key, if any
+	Delete(ctx context.Context, key string) error
+	// Keys returns the keys of the values stored with the given prefix
+	Keys(ctx context.Context, prefix string) ([]string, error)
+	// Ping checks that the storage is reachable
+	Ping(ctx context.Context) error
+}
+
+// RedisStore stores the values of the cache in redis, so that they are shared by the
+// replicas of kubeapps-apis and survive restarts
+type RedisStore struct {
+	redisCli *redis.Client
+}
+
+var _ Store = &RedisStore{}
+
+// NewRedisStore returns a store using the given redis client
+func NewRedisStore(redisCli *redis.Client) *RedisStore {
+	return &RedisStore{redisCli: redisCli}
+}
+
+// NewRedisClient returns a redis client for the cache, which records the redis commands
+// in the traces of the requests
+func NewRedisClient(options *redis.Options) *redis.Client {
+	redisCli := redis.NewClient(options)
+	redisCli.AddHook(redisTracingHook{})
+	return redisCli
+}
+
+func (s *RedisStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	bytes, err := s.redisCli.Get(ctx, key).Bytes()
+	if err == redis.Nil {
+		// this is normal if the key does not exist
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, err
+	}
+	return bytes, true, nil
+}
+
+func (s *RedisStore) Set(ctx context.Context, key string, value []byte) error {
+	// Zero expiration means the key has no expiration time.
+	return s.redisCli.Set(ctx, key, value, 0).Err()
+}
+
+func (s *RedisStore) Delete(ctx context.Context, key string) error {
+	return s.redisCli.Del(ctx, key).Err()
+}
+
+// redisScanCount is the number of keys hinted to redis for each SCAN of the keys
+const redisScanCount = 100
+
+// Keys scans the keys incrementally rather than with KEYS, which would block redis
+func (s *RedisStore) Keys(ctx context.Context, prefix string) ([]string, error) {
+	keys := []string{}
+	var cursor uint64
+	for {
+		page, nextCursor, err := s.redisCli.Scan(ctx, cursor, prefix+"*", redisScanCount).Result()
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, page...)
+		if nextCursor == 0 {
+			return keys, nil
+		}
+		cursor = nextCursor
+	}
+}
+
+func (s *RedisStore) Ping(ctx context.Context) error {
+	return s.redisCli.Ping(ctx).Err()
+}
+
+// MemoryStore stores the values of the cache in the memory of the process, evicting the
+// least recently used value when full, which the cache computes again when fetched. It
+// does not require any other service, which is suitable for small installations and
+// tests, but each replica then has its own cache which is lost on restart
+type MemoryStore struct {
+	maxEntries int
+	// the entries from the most to the least recently used, guarded by mutex
+	entries  *list.List
+	elements map[string]*list.Element
+	mutex    sync.Mutex
+}
+
+var _ Store = &MemoryStore{}
+
+type memoryStoreEntry struct {
+	key   string
+	value []byte
+}
+
+// NewMemoryStore returns a store keeping at most maxEntries values in memory
+func NewMemoryStore(maxEntries int) (*MemoryStore, error) {
+	if maxEntries <= 0 {
+		return nil, status.Errorf(codes.FailedPrecondition, "invalid maximum number of entries %d, expected a positive number", maxEntries)
+	}
+	return &MemoryStore{
+		maxEntries: maxEntries,
+		entries:    list.New(),
+		elements:   make(map[string]*list.Element),
+	}, nil
+}
+
+func (s *MemoryStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	element, ok := s.elements[key]
+	if !ok {
+		return nil, false, nil
+	}
+	s.entries.MoveToFront(element)
+	return copyBytes(element.Value.(*memoryStoreEntry).value), true, nil
+}
+
+func (s *MemoryStore) Set(ctx context.Context, key string, value []byte) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if element, ok := s.elements[key]; ok {
+		element.Value.(*memoryStoreEntry).value = copyBytes(value)
+		s.entries.MoveToFront(element)
+		return nil
+	}
+	s.elements[key] = s.entries.PushFront(&memoryStoreEntry{key: key, value: copyBytes(value)})
+	for s.entries.Len() > s.maxEntries {
+		oldest := s.entries.Back()
+		s.entries.Remove(oldest)
+		delete(s.elements, oldest.Value.(*memoryStoreEntry).key)
+		storeEvictionsTotal.Inc()
+	}
+	return nil
+}
+
+func (s *MemoryStore) Delete(ctx context.Context, key string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if element, ok := s.elements[key]; ok {
+		s.entries.Remove(element)
+		delete(s.elements, key)
+	}
+	return nil
+}
+
+func (s *MemoryStore) Keys(ctx context.Context, prefix string) ([]string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	keys := []string{}
+	for key := range s.elements {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+// Ping always succeeds, as the values are in the memory of the process
+func (s *MemoryStore) Ping(ctx context.Context) error {
+	return nil
+}
+
+// copyBytes copies the values stored in memory, so that they are not modified
+// by the callers
+func copyBytes(value []byte) []byte {
+	return append([]byte(nil), value...)
+}
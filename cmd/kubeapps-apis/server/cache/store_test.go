@@ -0,0 +1,169 @@
+/*
+Copyright © 2021 VMware
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cache
+
+import (
+	"context"
+	"testing"
+
+	redismock "github.com/go-redis/redismock/v8"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestMemoryStore(t *testing.T) {
+	testCases := []struct {
+		name       string
+		maxEntries int
+		operations func(ctx context.Context, s *MemoryStore) error
+		expected   map[string]string
+	}{
+		{
+			name:       "it stores the values",
+			maxEntries: 2,
+			operations: func(ctx context.Context, s *MemoryStore) error {
+				if err := s.Set(ctx, "a", []byte("1")); err != nil {
+					return err
+				}
+				return s.Set(ctx, "b", []byte("2"))
+			},
+			expected: map[string]string{"a": "1", "b": "2"},
+		},
+		{
+			name:       "it replaces the value of a key",
+			maxEntries: 2,
+			operations: func(ctx context.Context, s *MemoryStore) error {
+				if err := s.Set(ctx, "a", []byte("1")); err != nil {
+					return err
+				}
+				return s.Set(ctx, "a", []byte("2"))
+			},
+			expected: map[string]string{"a": "2"},
+		},
+		{
+			name:       "it evicts the least recently set value when full",
+			maxEntries: 2,
+			operations: func(ctx context.Context, s *MemoryStore) error {
+				for _, key := range []string{"a", "b", "c"} {
+					if err := s.Set(ctx, key, []byte(key)); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+			expected: map[string]string{"b": "b", "c": "c"},
+		},
+		{
+			name:       "it evicts the least recently read value when full",
+			maxEntries: 2,
+			operations: func(ctx context.Context, s *MemoryStore) error {
+				for _, key := range []string{"a", "b"} {
+					if err := s.Set(ctx, key, []byte(key)); err != nil {
+						return err
+					}
+				}
+				if _, _, err := s.Get(ctx, "a"); err != nil {
+					return err
+				}
+				return s.Set(ctx, "c", []byte("c"))
+			},
+			expected: map[string]string{"a": "a", "c": "c"},
+		},
+		{
+			name:       "it deletes the values",
+			maxEntries: 2,
+			operations: func(ctx context.Context, s *MemoryStore) error {
+				if err := s.Set(ctx, "a", []byte("1")); err != nil {
+					return err
+				}
+				if err := s.Delete(ctx, "a"); err != nil {
+					return err
+				}
+				return s.Delete(ctx, "missing")
+			},
+			expected: map[string]string{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			s, err := NewMemoryStore(tc.maxEntries)
+			if err != nil {
+				t.Fatalf("%+v", err)
+			}
+			if err := tc.operations(ctx, s); err != nil {
+				t.Fatalf("%+v", err)
+			}
+
+			got := map[string]string{}
+			for _, key := range []string{"a", "b", "c"} {
+				value, found, err := s.Get(ctx, key)
+				if err != nil {
+					t.Fatalf("%+v", err)
+				}
+				if found {
+					got[key] = string(value)
+				}
+			}
+			if want := tc.expected; !cmp.Equal(want, got) {
+				t.Errorf("mismatch (-want +got):\n%s", cmp.Diff(want, got))
+			}
+		})
+	}
+}
+
+func TestNewMemoryStoreRequiresPositiveMaxEntries(t *testing.T) {
+	if _, err := NewMemoryStore(0); err == nil {
+		t.Errorf("got: nil, want: error")
+	}
+}
+
+func TestRedisStore(t *testing.T) {
+	ctx := context.Background()
+	redisCli, mock := redismock.NewClientMock()
+	s := NewRedisStore(redisCli)
+
+	mock.ExpectSet("a", []byte("1"), 0).SetVal("OK")
+	mock.ExpectGet("a").SetVal("1")
+	mock.ExpectDel("a").SetVal(1)
+	mock.ExpectGet("a").RedisNil()
+	mock.ExpectScan(0, "foos:*", redisScanCount).SetVal([]string{"foos:ns:a"}, 7)
+	mock.ExpectScan(7, "foos:*", redisScanCount).SetVal([]string{"foos:ns:b"}, 0)
+
+	if err := s.Set(ctx, "a", []byte("1")); err != nil {
+		t.Fatalf("%+v", err)
+	}
+	value, found, err := s.Get(ctx, "a")
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if got, want := string(value), "1"; !found || got != want {
+		t.Errorf("got: %q (found: %t), want: %q", got, found, want)
+	}
+	if err := s.Delete(ctx, "a"); err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if _, found, err := s.Get(ctx, "a"); err != nil || found {
+		t.Errorf("got: found: %t, error: %v, want: not found", found, err)
+	}
+	keys, err := s.Keys(ctx, "foos:")
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if got, want := keys, []string{"foos:ns:a", "foos:ns:b"}; !cmp.Equal(want, got) {
+		t.Errorf("mismatch (-want +got):\n%s", cmp.Diff(want, got))
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("%v", err)
+	}
+}
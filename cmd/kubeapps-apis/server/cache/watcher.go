@@ -0,0 +1,250 @@
+/*
+Copyright © 2021 VMware
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+	log "k8s.io/klog/v2"
+)
+
+const (
+	// defaultResyncPeriod is how often the objects of each resource are listed again
+	// when the config of the cache does not say otherwise
+	defaultResyncPeriod = 10 * time.Minute
+)
+
+// defaultRestartBackoff is the delay before listing or watching a resource again after
+// a failure, doubling with each consecutive failure up to a minute
+var defaultRestartBackoff = wait.Backoff{
+	Duration: time.Second,
+	Factor:   2,
+	Jitter:   0.1,
+	Steps:    math.MaxInt32,
+	Cap:      time.Minute,
+}
+
+var (
+	// errResourceExpired is returned when the resource version from which a resource is
+	// watched is too old, so that the resource needs to be listed again
+	errResourceExpired = errors.New("resource version expired")
+	// errResyncDue is returned when it is time to list the resource again
+	errResyncDue = errors.New("resync due")
+)
+
+// watchResource keeps the cache up to date with the objects of the resource, in an
+// informer-like infinite loop: the objects are listed and reconciled with the cache,
+// then watched from the resource version of the list until the watch fails or it is
+// time to resync. A watch which ends is restarted from the last resource version seen,
+// with an exponential backoff, and the objects are listed again when that version has
+// expired. The given func is called once the objects have been listed the first time
+func (c *ResourceWatcherCache) watchResource(resource *ResourceConfig, onSynced func()) {
+	log.Infof("+ResourceWatcherCache watchResource [%s]", resource.Gvr)
+	var syncedOnce sync.Once
+	backoff := c.restartBackoff
+
+	for {
+		resourceVersion, err := c.resync(resource)
+		if err != nil {
+			log.Errorf("Failed to list [%s] due to: %v", resource.Gvr, err)
+			time.Sleep(backoff.Step())
+			continue
+		}
+		syncedOnce.Do(onSynced)
+		backoff = c.restartBackoff
+
+		resyncTimer := time.NewTimer(c.resyncPeriod)
+		for {
+			err = c.watchFrom(resource, &resourceVersion, resyncTimer.C, func() { backoff = c.restartBackoff })
+			if err == errResyncDue || err == errResourceExpired {
+				break
+			}
+			log.Warningf("Watch of [%s] ended, restarting it from resource version [%s]: %v", resource.Gvr, resourceVersion, err)
+			time.Sleep(backoff.Step())
+		}
+		resyncTimer.Stop()
+		log.Infof("Listing [%s] again: %v", resource.Gvr, err)
+	}
+}
+
+// resync lists the objects of the resource and reconciles the cache with them: the objects
+// which changed since they were last processed, or whose value is missing from the store,
+// are processed again and the values of the objects which no longer exist are removed.
+// It returns the resource version of the list, from which to watch the resource
+func (c *ResourceWatcherCache) resync(resource *ResourceConfig) (string, error) {
+	ctx := context.Background()
+	dynamicClient, err := c.dynamicClient(ctx)
+	if err != nil {
+		return "", err
+	}
+	// the objects are listed in all namespaces
+	list, err := dynamicClient.Resource(resource.Gvr).Namespace("").List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return "", err
+	}
+	storedKeys, err := c.store.Keys(ctx, resource.Gvr.Resource+":")
+	if err != nil {
+		return "", fmt.Errorf("unable to get the keys stored in the cache: %w", err)
+	}
+	stored := make(map[string]bool, len(storedKeys))
+	for _, key := range storedKeys {
+		stored[key] = true
+	}
+
+	c.processedMutex.Lock()
+	processed := make(map[string]processedObject, len(c.processed))
+	for key, object := range c.processed {
+		processed[key] = object
+	}
+	c.processedMutex.Unlock()
+
+	existing := make(map[string]bool, len(list.Items))
+	var wg sync.WaitGroup
+	workers := make(chan struct{}, maxWorkers)
+	for i := range list.Items {
+		obj := list.Items[i].Object
+		key, err := c.KeyFor(resource.Gvr, obj)
+		if err != nil {
+			log.Errorf("Failed to get cache key due to: %v", err)
+			continue
+		}
+		existing[key] = true
+
+		object, ok := processed[key]
+		if ok && object.resourceVersion == list.Items[i].GetResourceVersion() && (stored[key] || !object.stored) {
+			continue
+		}
+		// the objects are processed concurrently, each at most once, and the resync only
+		// completes once they all are, before the resource is watched again
+		wg.Add(1)
+		workers <- struct{}{}
+		go func() {
+			defer wg.Done()
+			c.onAddOrModify(resource, !ok, obj)
+			<-workers
+		}()
+	}
+	wg.Wait()
+
+	// the objects deleted while not watching
+	for _, key := range storedKeys {
+		if !existing[key] {
+			log.Infof("Removing value for deleted object with key [%s] from cache", key)
+			unlock := c.objectLocks.lock(key)
+			c.deleteKey(key)
+			unlock()
+		}
+	}
+	for key := range processed {
+		if strings.HasPrefix(key, resource.Gvr.Resource+":") && !existing[key] && !stored[key] {
+			c.forgetProcessed(key)
+		}
+	}
+	return list.GetResourceVersion(), nil
+}
+
+// watchFrom watches the resource from the given resource version, which is updated with
+// each event received, until the watch ends, the resource version expires or it is time
+// to resync. The given func is called when the watch has been started successfully
+func (c *ResourceWatcherCache) watchFrom(resource *ResourceConfig, resourceVersion *string, resyncDue <-chan time.Time, onStarted func()) error {
+	ctx := context.Background()
+	dynamicClient, err := c.dynamicClient(ctx)
+	if err != nil {
+		return err
+	}
+	watcher, err := dynamicClient.Resource(resource.Gvr).Namespace("").Watch(ctx, metav1.ListOptions{
+		ResourceVersion:     *resourceVersion,
+		AllowWatchBookmarks: true,
+	})
+	if err != nil {
+		if k8serrors.IsResourceExpired(err) || k8serrors.IsGone(err) {
+			return errResourceExpired
+		}
+		return err
+	}
+	defer watcher.Stop()
+	onStarted()
+	log.Infof("watcher for [%s] successfully started from resource version [%s]. waiting for events...", resource.Gvr, *resourceVersion)
+
+	for {
+		select {
+		case <-resyncDue:
+			return errResyncDue
+		case event, ok := <-watcher.ResultChan():
+			if !ok {
+				return fmt.Errorf("watch channel closed")
+			}
+			if event.Type == watch.Error {
+				err := k8serrors.FromObject(event.Object)
+				if k8serrors.IsResourceExpired(err) || k8serrors.IsGone(err) {
+					return errResourceExpired
+				}
+				return err
+			}
+			unstructuredObj, ok := event.Object.(*unstructured.Unstructured)
+			if !ok {
+				log.Errorf("Could not cast to unstructured.Unstructured")
+				continue
+			}
+			*resourceVersion = unstructuredObj.GetResourceVersion()
+			c.processEvent(resource, event.Type, unstructuredObj)
+		}
+	}
+}
+
+// processEvent processes an event of the watch of the resource. The events are processed
+// one at a time, in the order in which they are received, so that the value cached for an
+// object is the one of its latest event. The resyncs, which process the objects
+// concurrently, are never run at the same time as the watch of the same resource
+func (c *ResourceWatcherCache) processEvent(resource *ResourceConfig, eventType watch.EventType, unstructuredObj *unstructured.Unstructured) {
+	switch eventType {
+	case watch.Added:
+		log.Infof("got event: type: [%v] object:\n[%s]", eventType, prettyPrintObject(unstructuredObj))
+		c.onAddOrModify(resource, true, unstructuredObj.Object)
+	case watch.Modified:
+		log.Infof("got event: type: [%v] object:\n[%s]", eventType, prettyPrintObject(unstructuredObj))
+		c.onAddOrModify(resource, false, unstructuredObj.Object)
+	case watch.Deleted:
+		log.Infof("got event: type: [%v] object:\n[%s]", eventType, prettyPrintObject(unstructuredObj))
+		c.onDelete(resource, unstructuredObj.Object)
+	case watch.Bookmark:
+		// only the resource version of a bookmark is relevant, from which to restart the watch
+	default:
+		log.Errorf("got unexpected event: type: [%v] for [%s]", eventType, resource.Gvr)
+	}
+}
+
+// dynamicClient returns the client of the cluster on which Kubeapps is installed,
+// on which the resources are watched
+func (c *ResourceWatcherCache) dynamicClient(ctx context.Context) (dynamic.Interface, error) {
+	_, dynamicClient, err := c.config.ClientGetter(ctx, "")
+	if err != nil {
+		return nil, fmt.Errorf("unable to get client due to: %v", err)
+	}
+	if dynamicClient == nil {
+		return nil, fmt.Errorf("unable to get dynamic client")
+	}
+	return dynamicClient, nil
+}
@@ -0,0 +1,365 @@
+/*
+Copyright © 2021 VMware
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// testRestartBackoff restarts the watches quickly in the tests
+var testRestartBackoff = wait.Backoff{Duration: 10 * time.Millisecond, Factor: 2, Steps: 5}
+
+// testWatch is a watch started by the cache, with the resource version it was started from
+type testWatch struct {
+	watcher         *watch.FakeWatcher
+	resourceVersion string
+}
+
+// testCluster is a fake cluster on which each watch started by the cache is sent to the
+// channel of the watched resource, so that the tests can send events to it. The objects
+// changed directly with the client are only seen by the cache when listing them.
+type testCluster struct {
+	client  *fake.FakeDynamicClient
+	watches map[string]chan testWatch
+}
+
+func newTestCluster(objects ...runtime.Object) *testCluster {
+	cluster := &testCluster{
+		client: fake.NewSimpleDynamicClientWithCustomListKinds(
+			runtime.NewScheme(),
+			map[schema.GroupVersionResource]string{
+				fooGvr: "FooList",
+				barGvr: "BarList",
+			},
+			objects...),
+		watches: map[string]chan testWatch{
+			fooGvr.Resource: make(chan testWatch, 10),
+			barGvr.Resource: make(chan testWatch, 10),
+		},
+	}
+	cluster.client.PrependWatchReactor("*", func(action k8stesting.Action) (bool, watch.Interface, error) {
+		watcher := watch.NewFakeWithChanSize(10, false)
+		select {
+		case cluster.watches[action.GetResource().Resource] <- testWatch{
+			watcher:         watcher,
+			resourceVersion: action.(k8stesting.WatchActionImpl).WatchRestrictions.ResourceVersion,
+		}:
+		default:
+			// the test is not waiting for more watches
+		}
+		return true, watcher, nil
+	})
+	return cluster
+}
+
+func (cluster *testCluster) clientGetter(context.Context, string) (kubernetes.Interface, dynamic.Interface, error) {
+	return nil, cluster.client, nil
+}
+
+func (cluster *testCluster) waitForWatch(t *testing.T, gvr schema.GroupVersionResource) testWatch {
+	select {
+	case w := <-cluster.watches[gvr.Resource]:
+		return w
+	case <-time.After(10 * time.Second):
+		t.Fatalf("timed out waiting for a watch of [%s]", gvr)
+	}
+	return testWatch{}
+}
+
+// newSyncedTestCache returns a cache of the foos and bars of the cluster, once synced
+func newSyncedTestCache(t *testing.T, cluster *testCluster, store Store, resyncPeriod time.Duration) *ResourceWatcherCache {
+	c, err := newCache(Config{
+		ClientGetter: cluster.clientGetter,
+		Resources:    []ResourceConfig{testResourceConfig(fooGvr), testResourceConfig(barGvr)},
+		ResyncPeriod: resyncPeriod,
+	}, store, testRestartBackoff)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := c.WaitForCacheSync(ctx); err != nil {
+		t.Fatalf("%+v", err)
+	}
+	return c
+}
+
+func newTestMemoryStore(t *testing.T) *MemoryStore {
+	store, err := NewMemoryStore(10)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	return store
+}
+
+func expectCachedValue(t *testing.T, c *ResourceWatcherCache, key string, expected string) {
+	value, err := c.Fetch(context.Background(), key)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if expected == "" {
+		if value != nil {
+			t.Errorf("got: %v, want: no value for [%s]", value, key)
+		}
+		return
+	}
+	if value == nil {
+		t.Fatalf("got: no value, want: %q for [%s]", expected, key)
+	}
+	if got, want := *value.(*testValue), testValue(expected); got != want {
+		t.Errorf("got: %q, want: %q", got, want)
+	}
+}
+
+func expectEvent(t *testing.T, events <-chan Event, key string, expected string) {
+	event := waitForEvent(t, events)
+	if got, want := event.Key, key; got != want {
+		t.Errorf("got: %q, want: %q", got, want)
+	}
+	if expected == "" {
+		if event.Value != nil {
+			t.Errorf("got: %v, want: nil value", event.Value)
+		}
+	} else if event.Value == nil {
+		t.Errorf("got: nil value, want: %q", expected)
+	} else if got, want := *event.Value.(*testValue), testValue(expected); got != want {
+		t.Errorf("got: %q, want: %q", got, want)
+	}
+}
+
+func TestResourceWatcherCacheSyncsWithExistingObjects(t *testing.T) {
+	store := newTestMemoryStore(t)
+	// left over for an object deleted while the cache was not running
+	if err := store.Set(context.Background(), "foos:ns1:deleted", []byte("stale")); err != nil {
+		t.Fatalf("%+v", err)
+	}
+	cluster := newTestCluster(
+		newTestObject(fooGvr, "ns1", "test", "foo-value"),
+		newTestObject(barGvr, "ns2", "test", "bar-value"),
+	)
+
+	c := newSyncedTestCache(t, cluster, store, time.Hour)
+
+	expectCachedValue(t, c, "foos:ns1:test", "foo-value")
+	expectCachedValue(t, c, "bars:ns2:test", "bar-value")
+	expectCachedValue(t, c, "foos:ns1:deleted", "")
+}
+
+func TestResourceWatcherCacheWatchesEachResource(t *testing.T) {
+	cluster := newTestCluster()
+	c := newSyncedTestCache(t, cluster, newTestMemoryStore(t), time.Hour)
+	events, unsubscribe := c.Subscribe()
+	defer unsubscribe()
+
+	testCases := []struct {
+		name     string
+		gvr      schema.GroupVersionResource
+		value    string
+		expected string
+	}{
+		{
+			name:     "it caches the values of the objects of the first resource",
+			gvr:      fooGvr,
+			value:    "foo-value",
+			expected: "foos:ns1:test",
+		},
+		{
+			name:     "it caches the values of the objects of the second resource",
+			gvr:      barGvr,
+			value:    "bar-value",
+			expected: "bars:ns1:test",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := cluster.waitForWatch(t, tc.gvr)
+			obj := newTestObject(tc.gvr, "ns1", "test", tc.value)
+			key, err := c.KeyFor(tc.gvr, obj.Object)
+			if err != nil {
+				t.Fatalf("%+v", err)
+			}
+			if got, want := key, tc.expected; got != want {
+				t.Errorf("got: %q, want: %q", got, want)
+			}
+
+			w.watcher.Add(obj)
+			expectEvent(t, events, key, tc.value)
+			expectCachedValue(t, c, key, tc.value)
+
+			w.watcher.Delete(obj)
+			expectEvent(t, events, key, "")
+			expectCachedValue(t, c, key, "")
+		})
+	}
+}
+
+func TestResourceWatcherCacheListsAgainWhenResourceVersionExpired(t *testing.T) {
+	cluster := newTestCluster()
+	c := newSyncedTestCache(t, cluster, newTestMemoryStore(t), time.Hour)
+	events, unsubscribe := c.Subscribe()
+	defer unsubscribe()
+	w := cluster.waitForWatch(t, fooGvr)
+
+	// an object created while the watch is lagging behind
+	obj := newTestObject(fooGvr, "ns1", "missed", "missed-value")
+	if _, err := cluster.client.Resource(fooGvr).Namespace("ns1").Create(context.Background(), obj, metav1.CreateOptions{}); err != nil {
+		t.Fatalf("%+v", err)
+	}
+	w.watcher.Error(&metav1.Status{
+		Status: metav1.StatusFailure,
+		Code:   410,
+		Reason: metav1.StatusReasonExpired,
+	})
+
+	expectEvent(t, events, "foos:ns1:missed", "missed-value")
+	cluster.waitForWatch(t, fooGvr)
+}
+
+func TestResourceWatcherCacheRestartsWatchFromLastResourceVersion(t *testing.T) {
+	cluster := newTestCluster()
+	c := newSyncedTestCache(t, cluster, newTestMemoryStore(t), time.Hour)
+	events, unsubscribe := c.Subscribe()
+	defer unsubscribe()
+	w := cluster.waitForWatch(t, fooGvr)
+
+	obj := newTestObject(fooGvr, "ns1", "test", "foo-value")
+	obj.SetResourceVersion("41")
+	w.watcher.Add(obj)
+	expectEvent(t, events, "foos:ns1:test", "foo-value")
+
+	bookmark := newTestObject(fooGvr, "", "", "")
+	bookmark.SetResourceVersion("42")
+	w.watcher.Action(watch.Bookmark, bookmark)
+	w.watcher.Stop()
+
+	if got, want := cluster.waitForWatch(t, fooGvr).resourceVersion, "42"; got != want {
+		t.Errorf("got: %q, want: %q", got, want)
+	}
+	// the values cached are kept when restarting the watch
+	expectCachedValue(t, c, "foos:ns1:test", "foo-value")
+}
+
+func TestResourceWatcherCacheResyncsPeriodically(t *testing.T) {
+	obj := newTestObject(fooGvr, "ns1", "test", "foo-value")
+	obj.SetResourceVersion("1")
+	cluster := newTestCluster(obj)
+	c := newSyncedTestCache(t, cluster, newTestMemoryStore(t), 100*time.Millisecond)
+	events, unsubscribe := c.Subscribe()
+	defer unsubscribe()
+	expectCachedValue(t, c, "foos:ns1:test", "foo-value")
+
+	// changes missed by the watch
+	modified := newTestObject(fooGvr, "ns1", "test", "modified-value")
+	modified.SetResourceVersion("2")
+	if _, err := cluster.client.Resource(fooGvr).Namespace("ns1").Update(context.Background(), modified, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("%+v", err)
+	}
+	expectEvent(t, events, "foos:ns1:test", "modified-value")
+
+	if err := cluster.client.Resource(fooGvr).Namespace("ns1").Delete(context.Background(), "test", metav1.DeleteOptions{}); err != nil {
+		t.Fatalf("%+v", err)
+	}
+	expectEvent(t, events, "foos:ns1:test", "")
+	expectCachedValue(t, c, "foos:ns1:test", "")
+}
+
+func TestResourceWatcherCacheResyncKeepsObjectsOfOtherResources(t *testing.T) {
+	cluster := newTestCluster(newTestObject(fooGvr, "ns1", "test", "foo-value"))
+	c := newSyncedTestCache(t, cluster, newTestMemoryStore(t), time.Hour)
+
+	if _, err := c.resync(c.resources[barGvr.Resource]); err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	// the foo is not processed again by the next resync of the foos
+	c.processedMutex.Lock()
+	defer c.processedMutex.Unlock()
+	if _, ok := c.processed["foos:ns1:test"]; !ok {
+		t.Errorf("got: foo forgotten, want: foo still processed after resyncing the bars")
+	}
+}
+
+func TestResourceWatcherCacheComputesEvictedValuesAgain(t *testing.T) {
+	// the store only keeps one of the values of the objects
+	store, err := NewMemoryStore(1)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	cluster := newTestCluster(
+		newTestObject(fooGvr, "ns1", "first", "first-value"),
+		newTestObject(fooGvr, "ns1", "second", "second-value"),
+		newTestObject(fooGvr, "ns1", "deleted", "deleted-value"),
+	)
+	c := newSyncedTestCache(t, cluster, store, time.Hour)
+
+	expectCachedValue(t, c, "foos:ns1:first", "first-value")
+	expectCachedValue(t, c, "foos:ns1:second", "second-value")
+	expectCachedValue(t, c, "foos:ns1:first", "first-value")
+	if keys, err := store.Keys(context.Background(), ""); err != nil {
+		t.Fatalf("%+v", err)
+	} else if got, want := keys, []string{"foos:ns1:first"}; !cmp.Equal(got, want) {
+		t.Errorf("got: %v, want: %v", got, want)
+	}
+
+	// an object deleted before its deletion is watched has no value once evicted
+	if err := cluster.client.Resource(fooGvr).Namespace("ns1").Delete(context.Background(), "deleted", metav1.DeleteOptions{}); err != nil {
+		t.Fatalf("%+v", err)
+	}
+	expectCachedValue(t, c, "foos:ns1:deleted", "")
+	// nor has an object which was never cached
+	expectCachedValue(t, c, "foos:ns1:unknown", "")
+}
+
+func TestResourceWatcherCacheProcessesEventsInOrder(t *testing.T) {
+	cluster := newTestCluster()
+	// an object modified and then deleted straight away is still being processed for
+	// its modification when its deletion is received
+	resource := testResourceConfig(fooGvr)
+	resource.OnModify = func(key string, obj map[string]interface{}) (Value, bool, error) {
+		time.Sleep(10 * time.Millisecond)
+		return onAddOrModifyTestObject(key, obj)
+	}
+	c, err := newCache(Config{
+		ClientGetter: cluster.clientGetter,
+		Resources:    []ResourceConfig{resource},
+		ResyncPeriod: time.Hour,
+	}, newTestMemoryStore(t), testRestartBackoff)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	events, unsubscribe := c.Subscribe()
+	defer unsubscribe()
+	w := cluster.waitForWatch(t, fooGvr)
+
+	obj := newTestObject(fooGvr, "ns1", "test", "modified-value")
+	w.watcher.Modify(obj)
+	w.watcher.Delete(obj)
+
+	expectEvent(t, events, "foos:ns1:test", "modified-value")
+	expectEvent(t, events, "foos:ns1:test", "")
+	expectCachedValue(t, c, "foos:ns1:test", "")
+}
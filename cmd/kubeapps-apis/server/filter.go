@@ -0,0 +1,55 @@
+/*
+Copyright © 2021 VMware
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package server
+
+import (
+	"strings"
+)
+
+// The helpers below implement the semantics of the FilterOptions of an
+// available package summaries request, as applied by the helm plugin when
+// querying the assets database, for plugins filtering their results in memory.
+
+// MatchesFilterQuery returns whether any of the fields contains the text
+// query, ignoring case. An empty query matches any fields.
+func MatchesFilterQuery(query string, fields ...string) bool {
+	if query == "" {
+		return true
+	}
+	query = strings.ToLower(query)
+	for _, field := range fields {
+		if strings.Contains(strings.ToLower(field), query) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchesFilterValues returns whether any of the values equals one of the
+// filter values, such as the requested categories or repositories. Empty
+// filter values are ignored, so that no filter values match any values.
+func MatchesFilterValues(filterValues []string, values ...string) bool {
+	filtered := false
+	for _, filterValue := range filterValues {
+		if filterValue == "" {
+			continue
+		}
+		filtered = true
+		for _, value := range values {
+			if value == filterValue {
+				return true
+			}
+		}
+	}
+	return !filtered
+}
@@ -0,0 +1,104 @@
+/*
+Copyright © 2021 VMware
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package server
+
+import (
+	"testing"
+)
+
+func TestMatchesFilterQuery(t *testing.T) {
+	testCases := []struct {
+		name     string
+		query    string
+		fields   []string
+		expected bool
+	}{
+		{
+			name:     "it matches anything with an empty query",
+			query:    "",
+			fields:   []string{"apache"},
+			expected: true,
+		},
+		{
+			name:     "it matches a substring of any field ignoring case",
+			query:    "PACH",
+			fields:   []string{"nginx", "Apache HTTP server"},
+			expected: true,
+		},
+		{
+			name:     "it does not match when no field contains the query",
+			query:    "wordpress",
+			fields:   []string{"nginx", "Apache HTTP server"},
+			expected: false,
+		},
+		{
+			name:     "it does not match without fields",
+			query:    "wordpress",
+			expected: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got, want := MatchesFilterQuery(tc.query, tc.fields...), tc.expected; got != want {
+				t.Errorf("got: %t, want: %t", got, want)
+			}
+		})
+	}
+}
+
+func TestMatchesFilterValues(t *testing.T) {
+	testCases := []struct {
+		name         string
+		filterValues []string
+		values       []string
+		expected     bool
+	}{
+		{
+			name:     "it matches anything without filter values",
+			values:   []string{"Database"},
+			expected: true,
+		},
+		{
+			name:         "it matches anything when all filter values are empty",
+			filterValues: []string{""},
+			values:       []string{"Database"},
+			expected:     true,
+		},
+		{
+			name:         "it matches when any value equals any filter value",
+			filterValues: []string{"Database", "CMS"},
+			values:       []string{"Logging", "CMS"},
+			expected:     true,
+		},
+		{
+			name:         "it does not match when no value equals a filter value",
+			filterValues: []string{"Database", ""},
+			values:       []string{"cms"},
+			expected:     false,
+		},
+		{
+			name:         "it does not match without values",
+			filterValues: []string{"Database"},
+			expected:     false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got, want := MatchesFilterValues(tc.filterValues, tc.values...), tc.expected; got != want {
+				t.Errorf("got: %t, want: %t", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+/*
+Copyright © 2021 VMware
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package server
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	packages "github.com/kubeapps/kubeapps/cmd/kubeapps-apis/gen/core/packages/v1alpha1"
+	plugins "github.com/kubeapps/kubeapps/cmd/kubeapps-apis/gen/core/plugins/v1alpha1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+)
+
+// PluginDependenciesChecker is implemented by plugins which can check whether
+// the dependencies they require, such as a database or CRDs, are reachable.
+// The context is that of the GetConfiguredPlugins request, so it carries the
+// user credential.
+type PluginDependenciesChecker interface {
+	CheckDependencies(ctx context.Context) []*plugins.PluginDependencyStatus
+}
+
+// registeredPlugin stores what is known about a registered plugin in order to
+// report its status.
+type registeredPlugin struct {
+	plugin       *plugins.Plugin
+	capabilities []plugins.PluginStatus_Capability
+	// checker is nil for plugins which do not check their dependencies.
+	checker PluginDependenciesChecker
+	health  *pluginHealth
+}
+
+// pluginHealth records the last server error returned by a plugin.
+type pluginHealth struct {
+	mutex     sync.Mutex
+	lastError string
+}
+
+// recordError records the error if it is a server error. Errors due to the
+// request itself, such as NotFound or InvalidArgument, are not recorded as they
+// do not tell anything about the health of the plugin.
+func (h *pluginHealth) recordError(err error) {
+	if h == nil || err == nil {
+		return
+	}
+	switch status.Code(err) {
+	case codes.Unknown, codes.Internal, codes.Unavailable, codes.DeadlineExceeded, codes.DataLoss:
+		h.mutex.Lock()
+		defer h.mutex.Unlock()
+		h.lastError = err.Error()
+	}
+}
+
+func (h *pluginHealth) getLastError() string {
+	if h == nil {
+		return ""
+	}
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return h.lastError
+}
+
+// capabilitiesForServer returns the capabilities of a plugin server according
+// to the core APIs it implements.
+func capabilitiesForServer(pluginSrv interface{}) []plugins.PluginStatus_Capability {
+	capabilities := []plugins.PluginStatus_Capability{}
+	if _, ok := pluginSrv.(packages.PackagesServiceServer); ok {
+		capabilities = append(capabilities,
+			plugins.PluginStatus_CAPABILITY_AVAILABLE_PACKAGES,
+			plugins.PluginStatus_CAPABILITY_INSTALLED_PACKAGES)
+	}
+	if _, ok := pluginSrv.(packages.RepositoriesServiceServer); ok {
+		capabilities = append(capabilities, plugins.PluginStatus_CAPABILITY_PACKAGE_REPOSITORIES)
+	}
+	return capabilities
+}
+
+// pluginStatus returns the status of the plugin given the result of checking
+// its dependencies.
+func (p *registeredPlugin) pluginStatus(result pluginResult) *plugins.PluginStatus {
+	pluginStatus := &plugins.PluginStatus{
+		Plugin:       p.plugin,
+		Capabilities: p.capabilities,
+		Healthy:      true,
+	}
+	if result.err != nil {
+		p.health.recordError(result.err)
+		pluginStatus.Healthy = false
+	} else if dependencies, ok := result.response.([]*plugins.PluginDependencyStatus); ok {
+		pluginStatus.Dependencies = dependencies
+		for _, dependency := range dependencies {
+			if !dependency.Reachable {
+				p.health.recordError(status.Errorf(codes.Unavailable, "dependency %s is not reachable: %s", dependency.Name, dependency.Message))
+				pluginStatus.Healthy = false
+			}
+		}
+	}
+	pluginStatus.LastError = p.health.getLastError()
+	return pluginStatus
+}
+
+// CheckGroupVersionResources returns the status of each of the given resources,
+// which are reachable when the API server serves them, that is, when the CRDs
+// are installed. Plugins can use it to check the CRDs they require.
+func CheckGroupVersionResources(discoveryClient discovery.DiscoveryInterface, gvrs []schema.GroupVersionResource) []*plugins.PluginDependencyStatus {
+	dependencies := []*plugins.PluginDependencyStatus{}
+	for _, gvr := range gvrs {
+		dependency := &plugins.PluginDependencyStatus{
+			Name: gvr.GroupResource().String(),
+		}
+		resources, err := discoveryClient.ServerResourcesForGroupVersion(gvr.GroupVersion().String())
+		if err != nil {
+			dependency.Message = err.Error()
+		} else {
+			for _, resource := range resources.APIResources {
+				if resource.Name == gvr.Resource {
+					dependency.Reachable = true
+					break
+				}
+			}
+			if !dependency.Reachable {
+				dependency.Message = fmt.Sprintf("resource %s not served by the API server", gvr.String())
+			}
+		}
+		dependencies = append(dependencies, dependency)
+	}
+	return dependencies
+}
@@ -0,0 +1,175 @@
+/*
+Copyright © 2021 VMware
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	packages "github.com/kubeapps/kubeapps/cmd/kubeapps-apis/gen/core/packages/v1alpha1"
+	plugins "github.com/kubeapps/kubeapps/cmd/kubeapps-apis/gen/core/plugins/v1alpha1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// testDependenciesPluginServer is a packages plugin server which also checks
+// its dependencies.
+type testDependenciesPluginServer struct {
+	testPackagingPluginServer
+	dependencies []*plugins.PluginDependencyStatus
+	// checkDelay, when set, is waited when checking the dependencies.
+	checkDelay time.Duration
+}
+
+func (s testDependenciesPluginServer) CheckDependencies(ctx context.Context) []*plugins.PluginDependencyStatus {
+	time.Sleep(s.checkDelay)
+	return s.dependencies
+}
+
+// testPackagesAndRepositoriesPluginServer is a plugin server implementing both
+// the core packages and repositories APIs.
+type testPackagesAndRepositoriesPluginServer struct {
+	testPackagingPluginServer
+	testRepositoriesPluginServer
+}
+
+var pluginStatusOpts = cmpopts.IgnoreUnexported(
+	plugins.PluginStatus{},
+	plugins.PluginDependencyStatus{},
+	plugins.Plugin{},
+)
+
+func TestGetConfiguredPluginsStatuses(t *testing.T) {
+	packagesCapabilities := []plugins.PluginStatus_Capability{
+		plugins.PluginStatus_CAPABILITY_AVAILABLE_PACKAGES,
+		plugins.PluginStatus_CAPABILITY_INSTALLED_PACKAGES,
+	}
+
+	testCases := []struct {
+		name             string
+		pluginServer     interface{}
+		expectedStatuses []*plugins.PluginStatus
+	}{
+		{
+			name:         "it reports the capabilities of a plugin without dependencies as healthy",
+			pluginServer: testPackagingPluginServer{},
+			expectedStatuses: []*plugins.PluginStatus{
+				{
+					Plugin:       mockedPackagingPlugin1,
+					Capabilities: packagesCapabilities,
+					Healthy:      true,
+				},
+			},
+		},
+		{
+			name:         "it reports the capabilities of a plugin implementing both core APIs",
+			pluginServer: testPackagesAndRepositoriesPluginServer{},
+			expectedStatuses: []*plugins.PluginStatus{
+				{
+					Plugin: mockedPackagingPlugin1,
+					Capabilities: append(packagesCapabilities,
+						plugins.PluginStatus_CAPABILITY_PACKAGE_REPOSITORIES),
+					Healthy: true,
+				},
+			},
+		},
+		{
+			name: "it reports a plugin with unreachable dependencies as unhealthy",
+			pluginServer: testDependenciesPluginServer{
+				dependencies: []*plugins.PluginDependencyStatus{
+					{Name: "redis", Reachable: true},
+					{Name: "helmreleases.helm.toolkit.fluxcd.io", Message: "not found"},
+				},
+			},
+			expectedStatuses: []*plugins.PluginStatus{
+				{
+					Plugin:       mockedPackagingPlugin1,
+					Capabilities: packagesCapabilities,
+					Healthy:      false,
+					Dependencies: []*plugins.PluginDependencyStatus{
+						{Name: "redis", Reachable: true},
+						{Name: "helmreleases.helm.toolkit.fluxcd.io", Message: "not found"},
+					},
+					LastError: "rpc error: code = Unavailable desc = dependency helmreleases.helm.toolkit.fluxcd.io is not reachable: not found",
+				},
+			},
+		},
+		{
+			name: "it reports a plugin not checking its dependencies in time as unhealthy",
+			pluginServer: testDependenciesPluginServer{
+				checkDelay: 100 * time.Millisecond,
+			},
+			expectedStatuses: []*plugins.PluginStatus{
+				{
+					Plugin:       mockedPackagingPlugin1,
+					Capabilities: packagesCapabilities,
+					Healthy:      false,
+					LastError:    "rpc error: code = DeadlineExceeded desc = plugin mock1/v1alpha1 did not respond in time: context deadline exceeded",
+				},
+			},
+		},
+		{
+			name: "it reports the last server error returned by the plugin",
+			pluginServer: testPackagingPluginServer{
+				err: status.Errorf(codes.Internal, "Unable to retrieve charts"),
+			},
+			expectedStatuses: []*plugins.PluginStatus{
+				{
+					Plugin:       mockedPackagingPlugin1,
+					Capabilities: packagesCapabilities,
+					Healthy:      true,
+					LastError:    "rpc error: code = Internal desc = Unable to retrieve charts",
+				},
+			},
+		},
+		{
+			name: "it does not report errors due to the request",
+			pluginServer: testPackagingPluginServer{
+				err: status.Errorf(codes.InvalidArgument, "Invalid namespace"),
+			},
+			expectedStatuses: []*plugins.PluginStatus{
+				{
+					Plugin:       mockedPackagingPlugin1,
+					Capabilities: packagesCapabilities,
+					Healthy:      true,
+				},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ps := &pluginsServer{
+				plugins:       []*plugins.Plugin{mockedPackagingPlugin1},
+				pluginTimeout: 50 * time.Millisecond,
+			}
+			if err := ps.registerPluginsSatisfyingCoreAPIs(tc.pluginServer, mockedPackagingPlugin1); err != nil {
+				t.Fatalf("%+v", err)
+			}
+			// The errors returned for requests through the core API are recorded for the plugin.
+			ps.packagesPlugins[0].server.GetAvailablePackageSummaries(context.Background(), &packages.GetAvailablePackageSummariesRequest{})
+
+			response, err := ps.GetConfiguredPlugins(context.Background(), &plugins.GetConfiguredPluginsRequest{})
+			if err != nil {
+				t.Fatalf("%+v", err)
+			}
+
+			if got, want := response.PluginStatuses, tc.expectedStatuses; !cmp.Equal(want, got, pluginStatusOpts) {
+				t.Errorf("mismatch (-want +got):\n%s", cmp.Diff(want, got, pluginStatusOpts))
+			}
+		})
+	}
+}
@@ -0,0 +1,180 @@
+/*
+Copyright © 2021 VMware
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package server
+
+import (
+	"context"
+	"time"
+
+	packages "github.com/kubeapps/kubeapps/cmd/kubeapps-apis/gen/core/packages/v1alpha1"
+	plugins "github.com/kubeapps/kubeapps/cmd/kubeapps-apis/gen/core/plugins/v1alpha1"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/status"
+)
+
+// pluginInstrumentation instruments the requests sent to a plugin through the
+// core APIs, recording a span, the request metrics and the server errors
+// returned by the plugin for its health.
+type pluginInstrumentation struct {
+	plugin *plugins.Plugin
+	health *pluginHealth
+}
+
+// instrument starts the instrumentation of a request to the plugin, returning
+// the context for the request and the function to call with its result.
+func (i pluginInstrumentation) instrument(ctx context.Context, method string) (context.Context, func(error)) {
+	start := time.Now()
+	ctx, span := otel.Tracer(TracerName).Start(ctx, i.plugin.GetName()+"/"+method,
+		trace.WithAttributes(
+			attribute.String("kubeapps.plugin.name", i.plugin.GetName()),
+			attribute.String("kubeapps.plugin.version", i.plugin.GetVersion()),
+		))
+	return ctx, func(err error) {
+		endSpan(span, err)
+		pluginRequestsTotal.WithLabelValues(i.plugin.GetName(), method, status.Code(err).String()).Inc()
+		pluginRequestDuration.WithLabelValues(i.plugin.GetName(), method).Observe(time.Since(start).Seconds())
+		i.health.recordError(err)
+	}
+}
+
+// instrumentedPackagesServer instruments the requests sent to a plugin for the
+// core packages API.
+type instrumentedPackagesServer struct {
+	packages.PackagesServiceServer
+	pluginInstrumentation
+}
+
+func (s *instrumentedPackagesServer) GetAvailablePackageSummaries(ctx context.Context, request *packages.GetAvailablePackageSummariesRequest) (*packages.GetAvailablePackageSummariesResponse, error) {
+	ctx, done := s.instrument(ctx, "GetAvailablePackageSummaries")
+	response, err := s.PackagesServiceServer.GetAvailablePackageSummaries(ctx, request)
+	done(err)
+	return response, err
+}
+
+func (s *instrumentedPackagesServer) GetAvailablePackageDetail(ctx context.Context, request *packages.GetAvailablePackageDetailRequest) (*packages.GetAvailablePackageDetailResponse, error) {
+	ctx, done := s.instrument(ctx, "GetAvailablePackageDetail")
+	response, err := s.PackagesServiceServer.GetAvailablePackageDetail(ctx, request)
+	done(err)
+	return response, err
+}
+
+func (s *instrumentedPackagesServer) GetAvailablePackageVersions(ctx context.Context, request *packages.GetAvailablePackageVersionsRequest) (*packages.GetAvailablePackageVersionsResponse, error) {
+	ctx, done := s.instrument(ctx, "GetAvailablePackageVersions")
+	response, err := s.PackagesServiceServer.GetAvailablePackageVersions(ctx, request)
+	done(err)
+	return response, err
+}
+
+func (s *instrumentedPackagesServer) WatchAvailablePackages(request *packages.WatchAvailablePackagesRequest, stream packages.PackagesService_WatchAvailablePackagesServer) error {
+	ctx, done := s.instrument(stream.Context(), "WatchAvailablePackages")
+	err := s.PackagesServiceServer.WatchAvailablePackages(request, &instrumentedWatchStream{stream, ctx})
+	done(err)
+	return err
+}
+
+// instrumentedWatchStream passes the context of the instrumented request to
+// a plugin watching the available packages.
+type instrumentedWatchStream struct {
+	packages.PackagesService_WatchAvailablePackagesServer
+	ctx context.Context
+}
+
+func (s *instrumentedWatchStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *instrumentedPackagesServer) GetInstalledPackageSummaries(ctx context.Context, request *packages.GetInstalledPackageSummariesRequest) (*packages.GetInstalledPackageSummariesResponse, error) {
+	ctx, done := s.instrument(ctx, "GetInstalledPackageSummaries")
+	response, err := s.PackagesServiceServer.GetInstalledPackageSummaries(ctx, request)
+	done(err)
+	return response, err
+}
+
+func (s *instrumentedPackagesServer) GetInstalledPackageDetail(ctx context.Context, request *packages.GetInstalledPackageDetailRequest) (*packages.GetInstalledPackageDetailResponse, error) {
+	ctx, done := s.instrument(ctx, "GetInstalledPackageDetail")
+	response, err := s.PackagesServiceServer.GetInstalledPackageDetail(ctx, request)
+	done(err)
+	return response, err
+}
+
+func (s *instrumentedPackagesServer) CreateInstalledPackage(ctx context.Context, request *packages.CreateInstalledPackageRequest) (*packages.CreateInstalledPackageResponse, error) {
+	ctx, done := s.instrument(ctx, "CreateInstalledPackage")
+	response, err := s.PackagesServiceServer.CreateInstalledPackage(ctx, request)
+	done(err)
+	return response, err
+}
+
+func (s *instrumentedPackagesServer) UpdateInstalledPackage(ctx context.Context, request *packages.UpdateInstalledPackageRequest) (*packages.UpdateInstalledPackageResponse, error) {
+	ctx, done := s.instrument(ctx, "UpdateInstalledPackage")
+	response, err := s.PackagesServiceServer.UpdateInstalledPackage(ctx, request)
+	done(err)
+	return response, err
+}
+
+func (s *instrumentedPackagesServer) DeleteInstalledPackage(ctx context.Context, request *packages.DeleteInstalledPackageRequest) (*packages.DeleteInstalledPackageResponse, error) {
+	ctx, done := s.instrument(ctx, "DeleteInstalledPackage")
+	response, err := s.PackagesServiceServer.DeleteInstalledPackage(ctx, request)
+	done(err)
+	return response, err
+}
+
+// instrumentedRepositoriesServer instruments the requests sent to a plugin for
+// the core repositories API.
+type instrumentedRepositoriesServer struct {
+	packages.RepositoriesServiceServer
+	pluginInstrumentation
+}
+
+func (s *instrumentedRepositoriesServer) GetPackageRepositorySummaries(ctx context.Context, request *packages.GetPackageRepositorySummariesRequest) (*packages.GetPackageRepositorySummariesResponse, error) {
+	ctx, done := s.instrument(ctx, "GetPackageRepositorySummaries")
+	response, err := s.RepositoriesServiceServer.GetPackageRepositorySummaries(ctx, request)
+	done(err)
+	return response, err
+}
+
+func (s *instrumentedRepositoriesServer) GetPackageRepositoryDetail(ctx context.Context, request *packages.GetPackageRepositoryDetailRequest) (*packages.GetPackageRepositoryDetailResponse, error) {
+	ctx, done := s.instrument(ctx, "GetPackageRepositoryDetail")
+	response, err := s.RepositoriesServiceServer.GetPackageRepositoryDetail(ctx, request)
+	done(err)
+	return response, err
+}
+
+func (s *instrumentedRepositoriesServer) AddPackageRepository(ctx context.Context, request *packages.AddPackageRepositoryRequest) (*packages.AddPackageRepositoryResponse, error) {
+	ctx, done := s.instrument(ctx, "AddPackageRepository")
+	response, err := s.RepositoriesServiceServer.AddPackageRepository(ctx, request)
+	done(err)
+	return response, err
+}
+
+func (s *instrumentedRepositoriesServer) UpdatePackageRepository(ctx context.Context, request *packages.UpdatePackageRepositoryRequest) (*packages.UpdatePackageRepositoryResponse, error) {
+	ctx, done := s.instrument(ctx, "UpdatePackageRepository")
+	response, err := s.RepositoriesServiceServer.UpdatePackageRepository(ctx, request)
+	done(err)
+	return response, err
+}
+
+func (s *instrumentedRepositoriesServer) DeletePackageRepository(ctx context.Context, request *packages.DeletePackageRepositoryRequest) (*packages.DeletePackageRepositoryResponse, error) {
+	ctx, done := s.instrument(ctx, "DeletePackageRepository")
+	response, err := s.RepositoriesServiceServer.DeletePackageRepository(ctx, request)
+	done(err)
+	return response, err
+}
+
+func (s *instrumentedRepositoriesServer) RefreshPackageRepository(ctx context.Context, request *packages.RefreshPackageRepositoryRequest) (*packages.RefreshPackageRepositoryResponse, error) {
+	ctx, done := s.instrument(ctx, "RefreshPackageRepository")
+	response, err := s.RepositoriesServiceServer.RefreshPackageRepository(ctx, request)
+	done(err)
+	return response, err
+}
@@ -0,0 +1,75 @@
+/*
+Copyright © 2021 VMware
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package server
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// MetricsNamespace is the namespace of the metrics of the server, which
+// plugins can use for their own metrics. The metrics are registered with the
+// default prometheus registry, shared with the plugins, and served on /metrics.
+const MetricsNamespace = "kubeapps_apis"
+
+var (
+	grpcRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: MetricsNamespace,
+		Name:      "grpc_requests_total",
+		Help:      "Number of gRPC requests, including those received over HTTP through the gateway, by method and status code.",
+	}, []string{"method", "code"})
+	grpcRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: MetricsNamespace,
+		Name:      "grpc_request_duration_seconds",
+		Help:      "Duration of gRPC requests by method.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method"})
+	pluginRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: MetricsNamespace,
+		Name:      "plugin_requests_total",
+		Help:      "Number of requests sent to each plugin through the core APIs, by method and status code.",
+	}, []string{"plugin", "method", "code"})
+	pluginRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: MetricsNamespace,
+		Name:      "plugin_request_duration_seconds",
+		Help:      "Duration of the requests sent to each plugin through the core APIs, by method.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"plugin", "method"})
+)
+
+// observeRequest records the metrics of a gRPC request.
+func observeRequest(fullMethod string, err error, duration time.Duration) {
+	grpcRequestsTotal.WithLabelValues(fullMethod, status.Code(err).String()).Inc()
+	grpcRequestDuration.WithLabelValues(fullMethod).Observe(duration.Seconds())
+}
+
+// metricsUnaryInterceptor is the grpc.UnaryServerInterceptor recording the metrics of each request.
+func metricsUnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	response, err := handler(ctx, req)
+	observeRequest(info.FullMethod, err, time.Since(start))
+	return response, err
+}
+
+// metricsStreamInterceptor is the grpc.StreamServerInterceptor recording the metrics of each streaming request.
+func metricsStreamInterceptor(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	start := time.Now()
+	err := handler(srv, stream)
+	observeRequest(info.FullMethod, err, time.Since(start))
+	return err
+}
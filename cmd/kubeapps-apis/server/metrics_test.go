@@ -0,0 +1,70 @@
+/*
+Copyright © 2021 VMware
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestMetricsInterceptors(t *testing.T) {
+	testCases := []struct {
+		name         string
+		fullMethod   string
+		err          error
+		expectedCode string
+	}{
+		{
+			name:         "it counts the successful requests",
+			fullMethod:   "/test.Service/Succeed",
+			expectedCode: "OK",
+		},
+		{
+			name:         "it counts the failed requests by status code",
+			fullMethod:   "/test.Service/Fail",
+			err:          status.Errorf(codes.PermissionDenied, "forbidden"),
+			expectedCode: "PermissionDenied",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			requests := grpcRequestsTotal.WithLabelValues(tc.fullMethod, tc.expectedCode)
+			before := testutil.ToFloat64(requests)
+
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+				return nil, tc.err
+			}
+			_, err := metricsUnaryInterceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: tc.fullMethod}, handler)
+			if got, want := err, tc.err; got != want {
+				t.Fatalf("got: %v, want: %v", got, want)
+			}
+			streamHandler := func(srv interface{}, stream grpc.ServerStream) error {
+				return tc.err
+			}
+			err = metricsStreamInterceptor(nil, nil, &grpc.StreamServerInfo{FullMethod: tc.fullMethod}, streamHandler)
+			if got, want := err, tc.err; got != want {
+				t.Fatalf("got: %v, want: %v", got, want)
+			}
+
+			if got, want := testutil.ToFloat64(requests)-before, 2.0; got != want {
+				t.Errorf("got: %v requests, want: %v", got, want)
+			}
+		})
+	}
+}
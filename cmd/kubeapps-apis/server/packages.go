@@ -15,11 +15,21 @@ package server
 import (
 	"context"
 	"fmt"
+	"sync"
+	"time"
 
 	packages "github.com/kubeapps/kubeapps/cmd/kubeapps-apis/gen/core/packages/v1alpha1"
+	plugins "github.com/kubeapps/kubeapps/cmd/kubeapps-apis/gen/core/plugins/v1alpha1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 	log "k8s.io/klog/v2"
 )
 
+// defaultPluginTimeout is the deadline for each plugin when a request is
+// fanned out to all plugins, unless otherwise configured.
+const defaultPluginTimeout = 30 * time.Second
+
 // packagesServer implements the API defined in proto/kubeappsapis/core/packages/v1alpha1/packages.proto
 type packagesServer struct {
 	packages.UnimplementedPackagesServiceServer
@@ -27,14 +37,104 @@ type packagesServer struct {
 	// plugins is a slice of all registered plugins which satisfy the core.packages.v1alpha1
 	// interface.
 	plugins []*pkgsPluginWithServer
+
+	// pluginTimeout is the deadline for each plugin when a request is fanned
+	// out to all plugins.
+	pluginTimeout time.Duration
 }
 
-func NewPackagesServer(plugins []*pkgsPluginWithServer) *packagesServer {
+func NewPackagesServer(plugins []*pkgsPluginWithServer, pluginTimeout time.Duration) *packagesServer {
+	if pluginTimeout <= 0 {
+		pluginTimeout = defaultPluginTimeout
+	}
 	return &packagesServer{
-		plugins: plugins,
+		plugins:       plugins,
+		pluginTimeout: pluginTimeout,
 	}
 }
 
+// pluginResult holds the response or the error returned by a single plugin
+// when a request is fanned out to all plugins.
+type pluginResult struct {
+	plugin   *plugins.Plugin
+	response interface{}
+	err      error
+}
+
+// fanOut calls the given function concurrently for each of the given plugins,
+// each with its own deadline, and returns the results in the order of the
+// plugins. The function is passed the index of the plugin being called. A
+// plugin which does not return before its deadline results in a
+// DeadlineExceeded error, even if it does not honour the context.
+func fanOut(ctx context.Context, pluginTimeout time.Duration, pluginDetails []*plugins.Plugin, call func(ctx context.Context, i int) (interface{}, error)) []pluginResult {
+	results := make([]pluginResult, len(pluginDetails))
+	var wg sync.WaitGroup
+	for i, p := range pluginDetails {
+		wg.Add(1)
+		go func(i int, p *plugins.Plugin) {
+			defer wg.Done()
+			pluginCtx, cancel := context.WithTimeout(ctx, pluginTimeout)
+			defer cancel()
+
+			// Buffered so that a plugin returning after its deadline does not block forever.
+			done := make(chan pluginResult, 1)
+			go func() {
+				response, err := call(pluginCtx, i)
+				done <- pluginResult{plugin: p, response: response, err: err}
+			}()
+
+			select {
+			case result := <-done:
+				results[i] = result
+			case <-pluginCtx.Done():
+				results[i] = pluginResult{
+					plugin: p,
+					err:    status.Errorf(codes.DeadlineExceeded, "plugin %s/%s did not respond in time: %v", p.Name, p.Version, pluginCtx.Err()),
+				}
+			}
+		}(i, p)
+	}
+	wg.Wait()
+	return results
+}
+
+// fanOut calls the given function concurrently for each of the given
+// packages plugins, as described for the fanOut function.
+func (s packagesServer) fanOut(ctx context.Context, pluginsWithServer []*pkgsPluginWithServer, call func(context.Context, *pkgsPluginWithServer) (interface{}, error)) []pluginResult {
+	pluginDetails := make([]*plugins.Plugin, len(pluginsWithServer))
+	for i, p := range pluginsWithServer {
+		pluginDetails[i] = p.plugin
+	}
+	return fanOut(ctx, s.pluginTimeout, pluginDetails, func(ctx context.Context, i int) (interface{}, error) {
+		return call(ctx, pluginsWithServer[i])
+	})
+}
+
+// pluginErrorFor returns the structured error reported in aggregated
+// responses for a plugin which failed.
+func pluginErrorFor(plugin *plugins.Plugin, err error) *packages.PluginError {
+	st, _ := status.FromError(err)
+	return &packages.PluginError{
+		Plugin:       plugin,
+		StatusCode:   int32(st.Code()),
+		ErrorMessage: st.Message(),
+	}
+}
+
+// allPluginsFailed returns the error to be returned for an aggregated request
+// when every plugin failed, in which case there are no results to return.
+func allPluginsFailed(results []pluginResult) error {
+	if len(results) == 0 {
+		return nil
+	}
+	for _, r := range results {
+		if r.err == nil {
+			return nil
+		}
+	}
+	return results[0].err
+}
+
 // GetAvailablePackages returns the packages based on the request.
 func (s packagesServer) GetAvailablePackageSummaries(ctx context.Context, request *packages.GetAvailablePackageSummariesRequest) (*packages.GetAvailablePackageSummariesResponse, error) {
 	contextMsg := ""
@@ -44,13 +144,34 @@ func (s packagesServer) GetAvailablePackageSummaries(ctx context.Context, reques
 
 	log.Infof("+core GetAvailablePackageSummaries %s", contextMsg)
 
-	pkgs := []*packages.AvailablePackageSummary{}
-	// TODO: We can do these in parallel in separate go routines.
-	for _, p := range s.plugins {
-		response, err := p.server.GetAvailablePackageSummaries(ctx, request)
-		if err != nil {
-			return nil, err
+	cursor, err := s.decodePageCursor(request.GetPaginationOptions().GetPageToken())
+	if err != nil {
+		return nil, err
+	}
+	pageSize := request.GetPaginationOptions().GetPageSize()
+
+	results := s.fanOut(ctx, s.pluginsInCursor(cursor), func(ctx context.Context, p *pkgsPluginWithServer) (interface{}, error) {
+		pluginRequest := proto.Clone(request).(*packages.GetAvailablePackageSummariesRequest)
+		pluginRequest.PaginationOptions = paginationOptionsFor(cursor[pluginKey(p.plugin)], pageSize)
+		return p.server.GetAvailablePackageSummaries(ctx, pluginRequest)
+	})
+	if err := allPluginsFailed(results); err != nil {
+		return nil, err
+	}
+
+	var pluginErrors []*packages.PluginError
+	pages := []pluginPage{}
+	pagePkgs := [][]*packages.AvailablePackageSummary{}
+	page := newPageCollector(pageSize)
+	for _, result := range results {
+		pluginCursor := cursor[pluginKey(result.plugin)]
+		if result.err != nil {
+			log.Errorf("+core GetAvailablePackageSummaries: plugin %v failed: %v", result.plugin, result.err)
+			pluginErrors = append(pluginErrors, pluginErrorFor(result.plugin, result.err))
+			page.retry(result.plugin, pluginCursor)
+			continue
 		}
+		response := result.response.(*packages.GetAvailablePackageSummariesResponse)
 
 		// Add the plugin for the pkgs
 		pluginPkgs := response.AvailablePackagesSummaries
@@ -58,13 +179,349 @@ func (s packagesServer) GetAvailablePackageSummaries(ctx context.Context, reques
 			if r.AvailablePackageRef == nil {
 				r.AvailablePackageRef = &packages.AvailablePackageReference{}
 			}
-			r.AvailablePackageRef.Plugin = p.plugin
+			r.AvailablePackageRef.Plugin = result.plugin
 		}
-		pkgs = append(pkgs, pluginPkgs...)
+		pages = append(pages, pluginPage{
+			plugin:        result.plugin,
+			cursor:        pluginCursor,
+			numResults:    len(pluginPkgs),
+			nextPageToken: response.NextPageToken,
+		})
+		pagePkgs = append(pagePkgs, pluginPkgs)
+	}
+
+	// Each plugin returns its results sorted as requested, so they are merged
+	// into a single sorted page.
+	var lessPosition func(a, b resultPosition) bool
+	if less := LessAvailablePackageSummaryFunc(request.GetSortOptions()); less != nil {
+		lessPosition = func(a, b resultPosition) bool {
+			return less(pagePkgs[a.page][a.index], pagePkgs[b.page][b.index])
+		}
+	}
+	pkgs := []*packages.AvailablePackageSummary{}
+	for _, position := range page.merge(pages, lessPosition) {
+		pkgs = append(pkgs, pagePkgs[position.page][position.index])
+	}
+
+	nextPageToken, err := page.nextPageToken()
+	if err != nil {
+		return nil, err
 	}
 
-	// TODO: Sort via default sort order or that specified in request.
 	return &packages.GetAvailablePackageSummariesResponse{
 		AvailablePackagesSummaries: pkgs,
+		NextPageToken:              nextPageToken,
+		PluginErrors:               pluginErrors,
 	}, nil
 }
+
+// GetAvailablePackageDetail returns the available package detail from the
+// plugin specified in the available package reference.
+func (s packagesServer) GetAvailablePackageDetail(ctx context.Context, request *packages.GetAvailablePackageDetailRequest) (*packages.GetAvailablePackageDetailResponse, error) {
+	log.Infof("+core GetAvailablePackageDetail (ref=[%v], version=[%s])", request.GetAvailablePackageRef(), request.GetPkgVersion())
+
+	pluginWithServer, err := s.getPluginWithServer(request.GetAvailablePackageRef().GetPlugin())
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := pluginWithServer.server.GetAvailablePackageDetail(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	// Validate the plugin response and set the plugin on the ref.
+	if response.GetAvailablePackageDetail().GetAvailablePackageRef() == nil {
+		return nil, status.Errorf(codes.Internal, "available package detail returned by plugin %v is missing the available package reference", pluginWithServer.plugin)
+	}
+	response.AvailablePackageDetail.AvailablePackageRef.Plugin = pluginWithServer.plugin
+
+	return response, nil
+}
+
+// GetAvailablePackageVersions returns the versions of the available package
+// from the plugin specified in the available package reference.
+func (s packagesServer) GetAvailablePackageVersions(ctx context.Context, request *packages.GetAvailablePackageVersionsRequest) (*packages.GetAvailablePackageVersionsResponse, error) {
+	log.Infof("+core GetAvailablePackageVersions (ref=[%v])", request.GetAvailablePackageRef())
+
+	pluginWithServer, err := s.getPluginWithServer(request.GetAvailablePackageRef().GetPlugin())
+	if err != nil {
+		return nil, err
+	}
+
+	return pluginWithServer.server.GetAvailablePackageVersions(ctx, request)
+}
+
+// watchFanIn forwards the events streamed concurrently by the plugins to the
+// stream of the client, which does not support concurrent sends, until the
+// watch is closed.
+type watchFanIn struct {
+	stream packages.PackagesService_WatchAvailablePackagesServer
+	mutex  sync.Mutex
+	closed bool
+}
+
+func (f *watchFanIn) send(response *packages.WatchAvailablePackagesResponse) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.closed {
+		return status.Errorf(codes.Canceled, "the watch of the available packages is closed")
+	}
+	return f.stream.Send(response)
+}
+
+// close stops forwarding the events, so that no event is sent after the
+// request returns.
+func (f *watchFanIn) close() {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.closed = true
+}
+
+// pluginWatchStream is the stream passed to a plugin watching the available
+// packages. It sets the plugin on the package references of the events
+// before forwarding them to the client.
+type pluginWatchStream struct {
+	packages.PackagesService_WatchAvailablePackagesServer
+	ctx    context.Context
+	plugin *plugins.Plugin
+	fanIn  *watchFanIn
+}
+
+func (s *pluginWatchStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *pluginWatchStream) Send(response *packages.WatchAvailablePackagesResponse) error {
+	summary := response.GetAvailablePackageSummary()
+	if summary == nil {
+		return status.Errorf(codes.Internal, "event sent by plugin %v is missing the available package summary", s.plugin)
+	}
+	if summary.AvailablePackageRef == nil {
+		summary.AvailablePackageRef = &packages.AvailablePackageReference{}
+	}
+	summary.AvailablePackageRef.Plugin = s.plugin
+	return s.fanIn.send(response)
+}
+
+// WatchAvailablePackages streams the changes of the available packages of all
+// the registered plugins which support watching them. The watch ends with the
+// first plugin failing, so that the client can watch again.
+func (s packagesServer) WatchAvailablePackages(request *packages.WatchAvailablePackagesRequest, stream packages.PackagesService_WatchAvailablePackagesServer) error {
+	contextMsg := ""
+	if request.Context != nil {
+		contextMsg = fmt.Sprintf("(cluster=[%s], namespace=[%s])", request.Context.Cluster, request.Context.Namespace)
+	}
+
+	log.Infof("+core WatchAvailablePackages %s", contextMsg)
+
+	ctx, cancel := context.WithCancel(stream.Context())
+	defer cancel()
+	fanIn := &watchFanIn{stream: stream}
+	defer fanIn.close()
+
+	results := make(chan pluginResult, len(s.plugins))
+	for _, p := range s.plugins {
+		go func(p *pkgsPluginWithServer) {
+			pluginStream := &pluginWatchStream{
+				PackagesService_WatchAvailablePackagesServer: stream,
+				ctx:    ctx,
+				plugin: p.plugin,
+				fanIn:  fanIn,
+			}
+			results <- pluginResult{plugin: p.plugin, err: p.server.WatchAvailablePackages(request, pluginStream)}
+		}(p)
+	}
+
+	watching := 0
+	for range s.plugins {
+		result := <-results
+		switch {
+		case status.Code(result.err) == codes.Unimplemented:
+			log.Infof("+core WatchAvailablePackages: plugin %v does not support watching", result.plugin)
+			continue
+		case result.err != nil && ctx.Err() == nil:
+			log.Errorf("+core WatchAvailablePackages: plugin %v failed: %v", result.plugin, result.err)
+			return result.err
+		}
+		watching++
+	}
+
+	if watching == 0 {
+		return status.Errorf(codes.Unimplemented, "None of the registered plugins supports watching the available packages")
+	}
+	return nil
+}
+
+// GetInstalledPackageSummaries returns the installed packages managed by all
+// registered plugins for the request context.
+func (s packagesServer) GetInstalledPackageSummaries(ctx context.Context, request *packages.GetInstalledPackageSummariesRequest) (*packages.GetInstalledPackageSummariesResponse, error) {
+	contextMsg := ""
+	if request.Context != nil {
+		contextMsg = fmt.Sprintf("(cluster=[%s], namespace=[%s])", request.Context.Cluster, request.Context.Namespace)
+	}
+
+	log.Infof("+core GetInstalledPackageSummaries %s", contextMsg)
+
+	cursor, err := s.decodePageCursor(request.GetPaginationOptions().GetPageToken())
+	if err != nil {
+		return nil, err
+	}
+	pageSize := request.GetPaginationOptions().GetPageSize()
+
+	results := s.fanOut(ctx, s.pluginsInCursor(cursor), func(ctx context.Context, p *pkgsPluginWithServer) (interface{}, error) {
+		pluginRequest := proto.Clone(request).(*packages.GetInstalledPackageSummariesRequest)
+		pluginRequest.PaginationOptions = paginationOptionsFor(cursor[pluginKey(p.plugin)], pageSize)
+		return p.server.GetInstalledPackageSummaries(ctx, pluginRequest)
+	})
+	if err := allPluginsFailed(results); err != nil {
+		return nil, err
+	}
+
+	var pluginErrors []*packages.PluginError
+	pages := []pluginPage{}
+	pagePkgs := [][]*packages.InstalledPackageSummary{}
+	page := newPageCollector(pageSize)
+	for _, result := range results {
+		pluginCursor := cursor[pluginKey(result.plugin)]
+		if result.err != nil {
+			log.Errorf("+core GetInstalledPackageSummaries: plugin %v failed: %v", result.plugin, result.err)
+			pluginErrors = append(pluginErrors, pluginErrorFor(result.plugin, result.err))
+			page.retry(result.plugin, pluginCursor)
+			continue
+		}
+		response := result.response.(*packages.GetInstalledPackageSummariesResponse)
+
+		// Add the plugin for the pkgs
+		pluginPkgs := response.InstalledPackagesSummaries
+		for _, r := range pluginPkgs {
+			if r.InstalledPackageRef == nil {
+				r.InstalledPackageRef = &packages.InstalledPackageReference{}
+			}
+			r.InstalledPackageRef.Plugin = result.plugin
+		}
+		pages = append(pages, pluginPage{
+			plugin:        result.plugin,
+			cursor:        pluginCursor,
+			numResults:    len(pluginPkgs),
+			nextPageToken: response.NextPageToken,
+		})
+		pagePkgs = append(pagePkgs, pluginPkgs)
+	}
+
+	pkgs := []*packages.InstalledPackageSummary{}
+	for _, position := range page.merge(pages, nil) {
+		pkgs = append(pkgs, pagePkgs[position.page][position.index])
+	}
+
+	nextPageToken, err := page.nextPageToken()
+	if err != nil {
+		return nil, err
+	}
+
+	return &packages.GetInstalledPackageSummariesResponse{
+		InstalledPackagesSummaries: pkgs,
+		NextPageToken:              nextPageToken,
+		PluginErrors:               pluginErrors,
+	}, nil
+}
+
+// GetInstalledPackageDetail returns the installed package detail from the
+// plugin specified in the installed package reference.
+func (s packagesServer) GetInstalledPackageDetail(ctx context.Context, request *packages.GetInstalledPackageDetailRequest) (*packages.GetInstalledPackageDetailResponse, error) {
+	log.Infof("+core GetInstalledPackageDetail (ref=[%v])", request.GetInstalledPackageRef())
+
+	pluginWithServer, err := s.getPluginWithServer(request.GetInstalledPackageRef().GetPlugin())
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := pluginWithServer.server.GetInstalledPackageDetail(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	// Validate the plugin response and set the plugin on the refs.
+	if response.GetInstalledPackageDetail().GetInstalledPackageRef() == nil {
+		return nil, status.Errorf(codes.Internal, "installed package detail returned by plugin %v is missing the installed package reference", pluginWithServer.plugin)
+	}
+	response.InstalledPackageDetail.InstalledPackageRef.Plugin = pluginWithServer.plugin
+	if response.InstalledPackageDetail.AvailablePackageRef != nil {
+		response.InstalledPackageDetail.AvailablePackageRef.Plugin = pluginWithServer.plugin
+	}
+
+	return response, nil
+}
+
+// CreateInstalledPackage creates the installed package using the plugin
+// specified in the available package reference.
+func (s packagesServer) CreateInstalledPackage(ctx context.Context, request *packages.CreateInstalledPackageRequest) (*packages.CreateInstalledPackageResponse, error) {
+	log.Infof("+core CreateInstalledPackage (ref=[%v], name=[%s])", request.GetAvailablePackageRef(), request.GetName())
+
+	pluginWithServer, err := s.getPluginWithServer(request.GetAvailablePackageRef().GetPlugin())
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := pluginWithServer.server.CreateInstalledPackage(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.GetInstalledPackageRef() == nil {
+		return nil, status.Errorf(codes.Internal, "response returned by plugin %v is missing the installed package reference", pluginWithServer.plugin)
+	}
+	response.InstalledPackageRef.Plugin = pluginWithServer.plugin
+
+	return response, nil
+}
+
+// UpdateInstalledPackage updates the installed package using the plugin
+// specified in the installed package reference.
+func (s packagesServer) UpdateInstalledPackage(ctx context.Context, request *packages.UpdateInstalledPackageRequest) (*packages.UpdateInstalledPackageResponse, error) {
+	log.Infof("+core UpdateInstalledPackage (ref=[%v])", request.GetInstalledPackageRef())
+
+	pluginWithServer, err := s.getPluginWithServer(request.GetInstalledPackageRef().GetPlugin())
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := pluginWithServer.server.UpdateInstalledPackage(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.GetInstalledPackageRef() == nil {
+		return nil, status.Errorf(codes.Internal, "response returned by plugin %v is missing the installed package reference", pluginWithServer.plugin)
+	}
+	response.InstalledPackageRef.Plugin = pluginWithServer.plugin
+
+	return response, nil
+}
+
+// DeleteInstalledPackage deletes the installed package using the plugin
+// specified in the installed package reference.
+func (s packagesServer) DeleteInstalledPackage(ctx context.Context, request *packages.DeleteInstalledPackageRequest) (*packages.DeleteInstalledPackageResponse, error) {
+	log.Infof("+core DeleteInstalledPackage (ref=[%v])", request.GetInstalledPackageRef())
+
+	pluginWithServer, err := s.getPluginWithServer(request.GetInstalledPackageRef().GetPlugin())
+	if err != nil {
+		return nil, err
+	}
+
+	return pluginWithServer.server.DeleteInstalledPackage(ctx, request)
+}
+
+// getPluginWithServer returns the registered plugin server matching the
+// requested plugin name and version.
+func (s packagesServer) getPluginWithServer(plugin *plugins.Plugin) (*pkgsPluginWithServer, error) {
+	if plugin == nil {
+		return nil, status.Errorf(codes.InvalidArgument, "Unable to determine the plugin from the request")
+	}
+	for _, p := range s.plugins {
+		if p.plugin.Name == plugin.Name && p.plugin.Version == plugin.Version {
+			return p, nil
+		}
+	}
+	return nil, status.Errorf(codes.InvalidArgument, "Unable to find a registered plugin %s/%s", plugin.Name, plugin.Version)
+}
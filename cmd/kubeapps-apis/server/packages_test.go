@@ -0,0 +1,1327 @@
+/*
+Copyright © 2021 VMware
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package server
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	packages "github.com/kubeapps/kubeapps/cmd/kubeapps-apis/gen/core/packages/v1alpha1"
+	plugins "github.com/kubeapps/kubeapps/cmd/kubeapps-apis/gen/core/plugins/v1alpha1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var ignoreUnexportedOpts = cmpopts.IgnoreUnexported(
+	packages.GetAvailablePackageSummariesResponse{},
+	packages.AvailablePackageSummary{},
+	packages.AvailablePackageReference{},
+	packages.GetAvailablePackageDetailResponse{},
+	packages.AvailablePackageDetail{},
+	packages.GetAvailablePackageVersionsResponse{},
+	packages.GetAvailablePackageVersionsResponse_PackageAppVersion{},
+	packages.WatchAvailablePackagesResponse{},
+	packages.PluginError{},
+	packages.GetInstalledPackageSummariesResponse{},
+	packages.GetInstalledPackageDetailResponse{},
+	packages.CreateInstalledPackageResponse{},
+	packages.UpdateInstalledPackageResponse{},
+	packages.InstalledPackageSummary{},
+	packages.InstalledPackageDetail{},
+	packages.InstalledPackageReference{},
+	packages.Context{},
+	plugins.Plugin{},
+)
+
+var mockedPackagingPlugin1 = makeDefaultTestPackagingPlugin("mock1")
+var mockedPackagingPlugin2 = makeDefaultTestPackagingPlugin("mock2")
+
+// testPackagingPluginServer is a minimal packages plugin server returning
+// canned responses for the installed package rpcs.
+type testPackagingPluginServer struct {
+	packages.UnimplementedPackagesServiceServer
+	plugin                     *plugins.Plugin
+	availablePackageSummaries  []*packages.AvailablePackageSummary
+	availablePackageDetail     *packages.AvailablePackageDetail
+	availablePackageVersions   []*packages.GetAvailablePackageVersionsResponse_PackageAppVersion
+	installedPackageSummaries  []*packages.InstalledPackageSummary
+	installedPackageDetail     *packages.InstalledPackageDetail
+	createdInstalledPackageRef *packages.InstalledPackageReference
+	// err, when set, is returned by the summaries rpcs and ends the watch.
+	err error
+	// delay, when set, is waited by the summaries rpcs, ignoring the context.
+	delay time.Duration
+	// presorted, when set, returns the available packages in their given order
+	// rather than sorting them, as the helm plugin returns them in the order
+	// of its database.
+	presorted bool
+	// failingCall, when set, makes a single call to the summaries rpcs fail.
+	failingCall *failingCall
+}
+
+// failingCall fails the call of the given number, from 1, with its error.
+type failingCall struct {
+	call  int
+	calls int
+	err   error
+}
+
+func (f *failingCall) next() error {
+	if f == nil {
+		return nil
+	}
+	f.calls++
+	if f.calls == f.call {
+		return f.err
+	}
+	return nil
+}
+
+func makeDefaultTestPackagingPlugin(pluginName string) *plugins.Plugin {
+	return &plugins.Plugin{Name: pluginName, Version: "v1alpha1"}
+}
+
+func makeInstalledPackageSummary(identifier string) *packages.InstalledPackageSummary {
+	return &packages.InstalledPackageSummary{
+		InstalledPackageRef: &packages.InstalledPackageReference{
+			Context:    &packages.Context{Namespace: "default"},
+			Identifier: identifier,
+		},
+		Name: identifier,
+	}
+}
+
+func makeAvailablePackageSummary(identifier string) *packages.AvailablePackageSummary {
+	return &packages.AvailablePackageSummary{
+		AvailablePackageRef: &packages.AvailablePackageReference{
+			Context:    &packages.Context{Namespace: "default"},
+			Identifier: identifier,
+		},
+		Name: identifier,
+	}
+}
+
+func makeVersionedAvailablePackageSummary(identifier, version string) *packages.AvailablePackageSummary {
+	summary := makeAvailablePackageSummary(identifier)
+	summary.LatestPkgVersion = version
+	return summary
+}
+
+// pageBounds paginates the canned results of the test plugin, using the
+// offset of the first result as the page token, as the plugins do.
+func pageBounds(options *packages.PaginationOptions, total int) (start, end int, nextPageToken string, err error) {
+	if options.GetPageToken() != "" {
+		start, err = strconv.Atoi(options.GetPageToken())
+		if err != nil {
+			return 0, 0, "", status.Errorf(codes.InvalidArgument, "invalid page token %q", options.GetPageToken())
+		}
+	}
+	if start > total {
+		start = total
+	}
+	end = total
+	if pageSize := int(options.GetPageSize()); pageSize > 0 && start+pageSize < total {
+		end = start + pageSize
+		nextPageToken = fmt.Sprintf("%d", end)
+	}
+	return start, end, nextPageToken, nil
+}
+
+func (s testPackagingPluginServer) GetAvailablePackageSummaries(ctx context.Context, request *packages.GetAvailablePackageSummariesRequest) (*packages.GetAvailablePackageSummariesResponse, error) {
+	time.Sleep(s.delay)
+	if s.err != nil {
+		return nil, s.err
+	}
+	if err := s.failingCall.next(); err != nil {
+		return nil, err
+	}
+	summaries := append([]*packages.AvailablePackageSummary{}, s.availablePackageSummaries...)
+	if !s.presorted {
+		SortAvailablePackageSummaries(summaries, request.GetSortOptions())
+	}
+	start, end, nextPageToken, err := pageBounds(request.GetPaginationOptions(), len(summaries))
+	if err != nil {
+		return nil, err
+	}
+	return &packages.GetAvailablePackageSummariesResponse{
+		AvailablePackagesSummaries: summaries[start:end],
+		NextPageToken:              nextPageToken,
+	}, nil
+}
+
+func (s testPackagingPluginServer) GetAvailablePackageDetail(ctx context.Context, request *packages.GetAvailablePackageDetailRequest) (*packages.GetAvailablePackageDetailResponse, error) {
+	return &packages.GetAvailablePackageDetailResponse{
+		AvailablePackageDetail: s.availablePackageDetail,
+	}, nil
+}
+
+func (s testPackagingPluginServer) GetAvailablePackageVersions(ctx context.Context, request *packages.GetAvailablePackageVersionsRequest) (*packages.GetAvailablePackageVersionsResponse, error) {
+	return &packages.GetAvailablePackageVersionsResponse{
+		PackageAppVersions: s.availablePackageVersions,
+	}, nil
+}
+
+// WatchAvailablePackages streams the canned available packages as added
+// events and ends the watch.
+func (s testPackagingPluginServer) WatchAvailablePackages(request *packages.WatchAvailablePackagesRequest, stream packages.PackagesService_WatchAvailablePackagesServer) error {
+	for _, summary := range s.availablePackageSummaries {
+		err := stream.Send(&packages.WatchAvailablePackagesResponse{
+			Type:                    packages.WatchAvailablePackagesResponse_EVENT_TYPE_ADDED,
+			AvailablePackageSummary: summary,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return s.err
+}
+
+// testWatchStream records the events sent to the client of a watch.
+type testWatchStream struct {
+	grpc.ServerStream
+	ctx       context.Context
+	responses []*packages.WatchAvailablePackagesResponse
+}
+
+func (s *testWatchStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *testWatchStream) Send(response *packages.WatchAvailablePackagesResponse) error {
+	s.responses = append(s.responses, response)
+	return nil
+}
+
+func (s testPackagingPluginServer) GetInstalledPackageSummaries(ctx context.Context, request *packages.GetInstalledPackageSummariesRequest) (*packages.GetInstalledPackageSummariesResponse, error) {
+	time.Sleep(s.delay)
+	if s.err != nil {
+		return nil, s.err
+	}
+	start, end, nextPageToken, err := pageBounds(request.GetPaginationOptions(), len(s.installedPackageSummaries))
+	if err != nil {
+		return nil, err
+	}
+	return &packages.GetInstalledPackageSummariesResponse{
+		InstalledPackagesSummaries: s.installedPackageSummaries[start:end],
+		NextPageToken:              nextPageToken,
+	}, nil
+}
+
+func (s testPackagingPluginServer) GetInstalledPackageDetail(ctx context.Context, request *packages.GetInstalledPackageDetailRequest) (*packages.GetInstalledPackageDetailResponse, error) {
+	return &packages.GetInstalledPackageDetailResponse{
+		InstalledPackageDetail: s.installedPackageDetail,
+	}, nil
+}
+
+func (s testPackagingPluginServer) CreateInstalledPackage(ctx context.Context, request *packages.CreateInstalledPackageRequest) (*packages.CreateInstalledPackageResponse, error) {
+	return &packages.CreateInstalledPackageResponse{
+		InstalledPackageRef: s.createdInstalledPackageRef,
+	}, nil
+}
+
+func (s testPackagingPluginServer) DeleteInstalledPackage(ctx context.Context, request *packages.DeleteInstalledPackageRequest) (*packages.DeleteInstalledPackageResponse, error) {
+	return &packages.DeleteInstalledPackageResponse{}, nil
+}
+
+func TestGetAvailablePackageSummaries(t *testing.T) {
+	testCases := []struct {
+		name               string
+		configuredPlugins  []*pkgsPluginWithServer
+		expectedResponse   *packages.GetAvailablePackageSummariesResponse
+		expectedStatusCode codes.Code
+	}{
+		{
+			name: "it aggregates the available packages of all plugins, setting the plugin on each ref",
+			configuredPlugins: []*pkgsPluginWithServer{
+				{
+					plugin: mockedPackagingPlugin1,
+					server: testPackagingPluginServer{
+						availablePackageSummaries: []*packages.AvailablePackageSummary{
+							makeAvailablePackageSummary("pkg-1"),
+						},
+					},
+				},
+				{
+					plugin: mockedPackagingPlugin2,
+					server: testPackagingPluginServer{
+						availablePackageSummaries: []*packages.AvailablePackageSummary{
+							makeAvailablePackageSummary("pkg-2"),
+						},
+					},
+				},
+			},
+			expectedResponse: &packages.GetAvailablePackageSummariesResponse{
+				AvailablePackagesSummaries: []*packages.AvailablePackageSummary{
+					{
+						AvailablePackageRef: &packages.AvailablePackageReference{
+							Context:    &packages.Context{Namespace: "default"},
+							Identifier: "pkg-1",
+							Plugin:     mockedPackagingPlugin1,
+						},
+						Name: "pkg-1",
+					},
+					{
+						AvailablePackageRef: &packages.AvailablePackageReference{
+							Context:    &packages.Context{Namespace: "default"},
+							Identifier: "pkg-2",
+							Plugin:     mockedPackagingPlugin2,
+						},
+						Name: "pkg-2",
+					},
+				},
+			},
+		},
+		{
+			name: "it drops the results of a failing plugin, returning a plugin error",
+			configuredPlugins: []*pkgsPluginWithServer{
+				{
+					plugin: mockedPackagingPlugin1,
+					server: testPackagingPluginServer{
+						err: status.Errorf(codes.Internal, "Bang!"),
+					},
+				},
+				{
+					plugin: mockedPackagingPlugin2,
+					server: testPackagingPluginServer{
+						availablePackageSummaries: []*packages.AvailablePackageSummary{
+							makeAvailablePackageSummary("pkg-2"),
+						},
+					},
+				},
+			},
+			expectedResponse: &packages.GetAvailablePackageSummariesResponse{
+				AvailablePackagesSummaries: []*packages.AvailablePackageSummary{
+					{
+						AvailablePackageRef: &packages.AvailablePackageReference{
+							Context:    &packages.Context{Namespace: "default"},
+							Identifier: "pkg-2",
+							Plugin:     mockedPackagingPlugin2,
+						},
+						Name: "pkg-2",
+					},
+				},
+				PluginErrors: []*packages.PluginError{
+					{
+						Plugin:       mockedPackagingPlugin1,
+						StatusCode:   int32(codes.Internal),
+						ErrorMessage: "Bang!",
+					},
+				},
+			},
+		},
+		{
+			name: "it drops the results of a plugin which does not respond in time",
+			configuredPlugins: []*pkgsPluginWithServer{
+				{
+					plugin: mockedPackagingPlugin1,
+					server: testPackagingPluginServer{
+						availablePackageSummaries: []*packages.AvailablePackageSummary{
+							makeAvailablePackageSummary("pkg-1"),
+						},
+					},
+				},
+				{
+					plugin: mockedPackagingPlugin2,
+					server: testPackagingPluginServer{
+						availablePackageSummaries: []*packages.AvailablePackageSummary{
+							makeAvailablePackageSummary("pkg-2"),
+						},
+						delay: time.Second,
+					},
+				},
+			},
+			expectedResponse: &packages.GetAvailablePackageSummariesResponse{
+				AvailablePackagesSummaries: []*packages.AvailablePackageSummary{
+					{
+						AvailablePackageRef: &packages.AvailablePackageReference{
+							Context:    &packages.Context{Namespace: "default"},
+							Identifier: "pkg-1",
+							Plugin:     mockedPackagingPlugin1,
+						},
+						Name: "pkg-1",
+					},
+				},
+				PluginErrors: []*packages.PluginError{
+					{
+						Plugin:       mockedPackagingPlugin2,
+						StatusCode:   int32(codes.DeadlineExceeded),
+						ErrorMessage: "plugin mock2/v1alpha1 did not respond in time: context deadline exceeded",
+					},
+				},
+			},
+		},
+		{
+			name: "it returns an error when all plugins fail",
+			configuredPlugins: []*pkgsPluginWithServer{
+				{
+					plugin: mockedPackagingPlugin1,
+					server: testPackagingPluginServer{
+						err: status.Errorf(codes.PermissionDenied, "Bang!"),
+					},
+				},
+				{
+					plugin: mockedPackagingPlugin2,
+					server: testPackagingPluginServer{
+						err: status.Errorf(codes.Internal, "Bang!"),
+					},
+				},
+			},
+			expectedStatusCode: codes.PermissionDenied,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := NewPackagesServer(tc.configuredPlugins, 50*time.Millisecond)
+
+			response, err := server.GetAvailablePackageSummaries(context.Background(), &packages.GetAvailablePackageSummariesRequest{
+				Context: &packages.Context{Namespace: "default"},
+			})
+
+			if got, want := status.Code(err), tc.expectedStatusCode; got != want {
+				t.Fatalf("got: %+v, want: %+v, err: %+v", got, want, err)
+			}
+			if tc.expectedStatusCode != codes.OK {
+				return
+			}
+
+			if got, want := response, tc.expectedResponse; !cmp.Equal(got, want, ignoreUnexportedOpts) {
+				t.Errorf("mismatch (-want +got):\n%s", cmp.Diff(want, got, ignoreUnexportedOpts))
+			}
+		})
+	}
+}
+
+func TestGetAvailablePackageSummariesPagination(t *testing.T) {
+	testCases := []struct {
+		name              string
+		configuredPlugins []*pkgsPluginWithServer
+		pageSize          int32
+		sortOptions       *packages.SortOptions
+		expectedPages     [][]string
+	}{
+		{
+			name: "it pages through the merged results of all plugins",
+			configuredPlugins: []*pkgsPluginWithServer{
+				{
+					plugin: mockedPackagingPlugin1,
+					server: testPackagingPluginServer{
+						availablePackageSummaries: []*packages.AvailablePackageSummary{
+							makeAvailablePackageSummary("pkg-1a"),
+							makeAvailablePackageSummary("pkg-1b"),
+							makeAvailablePackageSummary("pkg-1c"),
+						},
+					},
+				},
+				{
+					plugin: mockedPackagingPlugin2,
+					server: testPackagingPluginServer{
+						availablePackageSummaries: []*packages.AvailablePackageSummary{
+							makeAvailablePackageSummary("pkg-2a"),
+							makeAvailablePackageSummary("pkg-2b"),
+						},
+					},
+				},
+			},
+			pageSize: 2,
+			expectedPages: [][]string{
+				{"pkg-1a", "pkg-1b"},
+				{"pkg-1c", "pkg-2a"},
+				{"pkg-2b"},
+			},
+		},
+		{
+			name: "it resumes a plugin part-way through its page of results",
+			configuredPlugins: []*pkgsPluginWithServer{
+				{
+					plugin: mockedPackagingPlugin1,
+					server: testPackagingPluginServer{
+						availablePackageSummaries: []*packages.AvailablePackageSummary{
+							makeAvailablePackageSummary("pkg-1a"),
+						},
+					},
+				},
+				{
+					plugin: mockedPackagingPlugin2,
+					server: testPackagingPluginServer{
+						availablePackageSummaries: []*packages.AvailablePackageSummary{
+							makeAvailablePackageSummary("pkg-2a"),
+							makeAvailablePackageSummary("pkg-2b"),
+							makeAvailablePackageSummary("pkg-2c"),
+						},
+					},
+				},
+			},
+			pageSize: 2,
+			expectedPages: [][]string{
+				{"pkg-1a", "pkg-2a"},
+				{"pkg-2b"},
+				{"pkg-2c"},
+			},
+		},
+		{
+			name: "it retries the only plugin with further results after it failed",
+			configuredPlugins: []*pkgsPluginWithServer{
+				{
+					plugin: mockedPackagingPlugin1,
+					server: testPackagingPluginServer{
+						availablePackageSummaries: []*packages.AvailablePackageSummary{
+							makeAvailablePackageSummary("pkg-1a"),
+							makeAvailablePackageSummary("pkg-1b"),
+							makeAvailablePackageSummary("pkg-1c"),
+						},
+					},
+				},
+				{
+					plugin: mockedPackagingPlugin2,
+					server: testPackagingPluginServer{
+						availablePackageSummaries: []*packages.AvailablePackageSummary{
+							makeAvailablePackageSummary("pkg-2a"),
+							makeAvailablePackageSummary("pkg-2b"),
+							makeAvailablePackageSummary("pkg-2c"),
+						},
+						failingCall: &failingCall{call: 2, err: status.Errorf(codes.Unavailable, "Bang!")},
+					},
+				},
+			},
+			pageSize: 2,
+			// The second plugin fails when the first one returns its last result.
+			expectedPages: [][]string{
+				{"pkg-1a", "pkg-1b"},
+				{"pkg-1c"},
+				{"pkg-2a", "pkg-2b"},
+				{"pkg-2c"},
+			},
+		},
+		{
+			name: "it merges the sorted results of all plugins",
+			configuredPlugins: []*pkgsPluginWithServer{
+				{
+					plugin: mockedPackagingPlugin1,
+					server: testPackagingPluginServer{
+						availablePackageSummaries: []*packages.AvailablePackageSummary{
+							makeAvailablePackageSummary("pkg-d"),
+							makeAvailablePackageSummary("pkg-a"),
+							makeAvailablePackageSummary("pkg-c"),
+						},
+					},
+				},
+				{
+					plugin: mockedPackagingPlugin2,
+					server: testPackagingPluginServer{
+						availablePackageSummaries: []*packages.AvailablePackageSummary{
+							makeAvailablePackageSummary("pkg-e"),
+							makeAvailablePackageSummary("pkg-b"),
+						},
+					},
+				},
+			},
+			pageSize: 2,
+			sortOptions: &packages.SortOptions{
+				Field:     packages.SortOptions_SORT_FIELD_NAME,
+				Direction: packages.SortOptions_SORT_DIRECTION_DESCENDING,
+			},
+			// The second page is short as the next result of the first plugin
+			// is not known until its next page is requested.
+			expectedPages: [][]string{
+				{"pkg-e", "pkg-d"},
+				{"pkg-c"},
+				{"pkg-b", "pkg-a"},
+			},
+		},
+		{
+			name: "it merges the versions which are not semantic versions last",
+			configuredPlugins: []*pkgsPluginWithServer{
+				{
+					plugin: mockedPackagingPlugin1,
+					server: testPackagingPluginServer{
+						// In the order of the database of the helm plugin.
+						availablePackageSummaries: []*packages.AvailablePackageSummary{
+							makeVersionedAvailablePackageSummary("helm-a", "1.0.0"),
+							makeVersionedAvailablePackageSummary("helm-b", "10.0.0"),
+							makeVersionedAvailablePackageSummary("helm-c", "latest"),
+						},
+						presorted: true,
+					},
+				},
+				{
+					plugin: mockedPackagingPlugin2,
+					server: testPackagingPluginServer{
+						availablePackageSummaries: []*packages.AvailablePackageSummary{
+							makeVersionedAvailablePackageSummary("other-a", "2.0.0"),
+							makeVersionedAvailablePackageSummary("other-b", "alpha"),
+							makeVersionedAvailablePackageSummary("other-c", "9.0.0-beta"),
+						},
+					},
+				},
+			},
+			pageSize:    2,
+			sortOptions: &packages.SortOptions{Field: packages.SortOptions_SORT_FIELD_LATEST_VERSION},
+			expectedPages: [][]string{
+				{"helm-a", "other-a"},
+				{"other-c"},
+				{"helm-b"},
+				{"other-b", "helm-c"},
+			},
+		},
+		{
+			name: "it merges the versions which are not semantic versions last in descending order",
+			configuredPlugins: []*pkgsPluginWithServer{
+				{
+					plugin: mockedPackagingPlugin1,
+					server: testPackagingPluginServer{
+						// In the order of the database of the helm plugin.
+						availablePackageSummaries: []*packages.AvailablePackageSummary{
+							makeVersionedAvailablePackageSummary("helm-b", "10.0.0"),
+							makeVersionedAvailablePackageSummary("helm-a", "1.0.0"),
+							makeVersionedAvailablePackageSummary("helm-c", "latest"),
+						},
+						presorted: true,
+					},
+				},
+				{
+					plugin: mockedPackagingPlugin2,
+					server: testPackagingPluginServer{
+						availablePackageSummaries: []*packages.AvailablePackageSummary{
+							makeVersionedAvailablePackageSummary("other-a", "2.0.0"),
+							makeVersionedAvailablePackageSummary("other-b", "alpha"),
+							makeVersionedAvailablePackageSummary("other-c", "9.0.0-beta"),
+						},
+					},
+				},
+			},
+			pageSize: 2,
+			sortOptions: &packages.SortOptions{
+				Field:     packages.SortOptions_SORT_FIELD_LATEST_VERSION,
+				Direction: packages.SortOptions_SORT_DIRECTION_DESCENDING,
+			},
+			expectedPages: [][]string{
+				{"helm-b", "other-c"},
+				{"other-a"},
+				{"helm-a"},
+				{"helm-c", "other-b"},
+			},
+		},
+		{
+			name: "it returns all results in a single page without a page size",
+			configuredPlugins: []*pkgsPluginWithServer{
+				{
+					plugin: mockedPackagingPlugin1,
+					server: testPackagingPluginServer{
+						availablePackageSummaries: []*packages.AvailablePackageSummary{
+							makeAvailablePackageSummary("pkg-1a"),
+							makeAvailablePackageSummary("pkg-1b"),
+						},
+					},
+				},
+				{
+					plugin: mockedPackagingPlugin2,
+					server: testPackagingPluginServer{
+						availablePackageSummaries: []*packages.AvailablePackageSummary{
+							makeAvailablePackageSummary("pkg-2a"),
+						},
+					},
+				},
+			},
+			expectedPages: [][]string{
+				{"pkg-1a", "pkg-1b", "pkg-2a"},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := NewPackagesServer(tc.configuredPlugins, 0)
+
+			pages := [][]string{}
+			pageToken := ""
+			for {
+				response, err := server.GetAvailablePackageSummaries(context.Background(), &packages.GetAvailablePackageSummariesRequest{
+					Context: &packages.Context{Namespace: "default"},
+					PaginationOptions: &packages.PaginationOptions{
+						PageToken: pageToken,
+						PageSize:  tc.pageSize,
+					},
+					SortOptions: tc.sortOptions,
+				})
+				if err != nil {
+					t.Fatalf("%+v", err)
+				}
+				page := []string{}
+				for _, pkg := range response.AvailablePackagesSummaries {
+					page = append(page, pkg.AvailablePackageRef.Identifier)
+				}
+				pages = append(pages, page)
+
+				pageToken = response.NextPageToken
+				if pageToken == "" {
+					break
+				}
+				if len(pages) > len(tc.expectedPages) {
+					t.Fatalf("unexpected next page token after %d pages: %q", len(pages), pageToken)
+				}
+			}
+
+			if got, want := pages, tc.expectedPages; !cmp.Equal(got, want) {
+				t.Errorf("mismatch (-want +got):\n%s", cmp.Diff(want, got))
+			}
+		})
+	}
+}
+
+func TestGetPackageSummariesInvalidPageToken(t *testing.T) {
+	server := NewPackagesServer([]*pkgsPluginWithServer{
+		{
+			plugin: mockedPackagingPlugin1,
+			server: testPackagingPluginServer{},
+		},
+	}, 0)
+	paginationOptions := &packages.PaginationOptions{
+		PageToken: "not a valid page token",
+		PageSize:  1,
+	}
+
+	_, err := server.GetAvailablePackageSummaries(context.Background(), &packages.GetAvailablePackageSummariesRequest{
+		PaginationOptions: paginationOptions,
+	})
+	if got, want := status.Code(err), codes.InvalidArgument; got != want {
+		t.Errorf("got: %+v, want: %+v, err: %+v", got, want, err)
+	}
+
+	_, err = server.GetInstalledPackageSummaries(context.Background(), &packages.GetInstalledPackageSummariesRequest{
+		PaginationOptions: paginationOptions,
+	})
+	if got, want := status.Code(err), codes.InvalidArgument; got != want {
+		t.Errorf("got: %+v, want: %+v, err: %+v", got, want, err)
+	}
+}
+
+func TestGetInstalledPackageSummaries(t *testing.T) {
+	testCases := []struct {
+		name              string
+		configuredPlugins []*pkgsPluginWithServer
+		expectedResponse  *packages.GetInstalledPackageSummariesResponse
+	}{
+		{
+			name: "it aggregates the installed packages of all plugins, setting the plugin on each ref",
+			configuredPlugins: []*pkgsPluginWithServer{
+				{
+					plugin: mockedPackagingPlugin1,
+					server: testPackagingPluginServer{
+						installedPackageSummaries: []*packages.InstalledPackageSummary{
+							makeInstalledPackageSummary("pkg-1"),
+						},
+					},
+				},
+				{
+					plugin: mockedPackagingPlugin2,
+					server: testPackagingPluginServer{
+						installedPackageSummaries: []*packages.InstalledPackageSummary{
+							makeInstalledPackageSummary("pkg-2"),
+							makeInstalledPackageSummary("pkg-3"),
+						},
+					},
+				},
+			},
+			expectedResponse: &packages.GetInstalledPackageSummariesResponse{
+				InstalledPackagesSummaries: []*packages.InstalledPackageSummary{
+					{
+						InstalledPackageRef: &packages.InstalledPackageReference{
+							Context:    &packages.Context{Namespace: "default"},
+							Identifier: "pkg-1",
+							Plugin:     mockedPackagingPlugin1,
+						},
+						Name: "pkg-1",
+					},
+					{
+						InstalledPackageRef: &packages.InstalledPackageReference{
+							Context:    &packages.Context{Namespace: "default"},
+							Identifier: "pkg-2",
+							Plugin:     mockedPackagingPlugin2,
+						},
+						Name: "pkg-2",
+					},
+					{
+						InstalledPackageRef: &packages.InstalledPackageReference{
+							Context:    &packages.Context{Namespace: "default"},
+							Identifier: "pkg-3",
+							Plugin:     mockedPackagingPlugin2,
+						},
+						Name: "pkg-3",
+					},
+				},
+			},
+		},
+		{
+			name: "it drops the results of a failing plugin, returning a plugin error",
+			configuredPlugins: []*pkgsPluginWithServer{
+				{
+					plugin: mockedPackagingPlugin1,
+					server: testPackagingPluginServer{
+						installedPackageSummaries: []*packages.InstalledPackageSummary{
+							makeInstalledPackageSummary("pkg-1"),
+						},
+					},
+				},
+				{
+					plugin: mockedPackagingPlugin2,
+					server: testPackagingPluginServer{
+						err: status.Errorf(codes.Unavailable, "Bang!"),
+					},
+				},
+			},
+			expectedResponse: &packages.GetInstalledPackageSummariesResponse{
+				InstalledPackagesSummaries: []*packages.InstalledPackageSummary{
+					{
+						InstalledPackageRef: &packages.InstalledPackageReference{
+							Context:    &packages.Context{Namespace: "default"},
+							Identifier: "pkg-1",
+							Plugin:     mockedPackagingPlugin1,
+						},
+						Name: "pkg-1",
+					},
+				},
+				PluginErrors: []*packages.PluginError{
+					{
+						Plugin:       mockedPackagingPlugin2,
+						StatusCode:   int32(codes.Unavailable),
+						ErrorMessage: "Bang!",
+					},
+				},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := NewPackagesServer(tc.configuredPlugins, 0)
+
+			response, err := server.GetInstalledPackageSummaries(context.Background(), &packages.GetInstalledPackageSummariesRequest{
+				Context: &packages.Context{Namespace: "default"},
+			})
+			if err != nil {
+				t.Fatalf("%+v", err)
+			}
+
+			if got, want := response, tc.expectedResponse; !cmp.Equal(got, want, ignoreUnexportedOpts) {
+				t.Errorf("mismatch (-want +got):\n%s", cmp.Diff(want, got, ignoreUnexportedOpts))
+			}
+		})
+	}
+}
+
+func TestGetAvailablePackageDetail(t *testing.T) {
+	configuredPlugins := []*pkgsPluginWithServer{
+		{
+			plugin: mockedPackagingPlugin1,
+			server: testPackagingPluginServer{
+				availablePackageDetail: &packages.AvailablePackageDetail{
+					AvailablePackageRef: &packages.AvailablePackageReference{Identifier: "pkg-1"},
+					Name:                "pkg-1",
+				},
+			},
+		},
+		{
+			plugin: mockedPackagingPlugin2,
+			server: testPackagingPluginServer{
+				availablePackageDetail: &packages.AvailablePackageDetail{
+					AvailablePackageRef: &packages.AvailablePackageReference{Identifier: "pkg-2"},
+					Name:                "pkg-2",
+				},
+			},
+		},
+	}
+
+	testCases := []struct {
+		name             string
+		request          *packages.GetAvailablePackageDetailRequest
+		expectedResponse *packages.GetAvailablePackageDetailResponse
+		statusCode       codes.Code
+	}{
+		{
+			name: "it routes the request to the plugin in the available package reference",
+			request: &packages.GetAvailablePackageDetailRequest{
+				AvailablePackageRef: &packages.AvailablePackageReference{
+					Identifier: "pkg-2",
+					Plugin:     mockedPackagingPlugin2,
+				},
+			},
+			expectedResponse: &packages.GetAvailablePackageDetailResponse{
+				AvailablePackageDetail: &packages.AvailablePackageDetail{
+					AvailablePackageRef: &packages.AvailablePackageReference{
+						Identifier: "pkg-2",
+						Plugin:     mockedPackagingPlugin2,
+					},
+					Name: "pkg-2",
+				},
+			},
+		},
+		{
+			name: "it returns an invalid argument error if the plugin is missing",
+			request: &packages.GetAvailablePackageDetailRequest{
+				AvailablePackageRef: &packages.AvailablePackageReference{
+					Identifier: "pkg-2",
+				},
+			},
+			statusCode: codes.InvalidArgument,
+		},
+		{
+			name: "it returns an invalid argument error if the plugin is not registered",
+			request: &packages.GetAvailablePackageDetailRequest{
+				AvailablePackageRef: &packages.AvailablePackageReference{
+					Identifier: "pkg-2",
+					Plugin:     makeDefaultTestPackagingPlugin("unknown"),
+				},
+			},
+			statusCode: codes.InvalidArgument,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := NewPackagesServer(configuredPlugins, 0)
+
+			response, err := server.GetAvailablePackageDetail(context.Background(), tc.request)
+
+			if got, want := status.Code(err), tc.statusCode; got != want {
+				t.Fatalf("got: %+v, want: %+v, err: %+v", got, want, err)
+			}
+			if tc.statusCode != codes.OK {
+				return
+			}
+
+			if got, want := response, tc.expectedResponse; !cmp.Equal(got, want, ignoreUnexportedOpts) {
+				t.Errorf("mismatch (-want +got):\n%s", cmp.Diff(want, got, ignoreUnexportedOpts))
+			}
+		})
+	}
+}
+
+func TestGetAvailablePackageVersions(t *testing.T) {
+	configuredPlugins := []*pkgsPluginWithServer{
+		{
+			plugin: mockedPackagingPlugin1,
+			server: testPackagingPluginServer{
+				availablePackageVersions: []*packages.GetAvailablePackageVersionsResponse_PackageAppVersion{
+					{PkgVersion: "1.0.0", AppVersion: "2.0.0"},
+				},
+			},
+		},
+		{
+			plugin: mockedPackagingPlugin2,
+			server: testPackagingPluginServer{
+				availablePackageVersions: []*packages.GetAvailablePackageVersionsResponse_PackageAppVersion{
+					{PkgVersion: "3.1.0", AppVersion: "4.0.1"},
+					{PkgVersion: "3.0.0", AppVersion: "4.0.0"},
+				},
+			},
+		},
+	}
+
+	testCases := []struct {
+		name             string
+		request          *packages.GetAvailablePackageVersionsRequest
+		expectedResponse *packages.GetAvailablePackageVersionsResponse
+		statusCode       codes.Code
+	}{
+		{
+			name: "it routes the request to the plugin in the available package reference",
+			request: &packages.GetAvailablePackageVersionsRequest{
+				AvailablePackageRef: &packages.AvailablePackageReference{
+					Identifier: "pkg-2",
+					Plugin:     mockedPackagingPlugin2,
+				},
+			},
+			expectedResponse: &packages.GetAvailablePackageVersionsResponse{
+				PackageAppVersions: []*packages.GetAvailablePackageVersionsResponse_PackageAppVersion{
+					{PkgVersion: "3.1.0", AppVersion: "4.0.1"},
+					{PkgVersion: "3.0.0", AppVersion: "4.0.0"},
+				},
+			},
+		},
+		{
+			name: "it returns an invalid argument error if the plugin is missing",
+			request: &packages.GetAvailablePackageVersionsRequest{
+				AvailablePackageRef: &packages.AvailablePackageReference{
+					Identifier: "pkg-2",
+				},
+			},
+			statusCode: codes.InvalidArgument,
+		},
+		{
+			name: "it returns an invalid argument error if the plugin is not registered",
+			request: &packages.GetAvailablePackageVersionsRequest{
+				AvailablePackageRef: &packages.AvailablePackageReference{
+					Identifier: "pkg-2",
+					Plugin:     makeDefaultTestPackagingPlugin("unknown"),
+				},
+			},
+			statusCode: codes.InvalidArgument,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := NewPackagesServer(configuredPlugins, 0)
+
+			response, err := server.GetAvailablePackageVersions(context.Background(), tc.request)
+
+			if got, want := status.Code(err), tc.statusCode; got != want {
+				t.Fatalf("got: %+v, want: %+v, err: %+v", got, want, err)
+			}
+			if tc.statusCode != codes.OK {
+				return
+			}
+
+			if got, want := response, tc.expectedResponse; !cmp.Equal(got, want, ignoreUnexportedOpts) {
+				t.Errorf("mismatch (-want +got):\n%s", cmp.Diff(want, got, ignoreUnexportedOpts))
+			}
+		})
+	}
+}
+
+func TestWatchAvailablePackages(t *testing.T) {
+	testCases := []struct {
+		name              string
+		configuredPlugins []*pkgsPluginWithServer
+		expectedResponses []*packages.WatchAvailablePackagesResponse
+		statusCode        codes.Code
+	}{
+		{
+			name: "it streams the events of all the plugins with the plugin set on the refs",
+			configuredPlugins: []*pkgsPluginWithServer{
+				{
+					plugin: mockedPackagingPlugin1,
+					server: testPackagingPluginServer{
+						availablePackageSummaries: []*packages.AvailablePackageSummary{
+							makeAvailablePackageSummary("pkg-1"),
+						},
+					},
+				},
+				{
+					plugin: mockedPackagingPlugin2,
+					server: testPackagingPluginServer{
+						availablePackageSummaries: []*packages.AvailablePackageSummary{
+							makeAvailablePackageSummary("pkg-2"),
+						},
+					},
+				},
+			},
+			expectedResponses: []*packages.WatchAvailablePackagesResponse{
+				{
+					Type: packages.WatchAvailablePackagesResponse_EVENT_TYPE_ADDED,
+					AvailablePackageSummary: &packages.AvailablePackageSummary{
+						AvailablePackageRef: &packages.AvailablePackageReference{
+							Context:    &packages.Context{Namespace: "default"},
+							Identifier: "pkg-1",
+							Plugin:     mockedPackagingPlugin1,
+						},
+						Name: "pkg-1",
+					},
+				},
+				{
+					Type: packages.WatchAvailablePackagesResponse_EVENT_TYPE_ADDED,
+					AvailablePackageSummary: &packages.AvailablePackageSummary{
+						AvailablePackageRef: &packages.AvailablePackageReference{
+							Context:    &packages.Context{Namespace: "default"},
+							Identifier: "pkg-2",
+							Plugin:     mockedPackagingPlugin2,
+						},
+						Name: "pkg-2",
+					},
+				},
+			},
+		},
+		{
+			name: "it ignores the plugins which do not support watching",
+			configuredPlugins: []*pkgsPluginWithServer{
+				{
+					plugin: mockedPackagingPlugin1,
+					server: testPackagingPluginServer{
+						err: status.Errorf(codes.Unimplemented, "not supported"),
+					},
+				},
+				{
+					plugin: mockedPackagingPlugin2,
+					server: testPackagingPluginServer{
+						availablePackageSummaries: []*packages.AvailablePackageSummary{
+							makeAvailablePackageSummary("pkg-2"),
+						},
+					},
+				},
+			},
+			expectedResponses: []*packages.WatchAvailablePackagesResponse{
+				{
+					Type: packages.WatchAvailablePackagesResponse_EVENT_TYPE_ADDED,
+					AvailablePackageSummary: &packages.AvailablePackageSummary{
+						AvailablePackageRef: &packages.AvailablePackageReference{
+							Context:    &packages.Context{Namespace: "default"},
+							Identifier: "pkg-2",
+							Plugin:     mockedPackagingPlugin2,
+						},
+						Name: "pkg-2",
+					},
+				},
+			},
+		},
+		{
+			name: "it returns an unimplemented error if no plugin supports watching",
+			configuredPlugins: []*pkgsPluginWithServer{
+				{
+					plugin: mockedPackagingPlugin1,
+					server: testPackagingPluginServer{
+						err: status.Errorf(codes.Unimplemented, "not supported"),
+					},
+				},
+			},
+			statusCode: codes.Unimplemented,
+		},
+		{
+			name: "it ends the watch with the error of a failing plugin",
+			configuredPlugins: []*pkgsPluginWithServer{
+				{
+					plugin: mockedPackagingPlugin1,
+					server: testPackagingPluginServer{
+						err: status.Errorf(codes.PermissionDenied, "forbidden"),
+					},
+				},
+				{
+					plugin: mockedPackagingPlugin2,
+					server: testPackagingPluginServer{},
+				},
+			},
+			statusCode: codes.PermissionDenied,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := NewPackagesServer(tc.configuredPlugins, 0)
+			stream := &testWatchStream{ctx: context.Background()}
+
+			err := server.WatchAvailablePackages(&packages.WatchAvailablePackagesRequest{}, stream)
+
+			if got, want := status.Code(err), tc.statusCode; got != want {
+				t.Fatalf("got: %+v, want: %+v, err: %+v", got, want, err)
+			}
+			if tc.statusCode != codes.OK {
+				return
+			}
+
+			// The plugins stream their events concurrently.
+			sort.Slice(stream.responses, func(i, j int) bool {
+				return stream.responses[i].AvailablePackageSummary.Name < stream.responses[j].AvailablePackageSummary.Name
+			})
+			if got, want := stream.responses, tc.expectedResponses; !cmp.Equal(got, want, ignoreUnexportedOpts) {
+				t.Errorf("mismatch (-want +got):\n%s", cmp.Diff(want, got, ignoreUnexportedOpts))
+			}
+		})
+	}
+}
+
+func TestGetInstalledPackageDetail(t *testing.T) {
+	configuredPlugins := []*pkgsPluginWithServer{
+		{
+			plugin: mockedPackagingPlugin1,
+			server: testPackagingPluginServer{
+				installedPackageDetail: &packages.InstalledPackageDetail{
+					InstalledPackageRef: &packages.InstalledPackageReference{Identifier: "pkg-1"},
+					Name:                "pkg-1",
+				},
+			},
+		},
+		{
+			plugin: mockedPackagingPlugin2,
+			server: testPackagingPluginServer{
+				installedPackageDetail: &packages.InstalledPackageDetail{
+					InstalledPackageRef: &packages.InstalledPackageReference{Identifier: "pkg-2"},
+					Name:                "pkg-2",
+				},
+			},
+		},
+	}
+
+	testCases := []struct {
+		name             string
+		request          *packages.GetInstalledPackageDetailRequest
+		expectedResponse *packages.GetInstalledPackageDetailResponse
+		statusCode       codes.Code
+	}{
+		{
+			name: "it routes the request to the plugin in the installed package reference",
+			request: &packages.GetInstalledPackageDetailRequest{
+				InstalledPackageRef: &packages.InstalledPackageReference{
+					Identifier: "pkg-2",
+					Plugin:     mockedPackagingPlugin2,
+				},
+			},
+			expectedResponse: &packages.GetInstalledPackageDetailResponse{
+				InstalledPackageDetail: &packages.InstalledPackageDetail{
+					InstalledPackageRef: &packages.InstalledPackageReference{
+						Identifier: "pkg-2",
+						Plugin:     mockedPackagingPlugin2,
+					},
+					Name: "pkg-2",
+				},
+			},
+		},
+		{
+			name: "it returns an invalid argument error if the plugin is missing",
+			request: &packages.GetInstalledPackageDetailRequest{
+				InstalledPackageRef: &packages.InstalledPackageReference{
+					Identifier: "pkg-2",
+				},
+			},
+			statusCode: codes.InvalidArgument,
+		},
+		{
+			name: "it returns an invalid argument error if the plugin is not registered",
+			request: &packages.GetInstalledPackageDetailRequest{
+				InstalledPackageRef: &packages.InstalledPackageReference{
+					Identifier: "pkg-2",
+					Plugin:     makeDefaultTestPackagingPlugin("unknown"),
+				},
+			},
+			statusCode: codes.InvalidArgument,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := NewPackagesServer(configuredPlugins, 0)
+
+			response, err := server.GetInstalledPackageDetail(context.Background(), tc.request)
+
+			if got, want := status.Code(err), tc.statusCode; got != want {
+				t.Fatalf("got: %+v, want: %+v, err: %+v", got, want, err)
+			}
+			if tc.statusCode != codes.OK {
+				return
+			}
+
+			if got, want := response, tc.expectedResponse; !cmp.Equal(got, want, ignoreUnexportedOpts) {
+				t.Errorf("mismatch (-want +got):\n%s", cmp.Diff(want, got, ignoreUnexportedOpts))
+			}
+		})
+	}
+}
+
+func TestCreateInstalledPackage(t *testing.T) {
+	configuredPlugins := []*pkgsPluginWithServer{
+		{
+			plugin: mockedPackagingPlugin1,
+			server: testPackagingPluginServer{
+				createdInstalledPackageRef: &packages.InstalledPackageReference{
+					Context:    &packages.Context{Namespace: "default"},
+					Identifier: "my-release",
+				},
+			},
+		},
+	}
+
+	testCases := []struct {
+		name             string
+		request          *packages.CreateInstalledPackageRequest
+		expectedResponse *packages.CreateInstalledPackageResponse
+		statusCode       codes.Code
+	}{
+		{
+			name: "it routes the request to the plugin in the available package reference",
+			request: &packages.CreateInstalledPackageRequest{
+				AvailablePackageRef: &packages.AvailablePackageReference{
+					Identifier: "bitnami/apache",
+					Plugin:     mockedPackagingPlugin1,
+				},
+				TargetContext: &packages.Context{Namespace: "default"},
+				Name:          "my-release",
+			},
+			expectedResponse: &packages.CreateInstalledPackageResponse{
+				InstalledPackageRef: &packages.InstalledPackageReference{
+					Context:    &packages.Context{Namespace: "default"},
+					Identifier: "my-release",
+					Plugin:     mockedPackagingPlugin1,
+				},
+			},
+		},
+		{
+			name: "it returns an invalid argument error if the available package reference is missing",
+			request: &packages.CreateInstalledPackageRequest{
+				TargetContext: &packages.Context{Namespace: "default"},
+				Name:          "my-release",
+			},
+			statusCode: codes.InvalidArgument,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := NewPackagesServer(configuredPlugins, 0)
+
+			response, err := server.CreateInstalledPackage(context.Background(), tc.request)
+
+			if got, want := status.Code(err), tc.statusCode; got != want {
+				t.Fatalf("got: %+v, want: %+v, err: %+v", got, want, err)
+			}
+			if tc.statusCode != codes.OK {
+				return
+			}
+
+			if got, want := response, tc.expectedResponse; !cmp.Equal(got, want, ignoreUnexportedOpts) {
+				t.Errorf("mismatch (-want +got):\n%s", cmp.Diff(want, got, ignoreUnexportedOpts))
+			}
+		})
+	}
+}
+
+func TestUpdateAndDeleteInstalledPackage(t *testing.T) {
+	configuredPlugins := []*pkgsPluginWithServer{
+		{
+			plugin: mockedPackagingPlugin1,
+			server: testPackagingPluginServer{},
+		},
+	}
+	installedRef := &packages.InstalledPackageReference{
+		Context:    &packages.Context{Namespace: "default"},
+		Identifier: "my-release",
+		Plugin:     mockedPackagingPlugin1,
+	}
+	server := NewPackagesServer(configuredPlugins, 0)
+
+	// The test plugin server does not implement UpdateInstalledPackage, so
+	// the request should be routed through to its Unimplemented default.
+	_, err := server.UpdateInstalledPackage(context.Background(), &packages.UpdateInstalledPackageRequest{
+		InstalledPackageRef: installedRef,
+	})
+	if got, want := status.Code(err), codes.Unimplemented; got != want {
+		t.Errorf("got: %+v, want: %+v, err: %+v", got, want, err)
+	}
+
+	_, err = server.DeleteInstalledPackage(context.Background(), &packages.DeleteInstalledPackageRequest{
+		InstalledPackageRef: installedRef,
+	})
+	if err != nil {
+		t.Errorf("%+v", err)
+	}
+
+	_, err = server.DeleteInstalledPackage(context.Background(), &packages.DeleteInstalledPackageRequest{})
+	if got, want := status.Code(err), codes.InvalidArgument; got != want {
+		t.Errorf("got: %+v, want: %+v, err: %+v", got, want, err)
+	}
+}
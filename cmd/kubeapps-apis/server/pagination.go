@@ -0,0 +1,208 @@
+/*
+Copyright © 2021 VMware
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package server
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+
+	packages "github.com/kubeapps/kubeapps/cmd/kubeapps-apis/gen/core/packages/v1alpha1"
+	plugins "github.com/kubeapps/kubeapps/cmd/kubeapps-apis/gen/core/plugins/v1alpha1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// pluginCursor records where the next page of results starts for a single
+// plugin: the page token to send to the plugin and the number of items of
+// the plugin's page for that token which were already returned to the client.
+type pluginCursor struct {
+	PageToken string `json:"t,omitempty"`
+	Skip      int    `json:"s,omitempty"`
+}
+
+// pageCursor is the decoded form of the opaque page token issued by the core
+// server when aggregating paginated results from several plugins. It is keyed
+// by plugin and only includes the plugins which may have further results.
+type pageCursor map[string]pluginCursor
+
+// pluginKey returns the key identifying a plugin in a page cursor.
+func pluginKey(plugin *plugins.Plugin) string {
+	return fmt.Sprintf("%s/%s", plugin.Name, plugin.Version)
+}
+
+// decodePageCursor returns the cursor encoded in the page token of a request.
+// An empty page token results in a cursor at the start of the results of
+// every registered plugin.
+func (s packagesServer) decodePageCursor(pageToken string) (pageCursor, error) {
+	cursor := pageCursor{}
+	if pageToken == "" {
+		for _, p := range s.plugins {
+			cursor[pluginKey(p.plugin)] = pluginCursor{}
+		}
+		return cursor, nil
+	}
+
+	cursorJSON, err := base64.RawURLEncoding.DecodeString(pageToken)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "Unable to interpret page token %q: %v", pageToken, err)
+	}
+	if err = json.Unmarshal(cursorJSON, &cursor); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "Unable to interpret page token %q: %v", pageToken, err)
+	}
+	for key, c := range cursor {
+		if c.Skip < 0 {
+			return nil, status.Errorf(codes.InvalidArgument, "Unable to interpret page token %q: invalid cursor for plugin %s", pageToken, key)
+		}
+	}
+	return cursor, nil
+}
+
+// encodePageCursor returns the opaque page token for the cursor, which is
+// empty when no plugin has further results.
+func encodePageCursor(cursor pageCursor) (string, error) {
+	if len(cursor) == 0 {
+		return "", nil
+	}
+	cursorJSON, err := json.Marshal(cursor)
+	if err != nil {
+		return "", status.Errorf(codes.Internal, "Unable to encode page token: %v", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(cursorJSON), nil
+}
+
+// pluginsInCursor returns the registered plugins which may have further
+// results according to the cursor, in the order of the registered plugins.
+func (s packagesServer) pluginsInCursor(cursor pageCursor) []*pkgsPluginWithServer {
+	pluginsWithServer := []*pkgsPluginWithServer{}
+	for _, p := range s.plugins {
+		if _, ok := cursor[pluginKey(p.plugin)]; ok {
+			pluginsWithServer = append(pluginsWithServer, p)
+		}
+	}
+	return pluginsWithServer
+}
+
+// paginationOptionsFor returns the pagination options to send to a plugin for
+// its cursor. Each plugin is asked for a full page since it is not known in
+// advance how many of its results will be consumed.
+func paginationOptionsFor(cursor pluginCursor, pageSize int32) *packages.PaginationOptions {
+	if cursor.PageToken == "" && pageSize == 0 {
+		return nil
+	}
+	return &packages.PaginationOptions{
+		PageToken: cursor.PageToken,
+		PageSize:  pageSize,
+	}
+}
+
+// pluginPage describes the results returned by a plugin for the cursor
+// with which it was requested.
+type pluginPage struct {
+	plugin        *plugins.Plugin
+	cursor        pluginCursor
+	numResults    int
+	nextPageToken string
+}
+
+// resultPosition identifies a result by the index of its plugin page and the
+// index of the result within that page.
+type resultPosition struct {
+	page  int
+	index int
+}
+
+// pageCollector collects the results of each plugin into a single page of the
+// requested size, recording the cursor for the following page as it goes.
+type pageCollector struct {
+	pageSize int
+	next     pageCursor
+	// hasMore is set when a plugin which responded may have further results,
+	// or when a plugin which failed is to be requested again.
+	hasMore bool
+}
+
+func newPageCollector(pageSize int32) *pageCollector {
+	return &pageCollector{
+		pageSize: int(pageSize),
+		next:     pageCursor{},
+	}
+}
+
+// merge returns the positions of the plugins' results which are included in
+// the page, in order, and records the cursor of each plugin for the following
+// page. Without a less function, results are included in the order of the
+// plugins. Otherwise the already sorted results of each plugin are merged,
+// stopping early if a plugin with further results has no more results in its
+// page, since its next result may sort before those of the other plugins.
+func (c *pageCollector) merge(pages []pluginPage, less func(a, b resultPosition) bool) []resultPosition {
+	next := make([]int, len(pages))
+	for i, p := range pages {
+		next[i] = p.cursor.Skip
+		if next[i] > p.numResults {
+			next[i] = p.numResults
+		}
+	}
+
+	merged := []resultPosition{}
+	for c.pageSize == 0 || len(merged) < c.pageSize {
+		selected := -1
+		blocked := false
+		for i, p := range pages {
+			if next[i] == p.numResults {
+				blocked = blocked || (less != nil && p.nextPageToken != "")
+				continue
+			}
+			if selected == -1 || (less != nil && less(resultPosition{i, next[i]}, resultPosition{selected, next[selected]})) {
+				selected = i
+			}
+		}
+		if selected == -1 || blocked {
+			break
+		}
+		merged = append(merged, resultPosition{selected, next[selected]})
+		next[selected]++
+	}
+
+	for i, p := range pages {
+		if next[i] < p.numResults {
+			c.next[pluginKey(p.plugin)] = pluginCursor{PageToken: p.cursor.PageToken, Skip: next[i]}
+			c.hasMore = true
+		} else if p.nextPageToken != "" {
+			c.next[pluginKey(p.plugin)] = pluginCursor{PageToken: p.nextPageToken}
+			c.hasMore = true
+		}
+	}
+	return merged
+}
+
+// retry keeps the cursor of a plugin which failed, so that it is requested
+// again for the following page, even if no other plugin has further results.
+// Without a page size, all the results were requested and there is no
+// following page.
+func (c *pageCollector) retry(plugin *plugins.Plugin, cursor pluginCursor) {
+	if c.pageSize == 0 {
+		return
+	}
+	c.next[pluginKey(plugin)] = cursor
+	c.hasMore = true
+}
+
+// nextPageToken returns the opaque token for the following page, which is
+// empty if none of the plugins has further results or is to be retried.
+func (c *pageCollector) nextPageToken() (string, error) {
+	if !c.hasMore {
+		return "", nil
+	}
+	return encodePageCursor(c.next)
+}
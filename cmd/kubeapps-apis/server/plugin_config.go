@@ -0,0 +1,62 @@
+/*
+Copyright © 2021 VMware
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package server
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+// PluginConfig is the configuration of a single plugin, read from the section
+// of the kubeapps-apis config file keyed by the plugin name, such as:
+//
+//	plugins:
+//	  fluxv2.packages:
+//	    redis:
+//	      addr: kubeapps-redis-master:6379
+//
+// The server does not know the configuration each plugin expects, so it is
+// handed to the plugin as is when it registers, and the plugin decodes and
+// validates it.
+type PluginConfig map[string]interface{}
+
+// Decode decodes the plugin configuration into the given pointer to a struct,
+// only setting the fields present in the configuration so that the struct can
+// be populated with defaults beforehand. Keys which do not match a field of the
+// struct are an error, so that a typo in the config file is not silently ignored.
+// Durations must be strings such as "1h": a bare number would otherwise be read
+// as nanoseconds.
+func (c PluginConfig) Decode(target interface{}) error {
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook:       mapstructure.ComposeDecodeHookFunc(durationFromStringOnlyHookFunc, mapstructure.StringToTimeDurationHookFunc()),
+		ErrorUnused:      true,
+		WeaklyTypedInput: true,
+		Result:           target,
+	})
+	if err != nil {
+		return err
+	}
+	return decoder.Decode(map[string]interface{}(c))
+}
+
+// durationFromStringOnlyHookFunc rejects the values decoded into a time.Duration
+// which are not strings, such as 3600, which would otherwise be 3600ns.
+func durationFromStringOnlyHookFunc(from reflect.Type, to reflect.Type, data interface{}) (interface{}, error) {
+	if to != reflect.TypeOf(time.Duration(0)) || from.Kind() == reflect.String || from == to {
+		return data, nil
+	}
+	return nil, fmt.Errorf("invalid duration %v, expected a string such as \"1h\"", data)
+}
@@ -0,0 +1,122 @@
+/*
+Copyright © 2021 VMware
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+type testDatabaseConfig struct {
+	URL      string `mapstructure:"url"`
+	Password string `mapstructure:"password"`
+}
+
+type testPluginConfig struct {
+	Database testDatabaseConfig `mapstructure:"database"`
+	Workers  int                `mapstructure:"workers"`
+	Timeout  time.Duration      `mapstructure:"timeout"`
+}
+
+func TestPluginConfigDecode(t *testing.T) {
+	defaults := testPluginConfig{
+		Database: testDatabaseConfig{
+			URL:      "default-url",
+			Password: "password-from-env",
+		},
+		Workers: 1,
+	}
+
+	testCases := []struct {
+		name           string
+		config         PluginConfig
+		expectedConfig testPluginConfig
+		expectedErr    bool
+	}{
+		{
+			name:           "it keeps the defaults without any configuration",
+			config:         nil,
+			expectedConfig: defaults,
+		},
+		{
+			name: "it overrides only the values present in the configuration",
+			config: PluginConfig{
+				"database": map[string]interface{}{
+					"url": "postgresql:5432",
+				},
+				"timeout": "30s",
+			},
+			expectedConfig: testPluginConfig{
+				Database: testDatabaseConfig{
+					URL:      "postgresql:5432",
+					Password: "password-from-env",
+				},
+				Workers: 1,
+				Timeout: 30 * time.Second,
+			},
+		},
+		{
+			name: "it converts values to the type of the field",
+			config: PluginConfig{
+				"workers": "3",
+			},
+			expectedConfig: testPluginConfig{
+				Database: defaults.Database,
+				Workers:  3,
+			},
+		},
+		{
+			name: "it returns an error for unknown keys",
+			config: PluginConfig{
+				"databse": map[string]interface{}{
+					"url": "postgresql:5432",
+				},
+			},
+			expectedErr: true,
+		},
+		{
+			name: "it returns an error for a duration which is not a string",
+			config: PluginConfig{
+				"timeout": 3600,
+			},
+			expectedErr: true,
+		},
+		{
+			name: "it returns an error for values of the wrong type",
+			config: PluginConfig{
+				"workers": "many",
+			},
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			config := defaults
+
+			err := tc.config.Decode(&config)
+			if got, want := err != nil, tc.expectedErr; got != want {
+				t.Fatalf("got error: %v, want error: %t", err, want)
+			}
+			if tc.expectedErr {
+				return
+			}
+
+			if got, want := config, tc.expectedConfig; !cmp.Equal(want, got) {
+				t.Errorf("mismatch (-want +got):\n%s", cmp.Diff(want, got))
+			}
+		})
+	}
+}
@@ -24,6 +24,7 @@ import (
 	"reflect"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
 	packages "github.com/kubeapps/kubeapps/cmd/kubeapps-apis/gen/core/packages/v1alpha1"
@@ -48,8 +49,15 @@ const (
 	clustersCAFilesPrefix   = "/etc/additional-clusters-cafiles"
 )
 
+// KubernetesConfigGetter is a function type used by plugins to get a k8s config
+// for the given cluster. An empty cluster refers to the cluster on which Kubeapps
+// is installed.
+type KubernetesConfigGetter func(ctx context.Context, cluster string) (*rest.Config, error)
+
 // KubernetesClientGetter is a function type used by plugins to get a k8s client
-type KubernetesClientGetter func(context.Context) (kubernetes.Interface, dynamic.Interface, error)
+// for the given cluster. An empty cluster refers to the cluster on which Kubeapps
+// is installed.
+type KubernetesClientGetter func(ctx context.Context, cluster string) (kubernetes.Interface, dynamic.Interface, error)
 
 // pkgsPluginWithServer stores the plugin detail together with its implementation.
 type pkgsPluginWithServer struct {
@@ -57,6 +65,13 @@ type pkgsPluginWithServer struct {
 	server packages.PackagesServiceServer
 }
 
+// repositoriesPluginWithServer stores the plugin detail together with its
+// implementation of the core repositories API.
+type repositoriesPluginWithServer struct {
+	plugin *plugins.Plugin
+	server packages.RepositoriesServiceServer
+}
+
 // coreServer implements the API defined in cmd/kubeapps-api-service/core/core.proto
 type pluginsServer struct {
 	plugins.UnimplementedPluginsServiceServer
@@ -69,9 +84,20 @@ type pluginsServer struct {
 	// TODO: Update the plugins server to be able to register different versions
 	// of core plugins.
 	packagesPlugins []*pkgsPluginWithServer
+
+	// repositoriesPlugins contains plugin server implementations which satisfy
+	// the core server packages.v1alpha1 repositories interface.
+	repositoriesPlugins []*repositoriesPluginWithServer
+
+	// registeredPlugins contains the capabilities and health of every plugin
+	// registered for the core APIs, used to report their status.
+	registeredPlugins []*registeredPlugin
+
+	// pluginTimeout is the deadline for each plugin to check its dependencies.
+	pluginTimeout time.Duration
 }
 
-func NewPluginsServer(serveOpts ServeOptions, registrar grpc.ServiceRegistrar, gwArgs gwHandlerArgs) (*pluginsServer, error) {
+func NewPluginsServer(serveOpts ServeOptions, configGetter KubernetesConfigGetter, kubeappsCluster string, registrar grpc.ServiceRegistrar, gwArgs gwHandlerArgs) (*pluginsServer, error) {
 	// Store the serveOptions in the global 'pluginsServeOpts' variable
 
 	// Find all .so plugins in the specified plugins directory.
@@ -80,13 +106,32 @@ func NewPluginsServer(serveOpts ServeOptions, registrar grpc.ServiceRegistrar, g
 		log.Fatalf("failed to check for plugins: %v", err)
 	}
 
-	ps := &pluginsServer{}
+	ps := &pluginsServer{
+		pluginTimeout: serveOpts.PluginTimeout,
+	}
+	if ps.pluginTimeout <= 0 {
+		ps.pluginTimeout = defaultPluginTimeout
+	}
 
-	pluginDetails, err := ps.registerPlugins(pluginPaths, registrar, gwArgs, serveOpts)
+	pluginDetails, err := ps.registerPlugins(pluginPaths, registrar, gwArgs, serveOpts, configGetter, kubeappsCluster)
 	if err != nil {
 		return nil, fmt.Errorf("failed to register plugins: %w", err)
 	}
 
+	// Plugins running out of process are reached with their own dial options, as
+	// those of the gateway only trust the certificate of this server.
+	remotePluginDetails, err := ps.registerRemotePlugins(serveOpts.RemotePlugins, remotePluginDialOptions())
+	if err != nil {
+		return nil, fmt.Errorf("failed to register remote plugins: %w", err)
+	}
+	pluginDetails = append(pluginDetails, remotePluginDetails...)
+
+	for pluginName := range serveOpts.PluginConfigs {
+		if !containsPluginNamed(pluginDetails, pluginName) {
+			log.Warningf("Ignoring the configuration for plugin %q which is not registered", pluginName)
+		}
+	}
+
 	sortPlugins(pluginDetails)
 
 	ps.plugins = pluginDetails
@@ -101,22 +146,57 @@ func sortPlugins(p []*plugins.Plugin) {
 	})
 }
 
-// GetConfiguredPlugins returns details for each configured plugin.
+// containsPluginNamed returns whether any of the plugins has the given name.
+func containsPluginNamed(p []*plugins.Plugin, name string) bool {
+	for _, plugin := range p {
+		if plugin.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// GetConfiguredPlugins returns details for each configured plugin, together
+// with its status after checking its dependencies.
 func (s *pluginsServer) GetConfiguredPlugins(ctx context.Context, in *plugins.GetConfiguredPluginsRequest) (*plugins.GetConfiguredPluginsResponse, error) {
 	log.Infof("+core GetConfiguredPlugins")
 	return &plugins.GetConfiguredPluginsResponse{
-		Plugins: s.plugins,
+		Plugins:        s.plugins,
+		PluginStatuses: s.pluginStatuses(ctx),
 	}, nil
 }
 
-// registerPlugins opens each plugin, looks up the register function and calls it with the registrar.
-func (s *pluginsServer) registerPlugins(pluginPaths []string, grpcReg grpc.ServiceRegistrar, gwArgs gwHandlerArgs, serveOpts ServeOptions) ([]*plugins.Plugin, error) {
-	pluginDetails := []*plugins.Plugin{}
+// pluginStatuses checks the dependencies of every plugin concurrently and
+// returns the status of each plugin, in the same order as the plugins.
+func (s *pluginsServer) pluginStatuses(ctx context.Context) []*plugins.PluginStatus {
+	registeredPlugins := make([]*registeredPlugin, len(s.plugins))
+	for i, pluginDetail := range s.plugins {
+		registeredPlugins[i] = &registeredPlugin{plugin: pluginDetail}
+		for _, p := range s.registeredPlugins {
+			if p.plugin.Name == pluginDetail.Name && p.plugin.Version == pluginDetail.Version {
+				registeredPlugins[i] = p
+				break
+			}
+		}
+	}
 
-	clientGetter, err := createClientGetter(serveOpts)
-	if err != nil {
-		return nil, fmt.Errorf("unable to create a ClientGetter: %w", err)
+	results := fanOut(ctx, s.pluginTimeout, s.plugins, func(ctx context.Context, i int) (interface{}, error) {
+		if registeredPlugins[i].checker == nil {
+			return nil, nil
+		}
+		return registeredPlugins[i].checker.CheckDependencies(ctx), nil
+	})
+
+	pluginStatuses := make([]*plugins.PluginStatus, len(results))
+	for i, result := range results {
+		pluginStatuses[i] = registeredPlugins[i].pluginStatus(result)
 	}
+	return pluginStatuses
+}
+
+// registerPlugins opens each plugin, looks up the register function and calls it with the registrar.
+func (s *pluginsServer) registerPlugins(pluginPaths []string, grpcReg grpc.ServiceRegistrar, gwArgs gwHandlerArgs, serveOpts ServeOptions, configGetter KubernetesConfigGetter, kubeappsCluster string) ([]*plugins.Plugin, error) {
+	pluginDetails := []*plugins.Plugin{}
 
 	for _, pluginPath := range pluginPaths {
 		p, err := plugin.Open(pluginPath)
@@ -131,7 +211,7 @@ func (s *pluginsServer) registerPlugins(pluginPaths []string, grpcReg grpc.Servi
 			pluginDetails = append(pluginDetails, pluginDetail)
 		}
 
-		if err = s.registerGRPC(p, pluginDetail, grpcReg, clientGetter); err != nil {
+		if err = s.registerGRPC(gwArgs.ctx, p, pluginDetail, grpcReg, configGetter, kubeappsCluster, serveOpts.PluginConfigs[pluginDetail.Name]); err != nil {
 			return nil, err
 		}
 
@@ -144,21 +224,26 @@ func (s *pluginsServer) registerPlugins(pluginPaths []string, grpcReg grpc.Servi
 	return pluginDetails, nil
 }
 
-// registerGRPC finds and calls the required function for registering the plugin for the GRPC server.
-func (s *pluginsServer) registerGRPC(p *plugin.Plugin, pluginDetail *plugins.Plugin, registrar grpc.ServiceRegistrar, clientGetter KubernetesClientGetter) error {
+// registerGRPC finds and calls the required function for registering the plugin for the GRPC server,
+// handing the plugin the name of the cluster on which Kubeapps is installed and its own section of
+// the config file. The context is cancelled when the server stops, so that the plugin can stop any
+// background work it started.
+func (s *pluginsServer) registerGRPC(ctx context.Context, p *plugin.Plugin, pluginDetail *plugins.Plugin, registrar grpc.ServiceRegistrar, configGetter KubernetesConfigGetter, kubeappsCluster string, pluginConfig PluginConfig) error {
 	grpcRegFn, err := p.Lookup(grpcRegisterFunction)
 	if err != nil {
 		return fmt.Errorf("unable to lookup %q for %v: %w", grpcRegisterFunction, pluginDetail, err)
 	}
-	type grpcRegisterFunctionType = func(grpc.ServiceRegistrar, KubernetesClientGetter) (interface{}, error)
+	type grpcRegisterFunctionType = func(context.Context, grpc.ServiceRegistrar, KubernetesConfigGetter, string, PluginConfig) (interface{}, error)
 
 	grpcFn, ok := grpcRegFn.(grpcRegisterFunctionType)
 	if !ok {
-		var dummyFn grpcRegisterFunctionType = func(grpc.ServiceRegistrar, KubernetesClientGetter) (interface{}, error) { return nil, nil }
+		var dummyFn grpcRegisterFunctionType = func(context.Context, grpc.ServiceRegistrar, KubernetesConfigGetter, string, PluginConfig) (interface{}, error) {
+			return nil, nil
+		}
 		return fmt.Errorf("unable to use %q in plugin %v due to mismatched signature.\nwant: %T\ngot: %T", grpcRegisterFunction, pluginDetail, dummyFn, grpcRegFn)
 	}
 
-	server, err := grpcFn(registrar, clientGetter)
+	server, err := grpcFn(ctx, registrar, configGetter, kubeappsCluster, pluginConfig)
 	if err != nil {
 		return fmt.Errorf("plug-in %q failed to register due to: %v", pluginDetail, err)
 	} else if server == nil {
@@ -176,6 +261,16 @@ func (s *pluginsServer) registerPluginsSatisfyingCoreAPIs(pluginSrv interface{},
 	// grpc-go itself does, see:
 	// https://github.com/grpc/grpc-go/blob/v1.38.0/server.go#L621
 	serverType := reflect.TypeOf(pluginSrv)
+	registered := &registeredPlugin{
+		plugin:       pluginDetail,
+		capabilities: capabilitiesForServer(pluginSrv),
+		health:       &pluginHealth{},
+	}
+	if checker, ok := pluginSrv.(PluginDependenciesChecker); ok {
+		registered.checker = checker
+	}
+	s.registeredPlugins = append(s.registeredPlugins, registered)
+
 	corePackagesType := reflect.TypeOf((*packages.PackagesServiceServer)(nil)).Elem()
 
 	if serverType.Implements(corePackagesType) {
@@ -185,10 +280,23 @@ func (s *pluginsServer) registerPluginsSatisfyingCoreAPIs(pluginSrv interface{},
 		}
 		s.packagesPlugins = append(s.packagesPlugins, &pkgsPluginWithServer{
 			plugin: pluginDetail,
-			server: pkgsSrv,
+			server: &instrumentedPackagesServer{pkgsSrv, pluginInstrumentation{pluginDetail, registered.health}},
 		})
 		log.Infof("Plugin %v implements core.packages.v1alpha1. Registered for aggregation.", pluginDetail)
 	}
+
+	coreRepositoriesType := reflect.TypeOf((*packages.RepositoriesServiceServer)(nil)).Elem()
+	if serverType.Implements(coreRepositoriesType) {
+		reposSrv, ok := pluginSrv.(packages.RepositoriesServiceServer)
+		if !ok {
+			return fmt.Errorf("Unable to convert plugin %v to core RepositoriesServiceServer although it implements the same.", pluginDetail)
+		}
+		s.repositoriesPlugins = append(s.repositoriesPlugins, &repositoriesPluginWithServer{
+			plugin: pluginDetail,
+			server: &instrumentedRepositoriesServer{reposSrv, pluginInstrumentation{pluginDetail, registered.health}},
+		})
+		log.Infof("Plugin %v implements core.packages.v1alpha1 repositories. Registered for aggregation.", pluginDetail)
+	}
 	return nil
 }
 
@@ -258,73 +366,127 @@ func listSOFiles(fsys fs.FS, pluginDirs []string) ([]string, error) {
 	return matches, nil
 }
 
-// createClientGetter returns a function closure for creating the k8s client to interact with the cluster.
+// createConfigGetter returns a function closure for creating the k8s config to interact with a cluster.
 // The returned function utilizes the user credential present in the request context.
-// The plugins just have to call this function passing the context in order to retrieve the configured k8s client
-func createClientGetter(serveOpts ServeOptions) (KubernetesClientGetter, error) {
-	var restConfig *rest.Config
+// The plugins just have to call this function passing the context and the target cluster in order
+// to retrieve the configured k8s config.
+// The name of the cluster on which Kubeapps is installed, for which an empty cluster stands,
+// is returned too. The CA files of the additional clusters referenced by the configs are
+// removed by the returned cleanup function, which must not be called while the config getter
+// is in use.
+func createConfigGetter(serveOpts ServeOptions) (configGetter KubernetesConfigGetter, kubeappsCluster string, cleanupCAFiles func(), err error) {
 	var clustersConfig kube.ClustersConfig
-	var err error
+	cleanupCAFiles = func() {}
 
-	if !serveOpts.UnsafeLocalDevKubeconfig {
-		// get the default rest inCluster config for the kube.NewClusterConfig function
-		restConfig, err = rest.InClusterConfig()
-		if err != nil {
-			return nil, fmt.Errorf("unable to get inClusterConfig: %w", err)
-		}
-	} else {
-		// using the local kubeconfig instead of the inCluster config
-		log.Warningf("Using the local kubeconfig configuration (in KUBECONFIG='%s' envar) since you passed --unsafe-local-dev-kubeconfig=true", os.Getenv("KUBECONFIG"))
-		kubeconfigBytes, err := ioutil.ReadFile(os.Getenv("KUBECONFIG"))
-		if err != nil {
-			return nil, fmt.Errorf("unable to read the file in KUBECONFIG envar: %w", err)
-		}
-		restConfig, err = clientcmd.RESTConfigFromKubeConfig(kubeconfigBytes)
-		if err != nil {
-			return nil, fmt.Errorf("unable to get local KUBECONFIG='%s' file: %w", os.Getenv("KUBECONFIG"), err)
-		}
+	restConfig, err := getRestConfigFromServeOpts(serveOpts)
+	if err != nil {
+		return nil, "", cleanupCAFiles, err
 	}
 
-	if !serveOpts.UnsafeUseDemoSA {
+	if !serveOpts.UnsafeUseDemoSA || serveOpts.ClustersConfigPath != "" {
 		// get the parsed kube.ClustersConfig from the serveOpts
-		clustersConfig, err = getClustersConfigFromServeOpts(serveOpts)
+		clustersConfig, cleanupCAFiles, err = getClustersConfigFromServeOpts(serveOpts, clustersCAFilesPrefix)
 		if err != nil {
-			return nil, err
+			return nil, "", cleanupCAFiles, err
 		}
 	} else {
-		// Just using the created SA, no user account nor clustersConfig is used here
-		clustersConfig = kube.ClustersConfig{}
+		// Just using the created SA, no user account is used here and, without a clustersConfig,
+		// the cluster on which Kubeapps is installed is the "default" one, as in kubeops
+		clustersConfig = kube.ClustersConfig{KubeappsClusterName: "default"}
 	}
 
 	// return the closure fuction that takes the context, but preserving the required scope,
 	// 'inClusterConfig' and 'config'
-	return createClientGetterWithParams(restConfig, serveOpts, clustersConfig)
+	configGetter, err = createConfigGetterWithParams(restConfig, serveOpts, clustersConfig)
+	return configGetter, clustersConfig.KubeappsClusterName, cleanupCAFiles, err
 }
 
-// createClientGetter takes the required params and returns the closure fuction.
+// getRestConfigFromServeOpts returns the config of the service itself for the
+// cluster on which Kubeapps is installed: the inCluster config or, for local
+// development, the config from the KUBECONFIG envar.
+func getRestConfigFromServeOpts(serveOpts ServeOptions) (*rest.Config, error) {
+	if !serveOpts.UnsafeLocalDevKubeconfig {
+		// get the default rest inCluster config for the kube.NewClusterConfig function
+		restConfig, err := rest.InClusterConfig()
+		if err != nil {
+			return nil, fmt.Errorf("unable to get inClusterConfig: %w", err)
+		}
+		return restConfig, nil
+	}
+	// using the local kubeconfig instead of the inCluster config
+	log.Warningf("Using the local kubeconfig configuration (in KUBECONFIG='%s' envar) since you passed --unsafe-local-dev-kubeconfig=true", os.Getenv("KUBECONFIG"))
+	kubeconfigBytes, err := ioutil.ReadFile(os.Getenv("KUBECONFIG"))
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the file in KUBECONFIG envar: %w", err)
+	}
+	restConfig, err := clientcmd.RESTConfigFromKubeConfig(kubeconfigBytes)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get local KUBECONFIG='%s' file: %w", os.Getenv("KUBECONFIG"), err)
+	}
+	return restConfig, nil
+}
+
+// createConfigGetterWithParams takes the required params and returns the closure fuction.
 // it's splitted for testing this fn separately
-func createClientGetterWithParams(inClusterConfig *rest.Config, serveOpts ServeOptions, clustersConfig kube.ClustersConfig) (KubernetesClientGetter, error) {
+func createConfigGetterWithParams(inClusterConfig *rest.Config, serveOpts ServeOptions, clustersConfig kube.ClustersConfig) (KubernetesConfigGetter, error) {
 	// return the closure fuction that takes the context, but preserving the required scope,
 	// 'inClusterConfig' and 'config'
-	return func(ctx context.Context) (kubernetes.Interface, dynamic.Interface, error) {
-		log.Infof("+clientGetter.GetClient")
+	return func(ctx context.Context, cluster string) (*rest.Config, error) {
+		log.Infof("+configGetter.GetConfig(cluster=[%s])", cluster)
 		var err error
 		token, err := extractToken(ctx)
 		if err != nil {
-			return nil, nil, status.Errorf(codes.Unauthenticated, "invalid authorization metadata: %v", err)
+			return nil, status.Errorf(codes.Unauthenticated, "invalid authorization metadata: %v", err)
+		}
+
+		if cluster == "" {
+			cluster = clustersConfig.KubeappsClusterName
 		}
 
 		var config *rest.Config
-		if !serveOpts.UnsafeUseDemoSA {
-			// We are using the KubeappsClusterName, but if the endpoint was cluster-scoped,
-			// we should pass the cluster name instead
-			config, err = kube.NewClusterConfig(inClusterConfig, token, clustersConfig.KubeappsClusterName, clustersConfig)
+		useServiceAccount, _ := ctx.Value(serviceAccountContextKey).(bool)
+		if !serveOpts.UnsafeUseDemoSA && !useServiceAccount {
+			// The config for an additional cluster either targets its API server directly
+			// or, when pinniped is enabled for it, the pinniped-proxy which exchanges the
+			// user credential for one valid on that cluster.
+			config, err = kube.NewClusterConfig(inClusterConfig, token, cluster, clustersConfig)
 			if err != nil {
-				return nil, nil, fmt.Errorf("unable to get clusterConfig: %w", err)
+				return nil, status.Errorf(codes.InvalidArgument, "unable to get config for cluster [%s]: %v", cluster, err)
 			}
 		} else {
-			// Just using the created SA, no user account is used
-			config = inClusterConfig
+			// Just using the service account of kubeapps-apis, or the created demo SA, no user
+			// account is used, which only has access to the cluster on which Kubeapps is installed
+			if cluster != clustersConfig.KubeappsClusterName {
+				return nil, status.Errorf(codes.InvalidArgument, "unable to get config for cluster [%s]: only the cluster [%s] is available with the service account", cluster, clustersConfig.KubeappsClusterName)
+			}
+			config = rest.CopyConfig(inClusterConfig)
+		}
+		// Trace the requests to the API server as part of the request to the plugin.
+		config.Wrap(NewTracingRoundTripper)
+		return config, nil
+	}, nil
+}
+
+// ContextWithServiceAccount returns a context for which the KubernetesConfigGetter
+// returns the config of the kubeapps-apis service account on the cluster on which
+// Kubeapps is installed, rather than one with the credential of the user. Plugins
+// use it for their background work, which is not run on behalf of any user.
+func ContextWithServiceAccount(ctx context.Context) context.Context {
+	return context.WithValue(ctx, serviceAccountContextKey, true)
+}
+
+// NewClientGetter returns a function closure for creating the typed and dynamic
+// k8s clients using the config returned by the given configGetter.
+// Plugins which only require the k8s clients can use this with the configGetter
+// passed during registration.
+func NewClientGetter(configGetter KubernetesConfigGetter) KubernetesClientGetter {
+	return func(ctx context.Context, cluster string) (kubernetes.Interface, dynamic.Interface, error) {
+		if configGetter == nil {
+			return nil, nil, status.Errorf(codes.Internal, "configGetter arg required")
+		}
+		config, err := configGetter(ctx, cluster)
+		if err != nil {
+			return nil, nil, err
 		}
 		dynamicClient, err := dynamic.NewForConfig(config)
 		if err != nil {
@@ -335,13 +497,18 @@ func createClientGetterWithParams(inClusterConfig *rest.Config, serveOpts ServeO
 			return nil, nil, fmt.Errorf("unable to create typed client: %w", err)
 		}
 		return typedClient, dynamicClient, nil
-	}, nil
+	}
 }
 
 // extractToken returns the token passed through the gRPC request in the "authorization" metadata in the context
 // It is equivalent to the "Authorization" usual HTTP 1 header
 // For instance: authorization="Bearer abc" will return "abc"
 func extractToken(ctx context.Context) (string, error) {
+	// The token is extracted once for each request by the auth interceptor.
+	if token, ok := ctx.Value(tokenContextKey).(string); ok {
+		return token, nil
+	}
+
 	// per https://github.com/kubeapps/kubeapps/pull/3044
 	// extractToken() to return an empty token with a nil error if there is no metadata with the context.
 	md, ok := metadata.FromIncomingContext(ctx)
@@ -363,16 +530,19 @@ func extractToken(ctx context.Context) (string, error) {
 }
 
 // getClustersConfigFromServeOpts get the serveOptions and calls parseClusterConfig with the proper values
-// returning a kube.ClustersConfig
-func getClustersConfigFromServeOpts(serveOpts ServeOptions) (kube.ClustersConfig, error) {
+// returning a kube.ClustersConfig, together with the function removing the CA files written in a
+// directory under caFilesPrefix, which are referenced by the config for as long as it is used
+func getClustersConfigFromServeOpts(serveOpts ServeOptions, caFilesPrefix string) (kube.ClustersConfig, func(), error) {
 	if serveOpts.ClustersConfigPath == "" {
-		return kube.ClustersConfig{}, fmt.Errorf("unable to parse clusters config, no config path passed")
+		return kube.ClustersConfig{}, func() {}, fmt.Errorf("unable to parse clusters config, no config path passed")
+	}
+	config, cleanupCAFiles, err := kube.ParseClusterConfig(serveOpts.ClustersConfigPath, caFilesPrefix, serveOpts.PinnipedProxyURL)
+	if cleanupCAFiles == nil {
+		cleanupCAFiles = func() {}
 	}
-	var cleanupCAFiles func()
-	config, cleanupCAFiles, err := kube.ParseClusterConfig(serveOpts.ClustersConfigPath, clustersCAFilesPrefix, serveOpts.PinnipedProxyURL)
 	if err != nil {
-		return kube.ClustersConfig{}, fmt.Errorf("unable to parse additional clusters config: %+v", err)
+		cleanupCAFiles()
+		return kube.ClustersConfig{}, func() {}, fmt.Errorf("unable to parse additional clusters config: %+v", err)
 	}
-	defer cleanupCAFiles()
-	return config, nil
+	return config, cleanupCAFiles, nil
 }
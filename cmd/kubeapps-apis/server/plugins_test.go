@@ -14,8 +14,11 @@ package server
 
 import (
 	"context"
+	"encoding/base64"
 	"fmt"
 	"io/fs"
+	"io/ioutil"
+	"os"
 	"path/filepath"
 	"testing"
 	"testing/fstest"
@@ -364,12 +367,12 @@ func TestCreateClientGetterWithParams(t *testing.T) {
 					},
 				},
 			}
-			clientGetter, err := createClientGetterWithParams(inClusterConfig, serveOpts, config)
+			configGetter, err := createConfigGetterWithParams(inClusterConfig, serveOpts, config)
 			if err != nil {
-				t.Fatalf("in %s: fail creating the clientGetter:  %+v", tc.name, err)
+				t.Fatalf("in %s: fail creating the configGetter:  %+v", tc.name, err)
 			}
 
-			typedClient, dynamicClient, err := clientGetter(ctx)
+			typedClient, dynamicClient, err := NewClientGetter(configGetter)(ctx, "")
 			if tc.expectedErrMsg != nil && err != nil {
 				if got, want := err.Error(), tc.expectedErrMsg.Error(); !cmp.Equal(want, got) {
 					t.Errorf("in %s: mismatch (-want +got):\n%s", tc.name, cmp.Diff(want, got))
@@ -389,3 +392,164 @@ func TestCreateClientGetterWithParams(t *testing.T) {
 		})
 	}
 }
+
+func TestCreateConfigGetterWithParamsForCluster(t *testing.T) {
+	inClusterConfig := &rest.Config{Host: "https://kubernetes.default", BearerToken: "service-account-token"}
+	clustersConfig := kube.ClustersConfig{
+		KubeappsClusterName: "default",
+		PinnipedProxyURL:    "http://pinniped-proxy.example.com",
+		Clusters: map[string]kube.ClusterConfig{
+			"default": {
+				Name:              "default",
+				IsKubeappsCluster: true,
+			},
+			"other": {
+				Name:          "other",
+				APIServiceURL: "https://other.example.com",
+			},
+			"pinniped": {
+				Name:          "pinniped",
+				APIServiceURL: "https://pinniped.example.com",
+				PinnipedConfig: kube.PinnipedConciergeConfig{
+					Enable: true,
+				},
+			},
+		},
+	}
+
+	testCases := []struct {
+		name            string
+		cluster         string
+		unsafeUseDemoSA bool
+		serviceAccount  bool
+		expectedHost    string
+		statusCode      codes.Code
+	}{
+		{
+			name:         "it uses the in-cluster config when no cluster is specified",
+			cluster:      "",
+			expectedHost: "https://kubernetes.default",
+		},
+		{
+			name:         "it uses the in-cluster config for the kubeapps cluster",
+			cluster:      "default",
+			expectedHost: "https://kubernetes.default",
+		},
+		{
+			name:         "it uses the API server of an additional cluster",
+			cluster:      "other",
+			expectedHost: "https://other.example.com",
+		},
+		{
+			name:         "it uses the pinniped proxy for a cluster with pinniped enabled",
+			cluster:      "pinniped",
+			expectedHost: "http://pinniped-proxy.example.com",
+		},
+		{
+			name:       "it returns an invalid argument error for an unknown cluster",
+			cluster:    "unknown",
+			statusCode: codes.InvalidArgument,
+		},
+		{
+			name:            "it uses the in-cluster config for the kubeapps cluster with the demo service account",
+			cluster:         "default",
+			unsafeUseDemoSA: true,
+			expectedHost:    "https://kubernetes.default",
+		},
+		{
+			name:            "it returns an invalid argument error for an additional cluster with the demo service account",
+			cluster:         "other",
+			unsafeUseDemoSA: true,
+			statusCode:      codes.InvalidArgument,
+		},
+		{
+			name:           "it uses the in-cluster config for the background work of the plugins",
+			cluster:        "",
+			serviceAccount: true,
+			expectedHost:   "https://kubernetes.default",
+		},
+		{
+			name:           "it returns an invalid argument error for an additional cluster for the background work of the plugins",
+			cluster:        "other",
+			serviceAccount: true,
+			statusCode:     codes.InvalidArgument,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := metadata.NewIncomingContext(context.Background(), metadata.New(map[string]string{
+				"authorization": "Bearer abc",
+			}))
+			if tc.serviceAccount {
+				ctx = ContextWithServiceAccount(ctx)
+			}
+
+			configGetter, err := createConfigGetterWithParams(inClusterConfig, ServeOptions{UnsafeUseDemoSA: tc.unsafeUseDemoSA}, clustersConfig)
+			if err != nil {
+				t.Fatalf("fail creating the configGetter: %+v", err)
+			}
+
+			config, err := configGetter(ctx, tc.cluster)
+			if got, want := status.Code(err), tc.statusCode; got != want {
+				t.Fatalf("got: %+v, want: %+v", got, want)
+			}
+			if tc.statusCode != codes.OK {
+				return
+			}
+
+			if got, want := config.Host, tc.expectedHost; got != want {
+				t.Errorf("got: %q, want: %q", got, want)
+			}
+			// The service account is used instead of the user token.
+			expectedToken := "abc"
+			if tc.unsafeUseDemoSA || tc.serviceAccount {
+				expectedToken = "service-account-token"
+			}
+			if got, want := config.BearerToken, expectedToken; got != want {
+				t.Errorf("got: %q, want: %q", got, want)
+			}
+		})
+	}
+}
+
+func TestGetClustersConfigFromServeOptsKeepsCAFiles(t *testing.T) {
+	configDir := t.TempDir()
+	configPath := filepath.Join(configDir, "clusters.json")
+	clustersJSON := fmt.Sprintf(`[{"name": "default"}, {"name": "other", "apiServiceURL": "https://other.example.com", "certificateAuthorityData": %q}]`, base64.StdEncoding.EncodeToString([]byte("ca-data")))
+	if err := ioutil.WriteFile(configPath, []byte(clustersJSON), 0644); err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	clustersConfig, cleanupCAFiles, err := getClustersConfigFromServeOpts(ServeOptions{ClustersConfigPath: configPath}, configDir)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	configGetter, err := createConfigGetterWithParams(&rest.Config{}, ServeOptions{}, clustersConfig)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.New(map[string]string{
+		"authorization": "Bearer abc",
+	}))
+	config, err := configGetter(ctx, "other")
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if config.CAFile == "" {
+		t.Fatalf("got: empty CA file, want: the CA file of the cluster")
+	}
+	caData, err := ioutil.ReadFile(config.CAFile)
+	if err != nil {
+		t.Fatalf("CA file not available once the config getter is created: %+v", err)
+	}
+	if got, want := string(caData), "ca-data"; got != want {
+		t.Errorf("got: %q, want: %q", got, want)
+	}
+
+	cleanupCAFiles()
+	if _, err := os.Stat(config.CAFile); !os.IsNotExist(err) {
+		t.Errorf("got: %+v, want: the CA file to be removed on cleanup", err)
+	}
+}
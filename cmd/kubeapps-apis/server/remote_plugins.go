@@ -0,0 +1,349 @@
+/*
+Copyright © 2021 VMware
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"strings"
+
+	packages "github.com/kubeapps/kubeapps/cmd/kubeapps-apis/gen/core/packages/v1alpha1"
+	plugins "github.com/kubeapps/kubeapps/cmd/kubeapps-apis/gen/core/plugins/v1alpha1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	log "k8s.io/klog/v2"
+)
+
+const (
+	// Core APIs which a remote plugin can declare to implement.
+	remotePackagesAPI     = "packages"
+	remoteRepositoriesAPI = "repositories"
+)
+
+// RemotePluginConfig configures a plugin running out of process, such as in a
+// sidecar container, which is reached over gRPC rather than loaded as a .so file.
+type RemotePluginConfig struct {
+	// Name and Version identify the plugin, as returned by GetPluginDetail for
+	// plugins loaded in process.
+	Name    string `mapstructure:"name"`
+	Version string `mapstructure:"version"`
+	// Address is the host:port on which the plugin serves gRPC.
+	Address string `mapstructure:"address"`
+	// CoreAPIs lists the core APIs implemented by the plugin, "packages" and/or
+	// "repositories". Defaults to "packages" only.
+	CoreAPIs []string `mapstructure:"coreAPIs"`
+	// TLS configures the TLS connection to the plugin, which verifies the
+	// certificate of the plugin against the system roots by default.
+	TLS RemotePluginTLSConfig `mapstructure:"tls"`
+	// Insecure dials the plugin without TLS. As the credentials of the users
+	// are forwarded to the plugin, this is only allowed for a loopback or unix
+	// socket address, such as for a sidecar container.
+	Insecure bool `mapstructure:"insecure"`
+}
+
+// RemotePluginTLSConfig configures the TLS connection to a remote plugin.
+type RemotePluginTLSConfig struct {
+	// CAFile is the CA used to verify the certificate of the plugin, instead
+	// of the system roots.
+	CAFile string `mapstructure:"caFile"`
+	// CertFile and KeyFile are the client certificate presented to the plugin.
+	CertFile string `mapstructure:"certFile"`
+	KeyFile  string `mapstructure:"keyFile"`
+	// ServerName is the name verified in the certificate of the plugin, which
+	// defaults to the host of the address.
+	ServerName string `mapstructure:"serverName"`
+}
+
+// remotePluginServer implements the core APIs by forwarding each request to the
+// plugin process over a gRPC client connection.
+type remotePluginServer struct {
+	packages.UnimplementedPackagesServiceServer
+	packages.UnimplementedRepositoriesServiceServer
+
+	packagesClient     packages.PackagesServiceClient
+	repositoriesClient packages.RepositoriesServiceClient
+	healthClient       healthpb.HealthClient
+}
+
+// remotePackagesServer only exposes the packages API of a remote plugin so that
+// it is registered for aggregation of that API alone.
+type remotePackagesServer struct {
+	packages.PackagesServiceServer
+	PluginDependenciesChecker
+}
+
+// remoteRepositoriesServer only exposes the repositories API of a remote plugin.
+type remoteRepositoriesServer struct {
+	packages.RepositoriesServiceServer
+	PluginDependenciesChecker
+}
+
+// remotePackagesAndRepositoriesServer exposes both core APIs of a remote plugin.
+type remotePackagesAndRepositoriesServer struct {
+	packages.PackagesServiceServer
+	packages.RepositoriesServiceServer
+	PluginDependenciesChecker
+}
+
+// remotePluginDialOptions are the options to dial the remote plugins, which
+// propagate the trace context of the requests forwarded to them. The transport
+// credentials are added for each plugin by remotePluginCredentials.
+func remotePluginDialOptions() []grpc.DialOption {
+	return []grpc.DialOption{
+		grpc.WithChainUnaryInterceptor(tracingUnaryClientInterceptor),
+		grpc.WithChainStreamInterceptor(tracingStreamClientInterceptor),
+	}
+}
+
+// remotePluginCredentials returns the transport credentials to dial the remote
+// plugin: TLS unless it is explicitly configured as insecure, which is rejected
+// for any address other than a loopback or unix socket one, so that the
+// credentials of the users forwarded to the plugin never cross the network in
+// plain text.
+func remotePluginCredentials(remotePlugin RemotePluginConfig) (grpc.DialOption, error) {
+	if remotePlugin.Insecure {
+		if remotePlugin.TLS != (RemotePluginTLSConfig{}) {
+			return nil, fmt.Errorf("the TLS configuration cannot be set for an insecure remote plugin")
+		}
+		if !isLocalAddress(remotePlugin.Address) {
+			return nil, fmt.Errorf("insecure address %q is not a loopback or unix socket address", remotePlugin.Address)
+		}
+		return grpc.WithInsecure(), nil
+	}
+
+	config := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: remotePlugin.TLS.ServerName,
+	}
+	if remotePlugin.TLS.CAFile != "" {
+		caBytes, err := ioutil.ReadFile(remotePlugin.TLS.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read the CA file: %w", err)
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(caBytes) {
+			return nil, fmt.Errorf("no certificate found in the CA file %q", remotePlugin.TLS.CAFile)
+		}
+	}
+	if remotePlugin.TLS.CertFile != "" || remotePlugin.TLS.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(remotePlugin.TLS.CertFile, remotePlugin.TLS.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load the client certificate and key: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return grpc.WithTransportCredentials(credentials.NewTLS(config)), nil
+}
+
+// isLocalAddress returns whether the gRPC target is a unix socket or a loopback
+// address, which can be dialed without TLS.
+func isLocalAddress(address string) bool {
+	if strings.HasPrefix(address, "unix:") {
+		return true
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// registerRemotePlugins dials each configured remote plugin and registers it for
+// the core APIs it implements, in the same way as the plugins loaded from .so files.
+// The connections are established lazily, so a plugin does not need to be up when
+// the server starts.
+func (s *pluginsServer) registerRemotePlugins(remotePlugins []RemotePluginConfig, dialOptions []grpc.DialOption) ([]*plugins.Plugin, error) {
+	pluginDetails := []*plugins.Plugin{}
+	for _, remotePlugin := range remotePlugins {
+		if remotePlugin.Name == "" || remotePlugin.Version == "" || remotePlugin.Address == "" {
+			return nil, fmt.Errorf("remote plugin %+v requires a name, version and address", remotePlugin)
+		}
+		pluginDetail := &plugins.Plugin{
+			Name:    remotePlugin.Name,
+			Version: remotePlugin.Version,
+		}
+
+		transportCredentials, err := remotePluginCredentials(remotePlugin)
+		if err != nil {
+			return nil, fmt.Errorf("unable to configure the connection to remote plugin %v: %w", pluginDetail, err)
+		}
+		pluginDialOptions := append([]grpc.DialOption{transportCredentials}, dialOptions...)
+		conn, err := grpc.Dial(remotePlugin.Address, pluginDialOptions...)
+		if err != nil {
+			return nil, fmt.Errorf("unable to dial remote plugin %v at %q: %w", pluginDetail, remotePlugin.Address, err)
+		}
+		remoteServer := &remotePluginServer{
+			packagesClient:     packages.NewPackagesServiceClient(conn),
+			repositoriesClient: packages.NewRepositoriesServiceClient(conn),
+			healthClient:       healthpb.NewHealthClient(conn),
+		}
+
+		server, err := remoteServerForCoreAPIs(remoteServer, remotePlugin.CoreAPIs)
+		if err != nil {
+			return nil, fmt.Errorf("unable to register remote plugin %v: %w", pluginDetail, err)
+		}
+		if err = s.registerPluginsSatisfyingCoreAPIs(server, pluginDetail); err != nil {
+			return nil, err
+		}
+		pluginDetails = append(pluginDetails, pluginDetail)
+		log.Infof("Successfully registered remote plugin %v at %q", pluginDetail, remotePlugin.Address)
+	}
+	return pluginDetails, nil
+}
+
+// remoteServerForCoreAPIs returns a server exposing only the given core APIs of the
+// remote plugin, so that registerPluginsSatisfyingCoreAPIs only registers those.
+func remoteServerForCoreAPIs(remoteServer *remotePluginServer, coreAPIs []string) (interface{}, error) {
+	if len(coreAPIs) == 0 {
+		coreAPIs = []string{remotePackagesAPI}
+	}
+	implementsPackages, implementsRepositories := false, false
+	for _, coreAPI := range coreAPIs {
+		switch coreAPI {
+		case remotePackagesAPI:
+			implementsPackages = true
+		case remoteRepositoriesAPI:
+			implementsRepositories = true
+		default:
+			return nil, fmt.Errorf("unknown core API %q, expected one of %q or %q", coreAPI, remotePackagesAPI, remoteRepositoriesAPI)
+		}
+	}
+
+	switch {
+	case implementsPackages && implementsRepositories:
+		return &remotePackagesAndRepositoriesServer{remoteServer, remoteServer, remoteServer}, nil
+	case implementsRepositories:
+		return &remoteRepositoriesServer{remoteServer, remoteServer}, nil
+	default:
+		return &remotePackagesServer{remoteServer, remoteServer}, nil
+	}
+}
+
+// outgoingContext returns the context for the request to the remote plugin,
+// forwarding the user credential so that the plugin acts on behalf of the user.
+func outgoingContext(ctx context.Context) context.Context {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok || len(md["authorization"]) == 0 {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, "authorization", md["authorization"][0])
+}
+
+// CheckDependencies checks that the plugin process is reachable using the
+// standard gRPC health checking protocol. A plugin which does not implement the
+// protocol is reachable as long as it responds.
+func (s *remotePluginServer) CheckDependencies(ctx context.Context) []*plugins.PluginDependencyStatus {
+	dependency := &plugins.PluginDependencyStatus{
+		Name: "grpc",
+	}
+	response, err := s.healthClient.Check(ctx, &healthpb.HealthCheckRequest{})
+	switch {
+	case status.Code(err) == codes.Unimplemented:
+		dependency.Reachable = true
+	case err != nil:
+		dependency.Message = err.Error()
+	case response.GetStatus() != healthpb.HealthCheckResponse_SERVING:
+		dependency.Message = fmt.Sprintf("plugin process reported status %s", response.GetStatus())
+	default:
+		dependency.Reachable = true
+	}
+	return []*plugins.PluginDependencyStatus{dependency}
+}
+
+func (s *remotePluginServer) GetAvailablePackageSummaries(ctx context.Context, request *packages.GetAvailablePackageSummariesRequest) (*packages.GetAvailablePackageSummariesResponse, error) {
+	return s.packagesClient.GetAvailablePackageSummaries(outgoingContext(ctx), request)
+}
+
+func (s *remotePluginServer) GetAvailablePackageDetail(ctx context.Context, request *packages.GetAvailablePackageDetailRequest) (*packages.GetAvailablePackageDetailResponse, error) {
+	return s.packagesClient.GetAvailablePackageDetail(outgoingContext(ctx), request)
+}
+
+func (s *remotePluginServer) GetAvailablePackageVersions(ctx context.Context, request *packages.GetAvailablePackageVersionsRequest) (*packages.GetAvailablePackageVersionsResponse, error) {
+	return s.packagesClient.GetAvailablePackageVersions(outgoingContext(ctx), request)
+}
+
+func (s *remotePluginServer) WatchAvailablePackages(request *packages.WatchAvailablePackagesRequest, stream packages.PackagesService_WatchAvailablePackagesServer) error {
+	client, err := s.packagesClient.WatchAvailablePackages(outgoingContext(stream.Context()), request)
+	if err != nil {
+		return err
+	}
+	for {
+		response, err := client.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := stream.Send(response); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *remotePluginServer) GetInstalledPackageSummaries(ctx context.Context, request *packages.GetInstalledPackageSummariesRequest) (*packages.GetInstalledPackageSummariesResponse, error) {
+	return s.packagesClient.GetInstalledPackageSummaries(outgoingContext(ctx), request)
+}
+
+func (s *remotePluginServer) GetInstalledPackageDetail(ctx context.Context, request *packages.GetInstalledPackageDetailRequest) (*packages.GetInstalledPackageDetailResponse, error) {
+	return s.packagesClient.GetInstalledPackageDetail(outgoingContext(ctx), request)
+}
+
+func (s *remotePluginServer) CreateInstalledPackage(ctx context.Context, request *packages.CreateInstalledPackageRequest) (*packages.CreateInstalledPackageResponse, error) {
+	return s.packagesClient.CreateInstalledPackage(outgoingContext(ctx), request)
+}
+
+func (s *remotePluginServer) UpdateInstalledPackage(ctx context.Context, request *packages.UpdateInstalledPackageRequest) (*packages.UpdateInstalledPackageResponse, error) {
+	return s.packagesClient.UpdateInstalledPackage(outgoingContext(ctx), request)
+}
+
+func (s *remotePluginServer) DeleteInstalledPackage(ctx context.Context, request *packages.DeleteInstalledPackageRequest) (*packages.DeleteInstalledPackageResponse, error) {
+	return s.packagesClient.DeleteInstalledPackage(outgoingContext(ctx), request)
+}
+
+func (s *remotePluginServer) GetPackageRepositorySummaries(ctx context.Context, request *packages.GetPackageRepositorySummariesRequest) (*packages.GetPackageRepositorySummariesResponse, error) {
+	return s.repositoriesClient.GetPackageRepositorySummaries(outgoingContext(ctx), request)
+}
+
+func (s *remotePluginServer) GetPackageRepositoryDetail(ctx context.Context, request *packages.GetPackageRepositoryDetailRequest) (*packages.GetPackageRepositoryDetailResponse, error) {
+	return s.repositoriesClient.GetPackageRepositoryDetail(outgoingContext(ctx), request)
+}
+
+func (s *remotePluginServer) AddPackageRepository(ctx context.Context, request *packages.AddPackageRepositoryRequest) (*packages.AddPackageRepositoryResponse, error) {
+	return s.repositoriesClient.AddPackageRepository(outgoingContext(ctx), request)
+}
+
+func (s *remotePluginServer) UpdatePackageRepository(ctx context.Context, request *packages.UpdatePackageRepositoryRequest) (*packages.UpdatePackageRepositoryResponse, error) {
+	return s.repositoriesClient.UpdatePackageRepository(outgoingContext(ctx), request)
+}
+
+func (s *remotePluginServer) DeletePackageRepository(ctx context.Context, request *packages.DeletePackageRepositoryRequest) (*packages.DeletePackageRepositoryResponse, error) {
+	return s.repositoriesClient.DeletePackageRepository(outgoingContext(ctx), request)
+}
+
+func (s *remotePluginServer) RefreshPackageRepository(ctx context.Context, request *packages.RefreshPackageRepositoryRequest) (*packages.RefreshPackageRepositoryResponse, error) {
+	return s.repositoriesClient.RefreshPackageRepository(outgoingContext(ctx), request)
+}
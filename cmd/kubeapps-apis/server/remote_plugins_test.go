@@ -0,0 +1,258 @@
+/*
+Copyright © 2021 VMware
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	packages "github.com/kubeapps/kubeapps/cmd/kubeapps-apis/gen/core/packages/v1alpha1"
+	plugins "github.com/kubeapps/kubeapps/cmd/kubeapps-apis/gen/core/plugins/v1alpha1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// fakeRemotePackagesServer is a plugin process serving the core packages API,
+// which records the authorization metadata it receives.
+type fakeRemotePackagesServer struct {
+	packages.UnimplementedPackagesServiceServer
+	authorization []string
+}
+
+func (s *fakeRemotePackagesServer) GetAvailablePackageSummaries(ctx context.Context, request *packages.GetAvailablePackageSummariesRequest) (*packages.GetAvailablePackageSummariesResponse, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	s.authorization = md["authorization"]
+	return &packages.GetAvailablePackageSummariesResponse{
+		AvailablePackagesSummaries: []*packages.AvailablePackageSummary{
+			{Name: "remote-package"},
+		},
+	}, nil
+}
+
+// startRemotePlugin serves the given packages server on an in-memory listener and
+// returns the dial options to reach it, whatever the address of the plugin.
+func startRemotePlugin(t *testing.T, server packages.PackagesServiceServer, opts ...grpc.ServerOption) []grpc.DialOption {
+	lis := bufconn.Listen(1024 * 1024)
+	grpcSrv := grpc.NewServer(opts...)
+	packages.RegisterPackagesServiceServer(grpcSrv, server)
+	go grpcSrv.Serve(lis)
+	t.Cleanup(grpcSrv.Stop)
+
+	return []grpc.DialOption{
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+			return lis.Dial()
+		}),
+	}
+}
+
+func TestRegisterRemotePlugins(t *testing.T) {
+	testCases := []struct {
+		name                        string
+		remotePlugins               []RemotePluginConfig
+		expectedPlugins             []*plugins.Plugin
+		expectedPackagesPlugins     int
+		expectedRepositoriesPlugins int
+		expectErr                   bool
+	}{
+		{
+			name: "it registers a remote plugin for the packages API by default",
+			remotePlugins: []RemotePluginConfig{
+				{Name: "remote.packages", Version: "v1alpha1", Address: "remote:50051"},
+			},
+			expectedPlugins:         []*plugins.Plugin{{Name: "remote.packages", Version: "v1alpha1"}},
+			expectedPackagesPlugins: 1,
+		},
+		{
+			name: "it registers a remote plugin for the configured core APIs",
+			remotePlugins: []RemotePluginConfig{
+				{Name: "remote.packages", Version: "v1alpha1", Address: "remote:50051", CoreAPIs: []string{"repositories"}},
+				{Name: "other.packages", Version: "v1alpha1", Address: "other:50051", CoreAPIs: []string{"packages", "repositories"}},
+			},
+			expectedPlugins: []*plugins.Plugin{
+				{Name: "remote.packages", Version: "v1alpha1"},
+				{Name: "other.packages", Version: "v1alpha1"},
+			},
+			expectedPackagesPlugins:     1,
+			expectedRepositoriesPlugins: 2,
+		},
+		{
+			name: "it errors for a remote plugin without address",
+			remotePlugins: []RemotePluginConfig{
+				{Name: "remote.packages", Version: "v1alpha1"},
+			},
+			expectErr: true,
+		},
+		{
+			name: "it registers an insecure remote plugin on a loopback or unix socket address",
+			remotePlugins: []RemotePluginConfig{
+				{Name: "remote.packages", Version: "v1alpha1", Address: "localhost:50051", Insecure: true},
+				{Name: "other.packages", Version: "v1alpha1", Address: "127.0.0.1:50051", Insecure: true},
+				{Name: "socket.packages", Version: "v1alpha1", Address: "unix:///var/run/plugin.sock", Insecure: true},
+			},
+			expectedPlugins: []*plugins.Plugin{
+				{Name: "remote.packages", Version: "v1alpha1"},
+				{Name: "other.packages", Version: "v1alpha1"},
+				{Name: "socket.packages", Version: "v1alpha1"},
+			},
+			expectedPackagesPlugins: 3,
+		},
+		{
+			name: "it errors for an insecure remote plugin on another address",
+			remotePlugins: []RemotePluginConfig{
+				{Name: "remote.packages", Version: "v1alpha1", Address: "remote:50051", Insecure: true},
+			},
+			expectErr: true,
+		},
+		{
+			name: "it errors for an insecure remote plugin with a TLS configuration",
+			remotePlugins: []RemotePluginConfig{
+				{Name: "remote.packages", Version: "v1alpha1", Address: "localhost:50051", Insecure: true, TLS: RemotePluginTLSConfig{ServerName: "remote"}},
+			},
+			expectErr: true,
+		},
+		{
+			name: "it errors for a missing CA file",
+			remotePlugins: []RemotePluginConfig{
+				{Name: "remote.packages", Version: "v1alpha1", Address: "remote:50051", TLS: RemotePluginTLSConfig{CAFile: "/missing/ca.crt"}},
+			},
+			expectErr: true,
+		},
+		{
+			name: "it errors for a client certificate without key",
+			remotePlugins: []RemotePluginConfig{
+				{Name: "remote.packages", Version: "v1alpha1", Address: "remote:50051", TLS: RemotePluginTLSConfig{CertFile: "/client.crt"}},
+			},
+			expectErr: true,
+		},
+		{
+			name: "it errors for an unknown core API",
+			remotePlugins: []RemotePluginConfig{
+				{Name: "remote.packages", Version: "v1alpha1", Address: "remote:50051", CoreAPIs: []string{"foo"}},
+			},
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ps := &pluginsServer{}
+
+			pluginDetails, err := ps.registerRemotePlugins(tc.remotePlugins, remotePluginDialOptions())
+			if got, want := err != nil, tc.expectErr; got != want {
+				t.Fatalf("got: %t, want: %t, err: %+v", got, want, err)
+			}
+			if tc.expectErr {
+				return
+			}
+
+			if got, want := pluginDetails, tc.expectedPlugins; !cmp.Equal(want, got, cmp.Comparer(pluginEqual)) {
+				t.Errorf("mismatch (-want +got):\n%s", cmp.Diff(want, got, cmp.Comparer(pluginEqual)))
+			}
+			if got, want := len(ps.packagesPlugins), tc.expectedPackagesPlugins; got != want {
+				t.Errorf("got: %d, want: %d", got, want)
+			}
+			if got, want := len(ps.repositoriesPlugins), tc.expectedRepositoriesPlugins; got != want {
+				t.Errorf("got: %d, want: %d", got, want)
+			}
+		})
+	}
+}
+
+func TestRemotePluginForwardsRequests(t *testing.T) {
+	remoteServer := &fakeRemotePackagesServer{}
+	dialOptions := startRemotePlugin(t, remoteServer)
+
+	ps := &pluginsServer{}
+	_, err := ps.registerRemotePlugins([]RemotePluginConfig{
+		{Name: "remote.packages", Version: "v1alpha1", Address: "localhost:50051", Insecure: true},
+	}, dialOptions)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer abc", "other", "value"))
+	response, err := ps.packagesPlugins[0].server.GetAvailablePackageSummaries(ctx, &packages.GetAvailablePackageSummariesRequest{})
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	expectedResponse := &packages.GetAvailablePackageSummariesResponse{
+		AvailablePackagesSummaries: []*packages.AvailablePackageSummary{
+			{Name: "remote-package"},
+		},
+	}
+	opts := cmpopts.IgnoreUnexported(packages.GetAvailablePackageSummariesResponse{}, packages.AvailablePackageSummary{})
+	if got, want := response, expectedResponse; !cmp.Equal(want, got, opts) {
+		t.Errorf("mismatch (-want +got):\n%s", cmp.Diff(want, got, opts))
+	}
+	if got, want := remoteServer.authorization, []string{"Bearer abc"}; !cmp.Equal(want, got) {
+		t.Errorf("mismatch (-want +got):\n%s", cmp.Diff(want, got))
+	}
+}
+
+func TestRemotePluginWithTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCertificate(t, "plugin-ca", true, nil)
+	caFile, _ := ca.writeFiles(t, dir, "ca")
+	serverCert := newTestCertificate(t, "remote-plugin.kubeapps.svc", false, ca)
+	otherCAFile, _ := newTestCertificate(t, "other-ca", true, nil).writeFiles(t, dir, "other-ca")
+
+	dialOptions := startRemotePlugin(t, &fakeRemotePackagesServer{}, grpc.Creds(credentials.NewServerTLSFromCert(&tls.Certificate{
+		Certificate: [][]byte{serverCert.cert.Raw},
+		PrivateKey:  serverCert.key,
+	})))
+
+	testCases := []struct {
+		name      string
+		tlsConfig RemotePluginTLSConfig
+		expectErr bool
+	}{
+		{
+			name:      "it verifies the certificate of the plugin with the CA",
+			tlsConfig: RemotePluginTLSConfig{CAFile: caFile},
+		},
+		{
+			name:      "it fails for a certificate not signed by the CA",
+			tlsConfig: RemotePluginTLSConfig{CAFile: otherCAFile},
+			expectErr: true,
+		},
+		{
+			name:      "it fails for a server name not in the certificate",
+			tlsConfig: RemotePluginTLSConfig{CAFile: caFile, ServerName: "other.kubeapps.svc"},
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ps := &pluginsServer{}
+			_, err := ps.registerRemotePlugins([]RemotePluginConfig{
+				{Name: "remote.packages", Version: "v1alpha1", Address: "remote-plugin.kubeapps.svc:50051", TLS: tc.tlsConfig},
+			}, dialOptions)
+			if err != nil {
+				t.Fatalf("%+v", err)
+			}
+
+			_, err = ps.packagesPlugins[0].server.GetAvailablePackageSummaries(context.Background(), &packages.GetAvailablePackageSummariesRequest{})
+			if got, want := err != nil, tc.expectErr; got != want {
+				t.Errorf("got error: %v, want error: %t", err, want)
+			}
+		})
+	}
+}
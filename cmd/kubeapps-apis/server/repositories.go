@@ -0,0 +1,215 @@
+/*
+Copyright © 2021 VMware
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package server
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	packages "github.com/kubeapps/kubeapps/cmd/kubeapps-apis/gen/core/packages/v1alpha1"
+	plugins "github.com/kubeapps/kubeapps/cmd/kubeapps-apis/gen/core/plugins/v1alpha1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	log "k8s.io/klog/v2"
+)
+
+// repositoriesServer implements the API defined in proto/kubeappsapis/core/packages/v1alpha1/repositories.proto
+type repositoriesServer struct {
+	packages.UnimplementedRepositoriesServiceServer
+
+	// plugins is a slice of all registered plugins which satisfy the core.packages.v1alpha1
+	// repositories interface.
+	plugins []*repositoriesPluginWithServer
+
+	// pluginTimeout is the deadline for each plugin when a request is fanned
+	// out to all plugins.
+	pluginTimeout time.Duration
+}
+
+func NewRepositoriesServer(plugins []*repositoriesPluginWithServer, pluginTimeout time.Duration) *repositoriesServer {
+	if pluginTimeout <= 0 {
+		pluginTimeout = defaultPluginTimeout
+	}
+	return &repositoriesServer{
+		plugins:       plugins,
+		pluginTimeout: pluginTimeout,
+	}
+}
+
+// GetPackageRepositorySummaries returns the package repositories managed by
+// all registered plugins for the request context.
+func (s repositoriesServer) GetPackageRepositorySummaries(ctx context.Context, request *packages.GetPackageRepositorySummariesRequest) (*packages.GetPackageRepositorySummariesResponse, error) {
+	contextMsg := ""
+	if request.Context != nil {
+		contextMsg = fmt.Sprintf("(cluster=[%s], namespace=[%s])", request.Context.Cluster, request.Context.Namespace)
+	}
+
+	log.Infof("+core GetPackageRepositorySummaries %s", contextMsg)
+
+	pluginDetails := make([]*plugins.Plugin, len(s.plugins))
+	for i, p := range s.plugins {
+		pluginDetails[i] = p.plugin
+	}
+	results := fanOut(ctx, s.pluginTimeout, pluginDetails, func(ctx context.Context, i int) (interface{}, error) {
+		return s.plugins[i].server.GetPackageRepositorySummaries(ctx, request)
+	})
+	if err := allPluginsFailed(results); err != nil {
+		return nil, err
+	}
+
+	var pluginErrors []*packages.PluginError
+	summaries := []*packages.PackageRepositorySummary{}
+	for _, result := range results {
+		if result.err != nil {
+			log.Errorf("+core GetPackageRepositorySummaries: plugin %v failed: %v", result.plugin, result.err)
+			pluginErrors = append(pluginErrors, pluginErrorFor(result.plugin, result.err))
+			continue
+		}
+		response := result.response.(*packages.GetPackageRepositorySummariesResponse)
+
+		// Add the plugin for the repositories
+		for _, r := range response.PackageRepositorySummaries {
+			if r.PackageRepoRef == nil {
+				r.PackageRepoRef = &packages.PackageRepositoryReference{}
+			}
+			r.PackageRepoRef.Plugin = result.plugin
+		}
+		summaries = append(summaries, response.PackageRepositorySummaries...)
+	}
+
+	return &packages.GetPackageRepositorySummariesResponse{
+		PackageRepositorySummaries: summaries,
+		PluginErrors:               pluginErrors,
+	}, nil
+}
+
+// GetPackageRepositoryDetail returns the package repository detail from the
+// plugin specified in the package repository reference.
+func (s repositoriesServer) GetPackageRepositoryDetail(ctx context.Context, request *packages.GetPackageRepositoryDetailRequest) (*packages.GetPackageRepositoryDetailResponse, error) {
+	log.Infof("+core GetPackageRepositoryDetail (ref=[%v])", request.GetPackageRepoRef())
+
+	pluginWithServer, err := s.getPluginWithServer(request.GetPackageRepoRef().GetPlugin())
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := pluginWithServer.server.GetPackageRepositoryDetail(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	// Validate the plugin response and set the plugin on the ref.
+	if response.GetDetail().GetPackageRepoRef() == nil {
+		return nil, status.Errorf(codes.Internal, "package repository detail returned by plugin %v is missing the package repository reference", pluginWithServer.plugin)
+	}
+	response.Detail.PackageRepoRef.Plugin = pluginWithServer.plugin
+
+	return response, nil
+}
+
+// AddPackageRepository adds the package repository using the plugin
+// specified in the request.
+func (s repositoriesServer) AddPackageRepository(ctx context.Context, request *packages.AddPackageRepositoryRequest) (*packages.AddPackageRepositoryResponse, error) {
+	log.Infof("+core AddPackageRepository (plugin=[%v], name=[%s])", request.GetPlugin(), request.GetName())
+
+	pluginWithServer, err := s.getPluginWithServer(request.GetPlugin())
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := pluginWithServer.server.AddPackageRepository(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.GetPackageRepoRef() == nil {
+		return nil, status.Errorf(codes.Internal, "response returned by plugin %v is missing the package repository reference", pluginWithServer.plugin)
+	}
+	response.PackageRepoRef.Plugin = pluginWithServer.plugin
+
+	return response, nil
+}
+
+// UpdatePackageRepository updates the package repository using the plugin
+// specified in the package repository reference.
+func (s repositoriesServer) UpdatePackageRepository(ctx context.Context, request *packages.UpdatePackageRepositoryRequest) (*packages.UpdatePackageRepositoryResponse, error) {
+	log.Infof("+core UpdatePackageRepository (ref=[%v])", request.GetPackageRepoRef())
+
+	pluginWithServer, err := s.getPluginWithServer(request.GetPackageRepoRef().GetPlugin())
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := pluginWithServer.server.UpdatePackageRepository(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.GetPackageRepoRef() == nil {
+		return nil, status.Errorf(codes.Internal, "response returned by plugin %v is missing the package repository reference", pluginWithServer.plugin)
+	}
+	response.PackageRepoRef.Plugin = pluginWithServer.plugin
+
+	return response, nil
+}
+
+// DeletePackageRepository deletes the package repository using the plugin
+// specified in the package repository reference.
+func (s repositoriesServer) DeletePackageRepository(ctx context.Context, request *packages.DeletePackageRepositoryRequest) (*packages.DeletePackageRepositoryResponse, error) {
+	log.Infof("+core DeletePackageRepository (ref=[%v])", request.GetPackageRepoRef())
+
+	pluginWithServer, err := s.getPluginWithServer(request.GetPackageRepoRef().GetPlugin())
+	if err != nil {
+		return nil, err
+	}
+
+	return pluginWithServer.server.DeletePackageRepository(ctx, request)
+}
+
+// RefreshPackageRepository refreshes the package repository using the plugin
+// specified in the package repository reference.
+func (s repositoriesServer) RefreshPackageRepository(ctx context.Context, request *packages.RefreshPackageRepositoryRequest) (*packages.RefreshPackageRepositoryResponse, error) {
+	log.Infof("+core RefreshPackageRepository (ref=[%v])", request.GetPackageRepoRef())
+
+	pluginWithServer, err := s.getPluginWithServer(request.GetPackageRepoRef().GetPlugin())
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := pluginWithServer.server.RefreshPackageRepository(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.GetPackageRepoRef() == nil {
+		return nil, status.Errorf(codes.Internal, "response returned by plugin %v is missing the package repository reference", pluginWithServer.plugin)
+	}
+	response.PackageRepoRef.Plugin = pluginWithServer.plugin
+
+	return response, nil
+}
+
+// getPluginWithServer returns the registered plugin server matching the
+// requested plugin name and version.
+func (s repositoriesServer) getPluginWithServer(plugin *plugins.Plugin) (*repositoriesPluginWithServer, error) {
+	if plugin == nil {
+		return nil, status.Errorf(codes.InvalidArgument, "Unable to determine the plugin from the request")
+	}
+	for _, p := range s.plugins {
+		if p.plugin.Name == plugin.Name && p.plugin.Version == plugin.Version {
+			return p, nil
+		}
+	}
+	return nil, status.Errorf(codes.InvalidArgument, "Unable to find a registered plugin %s/%s", plugin.Name, plugin.Version)
+}
@@ -0,0 +1,436 @@
+/*
+Copyright © 2021 VMware
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	packages "github.com/kubeapps/kubeapps/cmd/kubeapps-apis/gen/core/packages/v1alpha1"
+	plugins "github.com/kubeapps/kubeapps/cmd/kubeapps-apis/gen/core/plugins/v1alpha1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var ignoreUnexportedRepoOpts = cmpopts.IgnoreUnexported(
+	packages.GetPackageRepositorySummariesResponse{},
+	packages.GetPackageRepositoryDetailResponse{},
+	packages.AddPackageRepositoryResponse{},
+	packages.UpdatePackageRepositoryResponse{},
+	packages.RefreshPackageRepositoryResponse{},
+	packages.PackageRepositorySummary{},
+	packages.PackageRepositoryDetail{},
+	packages.PackageRepositoryReference{},
+	packages.PluginError{},
+	packages.Context{},
+	plugins.Plugin{},
+)
+
+// testRepositoriesPluginServer is a minimal repositories plugin server
+// returning canned responses.
+type testRepositoriesPluginServer struct {
+	packages.UnimplementedRepositoriesServiceServer
+	repositorySummaries []*packages.PackageRepositorySummary
+	repositoryDetail    *packages.PackageRepositoryDetail
+	// err, when set, is returned by the summaries rpc.
+	err error
+}
+
+func makePackageRepositorySummary(identifier string) *packages.PackageRepositorySummary {
+	return &packages.PackageRepositorySummary{
+		PackageRepoRef: &packages.PackageRepositoryReference{
+			Context:    &packages.Context{Namespace: "default"},
+			Identifier: identifier,
+		},
+		Name: identifier,
+	}
+}
+
+func (s testRepositoriesPluginServer) GetPackageRepositorySummaries(ctx context.Context, request *packages.GetPackageRepositorySummariesRequest) (*packages.GetPackageRepositorySummariesResponse, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &packages.GetPackageRepositorySummariesResponse{
+		PackageRepositorySummaries: s.repositorySummaries,
+	}, nil
+}
+
+func (s testRepositoriesPluginServer) GetPackageRepositoryDetail(ctx context.Context, request *packages.GetPackageRepositoryDetailRequest) (*packages.GetPackageRepositoryDetailResponse, error) {
+	return &packages.GetPackageRepositoryDetailResponse{
+		Detail: s.repositoryDetail,
+	}, nil
+}
+
+func (s testRepositoriesPluginServer) AddPackageRepository(ctx context.Context, request *packages.AddPackageRepositoryRequest) (*packages.AddPackageRepositoryResponse, error) {
+	return &packages.AddPackageRepositoryResponse{
+		PackageRepoRef: &packages.PackageRepositoryReference{
+			Context:    request.Context,
+			Identifier: request.Name,
+		},
+	}, nil
+}
+
+func (s testRepositoriesPluginServer) UpdatePackageRepository(ctx context.Context, request *packages.UpdatePackageRepositoryRequest) (*packages.UpdatePackageRepositoryResponse, error) {
+	return &packages.UpdatePackageRepositoryResponse{
+		PackageRepoRef: &packages.PackageRepositoryReference{
+			Context:    request.PackageRepoRef.Context,
+			Identifier: request.PackageRepoRef.Identifier,
+		},
+	}, nil
+}
+
+func (s testRepositoriesPluginServer) DeletePackageRepository(ctx context.Context, request *packages.DeletePackageRepositoryRequest) (*packages.DeletePackageRepositoryResponse, error) {
+	return &packages.DeletePackageRepositoryResponse{}, nil
+}
+
+func (s testRepositoriesPluginServer) RefreshPackageRepository(ctx context.Context, request *packages.RefreshPackageRepositoryRequest) (*packages.RefreshPackageRepositoryResponse, error) {
+	return &packages.RefreshPackageRepositoryResponse{
+		PackageRepoRef: &packages.PackageRepositoryReference{
+			Context:    request.PackageRepoRef.Context,
+			Identifier: request.PackageRepoRef.Identifier,
+		},
+	}, nil
+}
+
+func TestGetPackageRepositorySummaries(t *testing.T) {
+	testCases := []struct {
+		name              string
+		configuredPlugins []*repositoriesPluginWithServer
+		expectedResponse  *packages.GetPackageRepositorySummariesResponse
+		statusCode        codes.Code
+	}{
+		{
+			name: "it aggregates the repositories of all plugins, setting the plugin on the refs",
+			configuredPlugins: []*repositoriesPluginWithServer{
+				{
+					plugin: mockedPackagingPlugin1,
+					server: testRepositoriesPluginServer{
+						repositorySummaries: []*packages.PackageRepositorySummary{
+							makePackageRepositorySummary("repo-1"),
+						},
+					},
+				},
+				{
+					plugin: mockedPackagingPlugin2,
+					server: testRepositoriesPluginServer{
+						repositorySummaries: []*packages.PackageRepositorySummary{
+							makePackageRepositorySummary("repo-2"),
+							makePackageRepositorySummary("repo-3"),
+						},
+					},
+				},
+			},
+			expectedResponse: &packages.GetPackageRepositorySummariesResponse{
+				PackageRepositorySummaries: []*packages.PackageRepositorySummary{
+					{
+						PackageRepoRef: &packages.PackageRepositoryReference{
+							Context:    &packages.Context{Namespace: "default"},
+							Identifier: "repo-1",
+							Plugin:     mockedPackagingPlugin1,
+						},
+						Name: "repo-1",
+					},
+					{
+						PackageRepoRef: &packages.PackageRepositoryReference{
+							Context:    &packages.Context{Namespace: "default"},
+							Identifier: "repo-2",
+							Plugin:     mockedPackagingPlugin2,
+						},
+						Name: "repo-2",
+					},
+					{
+						PackageRepoRef: &packages.PackageRepositoryReference{
+							Context:    &packages.Context{Namespace: "default"},
+							Identifier: "repo-3",
+							Plugin:     mockedPackagingPlugin2,
+						},
+						Name: "repo-3",
+					},
+				},
+			},
+		},
+		{
+			name: "it returns the repositories of the other plugins when a plugin fails",
+			configuredPlugins: []*repositoriesPluginWithServer{
+				{
+					plugin: mockedPackagingPlugin1,
+					server: testRepositoriesPluginServer{
+						err: status.Errorf(codes.PermissionDenied, "not allowed"),
+					},
+				},
+				{
+					plugin: mockedPackagingPlugin2,
+					server: testRepositoriesPluginServer{
+						repositorySummaries: []*packages.PackageRepositorySummary{
+							makePackageRepositorySummary("repo-2"),
+						},
+					},
+				},
+			},
+			expectedResponse: &packages.GetPackageRepositorySummariesResponse{
+				PackageRepositorySummaries: []*packages.PackageRepositorySummary{
+					{
+						PackageRepoRef: &packages.PackageRepositoryReference{
+							Context:    &packages.Context{Namespace: "default"},
+							Identifier: "repo-2",
+							Plugin:     mockedPackagingPlugin2,
+						},
+						Name: "repo-2",
+					},
+				},
+				PluginErrors: []*packages.PluginError{
+					{
+						Plugin:       mockedPackagingPlugin1,
+						StatusCode:   int32(codes.PermissionDenied),
+						ErrorMessage: "not allowed",
+					},
+				},
+			},
+		},
+		{
+			name: "it returns the error when all plugins fail",
+			configuredPlugins: []*repositoriesPluginWithServer{
+				{
+					plugin: mockedPackagingPlugin1,
+					server: testRepositoriesPluginServer{
+						err: status.Errorf(codes.PermissionDenied, "not allowed"),
+					},
+				},
+			},
+			statusCode: codes.PermissionDenied,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := NewRepositoriesServer(tc.configuredPlugins, 0)
+
+			response, err := server.GetPackageRepositorySummaries(context.Background(), &packages.GetPackageRepositorySummariesRequest{Context: &packages.Context{}})
+
+			if got, want := status.Code(err), tc.statusCode; got != want {
+				t.Fatalf("got: %+v, want: %+v, err: %+v", got, want, err)
+			}
+			if tc.statusCode != codes.OK {
+				return
+			}
+
+			if got, want := response, tc.expectedResponse; !cmp.Equal(got, want, ignoreUnexportedRepoOpts) {
+				t.Errorf("mismatch (-want +got):\n%s", cmp.Diff(want, got, ignoreUnexportedRepoOpts))
+			}
+		})
+	}
+}
+
+func TestGetPackageRepositoryDetail(t *testing.T) {
+	configuredPlugins := []*repositoriesPluginWithServer{
+		{
+			plugin: mockedPackagingPlugin1,
+			server: testRepositoriesPluginServer{
+				repositoryDetail: &packages.PackageRepositoryDetail{
+					PackageRepoRef: &packages.PackageRepositoryReference{Identifier: "repo-1"},
+					Name:           "repo-1",
+				},
+			},
+		},
+		{
+			plugin: mockedPackagingPlugin2,
+			server: testRepositoriesPluginServer{
+				repositoryDetail: &packages.PackageRepositoryDetail{
+					PackageRepoRef: &packages.PackageRepositoryReference{Identifier: "repo-2"},
+					Name:           "repo-2",
+				},
+			},
+		},
+	}
+
+	testCases := []struct {
+		name             string
+		request          *packages.GetPackageRepositoryDetailRequest
+		expectedResponse *packages.GetPackageRepositoryDetailResponse
+		statusCode       codes.Code
+	}{
+		{
+			name: "it routes the request to the plugin in the package repository reference",
+			request: &packages.GetPackageRepositoryDetailRequest{
+				PackageRepoRef: &packages.PackageRepositoryReference{
+					Identifier: "repo-2",
+					Plugin:     mockedPackagingPlugin2,
+				},
+			},
+			expectedResponse: &packages.GetPackageRepositoryDetailResponse{
+				Detail: &packages.PackageRepositoryDetail{
+					PackageRepoRef: &packages.PackageRepositoryReference{
+						Identifier: "repo-2",
+						Plugin:     mockedPackagingPlugin2,
+					},
+					Name: "repo-2",
+				},
+			},
+		},
+		{
+			name: "it returns an invalid argument error if the plugin is missing",
+			request: &packages.GetPackageRepositoryDetailRequest{
+				PackageRepoRef: &packages.PackageRepositoryReference{
+					Identifier: "repo-2",
+				},
+			},
+			statusCode: codes.InvalidArgument,
+		},
+		{
+			name: "it returns an invalid argument error if the plugin is not registered",
+			request: &packages.GetPackageRepositoryDetailRequest{
+				PackageRepoRef: &packages.PackageRepositoryReference{
+					Identifier: "repo-2",
+					Plugin:     makeDefaultTestPackagingPlugin("unknown"),
+				},
+			},
+			statusCode: codes.InvalidArgument,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := NewRepositoriesServer(configuredPlugins, 0)
+
+			response, err := server.GetPackageRepositoryDetail(context.Background(), tc.request)
+
+			if got, want := status.Code(err), tc.statusCode; got != want {
+				t.Fatalf("got: %+v, want: %+v, err: %+v", got, want, err)
+			}
+			if tc.statusCode != codes.OK {
+				return
+			}
+
+			if got, want := response, tc.expectedResponse; !cmp.Equal(got, want, ignoreUnexportedRepoOpts) {
+				t.Errorf("mismatch (-want +got):\n%s", cmp.Diff(want, got, ignoreUnexportedRepoOpts))
+			}
+		})
+	}
+}
+
+func TestModifyPackageRepository(t *testing.T) {
+	configuredPlugins := []*repositoriesPluginWithServer{
+		{
+			plugin: mockedPackagingPlugin1,
+			server: testRepositoriesPluginServer{},
+		},
+		{
+			plugin: mockedPackagingPlugin2,
+			server: testRepositoriesPluginServer{},
+		},
+	}
+	server := NewRepositoriesServer(configuredPlugins, 0)
+
+	repoRef := func(plugin *plugins.Plugin) *packages.PackageRepositoryReference {
+		return &packages.PackageRepositoryReference{
+			Context:    &packages.Context{Namespace: "default"},
+			Identifier: "repo-1",
+			Plugin:     plugin,
+		}
+	}
+
+	testCases := []struct {
+		name       string
+		call       func() (*packages.PackageRepositoryReference, error)
+		statusCode codes.Code
+	}{
+		{
+			name: "it adds the repository with the requested plugin",
+			call: func() (*packages.PackageRepositoryReference, error) {
+				response, err := server.AddPackageRepository(context.Background(), &packages.AddPackageRepositoryRequest{
+					Context: &packages.Context{Namespace: "default"},
+					Name:    "repo-1",
+					Plugin:  mockedPackagingPlugin2,
+				})
+				return response.GetPackageRepoRef(), err
+			},
+		},
+		{
+			name: "it returns an invalid argument error adding a repository without a plugin",
+			call: func() (*packages.PackageRepositoryReference, error) {
+				response, err := server.AddPackageRepository(context.Background(), &packages.AddPackageRepositoryRequest{
+					Context: &packages.Context{Namespace: "default"},
+					Name:    "repo-1",
+				})
+				return response.GetPackageRepoRef(), err
+			},
+			statusCode: codes.InvalidArgument,
+		},
+		{
+			name: "it updates the repository with the plugin in the reference",
+			call: func() (*packages.PackageRepositoryReference, error) {
+				response, err := server.UpdatePackageRepository(context.Background(), &packages.UpdatePackageRepositoryRequest{
+					PackageRepoRef: repoRef(mockedPackagingPlugin2),
+				})
+				return response.GetPackageRepoRef(), err
+			},
+		},
+		{
+			name: "it returns an invalid argument error updating a repository of an unknown plugin",
+			call: func() (*packages.PackageRepositoryReference, error) {
+				response, err := server.UpdatePackageRepository(context.Background(), &packages.UpdatePackageRepositoryRequest{
+					PackageRepoRef: repoRef(makeDefaultTestPackagingPlugin("unknown")),
+				})
+				return response.GetPackageRepoRef(), err
+			},
+			statusCode: codes.InvalidArgument,
+		},
+		{
+			name: "it refreshes the repository with the plugin in the reference",
+			call: func() (*packages.PackageRepositoryReference, error) {
+				response, err := server.RefreshPackageRepository(context.Background(), &packages.RefreshPackageRepositoryRequest{
+					PackageRepoRef: repoRef(mockedPackagingPlugin2),
+				})
+				return response.GetPackageRepoRef(), err
+			},
+		},
+		{
+			name: "it deletes the repository with the plugin in the reference",
+			call: func() (*packages.PackageRepositoryReference, error) {
+				_, err := server.DeletePackageRepository(context.Background(), &packages.DeletePackageRepositoryRequest{
+					PackageRepoRef: repoRef(mockedPackagingPlugin2),
+				})
+				return repoRef(mockedPackagingPlugin2), err
+			},
+		},
+		{
+			name: "it returns an invalid argument error deleting a repository without a plugin",
+			call: func() (*packages.PackageRepositoryReference, error) {
+				_, err := server.DeletePackageRepository(context.Background(), &packages.DeletePackageRepositoryRequest{
+					PackageRepoRef: repoRef(nil),
+				})
+				return nil, err
+			},
+			statusCode: codes.InvalidArgument,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ref, err := tc.call()
+
+			if got, want := status.Code(err), tc.statusCode; got != want {
+				t.Fatalf("got: %+v, want: %+v, err: %+v", got, want, err)
+			}
+			if tc.statusCode != codes.OK {
+				return
+			}
+
+			if got, want := ref, repoRef(mockedPackagingPlugin2); !cmp.Equal(got, want, ignoreUnexportedRepoOpts) {
+				t.Errorf("mismatch (-want +got):\n%s", cmp.Diff(want, got, ignoreUnexportedRepoOpts))
+			}
+		})
+	}
+}
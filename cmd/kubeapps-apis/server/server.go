@@ -18,16 +18,25 @@ package server
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/soheilhy/cmux"
 
 	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/improbable-eng/grpc-web/go/grpcweb"
 	packages "github.com/kubeapps/kubeapps/cmd/kubeapps-apis/gen/core/packages/v1alpha1"
 	plugins "github.com/kubeapps/kubeapps/cmd/kubeapps-apis/gen/core/plugins/v1alpha1"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/reflection"
 	"google.golang.org/protobuf/encoding/protojson"
 	log "k8s.io/klog/v2"
@@ -38,6 +47,34 @@ type ServeOptions struct {
 	PluginDirs         []string
 	ClustersConfigPath string
 	PinnipedProxyURL   string
+	PluginTimeout      time.Duration
+	// RemotePlugins are the plugins running out of process, read from the config file.
+	RemotePlugins []RemotePluginConfig
+	// PluginConfigs are the configuration sections for the plugins, keyed by
+	// plugin name, read from the config file.
+	PluginConfigs map[string]PluginConfig
+	// TokenReview enables resolving the identity of the user sending each
+	// request with a TokenReview, rejecting invalid tokens early.
+	TokenReview bool
+	// OTLPEndpoint is the address of the OpenTelemetry collector to which the
+	// traces are exported. No traces are exported when empty.
+	OTLPEndpoint string
+	// TLSCertFile and TLSKeyFile enable TLS for both the gRPC and HTTP requests.
+	TLSCertFile string
+	TLSKeyFile  string
+	// TLSClientCAFile requires the gRPC clients, including the gateway, to
+	// present a certificate signed by this CA when TLS is enabled. The gRPC
+	// requests are then served on MTLSPort only.
+	TLSClientCAFile string
+	// MTLSPort is the port of the listener serving the gRPC requests, which
+	// requires client certificates, when a TLS client CA is configured.
+	MTLSPort int
+	// GRPCWebAllowedOrigins are the origins allowed to send cross-origin
+	// gRPC-Web requests, or "*" for any origin.
+	GRPCWebAllowedOrigins []string
+	// ShutdownTimeout is the time to wait for the in-flight requests to
+	// finish when the server is stopped.
+	ShutdownTimeout time.Duration
 	//temporary flags while this component in under heavy development
 	UnsafeUseDemoSA          bool
 	UnsafeLocalDevKubeconfig bool
@@ -46,28 +83,97 @@ type ServeOptions struct {
 // Serve is the root command that is run when no other sub-commands are present.
 // It runs the gRPC service, registering the configured plugins.
 func Serve(serveOpts ServeOptions) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	shutdownTracing, err := initTracing(ctx, serveOpts.OTLPEndpoint)
+	if err != nil {
+		log.Fatalf("failed to initialize tracing: %v", err)
+	}
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			log.Errorf("failed to flush the traces: %v", err)
+		}
+	}()
+
 	// Create the grpc server and register the reflection server (for now, useful for discovery
 	// using grpcurl) or similar.
-	grpcSrv := grpc.NewServer()
+	// Every request is traced and measured and then authenticated, and audited when
+	// mutating, by the interceptors, for both the gRPC and HTTP requests which go
+	// through the gateway.
+	authInterceptor, err := newAuthInterceptor(serveOpts)
+	if err != nil {
+		log.Fatalf("failed to initialize the auth interceptor: %v", err)
+	}
+	grpcSrv := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(tracingUnaryInterceptor, metricsUnaryInterceptor, authInterceptor.unaryInterceptor),
+		grpc.ChainStreamInterceptor(tracingStreamInterceptor, metricsStreamInterceptor, authInterceptor.streamInterceptor),
+	)
 	reflection.Register(grpcSrv)
 
+	// When TLS is enabled, the gateway calls the gRPC server over TLS too. With
+	// mTLS, the gRPC requests are served on their own listener, which requires
+	// client certificates, and the gateway authenticates there with the
+	// certificate of the server.
+	gwCredentials := grpc.WithInsecure()
+	var serverTLSConfig, publicTLSConfig *tls.Config
+	if serveOpts.tlsEnabled() {
+		serverTLSConfig, err = newServerTLSConfig(serveOpts)
+		if err != nil {
+			log.Fatalf("failed to initialize TLS: %v", err)
+		}
+		publicTLSConfig = newPublicTLSConfig(serverTLSConfig)
+		gwCredentials = grpc.WithTransportCredentials(credentials.NewTLS(newGatewayTLSConfig(serverTLSConfig)))
+	}
+
 	// Create the http server, register our core service followed by any plugins.
+	// The gateway propagates the trace context with the requests it sends.
 	listenAddr := fmt.Sprintf(":%d", serveOpts.Port)
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+	grpcAddr := listenAddr
+	if serveOpts.mTLSEnabled() {
+		if serveOpts.MTLSPort == serveOpts.Port {
+			log.Fatalf("the mTLS port must differ from the port %d", serveOpts.Port)
+		}
+		grpcAddr = fmt.Sprintf(":%d", serveOpts.MTLSPort)
+	}
 	gwArgs := gwHandlerArgs{
-		ctx:         ctx,
-		mux:         gatewayMux(),
-		addr:        listenAddr,
-		dialOptions: []grpc.DialOption{grpc.WithInsecure()},
+		ctx:  ctx,
+		mux:  gatewayMux(),
+		addr: grpcAddr,
+		dialOptions: []grpc.DialOption{
+			gwCredentials,
+			grpc.WithChainUnaryInterceptor(tracingUnaryClientInterceptor),
+			grpc.WithChainStreamInterceptor(tracingStreamClientInterceptor),
+		},
+	}
+	httpMux := http.NewServeMux()
+	httpMux.Handle("/metrics", promhttp.Handler())
+	httpMux.Handle("/", tracingHandler(gwArgs.mux))
+
+	// Browsers can call the gRPC services directly with gRPC-Web, without a proxy.
+	// With mTLS, the gRPC requests are not served on the public listener.
+	grpcWebSrv := grpcweb.WrapServer(grpcSrv, grpcweb.WithOriginFunc(allowedOriginFunc(serveOpts.GRPCWebAllowedOrigins)))
+	publicGRPCSrv := grpcSrv
+	if serveOpts.mTLSEnabled() {
+		publicGRPCSrv = nil
 	}
 	httpSrv := &http.Server{
-		Handler: gwArgs.mux,
+		Handler:   grpcHandler(publicGRPCSrv, grpcWebSrv, httpMux),
+		TLSConfig: publicTLSConfig,
+	}
+	httpSrvs := []*http.Server{httpSrv}
+
+	// The config getter is used by the plugins for the lifetime of the server, so the CA
+	// files of the additional clusters it references are only removed on exit.
+	configGetter, kubeappsCluster, cleanupCAFiles, err := createConfigGetter(serveOpts)
+	if err != nil {
+		log.Fatalf("failed to create a ConfigGetter: %v", err)
 	}
+	defer cleanupCAFiles()
 
 	// Create the core.plugins server which handles registration of plugins,
 	// and register it for both grpc and http.
-	pluginsServer, err := NewPluginsServer(serveOpts, grpcSrv, gwArgs)
+	pluginsServer, err := NewPluginsServer(serveOpts, configGetter, kubeappsCluster, grpcSrv, gwArgs)
 	if err != nil {
 		log.Fatalf("failed to initialize plugins server: %v", err)
 	}
@@ -78,35 +184,146 @@ func Serve(serveOpts ServeOptions) {
 	}
 
 	// Create the core.packages server and register it for both grpc and http.
-	packages.RegisterPackagesServiceServer(grpcSrv, NewPackagesServer(pluginsServer.packagesPlugins))
+	packages.RegisterPackagesServiceServer(grpcSrv, NewPackagesServer(pluginsServer.packagesPlugins, serveOpts.PluginTimeout))
 	err = packages.RegisterPackagesServiceHandlerFromEndpoint(gwArgs.ctx, gwArgs.mux, gwArgs.addr, gwArgs.dialOptions)
 	if err != nil {
 		log.Fatalf("failed to register core.packages handler for gateway: %v", err)
 	}
 
+	// Create the core.packages repositories server and register it for both grpc and http.
+	packages.RegisterRepositoriesServiceServer(grpcSrv, NewRepositoriesServer(pluginsServer.repositoriesPlugins, serveOpts.PluginTimeout))
+	err = packages.RegisterRepositoriesServiceHandlerFromEndpoint(gwArgs.ctx, gwArgs.mux, gwArgs.addr, gwArgs.dialOptions)
+	if err != nil {
+		log.Fatalf("failed to register core.packages repositories handler for gateway: %v", err)
+	}
+
 	lis, err := net.Listen("tcp", listenAddr)
 	if err != nil {
 		log.Fatalf("failed to listen: %v", err)
 	}
 
-	// Multiplex the connection between grpc and http.
-	// Note: due to a change in the grpc protocol, it's no longer possible to just match
-	// on the simpler cmux.HTTP2HeaderField("content-type", "application/grpc"). More details
-	// at https://github.com/soheilhy/cmux/issues/64
-	mux := cmux.New(lis)
-	grpcLis := mux.MatchWithWriters(cmux.HTTP2MatchHeaderFieldSendSettings("content-type", "application/grpc"))
-	httpLis := mux.Match(cmux.Any())
+	serveErr := make(chan error, 2)
+	stopAccepting := func() {}
+	if serveOpts.mTLSEnabled() {
+		// The gRPC requests, including those of the gateway, are only served to the
+		// clients presenting a certificate signed by the client CA.
+		grpcLis, err := net.Listen("tcp", grpcAddr)
+		if err != nil {
+			log.Fatalf("failed to listen: %v", err)
+		}
+		grpcHTTPSrv := &http.Server{
+			Handler:   grpcSrv,
+			TLSConfig: serverTLSConfig,
+		}
+		// The public listener is shut down first as the gateway sends requests to this one.
+		httpSrvs = append(httpSrvs, grpcHTTPSrv)
+		go func() {
+			serveErr <- grpcHTTPSrv.ServeTLS(grpcLis, "", "")
+		}()
+		log.Infof("Serving the gRPC requests with mTLS on %s", grpcAddr)
+	}
+	if serverTLSConfig != nil {
+		// The HTTP server negotiates HTTP/2 with the clients over TLS and
+		// hands the gRPC requests to the gRPC server.
+		go func() {
+			serveErr <- httpSrv.ServeTLS(lis, "", "")
+		}()
+	} else {
+		// Multiplex the connection between grpc and http.
+		// Note: due to a change in the grpc protocol, it's no longer possible to just match
+		// on the simpler cmux.HTTP2HeaderField("content-type", "application/grpc"). More details
+		// at https://github.com/soheilhy/cmux/issues/64
+		mux := cmux.New(lis)
+		grpcLis := mux.MatchWithWriters(cmux.HTTP2MatchHeaderFieldSendSettings("content-type", "application/grpc"))
+		httpLis := mux.Match(cmux.Any())
 
-	go grpcSrv.Serve(grpcLis)
-	go httpSrv.Serve(httpLis)
+		go grpcSrv.Serve(grpcLis)
+		go httpSrv.Serve(httpLis)
+		go func() {
+			serveErr <- mux.Serve()
+		}()
+		stopAccepting = mux.Close
+	}
 
 	if serveOpts.UnsafeUseDemoSA {
 		log.Warning("Using the demo Service Account for authenticating the requests. This is not recommended except for development purposes. Set `kubeappsapis.unsafeUseDemoSA: false` to remove this warning")
 	}
 
 	log.Infof("Starting server on :%d", serveOpts.Port)
-	if err := mux.Serve(); err != nil {
+
+	// Catch SIGINT and SIGTERM to drain the in-flight requests before exiting.
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	select {
+	case err := <-serveErr:
 		log.Fatalf("failed to serve: %v", err)
+	case s := <-signals:
+		log.Infof("Received signal: %v. Waiting for in-flight requests to finish", s)
+	}
+
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), serveOpts.ShutdownTimeout)
+	defer shutdownCancel()
+	stopAccepting()
+	gracefulShutdown(shutdownCtx, grpcSrv, httpSrvs...)
+	log.Info("All requests have been served. Exiting")
+}
+
+// gracefulShutdown waits for the in-flight requests to finish until the
+// context is done. The HTTP servers, which serve the gateway and gRPC-Web
+// requests, are shut down first, in order, as they send requests to the gRPC
+// server, which can only be stopped gracefully once no request is served
+// through HTTP.
+func gracefulShutdown(ctx context.Context, grpcSrv *grpc.Server, httpSrvs ...*http.Server) {
+	for _, httpSrv := range httpSrvs {
+		if err := httpSrv.Shutdown(ctx); err != nil {
+			log.Errorf("failed to wait for the HTTP requests to finish: %v", err)
+			for _, srv := range httpSrvs {
+				srv.Close()
+			}
+			grpcSrv.Stop()
+			return
+		}
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		grpcSrv.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		log.Errorf("failed to wait for the gRPC requests to finish: %v", ctx.Err())
+		grpcSrv.Stop()
+	}
+}
+
+// grpcHandler serves the gRPC-Web requests, as well as the gRPC requests
+// received by the HTTP server when it terminates TLS unless grpcSrv is nil, with
+// the gRPC server and any other request with the given handler.
+func grpcHandler(grpcSrv *grpc.Server, grpcWebSrv *grpcweb.WrappedGrpcServer, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case grpcWebSrv.IsGrpcWebRequest(r) || grpcWebSrv.IsAcceptableGrpcCorsRequest(r):
+			grpcWebSrv.ServeHTTP(w, r)
+		case grpcSrv != nil && r.ProtoMajor == 2 && strings.HasPrefix(r.Header.Get("content-type"), "application/grpc"):
+			grpcSrv.ServeHTTP(w, r)
+		default:
+			handler.ServeHTTP(w, r)
+		}
+	})
+}
+
+// allowedOriginFunc returns the function allowing the cross-origin gRPC-Web
+// requests from the given origins, or from any origin with "*".
+func allowedOriginFunc(allowedOrigins []string) func(string) bool {
+	return func(origin string) bool {
+		for _, allowed := range allowedOrigins {
+			if allowed == "*" || allowed == origin {
+				return true
+			}
+		}
+		return false
 	}
 }
 
@@ -0,0 +1,172 @@
+/*
+Copyright © 2021 VMware
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package server
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/improbable-eng/grpc-web/go/grpcweb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestAllowedOriginFunc(t *testing.T) {
+	testCases := []struct {
+		name           string
+		allowedOrigins []string
+		origin         string
+		expected       bool
+	}{
+		{"it denies cross-origin requests by default", nil, "https://example.com", false},
+		{"it allows a configured origin", []string{"https://kubeapps.example.com"}, "https://kubeapps.example.com", true},
+		{"it denies other origins", []string{"https://kubeapps.example.com"}, "https://example.com", false},
+		{"it allows any origin with a wildcard", []string{"*"}, "https://example.com", true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got, want := allowedOriginFunc(tc.allowedOrigins)(tc.origin), tc.expected; got != want {
+				t.Errorf("got: %t, want: %t", got, want)
+			}
+		})
+	}
+}
+
+func TestGrpcHandler(t *testing.T) {
+	grpcSrv := grpc.NewServer()
+	healthpb.RegisterHealthServer(grpcSrv, health.NewServer())
+	grpcWebSrv := grpcweb.WrapServer(grpcSrv)
+	fallback := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+	ts := httptest.NewServer(grpcHandler(grpcSrv, grpcWebSrv, fallback))
+	defer ts.Close()
+
+	testCases := []struct {
+		name           string
+		contentType    string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "it serves gRPC-Web requests with the gRPC server",
+			contentType:    "application/grpc-web+proto",
+			expectedStatus: http.StatusOK,
+			expectedBody:   "grpc-status: 0",
+		},
+		{
+			name:           "it serves other requests with the handler",
+			contentType:    "application/json",
+			expectedStatus: http.StatusTeapot,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// An empty HealthCheckRequest, framed as an uncompressed message.
+			body := bytes.NewReader([]byte{0, 0, 0, 0, 0})
+			response, err := http.Post(ts.URL+"/grpc.health.v1.Health/Check", tc.contentType, body)
+			if err != nil {
+				t.Fatalf("%+v", err)
+			}
+			defer response.Body.Close()
+			responseBody, err := ioutil.ReadAll(response.Body)
+			if err != nil {
+				t.Fatalf("%+v", err)
+			}
+
+			if got, want := response.StatusCode, tc.expectedStatus; got != want {
+				t.Errorf("got: %d, want: %d", got, want)
+			}
+			if got, want := string(responseBody), tc.expectedBody; !strings.Contains(got, want) {
+				t.Errorf("got: %q, want to contain: %q", got, want)
+			}
+		})
+	}
+}
+
+func TestGracefulShutdown(t *testing.T) {
+	testCases := []struct {
+		name            string
+		requestDuration time.Duration
+		timeout         time.Duration
+		expectedStatus  int
+	}{
+		{
+			name:            "it waits for the in-flight requests to finish",
+			requestDuration: 100 * time.Millisecond,
+			timeout:         10 * time.Second,
+			expectedStatus:  http.StatusOK,
+		},
+		{
+			name:            "it stops waiting after the timeout",
+			requestDuration: 10 * time.Second,
+			timeout:         100 * time.Millisecond,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			started := make(chan struct{})
+			httpSrv := &http.Server{
+				Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					close(started)
+					select {
+					case <-time.After(tc.requestDuration):
+					case <-r.Context().Done():
+					}
+					w.WriteHeader(http.StatusOK)
+				}),
+			}
+			lis, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatalf("%+v", err)
+			}
+			go httpSrv.Serve(lis)
+
+			statuses := make(chan int, 1)
+			go func() {
+				response, err := http.Get("http://" + lis.Addr().String())
+				if err != nil {
+					statuses <- 0
+					return
+				}
+				response.Body.Close()
+				statuses <- response.StatusCode
+			}()
+			<-started
+
+			ctx, cancel := context.WithTimeout(context.Background(), tc.timeout)
+			defer cancel()
+			start := time.Now()
+			gracefulShutdown(ctx, grpc.NewServer(), httpSrv)
+
+			if tc.expectedStatus != 0 {
+				if got, want := <-statuses, tc.expectedStatus; got != want {
+					t.Errorf("got: %d, want: %d", got, want)
+				}
+			} else if got, limit := time.Since(start), tc.requestDuration; got >= limit {
+				t.Errorf("got: shutdown after %s, want: before %s", got, limit)
+			}
+		})
+	}
+}
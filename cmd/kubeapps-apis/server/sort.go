@@ -0,0 +1,123 @@
+/*
+Copyright © 2021 VMware
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package server
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	packages "github.com/kubeapps/kubeapps/cmd/kubeapps-apis/gen/core/packages/v1alpha1"
+)
+
+// LessAvailablePackageSummaryFunc returns the function ordering available
+// package summaries as specified by the sort options, or nil if the options
+// do not specify a sort field. Plugins sorting their results in memory should
+// use it so that their order matches the order in which the core server merges
+// the results of all plugins.
+//
+// When sorting by latest version, the versions which are not semantic versions
+// are always sorted after the semantic ones, whatever the direction, and are
+// compared lexically between themselves.
+func LessAvailablePackageSummaryFunc(options *packages.SortOptions) func(a, b *packages.AvailablePackageSummary) bool {
+	var compareField func(a, b *packages.AvailablePackageSummary) int
+	// compareRank, when set, orders the summaries before the sort field,
+	// regardless of the direction.
+	var compareRank func(a, b *packages.AvailablePackageSummary) int
+	switch options.GetField() {
+	case packages.SortOptions_SORT_FIELD_NAME:
+		compareField = func(a, b *packages.AvailablePackageSummary) int {
+			return strings.Compare(a.Name, b.Name)
+		}
+	case packages.SortOptions_SORT_FIELD_DISPLAY_NAME:
+		compareField = func(a, b *packages.AvailablePackageSummary) int {
+			return strings.Compare(a.DisplayName, b.DisplayName)
+		}
+	case packages.SortOptions_SORT_FIELD_LATEST_VERSION:
+		compareField = func(a, b *packages.AvailablePackageSummary) int {
+			return compareVersions(a.LatestPkgVersion, b.LatestPkgVersion)
+		}
+		compareRank = func(a, b *packages.AvailablePackageSummary) int {
+			return compareVersionRanks(a.LatestPkgVersion, b.LatestPkgVersion)
+		}
+	case packages.SortOptions_SORT_FIELD_REPOSITORY:
+		compareField = func(a, b *packages.AvailablePackageSummary) int {
+			return strings.Compare(a.Repository, b.Repository)
+		}
+	default:
+		return nil
+	}
+
+	descending := options.GetDirection() == packages.SortOptions_SORT_DIRECTION_DESCENDING
+	return func(a, b *packages.AvailablePackageSummary) bool {
+		if compareRank != nil {
+			if rank := compareRank(a, b); rank != 0 {
+				return rank < 0
+			}
+		}
+		cmp := compareField(a, b)
+		if cmp == 0 {
+			cmp = strings.Compare(a.Name, b.Name)
+		}
+		if cmp == 0 {
+			cmp = strings.Compare(a.GetAvailablePackageRef().GetIdentifier(), b.GetAvailablePackageRef().GetIdentifier())
+		}
+		if descending {
+			return cmp > 0
+		}
+		return cmp < 0
+	}
+}
+
+// SortAvailablePackageSummaries sorts the summaries in place as specified by
+// the sort options, leaving them untouched if no sort field is specified.
+func SortAvailablePackageSummaries(summaries []*packages.AvailablePackageSummary, options *packages.SortOptions) {
+	less := LessAvailablePackageSummaryFunc(options)
+	if less == nil {
+		return
+	}
+	sort.SliceStable(summaries, func(i, j int) bool {
+		return less(summaries[i], summaries[j])
+	})
+}
+
+// compareVersions compares two versions semantically. Versions which are not
+// semantic versions compare after the semantic ones, and lexically between
+// themselves.
+func compareVersions(a, b string) int {
+	versionA, errA := semver.NewVersion(a)
+	versionB, errB := semver.NewVersion(b)
+	switch {
+	case errA == nil && errB == nil:
+		return versionA.Compare(versionB)
+	case errA == nil:
+		return -1
+	case errB == nil:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+// compareVersionRanks compares two versions only by whether they are semantic
+// versions, which rank first.
+func compareVersionRanks(a, b string) int {
+	_, errA := semver.NewVersion(a)
+	_, errB := semver.NewVersion(b)
+	switch {
+	case errA == nil && errB != nil:
+		return -1
+	case errA != nil && errB == nil:
+		return 1
+	}
+	return 0
+}
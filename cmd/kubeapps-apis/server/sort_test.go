@@ -0,0 +1,151 @@
+/*
+Copyright © 2021 VMware
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package server
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	packages "github.com/kubeapps/kubeapps/cmd/kubeapps-apis/gen/core/packages/v1alpha1"
+)
+
+func TestSortAvailablePackageSummaries(t *testing.T) {
+	summaries := func() []*packages.AvailablePackageSummary {
+		return []*packages.AvailablePackageSummary{
+			{
+				AvailablePackageRef: &packages.AvailablePackageReference{Identifier: "repo-b/apache"},
+				Name:                "apache",
+				DisplayName:         "Apache",
+				LatestPkgVersion:    "10.0.0",
+				Repository:          "repo-b",
+			},
+			{
+				AvailablePackageRef: &packages.AvailablePackageReference{Identifier: "repo-a/wordpress"},
+				Name:                "wordpress",
+				DisplayName:         "A WordPress",
+				LatestPkgVersion:    "9.1.0",
+				Repository:          "repo-a",
+			},
+			{
+				AvailablePackageRef: &packages.AvailablePackageReference{Identifier: "repo-a/apache"},
+				Name:                "apache",
+				DisplayName:         "Apache",
+				LatestPkgVersion:    "9.1.0-beta.1",
+				Repository:          "repo-a",
+			},
+		}
+	}
+
+	testCases := []struct {
+		name                string
+		options             *packages.SortOptions
+		expectedIdentifiers []string
+	}{
+		{
+			name:                "it leaves the summaries untouched without a sort field",
+			options:             &packages.SortOptions{Direction: packages.SortOptions_SORT_DIRECTION_DESCENDING},
+			expectedIdentifiers: []string{"repo-b/apache", "repo-a/wordpress", "repo-a/apache"},
+		},
+		{
+			name:                "it sorts by name, then by identifier",
+			options:             &packages.SortOptions{Field: packages.SortOptions_SORT_FIELD_NAME},
+			expectedIdentifiers: []string{"repo-a/apache", "repo-b/apache", "repo-a/wordpress"},
+		},
+		{
+			name: "it sorts by name in descending order",
+			options: &packages.SortOptions{
+				Field:     packages.SortOptions_SORT_FIELD_NAME,
+				Direction: packages.SortOptions_SORT_DIRECTION_DESCENDING,
+			},
+			expectedIdentifiers: []string{"repo-a/wordpress", "repo-b/apache", "repo-a/apache"},
+		},
+		{
+			name:                "it sorts by display name",
+			options:             &packages.SortOptions{Field: packages.SortOptions_SORT_FIELD_DISPLAY_NAME},
+			expectedIdentifiers: []string{"repo-a/wordpress", "repo-a/apache", "repo-b/apache"},
+		},
+		{
+			name:                "it sorts by latest version semantically",
+			options:             &packages.SortOptions{Field: packages.SortOptions_SORT_FIELD_LATEST_VERSION},
+			expectedIdentifiers: []string{"repo-a/apache", "repo-a/wordpress", "repo-b/apache"},
+		},
+		{
+			name:                "it sorts by repository, then by name",
+			options:             &packages.SortOptions{Field: packages.SortOptions_SORT_FIELD_REPOSITORY},
+			expectedIdentifiers: []string{"repo-a/apache", "repo-a/wordpress", "repo-b/apache"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			sorted := summaries()
+			SortAvailablePackageSummaries(sorted, tc.options)
+
+			identifiers := []string{}
+			for _, s := range sorted {
+				identifiers = append(identifiers, s.AvailablePackageRef.Identifier)
+			}
+			if got, want := identifiers, tc.expectedIdentifiers; !cmp.Equal(got, want) {
+				t.Errorf("mismatch (-want +got):\n%s", cmp.Diff(want, got))
+			}
+		})
+	}
+}
+
+func TestSortAvailablePackageSummariesByLatestVersion(t *testing.T) {
+	summaries := func() []*packages.AvailablePackageSummary {
+		summaries := []*packages.AvailablePackageSummary{}
+		for _, version := range []string{"latest", "2.0.0", "1.10.0", "", "1.2.3.4", "1.9.0-rc.1", "v1.9.0"} {
+			summaries = append(summaries, &packages.AvailablePackageSummary{
+				AvailablePackageRef: &packages.AvailablePackageReference{Identifier: "repo/pkg-" + version},
+				Name:                "pkg-" + version,
+				LatestPkgVersion:    version,
+			})
+		}
+		return summaries
+	}
+
+	testCases := []struct {
+		name             string
+		direction        packages.SortOptions_SortDirection
+		expectedVersions []string
+	}{
+		{
+			name:             "it sorts the versions which are not semantic versions last and lexically",
+			expectedVersions: []string{"1.9.0-rc.1", "v1.9.0", "1.10.0", "2.0.0", "", "1.2.3.4", "latest"},
+		},
+		{
+			name:             "it still sorts the versions which are not semantic versions last in descending order",
+			direction:        packages.SortOptions_SORT_DIRECTION_DESCENDING,
+			expectedVersions: []string{"2.0.0", "1.10.0", "v1.9.0", "1.9.0-rc.1", "latest", "1.2.3.4", ""},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			sorted := summaries()
+			SortAvailablePackageSummaries(sorted, &packages.SortOptions{
+				Field:     packages.SortOptions_SORT_FIELD_LATEST_VERSION,
+				Direction: tc.direction,
+			})
+
+			versions := []string{}
+			for _, s := range sorted {
+				versions = append(versions, s.LatestPkgVersion)
+			}
+			if got, want := versions, tc.expectedVersions; !cmp.Equal(got, want) {
+				t.Errorf("mismatch (-want +got):\n%s", cmp.Diff(want, got))
+			}
+		})
+	}
+}
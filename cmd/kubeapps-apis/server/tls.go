@@ -0,0 +1,101 @@
+/*
+Copyright © 2021 VMware
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+)
+
+// tlsEnabled returns whether the server is configured to terminate TLS.
+func (o ServeOptions) tlsEnabled() bool {
+	return o.TLSCertFile != "" || o.TLSKeyFile != ""
+}
+
+// mTLSEnabled returns whether the gRPC requests require a client certificate.
+func (o ServeOptions) mTLSEnabled() bool {
+	return o.tlsEnabled() && o.TLSClientCAFile != ""
+}
+
+// newServerTLSConfig returns the TLS config of the gRPC server which, if a client
+// CA is configured, requires the clients, including the gateway, to present a
+// certificate signed by it.
+func newServerTLSConfig(serveOpts ServeOptions) (*tls.Config, error) {
+	if serveOpts.TLSCertFile == "" || serveOpts.TLSKeyFile == "" {
+		return nil, fmt.Errorf("both the TLS certificate and key files are required to enable TLS")
+	}
+	cert, err := tls.LoadX509KeyPair(serveOpts.TLSCertFile, serveOpts.TLSKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load the TLS certificate and key: %w", err)
+	}
+	if cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+		return nil, fmt.Errorf("unable to parse the TLS certificate: %w", err)
+	}
+
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+		NextProtos:   []string{"h2", "http/1.1"},
+	}
+	if serveOpts.TLSClientCAFile != "" {
+		caBytes, err := ioutil.ReadFile(serveOpts.TLSClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read the TLS client CA file: %w", err)
+		}
+		clientCAs := x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(caBytes) {
+			return nil, fmt.Errorf("no certificate found in the TLS client CA file %q", serveOpts.TLSClientCAFile)
+		}
+		// The gateway authenticates with the certificate of the server itself.
+		clientCAs.AddCert(cert.Leaf)
+		config.ClientCAs = clientCAs
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return config, nil
+}
+
+// newPublicTLSConfig returns the TLS config of the listener serving the browsers and
+// HTTP clients, with gRPC-Web and the gateway, which do not present client certificates.
+func newPublicTLSConfig(serverConfig *tls.Config) *tls.Config {
+	config := serverConfig.Clone()
+	config.ClientAuth = tls.NoClientCert
+	config.ClientCAs = nil
+	return config
+}
+
+// newGatewayTLSConfig returns the TLS config used by the gateway to call the
+// gRPC server in the same process. The gateway trusts only the certificate of
+// the server and presents it as client certificate when the server verifies them.
+func newGatewayTLSConfig(serverConfig *tls.Config) *tls.Config {
+	cert := serverConfig.Certificates[0]
+	roots := x509.NewCertPool()
+	roots.AddCert(cert.Leaf)
+
+	config := &tls.Config{
+		RootCAs:    roots,
+		MinVersion: tls.VersionTLS12,
+		// The gateway dials the local address, which may not be a name of the certificate.
+		ServerName: "localhost",
+	}
+	if len(cert.Leaf.DNSNames) > 0 {
+		config.ServerName = cert.Leaf.DNSNames[0]
+	} else if len(cert.Leaf.IPAddresses) > 0 {
+		config.ServerName = cert.Leaf.IPAddresses[0].String()
+	}
+	if serverConfig.ClientAuth == tls.RequireAndVerifyClientCert {
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config
+}
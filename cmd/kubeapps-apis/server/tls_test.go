@@ -0,0 +1,240 @@
+/*
+Copyright © 2021 VMware
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testCertificate is a certificate and its key for the TLS tests.
+type testCertificate struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+// newTestCertificate creates a certificate signed by the parent, or self-signed
+// when the parent is nil.
+func newTestCertificate(t *testing.T, name string, isCA bool, parent *testCertificate) *testCertificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+	}
+	if !isCA {
+		template.DNSNames = []string{name}
+	}
+	signer, signerKey := template, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	return &testCertificate{cert: cert, key: key}
+}
+
+// writeFiles writes the PEM encoded certificate and key to the directory.
+func (c *testCertificate) writeFiles(t *testing.T, dir, name string) (string, string) {
+	keyBytes, err := x509.MarshalECPrivateKey(c.key)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	certFile, keyFile := filepath.Join(dir, name+".crt"), filepath.Join(dir, name+".key")
+	if err := ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.cert.Raw}), 0600); err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if err := ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyBytes}), 0600); err != nil {
+		t.Fatalf("%+v", err)
+	}
+	return certFile, keyFile
+}
+
+// handshake returns the error of a TLS handshake between the client and server
+// configs, as seen by either of them.
+func handshake(t *testing.T, serverConfig, clientConfig *tls.Config) error {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	defer lis.Close()
+
+	serverErr := make(chan error, 1)
+	go func() {
+		conn, err := lis.Accept()
+		if err != nil {
+			serverErr <- err
+			return
+		}
+		defer conn.Close()
+		serverErr <- tls.Server(conn, serverConfig).Handshake()
+	}()
+
+	conn, err := tls.Dial("tcp", lis.Addr().String(), clientConfig)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	// With TLS 1.3, the client certificate is only verified by the server
+	// after the client completes the handshake.
+	return <-serverErr
+}
+
+func TestTLSConfigs(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCertificate(t, "kubeapps-ca", true, nil)
+	caFile, _ := ca.writeFiles(t, dir, "ca")
+	serverCertFile, serverKeyFile := newTestCertificate(t, "kubeapps-apis.kubeapps.svc", false, ca).writeFiles(t, dir, "server")
+	client := newTestCertificate(t, "client", false, ca)
+	otherCA := newTestCertificate(t, "other-ca", true, nil)
+
+	testCases := []struct {
+		name               string
+		clientCAFile       string
+		clientCertificates []*testCertificate
+		expectHandshakeErr bool
+	}{
+		{
+			name: "the gateway trusts the certificate of the server",
+		},
+		{
+			name:               "the gateway authenticates with the certificate of the server with mTLS",
+			clientCAFile:       caFile,
+			clientCertificates: []*testCertificate{client},
+		},
+		{
+			name:               "a client certificate not signed by the client CA is rejected",
+			clientCAFile:       caFile,
+			clientCertificates: []*testCertificate{newTestCertificate(t, "client", false, otherCA)},
+			expectHandshakeErr: true,
+		},
+		{
+			name:               "a client without certificate is rejected with mTLS",
+			clientCAFile:       caFile,
+			clientCertificates: []*testCertificate{nil},
+			expectHandshakeErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			serverConfig, err := newServerTLSConfig(ServeOptions{
+				TLSCertFile:     serverCertFile,
+				TLSKeyFile:      serverKeyFile,
+				TLSClientCAFile: tc.clientCAFile,
+			})
+			if err != nil {
+				t.Fatalf("%+v", err)
+			}
+
+			gatewayConfig := newGatewayTLSConfig(serverConfig)
+			if got, want := gatewayConfig.ServerName, "kubeapps-apis.kubeapps.svc"; got != want {
+				t.Errorf("got: %q, want: %q", got, want)
+			}
+			if err := handshake(t, serverConfig, gatewayConfig); err != nil {
+				t.Fatalf("gateway handshake failed: %+v", err)
+			}
+
+			for _, clientCert := range tc.clientCertificates {
+				clientConfig := &tls.Config{
+					RootCAs:    gatewayConfig.RootCAs,
+					ServerName: gatewayConfig.ServerName,
+				}
+				if clientCert != nil {
+					// The certificate is presented even if it is not signed by one
+					// of the CAs accepted by the server.
+					certificate := &tls.Certificate{
+						Certificate: [][]byte{clientCert.cert.Raw},
+						PrivateKey:  clientCert.key,
+					}
+					clientConfig.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+						return certificate, nil
+					}
+				}
+				err := handshake(t, serverConfig, clientConfig)
+				if got, want := err != nil, tc.expectHandshakeErr; got != want {
+					t.Errorf("got handshake error: %v, want error: %t", err, want)
+				}
+			}
+
+			// The browsers and HTTP clients are served without client certificate.
+			clientConfig := &tls.Config{
+				RootCAs:    gatewayConfig.RootCAs,
+				ServerName: gatewayConfig.ServerName,
+			}
+			if err := handshake(t, newPublicTLSConfig(serverConfig), clientConfig); err != nil {
+				t.Errorf("public handshake failed: %+v", err)
+			}
+		})
+	}
+}
+
+func TestNewServerTLSConfigErrors(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := newTestCertificate(t, "localhost", false, nil).writeFiles(t, dir, "server")
+	emptyFile := filepath.Join(dir, "empty.crt")
+	if err := ioutil.WriteFile(emptyFile, []byte{}, 0600); err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	testCases := []struct {
+		name      string
+		serveOpts ServeOptions
+	}{
+		{
+			name:      "it requires both the certificate and key",
+			serveOpts: ServeOptions{TLSCertFile: certFile},
+		},
+		{
+			name:      "it fails for a missing certificate",
+			serveOpts: ServeOptions{TLSCertFile: filepath.Join(dir, "missing.crt"), TLSKeyFile: keyFile},
+		},
+		{
+			name:      "it fails for a client CA file without certificates",
+			serveOpts: ServeOptions{TLSCertFile: certFile, TLSKeyFile: keyFile, TLSClientCAFile: emptyFile},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := newServerTLSConfig(tc.serveOpts); err == nil {
+				t.Errorf("got: nil, want: error")
+			}
+		})
+	}
+}
@@ -0,0 +1,246 @@
+/*
+Copyright © 2021 VMware
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpgrpc"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/semconv"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// TracerName is the name of the tracer used by the server. Plugins can create
+// their own spans with otel.Tracer, which uses the tracer provider configured
+// by the server.
+const TracerName = "github.com/kubeapps/kubeapps/cmd/kubeapps-apis"
+
+// initTracing configures the global tracer provider to export the spans to the
+// OTLP collector at the given endpoint, if any, and the global propagator so
+// that the trace context is propagated from the requests to the plugins, the
+// API servers and remote plugins. It returns a function to flush the spans
+// when the server stops.
+func initTracing(ctx context.Context, otlpEndpoint string) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	if otlpEndpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := otlp.NewExporter(ctx, otlpgrpc.NewDriver(
+		otlpgrpc.WithEndpoint(otlpEndpoint),
+		otlpgrpc.WithInsecure(),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("unable to create the OTLP exporter for %q: %w", otlpEndpoint, err)
+	}
+	res, err := resource.New(ctx, resource.WithAttributes(semconv.ServiceNameKey.String("kubeapps-apis")))
+	if err != nil {
+		return nil, err
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// metadataCarrier adapts the gRPC metadata to propagate the trace context.
+type metadataCarrier metadata.MD
+
+func (c metadataCarrier) Get(key string) string {
+	values := metadata.MD(c).Get(key)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+func (c metadataCarrier) Set(key, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// startServerSpan starts the span for a request received by the server,
+// continuing the trace of the caller, such as the gateway, if any.
+func startServerSpan(ctx context.Context, fullMethod string) (context.Context, trace.Span) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if ok {
+		ctx = otel.GetTextMapPropagator().Extract(ctx, metadataCarrier(md))
+	}
+	service, method := splitFullMethod(fullMethod)
+	return otel.Tracer(TracerName).Start(ctx, fullMethod[1:],
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			semconv.RPCSystemGRPC,
+			semconv.RPCServiceKey.String(service),
+			semconv.RPCMethodKey.String(method),
+		))
+}
+
+// endSpan ends the span, recording the status of the error if any.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		s := status.Convert(err)
+		span.SetAttributes(attribute.String("rpc.grpc.status_code", s.Code().String()))
+		span.SetStatus(otelcodes.Error, s.Message())
+	}
+	span.End()
+}
+
+// splitFullMethod splits a full gRPC method, such as
+// "/kubeappsapis.core.packages.v1alpha1.PackagesService/GetAvailablePackageDetail",
+// into its service and method names.
+func splitFullMethod(fullMethod string) (string, string) {
+	fullMethod = strings.TrimPrefix(fullMethod, "/")
+	if i := strings.LastIndex(fullMethod, "/"); i >= 0 {
+		return fullMethod[:i], fullMethod[i+1:]
+	}
+	return "", fullMethod
+}
+
+// tracingUnaryInterceptor is the grpc.UnaryServerInterceptor creating a span for each request.
+func tracingUnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, span := startServerSpan(ctx, info.FullMethod)
+	response, err := handler(ctx, req)
+	endSpan(span, err)
+	return response, err
+}
+
+// tracingStreamInterceptor is the grpc.StreamServerInterceptor creating a span for each streaming request.
+func tracingStreamInterceptor(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, span := startServerSpan(stream.Context(), info.FullMethod)
+	err := handler(srv, &contextServerStream{ServerStream: stream, ctx: ctx})
+	endSpan(span, err)
+	return err
+}
+
+// contextServerStream overrides the context of a server stream.
+type contextServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextServerStream) Context() context.Context {
+	return s.ctx
+}
+
+// tracingUnaryClientInterceptor propagates the trace context to the server
+// called by the gateway or to a remote plugin.
+func tracingUnaryClientInterceptor(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	return invoker(injectTraceContext(ctx), method, req, reply, cc, opts...)
+}
+
+// tracingStreamClientInterceptor propagates the trace context for streaming requests.
+func tracingStreamClientInterceptor(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	return streamer(injectTraceContext(ctx), desc, cc, method, opts...)
+}
+
+func injectTraceContext(ctx context.Context) context.Context {
+	md, ok := metadata.FromOutgoingContext(ctx)
+	if ok {
+		md = md.Copy()
+	} else {
+		md = metadata.MD{}
+	}
+	otel.GetTextMapPropagator().Inject(ctx, metadataCarrier(md))
+	return metadata.NewOutgoingContext(ctx, md)
+}
+
+// tracingHandler creates a span for each HTTP request, continuing the trace
+// of the client if any, which the gateway then propagates to the gRPC server.
+func tracingHandler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := otel.Tracer(TracerName).Start(ctx, fmt.Sprintf("HTTP %s", r.Method),
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(semconv.HTTPServerAttributesFromHTTPRequest("kubeapps-apis", "", r)...))
+		defer span.End()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		handler.ServeHTTP(recorder, r.WithContext(ctx))
+		span.SetAttributes(semconv.HTTPAttributesFromHTTPStatusCode(recorder.status)...)
+		span.SetStatus(semconv.SpanStatusFromHTTPStatusCode(recorder.status))
+	})
+}
+
+// statusRecorder records the status code of an HTTP response.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Flush supports the streaming responses of the gateway.
+func (r *statusRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// tracingRoundTripper creates a client span for each outgoing HTTP request,
+// propagating the trace context.
+type tracingRoundTripper struct {
+	next http.RoundTripper
+}
+
+// NewTracingRoundTripper returns a round tripper creating a span for each
+// request, such as those to the API servers or to fetch charts, so that their
+// latency is visible in the trace of the request to the plugin.
+func NewTracingRoundTripper(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &tracingRoundTripper{next: next}
+}
+
+func (t *tracingRoundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
+	ctx, span := otel.Tracer(TracerName).Start(r.Context(), fmt.Sprintf("HTTP %s", r.Method),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.HTTPClientAttributesFromHTTPRequest(r)...))
+	defer span.End()
+
+	r = r.Clone(ctx)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(r.Header))
+	response, err := t.next.RoundTrip(r)
+	if err != nil {
+		span.SetStatus(otelcodes.Error, err.Error())
+		return response, err
+	}
+	span.SetAttributes(semconv.HTTPAttributesFromHTTPStatusCode(response.StatusCode)...)
+	span.SetStatus(semconv.SpanStatusFromHTTPStatusCode(response.StatusCode))
+	return response, nil
+}
@@ -0,0 +1,177 @@
+/*
+Copyright © 2021 VMware
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.opentelemetry.io/otel"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// setupTestTracing records the spans in memory for the duration of the test.
+func setupTestTracing(t *testing.T) *tracetest.InMemoryExporter {
+	if _, err := initTracing(context.Background(), ""); err != nil {
+		t.Fatalf("%+v", err)
+	}
+	exporter := tracetest.NewInMemoryExporter()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+	return exporter
+}
+
+func TestTracingInterceptorsPropagateTraceContext(t *testing.T) {
+	testCases := []struct {
+		name               string
+		handlerErr         error
+		expectedStatusCode otelcodes.Code
+	}{
+		{
+			name:               "it continues the trace of the caller",
+			expectedStatusCode: otelcodes.Unset,
+		},
+		{
+			name:               "it records the status of a failed request",
+			handlerErr:         status.Errorf(codes.NotFound, "not found"),
+			expectedStatusCode: otelcodes.Error,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			exporter := setupTestTracing(t)
+
+			// The gateway sends the request within the span of the HTTP request.
+			clientCtx, clientSpan := otel.Tracer(TracerName).Start(context.Background(), "HTTP GET")
+			var outgoing metadata.MD
+			invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+				outgoing, _ = metadata.FromOutgoingContext(ctx)
+				return nil
+			}
+			if err := tracingUnaryClientInterceptor(clientCtx, "/test.Service/Get", nil, nil, nil, invoker); err != nil {
+				t.Fatalf("%+v", err)
+			}
+			clientSpan.End()
+
+			var handlerSpanContext trace.SpanContext
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+				handlerSpanContext = trace.SpanContextFromContext(ctx)
+				return nil, tc.handlerErr
+			}
+			serverCtx := metadata.NewIncomingContext(context.Background(), outgoing)
+			_, err := tracingUnaryInterceptor(serverCtx, nil, &grpc.UnaryServerInfo{FullMethod: "/test.Service/Get"}, handler)
+			if got, want := err, tc.handlerErr; got != want {
+				t.Fatalf("got: %v, want: %v", got, want)
+			}
+
+			spans := exporter.GetSpans()
+			if got, want := len(spans), 2; got != want {
+				t.Fatalf("got: %d spans, want: %d", got, want)
+			}
+			serverSpan := spans[1]
+			if got, want := serverSpan.Name, "test.Service/Get"; got != want {
+				t.Errorf("got: %q, want: %q", got, want)
+			}
+			if got, want := serverSpan.Parent.SpanID(), clientSpan.SpanContext().SpanID(); got != want {
+				t.Errorf("got parent: %s, want: %s", got, want)
+			}
+			if got, want := handlerSpanContext.TraceID(), clientSpan.SpanContext().TraceID(); got != want {
+				t.Errorf("got trace: %s, want: %s", got, want)
+			}
+			if got, want := serverSpan.StatusCode, tc.expectedStatusCode; got != want {
+				t.Errorf("got: %v, want: %v", got, want)
+			}
+		})
+	}
+}
+
+func TestTracingRoundTripper(t *testing.T) {
+	exporter := setupTestTracing(t)
+
+	var traceparent string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer ts.Close()
+
+	ctx, parent := otel.Tracer(TracerName).Start(context.Background(), "plugin")
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL, nil)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	response, err := NewTracingRoundTripper(nil).RoundTrip(request)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	response.Body.Close()
+	parent.End()
+
+	spans := exporter.GetSpans()
+	if got, want := len(spans), 2; got != want {
+		t.Fatalf("got: %d spans, want: %d", got, want)
+	}
+	clientSpan := spans[0]
+	if got, want := clientSpan.Parent.SpanID(), parent.SpanContext().SpanID(); got != want {
+		t.Errorf("got parent: %s, want: %s", got, want)
+	}
+	if got, want := clientSpan.StatusCode, otelcodes.Error; got != want {
+		t.Errorf("got: %v, want: %v", got, want)
+	}
+	if traceparent == "" {
+		t.Errorf("got: no traceparent header, want: the trace context of the client span")
+	}
+}
+
+func TestPluginInstrumentation(t *testing.T) {
+	exporter := setupTestTracing(t)
+
+	plugin := makeDefaultTestPackagingPlugin("instrumented")
+	health := &pluginHealth{}
+	pluginErr := status.Errorf(codes.Internal, "boom")
+	server := &instrumentedPackagesServer{
+		&testPackagingPluginServer{plugin: plugin, err: pluginErr},
+		pluginInstrumentation{plugin, health},
+	}
+
+	_, err := server.GetAvailablePackageSummaries(context.Background(), nil)
+	if got, want := err, pluginErr; got != want {
+		t.Fatalf("got: %v, want: %v", got, want)
+	}
+
+	spans := exporter.GetSpans()
+	if got, want := len(spans), 1; got != want {
+		t.Fatalf("got: %d spans, want: %d", got, want)
+	}
+	if got, want := spans[0].Name, "instrumented/GetAvailablePackageSummaries"; got != want {
+		t.Errorf("got: %q, want: %q", got, want)
+	}
+	if got, want := testutil.ToFloat64(pluginRequestsTotal.WithLabelValues("instrumented", "GetAvailablePackageSummaries", "Internal")), 1.0; got != want {
+		t.Errorf("got: %v requests, want: %v", got, want)
+	}
+	if got, want := health.getLastError(), pluginErr.Error(); got != want {
+		t.Errorf("got: %q, want: %q", got, want)
+	}
+}
@@ -0,0 +1,73 @@
+/*
+Copyright © 2021 VMware
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package server
+
+import (
+	"github.com/Masterminds/semver/v3"
+	packages "github.com/kubeapps/kubeapps/cmd/kubeapps-apis/gen/core/packages/v1alpha1"
+)
+
+// The number of versions of each kind included in the summary of the versions
+// of a package.
+const (
+	MajorVersionsInSummary = 3
+	MinorVersionsInSummary = 3
+	PatchVersionsInSummary = 3
+)
+
+// PackageAppVersionsSummary returns the summary of the versions of a package,
+// given from the most recent: at most MajorVersionsInSummary major versions,
+// MinorVersionsInSummary minor versions of each and PatchVersionsInSummary
+// patch versions of each minor version. The versions which are not semantic
+// versions are skipped. Plugins should use it so that the versions of their
+// packages are summarized consistently.
+func PackageAppVersionsSummary(versions []*packages.GetAvailablePackageVersionsResponse_PackageAppVersion) []*packages.GetAvailablePackageVersionsResponse_PackageAppVersion {
+	pav := []*packages.GetAvailablePackageVersionsResponse_PackageAppVersion{}
+
+	// Use a version map to be able to count how many major, minor and patch versions
+	// we have included.
+	versionMap := map[uint64]map[uint64][]uint64{}
+	for _, v := range versions {
+		version, err := semver.NewVersion(v.PkgVersion)
+		if err != nil {
+			continue
+		}
+
+		if _, ok := versionMap[version.Major()]; !ok {
+			// Don't add a new major version if we already have enough
+			if len(versionMap) >= MajorVersionsInSummary {
+				continue
+			}
+		} else if _, ok := versionMap[version.Major()][version.Minor()]; !ok {
+			// Don't add a new minor version if we already have enough for this major version
+			if len(versionMap[version.Major()]) >= MinorVersionsInSummary {
+				continue
+			}
+		} else if len(versionMap[version.Major()][version.Minor()]) >= PatchVersionsInSummary {
+			continue
+		}
+
+		// Include the version and update the version map.
+		pav = append(pav, &packages.GetAvailablePackageVersionsResponse_PackageAppVersion{
+			PkgVersion: v.PkgVersion,
+			AppVersion: v.AppVersion,
+		})
+
+		if _, ok := versionMap[version.Major()]; !ok {
+			versionMap[version.Major()] = map[uint64][]uint64{}
+		}
+		versionMap[version.Major()][version.Minor()] = append(versionMap[version.Major()][version.Minor()], version.Patch())
+	}
+
+	return pav
+}
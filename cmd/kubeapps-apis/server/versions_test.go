@@ -0,0 +1,190 @@
+/*
+Copyright © 2021 VMware
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package server
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	packages "github.com/kubeapps/kubeapps/cmd/kubeapps-apis/gen/core/packages/v1alpha1"
+)
+
+func TestPackageAppVersionsSummary(t *testing.T) {
+	testCases := []struct {
+		name            string
+		versions        []*packages.GetAvailablePackageVersionsResponse_PackageAppVersion
+		expectedSummary []*packages.GetAvailablePackageVersionsResponse_PackageAppVersion
+	}{
+		{
+			name: "it includes the latest three major versions only",
+			versions: []*packages.GetAvailablePackageVersionsResponse_PackageAppVersion{
+				{PkgVersion: "8.5.6", AppVersion: "1.0.0"},
+				{PkgVersion: "7.5.6", AppVersion: "1.0.0"},
+				{PkgVersion: "6.5.6", AppVersion: "1.0.0"},
+				{PkgVersion: "5.5.6", AppVersion: "1.0.0"},
+			},
+			expectedSummary: []*packages.GetAvailablePackageVersionsResponse_PackageAppVersion{
+				{PkgVersion: "8.5.6", AppVersion: "1.0.0"},
+				{PkgVersion: "7.5.6", AppVersion: "1.0.0"},
+				{PkgVersion: "6.5.6", AppVersion: "1.0.0"},
+			},
+		},
+		{
+			name: "it includes the latest three minor versions for each major version only",
+			versions: []*packages.GetAvailablePackageVersionsResponse_PackageAppVersion{
+				{PkgVersion: "8.5.6", AppVersion: "1.0.0"},
+				{PkgVersion: "8.4.6", AppVersion: "1.0.0"},
+				{PkgVersion: "8.3.6", AppVersion: "1.0.0"},
+				{PkgVersion: "8.2.6", AppVersion: "1.0.0"},
+			},
+			expectedSummary: []*packages.GetAvailablePackageVersionsResponse_PackageAppVersion{
+				{PkgVersion: "8.5.6", AppVersion: "1.0.0"},
+				{PkgVersion: "8.4.6", AppVersion: "1.0.0"},
+				{PkgVersion: "8.3.6", AppVersion: "1.0.0"},
+			},
+		},
+		{
+			name: "it includes the latest three patch versions for each minor version only",
+			versions: []*packages.GetAvailablePackageVersionsResponse_PackageAppVersion{
+				{PkgVersion: "8.5.6", AppVersion: "1.0.0"},
+				{PkgVersion: "8.5.5", AppVersion: "1.0.0"},
+				{PkgVersion: "8.5.4", AppVersion: "1.0.0"},
+				{PkgVersion: "8.5.3", AppVersion: "1.0.0"},
+			},
+			expectedSummary: []*packages.GetAvailablePackageVersionsResponse_PackageAppVersion{
+				{PkgVersion: "8.5.6", AppVersion: "1.0.0"},
+				{PkgVersion: "8.5.5", AppVersion: "1.0.0"},
+				{PkgVersion: "8.5.4", AppVersion: "1.0.0"},
+			},
+		},
+		{
+			name: "it includes the latest three patch versions of the latest three minor versions of the latest three major versions only",
+			versions: []*packages.GetAvailablePackageVersionsResponse_PackageAppVersion{
+				{PkgVersion: "8.5.6", AppVersion: "1.0.0"},
+				{PkgVersion: "8.5.5", AppVersion: "1.0.0"},
+				{PkgVersion: "8.5.4", AppVersion: "1.0.0"},
+				{PkgVersion: "8.5.3", AppVersion: "1.0.0"},
+				{PkgVersion: "8.4.6", AppVersion: "1.0.0"},
+				{PkgVersion: "8.4.5", AppVersion: "1.0.0"},
+				{PkgVersion: "8.4.4", AppVersion: "1.0.0"},
+				{PkgVersion: "8.4.3", AppVersion: "1.0.0"},
+				{PkgVersion: "8.3.6", AppVersion: "1.0.0"},
+				{PkgVersion: "8.3.5", AppVersion: "1.0.0"},
+				{PkgVersion: "8.3.4", AppVersion: "1.0.0"},
+				{PkgVersion: "8.3.3", AppVersion: "1.0.0"},
+				{PkgVersion: "8.2.6", AppVersion: "1.0.0"},
+				{PkgVersion: "8.2.5", AppVersion: "1.0.0"},
+				{PkgVersion: "8.2.4", AppVersion: "1.0.0"},
+				{PkgVersion: "8.2.3", AppVersion: "1.0.0"},
+				{PkgVersion: "6.5.6", AppVersion: "1.0.0"},
+				{PkgVersion: "6.5.5", AppVersion: "1.0.0"},
+				{PkgVersion: "6.5.4", AppVersion: "1.0.0"},
+				{PkgVersion: "6.5.3", AppVersion: "1.0.0"},
+				{PkgVersion: "6.4.6", AppVersion: "1.0.0"},
+				{PkgVersion: "6.4.5", AppVersion: "1.0.0"},
+				{PkgVersion: "6.4.4", AppVersion: "1.0.0"},
+				{PkgVersion: "6.4.3", AppVersion: "1.0.0"},
+				{PkgVersion: "6.3.6", AppVersion: "1.0.0"},
+				{PkgVersion: "6.3.5", AppVersion: "1.0.0"},
+				{PkgVersion: "6.3.4", AppVersion: "1.0.0"},
+				{PkgVersion: "6.3.3", AppVersion: "1.0.0"},
+				{PkgVersion: "6.2.6", AppVersion: "1.0.0"},
+				{PkgVersion: "6.2.5", AppVersion: "1.0.0"},
+				{PkgVersion: "6.2.4", AppVersion: "1.0.0"},
+				{PkgVersion: "6.2.3", AppVersion: "1.0.0"},
+				{PkgVersion: "4.5.6", AppVersion: "1.0.0"},
+				{PkgVersion: "4.5.5", AppVersion: "1.0.0"},
+				{PkgVersion: "4.5.4", AppVersion: "1.0.0"},
+				{PkgVersion: "4.5.3", AppVersion: "1.0.0"},
+				{PkgVersion: "4.4.6", AppVersion: "1.0.0"},
+				{PkgVersion: "4.4.5", AppVersion: "1.0.0"},
+				{PkgVersion: "4.4.4", AppVersion: "1.0.0"},
+				{PkgVersion: "4.4.3", AppVersion: "1.0.0"},
+				{PkgVersion: "4.3.6", AppVersion: "1.0.0"},
+				{PkgVersion: "4.3.5", AppVersion: "1.0.0"},
+				{PkgVersion: "4.3.4", AppVersion: "1.0.0"},
+				{PkgVersion: "4.3.3", AppVersion: "1.0.0"},
+				{PkgVersion: "4.2.6", AppVersion: "1.0.0"},
+				{PkgVersion: "4.2.5", AppVersion: "1.0.0"},
+				{PkgVersion: "4.2.4", AppVersion: "1.0.0"},
+				{PkgVersion: "4.2.3", AppVersion: "1.0.0"},
+				{PkgVersion: "2.5.6", AppVersion: "1.0.0"},
+				{PkgVersion: "2.5.5", AppVersion: "1.0.0"},
+				{PkgVersion: "2.5.4", AppVersion: "1.0.0"},
+				{PkgVersion: "2.5.3", AppVersion: "1.0.0"},
+				{PkgVersion: "2.4.6", AppVersion: "1.0.0"},
+				{PkgVersion: "2.4.5", AppVersion: "1.0.0"},
+				{PkgVersion: "2.4.4", AppVersion: "1.0.0"},
+				{PkgVersion: "2.4.3", AppVersion: "1.0.0"},
+				{PkgVersion: "2.3.6", AppVersion: "1.0.0"},
+				{PkgVersion: "2.3.5", AppVersion: "1.0.0"},
+				{PkgVersion: "2.3.4", AppVersion: "1.0.0"},
+				{PkgVersion: "2.3.3", AppVersion: "1.0.0"},
+				{PkgVersion: "2.2.6", AppVersion: "1.0.0"},
+				{PkgVersion: "2.2.5", AppVersion: "1.0.0"},
+				{PkgVersion: "2.2.4", AppVersion: "1.0.0"},
+				{PkgVersion: "2.2.3", AppVersion: "1.0.0"},
+			},
+			expectedSummary: []*packages.GetAvailablePackageVersionsResponse_PackageAppVersion{
+				{PkgVersion: "8.5.6", AppVersion: "1.0.0"},
+				{PkgVersion: "8.5.5", AppVersion: "1.0.0"},
+				{PkgVersion: "8.5.4", AppVersion: "1.0.0"},
+				{PkgVersion: "8.4.6", AppVersion: "1.0.0"},
+				{PkgVersion: "8.4.5", AppVersion: "1.0.0"},
+				{PkgVersion: "8.4.4", AppVersion: "1.0.0"},
+				{PkgVersion: "8.3.6", AppVersion: "1.0.0"},
+				{PkgVersion: "8.3.5", AppVersion: "1.0.0"},
+				{PkgVersion: "8.3.4", AppVersion: "1.0.0"},
+				{PkgVersion: "6.5.6", AppVersion: "1.0.0"},
+				{PkgVersion: "6.5.5", AppVersion: "1.0.0"},
+				{PkgVersion: "6.5.4", AppVersion: "1.0.0"},
+				{PkgVersion: "6.4.6", AppVersion: "1.0.0"},
+				{PkgVersion: "6.4.5", AppVersion: "1.0.0"},
+				{PkgVersion: "6.4.4", AppVersion: "1.0.0"},
+				{PkgVersion: "6.3.6", AppVersion: "1.0.0"},
+				{PkgVersion: "6.3.5", AppVersion: "1.0.0"},
+				{PkgVersion: "6.3.4", AppVersion: "1.0.0"},
+				{PkgVersion: "4.5.6", AppVersion: "1.0.0"},
+				{PkgVersion: "4.5.5", AppVersion: "1.0.0"},
+				{PkgVersion: "4.5.4", AppVersion: "1.0.0"},
+				{PkgVersion: "4.4.6", AppVersion: "1.0.0"},
+				{PkgVersion: "4.4.5", AppVersion: "1.0.0"},
+				{PkgVersion: "4.4.4", AppVersion: "1.0.0"},
+				{PkgVersion: "4.3.6", AppVersion: "1.0.0"},
+				{PkgVersion: "4.3.5", AppVersion: "1.0.0"},
+				{PkgVersion: "4.3.4", AppVersion: "1.0.0"},
+			},
+		},
+		{
+			name: "it skips the versions which are not semver",
+			versions: []*packages.GetAvailablePackageVersionsResponse_PackageAppVersion{
+				{PkgVersion: "latest", AppVersion: "1.0.0"},
+				{PkgVersion: "1.2.3", AppVersion: "1.0.0"},
+			},
+			expectedSummary: []*packages.GetAvailablePackageVersionsResponse_PackageAppVersion{
+				{PkgVersion: "1.2.3", AppVersion: "1.0.0"},
+			},
+		},
+	}
+
+	opts := cmpopts.IgnoreUnexported(packages.GetAvailablePackageVersionsResponse_PackageAppVersion{})
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got, want := PackageAppVersionsSummary(tc.versions), tc.expectedSummary; !cmp.Equal(want, got, opts) {
+				t.Errorf("mismatch (-want +got):\n%s", cmp.Diff(want, got, opts))
+			}
+		})
+	}
+}
@@ -17,6 +17,7 @@ package chart
 
 import (
 	"bytes"
+	"context"
 	"crypto/x509"
 	"encoding/json"
 	"fmt"
@@ -39,6 +40,8 @@ import (
 	"github.com/kubeapps/kubeapps/pkg/kube"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	typfake "k8s.io/client-go/kubernetes/fake"
 	chartv2 "k8s.io/helm/pkg/proto/hapi/chart"
 	"k8s.io/helm/pkg/repo"
 )
@@ -838,6 +841,22 @@ func TestGetRegistrySecretsPerDomain(t *testing.T) {
 			if got, want := secretsPerDomain, tc.secretsPerDomain; !cmp.Equal(want, got) {
 				t.Errorf("mismatch (-want +got):\n%s", cmp.Diff(want, got))
 			}
+
+			// The same result is expected when reading the secrets with a typed client.
+			typedObjects := []runtime.Object{}
+			for _, s := range tc.existingSecrets {
+				typedObjects = append(typedObjects, s)
+			}
+			typedClient := typfake.NewSimpleClientset(typedObjects...)
+
+			secretsPerDomain, err = RegistrySecretsPerDomainWithClient(context.Background(), tc.secretNames, namespace, typedClient)
+			if err != nil {
+				t.Fatalf("%+v", err)
+			}
+
+			if got, want := secretsPerDomain, tc.secretsPerDomain; !cmp.Equal(want, got) {
+				t.Errorf("mismatch (-want +got):\n%s", cmp.Diff(want, got))
+			}
 		})
 	}
 }
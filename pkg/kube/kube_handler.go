@@ -302,17 +302,23 @@ type ValidationResponse struct {
 // this one and adds one method, to force call-sites to explicitly use a UserHandler
 // or ServiceHandler.
 type handler interface {
+	AppRepositoryHandler
+	GetNamespaces(precheckedNamespaces []corev1.Namespace) ([]corev1.Namespace, error)
+	GetSecret(name, namespace string) (*corev1.Secret, error)
+	ValidateAppRepository(appRepoBody io.ReadCloser, requestNamespace string) (*ValidationResponse, error)
+	GetOperatorLogo(namespace, name string) ([]byte, error)
+	CanI(resourceAttributes *authorizationapi.ResourceAttributes) (bool, error)
+}
+
+// AppRepositoryHandler exposes the AppRepository operations of a user handler, so that
+// they can be used by other components which already have a config for the user.
+type AppRepositoryHandler interface {
 	ListAppRepositories(requestNamespace string) (*v1alpha1.AppRepositoryList, error)
 	CreateAppRepository(appRepoBody io.ReadCloser, requestNamespace string) (*v1alpha1.AppRepository, error)
 	UpdateAppRepository(appRepoBody io.ReadCloser, requestNamespace string) (*v1alpha1.AppRepository, error)
 	RefreshAppRepository(repoName string, requestNamespace string) (*v1alpha1.AppRepository, error)
 	DeleteAppRepository(name, namespace string) error
-	GetNamespaces(precheckedNamespaces []corev1.Namespace) ([]corev1.Namespace, error)
-	GetSecret(name, namespace string) (*corev1.Secret, error)
 	GetAppRepository(repoName, repoNamespace string) (*v1alpha1.AppRepository, error)
-	ValidateAppRepository(appRepoBody io.ReadCloser, requestNamespace string) (*ValidationResponse, error)
-	GetOperatorLogo(namespace, name string) ([]byte, error)
-	CanI(resourceAttributes *authorizationapi.ResourceAttributes) (bool, error)
 }
 
 // AuthHandler exposes Handler functionality as a user or the current serviceaccount
@@ -399,12 +405,13 @@ func (a *kubeHandler) AsSVC(cluster string) (handler, error) {
 	}, nil
 }
 
-// appRepositoryRequest is used to parse the JSON request
-type appRepositoryRequest struct {
-	AppRepository appRepositoryRequestDetails `json:"appRepository"`
+// AppRepositoryRequest is used to parse the JSON request
+type AppRepositoryRequest struct {
+	AppRepository AppRepositoryRequestDetails `json:"appRepository"`
 }
 
-type appRepositoryRequestDetails struct {
+// AppRepositoryRequestDetails are the details of the AppRepository in the JSON request
+type AppRepositoryRequestDetails struct {
 	Name                  string                  `json:"name"`
 	Type                  string                  `json:"type"`
 	RepoURL               string                  `json:"repoURL"`
@@ -481,6 +488,24 @@ func NewHandler(kubeappsNamespace, namespaceHeaderName, namespaceHeaderPattern s
 	}, nil
 }
 
+// NewAppRepositoryHandler returns an AppRepositoryHandler using the user config, and the
+// service config for the AppRepositories and secrets in the kubeapps namespace.
+func NewAppRepositoryHandler(kubeappsNamespace string, userConfig, svcConfig *rest.Config) (AppRepositoryHandler, error) {
+	clientset, err := clientsetForConfig(userConfig)
+	if err != nil {
+		return nil, err
+	}
+	svcClientset, err := clientsetForConfig(svcConfig)
+	if err != nil {
+		return nil, err
+	}
+	return &userHandler{
+		kubeappsNamespace: kubeappsNamespace,
+		svcClientset:      svcClientset,
+		clientset:         clientset,
+	}, nil
+}
+
 // clientsetForConfig returns a clientset using the provided config.
 func clientsetForConfig(config *rest.Config) (combinedClientsetInterface, error) {
 	arclientset, err := apprepoclientset.NewForConfig(config)
@@ -494,8 +519,8 @@ func clientsetForConfig(config *rest.Config) (combinedClientsetInterface, error)
 	return &combinedClientset{arclientset, coreclientset, coreclientset.RESTClient()}, nil
 }
 
-func parseRepoRequest(appRepoBody io.ReadCloser) (*appRepositoryRequest, error) {
-	var appRepoRequest appRepositoryRequest
+func parseRepoRequest(appRepoBody io.ReadCloser) (*AppRepositoryRequest, error) {
+	var appRepoRequest AppRepositoryRequest
 	err := json.NewDecoder(appRepoBody).Decode(&appRepoRequest)
 	if err != nil {
 		log.Infof("unable to decode: %v", err)
@@ -797,7 +822,7 @@ func (a *userHandler) GetAppRepository(repoName, repoNamespace string) (*v1alpha
 }
 
 // appRepositoryForRequest takes care of parsing the request data into an AppRepository.
-func appRepositoryForRequest(appRepoRequest *appRepositoryRequest) *v1alpha1.AppRepository {
+func appRepositoryForRequest(appRepoRequest *AppRepositoryRequest) *v1alpha1.AppRepository {
 	appRepo := appRepoRequest.AppRepository
 
 	var auth v1alpha1.AppRepositoryAuth
@@ -859,7 +884,7 @@ func appRepositoryForRequest(appRepoRequest *appRepositoryRequest) *v1alpha1.App
 }
 
 // secretForRequest takes care of parsing the request data into a secret for an AppRepository.
-func (a *userHandler) secretForRequest(appRepoRequest *appRepositoryRequest, appRepo *v1alpha1.AppRepository, namespace string) (*corev1.Secret, error) {
+func (a *userHandler) secretForRequest(appRepoRequest *AppRepositoryRequest, appRepo *v1alpha1.AppRepository, namespace string) (*corev1.Secret, error) {
 	if len(appRepoRequest.AppRepository.AuthRegCreds) > 0 {
 		return a.GetSecret(appRepoRequest.AppRepository.AuthRegCreds, namespace)
 	}
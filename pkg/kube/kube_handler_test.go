@@ -170,8 +170,8 @@ func checkErr(t *testing.T, err error, expectedError error) {
 	}
 }
 
-func checkAppRepo(t *testing.T, requestData string, requestNamespace string, cs fakeCombinedClientset) (appRepositoryRequest, *v1alpha1.AppRepository, *v1alpha1.AppRepository) {
-	var appRepoRequest appRepositoryRequest
+func checkAppRepo(t *testing.T, requestData string, requestNamespace string, cs fakeCombinedClientset) (AppRepositoryRequest, *v1alpha1.AppRepository, *v1alpha1.AppRepository) {
+	var appRepoRequest AppRepositoryRequest
 	err := json.NewDecoder(strings.NewReader(requestData)).Decode(&appRepoRequest)
 	if err != nil {
 		t.Fatalf("%+v", err)
@@ -192,7 +192,7 @@ func checkAppRepo(t *testing.T, requestData string, requestNamespace string, cs
 	return appRepoRequest, expectedAppRepo, responseAppRepo
 }
 
-func checkSecrets(t *testing.T, requestNamespace string, appRepoRequest appRepositoryRequest, expectedAppRepo *v1alpha1.AppRepository, responseAppRepo *v1alpha1.AppRepository, handler userHandler) {
+func checkSecrets(t *testing.T, requestNamespace string, appRepoRequest AppRepositoryRequest, expectedAppRepo *v1alpha1.AppRepository, responseAppRepo *v1alpha1.AppRepository, handler userHandler) {
 	// TODO(#1655)
 	// The fake k8s API does not generate UID's for created object
 	// (among other things). We would need to add reactors to the fake
@@ -614,12 +614,12 @@ func errorCodeForK8sError(t *testing.T, err error) int {
 func TestAppRepositoryForRequest(t *testing.T) {
 	testCases := []struct {
 		name    string
-		request appRepositoryRequestDetails
+		request AppRepositoryRequestDetails
 		appRepo v1alpha1.AppRepository
 	}{
 		{
 			name: "it creates an app repo without auth",
-			request: appRepositoryRequestDetails{
+			request: AppRepositoryRequestDetails{
 				Name:    "test-repo",
 				Type:    "helm",
 				RepoURL: "http://example.com/test-repo",
@@ -636,7 +636,7 @@ func TestAppRepositoryForRequest(t *testing.T) {
 		},
 		{
 			name: "it creates an app repo with auth header",
-			request: appRepositoryRequestDetails{
+			request: AppRepositoryRequestDetails{
 				Name:       "test-repo",
 				Type:       "helm",
 				RepoURL:    "http://example.com/test-repo",
@@ -664,7 +664,7 @@ func TestAppRepositoryForRequest(t *testing.T) {
 		},
 		{
 			name: "it creates an app repo with custom CA",
-			request: appRepositoryRequestDetails{
+			request: AppRepositoryRequestDetails{
 				Name:     "test-repo",
 				Type:     "helm",
 				RepoURL:  "http://example.com/test-repo",
@@ -692,7 +692,7 @@ func TestAppRepositoryForRequest(t *testing.T) {
 		},
 		{
 			name: "it creates an app repo with a sync job",
-			request: appRepositoryRequestDetails{
+			request: AppRepositoryRequestDetails{
 				Name:    "test-repo",
 				Type:    "helm",
 				RepoURL: "http://example.com/test-repo",
@@ -719,7 +719,7 @@ func TestAppRepositoryForRequest(t *testing.T) {
 		},
 		{
 			name: "it creates an app repo with a resync requests",
-			request: appRepositoryRequestDetails{
+			request: AppRepositoryRequestDetails{
 				Name:           "test-repo",
 				Type:           "helm",
 				RepoURL:        "http://example.com/test-repo",
@@ -738,7 +738,7 @@ func TestAppRepositoryForRequest(t *testing.T) {
 		},
 		{
 			name: "it defaults type to helm",
-			request: appRepositoryRequestDetails{
+			request: AppRepositoryRequestDetails{
 				Name:    "test-repo",
 				RepoURL: "http://example.com/test-repo",
 			},
@@ -754,7 +754,7 @@ func TestAppRepositoryForRequest(t *testing.T) {
 		},
 		{
 			name: "it creates an OCI app repo",
-			request: appRepositoryRequestDetails{
+			request: AppRepositoryRequestDetails{
 				Name:            "test-repo",
 				Type:            "oci",
 				RepoURL:         "http://example.com/test-repo",
@@ -773,7 +773,7 @@ func TestAppRepositoryForRequest(t *testing.T) {
 		},
 		{
 			name: "it creates an app repo with a description",
-			request: appRepositoryRequestDetails{
+			request: AppRepositoryRequestDetails{
 				Name:        "test-repo",
 				Type:        "oci",
 				RepoURL:     "http://example.com/test-repo",
@@ -794,7 +794,7 @@ func TestAppRepositoryForRequest(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			if got, want := appRepositoryForRequest(&appRepositoryRequest{tc.request}), &tc.appRepo; !cmp.Equal(want, got) {
+			if got, want := appRepositoryForRequest(&AppRepositoryRequest{tc.request}), &tc.appRepo; !cmp.Equal(want, got) {
 				t.Errorf("mismatch (-want +got):\n%s", cmp.Diff(want, got))
 			}
 		})
@@ -834,12 +834,12 @@ func TestSecretForRequest(t *testing.T) {
 
 	testCases := []struct {
 		name    string
-		request appRepositoryRequestDetails
+		request AppRepositoryRequestDetails
 		secret  *corev1.Secret
 	}{
 		{
 			name: "it does not create a secret without auth",
-			request: appRepositoryRequestDetails{
+			request: AppRepositoryRequestDetails{
 				Name:    "test-repo",
 				RepoURL: "http://example.com/test-repo",
 			},
@@ -847,7 +847,7 @@ func TestSecretForRequest(t *testing.T) {
 		},
 		{
 			name: "it creates a secret with an auth header",
-			request: appRepositoryRequestDetails{
+			request: AppRepositoryRequestDetails{
 				Name:       "test-repo",
 				RepoURL:    "http://example.com/test-repo",
 				AuthHeader: "testing",
@@ -864,7 +864,7 @@ func TestSecretForRequest(t *testing.T) {
 		},
 		{
 			name: "it creates a secret with custom CA",
-			request: appRepositoryRequestDetails{
+			request: AppRepositoryRequestDetails{
 				Name:     "test-repo",
 				RepoURL:  "http://example.com/test-repo",
 				CustomCA: "test-me",
@@ -881,7 +881,7 @@ func TestSecretForRequest(t *testing.T) {
 		},
 		{
 			name: "uses the given secret for docker creds",
-			request: appRepositoryRequestDetails{
+			request: AppRepositoryRequestDetails{
 				Name:         "test-repo",
 				RepoURL:      "http://example.com/test-repo",
 				AuthRegCreds: dockerCredsSecret.Name,
@@ -903,7 +903,7 @@ func TestSecretForRequest(t *testing.T) {
 				clientset:         cs,
 			}
 
-			secret, err := handler.secretForRequest(&appRepositoryRequest{tc.request}, &appRepo, "default")
+			secret, err := handler.secretForRequest(&AppRepositoryRequest{tc.request}, &appRepo, "default")
 			if err != nil {
 				t.Fatalf("unexpected error %v", err)
 			}